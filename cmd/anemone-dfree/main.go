@@ -40,11 +40,16 @@ func main() {
 		os.Exit(0)
 	}
 
-	var username, shareType string
+	// Group shares live in /srv/anemone/shares/@groups/{group}/{share} and
+	// share the quota of their group
+	var username, shareType, groupDir string
 	for i, part := range parts {
 		if part == "shares" && i+2 < len(parts) {
 			username = parts[i+1]
 			shareType = parts[i+2]
+			if username == "@groups" {
+				groupDir = string(os.PathSeparator) + filepath.Join(parts[:i+3]...)
+			}
 			break
 		}
 	}
@@ -79,6 +84,16 @@ func main() {
 	}
 	defer db.Close()
 
+	if groupDir != "" {
+		var quotaGB int
+		if err := db.QueryRow(`SELECT quota_gb FROM groups WHERE name = ?`, shareType).Scan(&quotaGB); err != nil || quotaGB == 0 {
+			fmt.Println("1024 10737418240 10737418240")
+			os.Exit(0)
+		}
+		printSpace(int64(quotaGB)*1024*1024, calculateDirectorySize(groupDir)/blockSize)
+		return
+	}
+
 	// Get user quota from database
 	var quotaTotalGB, quotaBackupGB int
 	err = db.QueryRow(`
@@ -110,23 +125,27 @@ func main() {
 	usedBytes := calculateDirectorySize(sharePath)
 	usedBlocks := usedBytes / blockSize
 
-	// Calculate total and free blocks
-	var totalBlocks, freeBlocks int64
+	// Calculate total blocks
+	var totalBlocks int64
 	if quotaGB == 0 {
 		// Unlimited quota: return very large value (10 TB)
 		totalBlocks = 10 * 1024 * 1024 * 1024 // 10 TB in KB blocks
-		freeBlocks = totalBlocks - usedBlocks
 	} else {
 		totalBlocks = int64(quotaGB) * 1024 * 1024 // GB to KB blocks
-		freeBlocks = totalBlocks - usedBlocks
 	}
 
+	printSpace(totalBlocks, usedBlocks)
+}
+
+// printSpace prints the space in Samba dfree format: blocksize total_blocks free_blocks
+func printSpace(totalBlocks, usedBlocks int64) {
+	freeBlocks := totalBlocks - usedBlocks
+
 	// Ensure free blocks is not negative
 	if freeBlocks < 0 {
 		freeBlocks = 0
 	}
 
-	// Output in Samba dfree format: blocksize total_blocks free_blocks
 	fmt.Printf("%d %d %d\n", blockSize, totalBlocks, freeBlocks)
 }
 
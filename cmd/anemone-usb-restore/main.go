@@ -0,0 +1,250 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+// anemone-usb-restore reads an Anemone USB backup without a running server:
+// it prints the drive header, decrypts the backup to a directory, or changes
+// the drive passphrase.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/juste-un-gars/anemone/internal/usbbackup"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, `Usage: %[1]s <command> [options]
+
+Commands:
+  info    -dir <backup dir> [-passphrase ...]        Show the drive header and its shares
+  restore -dir <backup dir> -out <dir> [-share <dir>] [-config] [-passphrase ...]
+                                                     Decrypt shares (and configuration) to <dir>
+  rewrap  -dir <backup dir> [-passphrase ...] [-new-passphrase ...]
+                                                     Change the drive passphrase
+
+The passphrase may also be given with $ANEMONE_USB_PASSPHRASE (and the new one with
+$ANEMONE_USB_NEW_PASSPHRASE). A master key or recovery passphrase is accepted as well.
+`, os.Args[0])
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(1)
+	}
+
+	switch os.Args[1] {
+	case "info":
+		runInfo(os.Args[2:])
+	case "restore":
+		runRestore(os.Args[2:])
+	case "rewrap":
+		runRewrap(os.Args[2:])
+	case "-h", "-help", "--help", "help":
+		usage()
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command: %s\n\n", os.Args[1])
+		usage()
+		os.Exit(1)
+	}
+}
+
+func runInfo(args []string) {
+	fs := flag.NewFlagSet("info", flag.ExitOnError)
+	dir := fs.String("dir", "", "Backup directory on the drive")
+	passphrase := fs.String("passphrase", os.Getenv("ANEMONE_USB_PASSPHRASE"), "Drive passphrase (optional, lists share details)")
+	fs.Parse(args)
+	backupPath := requireBackupDir(*dir)
+
+	key := ""
+	if *passphrase != "" {
+		k, err := usbbackup.ResolveDriveKey(backupPath, *passphrase)
+		if err != nil {
+			fatal("Error unlocking backup: %v", err)
+		}
+		key = k
+	}
+
+	info, err := usbbackup.InspectBackup(backupPath, key)
+	if err != nil {
+		fatal("Error reading backup: %v", err)
+	}
+
+	fmt.Printf("Backup:      %s\n", backupPath)
+	if h := info.Header; h != nil {
+		fmt.Printf("Format:      %s v%d\n", h.Format, h.Version)
+		fmt.Printf("Server:      %s (%s, id %s)\n", h.Server.Name, h.Server.Hostname, h.Server.ID)
+		fmt.Printf("Created:     %s\n", h.CreatedAt.Local().Format(time.RFC3339))
+		fmt.Printf("Updated:     %s\n", h.UpdatedAt.Local().Format(time.RFC3339))
+		fmt.Printf("Key ID:      %s\n", h.KeyID)
+		fmt.Printf("KDF:         %s (time %d, memory %d KiB, threads %d)\n", h.KDF.Algorithm, h.KDF.Time, h.KDF.Memory, h.KDF.Threads)
+	} else {
+		fmt.Printf("Format:      legacy (encrypted with the server master key)\n")
+	}
+	if info.Config != nil {
+		fmt.Printf("Config:      %s, %d files, %s\n", info.Config.SourceServer, info.Config.FilesCount, info.Config.Timestamp)
+	}
+	fmt.Printf("Shares:      %d\n", len(info.Shares))
+	for _, s := range info.Shares {
+		if s.Locked {
+			fmt.Printf("  %-40s locked\n", s.Dir)
+			continue
+		}
+		fmt.Printf("  %-40s %d files, %d bytes, last sync %s\n", s.Dir, s.FileCount, s.TotalSize, s.LastSync.Local().Format(time.RFC3339))
+	}
+}
+
+func runRestore(args []string) {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	dir := fs.String("dir", "", "Backup directory on the drive")
+	out := fs.String("out", "", "Directory receiving the decrypted files")
+	share := fs.String("share", "", "Only restore this share directory ({user_id}_{share})")
+	withConfig := fs.Bool("config", false, "Also decrypt the server configuration backup")
+	passphrase := fs.String("passphrase", os.Getenv("ANEMONE_USB_PASSPHRASE"), "Drive passphrase")
+	fs.Parse(args)
+	backupPath := requireBackupDir(*dir)
+
+	if *out == "" {
+		fatal("Missing -out directory")
+	}
+	outDir, err := filepath.Abs(*out)
+	if err != nil {
+		fatal("Invalid output directory: %v", err)
+	}
+
+	secret := *passphrase
+	if secret == "" {
+		secret = prompt("Drive passphrase: ")
+	}
+	key, err := usbbackup.ResolveDriveKey(backupPath, secret)
+	if err != nil {
+		fatal("Error unlocking backup: %v", err)
+	}
+
+	if *withConfig {
+		n, err := usbbackup.RestoreConfig(backupPath, key, filepath.Join(outDir, "config"))
+		if err != nil {
+			fatal("Error restoring configuration: %v", err)
+		}
+		fmt.Printf("Configuration: %d files written to %s\n", n, filepath.Join(outDir, "config"))
+	}
+
+	info, err := usbbackup.InspectBackup(backupPath, key)
+	if err != nil {
+		fatal("Error reading backup: %v", err)
+	}
+
+	failed := false
+	for _, s := range info.Shares {
+		if *share != "" && s.Dir != *share {
+			continue
+		}
+		if s.Locked {
+			fmt.Fprintf(os.Stderr, "%s: cannot be decrypted with this key, skipped\n", s.Dir)
+			failed = true
+			continue
+		}
+
+		job, err := usbbackup.StartRestore(usbbackup.RestoreRequest{
+			BackupPath: backupPath,
+			ShareDir:   s.Dir,
+			TargetDir:  filepath.Join(outDir, s.Dir),
+			MasterKey:  key,
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", s.Dir, err)
+			failed = true
+			continue
+		}
+
+		for job.Status == "running" {
+			time.Sleep(500 * time.Millisecond)
+			job, _ = usbbackup.GetRestoreJob(job.ID)
+		}
+		fmt.Printf("%s: %s, %d/%d files, %d skipped, %d failed\n",
+			s.Dir, job.Status, job.ProcessedFiles, job.TotalFiles, job.SkippedFiles, job.FailedFiles)
+		for _, e := range job.Errors {
+			fmt.Fprintf(os.Stderr, "  %s\n", e)
+		}
+		if job.Status != "success" {
+			failed = true
+		}
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func runRewrap(args []string) {
+	fs := flag.NewFlagSet("rewrap", flag.ExitOnError)
+	dir := fs.String("dir", "", "Backup directory on the drive")
+	passphrase := fs.String("passphrase", os.Getenv("ANEMONE_USB_PASSPHRASE"), "Current drive passphrase")
+	newPassphrase := fs.String("new-passphrase", os.Getenv("ANEMONE_USB_NEW_PASSPHRASE"), "New drive passphrase")
+	fs.Parse(args)
+	backupPath := requireBackupDir(*dir)
+
+	h, err := usbbackup.ReadHeader(backupPath)
+	if err != nil {
+		fatal("Error reading header: %v", err)
+	}
+
+	current := *passphrase
+	if current == "" {
+		current = prompt("Current drive passphrase: ")
+	}
+	next := *newPassphrase
+	if next == "" {
+		next = prompt("New drive passphrase: ")
+		if prompt("Confirm new passphrase: ") != next {
+			fatal("Passphrases do not match")
+		}
+	}
+
+	if err := h.Rewrap(current, next); err != nil {
+		fatal("Error changing passphrase: %v", err)
+	}
+	if err := usbbackup.WriteHeader(backupPath, h); err != nil {
+		fatal("Error writing header: %v", err)
+	}
+	fmt.Println("Drive passphrase changed. The server adopts it on its next backup to this drive.")
+}
+
+// requireBackupDir checks that dir holds an Anemone USB backup
+func requireBackupDir(dir string) string {
+	if dir == "" {
+		fatal("Missing -dir (backup directory on the drive)")
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		fatal("Invalid backup directory: %v", err)
+	}
+	if !usbbackup.IsBackupDir(abs) {
+		fatal("%s is not an Anemone USB backup", abs)
+	}
+	return abs
+}
+
+var stdin = bufio.NewReader(os.Stdin)
+
+// prompt reads a line from standard input
+func prompt(label string) string {
+	fmt.Fprint(os.Stderr, label)
+	line, err := stdin.ReadString('\n')
+	if err != nil && line == "" {
+		fatal("Error reading input: %v", err)
+	}
+	return strings.TrimRight(line, "\r\n")
+}
+
+func fatal(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(1)
+}
@@ -14,12 +14,20 @@ import (
 	"sync"
 	"time"
 
+	"github.com/juste-un-gars/anemone/internal/bulkrestore"
+	"github.com/juste-un-gars/anemone/internal/capacity"
 	"github.com/juste-un-gars/anemone/internal/config"
 	"github.com/juste-un-gars/anemone/internal/database"
+	"github.com/juste-un-gars/anemone/internal/diskcrypt"
+	"github.com/juste-un-gars/anemone/internal/groups"
+	"github.com/juste-un-gars/anemone/internal/integrity"
 	"github.com/juste-un-gars/anemone/internal/logger"
+	"github.com/juste-un-gars/anemone/internal/poolmon"
 	"github.com/juste-un-gars/anemone/internal/scheduler"
 	"github.com/juste-un-gars/anemone/internal/serverbackup"
 	"github.com/juste-un-gars/anemone/internal/setup"
+	"github.com/juste-un-gars/anemone/internal/smartmon"
+	"github.com/juste-un-gars/anemone/internal/snapshots"
 	syncpkg "github.com/juste-un-gars/anemone/internal/sync"
 	"github.com/juste-un-gars/anemone/internal/sysconfig"
 	"github.com/juste-un-gars/anemone/internal/rclone"
@@ -30,6 +38,7 @@ import (
 	"github.com/juste-un-gars/anemone/internal/usermanifest"
 	"github.com/juste-un-gars/anemone/internal/web"
 	wgpkg "github.com/juste-un-gars/anemone/internal/wireguard"
+	"github.com/juste-un-gars/anemone/internal/zfsrepl"
 )
 
 func main() {
@@ -121,6 +130,14 @@ func main() {
 		logger.Warn("Failed to cleanup zombie syncs", "error", err)
 	}
 
+	// Unlock the encrypted disks, pools and datasets before anything reads them
+	diskcrypt.UnlockAtBoot(db)
+
+	// Close the trashes of group shares to the other members
+	if err := groups.SecureTrashes(db); err != nil {
+		logger.Warn("Failed to secure group share trashes", "error", err)
+	}
+
 	// Start automatic synchronization scheduler
 	scheduler.Start(db)
 
@@ -130,11 +147,35 @@ func main() {
 	// Start automatic USB backup scheduler
 	usbbackup.StartScheduler(db, cfg.DataDir)
 
+	// Start USB hotplug monitor (backs up registered drives when plugged in)
+	usbbackup.StartHotplugMonitor(db, cfg.DataDir)
+
 	// Cleanup stale rclone "running" statuses from previous run
 	rclone.CleanupStaleRunning(db)
 
+	// Mark restore jobs interrupted by the previous shutdown as failed
+	bulkrestore.CleanupStaleJobs(db)
+
 	// Start automatic rclone (cloud) backup scheduler
-	rclone.StartScheduler(db, cfg.DataDir)
+	rclone.StartScheduler(db, cfg.DataDir, cfg.IncomingDir)
+
+	// Start scheduled integrity checks of USB drives and peers
+	integrity.StartScheduler(db, cfg.DataDir, cfg.IncomingDir)
+
+	// Start scheduled ZFS snapshots and their retention
+	snapshots.StartScheduler(db)
+
+	// Start ZFS replication to peers
+	zfsrepl.StartScheduler(db)
+
+	// Start SMART monitoring of the disks
+	smartmon.StartMonitor(db)
+
+	// Start ZFS pool monitoring and scheduled scrubs
+	poolmon.StartScheduler(db)
+
+	// Start the storage usage history and capacity forecasts
+	capacity.StartCollector(db, cfg.SharesDir, cfg.IncomingDir)
 
 	// Auto-connect WireGuard VPN if configured
 	if err := wgpkg.AutoConnect(db); err != nil {
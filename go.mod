@@ -7,6 +7,7 @@ require (
 	github.com/golang-jwt/jwt/v5 v5.3.1
 	github.com/mattn/go-sqlite3 v1.14.34
 	golang.org/x/crypto v0.48.0
+	golang.org/x/sys v0.41.0
 )
 
-require golang.org/x/sys v0.41.0 // indirect
+require golang.org/x/net v0.50.0
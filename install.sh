@@ -566,6 +566,15 @@ install_storage_tools() {
         fi
     fi
 
+    # acl for the trash of the members of group shares
+    if ! command -v setfacl &> /dev/null; then
+        if [ "$PKG_MANAGER" = "dnf" ]; then
+            dnf install -y acl
+        elif [ "$PKG_MANAGER" = "apt" ]; then
+            apt install -y acl
+        fi
+    fi
+
     # Filesystem tools for USB formatting (FAT32, exFAT)
     if ! command -v mkfs.vfat &> /dev/null; then
         log_info "Installing FAT32 tools (dosfstools)..."
@@ -741,6 +750,12 @@ $SERVICE_USER ALL=(ALL) NOPASSWD: /usr/sbin/userdel *
 $SERVICE_USER ALL=(ALL) NOPASSWD: /usr/sbin/usermod -aG anemone *
 $SERVICE_USER ALL=(ALL) NOPASSWD: /usr/bin/smbpasswd
 
+# Group management for group shares
+$SERVICE_USER ALL=(ALL) NOPASSWD: /usr/sbin/groupadd anemone-g*
+$SERVICE_USER ALL=(ALL) NOPASSWD: /usr/sbin/groupdel anemone-g*
+$SERVICE_USER ALL=(ALL) NOPASSWD: /usr/bin/gpasswd -a * anemone-g*
+$SERVICE_USER ALL=(ALL) NOPASSWD: /usr/bin/gpasswd -d * anemone-g*
+
 # File operations - restricted to data directory
 $SERVICE_USER ALL=(ALL) NOPASSWD: /usr/bin/chown * $DATA_DIR/*
 $SERVICE_USER ALL=(ALL) NOPASSWD: /usr/bin/chown -R * $DATA_DIR/*
@@ -756,10 +771,18 @@ $SERVICE_USER ALL=(ALL) NOPASSWD: /usr/bin/rm -rf $DATA_DIR/shares/*
 $SERVICE_USER ALL=(ALL) NOPASSWD: /usr/bin/rm -rf $DATA_DIR/backups/*
 $SERVICE_USER ALL=(ALL) NOPASSWD: /usr/bin/rmdir $DATA_DIR/*
 $SERVICE_USER ALL=(ALL) NOPASSWD: /usr/bin/mv $DATA_DIR/* $DATA_DIR/*
+$SERVICE_USER ALL=(ALL) NOPASSWD: /usr/bin/cp -a -T --reflink=auto $DATA_DIR/shares/*/.snapshots/* $DATA_DIR/shares/*
+$SERVICE_USER ALL=(ALL) NOPASSWD: /usr/bin/cp -a -T --reflink=auto */.zfs/snapshot/* $DATA_DIR/shares/*
+$SERVICE_USER ALL=(ALL) NOPASSWD: /usr/bin/setfacl -R -m * $DATA_DIR/shares/*
 
 # SMB configuration
 $SERVICE_USER ALL=(ALL) NOPASSWD: /usr/bin/cp $DATA_DIR/smb/smb.conf /etc/samba/smb.conf
 
+# NFS exports
+$SERVICE_USER ALL=(ALL) NOPASSWD: /usr/bin/mkdir -p /etc/exports.d
+$SERVICE_USER ALL=(ALL) NOPASSWD: /usr/bin/cp $DATA_DIR/nfs/anemone.exports /etc/exports.d/anemone.exports
+$SERVICE_USER ALL=(ALL) NOPASSWD: /usr/sbin/exportfs -ra
+
 # SELinux (RHEL/Fedora)
 $SERVICE_USER ALL=(ALL) NOPASSWD: /usr/sbin/semanage fcontext -a -t samba_share_t $DATA_DIR/*
 $SERVICE_USER ALL=(ALL) NOPASSWD: /usr/sbin/restorecon -Rv $DATA_DIR/*
@@ -770,6 +793,8 @@ $SERVICE_USER ALL=(ALL) NOPASSWD: /usr/sbin/setsebool -P samba_export_all_rw on
 $SERVICE_USER ALL=(ALL) NOPASSWD: /usr/bin/btrfs subvolume create $DATA_DIR/*
 $SERVICE_USER ALL=(ALL) NOPASSWD: /usr/bin/btrfs subvolume delete $DATA_DIR/*
 $SERVICE_USER ALL=(ALL) NOPASSWD: /usr/bin/btrfs subvolume show $DATA_DIR/*
+$SERVICE_USER ALL=(ALL) NOPASSWD: /usr/bin/btrfs subvolume snapshot -r $DATA_DIR/* $DATA_DIR/*
+$SERVICE_USER ALL=(ALL) NOPASSWD: /usr/bin/btrfs subvolume snapshot $DATA_DIR/* $DATA_DIR/*
 $SERVICE_USER ALL=(ALL) NOPASSWD: /usr/bin/btrfs qgroup *
 $SERVICE_USER ALL=(ALL) NOPASSWD: /usr/bin/btrfs quota enable *
 
@@ -780,6 +805,33 @@ $SERVICE_USER ALL=(ALL) NOPASSWD: /usr/sbin/zpool *
 $SERVICE_USER ALL=(ALL) NOPASSWD: /sbin/zfs *
 $SERVICE_USER ALL=(ALL) NOPASSWD: /usr/sbin/zfs *
 
+# Software RAID (mdadm), restricted to md arrays and mdadm.conf
+$SERVICE_USER ALL=(ALL) NOPASSWD: /sbin/mdadm --create /dev/md* *
+$SERVICE_USER ALL=(ALL) NOPASSWD: /usr/sbin/mdadm --create /dev/md* *
+$SERVICE_USER ALL=(ALL) NOPASSWD: /sbin/mdadm --detail --brief /dev/md*
+$SERVICE_USER ALL=(ALL) NOPASSWD: /usr/sbin/mdadm --detail --brief /dev/md*
+$SERVICE_USER ALL=(ALL) NOPASSWD: /sbin/mdadm /dev/md* *
+$SERVICE_USER ALL=(ALL) NOPASSWD: /usr/sbin/mdadm /dev/md* *
+$SERVICE_USER ALL=(ALL) NOPASSWD: /usr/bin/tee -a /etc/mdadm/mdadm.conf
+$SERVICE_USER ALL=(ALL) NOPASSWD: /usr/bin/tee -a /etc/mdadm.conf
+$SERVICE_USER ALL=(ALL) NOPASSWD: /usr/bin/tee /sys/block/md*/md/sync_action
+
+# Disk encryption (LUKS), restricted to disks and anemone-* volumes
+$SERVICE_USER ALL=(ALL) NOPASSWD: /sbin/cryptsetup luksFormat --type luks2 --batch-mode --key-file - /dev/sd*
+$SERVICE_USER ALL=(ALL) NOPASSWD: /sbin/cryptsetup open --type luks --key-file - /dev/sd* anemone-*
+$SERVICE_USER ALL=(ALL) NOPASSWD: /sbin/cryptsetup luksUUID /dev/sd*
+$SERVICE_USER ALL=(ALL) NOPASSWD: /sbin/cryptsetup luksFormat --type luks2 --batch-mode --key-file - /dev/nvme*
+$SERVICE_USER ALL=(ALL) NOPASSWD: /sbin/cryptsetup open --type luks --key-file - /dev/nvme* anemone-*
+$SERVICE_USER ALL=(ALL) NOPASSWD: /sbin/cryptsetup luksUUID /dev/nvme*
+$SERVICE_USER ALL=(ALL) NOPASSWD: /sbin/cryptsetup close anemone-*
+$SERVICE_USER ALL=(ALL) NOPASSWD: /usr/sbin/cryptsetup luksFormat --type luks2 --batch-mode --key-file - /dev/sd*
+$SERVICE_USER ALL=(ALL) NOPASSWD: /usr/sbin/cryptsetup open --type luks --key-file - /dev/sd* anemone-*
+$SERVICE_USER ALL=(ALL) NOPASSWD: /usr/sbin/cryptsetup luksUUID /dev/sd*
+$SERVICE_USER ALL=(ALL) NOPASSWD: /usr/sbin/cryptsetup luksFormat --type luks2 --batch-mode --key-file - /dev/nvme*
+$SERVICE_USER ALL=(ALL) NOPASSWD: /usr/sbin/cryptsetup open --type luks --key-file - /dev/nvme* anemone-*
+$SERVICE_USER ALL=(ALL) NOPASSWD: /usr/sbin/cryptsetup luksUUID /dev/nvme*
+$SERVICE_USER ALL=(ALL) NOPASSWD: /usr/sbin/cryptsetup close anemone-*
+
 # Disk formatting (for setup wizard and storage management)
 $SERVICE_USER ALL=(ALL) NOPASSWD: /usr/sbin/mkfs.ext4 *
 $SERVICE_USER ALL=(ALL) NOPASSWD: /sbin/mkfs.ext4 *
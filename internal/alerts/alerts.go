@@ -0,0 +1,98 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+// Package alerts keeps the alerts raised by the monitors of the server until an
+// admin acknowledges them. Every monitor records its alerts in the same table,
+// under its own source, and gives its own meaning to the kind and values.
+package alerts
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Alert sources
+const (
+	SourceSMART    = "smart"    // Disk alerts of the SMART monitor
+	SourcePool     = "pool"     // Pool and md array alerts of the pool monitor
+	SourceCapacity = "capacity" // Series forecast to be full soon
+)
+
+// Alert severities
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+// Alert is an event worth the attention of an admin
+type Alert struct {
+	ID           int       `json:"id"`
+	Source       string    `json:"source"`
+	Subject      string    `json:"subject"` // What raised the alert (disk key, pool name, series key)
+	Device       string    `json:"device"`
+	Label        string    `json:"label"` // Human readable name of the subject
+	Severity     string    `json:"severity"`
+	Kind         string    `json:"kind"`
+	Attribute    string    `json:"attribute"`
+	OldValue     string    `json:"old_value"`
+	NewValue     string    `json:"new_value"`
+	Acknowledged bool      `json:"acknowledged"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// Raise records an alert
+func Raise(db *sql.DB, a Alert) error {
+	_, err := db.Exec(`INSERT INTO alerts (source, subject, device, label, severity, kind, attribute, old_value, new_value)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		a.Source, a.Subject, a.Device, a.Label, a.Severity, a.Kind, a.Attribute, a.OldValue, a.NewValue)
+	if err != nil {
+		return fmt.Errorf("failed to record alert: %w", err)
+	}
+	return nil
+}
+
+// List returns the most recent alerts of a source, or of all of them when
+// source is empty, optionally only those not acknowledged yet
+func List(db *sql.DB, source string, pendingOnly bool, limit int) ([]Alert, error) {
+	query := `SELECT id, source, subject, device, label, severity, kind, attribute, old_value, new_value,
+		acknowledged, created_at FROM alerts WHERE (? = '' OR source = ?)`
+	if pendingOnly {
+		query += ` AND acknowledged = 0`
+	}
+	query += ` ORDER BY created_at DESC, id DESC LIMIT ?`
+
+	rows, err := db.Query(query, source, source, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query alerts: %w", err)
+	}
+	defer rows.Close()
+
+	alerts := []Alert{}
+	for rows.Next() {
+		var a Alert
+		if err := rows.Scan(&a.ID, &a.Source, &a.Subject, &a.Device, &a.Label, &a.Severity, &a.Kind,
+			&a.Attribute, &a.OldValue, &a.NewValue, &a.Acknowledged, &a.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan alert: %w", err)
+		}
+		alerts = append(alerts, a)
+	}
+	return alerts, rows.Err()
+}
+
+// Acknowledge marks an alert as seen. When id is 0 it marks all the alerts of a
+// source, or of all of them when source is empty.
+func Acknowledge(db *sql.DB, source string, id int) error {
+	var err error
+	if id == 0 {
+		_, err = db.Exec("UPDATE alerts SET acknowledged = 1 WHERE acknowledged = 0 AND (? = '' OR source = ?)", source, source)
+	} else {
+		_, err = db.Exec("UPDATE alerts SET acknowledged = 1 WHERE id = ?", id)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to acknowledge alert: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+package alerts
+
+import (
+	"database/sql"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// setupTestDB creates an in-memory SQLite database with the alerts table
+func setupTestDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	_, err = db.Exec(`
+		CREATE TABLE alerts (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			source TEXT NOT NULL,
+			subject TEXT NOT NULL,
+			device TEXT DEFAULT '',
+			label TEXT DEFAULT '',
+			severity TEXT NOT NULL,
+			kind TEXT NOT NULL,
+			attribute TEXT DEFAULT '',
+			old_value TEXT DEFAULT '',
+			new_value TEXT DEFAULT '',
+			acknowledged BOOLEAN DEFAULT 0,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`)
+	if err != nil {
+		t.Fatalf("Failed to create tables: %v", err)
+	}
+	return db
+}
+
+func TestAcknowledgeBySource(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	for _, a := range []Alert{
+		{Source: SourceSMART, Subject: "disk-a", Severity: SeverityWarning, Kind: "health"},
+		{Source: SourceSMART, Subject: "disk-b", Severity: SeverityCritical, Kind: "health"},
+		{Source: "other", Subject: "x", Severity: SeverityInfo, Kind: "state"},
+	} {
+		if err := Raise(db, a); err != nil {
+			t.Fatalf("Raise failed: %v", err)
+		}
+	}
+
+	if all, _ := List(db, "", true, 10); len(all) != 3 {
+		t.Fatalf("List of all sources = %d alerts, want 3", len(all))
+	}
+	smart, err := List(db, SourceSMART, true, 10)
+	if err != nil || len(smart) != 2 {
+		t.Fatalf("List(smart) = %+v, %v, want 2 alerts", smart, err)
+	}
+
+	// Acknowledging one alert leaves the others pending
+	if err := Acknowledge(db, SourceSMART, smart[0].ID); err != nil {
+		t.Fatalf("Acknowledge failed: %v", err)
+	}
+	if pending, _ := List(db, SourceSMART, true, 10); len(pending) != 1 {
+		t.Errorf("after one acknowledgement %d SMART alerts pending, want 1", len(pending))
+	}
+
+	// Acknowledging all alerts of a source leaves the other sources alone
+	if err := Acknowledge(db, SourceSMART, 0); err != nil {
+		t.Fatalf("Acknowledge failed: %v", err)
+	}
+	if pending, _ := List(db, SourceSMART, true, 10); len(pending) != 0 {
+		t.Errorf("%d SMART alerts still pending", len(pending))
+	}
+	if pending, _ := List(db, "", true, 10); len(pending) != 1 || pending[0].Source != "other" {
+		t.Errorf("pending alerts = %+v, want the other source only", pending)
+	}
+	if all, _ := List(db, SourceSMART, false, 10); len(all) != 2 || !all[0].Acknowledged {
+		t.Errorf("acknowledged alerts = %+v, want 2 listed as acknowledged", all)
+	}
+}
@@ -439,3 +439,30 @@ func TestSessionDurations(t *testing.T) {
 		t.Errorf("RememberMeDuration should be %v, got %v", expectedRemember, RememberMeDuration)
 	}
 }
+
+func TestRestorePending(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	_, err := db.Exec(`
+		CREATE TABLE system_config (key TEXT PRIMARY KEY, value TEXT);
+		CREATE TABLE users (id INTEGER PRIMARY KEY, restore_acknowledged BOOLEAN DEFAULT 0);
+		INSERT INTO users (id, restore_acknowledged) VALUES (1, 0), (2, 1);`)
+	if err != nil {
+		t.Fatalf("Failed to create tables: %v", err)
+	}
+
+	if RestorePending(db, 1) {
+		t.Error("RestorePending should be false on a server that was not restored")
+	}
+
+	if _, err := db.Exec("INSERT INTO system_config (key, value) VALUES ('server_restored', '1')"); err != nil {
+		t.Fatalf("Failed to mark server restored: %v", err)
+	}
+	if !RestorePending(db, 1) {
+		t.Error("RestorePending should be true until the user acknowledges the restore")
+	}
+	if RestorePending(db, 2) {
+		t.Error("RestorePending should be false once the user acknowledged the restore")
+	}
+}
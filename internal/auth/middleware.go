@@ -91,25 +91,7 @@ func RequireRestoreCheck(db *sql.DB, next http.HandlerFunc) http.HandlerFunc {
 			return
 		}
 
-		// Check if server has been restored
-		var serverRestored string
-		err := db.QueryRow("SELECT value FROM system_config WHERE key = 'server_restored'").Scan(&serverRestored)
-		if err != nil || serverRestored != "1" {
-			// No restoration or error, continue normally
-			next.ServeHTTP(w, r)
-			return
-		}
-
-		// Check if user has acknowledged the restore
-		var restoreAcknowledged bool
-		err = db.QueryRow("SELECT restore_acknowledged FROM users WHERE id = ?", session.UserID).Scan(&restoreAcknowledged)
-		if err != nil {
-			// Error reading, continue normally
-			next.ServeHTTP(w, r)
-			return
-		}
-
-		if !restoreAcknowledged {
+		if RestorePending(db, session.UserID) {
 			// User needs to acknowledge restore, redirect to warning page
 			http.Redirect(w, r, "/restore-warning", http.StatusSeeOther)
 			return
@@ -119,3 +101,24 @@ func RequireRestoreCheck(db *sql.DB, next http.HandlerFunc) http.HandlerFunc {
 		next.ServeHTTP(w, r)
 	}
 }
+
+// RestorePending reports whether the server has been restored and the user
+// has not acknowledged it yet
+func RestorePending(db *sql.DB, userID int) bool {
+	// Check if server has been restored
+	var serverRestored string
+	err := db.QueryRow("SELECT value FROM system_config WHERE key = 'server_restored'").Scan(&serverRestored)
+	if err != nil || serverRestored != "1" {
+		// No restoration or error, continue normally
+		return false
+	}
+
+	// Check if user has acknowledged the restore
+	var restoreAcknowledged bool
+	err = db.QueryRow("SELECT restore_acknowledged FROM users WHERE id = ?", userID).Scan(&restoreAcknowledged)
+	if err != nil {
+		// Error reading, continue normally
+		return false
+	}
+	return !restoreAcknowledged
+}
@@ -6,6 +6,7 @@
 package backup
 
 import (
+	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
@@ -31,6 +32,7 @@ type ServerBackup struct {
 	Peers         []PeerBackup     `json:"peers"`
 	SyncConfig    *SyncConfig      `json:"sync_config"`
 	WireGuard     *WireGuardBackup `json:"wireguard,omitempty"`
+	RcloneBackups []RcloneBackup   `json:"rclone_backups,omitempty"`
 }
 
 // ConfigItem represents a key-value pair from system_config
@@ -65,6 +67,8 @@ type ShareBackup struct {
 	Path        string    `json:"path"`
 	Protocol    string    `json:"protocol"`
 	SyncEnabled bool      `json:"sync_enabled"`
+	MinReplicas int       `json:"min_replicas"`
+	PeerIDs     []int     `json:"peer_ids,omitempty"`
 	CreatedAt   time.Time `json:"created_at"`
 }
 
@@ -115,6 +119,28 @@ type WireGuardBackup struct {
 	UpdatedAt           time.Time `json:"updated_at"`
 }
 
+// RcloneBackup represents a cloud backup destination.
+// ProviderConfig is kept as the raw JSON stored in the database.
+type RcloneBackup struct {
+	ID                  int    `json:"id"`
+	Name                string `json:"name"`
+	ProviderType        string `json:"provider_type"`
+	ProviderConfig      string `json:"provider_config"`
+	SFTPHost            string `json:"sftp_host"`
+	SFTPPort            int    `json:"sftp_port"`
+	SFTPUser            string `json:"sftp_user"`
+	SFTPKeyPath         string `json:"sftp_key_path"`
+	SFTPPassword        string `json:"sftp_password"`
+	RemotePath          string `json:"remote_path"`
+	Enabled             bool   `json:"enabled"`
+	SyncEnabled         bool   `json:"sync_enabled"`
+	SyncFrequency       string `json:"sync_frequency"`
+	SyncTime            string `json:"sync_time"`
+	SyncDayOfWeek       *int   `json:"sync_day_of_week"`
+	SyncDayOfMonth      *int   `json:"sync_day_of_month"`
+	SyncIntervalMinutes int    `json:"sync_interval_minutes"`
+}
+
 // ExportConfiguration exports the complete server configuration to JSON
 func ExportConfiguration(db *sql.DB, serverName string) (*ServerBackup, error) {
 	backup := &ServerBackup{
@@ -184,7 +210,7 @@ func ExportConfiguration(db *sql.DB, serverName string) (*ServerBackup, error) {
 	}
 
 	// Export shares
-	shareRows, err := db.Query(`SELECT id, user_id, name, path, protocol, sync_enabled, created_at FROM shares`)
+	shareRows, err := db.Query(`SELECT id, user_id, name, path, protocol, sync_enabled, COALESCE(min_replicas, 0), created_at FROM shares`)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query shares: %w", err)
 	}
@@ -193,12 +219,31 @@ func ExportConfiguration(db *sql.DB, serverName string) (*ServerBackup, error) {
 	for shareRows.Next() {
 		var share ShareBackup
 		if err := shareRows.Scan(&share.ID, &share.UserID, &share.Name, &share.Path,
-			&share.Protocol, &share.SyncEnabled, &share.CreatedAt); err != nil {
+			&share.Protocol, &share.SyncEnabled, &share.MinReplicas, &share.CreatedAt); err != nil {
 			return nil, fmt.Errorf("failed to scan share row: %w", err)
 		}
 		backup.Shares = append(backup.Shares, share)
 	}
 
+	// Export per-share peer selection
+	sharePeerRows, err := db.Query(`SELECT share_id, peer_id FROM share_peers ORDER BY share_id, peer_id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query share peers: %w", err)
+	}
+	defer sharePeerRows.Close()
+
+	sharePeers := make(map[int][]int)
+	for sharePeerRows.Next() {
+		var shareID, peerID int
+		if err := sharePeerRows.Scan(&shareID, &peerID); err != nil {
+			return nil, fmt.Errorf("failed to scan share peer row: %w", err)
+		}
+		sharePeers[shareID] = append(sharePeers[shareID], peerID)
+	}
+	for i := range backup.Shares {
+		backup.Shares[i].PeerIDs = sharePeers[backup.Shares[i].ID]
+	}
+
 	// Export peers
 	peerRows, err := db.Query(`SELECT id, name, address, port, public_key, password, enabled, status,
 		sync_enabled, sync_frequency, sync_time, sync_day_of_week, sync_day_of_month, sync_interval_minutes,
@@ -301,6 +346,38 @@ func ExportConfiguration(db *sql.DB, serverName string) (*ServerBackup, error) {
 		backup.WireGuard = &wgConfig
 	}
 
+	// Export rclone_backups
+	rcloneRows, err := db.Query(`SELECT id, name, COALESCE(provider_type, 'sftp'), COALESCE(provider_config, '{}'),
+		COALESCE(sftp_host, ''), COALESCE(sftp_port, 22), COALESCE(sftp_user, ''), COALESCE(sftp_key_path, ''),
+		COALESCE(sftp_password, ''), remote_path, enabled, sync_enabled, sync_frequency, sync_time,
+		sync_day_of_week, sync_day_of_month, sync_interval_minutes
+		FROM rclone_backups ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query rclone_backups: %w", err)
+	}
+	defer rcloneRows.Close()
+
+	for rcloneRows.Next() {
+		var rb RcloneBackup
+		var dayOfWeek, dayOfMonth sql.NullInt64
+		err := rcloneRows.Scan(&rb.ID, &rb.Name, &rb.ProviderType, &rb.ProviderConfig,
+			&rb.SFTPHost, &rb.SFTPPort, &rb.SFTPUser, &rb.SFTPKeyPath,
+			&rb.SFTPPassword, &rb.RemotePath, &rb.Enabled, &rb.SyncEnabled, &rb.SyncFrequency, &rb.SyncTime,
+			&dayOfWeek, &dayOfMonth, &rb.SyncIntervalMinutes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan rclone backup: %w", err)
+		}
+		if dayOfWeek.Valid {
+			v := int(dayOfWeek.Int64)
+			rb.SyncDayOfWeek = &v
+		}
+		if dayOfMonth.Valid {
+			v := int(dayOfMonth.Int64)
+			rb.SyncDayOfMonth = &v
+		}
+		backup.RcloneBackups = append(backup.RcloneBackups, rb)
+	}
+
 	return backup, nil
 }
 
@@ -340,8 +417,81 @@ func EncryptBackup(backup *ServerBackup, passphrase string) ([]byte, error) {
 	return ciphertext, nil
 }
 
-// DecryptBackup decrypts the encrypted backup data
+// PassphraseFormat identifies the backups encrypted by EncryptBackupWithPassphrase
+const PassphraseFormat = "anemone-server-backup"
+
+// PassphraseVersion is the current version of the passphrase format
+const PassphraseVersion = 1
+
+// passphraseEnvelope is a backup encrypted with a key derived from a passphrase
+// with salted Argon2id. Unlike EncryptBackup, which is meant for the master key,
+// it resists offline guessing of the passphrase.
+type passphraseEnvelope struct {
+	Format  string                  `json:"format"`
+	Version int                     `json:"version"`
+	KDF     crypto.PassphraseParams `json:"kdf"`
+	Data    string                  `json:"data"` // base64(nonce || AES-256-GCM(backup JSON))
+}
+
+// EncryptBackupWithPassphrase encrypts the backup JSON with a key derived from
+// the passphrase, for the copies stored away from the server
+func EncryptBackupWithPassphrase(backup *ServerBackup, passphrase string) ([]byte, error) {
+	jsonData, err := json.Marshal(backup)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal backup: %w", err)
+	}
+
+	sealed, params, err := crypto.SealWithPassphrase(jsonData, passphrase, []byte(PassphraseFormat))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt backup: %w", err)
+	}
+	return json.Marshal(&passphraseEnvelope{
+		Format:  PassphraseFormat,
+		Version: PassphraseVersion,
+		KDF:     params,
+		Data:    base64.StdEncoding.EncodeToString(sealed),
+	})
+}
+
+// decryptPassphraseEnvelope decrypts a backup written by EncryptBackupWithPassphrase.
+// ok is false when the data is not in that format.
+func decryptPassphraseEnvelope(encryptedData []byte, passphrase string) (jsonData []byte, ok bool, err error) {
+	// The legacy format starts with a random nonce, the envelope with '{'
+	if !bytes.HasPrefix(encryptedData, []byte("{")) {
+		return nil, false, nil
+	}
+	var envelope passphraseEnvelope
+	if json.Unmarshal(encryptedData, &envelope) != nil || envelope.Format != PassphraseFormat {
+		return nil, false, nil
+	}
+	if envelope.Version > PassphraseVersion {
+		return nil, true, fmt.Errorf("unsupported backup version %d", envelope.Version)
+	}
+	sealed, err := base64.StdEncoding.DecodeString(envelope.Data)
+	if err != nil {
+		return nil, true, fmt.Errorf("invalid backup data: %w", err)
+	}
+	jsonData, err = crypto.OpenWithPassphrase(sealed, passphrase, envelope.KDF, []byte(PassphraseFormat))
+	if err != nil {
+		return nil, true, fmt.Errorf("failed to decrypt: %w", err)
+	}
+	return jsonData, true, nil
+}
+
+// DecryptBackup decrypts the encrypted backup data, in the format of
+// EncryptBackup or EncryptBackupWithPassphrase
 func DecryptBackup(encryptedData []byte, passphrase string) (*ServerBackup, error) {
+	if jsonData, ok, err := decryptPassphraseEnvelope(encryptedData, passphrase); ok {
+		if err != nil {
+			return nil, err
+		}
+		var backup ServerBackup
+		if err := json.Unmarshal(jsonData, &backup); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal backup: %w", err)
+		}
+		return &backup, nil
+	}
+
 	// Derive key from passphrase using SHA-256
 	keyHash := sha256.Sum256([]byte(passphrase))
 	key := keyHash[:]
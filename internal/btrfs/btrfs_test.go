@@ -7,6 +7,7 @@ package btrfs
 import (
 	"os"
 	"testing"
+	"time"
 )
 
 // TestIsSubvolume tests the IsSubvolume function
@@ -66,3 +67,61 @@ func TestIsSubvolumeEmptyPath(t *testing.T) {
 		t.Error("IsSubvolume should return false for empty path")
 	}
 }
+
+// TestParseQgroupShow tests parsing qgroup accounting, with and without the path column
+func TestParseQgroupShow(t *testing.T) {
+	output := `qgroupid         rfer         excl     max_rfer
+--------         ----         ----     --------
+0/5             16384        16384         none
+0/256      1073741824      1048576  10737418240
+0/257      1073741824        16384         none  shares/.snapshots/backup/daily
+1/100      2147483648      1064960         none
+`
+	usage := parseQgroupShow(output)
+	if len(usage) != 3 {
+		t.Fatalf("parseQgroupShow returned %d qgroups, want 3", len(usage))
+	}
+	if u := usage["256"]; u.Referenced != 1073741824 || u.Exclusive != 1048576 || u.Limit != 10737418240 {
+		t.Errorf("usage[256] = %+v", u)
+	}
+	if u := usage["257"]; u.Exclusive != 16384 || u.Limit != 0 {
+		t.Errorf("usage[257] = %+v", u)
+	}
+}
+
+// TestParseSubvolumeShow tests parsing the ID, creation time and flags of a subvolume
+func TestParseSubvolumeShow(t *testing.T) {
+	output := `/srv/anemone/shares/alice/.snapshots/backup/auto-daily-20250301-0000
+	Name: 			auto-daily-20250301-0000
+	UUID: 			1c2f3b0e-7a52-4c4b-9d1a-0d6f5c0c7f11
+	Parent UUID: 		7b0e1d2c-3a4b-4c5d-8e9f-0a1b2c3d4e5f
+	Creation time: 		2025-03-01 01:00:02 +0100
+	Subvolume ID: 		263
+	Generation: 		58
+	Flags: 			readonly
+`
+	info := parseSubvolumeShow(output)
+	if info.ID != "263" || !info.ReadOnly {
+		t.Errorf("parseSubvolumeShow = %+v", info)
+	}
+	if !info.Created.Equal(time.Date(2025, 3, 1, 0, 0, 2, 0, time.UTC)) {
+		t.Errorf("Created = %v", info.Created)
+	}
+}
+
+// TestSnapshotPaths tests where snapshots are stored and which names are accepted
+func TestSnapshotPaths(t *testing.T) {
+	if dir := SnapshotDir("/srv/shares/alice/backup"); dir != "/srv/shares/alice/.snapshots/backup" {
+		t.Errorf("SnapshotDir = %q", dir)
+	}
+	for _, bad := range []string{"", "../x", "a/b", ".hidden"} {
+		if _, err := snapshotPath("/srv/shares/alice/backup", bad); err == nil {
+			t.Errorf("snapshotPath accepted name %q", bad)
+		}
+	}
+	for _, bad := range []string{"relative/path", "/srv/../etc", "/"} {
+		if _, err := snapshotPath(bad, "snap"); err == nil {
+			t.Errorf("snapshotPath accepted subvolume %q", bad)
+		}
+	}
+}
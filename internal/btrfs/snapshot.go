@@ -0,0 +1,327 @@
+package btrfs
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// SnapshotsDirName is the directory holding the snapshots of the subvolumes of a
+// directory. Snapshots of /shares/alice/backup live in /shares/alice/.snapshots/backup.
+const SnapshotsDirName = ".snapshots"
+
+// superMagic is BTRFS_SUPER_MAGIC from /usr/include/linux/magic.h
+const superMagic = 0x9123683E
+
+var snapshotNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_\-.:]*$`)
+
+// Snapshot is a read-only snapshot of a subvolume.
+type Snapshot struct {
+	Subvolume  string    // Path of the snapshotted subvolume
+	Name       string    // Snapshot name
+	Path       string    // Path of the snapshot
+	Created    time.Time // Creation time
+	Referenced uint64    // Bytes referenced, 0 when quotas are disabled
+	Exclusive  uint64    // Bytes only held by the snapshot, 0 when quotas are disabled
+}
+
+// Usage is the qgroup accounting of a subvolume.
+type Usage struct {
+	Referenced uint64 // Bytes referenced by the subvolume
+	Exclusive  uint64 // Bytes not shared with any other subvolume or snapshot
+	Limit      uint64 // Referenced limit, 0 when unlimited
+}
+
+// subvolumeInfo is the part of "btrfs subvolume show" Anemone uses.
+type subvolumeInfo struct {
+	ID       string
+	Created  time.Time
+	ReadOnly bool
+}
+
+// IsFilesystem reports whether a path is on a Btrfs filesystem.
+func IsFilesystem(path string) bool {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return false
+	}
+	return stat.Type == superMagic
+}
+
+// ValidateSnapshotName checks the name of a snapshot, which becomes a directory name.
+func ValidateSnapshotName(name string) error {
+	if name == "" {
+		return fmt.Errorf("snapshot name cannot be empty")
+	}
+	if len(name) > 255 {
+		return fmt.Errorf("snapshot name too long")
+	}
+	if !snapshotNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid snapshot name: must start with alphanumeric")
+	}
+	return nil
+}
+
+// ValidateSubvolumePath checks that a path is absolute and clean.
+func ValidateSubvolumePath(path string) error {
+	if !filepath.IsAbs(path) || filepath.Clean(path) != path || path == "/" {
+		return fmt.Errorf("invalid subvolume path: %s", path)
+	}
+	return nil
+}
+
+// SnapshotDir returns the directory holding the snapshots of a subvolume.
+func SnapshotDir(subvolume string) string {
+	return filepath.Join(filepath.Dir(subvolume), SnapshotsDirName, filepath.Base(subvolume))
+}
+
+// snapshotPath returns the path of a snapshot of a subvolume, after checking both.
+func snapshotPath(subvolume, name string) (string, error) {
+	if err := ValidateSubvolumePath(subvolume); err != nil {
+		return "", err
+	}
+	if err := ValidateSnapshotName(name); err != nil {
+		return "", err
+	}
+	return filepath.Join(SnapshotDir(subvolume), name), nil
+}
+
+// CreateSnapshot creates a read-only snapshot of a subvolume.
+func CreateSnapshot(subvolume, name string) error {
+	path, err := snapshotPath(subvolume, name)
+	if err != nil {
+		return err
+	}
+	if !IsSubvolumeSudo(subvolume) {
+		return fmt.Errorf("%s is not a Btrfs subvolume", subvolume)
+	}
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("snapshot %s already exists", name)
+	}
+
+	if output, err := exec.Command("sudo", "mkdir", "-p", SnapshotDir(subvolume)).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to create snapshot directory: %s - %w", strings.TrimSpace(string(output)), err)
+	}
+	output, err := exec.Command("sudo", "btrfs", "subvolume", "snapshot", "-r", subvolume, path).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to create snapshot: %s - %w", strings.TrimSpace(string(output)), err)
+	}
+	return nil
+}
+
+// DeleteSnapshot deletes a snapshot of a subvolume.
+func DeleteSnapshot(subvolume, name string) error {
+	path, err := snapshotPath(subvolume, name)
+	if err != nil {
+		return err
+	}
+	if !IsSubvolumeSudo(path) {
+		return fmt.Errorf("snapshot %s not found", name)
+	}
+	if output, err := DeleteSubvolume(path); err != nil {
+		return fmt.Errorf("failed to delete snapshot: %s - %w", strings.TrimSpace(string(output)), err)
+	}
+	return nil
+}
+
+// DeleteAllSnapshots deletes every snapshot of a subvolume, before the subvolume
+// itself is removed.
+func DeleteAllSnapshots(subvolume string) error {
+	snaps, err := ListSnapshots(subvolume)
+	if err != nil {
+		return err
+	}
+	for _, snap := range snaps {
+		if err := DeleteSnapshot(subvolume, snap.Name); err != nil {
+			return err
+		}
+	}
+	os.Remove(SnapshotDir(subvolume))
+	return nil
+}
+
+// ListSnapshots returns the snapshots of a subvolume, oldest first.
+func ListSnapshots(subvolume string) ([]Snapshot, error) {
+	if err := ValidateSubvolumePath(subvolume); err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(SnapshotDir(subvolume))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []Snapshot{}, nil
+		}
+		return nil, fmt.Errorf("failed to read snapshot directory: %w", err)
+	}
+
+	// Quotas may be disabled: snapshots are listed without usage then
+	usage, _ := QgroupUsage(subvolume)
+
+	snapshots := []Snapshot{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		path := filepath.Join(SnapshotDir(subvolume), entry.Name())
+		info, err := showSubvolume(path)
+		if err != nil || !info.ReadOnly {
+			continue
+		}
+		snap := Snapshot{Subvolume: subvolume, Name: entry.Name(), Path: path, Created: info.Created}
+		if u, ok := usage[info.ID]; ok {
+			snap.Referenced = u.Referenced
+			snap.Exclusive = u.Exclusive
+		}
+		snapshots = append(snapshots, snap)
+	}
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].Created.Before(snapshots[j].Created) })
+	return snapshots, nil
+}
+
+// Rollback replaces a subvolume with a writable copy of one of its snapshots.
+// The current content of the subvolume is lost; other snapshots are kept, and
+// the quota limit of the subvolume is carried over.
+func Rollback(subvolume, name string) error {
+	path, err := snapshotPath(subvolume, name)
+	if err != nil {
+		return err
+	}
+	if !IsSubvolumeSudo(path) {
+		return fmt.Errorf("snapshot %s not found", name)
+	}
+	current, err := showSubvolume(subvolume)
+	if err != nil {
+		return err
+	}
+	var limit uint64
+	if usage, err := QgroupUsage(subvolume); err == nil {
+		limit = usage[current.ID].Limit
+	}
+
+	restored := subvolume + ".rollback"
+	previous := subvolume + ".pre-rollback"
+	for _, p := range []string{restored, previous} {
+		if _, err := os.Stat(p); err == nil {
+			return fmt.Errorf("%s exists, a previous rollback did not complete", p)
+		}
+	}
+
+	if output, err := exec.Command("sudo", "btrfs", "subvolume", "snapshot", path, restored).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to copy snapshot: %s - %w", strings.TrimSpace(string(output)), err)
+	}
+	if output, err := exec.Command("sudo", "mv", subvolume, previous).CombinedOutput(); err != nil {
+		DeleteSubvolume(restored)
+		return fmt.Errorf("failed to move current subvolume: %s - %w", strings.TrimSpace(string(output)), err)
+	}
+	if output, err := exec.Command("sudo", "mv", restored, subvolume).CombinedOutput(); err != nil {
+		exec.Command("sudo", "mv", previous, subvolume).Run()
+		DeleteSubvolume(restored)
+		return fmt.Errorf("failed to put snapshot in place: %s - %w", strings.TrimSpace(string(output)), err)
+	}
+
+	if limit > 0 {
+		if output, err := exec.Command("sudo", "btrfs", "qgroup", "limit", strconv.FormatUint(limit, 10), subvolume).CombinedOutput(); err != nil {
+			return fmt.Errorf("rolled back, but failed to restore quota limit: %s - %w", strings.TrimSpace(string(output)), err)
+		}
+	}
+	if output, err := DeleteSubvolume(previous); err != nil {
+		return fmt.Errorf("rolled back, but failed to delete %s: %s - %w", previous, strings.TrimSpace(string(output)), err)
+	}
+	return nil
+}
+
+// SubvolumeUsage returns the qgroup accounting of a subvolume.
+func SubvolumeUsage(subvolume string) (Usage, error) {
+	info, err := showSubvolume(subvolume)
+	if err != nil {
+		return Usage{}, err
+	}
+	usage, err := QgroupUsage(subvolume)
+	if err != nil {
+		return Usage{}, err
+	}
+	u, ok := usage[info.ID]
+	if !ok {
+		return Usage{}, fmt.Errorf("qgroup not found for subvolume")
+	}
+	return u, nil
+}
+
+// QgroupUsage returns the accounting of the level 0 qgroups of the filesystem
+// holding path, by subvolume ID.
+func QgroupUsage(path string) (map[string]Usage, error) {
+	output, err := exec.Command("sudo", "btrfs", "qgroup", "show", "-r", "--raw", path).CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get qgroup info: %s - %w", strings.TrimSpace(string(output)), err)
+	}
+	return parseQgroupShow(string(output)), nil
+}
+
+// parseQgroupShow parses the output of "btrfs qgroup show -r --raw":
+// qgroupid rfer excl max_rfer [path]
+func parseQgroupShow(output string) map[string]Usage {
+	usage := make(map[string]Usage)
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 {
+			continue
+		}
+		level, id, ok := strings.Cut(fields[0], "/")
+		if !ok || level != "0" {
+			continue
+		}
+		rfer, err1 := strconv.ParseUint(fields[1], 10, 64)
+		excl, err2 := strconv.ParseUint(fields[2], 10, 64)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		limit, _ := strconv.ParseUint(fields[3], 10, 64) // "none" when unlimited
+		usage[id] = Usage{Referenced: rfer, Exclusive: excl, Limit: limit}
+	}
+	return usage
+}
+
+// showSubvolume returns the ID, creation time and flags of a subvolume.
+func showSubvolume(path string) (*subvolumeInfo, error) {
+	output, err := exec.Command("sudo", "btrfs", "subvolume", "show", path).CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get subvolume info: %s - %w", strings.TrimSpace(string(output)), err)
+	}
+	info := parseSubvolumeShow(string(output))
+	if info.ID == "" {
+		return nil, fmt.Errorf("failed to parse subvolume ID")
+	}
+	return info, nil
+}
+
+// parseSubvolumeShow parses the output of "btrfs subvolume show".
+func parseSubvolumeShow(output string) *subvolumeInfo {
+	info := &subvolumeInfo{}
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch key {
+		case "Subvolume ID":
+			info.ID = value
+		case "Creation time":
+			if t, err := time.Parse("2006-01-02 15:04:05 -0700", value); err == nil {
+				info.Created = t
+			}
+		case "Flags":
+			info.ReadOnly = strings.Contains(value, "readonly")
+		}
+	}
+	return info
+}
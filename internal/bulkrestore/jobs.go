@@ -0,0 +1,574 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+// This file implements server-side restore jobs: selected files or directories
+// from a peer backup are decrypted straight into one of the user's shares.
+
+package bulkrestore
+
+import (
+	"crypto/tls"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/juste-un-gars/anemone/internal/crypto"
+	"github.com/juste-un-gars/anemone/internal/logger"
+	"github.com/juste-un-gars/anemone/internal/peers"
+	"github.com/juste-un-gars/anemone/internal/shares"
+	"github.com/juste-un-gars/anemone/internal/users"
+)
+
+// Conflict policies applied when a restored file already exists
+const (
+	ConflictSkip      = "skip"
+	ConflictOverwrite = "overwrite"
+	ConflictKeepBoth  = "keep_both"
+)
+
+// Job statuses
+const (
+	JobRunning = "running"
+	JobSuccess = "success"
+	JobPartial = "partial" // Completed with some failed files
+	JobError   = "error"
+)
+
+// progressInterval limits how often job progress is written to the database
+const progressInterval = 2 * time.Second
+
+// JobRequest describes what to restore and where
+type JobRequest struct {
+	UserID         int
+	PeerID         int
+	ShareName      string   // Backup (share) name on the peer
+	SourceServer   string   // Server that created the backup
+	Paths          []string // Files or directories to restore ("/" = everything)
+	TargetShare    string   // Name of the user's share to restore into
+	TargetDir      string   // Folder inside the target share ("" = original location)
+	ConflictPolicy string
+}
+
+// Job is a server-side restore job and its progress
+type Job struct {
+	ID             int64      `json:"id"`
+	UserID         int        `json:"user_id"`
+	PeerID         int        `json:"peer_id"`
+	PeerName       string     `json:"peer_name"`
+	ShareName      string     `json:"share_name"`
+	SourceServer   string     `json:"source_server"`
+	TargetShare    string     `json:"target_share"`
+	TargetDir      string     `json:"target_dir"`
+	ConflictPolicy string     `json:"conflict_policy"`
+	Status         string     `json:"status"`
+	TotalFiles     int        `json:"total_files"`
+	ProcessedFiles int        `json:"processed_files"`
+	SkippedFiles   int        `json:"skipped_files"`
+	FailedFiles    int        `json:"failed_files"`
+	TotalBytes     int64      `json:"total_bytes"`
+	ProcessedBytes int64      `json:"processed_bytes"`
+	CurrentFile    string     `json:"current_file"`
+	Errors         []string   `json:"errors"`
+	ErrorMessage   string     `json:"error_message"`
+	StartedAt      time.Time  `json:"started_at"`
+	CompletedAt    *time.Time `json:"completed_at"`
+}
+
+// maxStoredErrors caps the per-file error list kept with a job
+const maxStoredErrors = 100
+
+// ValidConflictPolicy reports whether p is a known conflict policy
+func ValidConflictPolicy(p string) bool {
+	return p == ConflictSkip || p == ConflictOverwrite || p == ConflictKeepBoth
+}
+
+// StartJob validates the request, records the job and runs it in the background
+func StartJob(db *sql.DB, req JobRequest) (int64, error) {
+	if len(req.Paths) == 0 {
+		return 0, fmt.Errorf("no paths selected")
+	}
+	if !ValidConflictPolicy(req.ConflictPolicy) {
+		return 0, fmt.Errorf("invalid conflict policy")
+	}
+
+	targetDir, err := cleanRelPath(req.TargetDir)
+	if err != nil {
+		return 0, err
+	}
+	req.TargetDir = targetDir
+
+	if _, err := findUserShare(db, req.UserID, req.TargetShare); err != nil {
+		return 0, err
+	}
+
+	peer, err := peers.GetByID(db, req.PeerID)
+	if err != nil {
+		return 0, fmt.Errorf("peer not found")
+	}
+
+	// One restore at a time per user: jobs compete for the same share
+	var running int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM restore_jobs WHERE user_id = ? AND status = ?`,
+		req.UserID, JobRunning).Scan(&running); err != nil {
+		return 0, fmt.Errorf("failed to check running jobs: %w", err)
+	}
+	if running > 0 {
+		return 0, fmt.Errorf("a restore is already running")
+	}
+
+	result, err := db.Exec(`INSERT INTO restore_jobs
+		(user_id, peer_id, peer_name, share_name, source_server, target_share, target_dir, conflict_policy, status, started_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)`,
+		req.UserID, req.PeerID, peer.Name, req.ShareName, req.SourceServer, req.TargetShare, req.TargetDir, req.ConflictPolicy, JobRunning)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create restore job: %w", err)
+	}
+	jobID, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get restore job ID: %w", err)
+	}
+
+	go runJob(db, jobID, req, peer)
+
+	return jobID, nil
+}
+
+// GetJob returns a restore job by ID
+func GetJob(db *sql.DB, jobID int64) (*Job, error) {
+	rows, err := db.Query(jobSelect+` WHERE id = ?`, jobID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query restore job: %w", err)
+	}
+	defer rows.Close()
+
+	jobs, err := scanJobs(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(jobs) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return jobs[0], nil
+}
+
+// ListJobs returns the most recent restore jobs of a user
+func ListJobs(db *sql.DB, userID, limit int) ([]*Job, error) {
+	rows, err := db.Query(jobSelect+` WHERE user_id = ? ORDER BY started_at DESC, id DESC LIMIT ?`, userID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query restore jobs: %w", err)
+	}
+	defer rows.Close()
+	return scanJobs(rows)
+}
+
+// ListRecentJobs returns the most recent restore jobs of all users
+func ListRecentJobs(db *sql.DB, limit int) ([]*Job, error) {
+	rows, err := db.Query(jobSelect+` ORDER BY started_at DESC, id DESC LIMIT ?`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query restore jobs: %w", err)
+	}
+	defer rows.Close()
+	return scanJobs(rows)
+}
+
+// CleanupStaleJobs marks jobs left running by a previous process as failed
+func CleanupStaleJobs(db *sql.DB) {
+	result, err := db.Exec(`UPDATE restore_jobs SET status = ?, error_message = 'restore interrupted (service restart)',
+	                        completed_at = CURRENT_TIMESTAMP WHERE status = ?`, JobError, JobRunning)
+	if err != nil {
+		logger.Warn("Restore: Failed to cleanup stale jobs", "error", err)
+		return
+	}
+	if rows, _ := result.RowsAffected(); rows > 0 {
+		logger.Info("Restore: Marked interrupted restore jobs as failed", "rows", rows)
+	}
+}
+
+const jobSelect = `SELECT id, user_id, peer_id, peer_name, share_name, source_server, target_share, target_dir,
+	conflict_policy, status, total_files, processed_files, skipped_files, failed_files, total_bytes, processed_bytes,
+	COALESCE(current_file, ''), COALESCE(errors, ''), COALESCE(error_message, ''), started_at, completed_at
+	FROM restore_jobs`
+
+func scanJobs(rows *sql.Rows) ([]*Job, error) {
+	var jobs []*Job
+	for rows.Next() {
+		j := &Job{}
+		var errorsJSON string
+		var completedAt sql.NullTime
+		if err := rows.Scan(&j.ID, &j.UserID, &j.PeerID, &j.PeerName, &j.ShareName, &j.SourceServer,
+			&j.TargetShare, &j.TargetDir, &j.ConflictPolicy, &j.Status, &j.TotalFiles, &j.ProcessedFiles,
+			&j.SkippedFiles, &j.FailedFiles, &j.TotalBytes, &j.ProcessedBytes, &j.CurrentFile, &errorsJSON,
+			&j.ErrorMessage, &j.StartedAt, &completedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan restore job: %w", err)
+		}
+		if errorsJSON != "" {
+			json.Unmarshal([]byte(errorsJSON), &j.Errors)
+		}
+		if completedAt.Valid {
+			j.CompletedAt = &completedAt.Time
+		}
+		jobs = append(jobs, j)
+	}
+	return jobs, rows.Err()
+}
+
+// saveProgress writes the job counters to the database
+func saveProgress(db *sql.DB, job *Job) {
+	errorsJSON, _ := json.Marshal(job.Errors)
+	_, err := db.Exec(`UPDATE restore_jobs SET total_files = ?, processed_files = ?, skipped_files = ?, failed_files = ?,
+	                   total_bytes = ?, processed_bytes = ?, current_file = ?, errors = ? WHERE id = ?`,
+		job.TotalFiles, job.ProcessedFiles, job.SkippedFiles, job.FailedFiles,
+		job.TotalBytes, job.ProcessedBytes, job.CurrentFile, string(errorsJSON), job.ID)
+	if err != nil {
+		logger.Warn("Restore: Failed to save job progress", "job_id", job.ID, "error", err)
+	}
+}
+
+// finishJob records the final status of a job
+func finishJob(db *sql.DB, job *Job, status, errorMessage string) {
+	job.Status = status
+	job.CurrentFile = ""
+	saveProgress(db, job)
+	if _, err := db.Exec(`UPDATE restore_jobs SET status = ?, error_message = ?, completed_at = CURRENT_TIMESTAMP WHERE id = ?`,
+		status, errorMessage, job.ID); err != nil {
+		logger.Warn("Restore: Failed to finish job", "job_id", job.ID, "error", err)
+	}
+}
+
+// addError records a per-file failure
+func (j *Job) addError(format string, args ...interface{}) {
+	j.FailedFiles++
+	if len(j.Errors) < maxStoredErrors {
+		j.Errors = append(j.Errors, fmt.Sprintf(format, args...))
+	}
+}
+
+// runJob performs the restore and records its outcome
+func runJob(db *sql.DB, jobID int64, req JobRequest, peer *peers.Peer) {
+	job := &Job{ID: jobID, Status: JobRunning}
+
+	if err := executeJob(db, job, req, peer); err != nil {
+		logger.Warn("Restore job failed", "job_id", jobID, "user_id", req.UserID, "error", err)
+		finishJob(db, job, JobError, err.Error())
+		return
+	}
+
+	status := JobSuccess
+	if job.FailedFiles > 0 {
+		status = JobPartial
+	}
+	finishJob(db, job, status, "")
+
+	logger.Info("Restore job completed", "job_id", jobID, "user_id", req.UserID, "peer", peer.Name,
+		"restored", job.ProcessedFiles-job.SkippedFiles-job.FailedFiles, "skipped", job.SkippedFiles, "failed", job.FailedFiles)
+}
+
+func executeJob(db *sql.DB, job *Job, req JobRequest, peer *peers.Peer) error {
+	user, err := users.GetByID(db, req.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+
+	share, err := findUserShare(db, req.UserID, req.TargetShare)
+	if err != nil {
+		return err
+	}
+
+	var masterKey string
+	if err := db.QueryRow("SELECT value FROM system_config WHERE key = 'master_key'").Scan(&masterKey); err != nil {
+		return fmt.Errorf("failed to get master key: %w", err)
+	}
+
+	userKey, err := crypto.DecryptKey(string(user.EncryptionKeyEncrypted), masterKey)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt user key: %w", err)
+	}
+
+	var peerPassword string
+	if peer.Password != nil && len(*peer.Password) > 0 {
+		peerPassword, err = peers.DecryptPeerPassword(peer.Password, masterKey)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt peer password: %w", err)
+		}
+	}
+
+	src := &peerSource{
+		baseURL:      fmt.Sprintf("https://%s:%d", peer.Address, peer.Port),
+		password:     peerPassword,
+		userID:       req.UserID,
+		shareName:    req.ShareName,
+		sourceServer: req.SourceServer,
+		userKey:      userKey,
+		client: &http.Client{
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+			},
+		},
+	}
+
+	manifest, err := src.manifest()
+	if err != nil {
+		return err
+	}
+
+	files := SelectFiles(manifest, req.Paths)
+	if len(files) == 0 {
+		return fmt.Errorf("no files match the selection")
+	}
+
+	job.TotalFiles = len(files)
+	for _, f := range files {
+		job.TotalBytes += manifest.Files[f].Size
+	}
+	saveProgress(db, job)
+
+	targetRoot := filepath.Join(share.Path, req.TargetDir)
+	lastSave := time.Now()
+
+	for _, filePath := range files {
+		job.CurrentFile = filePath
+		rel, err := cleanRelPath(filePath)
+		if err != nil || rel == "" {
+			job.addError("%s: invalid path", filePath)
+			job.ProcessedFiles++
+			continue
+		}
+
+		dest := filepath.Join(targetRoot, rel)
+		restored, err := restoreFile(src, filePath, share.Path, dest, req.ConflictPolicy, user.Username)
+		if err != nil {
+			job.addError("%s: %v", filePath, err)
+			logger.Info("Restore: Failed to restore file", "job_id", job.ID, "path", filePath, "error", err)
+		} else if !restored {
+			job.SkippedFiles++
+		}
+		job.ProcessedFiles++
+		job.ProcessedBytes += manifest.Files[filePath].Size
+
+		if time.Since(lastSave) >= progressInterval {
+			saveProgress(db, job)
+			lastSave = time.Now()
+		}
+	}
+
+	return nil
+}
+
+// insideRoot reports whether the real path of dir, through its symlinks, is
+// root or below it
+func insideRoot(root, dir string) bool {
+	rootReal, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return false
+	}
+	dirReal, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return false
+	}
+	return dirReal == rootReal || strings.HasPrefix(dirReal, rootReal+string(filepath.Separator))
+}
+
+// restoreFile downloads one file into dest, in the share at sharePath,
+// applying the conflict policy. It returns false when the file was skipped.
+func restoreFile(src *peerSource, filePath, sharePath, dest, policy, username string) (bool, error) {
+	if _, err := os.Lstat(dest); err == nil {
+		switch policy {
+		case ConflictSkip:
+			return false, nil
+		case ConflictKeepBoth:
+			dest = keepBothPath(dest)
+		}
+	}
+
+	parentDir := filepath.Dir(dest)
+	if err := os.MkdirAll(parentDir, 0755); err != nil {
+		return false, fmt.Errorf("failed to create directory: %w", err)
+	}
+	// A symlink in the share must not send the file outside of it
+	if !insideRoot(sharePath, parentDir) {
+		return false, fmt.Errorf("path outside share")
+	}
+	if err := setOwnership(parentDir, username); err != nil {
+		logger.Info("Warning: Failed to set ownership for directory", "path", parentDir, "error", err)
+	}
+
+	body, err := src.open(filePath)
+	if err != nil {
+		return false, err
+	}
+	defer body.Close()
+
+	// Decrypt into a temporary file next to the destination, then rename it
+	// into place so an interrupted restore never leaves a truncated file behind
+	tmp, err := os.CreateTemp(parentDir, ".anemone-restore-*")
+	if err != nil {
+		return false, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+
+	if err := crypto.DecryptStream(body, tmp, src.userKey); err != nil {
+		tmp.Close()
+		return false, fmt.Errorf("failed to decrypt: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return false, fmt.Errorf("failed to write: %w", err)
+	}
+	if err := os.Chmod(tmpPath, 0644); err != nil {
+		return false, fmt.Errorf("failed to set permissions: %w", err)
+	}
+	if err := os.Rename(tmpPath, dest); err != nil {
+		return false, fmt.Errorf("failed to move file into place: %w", err)
+	}
+
+	if err := setOwnership(dest, username); err != nil {
+		logger.Info("Warning: Failed to set ownership", "path", dest, "error", err)
+	}
+	return true, nil
+}
+
+// keepBothPath returns a free name next to path, e.g. "report (restored).pdf"
+func keepBothPath(path string) string {
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	candidate := fmt.Sprintf("%s (restored)%s", base, ext)
+	for i := 2; ; i++ {
+		if _, err := os.Lstat(candidate); os.IsNotExist(err) {
+			return candidate
+		}
+		candidate = fmt.Sprintf("%s (restored %d)%s", base, i, ext)
+	}
+}
+
+// SelectFiles returns the manifest file paths covered by the selected paths, sorted.
+// A selected path matches a file exactly or any file below it when it is a directory.
+func SelectFiles(manifest *Manifest, selected []string) []string {
+	prefixes := make([]string, 0, len(selected))
+	for _, p := range selected {
+		prefixes = append(prefixes, strings.Trim(p, "/"))
+	}
+
+	var files []string
+	for filePath, entry := range manifest.Files {
+		if entry.IsDir {
+			continue
+		}
+		normalized := strings.Trim(filePath, "/")
+		for _, prefix := range prefixes {
+			if prefix == "" || normalized == prefix || strings.HasPrefix(normalized, prefix+"/") {
+				files = append(files, filePath)
+				break
+			}
+		}
+	}
+	sort.Strings(files)
+	return files
+}
+
+// cleanRelPath normalizes a path relative to a share and rejects traversal
+func cleanRelPath(p string) (string, error) {
+	p = strings.Trim(strings.TrimSpace(p), "/")
+	if p == "" {
+		return "", nil
+	}
+	cleaned := filepath.Clean(p)
+	if cleaned == ".." || strings.HasPrefix(cleaned, "../") || filepath.IsAbs(cleaned) {
+		return "", fmt.Errorf("invalid path")
+	}
+	if cleaned == "." {
+		return "", nil
+	}
+	return cleaned, nil
+}
+
+// findUserShare returns the user's share with the given name
+func findUserShare(db *sql.DB, userID int, name string) (*shares.Share, error) {
+	userShares, err := shares.GetByUser(db, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user shares: %w", err)
+	}
+	for _, sh := range userShares {
+		if sh.Name == name {
+			return sh, nil
+		}
+	}
+	return nil, fmt.Errorf("share not found: %s", name)
+}
+
+// peerSource downloads encrypted backup content from a peer
+type peerSource struct {
+	baseURL      string
+	password     string
+	userID       int
+	shareName    string
+	sourceServer string
+	userKey      string
+	client       *http.Client
+}
+
+func (p *peerSource) get(endpoint string, params url.Values) (*http.Response, error) {
+	params.Set("user_id", strconv.Itoa(p.userID))
+	params.Set("share_name", p.shareName)
+	params.Set("source_server", p.sourceServer)
+
+	req, err := http.NewRequest("GET", p.baseURL+endpoint+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if p.password != "" {
+		req.Header.Set("X-Sync-Password", p.password)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to contact peer: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("peer returned status %d", resp.StatusCode)
+	}
+	return resp, nil
+}
+
+// manifest downloads and decrypts the backup manifest
+func (p *peerSource) manifest() (*Manifest, error) {
+	resp, err := p.get("/api/sync/download-encrypted-manifest", url.Values{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to download manifest: %w", err)
+	}
+	defer resp.Body.Close()
+
+	encrypted, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	decrypted, err := decryptData(encrypted, p.userKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt manifest: %w", err)
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(decrypted, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+	return &manifest, nil
+}
+
+// open starts downloading an encrypted file; the caller closes the body
+func (p *peerSource) open(filePath string) (io.ReadCloser, error) {
+	resp, err := p.get("/api/sync/download-encrypted-file", url.Values{"path": {filePath}})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
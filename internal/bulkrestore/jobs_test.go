@@ -0,0 +1,105 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+package bulkrestore
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSelectFiles(t *testing.T) {
+	manifest := &Manifest{Files: map[string]FileEntry{
+		"Documents/report.pdf":  {Size: 10},
+		"Documents/notes.txt":   {Size: 5},
+		"Documents2/other.txt":  {Size: 1},
+		"Images/photo.jpg":      {Size: 20},
+		"Images":                {IsDir: true},
+		"Projects/code/main.go": {Size: 3},
+	}}
+
+	testCases := []struct {
+		name     string
+		selected []string
+		expected []string
+	}{
+		{"root selects everything", []string{"/"}, []string{"Documents/notes.txt", "Documents/report.pdf", "Documents2/other.txt", "Images/photo.jpg", "Projects/code/main.go"}},
+		{"directory does not match sibling prefix", []string{"/Documents"}, []string{"Documents/notes.txt", "Documents/report.pdf"}},
+		{"single file", []string{"Images/photo.jpg"}, []string{"Images/photo.jpg"}},
+		{"nested directory and file", []string{"/Projects/code", "/Documents/notes.txt"}, []string{"Documents/notes.txt", "Projects/code/main.go"}},
+		{"unknown path", []string{"/Missing"}, nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := SelectFiles(manifest, tc.selected)
+			if !reflect.DeepEqual(result, tc.expected) {
+				t.Errorf("SelectFiles(%v) = %v, expected %v", tc.selected, result, tc.expected)
+			}
+		})
+	}
+}
+
+func TestCleanRelPath(t *testing.T) {
+	valid := map[string]string{
+		"":             "",
+		"/":            "",
+		"Restored":     "Restored",
+		"/a/b/":        "a/b",
+		"a/./b":        "a/b",
+		"a/../b":       "b",
+		" Restored/x ": "Restored/x",
+	}
+	for input, expected := range valid {
+		result, err := cleanRelPath(input)
+		if err != nil || result != expected {
+			t.Errorf("cleanRelPath(%q) = %q, %v; expected %q", input, result, err, expected)
+		}
+	}
+
+	for _, input := range []string{"..", "../etc", "a/../../etc"} {
+		if _, err := cleanRelPath(input); err == nil {
+			t.Errorf("cleanRelPath(%q) should fail", input)
+		}
+	}
+}
+
+func TestKeepBothPath(t *testing.T) {
+	dir := t.TempDir()
+	original := filepath.Join(dir, "report.pdf")
+
+	if got := keepBothPath(original); got != filepath.Join(dir, "report (restored).pdf") {
+		t.Errorf("unexpected first candidate: %s", got)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "report (restored).pdf"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := keepBothPath(original); got != filepath.Join(dir, "report (restored 2).pdf") {
+		t.Errorf("unexpected second candidate: %s", got)
+	}
+}
+
+func TestInsideRoot(t *testing.T) {
+	share := t.TempDir()
+	outside := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(share, "Documents"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(share, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	if !insideRoot(share, share) || !insideRoot(share, filepath.Join(share, "Documents")) {
+		t.Error("insideRoot refused a folder of the share")
+	}
+	if insideRoot(share, filepath.Join(share, "link")) {
+		t.Error("insideRoot accepted a symlink leading outside the share")
+	}
+	if insideRoot(share, share+"-other") {
+		t.Error("insideRoot accepted a sibling folder")
+	}
+}
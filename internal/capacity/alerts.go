@@ -0,0 +1,64 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+// This file raises the alerts of the series forecast to be full soon in the
+// shared alert store.
+
+package capacity
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+
+	"github.com/juste-un-gars/anemone/internal/alerts"
+	"github.com/juste-un-gars/anemone/internal/logger"
+)
+
+// Alert is a series forecast to be full soon, or already full. Its subject is
+// the key of the series, its kind the scope and its new value the days left.
+type Alert = alerts.Alert
+
+// severityRank orders the severities from the lowest to the highest
+func severityRank(severity string) int {
+	switch severity {
+	case SeverityWarning:
+		return 1
+	case SeverityCritical:
+		return 2
+	}
+	return 0
+}
+
+// watchForecast raises an alert when the severity of a series rises since the
+// previous collection. A series going back to normal can raise again later.
+func watchForecast(db *sql.DB, f Forecast) error {
+	var prev string
+	err := db.QueryRow("SELECT severity FROM usage_series WHERE scope = ? AND key = ?", f.Scope, f.Key).Scan(&prev)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("failed to get series severity: %w", err)
+	}
+
+	if severityRank(f.Severity) > severityRank(prev) {
+		raiseAlert(db, f)
+	}
+	if f.Severity != prev {
+		if _, err := db.Exec("UPDATE usage_series SET severity = ? WHERE scope = ? AND key = ?", f.Severity, f.Scope, f.Key); err != nil {
+			return fmt.Errorf("failed to save series severity: %w", err)
+		}
+	}
+	return nil
+}
+
+// raiseAlert records the alert of a forecast and logs it
+func raiseAlert(db *sql.DB, f Forecast) {
+	logger.Warn("Capacity: Series filling up", "scope", f.Scope, "series", f.Label, "severity", f.Severity,
+		"used", f.Used, "total", f.Total, "days_left", f.DaysLeft, "method", f.Method)
+
+	a := Alert{Source: alerts.SourceCapacity, Subject: f.Key, Label: f.Label, Severity: f.Severity,
+		Kind: f.Scope, NewValue: strconv.Itoa(f.DaysLeft)}
+	if err := alerts.Raise(db, a); err != nil {
+		logger.Warn("Capacity: Failed to record alert", "series", f.Label, "error", err)
+	}
+}
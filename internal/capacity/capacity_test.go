@@ -0,0 +1,242 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+package capacity
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/juste-un-gars/anemone/internal/alerts"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// setupTestDB creates an in-memory SQLite database with the capacity tables
+func setupTestDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	_, err = db.Exec(`
+		CREATE TABLE usage_samples (
+			scope TEXT NOT NULL,
+			key TEXT NOT NULL,
+			resolution TEXT NOT NULL,
+			sampled_at DATETIME NOT NULL,
+			used_bytes INTEGER NOT NULL,
+			total_bytes INTEGER DEFAULT 0,
+			PRIMARY KEY (scope, key, resolution, sampled_at)
+		);
+		CREATE TABLE usage_series (
+			scope TEXT NOT NULL,
+			key TEXT NOT NULL,
+			label TEXT NOT NULL,
+			severity TEXT DEFAULT '',
+			updated_at DATETIME NOT NULL,
+			PRIMARY KEY (scope, key)
+		);
+		CREATE TABLE alerts (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			source TEXT NOT NULL,
+			subject TEXT NOT NULL,
+			device TEXT DEFAULT '',
+			label TEXT DEFAULT '',
+			severity TEXT NOT NULL,
+			kind TEXT NOT NULL,
+			attribute TEXT DEFAULT '',
+			old_value TEXT DEFAULT '',
+			new_value TEXT DEFAULT '',
+			acknowledged BOOLEAN DEFAULT 0,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`)
+	if err != nil {
+		t.Fatalf("Failed to create tables: %v", err)
+	}
+	return db
+}
+
+func TestDownsample(t *testing.T) {
+	day := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	samples := []Sample{
+		{Time: day.Add(1 * time.Hour), Used: 100, Total: 1000},
+		{Time: day.Add(2 * time.Hour), Used: 200, Total: 1000},
+		{Time: day.Add(23 * time.Hour), Used: 300, Total: 2000},
+		{Time: day.Add(25 * time.Hour), Used: 500, Total: 2000},
+	}
+	merged := downsample(samples)
+	if len(merged) != 2 {
+		t.Fatalf("downsample = %+v, want 2 days", merged)
+	}
+	if !merged[0].Time.Equal(day) || merged[0].Used != 200 || merged[0].Total != 2000 {
+		t.Errorf("first day = %+v, want average 200 and largest total 2000", merged[0])
+	}
+	if !merged[1].Time.Equal(day.Add(24*time.Hour)) || merged[1].Used != 500 {
+		t.Errorf("second day = %+v", merged[1])
+	}
+}
+
+func TestCompact(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	now := time.Date(2026, 3, 30, 12, 0, 0, 0, time.UTC)
+	r := reading{Series: Series{Scope: ScopePool, Key: "tank", Label: "tank"}, Total: 1000}
+	for h := 0; h < 20*24; h++ {
+		r.Used = int64(h)
+		if err := saveReading(db, r, now.Add(-time.Duration(h)*time.Hour)); err != nil {
+			t.Fatalf("saveReading failed: %v", err)
+		}
+	}
+	if err := compact(db, now); err != nil {
+		t.Fatalf("compact failed: %v", err)
+	}
+
+	var hours, days int
+	db.QueryRow("SELECT COUNT(*) FROM usage_samples WHERE resolution = 'hour'").Scan(&hours)
+	db.QueryRow("SELECT COUNT(*) FROM usage_samples WHERE resolution = 'day'").Scan(&days)
+	// Hours since midnight 14 days ago stay hourly, the 6 older days are merged
+	if hours != 14*24+12+1 || days != 6 {
+		t.Errorf("after compact: %d hourly and %d daily samples, want %d and 6", hours, days, 14*24+13)
+	}
+
+	samples, err := GetHistory(db, ScopePool, "tank", now.Add(-30*24*time.Hour))
+	if err != nil {
+		t.Fatalf("GetHistory failed: %v", err)
+	}
+	if len(samples) != hours+days || samples[0].Time.After(samples[1].Time) {
+		t.Errorf("history has %d samples, want %d oldest first", len(samples), hours+days)
+	}
+
+	// Compacting again changes nothing
+	if err := compact(db, now); err != nil {
+		t.Fatalf("second compact failed: %v", err)
+	}
+	var again int
+	db.QueryRow("SELECT COUNT(*) FROM usage_samples").Scan(&again)
+	if again != hours+days {
+		t.Errorf("second compact left %d samples, want %d", again, hours+days)
+	}
+}
+
+// dailySamples returns a sample a day for days, ending at now, of usage(day)
+func dailySamples(now time.Time, days int, total int64, usage func(int) int64) []Sample {
+	samples := make([]Sample, 0, days)
+	for d := days - 1; d >= 0; d-- {
+		samples = append(samples, Sample{Time: now.Add(-time.Duration(d) * 24 * time.Hour), Used: usage(days - 1 - d), Total: total})
+	}
+	return samples
+}
+
+func TestForecastLinear(t *testing.T) {
+	now := time.Date(2026, 3, 30, 12, 0, 0, 0, time.UTC)
+	series := Series{Scope: ScopePool, Key: "tank"}
+
+	// 10 GB a day for 5 days, 500 GB left: full in 50 days
+	gb := int64(gigabyte)
+	f := forecast(series, dailySamples(now, 5, 1000*gb, func(d int) int64 { return 460*gb + int64(d)*10*gb }), now)
+	if f.Method != MethodLinear {
+		t.Fatalf("method = %q, want linear", f.Method)
+	}
+	if f.DaysLeft != 50 || f.Severity != SeverityNone {
+		t.Errorf("forecast = %d days (%s), want 50 days and no alert", f.DaysLeft, f.Severity)
+	}
+
+	// Full in 5 days
+	f = forecast(series, dailySamples(now, 5, 1000*gb, func(d int) int64 { return 910*gb + int64(d)*10*gb }), now)
+	if f.DaysLeft != 5 || f.Severity != SeverityCritical || f.FullAt == nil {
+		t.Errorf("forecast = %d days (%s), want 5 days and critical", f.DaysLeft, f.Severity)
+	}
+
+	// Shrinking usage never fills up
+	f = forecast(series, dailySamples(now, 5, 1000*gb, func(d int) int64 { return 900*gb - int64(d)*gb }), now)
+	if f.DaysLeft != -1 || f.FullAt != nil || f.Severity != SeverityNone {
+		t.Errorf("shrinking forecast = %+v", f)
+	}
+
+	// Unlimited quotas have a trend but no date
+	f = forecast(series, dailySamples(now, 5, 0, func(d int) int64 { return int64(d) * gb }), now)
+	if f.DaysLeft != -1 || f.DailyGrowth <= 0 {
+		t.Errorf("unlimited forecast = %+v", f)
+	}
+
+	// Not enough history
+	f = forecast(series, dailySamples(now, 2, 1000*gb, func(d int) int64 { return int64(d) * 400 * gb }), now)
+	if f.Method != MethodNone || f.DaysLeft != -1 {
+		t.Errorf("short history forecast = %+v", f)
+	}
+}
+
+func TestForecastSeasonal(t *testing.T) {
+	now := time.Date(2026, 3, 30, 12, 0, 0, 0, time.UTC)
+	gb := int64(gigabyte)
+
+	// 1 GB a day of growth, plus 50 GB of backups every weekend that the line
+	// through the samples would take for a steep growth at the end
+	usage := func(d int) int64 {
+		used := 100*gb + int64(d)*gb
+		if d%7 >= 5 {
+			used += 50 * gb
+		}
+		return used
+	}
+	f := forecast(Series{Scope: ScopeUser, Key: "1"}, dailySamples(now, 28, 400*gb, usage), now)
+	if f.Method != MethodSeasonal {
+		t.Fatalf("method = %q, want seasonal", f.Method)
+	}
+	if f.DailyGrowth < 0.9*float64(gb) || f.DailyGrowth > 1.1*float64(gb) {
+		t.Errorf("daily growth = %.1f GB, want about 1 GB", f.DailyGrowth/float64(gb))
+	}
+	// The last peak (127 + 50 GB) reaches 400 GB after about 220 days
+	if f.DaysLeft < 210 || f.DaysLeft > 230 {
+		t.Errorf("days left = %d, want about 220", f.DaysLeft)
+	}
+}
+
+func TestWatchForecast(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	now := time.Now()
+	series := Series{Scope: ScopeShare, Key: ShareKey(1, ShareData), Label: "alice/data"}
+	if err := saveReading(db, reading{Series: series, Used: 10, Total: 100}, now); err != nil {
+		t.Fatalf("saveReading failed: %v", err)
+	}
+
+	steps := []struct {
+		severity string
+		alerts   int
+	}{
+		{SeverityWarning, 1},
+		{SeverityWarning, 1}, // Unchanged, no new alert
+		{SeverityCritical, 2},
+		{SeverityWarning, 2}, // Lower, no alert
+		{SeverityNone, 2},
+		{SeverityCritical, 3}, // Raises again after going back to normal
+	}
+	for i, step := range steps {
+		if err := watchForecast(db, Forecast{Series: series, Severity: step.severity, DaysLeft: 3}); err != nil {
+			t.Fatalf("step %d: watchForecast failed: %v", i, err)
+		}
+		pending, err := alerts.List(db, alerts.SourceCapacity, true, 10)
+		if err != nil {
+			t.Fatalf("step %d: List failed: %v", i, err)
+		}
+		if len(pending) != step.alerts {
+			t.Errorf("step %d: %d alerts, want %d", i, len(pending), step.alerts)
+		}
+	}
+
+	pending, _ := alerts.List(db, alerts.SourceCapacity, true, 10)
+	if a := pending[0]; a.Subject != series.Key || a.Kind != ScopeShare || a.Label != "alice/data" || a.NewValue != "3" {
+		t.Errorf("alert = %+v, want series %s of scope %s full in 3 days", a, series.Key, ScopeShare)
+	}
+
+	if err := alerts.Acknowledge(db, alerts.SourceCapacity, 0); err != nil {
+		t.Fatalf("Acknowledge failed: %v", err)
+	}
+	if pending, _ := alerts.List(db, alerts.SourceCapacity, true, 10); len(pending) != 0 {
+		t.Errorf("%d alerts pending after acknowledging all", len(pending))
+	}
+}
@@ -0,0 +1,221 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+// Package capacity records the storage usage over time and forecasts when it
+// will run out. Every hour, the collector samples the usage of the ZFS pools,
+// of the filesystems holding the shares and the incoming backups, of each user
+// against its quota (data, backup and trash) and of the backups received from
+// each peer. Hourly samples are merged into daily ones after two weeks.
+//
+// The history of each series with a capacity or a quota drives a forecast of
+// the date it will be full, which raises an alert when it gets close.
+package capacity
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/juste-un-gars/anemone/internal/incoming"
+	"github.com/juste-un-gars/anemone/internal/logger"
+	"github.com/juste-un-gars/anemone/internal/quota"
+	"github.com/juste-un-gars/anemone/internal/shares"
+	"github.com/juste-un-gars/anemone/internal/storage"
+	"github.com/juste-un-gars/anemone/internal/trash"
+	"github.com/juste-un-gars/anemone/internal/users"
+)
+
+// collectInterval is how often the usage is collected
+const collectInterval = time.Hour
+
+// staleAfter is how long a series that is no longer collected (deleted user or
+// pool) is still shown
+const staleAfter = 2 * 24 * time.Hour
+
+const (
+	megabyte = 1024 * 1024
+	gigabyte = 1024 * megabyte
+)
+
+// collectMu serializes collections, which can also be requested from the web
+// interface
+var collectMu sync.Mutex
+
+// Collect samples the usage of every series, downsamples the old samples and
+// raises the alerts of the series filling up
+func Collect(db *sql.DB, sharesDir, incomingDir string) error {
+	collectMu.Lock()
+	defer collectMu.Unlock()
+
+	now := time.Now()
+	var readings []reading
+	readings = append(readings, poolReadings()...)
+	readings = append(readings, filesystemReadings(sharesDir, incomingDir)...)
+
+	perUser, err := userReadings(db)
+	if err != nil {
+		return err
+	}
+	readings = append(readings, perUser...)
+
+	backups, err := incoming.ScanIncomingBackups(db, incomingDir)
+	if err != nil {
+		logger.Warn("Capacity: Failed to scan incoming backups", "error", err)
+	}
+	readings = append(readings, incomingReadings(backups)...)
+
+	for _, r := range readings {
+		if err := saveReading(db, r, now); err != nil {
+			return err
+		}
+	}
+	if err := compact(db, now); err != nil {
+		return err
+	}
+
+	for _, r := range readings {
+		if r.Total <= 0 {
+			continue
+		}
+		f, err := GetForecast(db, r.Series, now)
+		if err != nil {
+			return err
+		}
+		if err := watchForecast(db, f); err != nil {
+			logger.Warn("Capacity: Failed to check forecast", "series", r.Label, "error", err)
+		}
+	}
+	return nil
+}
+
+// poolReadings returns the usage of the ZFS pools
+func poolReadings() []reading {
+	if !storage.IsZFSAvailable() {
+		return nil
+	}
+	pools, err := storage.ListZFSPools()
+	if err != nil {
+		logger.Warn("Capacity: Failed to list pools", "error", err)
+		return nil
+	}
+	readings := make([]reading, 0, len(pools))
+	for _, p := range pools {
+		readings = append(readings, reading{Series: Series{Scope: ScopePool, Key: p.Name, Label: p.Name},
+			Used: int64(p.Allocated), Total: int64(p.Size)})
+	}
+	return readings
+}
+
+// filesystemReadings returns the usage of the filesystems holding the paths,
+// each filesystem once
+func filesystemReadings(paths ...string) []reading {
+	var readings []reading
+	seen := map[syscall.Fsid]bool{}
+	for _, path := range paths {
+		var st syscall.Statfs_t
+		if err := syscall.Statfs(path, &st); err != nil {
+			continue
+		}
+		if seen[st.Fsid] {
+			continue
+		}
+		seen[st.Fsid] = true
+
+		// Like df, the space reserved to root is neither used nor available
+		used := int64(st.Blocks-st.Bfree) * int64(st.Bsize)
+		total := used + int64(st.Bavail)*int64(st.Bsize)
+		readings = append(readings, reading{Series: Series{Scope: ScopeFilesystem, Key: path, Label: path},
+			Used: used, Total: total})
+	}
+	return readings
+}
+
+// userReadings returns the usage of each user against its quotas, and of its
+// trash
+func userReadings(db *sql.DB) ([]reading, error) {
+	all, err := users.GetAllUsers(db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+
+	var readings []reading
+	for _, u := range all {
+		info, err := quota.GetUserQuota(db, u.ID)
+		if err != nil {
+			logger.Warn("Capacity: Failed to get user usage", "username", u.Username, "error", err)
+			continue
+		}
+		key := strconv.Itoa(u.ID)
+		readings = append(readings,
+			reading{Series: Series{Scope: ScopeUser, Key: key, Label: u.Username},
+				Used: info.UsedTotalMB * megabyte, Total: int64(info.QuotaTotalGB) * gigabyte},
+			reading{Series: Series{Scope: ScopeShare, Key: ShareKey(u.ID, ShareData), Label: u.Username + "/" + ShareData},
+				Used: info.UsedDataMB * megabyte, Total: int64(info.QuotaDataGB) * gigabyte},
+			reading{Series: Series{Scope: ScopeShare, Key: ShareKey(u.ID, ShareBackup), Label: u.Username + "/" + ShareBackup},
+				Used: info.UsedBackupMB * megabyte, Total: int64(info.QuotaBackupGB) * gigabyte},
+		)
+
+		// The trash is part of the data share, it has no quota of its own
+		userShares, err := shares.GetByUser(db, u.ID)
+		if err != nil {
+			logger.Warn("Capacity: Failed to get user shares", "username", u.Username, "error", err)
+			continue
+		}
+		var trashSize int64
+		for _, share := range userShares {
+			size, err := trash.GetTrashSize(share.Path, u.Username)
+			if err != nil {
+				continue
+			}
+			trashSize += size
+		}
+		readings = append(readings, reading{Series: Series{Scope: ScopeShare, Key: ShareKey(u.ID, ShareTrash),
+			Label: u.Username + "/" + ShareTrash}, Used: trashSize})
+	}
+	return readings, nil
+}
+
+// incomingReadings returns the size of the backups received from each peer
+func incomingReadings(backups []*incoming.IncomingBackup) []reading {
+	bySource := map[string]int64{}
+	var sources []string
+	for _, b := range backups {
+		if _, ok := bySource[b.SourceServer]; !ok {
+			sources = append(sources, b.SourceServer)
+		}
+		bySource[b.SourceServer] += b.TotalSize
+	}
+	readings := make([]reading, 0, len(sources))
+	for _, source := range sources {
+		readings = append(readings, reading{Series: Series{Scope: ScopeIncoming, Key: source, Label: source},
+			Used: bySource[source]})
+	}
+	return readings
+}
+
+// StartCollector collects the usage in the background every hour
+func StartCollector(db *sql.DB, sharesDir, incomingDir string) {
+	go func() {
+		// First collection once the server has settled
+		time.Sleep(2 * time.Minute)
+		if err := Collect(db, sharesDir, incomingDir); err != nil {
+			logger.Warn("Capacity: Collection failed", "error", err)
+		}
+
+		ticker := time.NewTicker(collectInterval)
+		defer ticker.Stop()
+
+		for {
+			<-ticker.C
+			if err := Collect(db, sharesDir, incomingDir); err != nil {
+				logger.Warn("Capacity: Collection failed", "error", err)
+			}
+		}
+	}()
+
+	logger.Info("✅ Capacity collector started (samples every hour)")
+}
@@ -0,0 +1,216 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+// This file forecasts the date a series will be full from its history.
+
+package capacity
+
+import (
+	"database/sql"
+	"math"
+	"time"
+)
+
+// Forecast methods
+const (
+	MethodNone     = ""         // Not enough history yet
+	MethodLinear   = "linear"   // Least squares line through the samples
+	MethodSeasonal = "seasonal" // Drift between weekly averages, plus the weekly peak
+)
+
+// Bounds of the forecasts
+const (
+	minHistory      = 2 * 24 * time.Hour  // History needed for a forecast
+	seasonalHistory = 14 * 24 * time.Hour // History needed for a seasonal forecast
+	forecastWindow  = 8 * 7 * 24 * time.Hour
+	maxDaysLeft     = 10 * 365 // Further than this, the series is reported as not filling up
+)
+
+// Severities of the forecasts and of their alerts
+const (
+	SeverityNone     = ""
+	SeverityWarning  = "warning"  // Full within warningDays
+	SeverityCritical = "critical" // Full within criticalDays, or already full
+)
+
+// Days left raising a warning or a critical alert
+const (
+	warningDays  = 30
+	criticalDays = 7
+)
+
+// Forecast is the trend of a series and when it will be full
+type Forecast struct {
+	Series
+	Used        int64      `json:"used"`
+	Total       int64      `json:"total"` // 0 if unlimited
+	Percent     float64    `json:"percent"`
+	DailyGrowth float64    `json:"daily_growth"` // Bytes a day
+	Method      string     `json:"method"`
+	DaysLeft    int        `json:"days_left"` // -1 if not filling up or unlimited
+	FullAt      *time.Time `json:"full_at,omitempty"`
+	Severity    string     `json:"severity"`
+	Samples     []Sample   `json:"-"` // History the forecast is based on, for the charts
+}
+
+// forecast computes the forecast of a series from its samples, oldest first
+func forecast(series Series, samples []Sample, now time.Time) Forecast {
+	f := Forecast{Series: series, DaysLeft: -1, Samples: samples}
+	if len(samples) == 0 {
+		return f
+	}
+	last := samples[len(samples)-1]
+	f.Used, f.Total = last.Used, last.Total
+	if f.Total > 0 {
+		f.Percent = float64(f.Used) * 100 / float64(f.Total)
+	}
+
+	span := last.Time.Sub(samples[0].Time)
+	if len(samples) < 3 || span < minHistory {
+		f.Severity = severityOf(f)
+		return f
+	}
+
+	// daysLeft counts from now; level is the usage the growth starts from
+	var level float64
+	var offset float64
+	if span >= seasonalHistory {
+		f.Method = MethodSeasonal
+		level, f.DailyGrowth, offset = seasonalTrend(samples, now)
+	} else {
+		f.Method = MethodLinear
+		f.DailyGrowth = linearTrend(samples)
+		level = float64(last.Used)
+		offset = now.Sub(last.Time).Hours() / 24
+	}
+
+	if f.Total > 0 {
+		switch {
+		case f.Used >= f.Total:
+			f.DaysLeft = 0
+		case f.DailyGrowth > 0:
+			days := (float64(f.Total)-level)/f.DailyGrowth - offset
+			if days < 0 {
+				days = 0
+			}
+			if days <= maxDaysLeft {
+				f.DaysLeft = int(math.Floor(days))
+			}
+		}
+		if f.DaysLeft >= 0 {
+			full := now.Add(time.Duration(f.DaysLeft) * 24 * time.Hour)
+			f.FullAt = &full
+		}
+	}
+	f.Severity = severityOf(f)
+	return f
+}
+
+// linearTrend returns the slope in bytes a day of the least squares line
+// through the samples
+func linearTrend(samples []Sample) float64 {
+	origin := samples[0].Time
+	var sumX, sumY, sumXY, sumXX float64
+	for _, s := range samples {
+		x := s.Time.Sub(origin).Hours() / 24
+		y := float64(s.Used)
+		sumX += x
+		sumY += y
+		sumXY += x * y
+		sumXX += x * x
+	}
+	n := float64(len(samples))
+	denom := n*sumXX - sumX*sumX
+	if denom == 0 {
+		return 0
+	}
+	return (n*sumXY - sumX*sumY) / denom
+}
+
+// seasonalTrend compares the average usage of the last week with the one of
+// the oldest whole week of the history, so that a weekly cycle (e.g. weekend
+// backups filling then rotating out) does not bias the growth. The usage is
+// projected from the peak of the last week, centered three and a half days ago.
+// It returns that level, the growth in bytes a day, and the days already
+// elapsed since the level.
+func seasonalTrend(samples []Sample, now time.Time) (float64, float64, float64) {
+	week := 7 * 24 * time.Hour
+	weekMean := func(end time.Time) (float64, int64, bool) {
+		var sum float64
+		var n int
+		var peak int64
+		for _, s := range samples {
+			if !s.Time.Before(end.Add(-week)) && s.Time.Before(end) {
+				sum += float64(s.Used)
+				n++
+				if s.Used > peak {
+					peak = s.Used
+				}
+			}
+		}
+		if n == 0 {
+			return 0, 0, false
+		}
+		return sum / float64(n), peak, true
+	}
+
+	end := now.Add(time.Second)
+	latest, peak, ok := weekMean(end)
+	if !ok {
+		return float64(samples[len(samples)-1].Used), 0, 0
+	}
+
+	// Oldest whole week with samples
+	weeks := int(now.Sub(samples[0].Time) / week)
+	var growth float64
+	for w := weeks; w >= 1; w-- {
+		if oldest, _, ok := weekMean(end.Add(-time.Duration(w) * week)); ok {
+			growth = (latest - oldest) / float64(w*7)
+			break
+		}
+	}
+
+	return float64(peak), growth, 3.5
+}
+
+// severityOf returns the severity of a forecast
+func severityOf(f Forecast) string {
+	switch {
+	case f.Total <= 0:
+		return SeverityNone
+	case f.Used >= f.Total || (f.DaysLeft >= 0 && f.DaysLeft <= criticalDays):
+		return SeverityCritical
+	case f.DaysLeft >= 0 && f.DaysLeft <= warningDays:
+		return SeverityWarning
+	}
+	return SeverityNone
+}
+
+// GetForecast returns the forecast of a series from its last eight weeks
+func GetForecast(db *sql.DB, series Series, now time.Time) (Forecast, error) {
+	samples, err := GetHistory(db, series.Scope, series.Key, now.Add(-forecastWindow))
+	if err != nil {
+		return Forecast{}, err
+	}
+	return forecast(series, samples, now), nil
+}
+
+// GetForecasts returns the forecasts of the series of a scope, or of every
+// scope when scope is empty, that are still collected
+func GetForecasts(db *sql.DB, scope string) ([]Forecast, error) {
+	now := time.Now()
+	series, err := GetSeries(db, scope, now.Add(-staleAfter))
+	if err != nil {
+		return nil, err
+	}
+	forecasts := make([]Forecast, 0, len(series))
+	for _, s := range series {
+		f, err := GetForecast(db, s, now)
+		if err != nil {
+			return nil, err
+		}
+		forecasts = append(forecasts, f)
+	}
+	return forecasts, nil
+}
@@ -0,0 +1,224 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+// This file contains the usage history: its series, its hourly samples and
+// their downsampling into daily samples as they get older.
+
+package capacity
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Scopes of the series
+const (
+	ScopePool       = "pool"       // ZFS pool, keyed by its name
+	ScopeFilesystem = "filesystem" // Filesystem of the shares or incoming directory, keyed by its path
+	ScopeUser       = "user"       // All the shares of a user against its quota, keyed by the user ID
+	ScopeShare      = "share"      // Part of the usage of a user, keyed by "<user ID>/<kind>"
+	ScopeIncoming   = "incoming"   // Backups received from a peer, keyed by the peer name
+)
+
+// Kinds of the share series
+const (
+	ShareData   = "data"
+	ShareBackup = "backup"
+	ShareTrash  = "trash"
+)
+
+// Resolutions of the samples
+const (
+	resolutionHour = "hour"
+	resolutionDay  = "day"
+)
+
+// Retention of the samples: the hourly samples are merged into one sample a day
+// after hourRetention, which are kept for dayRetention
+const (
+	hourRetention = 14 * 24 * time.Hour
+	dayRetention  = 2 * 365 * 24 * time.Hour
+)
+
+// Series identifies what a usage is measured on
+type Series struct {
+	Scope string `json:"scope"`
+	Key   string `json:"key"`
+	Label string `json:"label"`
+}
+
+// ShareKey returns the key of the series of a kind of usage of a user
+func ShareKey(userID int, kind string) string {
+	return fmt.Sprintf("%d/%s", userID, kind)
+}
+
+// Sample is the usage of a series at a point in time
+type Sample struct {
+	Time  time.Time `json:"time"`
+	Used  int64     `json:"used"`
+	Total int64     `json:"total"` // Capacity or quota in bytes, 0 if unlimited
+}
+
+// reading is a sample taken by the collector
+type reading struct {
+	Series
+	Used  int64
+	Total int64
+}
+
+// saveReading records the sample of a series for the hour of now. Collecting
+// twice in the same hour replaces the first sample.
+func saveReading(db *sql.DB, r reading, now time.Time) error {
+	_, err := db.Exec(`INSERT INTO usage_samples (scope, key, resolution, sampled_at, used_bytes, total_bytes)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(scope, key, resolution, sampled_at) DO UPDATE SET
+			used_bytes = excluded.used_bytes, total_bytes = excluded.total_bytes`,
+		r.Scope, r.Key, resolutionHour, now.UTC().Truncate(time.Hour), r.Used, r.Total)
+	if err != nil {
+		return fmt.Errorf("failed to save usage sample: %w", err)
+	}
+
+	_, err = db.Exec(`INSERT INTO usage_series (scope, key, label, updated_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT(scope, key) DO UPDATE SET label = excluded.label, updated_at = excluded.updated_at`,
+		r.Scope, r.Key, r.Label, now.UTC())
+	if err != nil {
+		return fmt.Errorf("failed to save usage series: %w", err)
+	}
+	return nil
+}
+
+// GetHistory returns the samples of a series taken since a time, oldest first.
+// Samples older than two weeks are daily averages.
+func GetHistory(db *sql.DB, scope, key string, since time.Time) ([]Sample, error) {
+	rows, err := db.Query(`SELECT sampled_at, used_bytes, total_bytes FROM usage_samples
+		WHERE scope = ? AND key = ? AND sampled_at >= ? ORDER BY sampled_at`, scope, key, since.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query usage history: %w", err)
+	}
+	defer rows.Close()
+
+	samples := []Sample{}
+	for rows.Next() {
+		var s Sample
+		if err := rows.Scan(&s.Time, &s.Used, &s.Total); err != nil {
+			return nil, fmt.Errorf("failed to scan usage sample: %w", err)
+		}
+		samples = append(samples, s)
+	}
+	return samples, rows.Err()
+}
+
+// GetSeries returns the series of a scope, or of every scope when scope is
+// empty, that were collected since a time
+func GetSeries(db *sql.DB, scope string, since time.Time) ([]Series, error) {
+	query := `SELECT scope, key, label FROM usage_series WHERE updated_at >= ?`
+	args := []interface{}{since.UTC()}
+	if scope != "" {
+		query += ` AND scope = ?`
+		args = append(args, scope)
+	}
+	query += ` ORDER BY scope, label`
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query usage series: %w", err)
+	}
+	defer rows.Close()
+
+	series := []Series{}
+	for rows.Next() {
+		var s Series
+		if err := rows.Scan(&s.Scope, &s.Key, &s.Label); err != nil {
+			return nil, fmt.Errorf("failed to scan usage series: %w", err)
+		}
+		series = append(series, s)
+	}
+	return series, rows.Err()
+}
+
+// downsample merges hourly samples into one sample a day, at midnight UTC,
+// with the average usage and the largest total of the day
+func downsample(samples []Sample) []Sample {
+	type day struct {
+		sum, count, total int64
+	}
+	days := map[time.Time]*day{}
+	for _, s := range samples {
+		t := s.Time.UTC().Truncate(24 * time.Hour)
+		d, ok := days[t]
+		if !ok {
+			d = &day{}
+			days[t] = d
+		}
+		d.sum += s.Used
+		d.count++
+		if s.Total > d.total {
+			d.total = s.Total
+		}
+	}
+
+	merged := make([]Sample, 0, len(days))
+	for t, d := range days {
+		merged = append(merged, Sample{Time: t, Used: d.sum / d.count, Total: d.total})
+	}
+	sort.Slice(merged, func(i, j int) bool { return merged[i].Time.Before(merged[j].Time) })
+	return merged
+}
+
+// compact downsamples the hourly samples of the whole days older than the
+// hourly retention, and deletes the daily samples older than their retention
+func compact(db *sql.DB, now time.Time) error {
+	cutoff := now.UTC().Add(-hourRetention).Truncate(24 * time.Hour)
+
+	rows, err := db.Query(`SELECT scope, key, sampled_at, used_bytes, total_bytes FROM usage_samples
+		WHERE resolution = ? AND sampled_at < ?`, resolutionHour, cutoff)
+	if err != nil {
+		return fmt.Errorf("failed to query hourly samples: %w", err)
+	}
+	old := map[[2]string][]Sample{}
+	for rows.Next() {
+		var scope, key string
+		var s Sample
+		if err := rows.Scan(&scope, &key, &s.Time, &s.Used, &s.Total); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan hourly sample: %w", err)
+		}
+		old[[2]string{scope, key}] = append(old[[2]string{scope, key}], s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read hourly samples: %w", err)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for id, samples := range old {
+		for _, s := range downsample(samples) {
+			_, err := tx.Exec(`INSERT INTO usage_samples (scope, key, resolution, sampled_at, used_bytes, total_bytes)
+				VALUES (?, ?, ?, ?, ?, ?)
+				ON CONFLICT(scope, key, resolution, sampled_at) DO UPDATE SET
+					used_bytes = excluded.used_bytes, total_bytes = excluded.total_bytes`,
+				id[0], id[1], resolutionDay, s.Time, s.Used, s.Total)
+			if err != nil {
+				return fmt.Errorf("failed to save daily sample: %w", err)
+			}
+		}
+	}
+	if _, err := tx.Exec("DELETE FROM usage_samples WHERE resolution = ? AND sampled_at < ?", resolutionHour, cutoff); err != nil {
+		return fmt.Errorf("failed to delete hourly samples: %w", err)
+	}
+	if _, err := tx.Exec("DELETE FROM usage_samples WHERE resolution = ? AND sampled_at < ?", resolutionDay, now.UTC().Add(-dayRetention)); err != nil {
+		return fmt.Errorf("failed to prune daily samples: %w", err)
+	}
+	if _, err := tx.Exec("DELETE FROM usage_series WHERE updated_at < ?", now.UTC().Add(-dayRetention)); err != nil {
+		return fmt.Errorf("failed to prune usage series: %w", err)
+	}
+	return tx.Commit()
+}
@@ -12,6 +12,7 @@ import (
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
@@ -110,12 +111,15 @@ func CheckPassword(password, hash string) bool {
 	return err == nil
 }
 
+// maxChunkSize is the plaintext size of a chunk written by EncryptStream (128MB)
+const maxChunkSize = 128 * 1024 * 1024
+
 // EncryptStream encrypts data from reader and writes to writer using AES-256-GCM with chunking
 // The encryption key must be base64-encoded 32-byte key
 // Format: [magic "AECG" 4B][version 4B][chunk_size 4B][nonce 12B][encrypted_chunk + tag][...]
 // Uses 128MB chunks to prevent OOM on systems with limited RAM (2GB)
 func EncryptStream(reader io.Reader, writer io.Writer, encryptionKey string) error {
-	const chunkSize = 128 * 1024 * 1024 // 128MB chunks
+	const chunkSize = maxChunkSize
 
 	// Decode the base64 key
 	key, err := base64.StdEncoding.DecodeString(encryptionKey)
@@ -248,6 +252,17 @@ func DecryptStream(reader io.Reader, writer io.Writer, encryptionKey string) err
 	return decryptStreamLegacy(reader, writer, gcm, magic)
 }
 
+// VerifyStream reads an encrypted stream without keeping the plaintext: the
+// authentication tag of every chunk is checked and the SHA-256 checksum of the
+// plaintext is returned, to be compared with the one recorded at backup time
+func VerifyStream(reader io.Reader, encryptionKey string) (string, error) {
+	hash := sha256.New()
+	if err := DecryptStream(reader, hash, encryptionKey); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
 // decryptStreamChunked handles the new chunked format
 func decryptStreamChunked(reader io.Reader, writer io.Writer, gcm cipher.AEAD) error {
 	// Read version
@@ -272,6 +287,10 @@ func decryptStreamChunked(reader io.Reader, writer io.Writer, gcm cipher.AEAD) e
 			return fmt.Errorf("failed to read chunk size: %w", err)
 		}
 		chunkSize := uint32(chunkSizeBytes[0])<<24 | uint32(chunkSizeBytes[1])<<16 | uint32(chunkSizeBytes[2])<<8 | uint32(chunkSizeBytes[3])
+		if chunkSize > maxChunkSize+uint32(gcm.Overhead()) {
+			// A damaged size field must not trigger a huge allocation
+			return fmt.Errorf("invalid chunk size %d (corrupted data)", chunkSize)
+		}
 
 		// Read nonce
 		nonce := make([]byte, gcm.NonceSize())
@@ -6,7 +6,9 @@ package crypto
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"strings"
 	"testing"
 )
@@ -242,6 +244,39 @@ func TestDecryptStreamWrongKey(t *testing.T) {
 	}
 }
 
+func TestVerifyStream(t *testing.T) {
+	key, _ := GenerateEncryptionKey()
+	plaintext := []byte("some file content that will be checked after writing")
+	sum := sha256.Sum256(plaintext)
+
+	var encrypted bytes.Buffer
+	if err := EncryptStream(bytes.NewReader(plaintext), &encrypted, key); err != nil {
+		t.Fatalf("EncryptStream failed: %v", err)
+	}
+
+	got, err := VerifyStream(bytes.NewReader(encrypted.Bytes()), key)
+	if err != nil {
+		t.Fatalf("VerifyStream failed: %v", err)
+	}
+	if got != hex.EncodeToString(sum[:]) {
+		t.Errorf("VerifyStream checksum = %s, expected %s", got, hex.EncodeToString(sum[:]))
+	}
+
+	// A flipped bit in the ciphertext fails authentication
+	damaged := append([]byte(nil), encrypted.Bytes()...)
+	damaged[len(damaged)-5] ^= 0x01
+	if _, err := VerifyStream(bytes.NewReader(damaged), key); err == nil {
+		t.Error("VerifyStream accepted a damaged stream")
+	}
+
+	// A damaged chunk size is rejected without allocating it
+	damaged = append([]byte(nil), encrypted.Bytes()...)
+	damaged[8] = 0xff
+	if _, err := VerifyStream(bytes.NewReader(damaged), key); err == nil {
+		t.Error("VerifyStream accepted a damaged chunk size")
+	}
+}
+
 func TestEncryptDecryptPassword(t *testing.T) {
 	password := "MySecretSMBPassword123!"
 	masterKey := "server-master-key"
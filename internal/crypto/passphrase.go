@@ -0,0 +1,109 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+// This file contains the encryption of data with a passphrase, through a key
+// derived with salted Argon2id.
+
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Argon2id parameters of new passphrase keys (RFC 9106 second recommended
+// option). USB headers and offsite copies store the parameters they were
+// written with, but encrypted volumes only store their salt: changing these
+// breaks existing volumes.
+const (
+	Argon2Time    = 3
+	Argon2Memory  = 64 * 1024 // KiB
+	Argon2Threads = 4
+)
+
+// ErrWrongPassphrase is returned when data can't be decrypted with a passphrase
+var ErrWrongPassphrase = errors.New("wrong passphrase")
+
+// PassphraseParams describes how a key is derived from a passphrase
+type PassphraseParams struct {
+	Algorithm string `json:"algorithm"` // "argon2id"
+	Salt      string `json:"salt"`      // base64
+	Time      uint32 `json:"time"`
+	Memory    uint32 `json:"memory"` // KiB
+	Threads   uint8  `json:"threads"`
+}
+
+// DefaultPassphraseParams returns the current parameters with a salt (base64)
+func DefaultPassphraseParams(salt string) PassphraseParams {
+	return PassphraseParams{
+		Algorithm: "argon2id",
+		Salt:      salt,
+		Time:      Argon2Time,
+		Memory:    Argon2Memory,
+		Threads:   Argon2Threads,
+	}
+}
+
+// newCipher derives the key of a passphrase and returns its AES-256-GCM cipher
+func (p PassphraseParams) newCipher(passphrase string) (cipher.AEAD, error) {
+	if p.Algorithm != "argon2id" {
+		return nil, fmt.Errorf("unsupported key derivation %q", p.Algorithm)
+	}
+	if p.Time == 0 || p.Memory == 0 || p.Threads == 0 {
+		return nil, fmt.Errorf("invalid key derivation parameters")
+	}
+	salt, err := base64.StdEncoding.DecodeString(p.Salt)
+	if err != nil || len(salt) == 0 {
+		return nil, fmt.Errorf("invalid salt")
+	}
+	key := argon2.IDKey([]byte(passphrase), salt, p.Time, p.Memory, p.Threads, 32)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// SealWithPassphrase encrypts data with a key derived from the passphrase and
+// a fresh salt. It returns nonce || AES-256-GCM(data) and the parameters
+// needed to decrypt it. additionalData is authenticated but not encrypted.
+func SealWithPassphrase(data []byte, passphrase string, additionalData []byte) ([]byte, PassphraseParams, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, PassphraseParams{}, fmt.Errorf("failed to generate salt: %w", err)
+	}
+	params := DefaultPassphraseParams(base64.StdEncoding.EncodeToString(salt))
+
+	gcm, err := params.newCipher(passphrase)
+	if err != nil {
+		return nil, PassphraseParams{}, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, PassphraseParams{}, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return gcm.Seal(nonce, nonce, data, additionalData), params, nil
+}
+
+// OpenWithPassphrase decrypts data sealed by SealWithPassphrase
+func OpenWithPassphrase(sealed []byte, passphrase string, params PassphraseParams, additionalData []byte) ([]byte, error) {
+	gcm, err := params.newCipher(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, fmt.Errorf("invalid encrypted data")
+	}
+	data, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], additionalData)
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	return data, nil
+}
@@ -0,0 +1,64 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+package crypto
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"testing"
+
+	"golang.org/x/crypto/argon2"
+)
+
+func TestSealWithPassphrase(t *testing.T) {
+	data := []byte("volume key")
+	sealed, params, err := SealWithPassphrase(data, "correct horse battery", []byte("ad"))
+	if err != nil {
+		t.Fatalf("SealWithPassphrase failed: %v", err)
+	}
+	if params.Algorithm != "argon2id" || params.Time != Argon2Time || params.Memory != Argon2Memory || params.Threads != Argon2Threads {
+		t.Errorf("unexpected parameters %+v", params)
+	}
+
+	got, err := OpenWithPassphrase(sealed, "correct horse battery", params, []byte("ad"))
+	if err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("OpenWithPassphrase = %q, %v", got, err)
+	}
+	if _, err := OpenWithPassphrase(sealed, "wrong horse battery", params, []byte("ad")); err != ErrWrongPassphrase {
+		t.Errorf("OpenWithPassphrase with a wrong passphrase = %v", err)
+	}
+	if _, err := OpenWithPassphrase(sealed, "correct horse battery", params, []byte("other")); err != ErrWrongPassphrase {
+		t.Errorf("OpenWithPassphrase with other additional data = %v", err)
+	}
+
+	other, params2, _ := SealWithPassphrase(data, "correct horse battery", []byte("ad"))
+	if params2.Salt == params.Salt || bytes.Equal(other, sealed) {
+		t.Error("SealWithPassphrase reused a salt")
+	}
+
+	params.Algorithm = "scrypt"
+	if _, err := OpenWithPassphrase(sealed, "correct horse battery", params, []byte("ad")); err == nil {
+		t.Error("OpenWithPassphrase accepted an unknown key derivation")
+	}
+}
+
+// TestOpenWithPassphraseFormat pins the format: existing USB headers and
+// encrypted volumes were sealed this way
+func TestOpenWithPassphraseFormat(t *testing.T) {
+	salt := []byte("0123456789abcdef")
+	key := argon2.IDKey([]byte("correct horse battery"), salt, 3, 64*1024, 4, 32)
+	block, _ := aes.NewCipher(key)
+	gcm, _ := cipher.NewGCM(block)
+	nonce := make([]byte, gcm.NonceSize())
+	sealed := gcm.Seal(nonce, nonce, []byte("volume key"), nil)
+
+	params := DefaultPassphraseParams(base64.StdEncoding.EncodeToString(salt))
+	got, err := OpenWithPassphrase(sealed, "correct horse battery", params, nil)
+	if err != nil || string(got) != "volume key" {
+		t.Errorf("OpenWithPassphrase = %q, %v", got, err)
+	}
+}
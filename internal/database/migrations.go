@@ -205,6 +205,105 @@ func Migrate(db *sql.DB) error {
 		return fmt.Errorf("rclone multi-provider migration failed: %w", err)
 	}
 
+	// Migration pour la sélection des pairs par partage et le facteur de réplication
+	if err := migrateSharePeers(db); err != nil {
+		return fmt.Errorf("share peers migration failed: %w", err)
+	}
+
+	// Migration pour les restaurations côté serveur (jobs en arrière-plan)
+	if err := migrateRestoreJobs(db); err != nil {
+		return fmt.Errorf("restore jobs migration failed: %w", err)
+	}
+
+	// Migration pour ajouter la clé de chiffrement propre à chaque disque USB
+	if err := migrateUSBDriveKeys(db); err != nil {
+		return fmt.Errorf("usb drive keys migration failed: %w", err)
+	}
+
+	// Migration pour identifier les disques USB par UUID de système de fichiers
+	if err := migrateUSBDriveIdentity(db); err != nil {
+		return fmt.Errorf("usb drive identity migration failed: %w", err)
+	}
+
+	// Migration pour les jeux de rotation de disques USB
+	if err := migrateUSBRotationSets(db); err != nil {
+		return fmt.Errorf("usb rotation sets migration failed: %w", err)
+	}
+
+	// Migration pour la vérification des sauvegardes USB et P2P
+	if err := migrateIntegrityChecks(db); err != nil {
+		return fmt.Errorf("integrity checks migration failed: %w", err)
+	}
+
+	// Migration pour la sélection des sources des sauvegardes cloud
+	if err := migrateRcloneSources(db); err != nil {
+		return fmt.Errorf("rclone sources migration failed: %w", err)
+	}
+
+	// Migration pour l'archivage des versions des sauvegardes cloud
+	if err := migrateRcloneVersioning(db); err != nil {
+		return fmt.Errorf("rclone versioning migration failed: %w", err)
+	}
+
+	// Migration pour les erreurs par fichier des sauvegardes cloud
+	if err := migrateRcloneFileErrors(db); err != nil {
+		return fmt.Errorf("rclone file errors migration failed: %w", err)
+	}
+
+	// Migration pour la vérification planifiée des sauvegardes cloud
+	if err := migrateRcloneChecks(db); err != nil {
+		return fmt.Errorf("rclone checks migration failed: %w", err)
+	}
+
+	// Migration pour les politiques de snapshots planifiés
+	if err := migrateSnapshotPolicies(db); err != nil {
+		return fmt.Errorf("snapshot policies migration failed: %w", err)
+	}
+
+	// Migration pour la réplication ZFS vers les pairs
+	if err := migrateZFSReplications(db); err != nil {
+		return fmt.Errorf("zfs replications migration failed: %w", err)
+	}
+
+	// Migration pour la surveillance SMART (historique, auto-tests)
+	if err := migrateSMARTMonitor(db); err != nil {
+		return fmt.Errorf("smart monitor migration failed: %w", err)
+	}
+
+	// Migration pour les alertes partagées des moniteurs
+	if err := migrateAlerts(db); err != nil {
+		return fmt.Errorf("alerts migration failed: %w", err)
+	}
+
+	// Migration pour la surveillance des pools ZFS (scrubs planifiés, états des disques)
+	if err := migratePoolMonitor(db); err != nil {
+		return fmt.Errorf("pool monitor migration failed: %w", err)
+	}
+
+	// Migration pour les volumes chiffrés
+	if err := migrateEncryptedVolumes(db); err != nil {
+		return fmt.Errorf("encrypted volumes migration failed: %w", err)
+	}
+
+	// Migration pour l'historique d'utilisation et les prévisions de capacité
+	if err := migrateCapacity(db); err != nil {
+		return fmt.Errorf("capacity migration failed: %w", err)
+	}
+
+	// Migration pour les groupes et leurs partages
+	if err := migrateGroups(db); err != nil {
+		return fmt.Errorf("groups migration failed: %w", err)
+	}
+
+	// Migration pour les exports NFS des partages
+	if err := migrateNFSExports(db); err != nil {
+		return fmt.Errorf("NFS exports migration failed: %w", err)
+	}
+
+	// Migration pour les mots de passe d'application (WebDAV)
+	if err := migrateAppPasswords(db); err != nil {
+		return fmt.Errorf("app passwords migration failed: %w", err)
+	}
 	return nil
 }
 
@@ -251,6 +350,7 @@ func migratePeersTable(db *sql.DB) error {
 		"sync_day_of_month":    "ALTER TABLE peers ADD COLUMN sync_day_of_month INTEGER",
 		"sync_interval_minutes": "ALTER TABLE peers ADD COLUMN sync_interval_minutes INTEGER DEFAULT 60",
 		"sync_timeout_hours":   "ALTER TABLE peers ADD COLUMN sync_timeout_hours INTEGER DEFAULT 2",
+		"verify_uploads":       "ALTER TABLE peers ADD COLUMN verify_uploads BOOLEAN DEFAULT 0",
 	}
 
 	for column, query := range columnsToAdd {
@@ -284,6 +384,7 @@ func migratePeersTable(db *sql.DB) error {
 				sync_day_of_month INTEGER,
 				sync_interval_minutes INTEGER DEFAULT 60,
 				sync_timeout_hours INTEGER DEFAULT 2,
+				verify_uploads BOOLEAN DEFAULT 0,
 				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 				updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
 			)
@@ -612,3 +713,675 @@ func migrateRcloneMultiProvider(db *sql.DB) error {
 
 	return nil
 }
+
+// migrateSharePeers creates the share_peers mapping table and adds the
+// replication columns used to evaluate per-share peer coverage
+func migrateSharePeers(db *sql.DB) error {
+	// Share-to-peer mapping: a share with no rows is sent to every enabled peer
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS share_peers (
+		share_id INTEGER NOT NULL,
+		peer_id INTEGER NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (share_id, peer_id),
+		FOREIGN KEY (share_id) REFERENCES shares(id) ON DELETE CASCADE,
+		FOREIGN KEY (peer_id) REFERENCES peers(id) ON DELETE CASCADE
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create share_peers table: %w", err)
+	}
+
+	// Minimum number of peers that must hold an up-to-date copy (0 = no requirement)
+	var colName string
+	err = db.QueryRow("SELECT name FROM pragma_table_info('shares') WHERE name='min_replicas'").Scan(&colName)
+	if err != nil {
+		if _, err := db.Exec("ALTER TABLE shares ADD COLUMN min_replicas INTEGER DEFAULT 0"); err != nil {
+			return fmt.Errorf("failed to add min_replicas column: %w", err)
+		}
+	}
+
+	// Track which share a sync log entry belongs to (NULL for older entries)
+	err = db.QueryRow("SELECT name FROM pragma_table_info('sync_log') WHERE name='share_id'").Scan(&colName)
+	if err != nil {
+		if _, err := db.Exec("ALTER TABLE sync_log ADD COLUMN share_id INTEGER"); err != nil {
+			return fmt.Errorf("failed to add share_id column to sync_log: %w", err)
+		}
+	}
+
+	if _, err := db.Exec("CREATE INDEX IF NOT EXISTS idx_sync_log_share_peer ON sync_log(share_id, peer_id)"); err != nil {
+		return fmt.Errorf("failed to create sync_log share index: %w", err)
+	}
+
+	return nil
+}
+
+// migrateRestoreJobs creates the table tracking server-side restore jobs
+func migrateRestoreJobs(db *sql.DB) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS restore_jobs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		peer_id INTEGER NOT NULL,
+		peer_name TEXT NOT NULL DEFAULT '',
+		share_name TEXT NOT NULL,
+		source_server TEXT NOT NULL,
+		target_share TEXT NOT NULL,
+		target_dir TEXT NOT NULL DEFAULT '',
+		conflict_policy TEXT NOT NULL DEFAULT 'skip',
+		status TEXT NOT NULL DEFAULT 'running',
+		total_files INTEGER DEFAULT 0,
+		processed_files INTEGER DEFAULT 0,
+		skipped_files INTEGER DEFAULT 0,
+		failed_files INTEGER DEFAULT 0,
+		total_bytes INTEGER DEFAULT 0,
+		processed_bytes INTEGER DEFAULT 0,
+		current_file TEXT DEFAULT '',
+		errors TEXT DEFAULT '',
+		error_message TEXT DEFAULT '',
+		started_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		completed_at DATETIME,
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create restore_jobs table: %w", err)
+	}
+
+	if _, err := db.Exec("CREATE INDEX IF NOT EXISTS idx_restore_jobs_user ON restore_jobs(user_id, started_at)"); err != nil {
+		return fmt.Errorf("failed to create restore_jobs index: %w", err)
+	}
+
+	return nil
+}
+
+// migrateUSBDriveKeys adds the passphrase-protected data key of USB backup drives
+func migrateUSBDriveKeys(db *sql.DB) error {
+	var colName string
+	err := db.QueryRow("SELECT name FROM pragma_table_info('usb_backups') WHERE name='data_key_encrypted'").Scan(&colName)
+	if err != nil {
+		if _, err := db.Exec("ALTER TABLE usb_backups ADD COLUMN data_key_encrypted TEXT DEFAULT ''"); err != nil {
+			return fmt.Errorf("failed to add data_key_encrypted column: %w", err)
+		}
+	}
+
+	err = db.QueryRow("SELECT name FROM pragma_table_info('usb_backups') WHERE name='key_header'").Scan(&colName)
+	if err != nil {
+		if _, err := db.Exec("ALTER TABLE usb_backups ADD COLUMN key_header TEXT DEFAULT ''"); err != nil {
+			return fmt.Errorf("failed to add key_header column: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// migrateUSBDriveIdentity adds the filesystem UUID and label used to recognise USB backup drives
+func migrateUSBDriveIdentity(db *sql.DB) error {
+	var colName string
+	err := db.QueryRow("SELECT name FROM pragma_table_info('usb_backups') WHERE name='fs_uuid'").Scan(&colName)
+	if err != nil {
+		if _, err := db.Exec("ALTER TABLE usb_backups ADD COLUMN fs_uuid TEXT DEFAULT ''"); err != nil {
+			return fmt.Errorf("failed to add fs_uuid column: %w", err)
+		}
+	}
+
+	err = db.QueryRow("SELECT name FROM pragma_table_info('usb_backups') WHERE name='fs_label'").Scan(&colName)
+	if err != nil {
+		if _, err := db.Exec("ALTER TABLE usb_backups ADD COLUMN fs_label TEXT DEFAULT ''"); err != nil {
+			return fmt.Errorf("failed to add fs_label column: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// migrateUSBRotationSets lets several drives share one USB backup definition.
+// The drive registered for a backup becomes the first member of its rotation set.
+func migrateUSBRotationSets(db *sql.DB) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS usb_backup_drives (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		backup_id INTEGER NOT NULL,
+		name TEXT NOT NULL DEFAULT '',
+		fs_uuid TEXT NOT NULL,
+		fs_label TEXT DEFAULT '',
+		last_sync DATETIME,
+		last_status TEXT DEFAULT 'unknown',
+		last_error TEXT DEFAULT '',
+		files_synced INTEGER DEFAULT 0,
+		bytes_synced INTEGER DEFAULT 0,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (backup_id) REFERENCES usb_backups(id) ON DELETE CASCADE,
+		UNIQUE(backup_id, fs_uuid)
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create usb_backup_drives table: %w", err)
+	}
+
+	_, err = db.Exec(`INSERT OR IGNORE INTO usb_backup_drives
+		(backup_id, name, fs_uuid, fs_label, last_sync, last_status, last_error, files_synced, bytes_synced)
+		SELECT id, CASE WHEN fs_label != '' THEN fs_label ELSE 'Drive 1' END, fs_uuid, fs_label, last_sync, last_status, last_error, files_synced, bytes_synced
+		FROM usb_backups WHERE fs_uuid IS NOT NULL AND fs_uuid != ''`)
+	if err != nil {
+		return fmt.Errorf("failed to register existing USB drives: %w", err)
+	}
+	if _, err := db.Exec("UPDATE usb_backups SET fs_uuid = '', fs_label = '' WHERE fs_uuid != ''"); err != nil {
+		return fmt.Errorf("failed to clear migrated USB drive identities: %w", err)
+	}
+
+	var colName string
+	err = db.QueryRow("SELECT name FROM pragma_table_info('usb_backups') WHERE name='rpo_hours'").Scan(&colName)
+	if err != nil {
+		if _, err := db.Exec("ALTER TABLE usb_backups ADD COLUMN rpo_hours INTEGER DEFAULT 0"); err != nil {
+			return fmt.Errorf("failed to add rpo_hours column: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// migrateIntegrityChecks adds post-write verification of USB backups and the table
+// recording the integrity checks of USB drives and peers
+func migrateIntegrityChecks(db *sql.DB) error {
+	var colName string
+	err := db.QueryRow("SELECT name FROM pragma_table_info('usb_backups') WHERE name='verify_writes'").Scan(&colName)
+	if err != nil {
+		if _, err := db.Exec("ALTER TABLE usb_backups ADD COLUMN verify_writes BOOLEAN DEFAULT 0"); err != nil {
+			return fmt.Errorf("failed to add verify_writes column: %w", err)
+		}
+	}
+
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS integrity_checks (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		target_type TEXT NOT NULL,
+		target_id INTEGER NOT NULL,
+		target_name TEXT NOT NULL,
+		sample_percent INTEGER NOT NULL DEFAULT 100,
+		status TEXT NOT NULL DEFAULT 'running',
+		files_checked INTEGER DEFAULT 0,
+		files_failed INTEGER DEFAULT 0,
+		files_repaired INTEGER DEFAULT 0,
+		failures TEXT DEFAULT '[]',
+		error_message TEXT DEFAULT '',
+		started_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		completed_at DATETIME
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create integrity_checks table: %w", err)
+	}
+	if _, err := db.Exec("CREATE INDEX IF NOT EXISTS idx_integrity_checks_started ON integrity_checks(started_at)"); err != nil {
+		return fmt.Errorf("failed to create integrity_checks index: %w", err)
+	}
+
+	return nil
+}
+
+// migrateRcloneSources adds the selection of what each cloud destination receives.
+// Existing destinations keep sending the users' backup shares only.
+func migrateRcloneSources(db *sql.DB) error {
+	columns := []struct {
+		name       string
+		definition string
+	}{
+		{"include_backup", "BOOLEAN DEFAULT 1"},
+		{"include_data", "BOOLEAN DEFAULT 0"},
+		{"selected_shares", "TEXT DEFAULT ''"},
+		{"include_server_config", "BOOLEAN DEFAULT 0"},
+		{"include_incoming", "BOOLEAN DEFAULT 0"},
+	}
+	for _, col := range columns {
+		var colName string
+		err := db.QueryRow("SELECT name FROM pragma_table_info('rclone_backups') WHERE name=?", col.name).Scan(&colName)
+		if err != nil {
+			if _, err := db.Exec("ALTER TABLE rclone_backups ADD COLUMN " + col.name + " " + col.definition); err != nil {
+				return fmt.Errorf("failed to add %s column: %w", col.name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// migrateRcloneVersioning adds the archiving of replaced and deleted files on cloud destinations
+func migrateRcloneVersioning(db *sql.DB) error {
+	columns := []struct {
+		name       string
+		definition string
+	}{
+		{"versioning", "BOOLEAN DEFAULT 0"},
+		{"version_retention_days", "INTEGER DEFAULT 30"},
+	}
+	for _, col := range columns {
+		var colName string
+		err := db.QueryRow("SELECT name FROM pragma_table_info('rclone_backups') WHERE name=?", col.name).Scan(&colName)
+		if err != nil {
+			if _, err := db.Exec("ALTER TABLE rclone_backups ADD COLUMN " + col.name + " " + col.definition); err != nil {
+				return fmt.Errorf("failed to add %s column: %w", col.name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// migrateRcloneFileErrors records the files that failed during the last run of each cloud backup
+func migrateRcloneFileErrors(db *sql.DB) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS rclone_file_errors (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		backup_id INTEGER NOT NULL,
+		source TEXT NOT NULL DEFAULT '',
+		path TEXT NOT NULL,
+		error TEXT NOT NULL DEFAULT '',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (backup_id) REFERENCES rclone_backups(id) ON DELETE CASCADE
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create rclone_file_errors table: %w", err)
+	}
+
+	_, err = db.Exec("CREATE INDEX IF NOT EXISTS idx_rclone_file_errors_backup ON rclone_file_errors(backup_id)")
+	if err != nil {
+		return fmt.Errorf("failed to create rclone_file_errors index: %w", err)
+	}
+	return nil
+}
+
+// migrateRcloneChecks adds the schedule of the integrity checks of cloud destinations
+func migrateRcloneChecks(db *sql.DB) error {
+	var colName string
+	err := db.QueryRow("SELECT name FROM pragma_table_info('rclone_backups') WHERE name='check_interval_days'").Scan(&colName)
+	if err != nil {
+		if _, err := db.Exec("ALTER TABLE rclone_backups ADD COLUMN check_interval_days INTEGER DEFAULT 0"); err != nil {
+			return fmt.Errorf("failed to add check_interval_days column: %w", err)
+		}
+	}
+	return nil
+}
+
+// migrateSnapshotPolicies creates the table of the scheduled snapshot policies
+func migrateSnapshotPolicies(db *sql.DB) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS snapshot_policies (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		dataset TEXT NOT NULL,
+		prefix TEXT NOT NULL DEFAULT 'auto',
+		recursive BOOLEAN DEFAULT 0,
+		keep_hourly INTEGER DEFAULT 0,
+		keep_daily INTEGER DEFAULT 7,
+		keep_weekly INTEGER DEFAULT 4,
+		keep_monthly INTEGER DEFAULT 0,
+		enabled BOOLEAN DEFAULT 1,
+		last_run DATETIME,
+		last_error TEXT DEFAULT '',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE(dataset, prefix)
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create snapshot_policies table: %w", err)
+	}
+	return nil
+}
+
+// migrateZFSReplications creates the table of the ZFS replication jobs sending
+// datasets to peers
+func migrateZFSReplications(db *sql.DB) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS zfs_replications (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		peer_id INTEGER NOT NULL,
+		dataset TEXT NOT NULL,
+		target_name TEXT NOT NULL,
+		interval_minutes INTEGER DEFAULT 1440,
+		keep_snapshots INTEGER DEFAULT 7,
+		enabled BOOLEAN DEFAULT 1,
+		last_run DATETIME,
+		last_status TEXT DEFAULT '',
+		last_error TEXT DEFAULT '',
+		last_snapshot TEXT DEFAULT '',
+		bytes_sent INTEGER DEFAULT 0,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE(peer_id, target_name),
+		FOREIGN KEY (peer_id) REFERENCES peers(id) ON DELETE CASCADE
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create zfs_replications table: %w", err)
+	}
+	return nil
+}
+
+// migrateSMARTMonitor creates the tables of the SMART monitor: attribute samples
+// and self-tests
+func migrateSMARTMonitor(db *sql.DB) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS smart_samples (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		disk TEXT NOT NULL,
+		device TEXT NOT NULL,
+		sampled_at DATETIME NOT NULL,
+		health TEXT NOT NULL,
+		temperature INTEGER DEFAULT 0,
+		reallocated_sectors INTEGER DEFAULT 0,
+		pending_sectors INTEGER DEFAULT 0,
+		uncorrectable_sectors INTEGER DEFAULT 0,
+		crc_errors INTEGER DEFAULT 0,
+		media_errors INTEGER DEFAULT 0,
+		wear_level INTEGER DEFAULT -1,
+		power_on_hours INTEGER DEFAULT 0
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create smart_samples table: %w", err)
+	}
+	if _, err := db.Exec(`CREATE INDEX IF NOT EXISTS idx_smart_samples_disk ON smart_samples(disk, sampled_at)`); err != nil {
+		return fmt.Errorf("failed to create smart_samples index: %w", err)
+	}
+
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS smart_selftests (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		disk TEXT NOT NULL,
+		device TEXT NOT NULL,
+		test_type TEXT NOT NULL,
+		scheduled BOOLEAN DEFAULT 0,
+		status TEXT NOT NULL DEFAULT 'running',
+		result TEXT DEFAULT '',
+		started_at DATETIME NOT NULL,
+		finished_at DATETIME
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create smart_selftests table: %w", err)
+	}
+	return nil
+}
+
+// migrateAlerts creates the table of the alerts raised by the monitors and
+// moves there the SMART, pool and capacity alerts kept in their own tables
+// before
+func migrateAlerts(db *sql.DB) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS alerts (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		source TEXT NOT NULL,
+		subject TEXT NOT NULL,
+		device TEXT DEFAULT '',
+		label TEXT DEFAULT '',
+		severity TEXT NOT NULL,
+		kind TEXT NOT NULL,
+		attribute TEXT DEFAULT '',
+		old_value TEXT DEFAULT '',
+		new_value TEXT DEFAULT '',
+		acknowledged BOOLEAN DEFAULT 0,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create alerts table: %w", err)
+	}
+	if _, err := db.Exec(`CREATE INDEX IF NOT EXISTS idx_alerts_source ON alerts(source, acknowledged)`); err != nil {
+		return fmt.Errorf("failed to create alerts index: %w", err)
+	}
+
+	moves := map[string]string{
+		"smart_alerts": `INSERT INTO alerts (source, subject, device, label, severity, kind, attribute, old_value, new_value, acknowledged, created_at)
+			SELECT 'smart', disk, device, model, severity, kind, attribute, old_value, new_value, acknowledged, created_at FROM smart_alerts`,
+		"pool_alerts": `INSERT INTO alerts (source, subject, device, severity, kind, attribute, old_value, new_value, acknowledged, created_at)
+			SELECT 'pool', pool, device, severity, kind, attribute, old_value, new_value, acknowledged, created_at FROM pool_alerts`,
+		"capacity_alerts": `INSERT INTO alerts (source, subject, label, severity, kind, new_value, acknowledged, created_at)
+			SELECT 'capacity', key, label, severity, scope, CAST(days_left AS TEXT), acknowledged, created_at FROM capacity_alerts`,
+	}
+	for table, move := range moves {
+		var name string
+		err := db.QueryRow("SELECT name FROM sqlite_master WHERE type='table' AND name=?", table).Scan(&name)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to check table %s: %w", table, err)
+		}
+		if _, err := db.Exec(move); err != nil {
+			return fmt.Errorf("failed to move %s: %w", table, err)
+		}
+		if _, err := db.Exec("DROP TABLE " + table); err != nil {
+			return fmt.Errorf("failed to drop %s: %w", table, err)
+		}
+	}
+	return nil
+}
+
+// migratePoolMonitor creates the tables of the pool monitor: scrub schedules and
+// history, and last known device states
+func migratePoolMonitor(db *sql.DB) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS scrub_schedules (
+		pool TEXT PRIMARY KEY,
+		interval_days INTEGER NOT NULL DEFAULT 30,
+		hour INTEGER NOT NULL DEFAULT 2,
+		enabled BOOLEAN DEFAULT 1,
+		last_started DATETIME,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create scrub_schedules table: %w", err)
+	}
+
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS scrub_history (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		pool TEXT NOT NULL,
+		function TEXT NOT NULL,
+		state TEXT NOT NULL,
+		started_at DATETIME,
+		finished_at DATETIME NOT NULL,
+		duration_seconds INTEGER DEFAULT 0,
+		bytes_repaired INTEGER DEFAULT 0,
+		errors INTEGER DEFAULT 0,
+		UNIQUE(pool, function, finished_at)
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create scrub_history table: %w", err)
+	}
+
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS pool_device_states (
+		pool TEXT NOT NULL,
+		device TEXT NOT NULL,
+		state TEXT NOT NULL,
+		read_errors INTEGER DEFAULT 0,
+		write_errors INTEGER DEFAULT 0,
+		cksum_errors INTEGER DEFAULT 0,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (pool, device)
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create pool_device_states table: %w", err)
+	}
+	return nil
+}
+
+// migrateEncryptedVolumes creates the table of the encrypted disks, pools and
+// datasets with their wrapped keys
+func migrateEncryptedVolumes(db *sql.DB) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS encrypted_volumes (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		kind TEXT NOT NULL,
+		name TEXT NOT NULL UNIQUE,
+		device TEXT DEFAULT '',
+		uuid TEXT DEFAULT '',
+		mountpoint TEXT DEFAULT '',
+		shared_access BOOLEAN DEFAULT 0,
+		key_mode TEXT NOT NULL,
+		wrapped_key TEXT NOT NULL,
+		salt TEXT DEFAULT '',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create encrypted_volumes table: %w", err)
+	}
+	return nil
+}
+
+// migrateCapacity creates the tables of the usage history and of the capacity
+// forecasts
+func migrateCapacity(db *sql.DB) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS usage_samples (
+		scope TEXT NOT NULL,
+		key TEXT NOT NULL,
+		resolution TEXT NOT NULL,
+		sampled_at DATETIME NOT NULL,
+		used_bytes INTEGER NOT NULL,
+		total_bytes INTEGER DEFAULT 0,
+		PRIMARY KEY (scope, key, resolution, sampled_at)
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create usage_samples table: %w", err)
+	}
+
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS usage_series (
+		scope TEXT NOT NULL,
+		key TEXT NOT NULL,
+		label TEXT NOT NULL,
+		severity TEXT DEFAULT '',
+		updated_at DATETIME NOT NULL,
+		PRIMARY KEY (scope, key)
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create usage_series table: %w", err)
+	}
+	return nil
+}
+
+// migrateGroups creates the tables of the groups, of their members and of
+// their shares, and lets the sync log record the syncs of group shares, which
+// have no user
+func migrateGroups(db *sql.DB) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS groups (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT UNIQUE NOT NULL,
+		description TEXT DEFAULT '',
+		quota_gb INTEGER DEFAULT 0,
+		encryption_key_encrypted TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create groups table: %w", err)
+	}
+
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS group_members (
+		group_id INTEGER NOT NULL,
+		user_id INTEGER NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (group_id, user_id),
+		FOREIGN KEY (group_id) REFERENCES groups(id) ON DELETE CASCADE,
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create group_members table: %w", err)
+	}
+
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS group_shares (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		group_id INTEGER NOT NULL,
+		name TEXT UNIQUE NOT NULL,
+		path TEXT NOT NULL,
+		default_access TEXT NOT NULL DEFAULT 'write',
+		sync_enabled BOOLEAN DEFAULT 0,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (group_id) REFERENCES groups(id) ON DELETE CASCADE
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create group_shares table: %w", err)
+	}
+
+	// Access of a member to a share, when it differs from the default one
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS group_share_access (
+		share_id INTEGER NOT NULL,
+		user_id INTEGER NOT NULL,
+		access TEXT NOT NULL,
+		PRIMARY KEY (share_id, user_id),
+		FOREIGN KEY (share_id) REFERENCES group_shares(id) ON DELETE CASCADE,
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create group_share_access table: %w", err)
+	}
+
+	// The sync log needs a nullable user_id: SQLite cannot drop a NOT NULL
+	// constraint, so the table is recreated once
+	var colName string
+	err = db.QueryRow("SELECT name FROM pragma_table_info('sync_log') WHERE name='group_share_id'").Scan(&colName)
+	if err == nil {
+		return nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start sync_log migration: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`CREATE TABLE sync_log_new (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER,
+		peer_id INTEGER NOT NULL,
+		started_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		completed_at DATETIME,
+		status TEXT DEFAULT 'running',
+		files_synced INTEGER DEFAULT 0,
+		bytes_synced INTEGER DEFAULT 0,
+		error_message TEXT,
+		share_id INTEGER,
+		group_share_id INTEGER,
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+		FOREIGN KEY (peer_id) REFERENCES peers(id) ON DELETE CASCADE,
+		FOREIGN KEY (group_share_id) REFERENCES group_shares(id) ON DELETE CASCADE
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create sync_log_new table: %w", err)
+	}
+
+	_, err = tx.Exec(`INSERT INTO sync_log_new (id, user_id, peer_id, started_at, completed_at, status,
+			files_synced, bytes_synced, error_message, share_id)
+		SELECT id, user_id, peer_id, started_at, completed_at, status,
+			files_synced, bytes_synced, error_message, share_id
+		FROM sync_log`)
+	if err != nil {
+		return fmt.Errorf("failed to copy sync_log data: %w", err)
+	}
+
+	if _, err := tx.Exec("DROP TABLE sync_log"); err != nil {
+		return fmt.Errorf("failed to drop old sync_log table: %w", err)
+	}
+	if _, err := tx.Exec("ALTER TABLE sync_log_new RENAME TO sync_log"); err != nil {
+		return fmt.Errorf("failed to rename sync_log_new table: %w", err)
+	}
+
+	for _, index := range []string{
+		"CREATE INDEX IF NOT EXISTS idx_sync_log_user ON sync_log(user_id)",
+		"CREATE INDEX IF NOT EXISTS idx_sync_log_share_peer ON sync_log(share_id, peer_id)",
+		"CREATE INDEX IF NOT EXISTS idx_sync_log_group_share_peer ON sync_log(group_share_id, peer_id)",
+	} {
+		if _, err := tx.Exec(index); err != nil {
+			return fmt.Errorf("failed to create sync_log index: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// migrateNFSExports creates the table of the NFS exports of the shares
+func migrateNFSExports(db *sql.DB) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS nfs_exports (
+		share_id INTEGER PRIMARY KEY,
+		enabled BOOLEAN DEFAULT 1,
+		clients TEXT NOT NULL,
+		read_only BOOLEAN DEFAULT 0,
+		squash TEXT NOT NULL DEFAULT 'root',
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (share_id) REFERENCES shares(id) ON DELETE CASCADE
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create nfs_exports table: %w", err)
+	}
+	return nil
+}
+
+// migrateAppPasswords creates the table of the app passwords used by WebDAV clients
+func migrateAppPasswords(db *sql.DB) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS app_passwords (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		name TEXT NOT NULL,
+		password_hash TEXT NOT NULL UNIQUE,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		last_used_at DATETIME,
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create app_passwords table: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+package diskcrypt
+
+import (
+	"database/sql"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// setupTestDB creates an in-memory SQLite database with a master key
+func setupTestDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	_, err = db.Exec(`
+		CREATE TABLE system_config (key TEXT PRIMARY KEY, value TEXT NOT NULL);
+		INSERT INTO system_config (key, value) VALUES ('master_key', 'test-master-key');
+		CREATE TABLE encrypted_volumes (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			kind TEXT NOT NULL,
+			name TEXT NOT NULL UNIQUE,
+			device TEXT DEFAULT '',
+			uuid TEXT DEFAULT '',
+			mountpoint TEXT DEFAULT '',
+			shared_access BOOLEAN DEFAULT 0,
+			key_mode TEXT NOT NULL,
+			wrapped_key TEXT NOT NULL,
+			salt TEXT DEFAULT '',
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`)
+	if err != nil {
+		t.Fatalf("Failed to create tables: %v", err)
+	}
+	return db
+}
+
+func TestWrapKey(t *testing.T) {
+	key, err := NewVolumeKey()
+	if err != nil {
+		t.Fatalf("NewVolumeKey failed: %v", err)
+	}
+	if len(key) != 64 {
+		t.Fatalf("volume key length = %d, want 64 hex characters", len(key))
+	}
+
+	wrapped, salt, err := wrapKey(key, KeyModeMaster, "master", "")
+	if err != nil {
+		t.Fatalf("wrap with master key failed: %v", err)
+	}
+	if salt != "" {
+		t.Errorf("master key wrap has a salt")
+	}
+	if got, err := unwrapKey(wrapped, salt, KeyModeMaster, "master", ""); err != nil || got != key {
+		t.Errorf("unwrap with master key = %q, %v", got, err)
+	}
+	if _, err := unwrapKey(wrapped, salt, KeyModeMaster, "other", ""); err == nil {
+		t.Error("unwrap with the wrong master key succeeded")
+	}
+
+	wrapped, salt, err = wrapKey(key, KeyModePassphrase, "", "correct horse battery")
+	if err != nil {
+		t.Fatalf("wrap with passphrase failed: %v", err)
+	}
+	if got, err := unwrapKey(wrapped, salt, KeyModePassphrase, "", "correct horse battery"); err != nil || got != key {
+		t.Errorf("unwrap with passphrase = %q, %v", got, err)
+	}
+	if _, err := unwrapKey(wrapped, salt, KeyModePassphrase, "", "wrong horse battery"); err == nil {
+		t.Error("unwrap with the wrong passphrase succeeded")
+	}
+
+	if _, _, err := wrapKey(key, KeyModePassphrase, "", "short"); err == nil {
+		t.Error("short passphrase accepted")
+	}
+	if _, _, err := wrapKey(key, "none", "master", ""); err == nil {
+		t.Error("invalid key mode accepted")
+	}
+}
+
+func TestChangeKey(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	key, _ := NewVolumeKey()
+	id, err := Register(db, Volume{Kind: KindZFS, Name: "tank/secure", KeyMode: KeyModeMaster}, key, "")
+	if err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	// Switch to a passphrase, the volume key itself stays the same
+	if err := ChangeKey(db, id, "", KeyModePassphrase, "a long unlock passphrase"); err != nil {
+		t.Fatalf("ChangeKey to passphrase failed: %v", err)
+	}
+	if _, _, err := ExportKey(db, id, ""); err == nil {
+		t.Error("export without the passphrase succeeded")
+	}
+	v, got, err := ExportKey(db, id, "a long unlock passphrase")
+	if err != nil || got != key {
+		t.Fatalf("ExportKey = %q, %v, want the registered key", got, err)
+	}
+	if v.KeyMode != KeyModePassphrase {
+		t.Errorf("key mode = %s, want passphrase", v.KeyMode)
+	}
+
+	// Changing the passphrase needs the current one
+	if err := ChangeKey(db, id, "not the passphrase", KeyModePassphrase, "another long passphrase"); err == nil {
+		t.Error("ChangeKey with the wrong passphrase succeeded")
+	}
+	if err := ChangeKey(db, id, "a long unlock passphrase", KeyModeMaster, ""); err != nil {
+		t.Fatalf("ChangeKey to master failed: %v", err)
+	}
+	if _, got, err := ExportKey(db, id, ""); err != nil || got != key {
+		t.Errorf("ExportKey after switching back = %q, %v", got, err)
+	}
+
+	// Registering the same name again replaces the record
+	newKey, _ := NewVolumeKey()
+	again, err := Register(db, Volume{Kind: KindZFS, Name: "tank/secure", KeyMode: KeyModeMaster}, newKey, "")
+	if err != nil || again != id {
+		t.Fatalf("Register again = %d, %v, want %d", again, err, id)
+	}
+	if _, got, _ := ExportKey(db, id, ""); got != newKey {
+		t.Error("re-registered volume kept its old key")
+	}
+}
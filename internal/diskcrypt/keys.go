@@ -0,0 +1,99 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+// Package diskcrypt manages the at-rest encryption of data disks (LUKS2) and
+// ZFS pools and datasets (native encryption): their volume keys, wrapped by the
+// master key or an unlock passphrase, and their locking and unlocking.
+package diskcrypt
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/juste-un-gars/anemone/internal/crypto"
+)
+
+// Key modes: how a volume key is wrapped
+const (
+	KeyModeMaster     = "master"     // Wrapped by the master key, unlocked at boot
+	KeyModePassphrase = "passphrase" // Wrapped by a passphrase, unlocked by an admin
+)
+
+// MinPassphraseLength is the minimum length of an unlock passphrase
+const MinPassphraseLength = 12
+
+// NewVolumeKey generates a random 32-byte volume key, hex-encoded as both
+// cryptsetup and ZFS (keyformat=hex) accept it
+func NewVolumeKey() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", fmt.Errorf("failed to generate key: %w", err)
+	}
+	return hex.EncodeToString(key), nil
+}
+
+// ValidateKeyMode checks a key mode and its passphrase
+func ValidateKeyMode(mode, passphrase string) error {
+	switch mode {
+	case KeyModeMaster:
+		return nil
+	case KeyModePassphrase:
+		if len(passphrase) < MinPassphraseLength {
+			return fmt.Errorf("passphrase must be at least %d characters", MinPassphraseLength)
+		}
+		return nil
+	default:
+		return fmt.Errorf("invalid key mode: %s (use master or passphrase)", mode)
+	}
+}
+
+// wrapKey encrypts a volume key with the master key or a passphrase and
+// returns the wrapped key and, for a passphrase, its salt
+func wrapKey(volumeKey, mode, masterKey, passphrase string) (string, string, error) {
+	if err := ValidateKeyMode(mode, passphrase); err != nil {
+		return "", "", err
+	}
+	if mode == KeyModeMaster {
+		if masterKey == "" {
+			return "", "", fmt.Errorf("master key is not available")
+		}
+		wrapped, err := crypto.EncryptKey(volumeKey, masterKey)
+		return wrapped, "", err
+	}
+
+	// Only the salt is stored: the volume keys use the default Argon2id parameters
+	wrapped, params, err := crypto.SealWithPassphrase([]byte(volumeKey), passphrase, nil)
+	if err != nil {
+		return "", "", err
+	}
+	return base64.StdEncoding.EncodeToString(wrapped), params.Salt, nil
+}
+
+// unwrapKey decrypts a volume key wrapped by wrapKey
+func unwrapKey(wrapped, salt, mode, masterKey, passphrase string) (string, error) {
+	if mode == KeyModeMaster {
+		if masterKey == "" {
+			return "", fmt.Errorf("master key is not available")
+		}
+		return crypto.DecryptKey(wrapped, masterKey)
+	}
+	if mode != KeyModePassphrase {
+		return "", fmt.Errorf("invalid key mode: %s", mode)
+	}
+	if passphrase == "" {
+		return "", fmt.Errorf("passphrase required")
+	}
+
+	data, err := base64.StdEncoding.DecodeString(wrapped)
+	if err != nil {
+		return "", fmt.Errorf("invalid wrapped key")
+	}
+	key, err := crypto.OpenWithPassphrase(data, passphrase, crypto.DefaultPassphraseParams(salt), nil)
+	if err != nil {
+		return "", err
+	}
+	return string(key), nil
+}
@@ -0,0 +1,347 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+// This file contains the encrypted volumes: their registration, lock state,
+// unlocking at boot or by an admin, and key management.
+
+package diskcrypt
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/juste-un-gars/anemone/internal/logger"
+	"github.com/juste-un-gars/anemone/internal/storage"
+)
+
+// Volume kinds
+const (
+	KindLUKS = "luks" // LUKS2 data disk, named after its mapper device
+	KindZFS  = "zfs"  // ZFS pool or dataset, the encryption root
+)
+
+// Lock states of a volume
+const (
+	StateLocked   = "locked"
+	StateUnlocked = "unlocked"
+	StateUnknown  = "unknown" // Tools missing or volume not found
+)
+
+// Volume is an encrypted disk, pool or dataset
+type Volume struct {
+	ID           int64     `json:"id"`
+	Kind         string    `json:"kind"`
+	Name         string    `json:"name"`   // Mapper name (luks) or dataset name (zfs)
+	Device       string    `json:"device"` // Disk the volume was created on (luks)
+	UUID         string    `json:"uuid"`   // LUKS UUID, found again if the disk is renamed
+	Mountpoint   string    `json:"mountpoint"`
+	SharedAccess bool      `json:"shared_access"`
+	KeyMode      string    `json:"key_mode"`
+	State        string    `json:"state"`
+	Mounted      bool      `json:"mounted"`
+	CreatedAt    time.Time `json:"created_at"`
+
+	wrappedKey string
+	salt       string
+}
+
+// getMasterKey returns the master key of the server
+func getMasterKey(db *sql.DB) (string, error) {
+	var masterKey string
+	if err := db.QueryRow("SELECT value FROM system_config WHERE key = 'master_key'").Scan(&masterKey); err != nil {
+		return "", fmt.Errorf("failed to get master key: %w", err)
+	}
+	return masterKey, nil
+}
+
+// Register records a volume created with volumeKey, wrapping the key with the
+// master key or the passphrase depending on v.KeyMode
+func Register(db *sql.DB, v Volume, volumeKey, passphrase string) (int64, error) {
+	if v.Kind != KindLUKS && v.Kind != KindZFS {
+		return 0, fmt.Errorf("invalid volume kind: %s", v.Kind)
+	}
+	if v.Name == "" {
+		return 0, fmt.Errorf("volume name cannot be empty")
+	}
+
+	masterKey := ""
+	if v.KeyMode == KeyModeMaster {
+		var err error
+		if masterKey, err = getMasterKey(db); err != nil {
+			return 0, err
+		}
+	}
+	wrapped, salt, err := wrapKey(volumeKey, v.KeyMode, masterKey, passphrase)
+	if err != nil {
+		return 0, err
+	}
+
+	// A reformatted disk or recreated pool replaces the previous record
+	_, err = db.Exec(`INSERT INTO encrypted_volumes
+		(kind, name, device, uuid, mountpoint, shared_access, key_mode, wrapped_key, salt)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(name) DO UPDATE SET kind = excluded.kind, device = excluded.device,
+			uuid = excluded.uuid, mountpoint = excluded.mountpoint, shared_access = excluded.shared_access,
+			key_mode = excluded.key_mode, wrapped_key = excluded.wrapped_key, salt = excluded.salt,
+			created_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP`,
+		v.Kind, v.Name, v.Device, v.UUID, v.Mountpoint, v.SharedAccess, v.KeyMode, wrapped, salt)
+	if err != nil {
+		return 0, fmt.Errorf("failed to register encrypted volume: %w", err)
+	}
+
+	var id int64
+	if err := db.QueryRow("SELECT id FROM encrypted_volumes WHERE name = ?", v.Name).Scan(&id); err != nil {
+		return 0, fmt.Errorf("failed to get encrypted volume: %w", err)
+	}
+	return id, nil
+}
+
+const volumeColumns = `id, kind, name, device, uuid, mountpoint, shared_access, key_mode, wrapped_key, salt, created_at`
+
+// scanVolume reads a volume row
+func scanVolume(row interface{ Scan(...interface{}) error }) (*Volume, error) {
+	var v Volume
+	if err := row.Scan(&v.ID, &v.Kind, &v.Name, &v.Device, &v.UUID, &v.Mountpoint, &v.SharedAccess,
+		&v.KeyMode, &v.wrappedKey, &v.salt, &v.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// List returns the encrypted volumes with their lock state
+func List(db *sql.DB) ([]Volume, error) {
+	rows, err := db.Query("SELECT " + volumeColumns + " FROM encrypted_volumes ORDER BY kind, name")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query encrypted volumes: %w", err)
+	}
+	defer rows.Close()
+
+	volumes := []Volume{}
+	for rows.Next() {
+		v, err := scanVolume(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan encrypted volume: %w", err)
+		}
+		v.refreshState()
+		volumes = append(volumes, *v)
+	}
+	return volumes, rows.Err()
+}
+
+// Get returns an encrypted volume with its lock state
+func Get(db *sql.DB, id int64) (*Volume, error) {
+	v, err := scanVolume(db.QueryRow("SELECT "+volumeColumns+" FROM encrypted_volumes WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("encrypted volume not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get encrypted volume: %w", err)
+	}
+	v.refreshState()
+	return v, nil
+}
+
+// Forget removes the record of a volume that no longer exists. Its key is lost.
+func Forget(db *sql.DB, id int64) error {
+	res, err := db.Exec("DELETE FROM encrypted_volumes WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete encrypted volume: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("encrypted volume not found")
+	}
+	return nil
+}
+
+// refreshState reads the lock and mount state of the volume from the system
+func (v *Volume) refreshState() {
+	switch v.Kind {
+	case KindLUKS:
+		if !storage.IsLUKSAvailable() {
+			v.State = StateUnknown
+		} else if storage.IsLUKSOpen(v.Name) {
+			v.State = StateUnlocked
+		} else {
+			v.State = StateLocked
+		}
+		v.Mounted = v.Mountpoint != "" && storage.IsMountpoint(v.Mountpoint)
+
+	case KindZFS:
+		status, err := storage.GetKeyStatus(v.Name)
+		switch {
+		case err != nil:
+			v.State = StateUnknown
+		case status == storage.KeyStatusAvailable:
+			v.State = StateUnlocked
+		default:
+			v.State = StateLocked
+		}
+		v.Mounted = v.State == StateUnlocked
+
+	default:
+		v.State = StateUnknown
+	}
+}
+
+// volumeKey unwraps the key of a volume
+func volumeKey(db *sql.DB, v *Volume, passphrase string) (string, error) {
+	masterKey := ""
+	if v.KeyMode == KeyModeMaster {
+		var err error
+		if masterKey, err = getMasterKey(db); err != nil {
+			return "", err
+		}
+	}
+	return unwrapKey(v.wrappedKey, v.salt, v.KeyMode, masterKey, passphrase)
+}
+
+// Unlock unlocks a volume and mounts it. The passphrase is ignored for the
+// volumes wrapped by the master key.
+func Unlock(db *sql.DB, id int64, passphrase string) error {
+	v, err := Get(db, id)
+	if err != nil {
+		return err
+	}
+	key, err := volumeKey(db, v, passphrase)
+	if err != nil {
+		return err
+	}
+	return v.unlock(key)
+}
+
+// unlock opens the volume with its key and mounts it
+func (v *Volume) unlock(key string) error {
+	switch v.Kind {
+	case KindLUKS:
+		if v.State != StateUnlocked {
+			device := v.Device
+			if v.UUID != "" {
+				var err error
+				if device, err = storage.LUKSDeviceByUUID(v.UUID); err != nil {
+					return err
+				}
+			}
+			if err := storage.LUKSOpen(device, v.Name, key); err != nil {
+				return err
+			}
+		}
+		if v.Mountpoint != "" && !storage.IsMountpoint(v.Mountpoint) {
+			if err := storage.MountDisk(storage.MapperPath(v.Name), v.Mountpoint, v.SharedAccess); err != nil {
+				return fmt.Errorf("volume unlocked but failed to mount: %w", err)
+			}
+		}
+		return nil
+
+	case KindZFS:
+		if v.State == StateUnlocked {
+			return nil
+		}
+		return storage.LoadKey(v.Name, key)
+
+	default:
+		return fmt.Errorf("invalid volume kind: %s", v.Kind)
+	}
+}
+
+// Lock unmounts a volume and locks it
+func Lock(db *sql.DB, id int64) error {
+	v, err := Get(db, id)
+	if err != nil {
+		return err
+	}
+	if v.State == StateLocked {
+		return nil
+	}
+
+	switch v.Kind {
+	case KindLUKS:
+		if v.Mounted {
+			if err := storage.UnmountDisk(v.Mountpoint, false); err != nil {
+				return err
+			}
+		}
+		return storage.LUKSClose(v.Name)
+	case KindZFS:
+		return storage.UnloadKey(v.Name)
+	default:
+		return fmt.Errorf("invalid volume kind: %s", v.Kind)
+	}
+}
+
+// ChangeKey rewraps the key of a volume with the master key or a new
+// passphrase. The data is not touched: only the wrapped key changes.
+func ChangeKey(db *sql.DB, id int64, currentPassphrase, newMode, newPassphrase string) error {
+	v, err := Get(db, id)
+	if err != nil {
+		return err
+	}
+	key, err := volumeKey(db, v, currentPassphrase)
+	if err != nil {
+		return err
+	}
+
+	masterKey := ""
+	if newMode == KeyModeMaster {
+		if masterKey, err = getMasterKey(db); err != nil {
+			return err
+		}
+	}
+	wrapped, salt, err := wrapKey(key, newMode, masterKey, newPassphrase)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(`UPDATE encrypted_volumes SET key_mode = ?, wrapped_key = ?, salt = ?,
+		updated_at = CURRENT_TIMESTAMP WHERE id = ?`, newMode, wrapped, salt, id)
+	if err != nil {
+		return fmt.Errorf("failed to update encrypted volume: %w", err)
+	}
+	return nil
+}
+
+// ExportKey returns the raw key of a volume, to keep offline in case the
+// database or the master key is lost
+func ExportKey(db *sql.DB, id int64, passphrase string) (*Volume, string, error) {
+	v, err := Get(db, id)
+	if err != nil {
+		return nil, "", err
+	}
+	key, err := volumeKey(db, v, passphrase)
+	if err != nil {
+		return nil, "", err
+	}
+	return v, key, nil
+}
+
+// UnlockAtBoot unlocks the volumes wrapped by the master key, and reports the
+// ones waiting for their passphrase
+func UnlockAtBoot(db *sql.DB) {
+	volumes, err := List(db)
+	if err != nil {
+		logger.Warn("Failed to list encrypted volumes", "error", err)
+		return
+	}
+
+	for i := range volumes {
+		v := &volumes[i]
+		if v.State == StateUnlocked && (v.Kind != KindLUKS || v.Mountpoint == "" || v.Mounted) {
+			continue
+		}
+		if v.KeyMode != KeyModeMaster {
+			logger.Warn("Encrypted volume is locked, unlock it with its passphrase", "volume", v.Name, "kind", v.Kind)
+			continue
+		}
+
+		key, err := volumeKey(db, v, "")
+		if err == nil {
+			err = v.unlock(key)
+		}
+		if err != nil {
+			logger.Warn("Failed to unlock encrypted volume", "volume", v.Name, "kind", v.Kind, "error", err)
+			continue
+		}
+		logger.Info("Unlocked encrypted volume", "volume", v.Name, "kind", v.Kind)
+	}
+}
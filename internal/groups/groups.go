@@ -0,0 +1,223 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+// Package groups manages groups of users and the shares they own together.
+//
+// A group share (a family "Photos" folder, a team "Projects" folder) is open to
+// every member of its group, read-only or read-write by default, with per
+// member exceptions. Each group has a quota shared by its shares, a trash per
+// member in each share, a Unix group used by Samba (valid users = @group) and
+// its own encryption key for the P2P synchronization of its shares.
+package groups
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/juste-un-gars/anemone/internal/crypto"
+)
+
+// nameRegex restricts the names of groups and group shares, which are used in
+// paths and in smb.conf section names
+var nameRegex = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// Group is a set of users owning shares together
+type Group struct {
+	ID          int
+	Name        string
+	Description string
+	QuotaGB     int // 0 = unlimited
+	CreatedAt   time.Time
+}
+
+// Member is a user of a group
+type Member struct {
+	UserID   int
+	Username string
+}
+
+// ValidateName checks that the name of a group or of a group share is safe
+func ValidateName(name string) error {
+	if len(name) < 2 {
+		return fmt.Errorf("name must be at least 2 characters")
+	}
+	if len(name) > 32 {
+		return fmt.Errorf("name must not exceed 32 characters")
+	}
+	if !nameRegex.MatchString(name) {
+		return fmt.Errorf("name can only contain letters, numbers, underscore (_) and hyphen (-)")
+	}
+	return nil
+}
+
+// UnixGroup returns the name of the Unix group of a group, which Samba checks
+// the members against. It is based on the ID so that it is always valid.
+func (g *Group) UnixGroup() string {
+	return fmt.Sprintf("anemone-g%d", g.ID)
+}
+
+// Create creates a group with a new encryption key, protected by the master key
+func Create(db *sql.DB, masterKey, name, description string, quotaGB int) (*Group, error) {
+	if err := ValidateName(name); err != nil {
+		return nil, fmt.Errorf("invalid group name: %w", err)
+	}
+	if quotaGB < 0 {
+		return nil, fmt.Errorf("invalid quota")
+	}
+
+	key, err := crypto.GenerateEncryptionKey()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate encryption key: %w", err)
+	}
+	encryptedKey, err := crypto.EncryptKey(key, masterKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt key: %w", err)
+	}
+
+	result, err := db.Exec(`INSERT INTO groups (name, description, quota_gb, encryption_key_encrypted)
+		VALUES (?, ?, ?, ?)`, name, description, quotaGB, encryptedKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create group: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get group ID: %w", err)
+	}
+	return GetByID(db, int(id))
+}
+
+// GetByID retrieves a group by its ID
+func GetByID(db *sql.DB, id int) (*Group, error) {
+	g := &Group{}
+	err := db.QueryRow(`SELECT id, name, description, quota_gb, created_at FROM groups WHERE id = ?`, id).
+		Scan(&g.ID, &g.Name, &g.Description, &g.QuotaGB, &g.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("group not found")
+		}
+		return nil, fmt.Errorf("failed to get group: %w", err)
+	}
+	return g, nil
+}
+
+// GetAll retrieves all groups, by name
+func GetAll(db *sql.DB) ([]*Group, error) {
+	rows, err := db.Query(`SELECT id, name, description, quota_gb, created_at FROM groups ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query groups: %w", err)
+	}
+	defer rows.Close()
+
+	var groups []*Group
+	for rows.Next() {
+		g := &Group{}
+		if err := rows.Scan(&g.ID, &g.Name, &g.Description, &g.QuotaGB, &g.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan group: %w", err)
+		}
+		groups = append(groups, g)
+	}
+	return groups, rows.Err()
+}
+
+// Update updates the description and the quota of a group
+func Update(db *sql.DB, g *Group) error {
+	if g.QuotaGB < 0 {
+		return fmt.Errorf("invalid quota")
+	}
+	_, err := db.Exec(`UPDATE groups SET description = ?, quota_gb = ? WHERE id = ?`, g.Description, g.QuotaGB, g.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update group: %w", err)
+	}
+	return nil
+}
+
+// Delete deletes a group, with its members and its shares
+func Delete(db *sql.DB, id int) error {
+	if _, err := db.Exec(`DELETE FROM groups WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete group: %w", err)
+	}
+	return nil
+}
+
+// GetEncryptionKey retrieves and decrypts the encryption key of a group
+func GetEncryptionKey(db *sql.DB, groupID int) (string, error) {
+	var masterKey string
+	if err := db.QueryRow("SELECT value FROM system_config WHERE key = 'master_key'").Scan(&masterKey); err != nil {
+		return "", fmt.Errorf("failed to get master key: %w", err)
+	}
+
+	var encryptedKey string
+	err := db.QueryRow("SELECT encryption_key_encrypted FROM groups WHERE id = ?", groupID).Scan(&encryptedKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to get group encryption key: %w", err)
+	}
+
+	key, err := crypto.DecryptKey(encryptedKey, masterKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt group encryption key: %w", err)
+	}
+	return key, nil
+}
+
+// AddMember adds a user to a group
+func AddMember(db *sql.DB, groupID, userID int) error {
+	_, err := db.Exec(`INSERT OR IGNORE INTO group_members (group_id, user_id) VALUES (?, ?)`, groupID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to add group member: %w", err)
+	}
+	return nil
+}
+
+// RemoveMember removes a user from a group, with its access exceptions to the
+// shares of the group
+func RemoveMember(db *sql.DB, groupID, userID int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM group_members WHERE group_id = ? AND user_id = ?`, groupID, userID); err != nil {
+		return fmt.Errorf("failed to remove group member: %w", err)
+	}
+	_, err = tx.Exec(`DELETE FROM group_share_access
+		WHERE user_id = ? AND share_id IN (SELECT id FROM group_shares WHERE group_id = ?)`, userID, groupID)
+	if err != nil {
+		return fmt.Errorf("failed to remove member access: %w", err)
+	}
+	return tx.Commit()
+}
+
+// GetMembers retrieves the members of a group, by username
+func GetMembers(db *sql.DB, groupID int) ([]Member, error) {
+	rows, err := db.Query(`SELECT u.id, u.username FROM group_members gm
+		JOIN users u ON u.id = gm.user_id
+		WHERE gm.group_id = ? ORDER BY u.username`, groupID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query group members: %w", err)
+	}
+	defer rows.Close()
+
+	var members []Member
+	for rows.Next() {
+		var m Member
+		if err := rows.Scan(&m.UserID, &m.Username); err != nil {
+			return nil, fmt.Errorf("failed to scan group member: %w", err)
+		}
+		members = append(members, m)
+	}
+	return members, rows.Err()
+}
+
+// IsMember reports whether a user belongs to a group
+func IsMember(db *sql.DB, groupID, userID int) (bool, error) {
+	var count int
+	err := db.QueryRow(`SELECT COUNT(*) FROM group_members WHERE group_id = ? AND user_id = ?`, groupID, userID).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("failed to check group membership: %w", err)
+	}
+	return count > 0, nil
+}
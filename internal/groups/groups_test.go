@@ -0,0 +1,207 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+package groups
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/juste-un-gars/anemone/internal/crypto"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// setupTestDB creates an in-memory SQLite database with the group tables, three
+// users and a user share
+func setupTestDB(t *testing.T) (*sql.DB, string) {
+	db, err := sql.Open("sqlite3", ":memory:?_foreign_keys=on")
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	masterKey, err := crypto.GenerateEncryptionKey()
+	if err != nil {
+		t.Fatalf("Failed to generate master key: %v", err)
+	}
+	_, err = db.Exec(`
+		CREATE TABLE system_config (key TEXT PRIMARY KEY, value TEXT);
+		CREATE TABLE users (id INTEGER PRIMARY KEY AUTOINCREMENT, username TEXT UNIQUE NOT NULL);
+		CREATE TABLE shares (id INTEGER PRIMARY KEY AUTOINCREMENT, user_id INTEGER, name TEXT NOT NULL);
+		CREATE TABLE groups (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT UNIQUE NOT NULL,
+			description TEXT DEFAULT '',
+			quota_gb INTEGER DEFAULT 0,
+			encryption_key_encrypted TEXT NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE TABLE group_members (
+			group_id INTEGER NOT NULL,
+			user_id INTEGER NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (group_id, user_id),
+			FOREIGN KEY (group_id) REFERENCES groups(id) ON DELETE CASCADE,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		);
+		CREATE TABLE group_shares (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			group_id INTEGER NOT NULL,
+			name TEXT UNIQUE NOT NULL,
+			path TEXT NOT NULL,
+			default_access TEXT NOT NULL DEFAULT 'write',
+			sync_enabled BOOLEAN DEFAULT 0,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (group_id) REFERENCES groups(id) ON DELETE CASCADE
+		);
+		CREATE TABLE group_share_access (
+			share_id INTEGER NOT NULL,
+			user_id INTEGER NOT NULL,
+			access TEXT NOT NULL,
+			PRIMARY KEY (share_id, user_id),
+			FOREIGN KEY (share_id) REFERENCES group_shares(id) ON DELETE CASCADE,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		);
+		INSERT INTO users (username) VALUES ('alice'), ('bob'), ('carol');
+		INSERT INTO shares (user_id, name) VALUES (1, 'data_alice');`)
+	if err != nil {
+		t.Fatalf("Failed to create tables: %v", err)
+	}
+	if _, err := db.Exec("INSERT INTO system_config (key, value) VALUES ('master_key', ?)", masterKey); err != nil {
+		t.Fatalf("Failed to save master key: %v", err)
+	}
+	return db, masterKey
+}
+
+func TestValidateName(t *testing.T) {
+	for name, valid := range map[string]bool{
+		"Photos":                            true,
+		"team-2026":                         true,
+		"a":                                 false,
+		"../etc":                            false,
+		"with space":                        false,
+		"semi;colon":                        false,
+		"@groups":                           false,
+		"abcdefghijklmnopqrstuvwxyz0123456": false,
+	} {
+		if err := ValidateName(name); (err == nil) != valid {
+			t.Errorf("ValidateName(%q) = %v, want valid %v", name, err, valid)
+		}
+	}
+}
+
+func TestGroupMembers(t *testing.T) {
+	db, masterKey := setupTestDB(t)
+	defer db.Close()
+
+	g, err := Create(db, masterKey, "family", "Family files", 200)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if g.UnixGroup() != "anemone-g1" || g.QuotaGB != 200 {
+		t.Errorf("group = %+v (unix group %s)", g, g.UnixGroup())
+	}
+	if _, err := Create(db, masterKey, "family", "", 0); err == nil {
+		t.Error("Create accepted a duplicate name")
+	}
+
+	key, err := GetEncryptionKey(db, g.ID)
+	if err != nil || key == "" {
+		t.Fatalf("GetEncryptionKey = %q, %v", key, err)
+	}
+
+	for _, userID := range []int{2, 1, 1} {
+		if err := AddMember(db, g.ID, userID); err != nil {
+			t.Fatalf("AddMember(%d) failed: %v", userID, err)
+		}
+	}
+	members, err := GetMembers(db, g.ID)
+	if err != nil {
+		t.Fatalf("GetMembers failed: %v", err)
+	}
+	if len(members) != 2 || members[0].Username != "alice" || members[1].Username != "bob" {
+		t.Errorf("members = %+v, want alice and bob", members)
+	}
+	if ok, _ := IsMember(db, g.ID, 3); ok {
+		t.Error("carol is reported as a member")
+	}
+}
+
+func TestShareAccess(t *testing.T) {
+	db, masterKey := setupTestDB(t)
+	defer db.Close()
+
+	g, err := Create(db, masterKey, "family", "", 0)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	AddMember(db, g.ID, 1)
+	AddMember(db, g.ID, 2)
+
+	sharesDir := "/srv/anemone/shares"
+	photos, err := CreateShare(db, sharesDir, g, "Photos", AccessRead, true)
+	if err != nil {
+		t.Fatalf("CreateShare failed: %v", err)
+	}
+	if want := filepath.Join(sharesDir, "@groups", "family", "Photos"); photos.Path != want {
+		t.Errorf("path = %s, want %s", photos.Path, want)
+	}
+	if _, err := CreateShare(db, sharesDir, g, "photos", AccessRead, false); err == nil {
+		t.Error("CreateShare accepted a name differing only by case")
+	}
+	if _, err := CreateShare(db, sharesDir, g, "DATA_alice", AccessRead, false); err == nil {
+		t.Error("CreateShare accepted the name of a user share")
+	}
+	if _, err := CreateShare(db, sharesDir, g, "backup_carol", AccessRead, false); err == nil {
+		t.Error("CreateShare accepted the name of a user share to come")
+	}
+	if _, err := CreateShare(db, sharesDir, g, "Docs", "admin", false); err == nil {
+		t.Error("CreateShare accepted an invalid access")
+	}
+
+	// alice may write, bob keeps the default read-only access
+	if err := SetMemberAccess(db, photos.ID, 1, AccessWrite); err != nil {
+		t.Fatalf("SetMemberAccess failed: %v", err)
+	}
+	if err := SetMemberAccess(db, photos.ID, 3, AccessWrite); err == nil {
+		t.Error("SetMemberAccess accepted a user outside the group")
+	}
+
+	access, err := GetShareAccess(db, photos)
+	if err != nil {
+		t.Fatalf("GetShareAccess failed: %v", err)
+	}
+	if len(access) != 2 || access[0].Access != AccessWrite || !access[0].Override ||
+		access[1].Access != AccessRead || access[1].Override {
+		t.Errorf("access = %+v", access)
+	}
+
+	share, err := GetUserShare(db, 1, "Photos")
+	if err != nil || share == nil || !share.Writable() {
+		t.Errorf("alice's share = %+v, %v, want writable", share, err)
+	}
+	share, _ = GetUserShare(db, 2, "Photos")
+	if share == nil || share.Writable() {
+		t.Errorf("bob's share = %+v, want read-only", share)
+	}
+	if share, _ := GetUserShare(db, 3, "Photos"); share != nil {
+		t.Errorf("carol has access to %+v", share)
+	}
+
+	// Leaving the group drops the exceptions
+	if err := RemoveMember(db, g.ID, 1); err != nil {
+		t.Fatalf("RemoveMember failed: %v", err)
+	}
+	AddMember(db, g.ID, 1)
+	if share, _ := GetUserShare(db, 1, "Photos"); share == nil || share.Writable() {
+		t.Errorf("alice's share after rejoining = %+v, want the default access", share)
+	}
+
+	// Deleting the group deletes its shares
+	if err := Delete(db, g.ID); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if all, _ := GetAllShares(db); len(all) != 0 {
+		t.Errorf("%d shares left after deleting the group", len(all))
+	}
+}
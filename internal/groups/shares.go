@@ -0,0 +1,291 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+// This file contains the shares owned by groups and the access of their
+// members.
+
+package groups
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Access levels of a member to a group share
+const (
+	AccessRead  = "read"
+	AccessWrite = "write"
+)
+
+// groupsDirName is the directory of the shares directory holding the group
+// shares. Usernames cannot contain '@', so it never clashes with a user.
+const groupsDirName = "@groups"
+
+// Share is a share owned by a group
+type Share struct {
+	ID            int
+	GroupID       int
+	GroupName     string
+	Name          string // SMB share name, unique among all shares
+	Path          string
+	DefaultAccess string // Access of the members without an exception
+	SyncEnabled   bool
+	CreatedAt     time.Time
+}
+
+// UserShare is a group share open to a user, with the access of the user
+type UserShare struct {
+	*Share
+	Access string
+}
+
+// Writable reports whether the user can modify the share
+func (s UserShare) Writable() bool {
+	return s.Access == AccessWrite
+}
+
+// MemberAccess is the access of a member of the group to a share
+type MemberAccess struct {
+	Member
+	Access   string
+	Override bool // Differs from the default access of the share
+}
+
+// Dir returns the directory of a group, holding its shares
+func Dir(sharesDir, groupName string) string {
+	return filepath.Join(sharesDir, groupsDirName, groupName)
+}
+
+// validAccess checks an access level
+func validAccess(access string) error {
+	if access != AccessRead && access != AccessWrite {
+		return fmt.Errorf("invalid access %q", access)
+	}
+	return nil
+}
+
+// reservedSharePrefixes start the names of the shares of the users, created
+// when a user is activated
+var reservedSharePrefixes = []string{"data_", "backup_"}
+
+// CreateShare creates a share of a group in the directory of the group. The
+// name must not be used by any other share, Samba exposing them side by side,
+// nor by a user share to come.
+func CreateShare(db *sql.DB, sharesDir string, g *Group, name, defaultAccess string, syncEnabled bool) (*Share, error) {
+	if err := ValidateName(name); err != nil {
+		return nil, fmt.Errorf("invalid share name: %w", err)
+	}
+	for _, prefix := range reservedSharePrefixes {
+		if strings.HasPrefix(strings.ToLower(name), prefix) {
+			return nil, fmt.Errorf("invalid share name: %s is reserved for user shares", prefix)
+		}
+	}
+	if err := validAccess(defaultAccess); err != nil {
+		return nil, err
+	}
+
+	var count int
+	err := db.QueryRow(`SELECT (SELECT COUNT(*) FROM shares WHERE LOWER(name) = LOWER(?))
+		+ (SELECT COUNT(*) FROM group_shares WHERE LOWER(name) = LOWER(?))`, name, name).Scan(&count)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check share name: %w", err)
+	}
+	if count > 0 {
+		return nil, fmt.Errorf("a share named %s already exists", name)
+	}
+
+	path := filepath.Join(Dir(sharesDir, g.Name), name)
+	result, err := db.Exec(`INSERT INTO group_shares (group_id, name, path, default_access, sync_enabled)
+		VALUES (?, ?, ?, ?, ?)`, g.ID, name, path, defaultAccess, syncEnabled)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create group share: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get group share ID: %w", err)
+	}
+	return GetShareByID(db, int(id))
+}
+
+const shareColumns = `gs.id, gs.group_id, g.name, gs.name, gs.path, gs.default_access, gs.sync_enabled, gs.created_at`
+
+// scanShare scans the shareColumns of a row
+func scanShare(row interface{ Scan(...interface{}) error }, extra ...interface{}) (*Share, error) {
+	s := &Share{}
+	dest := append([]interface{}{&s.ID, &s.GroupID, &s.GroupName, &s.Name, &s.Path,
+		&s.DefaultAccess, &s.SyncEnabled, &s.CreatedAt}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// queryShares runs a query selecting the shareColumns
+func queryShares(db *sql.DB, query string, args ...interface{}) ([]*Share, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query group shares: %w", err)
+	}
+	defer rows.Close()
+
+	var shares []*Share
+	for rows.Next() {
+		s, err := scanShare(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan group share: %w", err)
+		}
+		shares = append(shares, s)
+	}
+	return shares, rows.Err()
+}
+
+// GetShareByID retrieves a group share by its ID
+func GetShareByID(db *sql.DB, id int) (*Share, error) {
+	row := db.QueryRow(`SELECT `+shareColumns+` FROM group_shares gs
+		JOIN groups g ON g.id = gs.group_id WHERE gs.id = ?`, id)
+	s, err := scanShare(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("group share not found")
+		}
+		return nil, fmt.Errorf("failed to get group share: %w", err)
+	}
+	return s, nil
+}
+
+// GetShares retrieves the shares of a group, by name
+func GetShares(db *sql.DB, groupID int) ([]*Share, error) {
+	return queryShares(db, `SELECT `+shareColumns+` FROM group_shares gs
+		JOIN groups g ON g.id = gs.group_id WHERE gs.group_id = ? ORDER BY gs.name`, groupID)
+}
+
+// GetAllShares retrieves the shares of every group, by name
+func GetAllShares(db *sql.DB) ([]*Share, error) {
+	return queryShares(db, `SELECT `+shareColumns+` FROM group_shares gs
+		JOIN groups g ON g.id = gs.group_id ORDER BY gs.name`)
+}
+
+// UpdateShare updates the default access and the synchronization of a share
+func UpdateShare(db *sql.DB, s *Share) error {
+	if err := validAccess(s.DefaultAccess); err != nil {
+		return err
+	}
+	_, err := db.Exec(`UPDATE group_shares SET default_access = ?, sync_enabled = ? WHERE id = ?`,
+		s.DefaultAccess, s.SyncEnabled, s.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update group share: %w", err)
+	}
+	return nil
+}
+
+// DeleteShare deletes a group share
+func DeleteShare(db *sql.DB, id int) error {
+	if _, err := db.Exec(`DELETE FROM group_shares WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete group share: %w", err)
+	}
+	return nil
+}
+
+// SetMemberAccess sets the access of a member to a share. An empty access, or
+// the default one, removes the exception.
+func SetMemberAccess(db *sql.DB, shareID, userID int, access string) error {
+	s, err := GetShareByID(db, shareID)
+	if err != nil {
+		return err
+	}
+	if access == "" || access == s.DefaultAccess {
+		_, err = db.Exec(`DELETE FROM group_share_access WHERE share_id = ? AND user_id = ?`, shareID, userID)
+		if err != nil {
+			return fmt.Errorf("failed to reset member access: %w", err)
+		}
+		return nil
+	}
+	if err := validAccess(access); err != nil {
+		return err
+	}
+
+	member, err := IsMember(db, s.GroupID, userID)
+	if err != nil {
+		return err
+	}
+	if !member {
+		return fmt.Errorf("user is not a member of group %s", s.GroupName)
+	}
+	_, err = db.Exec(`INSERT INTO group_share_access (share_id, user_id, access) VALUES (?, ?, ?)
+		ON CONFLICT(share_id, user_id) DO UPDATE SET access = excluded.access`, shareID, userID, access)
+	if err != nil {
+		return fmt.Errorf("failed to set member access: %w", err)
+	}
+	return nil
+}
+
+// GetShareAccess returns the access of each member of the group to a share
+func GetShareAccess(db *sql.DB, s *Share) ([]MemberAccess, error) {
+	rows, err := db.Query(`SELECT u.id, u.username, a.access FROM group_members gm
+		JOIN users u ON u.id = gm.user_id
+		LEFT JOIN group_share_access a ON a.share_id = ? AND a.user_id = gm.user_id
+		WHERE gm.group_id = ? ORDER BY u.username`, s.ID, s.GroupID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query share access: %w", err)
+	}
+	defer rows.Close()
+
+	var access []MemberAccess
+	for rows.Next() {
+		var m MemberAccess
+		var override sql.NullString
+		if err := rows.Scan(&m.UserID, &m.Username, &override); err != nil {
+			return nil, fmt.Errorf("failed to scan share access: %w", err)
+		}
+		m.Access = s.DefaultAccess
+		if override.Valid && override.String != s.DefaultAccess {
+			m.Access, m.Override = override.String, true
+		}
+		access = append(access, m)
+	}
+	return access, rows.Err()
+}
+
+// GetUserShares returns the shares of the groups of a user, with its access
+func GetUserShares(db *sql.DB, userID int) ([]UserShare, error) {
+	rows, err := db.Query(`SELECT `+shareColumns+`, COALESCE(a.access, gs.default_access)
+		FROM group_shares gs
+		JOIN groups g ON g.id = gs.group_id
+		JOIN group_members gm ON gm.group_id = gs.group_id AND gm.user_id = ?
+		LEFT JOIN group_share_access a ON a.share_id = gs.id AND a.user_id = gm.user_id
+		ORDER BY gs.name`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query user group shares: %w", err)
+	}
+	defer rows.Close()
+
+	var shares []UserShare
+	for rows.Next() {
+		var access string
+		s, err := scanShare(rows, &access)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan user group share: %w", err)
+		}
+		shares = append(shares, UserShare{Share: s, Access: access})
+	}
+	return shares, rows.Err()
+}
+
+// GetUserShare returns a share of the groups of a user by name, nil if the
+// user has no access to it
+func GetUserShare(db *sql.DB, userID int, name string) (*UserShare, error) {
+	shares, err := GetUserShares(db, userID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range shares {
+		if shares[i].Name == name {
+			return &shares[i], nil
+		}
+	}
+	return nil, nil
+}
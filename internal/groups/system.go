@@ -0,0 +1,181 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+// This file contains the system side of the groups: their Unix group, checked
+// by Samba, and the directories of their shares.
+
+package groups
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"syscall"
+)
+
+// QuotaManager creates the quota-limited directories of the groups. It is
+// satisfied by quota.QuotaManager, which cannot be imported here as the quota
+// package depends on the users, and so on the SMB configuration.
+type QuotaManager interface {
+	CreateQuotaDir(path string, limitGB int, owner string) error
+	UpdateQuota(path string, limitGB int) error
+	RemoveQuotaDir(path string) error
+}
+
+// SetupGroup creates the Unix group of a group and its directory, limited to
+// the quota of the group when the filesystem supports it
+func SetupGroup(qm QuotaManager, sharesDir string, g *Group) error {
+	if err := exec.Command("getent", "group", g.UnixGroup()).Run(); err != nil {
+		if output, err := exec.Command("sudo", "/usr/sbin/groupadd", g.UnixGroup()).CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to create unix group %s: %w (output: %s)", g.UnixGroup(), err, output)
+		}
+	}
+
+	dir := Dir(sharesDir, g.Name)
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		if err := exec.Command("sudo", "/usr/bin/mkdir", "-p", filepath.Dir(dir)).Run(); err != nil {
+			return fmt.Errorf("failed to create groups directory: %w", err)
+		}
+		if err := qm.CreateQuotaDir(dir, g.QuotaGB, ""); err != nil {
+			return fmt.Errorf("failed to create group directory: %w", err)
+		}
+	}
+	return setGroupPermissions(dir)
+}
+
+// UpdateQuota applies the quota of a group to its directory
+func UpdateQuota(qm QuotaManager, sharesDir string, g *Group) error {
+	return qm.UpdateQuota(Dir(sharesDir, g.Name), g.QuotaGB)
+}
+
+// RemoveGroup deletes the directory of a group, with its shares, and its Unix
+// group
+func RemoveGroup(qm QuotaManager, sharesDir string, g *Group) error {
+	dir := Dir(sharesDir, g.Name)
+	if _, err := os.Stat(dir); err == nil {
+		if err := qm.RemoveQuotaDir(dir); err != nil {
+			if output, err := exec.Command("sudo", "/usr/bin/rm", "-rf", dir).CombinedOutput(); err != nil {
+				return fmt.Errorf("failed to remove group directory: %w (output: %s)", err, output)
+			}
+		}
+	}
+
+	if err := exec.Command("getent", "group", g.UnixGroup()).Run(); err == nil {
+		if output, err := exec.Command("sudo", "/usr/sbin/groupdel", g.UnixGroup()).CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to delete unix group %s: %w (output: %s)", g.UnixGroup(), err, output)
+		}
+	}
+	return nil
+}
+
+// AddUnixMember adds a user to the Unix group of a group
+func AddUnixMember(g *Group, username string) error {
+	if output, err := exec.Command("sudo", "/usr/bin/gpasswd", "-a", username, g.UnixGroup()).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to add %s to unix group %s: %w (output: %s)", username, g.UnixGroup(), err, output)
+	}
+	return nil
+}
+
+// RemoveUnixMember removes a user from the Unix group of a group
+func RemoveUnixMember(g *Group, username string) error {
+	if output, err := exec.Command("sudo", "/usr/bin/gpasswd", "-d", username, g.UnixGroup()).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to remove %s from unix group %s: %w (output: %s)", username, g.UnixGroup(), err, output)
+	}
+	return nil
+}
+
+// SetupShare creates the directory of a group share and the trash of each
+// member. Files are group-owned by anemone so that the members, who connect
+// as themselves, and the Anemone service can all reach them.
+func SetupShare(s *Share, members []Member) error {
+	if err := exec.Command("sudo", "/usr/bin/mkdir", "-p", s.Path).Run(); err != nil {
+		return fmt.Errorf("failed to create share directory: %w", err)
+	}
+	if err := setGroupPermissions(s.Path); err != nil {
+		return err
+	}
+	for _, m := range members {
+		if err := SetupTrash(s.Path, m.Username); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetupTrash pre-creates the trash of a member in a group share, as the Samba
+// recycle module would create it out of reach of the Anemone service. Every
+// user is in the anemone group, so the trash is only open to its owner and,
+// through an ACL inherited by what is deleted into it, to the service.
+func SetupTrash(sharePath, username string) error {
+	trashDir := filepath.Join(sharePath, ".trash", username)
+	if err := exec.Command("sudo", "/usr/bin/mkdir", "-p", trashDir).Run(); err != nil {
+		return fmt.Errorf("failed to create trash directory: %w", err)
+	}
+	if err := exec.Command("sudo", "/usr/bin/chown", "-R", username+":anemone", trashDir).Run(); err != nil {
+		return fmt.Errorf("failed to set trash directory ownership: %w", err)
+	}
+	if err := exec.Command("sudo", "/usr/bin/chmod", "2700", trashDir).Run(); err != nil {
+		return fmt.Errorf("failed to set trash directory permissions: %w", err)
+	}
+	service, err := user.Current()
+	if err != nil {
+		return fmt.Errorf("failed to get service user: %w", err)
+	}
+	acl := fmt.Sprintf("u:%s:rwX,d:u:%s:rwX", service.Username, service.Username)
+	if output, err := exec.Command("sudo", "/usr/bin/setfacl", "-R", "-m", acl, trashDir).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to set trash directory ACL: %w (output: %s)", err, output)
+	}
+	return nil
+}
+
+// SecureTrashes makes private the trashes of group shares created open to the
+// anemone group, before SetupTrash set an ACL on them
+func SecureTrashes(db *sql.DB) error {
+	shares, err := GetAllShares(db)
+	if err != nil {
+		return err
+	}
+	for _, s := range shares {
+		members, err := GetMembers(db, s.GroupID)
+		if err != nil {
+			return err
+		}
+		for _, m := range members {
+			trashDir := filepath.Join(s.Path, ".trash", m.Username)
+			if _, err := os.Stat(trashDir); err != nil {
+				continue
+			}
+			if _, err := syscall.Getxattr(trashDir, "system.posix_acl_access", nil); err == nil {
+				continue
+			}
+			if err := SetupTrash(s.Path, m.Username); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// RemoveShareDir deletes the directory of a group share
+func RemoveShareDir(s *Share) error {
+	if output, err := exec.Command("sudo", "/usr/bin/rm", "-rf", s.Path).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to remove share directory: %w (output: %s)", err, output)
+	}
+	return nil
+}
+
+// setGroupPermissions gives a directory to the anemone group, setgid so that
+// new files inherit it
+func setGroupPermissions(dir string) error {
+	if err := exec.Command("sudo", "/usr/bin/chown", ":anemone", dir).Run(); err != nil {
+		return fmt.Errorf("failed to set directory ownership: %w", err)
+	}
+	if err := exec.Command("sudo", "/usr/bin/chmod", "2770", dir).Run(); err != nil {
+		return fmt.Errorf("failed to set directory permissions: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,505 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+// Package integrity runs periodic checks of the files stored on USB backup drives,
+// on peers and on cloud destinations, records their results and sends damaged files again.
+package integrity
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	gosync "sync"
+	"time"
+
+	"github.com/juste-un-gars/anemone/internal/logger"
+	"github.com/juste-un-gars/anemone/internal/peers"
+	"github.com/juste-un-gars/anemone/internal/rclone"
+	"github.com/juste-un-gars/anemone/internal/sync"
+	"github.com/juste-un-gars/anemone/internal/usbbackup"
+)
+
+// Target types
+const (
+	TargetUSB   = "usb"
+	TargetPeer  = "peer"
+	TargetCloud = "cloud"
+)
+
+// ErrRunning is returned when a check or a repair is already in progress
+var ErrRunning = errors.New("an integrity check is already running")
+
+var (
+	running   bool
+	runningMu gosync.Mutex
+)
+
+// Failure is a file that failed a check
+type Failure struct {
+	ShareID int    `json:"share_id,omitempty"` // Peer checks only
+	Share   string `json:"share"`
+	Path    string `json:"path"`
+	Kind    string `json:"kind,omitempty"` // Cloud checks only: "missing", "differ", "extra" or "error"
+	Error   string `json:"error"`
+}
+
+// Check is the result of an integrity check of one USB drive, peer or cloud destination
+type Check struct {
+	ID            int
+	TargetType    string // "usb", "peer" or "cloud"
+	TargetID      int
+	TargetName    string
+	SamplePercent int
+	Status        string // "running", "success", "failed" (damaged files), "error"
+	FilesChecked  int
+	FilesFailed   int
+	FilesRepaired int
+	Failures      []Failure
+	ErrorMessage  string
+	StartedAt     time.Time
+	CompletedAt   *time.Time
+}
+
+// Settings controls the scheduled checks
+type Settings struct {
+	Enabled       bool
+	IntervalDays  int
+	SamplePercent int // Share of the files checked by scheduled runs (100 = all)
+}
+
+// GetSettings returns the scheduled check settings (monthly, 10% sample by default)
+func GetSettings(db *sql.DB) (*Settings, error) {
+	settings := &Settings{IntervalDays: 30, SamplePercent: 10}
+	rows, err := db.Query(`SELECT key, value FROM system_config
+		WHERE key IN ('integrity_check_enabled', 'integrity_check_interval_days', 'integrity_check_sample_percent')`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get integrity check settings: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, fmt.Errorf("failed to scan integrity check setting: %w", err)
+		}
+		switch key {
+		case "integrity_check_enabled":
+			settings.Enabled = value == "true"
+		case "integrity_check_interval_days":
+			if n, err := strconv.Atoi(value); err == nil && n > 0 {
+				settings.IntervalDays = n
+			}
+		case "integrity_check_sample_percent":
+			if n, err := strconv.Atoi(value); err == nil && n > 0 && n <= 100 {
+				settings.SamplePercent = n
+			}
+		}
+	}
+	return settings, rows.Err()
+}
+
+// SaveSettings stores the scheduled check settings
+func SaveSettings(db *sql.DB, settings *Settings) error {
+	if settings.IntervalDays < 1 {
+		return fmt.Errorf("interval must be at least one day")
+	}
+	if settings.SamplePercent < 1 || settings.SamplePercent > 100 {
+		return fmt.Errorf("sample must be between 1 and 100 percent")
+	}
+
+	values := map[string]string{
+		"integrity_check_enabled":        strconv.FormatBool(settings.Enabled),
+		"integrity_check_interval_days":  strconv.Itoa(settings.IntervalDays),
+		"integrity_check_sample_percent": strconv.Itoa(settings.SamplePercent),
+	}
+	for key, value := range values {
+		_, err := db.Exec(`INSERT INTO system_config (key, value, updated_at)
+			VALUES (?, ?, CURRENT_TIMESTAMP)
+			ON CONFLICT(key) DO UPDATE SET value = excluded.value, updated_at = excluded.updated_at`, key, value)
+		if err != nil {
+			return fmt.Errorf("failed to save %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// IsRunning reports whether a check or a repair is in progress
+func IsRunning() bool {
+	runningMu.Lock()
+	defer runningMu.Unlock()
+	return running
+}
+
+// start marks a run as in progress, or returns ErrRunning
+func start() error {
+	runningMu.Lock()
+	defer runningMu.Unlock()
+	if running {
+		return ErrRunning
+	}
+	running = true
+	return nil
+}
+
+// done ends a run started with start
+func done() {
+	runningMu.Lock()
+	defer runningMu.Unlock()
+	running = false
+}
+
+// RunAll checks the mounted USB drives holding shares and the enabled peers
+func RunAll(db *sql.DB, samplePercent int) error {
+	if err := start(); err != nil {
+		return err
+	}
+	defer done()
+
+	backups, err := usbbackup.GetEnabled(db)
+	if err != nil {
+		return err
+	}
+	for _, b := range backups {
+		if b.BackupType == usbbackup.BackupTypeConfig || !b.IsMounted() {
+			continue
+		}
+		runUSB(db, b, samplePercent)
+	}
+
+	allPeers, err := peers.GetAll(db)
+	if err != nil {
+		return err
+	}
+	for _, p := range allPeers {
+		if !p.Enabled {
+			continue
+		}
+		runPeer(db, p, samplePercent)
+	}
+	return nil
+}
+
+// RunUSB checks the drive of a USB backup
+func RunUSB(db *sql.DB, backupID, samplePercent int) error {
+	if err := start(); err != nil {
+		return err
+	}
+	defer done()
+
+	backup, err := usbbackup.GetByID(db, backupID)
+	if err != nil {
+		return err
+	}
+	runUSB(db, backup, samplePercent)
+	return nil
+}
+
+// RunPeer checks the files stored on a peer
+func RunPeer(db *sql.DB, peerID, samplePercent int) error {
+	if err := start(); err != nil {
+		return err
+	}
+	defer done()
+
+	peer, err := peers.GetByID(db, peerID)
+	if err != nil {
+		return err
+	}
+	runPeer(db, peer, samplePercent)
+	return nil
+}
+
+// RunCloud compares a cloud destination with the local files. Cloud checks always
+// cover all files: rclone compares sizes and checksums without downloading them.
+func RunCloud(db *sql.DB, backupID int, dataDir, incomingDir string) error {
+	if err := start(); err != nil {
+		return err
+	}
+	defer done()
+
+	backup, err := rclone.GetByID(db, backupID)
+	if err != nil {
+		return err
+	}
+	runCloud(db, backup, dataDir, incomingDir)
+	return nil
+}
+
+// runUSB checks a USB drive and records the result
+func runUSB(db *sql.DB, backup *usbbackup.USBBackup, samplePercent int) {
+	id, err := create(db, TargetUSB, backup.ID, backup.Name, samplePercent)
+	if err != nil {
+		logger.Warn("Integrity check: failed to record check", "error", err)
+		return
+	}
+
+	masterKey, serverName, err := serverKeys(db)
+	if err != nil {
+		complete(db, id, 0, nil, err)
+		return
+	}
+	checked, usbFailures, err := usbbackup.Verify(db, backup, masterKey, serverName, samplePercent)
+	failures := make([]Failure, len(usbFailures))
+	for i, f := range usbFailures {
+		failures[i] = Failure{Share: f.Share, Path: f.Path, Error: f.Error}
+	}
+	complete(db, id, checked, failures, err)
+}
+
+// runPeer checks a peer and records the result
+func runPeer(db *sql.DB, peer *peers.Peer, samplePercent int) {
+	id, err := create(db, TargetPeer, peer.ID, peer.Name, samplePercent)
+	if err != nil {
+		logger.Warn("Integrity check: failed to record check", "error", err)
+		return
+	}
+
+	checked, peerFailures, err := sync.VerifyPeer(db, peer.ID, samplePercent)
+	failures := make([]Failure, len(peerFailures))
+	for i, f := range peerFailures {
+		failures[i] = Failure{ShareID: f.ShareID, Share: f.ShareName, Path: f.Path, Error: f.Error}
+	}
+	complete(db, id, checked, failures, err)
+}
+
+// runCloud checks a cloud destination and records the result
+func runCloud(db *sql.DB, backup *rclone.RcloneBackup, dataDir, incomingDir string) {
+	id, err := create(db, TargetCloud, backup.ID, backup.Name, 100)
+	if err != nil {
+		logger.Warn("Integrity check: failed to record check", "error", err)
+		return
+	}
+
+	checked, failures, err := checkCloud(db, backup, dataDir, incomingDir)
+	complete(db, id, checked, failures, err)
+}
+
+// checkCloud compares a cloud destination with the local files
+func checkCloud(db *sql.DB, backup *rclone.RcloneBackup, dataDir, incomingDir string) (int, []Failure, error) {
+	checked, diffs, err := rclone.Check(db, backup, dataDir, incomingDir)
+	failures := make([]Failure, len(diffs))
+	for i, d := range diffs {
+		failures[i] = Failure{Share: d.Source, Path: d.Path, Kind: d.Kind, Error: d.Error}
+	}
+	return checked, failures, err
+}
+
+// Repair sends the damaged files of a check again and records the files still damaged.
+// Cloud destinations are repaired by a full sync followed by a new comparison.
+func Repair(db *sql.DB, checkID int, dataDir, incomingDir string) error {
+	if err := start(); err != nil {
+		return err
+	}
+	defer done()
+
+	check, err := GetByID(db, checkID)
+	if err != nil {
+		return err
+	}
+	if len(check.Failures) == 0 {
+		return nil
+	}
+
+	var repaired int
+	var remaining []Failure
+	switch check.TargetType {
+	case TargetUSB:
+		backup, err := usbbackup.GetByID(db, check.TargetID)
+		if err != nil {
+			return err
+		}
+		masterKey, serverName, err := serverKeys(db)
+		if err != nil {
+			return err
+		}
+		usbFailures := make([]usbbackup.VerifyFailure, len(check.Failures))
+		for i, f := range check.Failures {
+			usbFailures[i] = usbbackup.VerifyFailure{Share: f.Share, Path: f.Path, Error: f.Error}
+		}
+		n, left, repairErr := usbbackup.RepairFiles(db, backup, masterKey, serverName, usbFailures)
+		if repairErr != nil && n == 0 {
+			return repairErr
+		}
+		repaired = n
+		for _, f := range left {
+			remaining = append(remaining, Failure{Share: f.Share, Path: f.Path, Error: f.Error})
+		}
+	case TargetPeer:
+		peerFailures := make([]sync.VerifyFailure, len(check.Failures))
+		for i, f := range check.Failures {
+			peerFailures[i] = sync.VerifyFailure{ShareID: f.ShareID, ShareName: f.Share, Path: f.Path, Error: f.Error}
+		}
+		n, left, repairErr := sync.RepairPeerFiles(db, check.TargetID, peerFailures)
+		if repairErr != nil && n == 0 {
+			return repairErr
+		}
+		repaired = n
+		for _, f := range left {
+			remaining = append(remaining, Failure{ShareID: f.ShareID, Share: f.ShareName, Path: f.Path, Error: f.Error})
+		}
+	case TargetCloud:
+		backup, err := rclone.GetByID(db, check.TargetID)
+		if err != nil {
+			return err
+		}
+		if rclone.IsBackupSyncing(backup.ID) {
+			return fmt.Errorf("a sync to this destination is running")
+		}
+		if _, err := rclone.Sync(db, backup, dataDir, incomingDir); err != nil {
+			return err
+		}
+		_, left, err := checkCloud(db, backup, dataDir, incomingDir)
+		if err != nil {
+			return err
+		}
+		remaining = left
+		if n := len(check.Failures) - len(left); n > 0 {
+			repaired = n
+		}
+	default:
+		return fmt.Errorf("unknown check target: %s", check.TargetType)
+	}
+
+	status := "success"
+	if len(remaining) > 0 {
+		status = "failed"
+	}
+	failuresJSON, _ := json.Marshal(nonNil(remaining))
+	_, err = db.Exec(`UPDATE integrity_checks SET status = ?, files_repaired = files_repaired + ?, failures = ?
+		WHERE id = ?`, status, repaired, string(failuresJSON), checkID)
+	if err != nil {
+		return fmt.Errorf("failed to record repair: %w", err)
+	}
+	logger.Info("Integrity check: files repaired", "target", check.TargetName, "repaired", repaired, "remaining", len(remaining))
+	return nil
+}
+
+// serverKeys returns the master key and the server name used to open USB drives
+func serverKeys(db *sql.DB) (string, string, error) {
+	var masterKey string
+	if err := db.QueryRow("SELECT value FROM system_config WHERE key = 'master_key'").Scan(&masterKey); err != nil {
+		return "", "", fmt.Errorf("failed to get master key: %w", err)
+	}
+	serverName, _ := sync.GetServerName(db)
+	if serverName == "" {
+		serverName = "anemone"
+	}
+	return masterKey, serverName, nil
+}
+
+// create records the start of a check
+func create(db *sql.DB, targetType string, targetID int, targetName string, samplePercent int) (int, error) {
+	result, err := db.Exec(`INSERT INTO integrity_checks (target_type, target_id, target_name, sample_percent, status)
+		VALUES (?, ?, ?, ?, 'running')`, targetType, targetID, targetName, samplePercent)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	return int(id), err
+}
+
+// complete records the outcome of a check
+func complete(db *sql.DB, id, checked int, failures []Failure, checkErr error) {
+	status, errMsg := "success", ""
+	switch {
+	case checkErr != nil:
+		status, errMsg = "error", checkErr.Error()
+	case len(failures) > 0:
+		status = "failed"
+	}
+	failuresJSON, _ := json.Marshal(nonNil(failures))
+
+	_, err := db.Exec(`UPDATE integrity_checks SET status = ?, files_checked = ?, files_failed = ?, failures = ?,
+		error_message = ?, completed_at = CURRENT_TIMESTAMP WHERE id = ?`,
+		status, checked, len(failures), string(failuresJSON), errMsg, id)
+	if err != nil {
+		logger.Warn("Integrity check: failed to record result", "id", id, "error", err)
+	}
+	if status != "success" {
+		logger.Warn("Integrity check found problems", "id", id, "status", status, "failed", len(failures), "error", errMsg)
+	}
+}
+
+// nonNil keeps empty failure lists serialized as [] rather than null
+func nonNil(failures []Failure) []Failure {
+	if failures == nil {
+		return []Failure{}
+	}
+	return failures
+}
+
+const checkColumns = `id, target_type, target_id, target_name, sample_percent, status, files_checked, files_failed,
+	files_repaired, failures, error_message, started_at, completed_at`
+
+// scanCheck reads a row selected with checkColumns
+func scanCheck(scan func(...any) error) (*Check, error) {
+	c := &Check{}
+	var failures, errMsg sql.NullString
+	if err := scan(&c.ID, &c.TargetType, &c.TargetID, &c.TargetName, &c.SamplePercent, &c.Status,
+		&c.FilesChecked, &c.FilesFailed, &c.FilesRepaired, &failures, &errMsg, &c.StartedAt, &c.CompletedAt); err != nil {
+		return nil, err
+	}
+	c.ErrorMessage = errMsg.String
+	if failures.String != "" {
+		json.Unmarshal([]byte(failures.String), &c.Failures)
+	}
+	return c, nil
+}
+
+// GetByID returns a check with its list of failures
+func GetByID(db *sql.DB, id int) (*Check, error) {
+	c, err := scanCheck(db.QueryRow("SELECT "+checkColumns+" FROM integrity_checks WHERE id = ?", id).Scan)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("integrity check not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get integrity check: %w", err)
+	}
+	return c, nil
+}
+
+// GetRecent returns the latest checks, newest first
+func GetRecent(db *sql.DB, limit int) ([]*Check, error) {
+	rows, err := db.Query("SELECT "+checkColumns+" FROM integrity_checks ORDER BY started_at DESC, id DESC LIMIT ?", limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query integrity checks: %w", err)
+	}
+	defer rows.Close()
+
+	var checks []*Check
+	for rows.Next() {
+		c, err := scanCheck(rows.Scan)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan integrity check: %w", err)
+		}
+		checks = append(checks, c)
+	}
+	return checks, rows.Err()
+}
+
+// GetLatest returns the latest check of a target, or nil if it was never checked
+func GetLatest(db *sql.DB, targetType string, targetID int) (*Check, error) {
+	c, err := scanCheck(db.QueryRow("SELECT "+checkColumns+` FROM integrity_checks
+		WHERE target_type = ? AND target_id = ? ORDER BY started_at DESC, id DESC LIMIT 1`, targetType, targetID).Scan)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get integrity check: %w", err)
+	}
+	return c, nil
+}
+
+// lastRun returns when the last scheduled check of USB drives and peers started (zero if never).
+// Cloud destinations follow their own schedule.
+func lastRun(db *sql.DB) (time.Time, error) {
+	var last time.Time
+	err := db.QueryRow("SELECT started_at FROM integrity_checks WHERE target_type != ? ORDER BY started_at DESC LIMIT 1", TargetCloud).Scan(&last)
+	if err == sql.ErrNoRows {
+		return time.Time{}, nil
+	}
+	return last, err
+}
@@ -0,0 +1,74 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+package integrity
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/juste-un-gars/anemone/internal/logger"
+	"github.com/juste-un-gars/anemone/internal/rclone"
+)
+
+// StartScheduler runs the scheduled integrity checks. It checks every hour whether
+// the last check is older than the configured interval, and whether a cloud
+// destination is due for its own check.
+func StartScheduler(db *sql.DB, dataDir, incomingDir string) {
+	// Checks interrupted by a restart will never complete
+	if _, err := db.Exec(`UPDATE integrity_checks SET status = 'error', error_message = 'check interrupted (service restart)',
+		completed_at = CURRENT_TIMESTAMP WHERE status = 'running'`); err != nil {
+		logger.Warn("Integrity check: failed to cleanup stale checks", "error", err)
+	}
+
+	go func() {
+		ticker := time.NewTicker(1 * time.Hour)
+		defer ticker.Stop()
+
+		for {
+			<-ticker.C
+
+			runDueCloudChecks(db, dataDir, incomingDir)
+
+			settings, err := GetSettings(db)
+			if err != nil || !settings.Enabled {
+				continue
+			}
+			last, err := lastRun(db)
+			if err != nil || time.Since(last) < time.Duration(settings.IntervalDays)*24*time.Hour {
+				continue
+			}
+
+			logger.Info("Integrity check: starting scheduled check", "sample_percent", settings.SamplePercent)
+			if err := RunAll(db, settings.SamplePercent); err != nil {
+				logger.Warn("Integrity check: scheduled check failed", "error", err)
+			}
+		}
+	}()
+
+	logger.Info("✅ Integrity check scheduler started (checks every hour)")
+}
+
+// runDueCloudChecks checks the enabled cloud destinations whose last check is older than their interval
+func runDueCloudChecks(db *sql.DB, dataDir, incomingDir string) {
+	backups, err := rclone.GetEnabled(db)
+	if err != nil {
+		logger.Warn("Integrity check: failed to get cloud destinations", "error", err)
+		return
+	}
+	for _, b := range backups {
+		if b.CheckIntervalDays <= 0 || b.LastStatus == "running" {
+			continue
+		}
+		last, err := GetLatest(db, TargetCloud, b.ID)
+		if err != nil || (last != nil && time.Since(last.StartedAt) < time.Duration(b.CheckIntervalDays)*24*time.Hour) {
+			continue
+		}
+
+		logger.Info("Integrity check: starting scheduled cloud check", "name", b.Name)
+		if err := RunCloud(db, b.ID, dataDir, incomingDir); err != nil {
+			logger.Warn("Integrity check: scheduled cloud check failed", "name", b.Name, "error", err)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+// Package nfs exports user shares over NFS, alongside Samba.
+//
+// The NFS settings of a share (allowed clients, read-only, squash) are stored in
+// the database and rendered into /etc/exports.d/anemone.exports. Squashed
+// requests are mapped to the owner of the share and the anemone group, which
+// own its files, so that NFS clients don't need matching UIDs.
+package nfs
+
+import (
+	"database/sql"
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Squash modes of an export
+const (
+	SquashRoot = "root" // Only root is mapped to the owner of the share
+	SquashAll  = "all"  // Every user is mapped to the owner of the share
+)
+
+// fsidBase is added to the share ID to give each export a stable fsid, which
+// NFSv4 needs for filesystems without a UUID per export (Btrfs subvolumes)
+const fsidBase = 10000
+
+// Export is the NFS export of a share
+type Export struct {
+	ShareID   int
+	ShareName string
+	Owner     string // Username owning the share
+	Path      string
+	Enabled   bool
+	Clients   []string // IP addresses or CIDR networks allowed to mount the share
+	ReadOnly  bool
+	Squash    string
+}
+
+// Identity maps a username to the UID and GID squashed requests run as
+type Identity func(username string) (uid, gid int, err error)
+
+// SystemIdentity returns the UID of the user and the GID of the anemone group
+func SystemIdentity(username string) (int, int, error) {
+	u, err := user.Lookup(username)
+	if err != nil {
+		return 0, 0, fmt.Errorf("unknown user %s: %w", username, err)
+	}
+	uid, err := strconv.Atoi(u.Uid)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid UID for %s: %w", username, err)
+	}
+
+	g, err := user.LookupGroup("anemone")
+	if err != nil {
+		return 0, 0, fmt.Errorf("unknown group anemone: %w", err)
+	}
+	gid, err := strconv.Atoi(g.Gid)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid GID for anemone: %w", err)
+	}
+	return uid, gid, nil
+}
+
+// ParseClients splits a list of clients separated by commas, spaces or new lines
+func ParseClients(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\n' || r == '\r' || r == '\t'
+	})
+}
+
+// ValidateClients checks that each client is an IP address or a CIDR network.
+// Host names and wildcards are refused: the allow-list must be explicit.
+func ValidateClients(clients []string) error {
+	if len(clients) == 0 {
+		return fmt.Errorf("at least one client network is required")
+	}
+	for _, c := range clients {
+		if strings.Contains(c, "/") {
+			if _, _, err := net.ParseCIDR(c); err != nil {
+				return fmt.Errorf("invalid network %q", c)
+			}
+		} else if net.ParseIP(c) == nil {
+			return fmt.Errorf("invalid IP address %q", c)
+		}
+	}
+	return nil
+}
+
+// validate checks the settings of an export. A disabled export may have no
+// clients yet.
+func (e *Export) validate() error {
+	if e.Enabled || len(e.Clients) > 0 {
+		if err := ValidateClients(e.Clients); err != nil {
+			return err
+		}
+	}
+	if e.Squash != SquashRoot && e.Squash != SquashAll {
+		return fmt.Errorf("invalid squash mode %q", e.Squash)
+	}
+	return nil
+}
+
+// Validate checks the exports before they are installed: valid settings and
+// an existing directory, exported once
+func Validate(exports []*Export) error {
+	paths := make(map[string]bool)
+	for _, e := range exports {
+		if err := e.validate(); err != nil {
+			return fmt.Errorf("share %s: %w", e.ShareName, err)
+		}
+		if !filepath.IsAbs(e.Path) || strings.ContainsAny(e.Path, "\"\n") {
+			return fmt.Errorf("share %s: invalid path %q", e.ShareName, e.Path)
+		}
+		info, err := os.Stat(e.Path)
+		if err != nil || !info.IsDir() {
+			return fmt.Errorf("share %s: %s is not a directory", e.ShareName, e.Path)
+		}
+		if paths[e.Path] {
+			return fmt.Errorf("share %s: %s is exported twice", e.ShareName, e.Path)
+		}
+		paths[e.Path] = true
+	}
+	return nil
+}
+
+// Options returns the export options of a share, for a client
+func (e *Export) Options(uid, gid int) string {
+	access := "rw"
+	if e.ReadOnly {
+		access = "ro"
+	}
+	squash := "root_squash"
+	if e.Squash == SquashAll {
+		squash = "all_squash"
+	}
+	return fmt.Sprintf("%s,sync,no_subtree_check,sec=sys,%s,anonuid=%d,anongid=%d,fsid=%d",
+		access, squash, uid, gid, fsidBase+e.ShareID)
+}
+
+// Render renders the exports file, one line per share, sorted by path
+func Render(exports []*Export, identity Identity) (string, error) {
+	sorted := append([]*Export(nil), exports...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Path < sorted[j].Path })
+
+	var b strings.Builder
+	b.WriteString("# Generated by Anemone - do not edit, changes are overwritten\n")
+	for _, e := range sorted {
+		uid, gid, err := identity(e.Owner)
+		if err != nil {
+			return "", fmt.Errorf("share %s: %w", e.ShareName, err)
+		}
+		options := e.Options(uid, gid)
+
+		fmt.Fprintf(&b, "\n# %s (%s)\n\"%s\"", e.ShareName, e.Owner, e.Path)
+		for _, c := range e.Clients {
+			fmt.Fprintf(&b, " %s(%s)", c, options)
+		}
+		b.WriteString("\n")
+	}
+	return b.String(), nil
+}
+
+const exportColumns = `s.id, s.name, u.username, s.path, e.enabled, e.clients, e.read_only, e.squash`
+
+// scanExport scans the exportColumns of a row
+func scanExport(row interface{ Scan(...interface{}) error }) (*Export, error) {
+	e := &Export{}
+	var clients string
+	if err := row.Scan(&e.ShareID, &e.ShareName, &e.Owner, &e.Path, &e.Enabled, &clients, &e.ReadOnly, &e.Squash); err != nil {
+		return nil, err
+	}
+	e.Clients = ParseClients(clients)
+	return e, nil
+}
+
+// Get retrieves the export of a share, nil if the share was never exported
+func Get(db *sql.DB, shareID int) (*Export, error) {
+	row := db.QueryRow(`SELECT `+exportColumns+` FROM nfs_exports e
+		JOIN shares s ON s.id = e.share_id
+		JOIN users u ON u.id = s.user_id
+		WHERE e.share_id = ?`, shareID)
+	e, err := scanExport(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get NFS export: %w", err)
+	}
+	return e, nil
+}
+
+// GetAll retrieves the exports of all shares, enabled or not
+func GetAll(db *sql.DB) ([]*Export, error) {
+	rows, err := db.Query(`SELECT ` + exportColumns + ` FROM nfs_exports e
+		JOIN shares s ON s.id = e.share_id
+		JOIN users u ON u.id = s.user_id
+		ORDER BY s.name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query NFS exports: %w", err)
+	}
+	defer rows.Close()
+
+	var exports []*Export
+	for rows.Next() {
+		e, err := scanExport(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan NFS export: %w", err)
+		}
+		exports = append(exports, e)
+	}
+	return exports, rows.Err()
+}
+
+// GetEnabled retrieves the exports to install
+func GetEnabled(db *sql.DB) ([]*Export, error) {
+	all, err := GetAll(db)
+	if err != nil {
+		return nil, err
+	}
+	var enabled []*Export
+	for _, e := range all {
+		if e.Enabled {
+			enabled = append(enabled, e)
+		}
+	}
+	return enabled, nil
+}
+
+// Save creates or updates the export of a share
+func Save(db *sql.DB, e *Export) error {
+	if err := e.validate(); err != nil {
+		return err
+	}
+	_, err := db.Exec(`INSERT INTO nfs_exports (share_id, enabled, clients, read_only, squash, updated_at)
+		VALUES (?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(share_id) DO UPDATE SET enabled = excluded.enabled, clients = excluded.clients,
+			read_only = excluded.read_only, squash = excluded.squash, updated_at = CURRENT_TIMESTAMP`,
+		e.ShareID, e.Enabled, strings.Join(e.Clients, ","), e.ReadOnly, e.Squash)
+	if err != nil {
+		return fmt.Errorf("failed to save NFS export: %w", err)
+	}
+	return nil
+}
+
+// Delete removes the export of a share
+func Delete(db *sql.DB, shareID int) error {
+	if _, err := db.Exec(`DELETE FROM nfs_exports WHERE share_id = ?`, shareID); err != nil {
+		return fmt.Errorf("failed to delete NFS export: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+package nfs
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func testIdentity(username string) (int, int, error) {
+	if username == "alice" {
+		return 1001, 990, nil
+	}
+	return 0, 0, fmt.Errorf("unknown user %s", username)
+}
+
+func TestValidateClients(t *testing.T) {
+	for clients, valid := range map[string]bool{
+		"192.168.1.0/24":             true,
+		"10.0.0.5, fd00::/64":        true,
+		"":                           false,
+		"*":                          false,
+		"nas.local":                  false,
+		"192.168.1.0/33":             false,
+		"192.168.1.0/24(rw,no_root)": false,
+	} {
+		if err := ValidateClients(ParseClients(clients)); (err == nil) != valid {
+			t.Errorf("ValidateClients(%q) = %v, want valid %v", clients, err, valid)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	exports := []*Export{
+		{ShareID: 3, ShareName: "data_alice", Owner: "alice", Path: "/srv/anemone/shares/alice/data",
+			Clients: []string{"192.168.1.0/24", "10.0.0.5"}, Squash: SquashAll},
+		{ShareID: 2, ShareName: "backup_alice", Owner: "alice", Path: "/srv/anemone/shares/alice/backup",
+			Clients: []string{"192.168.1.0/24"}, ReadOnly: true, Squash: SquashRoot},
+	}
+	content, err := Render(exports, testIdentity)
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+
+	want := []string{
+		`"/srv/anemone/shares/alice/backup" 192.168.1.0/24(ro,sync,no_subtree_check,sec=sys,root_squash,anonuid=1001,anongid=990,fsid=10002)`,
+		`"/srv/anemone/shares/alice/data" 192.168.1.0/24(rw,sync,no_subtree_check,sec=sys,all_squash,anonuid=1001,anongid=990,fsid=10003) 10.0.0.5(rw,sync,no_subtree_check,sec=sys,all_squash,anonuid=1001,anongid=990,fsid=10003)`,
+	}
+	if i, j := strings.Index(content, want[0]), strings.Index(content, want[1]); i < 0 || j < 0 || i > j {
+		t.Errorf("unexpected exports:\n%s", content)
+	}
+
+	exports[0].Owner = "bob"
+	if _, err := Render(exports, testIdentity); err == nil {
+		t.Error("Render accepted a share of an unknown user")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	dir := t.TempDir()
+	export := &Export{ShareName: "data", Path: dir, Enabled: true, Clients: []string{"10.0.0.0/8"}, Squash: SquashRoot}
+	if err := Validate([]*Export{export}); err != nil {
+		t.Errorf("Validate failed: %v", err)
+	}
+	if err := Validate([]*Export{export, export}); err == nil {
+		t.Error("Validate accepted a directory exported twice")
+	}
+
+	missing := *export
+	missing.Path = dir + "/missing"
+	if err := Validate([]*Export{&missing}); err == nil {
+		t.Error("Validate accepted a missing directory")
+	}
+	noClients := *export
+	noClients.Clients = nil
+	if err := Validate([]*Export{&noClients}); err == nil {
+		t.Error("Validate accepted an export without clients")
+	}
+	quoted := *export
+	quoted.Path = dir + `/a"b`
+	if err := Validate([]*Export{&quoted}); err == nil {
+		t.Error("Validate accepted a path with a quote")
+	}
+	squash := *export
+	squash.Squash = "none"
+	if err := Validate([]*Export{&squash}); err == nil {
+		t.Error("Validate accepted an invalid squash mode")
+	}
+}
+
+func TestSaveAndGet(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:?_foreign_keys=on")
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	defer db.Close()
+	_, err = db.Exec(`
+		CREATE TABLE users (id INTEGER PRIMARY KEY, username TEXT);
+		CREATE TABLE shares (id INTEGER PRIMARY KEY, user_id INTEGER, name TEXT, path TEXT);
+		CREATE TABLE nfs_exports (
+			share_id INTEGER PRIMARY KEY,
+			enabled BOOLEAN DEFAULT 1,
+			clients TEXT NOT NULL,
+			read_only BOOLEAN DEFAULT 0,
+			squash TEXT NOT NULL DEFAULT 'root',
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (share_id) REFERENCES shares(id) ON DELETE CASCADE
+		);
+		INSERT INTO users VALUES (1, 'alice');
+		INSERT INTO shares VALUES (1, 1, 'data_alice', '/srv/data'), (2, 1, 'backup_alice', '/srv/backup');`)
+	if err != nil {
+		t.Fatalf("Failed to create tables: %v", err)
+	}
+
+	if e, err := Get(db, 1); err != nil || e != nil {
+		t.Fatalf("Get of a share never exported = %+v, %v", e, err)
+	}
+	if err := Save(db, &Export{ShareID: 1, Enabled: true, Clients: []string{"bad"}, Squash: SquashRoot}); err == nil {
+		t.Error("Save accepted an invalid client")
+	}
+
+	export := &Export{ShareID: 1, Enabled: true, Clients: []string{"10.0.0.0/8", "192.168.1.7"}, Squash: SquashAll}
+	if err := Save(db, export); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	export.ReadOnly = true
+	if err := Save(db, export); err != nil {
+		t.Fatalf("Save update failed: %v", err)
+	}
+	if err := Save(db, &Export{ShareID: 2, Squash: SquashRoot}); err != nil {
+		t.Fatalf("Save of a disabled export failed: %v", err)
+	}
+
+	e, err := Get(db, 1)
+	if err != nil || e == nil {
+		t.Fatalf("Get = %+v, %v", e, err)
+	}
+	if e.Owner != "alice" || e.Path != "/srv/data" || !e.ReadOnly || len(e.Clients) != 2 || e.Squash != SquashAll {
+		t.Errorf("export = %+v", e)
+	}
+
+	enabled, err := GetEnabled(db)
+	if err != nil || len(enabled) != 1 || enabled[0].ShareID != 1 {
+		t.Errorf("GetEnabled = %+v, %v", enabled, err)
+	}
+}
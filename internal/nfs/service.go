@@ -0,0 +1,109 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+// This file contains the installation of the exports file and the status of
+// the NFS server.
+
+package nfs
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// ExportsPath is the exports file installed for the NFS server
+const ExportsPath = "/etc/exports.d/anemone.exports"
+
+// Config configures the generation of the exports file
+type Config struct {
+	ConfigPath string // Staging file, copied to ExportsPath once valid
+}
+
+// GenerateConfig renders the enabled exports, checks them and installs them.
+// If exportfs rejects the new file, the previous one is put back.
+func GenerateConfig(db *sql.DB, cfg *Config) error {
+	exports, err := GetEnabled(db)
+	if err != nil {
+		return err
+	}
+	if err := Validate(exports); err != nil {
+		return fmt.Errorf("invalid NFS exports: %w", err)
+	}
+	content, err := Render(exports, SystemIdentity)
+	if err != nil {
+		return fmt.Errorf("failed to render NFS exports: %w", err)
+	}
+
+	if !CheckNFSInstalled() {
+		if len(exports) > 0 {
+			return fmt.Errorf("NFS server is not installed")
+		}
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(cfg.ConfigPath), 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	previous, err := os.ReadFile(cfg.ConfigPath)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read previous exports: %w", err)
+	}
+
+	if err := install(cfg.ConfigPath, []byte(content)); err != nil {
+		// Put back the exports that were in place, none the first time
+		if rerr := install(cfg.ConfigPath, previous); rerr != nil {
+			return fmt.Errorf("%w (restoring the previous exports also failed: %v)", err, rerr)
+		}
+		return err
+	}
+	return nil
+}
+
+// install writes the exports file, copies it for the NFS server and reloads
+// the exports
+func install(stagingPath string, content []byte) error {
+	if err := os.WriteFile(stagingPath, content, 0644); err != nil {
+		return fmt.Errorf("failed to write exports file: %w", err)
+	}
+	if output, err := exec.Command("sudo", "/usr/bin/mkdir", "-p", filepath.Dir(ExportsPath)).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to create %s: %w (output: %s)", filepath.Dir(ExportsPath), err, output)
+	}
+	if output, err := exec.Command("sudo", "/usr/bin/cp", stagingPath, ExportsPath).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to install exports file: %w (output: %s)", err, output)
+	}
+	return ReloadConfig()
+}
+
+// ReloadConfig re-exports all directories. exportfs succeeds even when some
+// lines are ignored, so its warnings about the Anemone exports are errors.
+func ReloadConfig() error {
+	output, err := exec.Command("sudo", "/usr/sbin/exportfs", "-ra").CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to reload NFS exports: %w (output: %s)", err, output)
+	}
+	if strings.Contains(string(output), filepath.Base(ExportsPath)) {
+		return fmt.Errorf("NFS exports rejected: %s", strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// CheckNFSInstalled checks if the NFS server is installed
+func CheckNFSInstalled() bool {
+	_, err := exec.LookPath("exportfs")
+	if err != nil {
+		_, err = os.Stat("/usr/sbin/exportfs")
+	}
+	return err == nil
+}
+
+// GetServiceStatus returns the status of the NFS server
+func GetServiceStatus() (string, error) {
+	cmd := exec.Command("systemctl", "is-active", "nfs-server")
+	output, _ := cmd.Output()
+	return strings.TrimSpace(string(output)), nil
+}
@@ -72,6 +72,7 @@ type Peer struct {
 	SyncDayOfMonth      *int   // 1-31, NULL if not monthly
 	SyncIntervalMinutes int    // Interval in minutes for "interval" frequency
 	SyncTimeoutHours    int    // Sync timeout in hours (0 = disabled)
+	VerifyUploads       bool   // Ask the peer to read each uploaded file back from disk
 	CreatedAt           time.Time
 	UpdatedAt           time.Time
 }
@@ -82,12 +83,12 @@ func Create(db *sql.DB, peer *Peer) error {
 	// scheduled occurrence instead of triggering an immediate sync
 	query := `INSERT INTO peers (name, address, port, public_key, password, enabled, status,
 	          sync_enabled, sync_frequency, sync_time, sync_day_of_week, sync_day_of_month,
-	          sync_interval_minutes, sync_timeout_hours, last_sync, created_at, updated_at)
-	          VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)`
+	          sync_interval_minutes, sync_timeout_hours, verify_uploads, last_sync, created_at, updated_at)
+	          VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)`
 
 	result, err := db.Exec(query, peer.Name, peer.Address, peer.Port, peer.PublicKey, peer.Password,
 		peer.Enabled, peer.Status, peer.SyncEnabled, peer.SyncFrequency, peer.SyncTime,
-		peer.SyncDayOfWeek, peer.SyncDayOfMonth, peer.SyncIntervalMinutes, peer.SyncTimeoutHours)
+		peer.SyncDayOfWeek, peer.SyncDayOfMonth, peer.SyncIntervalMinutes, peer.SyncTimeoutHours, peer.VerifyUploads)
 	if err != nil {
 		return fmt.Errorf("failed to create peer: %w", err)
 	}
@@ -106,14 +107,14 @@ func GetByID(db *sql.DB, id int) (*Peer, error) {
 	peer := &Peer{}
 	query := `SELECT id, name, address, port, public_key, password, enabled, status, last_seen, last_sync,
 	          sync_enabled, sync_frequency, sync_time, sync_day_of_week, sync_day_of_month,
-	          sync_interval_minutes, sync_timeout_hours, created_at, updated_at
+	          sync_interval_minutes, sync_timeout_hours, verify_uploads, created_at, updated_at
 	          FROM peers WHERE id = ?`
 
 	err := db.QueryRow(query, id).Scan(
 		&peer.ID, &peer.Name, &peer.Address, &peer.Port, &peer.PublicKey, &peer.Password,
 		&peer.Enabled, &peer.Status, &peer.LastSeen, &peer.LastSync,
 		&peer.SyncEnabled, &peer.SyncFrequency, &peer.SyncTime, &peer.SyncDayOfWeek, &peer.SyncDayOfMonth,
-		&peer.SyncIntervalMinutes, &peer.SyncTimeoutHours, &peer.CreatedAt, &peer.UpdatedAt,
+		&peer.SyncIntervalMinutes, &peer.SyncTimeoutHours, &peer.VerifyUploads, &peer.CreatedAt, &peer.UpdatedAt,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -129,7 +130,7 @@ func GetByID(db *sql.DB, id int) (*Peer, error) {
 func GetAll(db *sql.DB) ([]*Peer, error) {
 	query := `SELECT id, name, address, port, public_key, password, enabled, status, last_seen, last_sync,
 	          sync_enabled, sync_frequency, sync_time, sync_day_of_week, sync_day_of_month,
-	          sync_interval_minutes, sync_timeout_hours, created_at, updated_at
+	          sync_interval_minutes, sync_timeout_hours, verify_uploads, created_at, updated_at
 	          FROM peers ORDER BY created_at DESC`
 
 	rows, err := db.Query(query)
@@ -145,7 +146,7 @@ func GetAll(db *sql.DB) ([]*Peer, error) {
 			&peer.ID, &peer.Name, &peer.Address, &peer.Port, &peer.PublicKey, &peer.Password,
 			&peer.Enabled, &peer.Status, &peer.LastSeen, &peer.LastSync,
 			&peer.SyncEnabled, &peer.SyncFrequency, &peer.SyncTime, &peer.SyncDayOfWeek, &peer.SyncDayOfMonth,
-			&peer.SyncIntervalMinutes, &peer.SyncTimeoutHours, &peer.CreatedAt, &peer.UpdatedAt,
+			&peer.SyncIntervalMinutes, &peer.SyncTimeoutHours, &peer.VerifyUploads, &peer.CreatedAt, &peer.UpdatedAt,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan peer: %w", err)
@@ -161,12 +162,12 @@ func Update(db *sql.DB, peer *Peer) error {
 	query := `UPDATE peers SET name = ?, address = ?, port = ?, public_key = ?, password = ?,
 	          enabled = ?, status = ?, sync_enabled = ?, sync_frequency = ?, sync_time = ?,
 	          sync_day_of_week = ?, sync_day_of_month = ?, sync_interval_minutes = ?, sync_timeout_hours = ?,
-	          updated_at = CURRENT_TIMESTAMP
+	          verify_uploads = ?, updated_at = CURRENT_TIMESTAMP
 	          WHERE id = ?`
 
 	_, err := db.Exec(query, peer.Name, peer.Address, peer.Port, peer.PublicKey, peer.Password,
 		peer.Enabled, peer.Status, peer.SyncEnabled, peer.SyncFrequency, peer.SyncTime,
-		peer.SyncDayOfWeek, peer.SyncDayOfMonth, peer.SyncIntervalMinutes, peer.SyncTimeoutHours, peer.ID)
+		peer.SyncDayOfWeek, peer.SyncDayOfMonth, peer.SyncIntervalMinutes, peer.SyncTimeoutHours, peer.VerifyUploads, peer.ID)
 	if err != nil {
 		return fmt.Errorf("failed to update peer: %w", err)
 	}
@@ -174,14 +175,26 @@ func Update(db *sql.DB, peer *Peer) error {
 	return nil
 }
 
-// Delete deletes a peer
+// Delete deletes a peer and removes it from the peer selection of the shares.
+// The foreign key cascade is not relied upon: foreign keys are only enforced
+// on the connections that enabled them.
 func Delete(db *sql.DB, id int) error {
-	query := `DELETE FROM peers WHERE id = ?`
-	_, err := db.Exec(query, id)
+	tx, err := db.Begin()
 	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM share_peers WHERE peer_id = ?`, id); err != nil {
+		return fmt.Errorf("failed to remove peer from shares: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM peers WHERE id = ?`, id); err != nil {
 		return fmt.Errorf("failed to delete peer: %w", err)
 	}
 
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit peer deletion: %w", err)
+	}
 	return nil
 }
 
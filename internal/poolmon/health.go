@@ -0,0 +1,221 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+// This file compares the state of a pool and of its devices with the one seen at
+// the previous check, and records the alerts the changes raise in the shared
+// alert store.
+
+package poolmon
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/juste-un-gars/anemone/internal/alerts"
+	"github.com/juste-un-gars/anemone/internal/logger"
+	"github.com/juste-un-gars/anemone/internal/storage"
+)
+
+// Alert severities
+const (
+	SeverityInfo     = alerts.SeverityInfo
+	SeverityWarning  = alerts.SeverityWarning
+	SeverityCritical = alerts.SeverityCritical
+)
+
+// Alert kinds
+const (
+	KindPoolState   = "pool_state"   // Pool state changed from OldValue to NewValue
+	KindDeviceState = "device_state" // State of Device changed from OldValue to NewValue
+	KindErrors      = "errors"       // Attribute errors of Device grew from OldValue to NewValue
+	KindScrubErrors = "scrub_errors" // Scan Attribute finished with NewValue errors
+)
+
+// DeviceState is the state and error counters of a device of a pool
+type DeviceState struct {
+	State string
+	Read  uint64
+	Write uint64
+	Cksum uint64
+}
+
+// PoolState is the state of a pool and of its devices at a point in time
+type PoolState struct {
+	State   string
+	Devices map[string]DeviceState
+}
+
+// stateOf returns the state of a pool listed by storage.ListZFSPools
+func stateOf(pool storage.ZFSPool) PoolState {
+	state := PoolState{State: pool.State, Devices: map[string]DeviceState{}}
+	for _, vdev := range pool.VDevs {
+		for _, d := range vdev.Disks {
+			state.Devices[d.Name] = DeviceState{State: d.State, Read: d.Read, Write: d.Write, Cksum: d.Cksum}
+		}
+	}
+	return state
+}
+
+// arrayStateOf returns the state of an md array, in the terms of the ZFS pools
+func arrayStateOf(array storage.MDArray) PoolState {
+	state := PoolState{State: "ONLINE", Devices: map[string]DeviceState{}}
+	switch array.State {
+	case "degraded":
+		state.State = "DEGRADED"
+	case "inactive":
+		state.State = "FAULTED"
+	}
+	for _, m := range array.Members {
+		d := DeviceState{State: "ONLINE"}
+		switch m.State {
+		case storage.MDMemberSpare:
+			d.State = "AVAIL"
+		case storage.MDMemberFaulty:
+			d.State = "FAULTED"
+		}
+		state.Devices[m.Name] = d
+	}
+	return state
+}
+
+// Alert is a change of a pool worth the attention of an admin. Its subject is
+// the name of the pool.
+type Alert = alerts.Alert
+
+// stateRank orders the states of pools and devices from the best to the worst
+func stateRank(state string) int {
+	switch state {
+	case "ONLINE", "AVAIL": // AVAIL is an unused spare
+		return 0
+	case "DEGRADED", "OFFLINE":
+		return 1
+	}
+	return 2 // FAULTED, UNAVAIL, REMOVED, SUSPENDED
+}
+
+// stateSeverity returns the severity of a state change, from its new state
+func stateSeverity(prev, cur string) string {
+	switch {
+	case stateRank(cur) < stateRank(prev) || stateRank(cur) == 0:
+		return SeverityInfo
+	case stateRank(cur) == 1:
+		return SeverityWarning
+	}
+	return SeverityCritical
+}
+
+// compareStates returns the alerts raised by the change from prev to cur. A
+// pool or device seen for the first time is compared with a healthy one.
+func compareStates(prev, cur PoolState) []Alert {
+	var raised []Alert
+
+	prevState := prev.State
+	if prevState == "" {
+		prevState = "ONLINE"
+	}
+	if cur.State != prevState {
+		raised = append(raised, Alert{Severity: stateSeverity(prevState, cur.State), Kind: KindPoolState,
+			OldValue: prevState, NewValue: cur.State})
+	}
+
+	names := make([]string, 0, len(cur.Devices))
+	for name := range cur.Devices {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		c := cur.Devices[name]
+		p, seen := prev.Devices[name]
+		if !seen {
+			p = DeviceState{State: "ONLINE"}
+			if stateRank(c.State) == 0 {
+				p.State = c.State // A new healthy device or spare
+			}
+		}
+		if c.State != p.State {
+			raised = append(raised, Alert{Device: name, Severity: stateSeverity(p.State, c.State), Kind: KindDeviceState,
+				OldValue: p.State, NewValue: c.State})
+		}
+		// Counters go back to 0 after zpool clear, which is not an alert
+		counters := []struct {
+			name     string
+			old, new uint64
+		}{
+			{"read", p.Read, c.Read},
+			{"write", p.Write, c.Write},
+			{"cksum", p.Cksum, c.Cksum},
+		}
+		for _, counter := range counters {
+			if counter.new > counter.old {
+				raised = append(raised, Alert{Device: name, Severity: SeverityWarning, Kind: KindErrors, Attribute: counter.name,
+					OldValue: strconv.FormatUint(counter.old, 10), NewValue: strconv.FormatUint(counter.new, 10)})
+			}
+		}
+	}
+	return raised
+}
+
+// loadState returns the state of a pool seen at the previous check
+func loadState(db *sql.DB, pool string) (PoolState, error) {
+	state := PoolState{Devices: map[string]DeviceState{}}
+	rows, err := db.Query(`SELECT device, state, read_errors, write_errors, cksum_errors
+		FROM pool_device_states WHERE pool = ?`, pool)
+	if err != nil {
+		return state, fmt.Errorf("failed to query pool state: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		var d DeviceState
+		if err := rows.Scan(&name, &d.State, &d.Read, &d.Write, &d.Cksum); err != nil {
+			return state, fmt.Errorf("failed to scan pool state: %w", err)
+		}
+		// The pool itself is stored as the device without name
+		if name == "" {
+			state.State = d.State
+		} else {
+			state.Devices[name] = d
+		}
+	}
+	return state, rows.Err()
+}
+
+// saveState replaces the state of a pool seen at the previous check
+func saveState(db *sql.DB, pool string, state PoolState) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM pool_device_states WHERE pool = ?", pool); err != nil {
+		return fmt.Errorf("failed to clear pool state: %w", err)
+	}
+	insert := `INSERT INTO pool_device_states (pool, device, state, read_errors, write_errors, cksum_errors)
+		VALUES (?, ?, ?, ?, ?, ?)`
+	if _, err := tx.Exec(insert, pool, "", state.State, 0, 0, 0); err != nil {
+		return fmt.Errorf("failed to save pool state: %w", err)
+	}
+	for name, d := range state.Devices {
+		if _, err := tx.Exec(insert, pool, name, d.State, d.Read, d.Write, d.Cksum); err != nil {
+			return fmt.Errorf("failed to save device state: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// raiseAlert records an alert of a pool and logs it
+func raiseAlert(db *sql.DB, pool string, a Alert) {
+	a.Source, a.Subject = alerts.SourcePool, pool
+	logger.Warn("Pool monitor: Pool alert", "pool", pool, "device", a.Device, "severity", a.Severity,
+		"kind", a.Kind, "attribute", a.Attribute, "old", a.OldValue, "new", a.NewValue)
+
+	if err := alerts.Raise(db, a); err != nil {
+		logger.Warn("Pool monitor: Failed to record alert", "pool", pool, "error", err)
+	}
+}
@@ -0,0 +1,201 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+// Package poolmon watches the ZFS pools and the md arrays in the background. It
+// starts the scrubs of the pools and the checks of the arrays on their schedule,
+// keeps the history of their scrubs, checks and rebuilds, and raises alerts when
+// the state of a pool or of one of its devices changes or when their error
+// counters grow. An md array is handled as a pool named after it (e.g. md0).
+package poolmon
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/juste-un-gars/anemone/internal/logger"
+	"github.com/juste-un-gars/anemone/internal/storage"
+)
+
+// checkInterval is how often the pools are checked
+const checkInterval = 5 * time.Minute
+
+// checkMu serializes checks, which can also be requested from the web interface
+var checkMu sync.Mutex
+
+// arrayScans holds the scans of the md arrays seen running at the previous
+// check. The kernel keeps no history of them, so a scan is recorded when the
+// monitor sees it stop.
+var arrayScans = map[string]*storage.ScanInfo{}
+
+// Check watches the health of every pool, records their finished scans and
+// starts the scrubs that are due
+func Check(db *sql.DB) error {
+	checkMu.Lock()
+	defer checkMu.Unlock()
+
+	pools, err := storage.ListZFSPools()
+	if err != nil {
+		return fmt.Errorf("failed to list pools: %w", err)
+	}
+	schedules, err := GetSchedules(db)
+	if err != nil {
+		return err
+	}
+	byPool := map[string]Schedule{}
+	for _, s := range schedules {
+		byPool[s.Pool] = s
+	}
+
+	now := time.Now()
+	for _, pool := range pools {
+		if err := watchHealth(db, pool.Name, stateOf(pool)); err != nil {
+			logger.Warn("Pool monitor: Failed to check pool health", "pool", pool.Name, "error", err)
+		}
+		scan, err := watchScan(db, pool.Name)
+		if err != nil {
+			logger.Warn("Pool monitor: Failed to check pool scan", "pool", pool.Name, "error", err)
+			continue
+		}
+		if schedule, ok := byPool[pool.Name]; ok {
+			if err := runSchedule(db, schedule, scan, now, storage.StartScrub); err != nil {
+				logger.Warn("Pool monitor: Failed to start scheduled scrub", "pool", pool.Name, "error", err)
+			}
+		}
+	}
+
+	arrays, err := storage.ListMDArrays()
+	if err != nil {
+		return fmt.Errorf("failed to list md arrays: %w", err)
+	}
+	for _, array := range arrays {
+		if err := watchHealth(db, array.Name, arrayStateOf(array)); err != nil {
+			logger.Warn("Pool monitor: Failed to check array health", "array", array.Name, "error", err)
+		}
+		scan := watchArrayScan(db, array, now)
+		if schedule, ok := byPool[array.Name]; ok {
+			if err := runSchedule(db, schedule, scan, now, storage.StartMDCheck); err != nil {
+				logger.Warn("Pool monitor: Failed to start scheduled check", "array", array.Name, "error", err)
+			}
+		}
+	}
+	return nil
+}
+
+// watchHealth raises the alerts of the changes of a pool since the last check
+func watchHealth(db *sql.DB, pool string, cur PoolState) error {
+	prev, err := loadState(db, pool)
+	if err != nil {
+		return err
+	}
+	for _, alert := range compareStates(prev, cur) {
+		raiseAlert(db, pool, alert)
+	}
+	return saveState(db, pool, cur)
+}
+
+// watchScan records the last scan of a pool once it is over, and returns it
+func watchScan(db *sql.DB, pool string) (*storage.ScanInfo, error) {
+	scan, err := storage.GetPoolScan(pool)
+	if err != nil {
+		return nil, err
+	}
+	added, err := recordScan(db, pool, scan)
+	if err != nil {
+		return nil, err
+	}
+	if added {
+		logger.Info("Pool monitor: Scan finished", "pool", pool, "function", scan.Function, "state", scan.State,
+			"repaired", scan.Repaired, "errors", scan.Errors)
+		if scan.Errors > 0 {
+			raiseAlert(db, pool, Alert{Severity: SeverityCritical, Kind: KindScrubErrors, Attribute: scan.Function,
+				NewValue: fmt.Sprintf("%d", scan.Errors)})
+		}
+	}
+	return scan, nil
+}
+
+// watchArrayScan records the last scan of an md array once it stopped, and
+// returns the running one
+func watchArrayScan(db *sql.DB, array storage.MDArray, now time.Time) *storage.ScanInfo {
+	scan := array.Scan()
+	prev, seen := arrayScans[array.Name]
+
+	if scan.State == storage.ScanRunning || scan.State == storage.ScanPaused {
+		scan.Start = now
+		if seen && prev.Function == scan.Function {
+			scan.Start = prev.Start
+		}
+		arrayScans[array.Name] = scan
+		return scan
+	}
+	if !seen {
+		return scan
+	}
+	delete(arrayScans, array.Name)
+
+	finished := &storage.ScanInfo{Function: prev.Function, State: storage.ScanFinished, Start: prev.Start, End: now,
+		DurationSeconds: int64(now.Sub(prev.Start).Seconds())}
+	if prev.Function == "check" {
+		finished.Errors = array.MismatchCount
+	}
+	if _, err := recordScan(db, array.Name, finished); err != nil {
+		logger.Warn("Pool monitor: Failed to record array scan", "array", array.Name, "error", err)
+	}
+	logger.Info("Pool monitor: Array scan finished", "array", array.Name, "function", finished.Function,
+		"mismatches", finished.Errors)
+	// Mismatches are not repaired by a check, and can be harmless on RAID1/10
+	if finished.Errors > 0 {
+		raiseAlert(db, array.Name, Alert{Severity: SeverityWarning, Kind: KindScrubErrors, Attribute: finished.Function,
+			NewValue: fmt.Sprintf("%d", finished.Errors)})
+	}
+	return scan
+}
+
+// runSchedule starts the scrub of a pool, or the check of an array, when its
+// schedule makes it due
+func runSchedule(db *sql.DB, schedule Schedule, scan *storage.ScanInfo, now time.Time, start func(string) error) error {
+	// A running resilver or a paused scrub is never interrupted
+	if scan.State == storage.ScanRunning || scan.State == storage.ScanPaused {
+		return nil
+	}
+	last, err := lastScrub(db, schedule)
+	if err != nil {
+		return err
+	}
+	if !scrubDue(schedule, last, now) {
+		return nil
+	}
+	if err := start(schedule.Pool); err != nil {
+		return err
+	}
+	if _, err := db.Exec("UPDATE scrub_schedules SET last_started = ? WHERE pool = ?", now.UTC(), schedule.Pool); err != nil {
+		return fmt.Errorf("failed to record scrub start: %w", err)
+	}
+	logger.Info("Pool monitor: Started scheduled scrub", "pool", schedule.Pool)
+	return nil
+}
+
+// StartScheduler checks the pools and arrays in the background
+func StartScheduler(db *sql.DB) {
+	if !storage.IsZFSAvailable() && !storage.IsMDAdmAvailable() {
+		logger.Info("Pool monitor not started: neither ZFS nor mdadm is installed")
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(checkInterval)
+		defer ticker.Stop()
+
+		for {
+			<-ticker.C
+			if err := Check(db); err != nil {
+				logger.Warn("Pool monitor: Check failed", "error", err)
+			}
+		}
+	}()
+
+	logger.Info("✅ Pool monitor started (checks every 5 minutes)")
+}
@@ -0,0 +1,139 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+package poolmon
+
+import (
+	"testing"
+	"time"
+
+	"github.com/juste-un-gars/anemone/internal/storage"
+)
+
+func TestCompareStates(t *testing.T) {
+	healthy := PoolState{State: "ONLINE", Devices: map[string]DeviceState{
+		"sda": {State: "ONLINE"},
+		"sdb": {State: "ONLINE", Cksum: 2},
+	}}
+	if alerts := compareStates(healthy, healthy); len(alerts) != 0 {
+		t.Errorf("unchanged pool raised %+v", alerts)
+	}
+
+	degraded := PoolState{State: "DEGRADED", Devices: map[string]DeviceState{
+		"sda": {State: "ONLINE"},
+		"sdb": {State: "FAULTED", Cksum: 5},
+	}}
+	alerts := compareStates(healthy, degraded)
+	if len(alerts) != 3 {
+		t.Fatalf("compareStates = %+v, want 3 alerts", alerts)
+	}
+	if alerts[0].Kind != KindPoolState || alerts[0].Severity != SeverityWarning || alerts[0].NewValue != "DEGRADED" {
+		t.Errorf("pool alert = %+v", alerts[0])
+	}
+	if alerts[1].Kind != KindDeviceState || alerts[1].Device != "sdb" || alerts[1].Severity != SeverityCritical {
+		t.Errorf("device alert = %+v", alerts[1])
+	}
+	if alerts[2].Kind != KindErrors || alerts[2].Attribute != "cksum" || alerts[2].OldValue != "2" || alerts[2].NewValue != "5" {
+		t.Errorf("errors alert = %+v", alerts[2])
+	}
+
+	// Recovery is reported, cleared counters are not
+	recovered := PoolState{State: "ONLINE", Devices: map[string]DeviceState{
+		"sda": {State: "ONLINE"},
+		"sdc": {State: "ONLINE"},
+	}}
+	alerts = compareStates(degraded, recovered)
+	if len(alerts) != 1 || alerts[0].Severity != SeverityInfo {
+		t.Errorf("recovery = %+v, want one info alert", alerts)
+	}
+}
+
+func TestCompareStatesFirstCheck(t *testing.T) {
+	empty := PoolState{Devices: map[string]DeviceState{}}
+	healthy := PoolState{State: "ONLINE", Devices: map[string]DeviceState{"sda": {State: "ONLINE"}}}
+	if alerts := compareStates(empty, healthy); len(alerts) != 0 {
+		t.Errorf("first check of a healthy pool raised %+v", alerts)
+	}
+
+	degraded := PoolState{State: "DEGRADED", Devices: map[string]DeviceState{"sda": {State: "UNAVAIL"}}}
+	if alerts := compareStates(empty, degraded); len(alerts) != 2 {
+		t.Errorf("first check of a degraded pool = %+v, want 2 alerts", alerts)
+	}
+}
+
+func TestArrayStateOf(t *testing.T) {
+	healthy := arrayStateOf(storage.MDArray{State: "active", Members: []storage.MDMember{
+		{Name: "sda", State: storage.MDMemberActive},
+		{Name: "sdb", State: storage.MDMemberActive},
+		{Name: "sdc", State: storage.MDMemberSpare},
+	}})
+	if alerts := compareStates(PoolState{Devices: map[string]DeviceState{}}, healthy); len(alerts) != 0 {
+		t.Errorf("healthy array with a spare raised %+v", alerts)
+	}
+
+	degraded := arrayStateOf(storage.MDArray{State: "degraded", Members: []storage.MDMember{
+		{Name: "sda", State: storage.MDMemberActive},
+		{Name: "sdb", State: storage.MDMemberFaulty},
+		{Name: "sdc", State: storage.MDMemberActive},
+	}})
+	alerts := compareStates(healthy, degraded)
+	if len(alerts) != 3 {
+		t.Fatalf("compareStates = %+v, want 3 alerts", alerts)
+	}
+	if alerts[0].Kind != KindPoolState || alerts[0].NewValue != "DEGRADED" {
+		t.Errorf("array alert = %+v", alerts[0])
+	}
+	if alerts[1].Device != "sdb" || alerts[1].NewValue != "FAULTED" || alerts[1].Severity != SeverityCritical {
+		t.Errorf("faulty member alert = %+v", alerts[1])
+	}
+	if alerts[2].Device != "sdc" || alerts[2].Severity != SeverityInfo {
+		t.Errorf("spare in use alert = %+v", alerts[2])
+	}
+}
+
+func TestScrubDue(t *testing.T) {
+	schedule := Schedule{Pool: "tank", IntervalDays: 7, Hour: 2, Enabled: true}
+	at := func(day, hour, min int) time.Time {
+		return time.Date(2026, 3, day, hour, min, 0, 0, time.Local)
+	}
+	ptr := func(t time.Time) *time.Time { return &t }
+
+	tests := []struct {
+		name string
+		last *time.Time
+		now  time.Time
+		want bool
+	}{
+		{"never scrubbed", nil, at(10, 2, 0), true},
+		{"outside the hour", nil, at(10, 3, 0), false},
+		{"a week later despite drift", ptr(at(3, 2, 40)), at(10, 2, 5), true},
+		{"scrubbed recently", ptr(at(8, 14, 0)), at(10, 2, 0), false},
+	}
+	for _, tt := range tests {
+		if got := scrubDue(schedule, tt.last, tt.now); got != tt.want {
+			t.Errorf("%s: scrubDue = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	schedule.Enabled = false
+	if scrubDue(schedule, nil, at(10, 2, 0)) {
+		t.Error("disabled schedule is due")
+	}
+}
+
+func TestScheduleValidate(t *testing.T) {
+	valid := Schedule{Pool: "tank", IntervalDays: 30, Hour: 2}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("valid schedule rejected: %v", err)
+	}
+	for _, s := range []Schedule{
+		{Pool: "", IntervalDays: 30, Hour: 2},
+		{Pool: "tank", IntervalDays: 0, Hour: 2},
+		{Pool: "tank", IntervalDays: 30, Hour: 24},
+	} {
+		if err := s.Validate(); err == nil {
+			t.Errorf("invalid schedule %+v accepted", s)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+// This file contains the scrub schedules of the pools and the history of their
+// scrubs and resilvers.
+
+package poolmon
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/juste-un-gars/anemone/internal/storage"
+)
+
+// Bounds of a scrub schedule
+const (
+	minScrubDays = 1
+	maxScrubDays = 365
+)
+
+// scheduleSlack absorbs the drift of the check times, so that a scrub due every
+// week starts every week at the configured hour
+const scheduleSlack = 2 * time.Hour
+
+// Schedule is the scrub schedule of a pool
+type Schedule struct {
+	Pool         string     `json:"pool"`
+	IntervalDays int        `json:"interval_days"` // Days between scrubs
+	Hour         int        `json:"hour"`          // Local hour scrubs start at
+	Enabled      bool       `json:"enabled"`
+	LastStarted  *time.Time `json:"last_started"` // Last scrub started by the schedule
+}
+
+// Validate checks a schedule
+func (s *Schedule) Validate() error {
+	if err := storage.ValidatePoolName(s.Pool); err != nil {
+		return err
+	}
+	if s.IntervalDays < minScrubDays || s.IntervalDays > maxScrubDays {
+		return fmt.Errorf("interval must be between %d and %d days", minScrubDays, maxScrubDays)
+	}
+	if s.Hour < 0 || s.Hour > 23 {
+		return fmt.Errorf("hour must be between 0 and 23")
+	}
+	return nil
+}
+
+// GetSchedules returns the scrub schedules of all pools
+func GetSchedules(db *sql.DB) ([]Schedule, error) {
+	rows, err := db.Query("SELECT pool, interval_days, hour, enabled, last_started FROM scrub_schedules ORDER BY pool")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query scrub schedules: %w", err)
+	}
+	defer rows.Close()
+
+	schedules := []Schedule{}
+	for rows.Next() {
+		var s Schedule
+		var last sql.NullTime
+		if err := rows.Scan(&s.Pool, &s.IntervalDays, &s.Hour, &s.Enabled, &last); err != nil {
+			return nil, fmt.Errorf("failed to scan scrub schedule: %w", err)
+		}
+		if last.Valid {
+			s.LastStarted = &last.Time
+		}
+		schedules = append(schedules, s)
+	}
+	return schedules, rows.Err()
+}
+
+// SetSchedule creates or updates the scrub schedule of a pool
+func SetSchedule(db *sql.DB, s Schedule) error {
+	if err := s.Validate(); err != nil {
+		return err
+	}
+	_, err := db.Exec(`INSERT INTO scrub_schedules (pool, interval_days, hour, enabled, updated_at)
+		VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(pool) DO UPDATE SET interval_days = excluded.interval_days, hour = excluded.hour,
+			enabled = excluded.enabled, updated_at = excluded.updated_at`,
+		s.Pool, s.IntervalDays, s.Hour, s.Enabled)
+	if err != nil {
+		return fmt.Errorf("failed to save scrub schedule: %w", err)
+	}
+	return nil
+}
+
+// DeleteSchedule removes the scrub schedule of a pool
+func DeleteSchedule(db *sql.DB, pool string) error {
+	if _, err := db.Exec("DELETE FROM scrub_schedules WHERE pool = ?", pool); err != nil {
+		return fmt.Errorf("failed to delete scrub schedule: %w", err)
+	}
+	return nil
+}
+
+// scrubDue reports whether the scrub of a schedule is due at now, last being
+// when the pool was last scrubbed
+func scrubDue(s Schedule, last *time.Time, now time.Time) bool {
+	if !s.Enabled || now.Hour() != s.Hour {
+		return false
+	}
+	return last == nil || now.Sub(*last) >= time.Duration(s.IntervalDays)*24*time.Hour-scheduleSlack
+}
+
+// Scan is a finished or canceled scrub or resilver of a pool
+type Scan struct {
+	ID              int        `json:"id"`
+	Pool            string     `json:"pool"`
+	Function        string     `json:"function"`
+	State           string     `json:"state"`
+	StartedAt       *time.Time `json:"started_at"`
+	FinishedAt      time.Time  `json:"finished_at"`
+	DurationSeconds int64      `json:"duration_seconds"`
+	BytesRepaired   uint64     `json:"bytes_repaired"`
+	Errors          uint64     `json:"errors"`
+}
+
+// recordScan adds the last scan of a pool to its history, and reports whether
+// it was not recorded yet
+func recordScan(db *sql.DB, pool string, info *storage.ScanInfo) (bool, error) {
+	if info.State != storage.ScanFinished && info.State != storage.ScanCanceled {
+		return false, nil
+	}
+	var started interface{}
+	if info.DurationSeconds > 0 {
+		started = info.End.Add(-time.Duration(info.DurationSeconds) * time.Second).UTC()
+	}
+	res, err := db.Exec(`INSERT OR IGNORE INTO scrub_history
+		(pool, function, state, started_at, finished_at, duration_seconds, bytes_repaired, errors)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		pool, info.Function, info.State, started, info.End.UTC(), info.DurationSeconds, info.Repaired, info.Errors)
+	if err != nil {
+		return false, fmt.Errorf("failed to record scan: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// GetHistory returns the latest scans of a pool, newest first
+func GetHistory(db *sql.DB, pool string, limit int) ([]Scan, error) {
+	rows, err := db.Query(`SELECT id, pool, function, state, started_at, finished_at, duration_seconds, bytes_repaired, errors
+		FROM scrub_history WHERE pool = ? ORDER BY finished_at DESC LIMIT ?`, pool, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query scrub history: %w", err)
+	}
+	defer rows.Close()
+
+	scans := []Scan{}
+	for rows.Next() {
+		var s Scan
+		var started sql.NullTime
+		if err := rows.Scan(&s.ID, &s.Pool, &s.Function, &s.State, &started, &s.FinishedAt,
+			&s.DurationSeconds, &s.BytesRepaired, &s.Errors); err != nil {
+			return nil, fmt.Errorf("failed to scan scrub history: %w", err)
+		}
+		if started.Valid {
+			s.StartedAt = &started.Time
+		}
+		scans = append(scans, s)
+	}
+	return scans, rows.Err()
+}
+
+// lastScrub returns when the last scrub of a pool, or check of an md array,
+// started, whether run by its schedule or by hand
+func lastScrub(db *sql.DB, s Schedule) (*time.Time, error) {
+	last := s.LastStarted
+	var started sql.NullTime
+	var finished time.Time
+	err := db.QueryRow(`SELECT started_at, finished_at FROM scrub_history
+		WHERE pool = ? AND function IN ('scrub', 'check') ORDER BY finished_at DESC LIMIT 1`, s.Pool).Scan(&started, &finished)
+	if err == sql.ErrNoRows {
+		return last, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get last scrub: %w", err)
+	}
+	at := finished
+	if started.Valid {
+		at = started.Time
+	}
+	if last == nil || at.After(*last) {
+		last = &at
+	}
+	return last, nil
+}
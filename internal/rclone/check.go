@@ -0,0 +1,137 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+// This file compares what a cloud destination holds with the local files, using
+// rclone check, or rclone cryptcheck for destinations wrapped in rclone crypt.
+
+package rclone
+
+import (
+	"bufio"
+	"bytes"
+	"database/sql"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Kinds of differences found by a check
+const (
+	DiffMissing = "missing" // Local file absent from the destination
+	DiffChanged = "differ"  // File present on both sides with different content
+	DiffExtra   = "extra"   // File on the destination without a local counterpart
+	DiffError   = "error"   // File or source that could not be compared
+)
+
+// Difference is a file that does not match between the local files and the destination
+type Difference struct {
+	Source string
+	Path   string
+	Kind   string
+	Error  string
+}
+
+// Check compares each source of a backup with its directory on the destination.
+// It returns the number of files compared and the differences found.
+func Check(db *sql.DB, backup *RcloneBackup, dataDir, incomingDir string) (int, []Difference, error) {
+	if !IsRcloneInstalled() {
+		return 0, nil, fmt.Errorf("rclone is not installed")
+	}
+	if IsBackupSyncing(backup.ID) {
+		return 0, nil, fmt.Errorf("a sync to this destination is running")
+	}
+
+	sources, err := Sources(db, backup, dataDir, incomingDir)
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(sources) == 0 {
+		return 0, nil, fmt.Errorf("no source selected")
+	}
+
+	// cryptcheck compares the checksums of the local files with the encrypted ones
+	command := "check"
+	if cfgGet(backup.ProviderConfig, "crypt_password", "") != "" {
+		command = "cryptcheck"
+	}
+
+	checked := 0
+	var diffs []Difference
+	for _, source := range sources {
+		if _, err := os.Stat(source.LocalDir); os.IsNotExist(err) {
+			continue
+		}
+		dest := buildDestination(backup, dataDir, filepath.Join(backup.RemotePath, source.RemoteDir))
+
+		cmd := exec.Command("rclone", command, source.LocalDir, dest,
+			"--combined", "-",
+			"--exclude", "/"+VersionsDir+"/**",
+			"--checkers", "8",
+		)
+		var stdout, stderr bytes.Buffer
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+
+		// rclone exits with an error when differences are found: only a run
+		// without any compared file is a failure of the check itself
+		runErr := cmd.Run()
+		n, sourceDiffs := parseCombined(source.Name, stdout.Bytes())
+		if runErr != nil && n == 0 {
+			msg := lastLine(stderr.String())
+			if msg == "" {
+				msg = runErr.Error()
+			}
+			diffs = append(diffs, Difference{Source: source.Name, Kind: DiffError, Error: msg})
+			continue
+		}
+		checked += n
+		diffs = append(diffs, sourceDiffs...)
+	}
+	return checked, diffs, nil
+}
+
+// parseCombined reads the --combined report of rclone check: one line per file,
+// prefixed with "=" (identical), "-" (missing on the destination), "+" (missing
+// locally), "*" (different) or "!" (error)
+func parseCombined(source string, output []byte) (int, []Difference) {
+	checked := 0
+	var diffs []Difference
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if len(line) < 3 || line[1] != ' ' {
+			continue
+		}
+		path := line[2:]
+		checked++
+
+		kind := ""
+		switch line[0] {
+		case '=':
+			continue
+		case '-':
+			kind = DiffMissing
+		case '+':
+			kind = DiffExtra
+		case '*':
+			kind = DiffChanged
+		case '!':
+			kind = DiffError
+		default:
+			checked--
+			continue
+		}
+		diffs = append(diffs, Difference{Source: source, Path: path, Kind: kind})
+	}
+	return checked, diffs
+}
+
+// lastLine returns the last non-empty line of rclone's log output
+func lastLine(s string) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
@@ -0,0 +1,34 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+package rclone
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCombined(t *testing.T) {
+	output := []byte("= docs/same.txt\n" +
+		"- docs/not uploaded.txt\n" +
+		"+ old/removed.txt\n" +
+		"* photos/edited.jpg\n" +
+		"! broken.bin\n" +
+		"\n" +
+		"2026/03/14 02:00:00 NOTICE: not a report line\n")
+
+	checked, diffs := parseCombined("backup/alice", output)
+	if checked != 5 {
+		t.Errorf("checked = %d, expected 5", checked)
+	}
+	want := []Difference{
+		{Source: "backup/alice", Path: "docs/not uploaded.txt", Kind: DiffMissing},
+		{Source: "backup/alice", Path: "old/removed.txt", Kind: DiffExtra},
+		{Source: "backup/alice", Path: "photos/edited.jpg", Kind: DiffChanged},
+		{Source: "backup/alice", Path: "broken.bin", Kind: DiffError},
+	}
+	if !reflect.DeepEqual(diffs, want) {
+		t.Errorf("differences = %+v, expected %+v", diffs, want)
+	}
+}
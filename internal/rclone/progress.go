@@ -0,0 +1,196 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+// This file tracks the live progress of running cloud backups, lets the admin
+// cancel them, and records the files that failed during the last run.
+
+package rclone
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	gosync "sync"
+	"time"
+)
+
+// ErrNotRunning is returned by CancelSync when no sync to the destination is running
+var ErrNotRunning = errors.New("no sync is running for this destination")
+
+// Progress is the live state of a running cloud backup
+type Progress struct {
+	BackupID       int       `json:"backup_id"`
+	Source         string    `json:"source"`       // Source being synced
+	SourceIndex    int       `json:"source_index"` // 1-based
+	SourceCount    int       `json:"source_count"`
+	Bytes          int64     `json:"bytes"`
+	TotalBytes     int64     `json:"total_bytes"`
+	Transfers      int64     `json:"transfers"`
+	TotalTransfers int64     `json:"total_transfers"`
+	Errors         int64     `json:"errors"`
+	Speed          float64   `json:"speed"` // Bytes per second
+	ETA            int64     `json:"eta"`   // Seconds, -1 when unknown
+	Cancelling     bool      `json:"cancelling"`
+	StartedAt      time.Time `json:"started_at"`
+}
+
+// FileError is a file that failed to sync during the last run of a backup
+type FileError struct {
+	ID        int
+	BackupID  int
+	Source    string
+	Path      string
+	Error     string
+	CreatedAt time.Time
+}
+
+// runState is a running sync: its progress and the channel cancelling it
+type runState struct {
+	progress Progress
+	cancel   chan struct{}
+}
+
+// Running syncs by backup ID, guarded by runsMu
+var (
+	runs   = make(map[int]*runState)
+	runsMu gosync.Mutex
+)
+
+// beginRun registers a running sync and returns the channel closed on cancellation
+func beginRun(backupID, sourceCount int) <-chan struct{} {
+	runsMu.Lock()
+	defer runsMu.Unlock()
+	state := &runState{
+		progress: Progress{BackupID: backupID, SourceCount: sourceCount, ETA: -1, StartedAt: time.Now()},
+		cancel:   make(chan struct{}),
+	}
+	runs[backupID] = state
+	return state.cancel
+}
+
+// endRun forgets a finished sync
+func endRun(backupID int) {
+	runsMu.Lock()
+	defer runsMu.Unlock()
+	delete(runs, backupID)
+}
+
+// setRunSource records the source a sync has moved on to
+func setRunSource(backupID, index int, source string) {
+	runsMu.Lock()
+	defer runsMu.Unlock()
+	if state, ok := runs[backupID]; ok {
+		p := &state.progress
+		p.Source, p.SourceIndex = source, index
+		p.Bytes, p.TotalBytes, p.Transfers, p.TotalTransfers, p.Speed, p.ETA = 0, 0, 0, 0, 0, -1
+	}
+}
+
+// setRunStats records the latest stats of the source being synced.
+// Errors accumulate over the sources of the run.
+func setRunStats(backupID int, stats rcStats, previousErrors int64) {
+	runsMu.Lock()
+	defer runsMu.Unlock()
+	state, ok := runs[backupID]
+	if !ok {
+		return
+	}
+	p := &state.progress
+	p.Bytes = stats.Bytes
+	p.TotalBytes = stats.TotalBytes
+	p.Transfers = stats.Transfers
+	p.TotalTransfers = stats.TotalTransfers
+	p.Errors = previousErrors + stats.Errors
+	p.Speed = stats.Speed
+	p.ETA = -1
+	if stats.ETA != nil {
+		p.ETA = int64(*stats.ETA)
+	}
+}
+
+// GetProgress returns the live progress of a running sync
+func GetProgress(backupID int) (*Progress, bool) {
+	runsMu.Lock()
+	defer runsMu.Unlock()
+	state, ok := runs[backupID]
+	if !ok {
+		return nil, false
+	}
+	snapshot := state.progress
+	return &snapshot, true
+}
+
+// CancelSync stops the running sync of a backup. Files already transferred stay on the destination.
+func CancelSync(backupID int) error {
+	runsMu.Lock()
+	defer runsMu.Unlock()
+	state, ok := runs[backupID]
+	if !ok {
+		return ErrNotRunning
+	}
+	if !state.progress.Cancelling {
+		state.progress.Cancelling = true
+		close(state.cancel)
+	}
+	return nil
+}
+
+// saveFileErrors replaces the recorded file errors of a backup with those of its last run
+func saveFileErrors(db *sql.DB, backupID int, errs []FileError) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM rclone_file_errors WHERE backup_id = ?", backupID); err != nil {
+		return fmt.Errorf("failed to clear file errors: %w", err)
+	}
+	for _, fe := range errs {
+		if _, err := tx.Exec("INSERT INTO rclone_file_errors (backup_id, source, path, error) VALUES (?, ?, ?, ?)",
+			backupID, fe.Source, fe.Path, fe.Error); err != nil {
+			return fmt.Errorf("failed to record file error: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// GetFileErrors returns the files that failed during the last run of a backup
+func GetFileErrors(db *sql.DB, backupID int) ([]FileError, error) {
+	rows, err := db.Query(`SELECT id, backup_id, source, path, error, created_at
+		FROM rclone_file_errors WHERE backup_id = ? ORDER BY source, path`, backupID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query file errors: %w", err)
+	}
+	defer rows.Close()
+
+	var errs []FileError
+	for rows.Next() {
+		var fe FileError
+		if err := rows.Scan(&fe.ID, &fe.BackupID, &fe.Source, &fe.Path, &fe.Error, &fe.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan file error: %w", err)
+		}
+		errs = append(errs, fe)
+	}
+	return errs, rows.Err()
+}
+
+// CountFileErrors returns the number of files that failed during the last run of each backup
+func CountFileErrors(db *sql.DB) (map[int]int, error) {
+	rows, err := db.Query("SELECT backup_id, COUNT(*) FROM rclone_file_errors GROUP BY backup_id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to count file errors: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[int]int)
+	for rows.Next() {
+		var id, n int
+		if err := rows.Scan(&id, &n); err != nil {
+			return nil, fmt.Errorf("failed to scan file error count: %w", err)
+		}
+		counts[id] = n
+	}
+	return counts, rows.Err()
+}
@@ -0,0 +1,304 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+// This file drives rclone through its remote control API. Each backup run starts a
+// private "rclone rcd" on the loopback interface, submits sync/sync jobs to it and
+// polls core/stats for live progress, instead of parsing the text output of
+// "rclone sync" once it has exited.
+
+package rclone
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	gosync "sync"
+	"time"
+)
+
+// rcPollInterval is how often a running job is polled for its status and stats
+const rcPollInterval = time.Second
+
+// maxFileErrors bounds the per-file errors kept for one backup run
+const maxFileErrors = 1000
+
+// ErrCancelled is returned when a sync is cancelled by the admin
+var ErrCancelled = errors.New("sync cancelled")
+
+// syncOptions are the options of a sync/sync job beyond source and destination
+type syncOptions struct {
+	BackupDir string   // Archive directory for replaced and deleted files (versioning)
+	Exclude   []string // Filter rules excluding paths from the sync
+}
+
+// rcStats is the part of the core/stats response Anemone uses
+type rcStats struct {
+	Bytes          int64    `json:"bytes"`
+	TotalBytes     int64    `json:"totalBytes"`
+	Transfers      int64    `json:"transfers"`
+	TotalTransfers int64    `json:"totalTransfers"`
+	Checks         int64    `json:"checks"`
+	Errors         int64    `json:"errors"`
+	Speed          float64  `json:"speed"`
+	ETA            *float64 `json:"eta"` // Seconds, null when unknown
+	LastError      string   `json:"lastError"`
+}
+
+// rcDaemon is an rclone remote control server serving one backup run
+type rcDaemon struct {
+	cmd    *exec.Cmd
+	url    string
+	user   string
+	pass   string
+	client *http.Client
+	done   chan struct{} // Closed once the log reader has finished
+
+	mu         gosync.Mutex
+	source     string      // Source being synced, attached to the errors logged
+	fileErrors []FileError // Per-file errors logged by rclone
+}
+
+// startRC starts an rclone remote control server on a free loopback port, with
+// random credentials so that other local users cannot drive it. The credentials
+// are passed in the environment, which unlike the command line is not readable
+// by other users.
+func startRC(backupID int) (*rcDaemon, error) {
+	port, err := freePort()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find a free port for rclone: %w", err)
+	}
+	user, pass := randomToken(), randomToken()
+
+	d := &rcDaemon{
+		url:    "http://127.0.0.1:" + strconv.Itoa(port) + "/",
+		user:   user,
+		pass:   pass,
+		client: &http.Client{Timeout: 30 * time.Second},
+		done:   make(chan struct{}),
+	}
+	d.cmd = exec.Command("rclone", "rcd",
+		"--rc-addr", "127.0.0.1:"+strconv.Itoa(port),
+		"--use-json-log",
+		"--log-level", "NOTICE",
+	)
+	d.cmd.Env = append(os.Environ(), "RCLONE_RC_USER="+user, "RCLONE_RC_PASS="+pass)
+	stderr, err := d.cmd.StderrPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to capture rclone output: %w", err)
+	}
+	if err := d.cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start rclone: %w", err)
+	}
+	activeProcesses.Store(backupID, d.cmd)
+	go d.readLog(stderr)
+
+	// Wait for the server to accept requests
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		if err := d.call("rc/noop", nil, nil); err == nil {
+			return d, nil
+		}
+		if time.Now().After(deadline) {
+			d.stop()
+			return nil, fmt.Errorf("rclone remote control did not start")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// freePort returns a TCP port currently free on the loopback interface
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
+
+// randomToken returns a random hex string used as an rc credential
+func randomToken() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// readLog collects the per-file errors from the JSON log of the daemon
+func (d *rcDaemon) readLog(r io.Reader) {
+	defer close(d.done)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if fe, ok := parseLogError(scanner.Bytes()); ok {
+			d.mu.Lock()
+			if len(d.fileErrors) < maxFileErrors {
+				fe.Source = d.source
+				d.fileErrors = append(d.fileErrors, fe)
+			}
+			d.mu.Unlock()
+		}
+	}
+}
+
+// parseLogError returns the file error reported by a JSON log line of rclone, if any
+func parseLogError(line []byte) (FileError, bool) {
+	var entry struct {
+		Level  string `json:"level"`
+		Msg    string `json:"msg"`
+		Object string `json:"object"`
+	}
+	if err := json.Unmarshal(line, &entry); err != nil {
+		return FileError{}, false
+	}
+	if entry.Level != "error" || entry.Object == "" {
+		return FileError{}, false
+	}
+	return FileError{Path: entry.Object, Error: entry.Msg}, true
+}
+
+// call invokes an rc method and decodes its JSON response into out
+func (d *rcDaemon) call(method string, params map[string]interface{}, out interface{}) error {
+	if params == nil {
+		params = map[string]interface{}{}
+	}
+	body, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, d.url+method, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.SetBasicAuth(d.user, d.pass)
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var rcErr struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(data, &rcErr) == nil && rcErr.Error != "" {
+			return fmt.Errorf("%s", rcErr.Error)
+		}
+		return fmt.Errorf("%s: HTTP %d", method, resp.StatusCode)
+	}
+	if out != nil {
+		return json.Unmarshal(data, out)
+	}
+	return nil
+}
+
+// sync runs a sync/sync job and reports its stats every poll until it finishes.
+// Closing cancel stops the job.
+func (d *rcDaemon) sync(source, src, dst string, opts syncOptions, cancel <-chan struct{}, onStats func(rcStats)) (rcStats, error) {
+	d.mu.Lock()
+	d.source = source
+	d.mu.Unlock()
+
+	params := map[string]interface{}{
+		"srcFs":  src,
+		"dstFs":  dst,
+		"_async": true,
+		"_config": map[string]interface{}{
+			"Transfers": 4,
+			"Checkers":  8,
+			"BackupDir": opts.BackupDir,
+		},
+	}
+	if len(opts.Exclude) > 0 {
+		params["_filter"] = map[string]interface{}{"ExcludeRule": opts.Exclude}
+	}
+
+	var started struct {
+		JobID int64 `json:"jobid"`
+	}
+	if err := d.call("sync/sync", params, &started); err != nil {
+		return rcStats{}, fmt.Errorf("failed to start sync: %w", err)
+	}
+
+	group := "job/" + strconv.FormatInt(started.JobID, 10)
+	ticker := time.NewTicker(rcPollInterval)
+	defer ticker.Stop()
+
+	var stats rcStats
+	cancelled := false
+	for {
+		select {
+		case <-cancel:
+			if !cancelled {
+				cancelled = true
+				d.call("job/stop", map[string]interface{}{"jobid": started.JobID}, nil)
+			}
+		case <-ticker.C:
+		}
+
+		var status struct {
+			Finished bool   `json:"finished"`
+			Success  bool   `json:"success"`
+			Error    string `json:"error"`
+		}
+		if err := d.call("job/status", map[string]interface{}{"jobid": started.JobID}, &status); err != nil {
+			return stats, fmt.Errorf("failed to get sync status: %w", err)
+		}
+		if err := d.call("core/stats", map[string]interface{}{"group": group}, &stats); err == nil && onStats != nil {
+			onStats(stats)
+		}
+
+		if status.Finished {
+			switch {
+			case cancelled:
+				return stats, ErrCancelled
+			case !status.Success:
+				return stats, fmt.Errorf("rclone error: %s", status.Error)
+			}
+			return stats, nil
+		}
+	}
+}
+
+// takeFileErrors returns the per-file errors logged so far and clears them
+func (d *rcDaemon) takeFileErrors() []FileError {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	errs := d.fileErrors
+	d.fileErrors = nil
+	return errs
+}
+
+// stop shuts the daemon down, killing it if it does not quit in time
+func (d *rcDaemon) stop() {
+	d.call("core/quit", nil, nil)
+	exited := make(chan struct{})
+	go func() {
+		<-d.done
+		d.cmd.Wait()
+		close(exited)
+	}()
+	select {
+	case <-exited:
+	case <-time.After(10 * time.Second):
+		d.cmd.Process.Kill()
+		<-exited
+	}
+}
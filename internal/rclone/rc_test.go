@@ -0,0 +1,78 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+package rclone
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseLogError(t *testing.T) {
+	line := `{"level":"error","msg":"Failed to copy: permission denied","object":"docs/report.odt","objectType":"*local.Object"}`
+	fe, ok := parseLogError([]byte(line))
+	if !ok || fe.Path != "docs/report.odt" || fe.Error != "Failed to copy: permission denied" {
+		t.Errorf("parseLogError = %+v, %v", fe, ok)
+	}
+
+	for _, line := range []string{
+		`{"level":"notice","msg":"Transferred: 5","object":"docs/a.txt"}`,
+		`{"level":"error","msg":"Attempt 1/3 failed with 1 errors"}`,
+		`not json`,
+	} {
+		if _, ok := parseLogError([]byte(line)); ok {
+			t.Errorf("parseLogError(%s) reported a file error", line)
+		}
+	}
+}
+
+func TestRCSync(t *testing.T) {
+	var syncParams map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "u" || pass != "p" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var params map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&params)
+		switch r.URL.Path {
+		case "/sync/sync":
+			syncParams = params
+			json.NewEncoder(w).Encode(map[string]interface{}{"jobid": 7})
+		case "/job/status":
+			json.NewEncoder(w).Encode(map[string]interface{}{"finished": true, "success": false, "error": "1 file failed"})
+		case "/core/stats":
+			if params["group"] != "job/7" {
+				t.Errorf("core/stats group = %v", params["group"])
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"bytes": 2048, "transfers": 3, "errors": 1, "eta": nil})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	d := &rcDaemon{url: server.URL + "/", user: "u", pass: "p", client: server.Client()}
+	var seen []rcStats
+	stats, err := d.sync("backup/alice", "/src", "remote:dst", syncOptions{Exclude: []string{"/.anemone-versions/**"}}, nil, func(s rcStats) {
+		seen = append(seen, s)
+	})
+	if err == nil || err.Error() != "rclone error: 1 file failed" {
+		t.Errorf("sync error = %v", err)
+	}
+	if stats.Bytes != 2048 || stats.Transfers != 3 || stats.Errors != 1 || stats.ETA != nil {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+	if len(seen) != 1 {
+		t.Errorf("onStats called %d times, expected 1", len(seen))
+	}
+	if syncParams["srcFs"] != "/src" || syncParams["dstFs"] != "remote:dst" || syncParams["_async"] != true {
+		t.Errorf("unexpected sync/sync params %v", syncParams)
+	}
+	if _, ok := syncParams["_filter"]; !ok {
+		t.Error("exclude rules were not sent")
+	}
+}
@@ -41,6 +41,20 @@ type RcloneBackup struct {
 	// Options
 	Enabled bool
 
+	// Sources: shares are selected by kind and/or one by one
+	IncludeBackup       bool   // Backup shares of all users
+	IncludeData         bool   // Data shares of all users
+	SelectedShares      string // JSON array of share IDs sent in addition
+	IncludeServerConfig bool   // Encrypted server configuration backups
+	IncludeIncoming     bool   // Backups received from peers
+
+	// Versioning: replaced and deleted files are moved to dated archive directories
+	Versioning           bool
+	VersionRetentionDays int // Archives older than this are pruned (0 = keep forever)
+
+	// Integrity check: the destination is compared with the local files every N days (0 = never)
+	CheckIntervalDays int
+
 	// Scheduling fields
 	SyncEnabled         bool   // Enable automatic sync
 	SyncFrequency       string // "daily", "weekly", "monthly", "interval"
@@ -107,6 +121,34 @@ func unmarshalProviderConfig(data string) map[string]string {
 	return config
 }
 
+// GetSelectedShareIDs returns the shares selected one by one
+func (b *RcloneBackup) GetSelectedShareIDs() []int {
+	if b.SelectedShares == "" {
+		return []int{}
+	}
+
+	var ids []int
+	if err := json.Unmarshal([]byte(b.SelectedShares), &ids); err != nil {
+		return []int{}
+	}
+	return ids
+}
+
+// SetSelectedShareIDs sets the shares selected one by one
+func (b *RcloneBackup) SetSelectedShareIDs(ids []int) {
+	if len(ids) == 0 {
+		b.SelectedShares = ""
+		return
+	}
+
+	data, err := json.Marshal(ids)
+	if err != nil {
+		b.SelectedShares = ""
+		return
+	}
+	b.SelectedShares = string(data)
+}
+
 // Create creates a new rclone backup configuration
 func Create(db *sql.DB, backup *RcloneBackup) error {
 	// Set defaults
@@ -129,8 +171,10 @@ func Create(db *sql.DB, backup *RcloneBackup) error {
 	query := `INSERT INTO rclone_backups (
 		name, sftp_host, sftp_port, sftp_user, sftp_key_path, sftp_password, remote_path,
 		enabled, sync_enabled, sync_frequency, sync_time, sync_day_of_week, sync_day_of_month,
-		sync_interval_minutes, provider_type, provider_config, last_status, created_at, updated_at
-	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 'unknown', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)`
+		sync_interval_minutes, provider_type, provider_config, include_backup, include_data,
+		selected_shares, include_server_config, include_incoming, versioning, version_retention_days,
+		check_interval_days, last_status, created_at, updated_at
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 'unknown', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)`
 
 	result, err := db.Exec(query,
 		backup.Name, backup.SFTPHost, backup.SFTPPort, backup.SFTPUser,
@@ -138,6 +182,9 @@ func Create(db *sql.DB, backup *RcloneBackup) error {
 		backup.Enabled, backup.SyncEnabled, backup.SyncFrequency, backup.SyncTime,
 		backup.SyncDayOfWeek, backup.SyncDayOfMonth, backup.SyncIntervalMinutes,
 		backup.ProviderType, marshalProviderConfig(backup.ProviderConfig),
+		backup.IncludeBackup, backup.IncludeData, backup.SelectedShares,
+		backup.IncludeServerConfig, backup.IncludeIncoming,
+		backup.Versioning, backup.VersionRetentionDays, backup.CheckIntervalDays,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to create rclone backup: %w", err)
@@ -158,13 +205,15 @@ func GetByID(db *sql.DB, id int) (*RcloneBackup, error) {
 	query := `SELECT id, name, sftp_host, sftp_port, sftp_user, sftp_key_path, sftp_password,
 		remote_path, enabled, sync_enabled, sync_frequency, sync_time, sync_day_of_week,
 		sync_day_of_month, sync_interval_minutes, last_sync, last_status, last_error,
-		files_synced, bytes_synced, created_at, updated_at, provider_type, provider_config
+		files_synced, bytes_synced, created_at, updated_at, provider_type, provider_config,
+		include_backup, include_data, selected_shares, include_server_config, include_incoming,
+		versioning, version_retention_days, check_interval_days
 		FROM rclone_backups WHERE id = ?`
 
 	var syncFrequency, syncTime, lastStatus, lastError sql.NullString
 	var syncDayOfWeek, syncDayOfMonth, syncIntervalMinutes sql.NullInt64
 	var sftpKeyPath, sftpPassword sql.NullString
-	var providerType, providerConfig sql.NullString
+	var providerType, providerConfig, selectedShares sql.NullString
 
 	err := db.QueryRow(query, id).Scan(
 		&backup.ID, &backup.Name, &backup.SFTPHost, &backup.SFTPPort, &backup.SFTPUser,
@@ -173,6 +222,9 @@ func GetByID(db *sql.DB, id int) (*RcloneBackup, error) {
 		&syncDayOfMonth, &syncIntervalMinutes, &backup.LastSync, &lastStatus,
 		&lastError, &backup.FilesSynced, &backup.BytesSynced,
 		&backup.CreatedAt, &backup.UpdatedAt, &providerType, &providerConfig,
+		&backup.IncludeBackup, &backup.IncludeData, &selectedShares,
+		&backup.IncludeServerConfig, &backup.IncludeIncoming,
+		&backup.Versioning, &backup.VersionRetentionDays, &backup.CheckIntervalDays,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -225,6 +277,7 @@ func GetByID(db *sql.DB, id int) (*RcloneBackup, error) {
 		backup.ProviderType = providerType.String
 	}
 	backup.ProviderConfig = unmarshalProviderConfig(providerConfig.String)
+	backup.SelectedShares = selectedShares.String
 
 	return backup, nil
 }
@@ -234,7 +287,9 @@ func GetAll(db *sql.DB) ([]*RcloneBackup, error) {
 	query := `SELECT id, name, sftp_host, sftp_port, sftp_user, sftp_key_path, sftp_password,
 		remote_path, enabled, sync_enabled, sync_frequency, sync_time, sync_day_of_week,
 		sync_day_of_month, sync_interval_minutes, last_sync, last_status, last_error,
-		files_synced, bytes_synced, created_at, updated_at, provider_type, provider_config
+		files_synced, bytes_synced, created_at, updated_at, provider_type, provider_config,
+		include_backup, include_data, selected_shares, include_server_config, include_incoming,
+		versioning, version_retention_days, check_interval_days
 		FROM rclone_backups ORDER BY created_at DESC`
 
 	return queryBackups(db, query)
@@ -245,7 +300,9 @@ func GetEnabled(db *sql.DB) ([]*RcloneBackup, error) {
 	query := `SELECT id, name, sftp_host, sftp_port, sftp_user, sftp_key_path, sftp_password,
 		remote_path, enabled, sync_enabled, sync_frequency, sync_time, sync_day_of_week,
 		sync_day_of_month, sync_interval_minutes, last_sync, last_status, last_error,
-		files_synced, bytes_synced, created_at, updated_at, provider_type, provider_config
+		files_synced, bytes_synced, created_at, updated_at, provider_type, provider_config,
+		include_backup, include_data, selected_shares, include_server_config, include_incoming,
+		versioning, version_retention_days, check_interval_days
 		FROM rclone_backups WHERE enabled = 1 ORDER BY created_at DESC`
 
 	return queryBackups(db, query)
@@ -265,7 +322,7 @@ func queryBackups(db *sql.DB, query string, args ...interface{}) ([]*RcloneBacku
 		var syncFrequency, syncTime, lastStatus, lastError sql.NullString
 		var syncDayOfWeek, syncDayOfMonth, syncIntervalMinutes sql.NullInt64
 		var sftpKeyPath, sftpPassword sql.NullString
-		var providerType, providerConfig sql.NullString
+		var providerType, providerConfig, selectedShares sql.NullString
 
 		err := rows.Scan(
 			&backup.ID, &backup.Name, &backup.SFTPHost, &backup.SFTPPort, &backup.SFTPUser,
@@ -274,6 +331,9 @@ func queryBackups(db *sql.DB, query string, args ...interface{}) ([]*RcloneBacku
 			&syncDayOfMonth, &syncIntervalMinutes, &backup.LastSync, &lastStatus,
 			&lastError, &backup.FilesSynced, &backup.BytesSynced,
 			&backup.CreatedAt, &backup.UpdatedAt, &providerType, &providerConfig,
+			&backup.IncludeBackup, &backup.IncludeData, &selectedShares,
+			&backup.IncludeServerConfig, &backup.IncludeIncoming,
+			&backup.Versioning, &backup.VersionRetentionDays, &backup.CheckIntervalDays,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan rclone backup: %w", err)
@@ -320,6 +380,7 @@ func queryBackups(db *sql.DB, query string, args ...interface{}) ([]*RcloneBacku
 			backup.ProviderType = providerType.String
 		}
 		backup.ProviderConfig = unmarshalProviderConfig(providerConfig.String)
+		backup.SelectedShares = selectedShares.String
 
 		backups = append(backups, backup)
 	}
@@ -334,6 +395,9 @@ func Update(db *sql.DB, backup *RcloneBackup) error {
 		sftp_password = ?, remote_path = ?, enabled = ?, sync_enabled = ?,
 		sync_frequency = ?, sync_time = ?, sync_day_of_week = ?, sync_day_of_month = ?,
 		sync_interval_minutes = ?, provider_type = ?, provider_config = ?,
+		include_backup = ?, include_data = ?, selected_shares = ?,
+		include_server_config = ?, include_incoming = ?,
+		versioning = ?, version_retention_days = ?, check_interval_days = ?,
 		updated_at = CURRENT_TIMESTAMP
 		WHERE id = ?`
 
@@ -343,6 +407,9 @@ func Update(db *sql.DB, backup *RcloneBackup) error {
 		backup.Enabled, backup.SyncEnabled, backup.SyncFrequency, backup.SyncTime,
 		backup.SyncDayOfWeek, backup.SyncDayOfMonth, backup.SyncIntervalMinutes,
 		backup.ProviderType, marshalProviderConfig(backup.ProviderConfig),
+		backup.IncludeBackup, backup.IncludeData, backup.SelectedShares,
+		backup.IncludeServerConfig, backup.IncludeIncoming,
+		backup.Versioning, backup.VersionRetentionDays, backup.CheckIntervalDays,
 		backup.ID,
 	)
 	if err != nil {
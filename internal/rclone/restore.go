@@ -0,0 +1,414 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+// This file pulls users' backup directories back from a cloud destination.
+
+package rclone
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	gosync "sync"
+	"time"
+
+	"github.com/juste-un-gars/anemone/internal/logger"
+)
+
+// CloudEntry is a file or directory stored on a cloud destination
+type CloudEntry struct {
+	Name    string    `json:"name"`
+	Path    string    `json:"path"` // Relative to the user's backup root
+	Size    int64     `json:"size"`
+	IsDir   bool      `json:"is_dir"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// RestoreRequest describes what to pull back from a cloud destination
+type RestoreRequest struct {
+	Username  string   // User directory on the remote
+	Version   string   // Archive to restore from (empty = current files)
+	Paths     []string // Relative paths to restore (empty = everything)
+	TargetDir string   // Local directory receiving the files
+	Owner     string   // Local user owning restored files ("" = leave as is)
+}
+
+// RestoreJob tracks a cloud restore running in the background
+type RestoreJob struct {
+	ID             int64      `json:"id"`
+	BackupID       int        `json:"backup_id"`
+	BackupName     string     `json:"backup_name"`
+	Username       string     `json:"username"`
+	Version        string     `json:"version"`
+	TargetDir      string     `json:"target_dir"`
+	Status         string     `json:"status"` // "running", "success", "error"
+	Bytes          int64      `json:"bytes"`
+	TotalBytes     int64      `json:"total_bytes"`
+	Transfers      int64      `json:"transfers"`
+	TotalTransfers int64      `json:"total_transfers"`
+	Errors         int64      `json:"errors"`
+	Error          string     `json:"error"`
+	StartedAt      time.Time  `json:"started_at"`
+	CompletedAt    *time.Time `json:"completed_at"`
+}
+
+// Cloud restore jobs started since boot, guarded by restoreJobsMu
+var (
+	restoreJobs   = make(map[int64]*RestoreJob)
+	restoreJobsMu gosync.Mutex
+	lastJobID     int64
+)
+
+// userRoot returns the rclone path of a user's backup directory, unwrapping crypt if enabled
+func userRoot(backup *RcloneBackup, dataDir, username string) string {
+	return buildDestination(backup, dataDir, filepath.Join(backup.RemotePath, "backup", username))
+}
+
+// joinRemote appends a relative path to an rclone remote path
+func joinRemote(root, rel string) string {
+	rel = strings.Trim(rel, "/")
+	if rel == "" {
+		return root
+	}
+	if strings.HasSuffix(root, ":") || strings.HasSuffix(root, "/") {
+		return root + rel
+	}
+	return root + "/" + rel
+}
+
+// ListCloudUsers returns the user directories found on a cloud destination
+func ListCloudUsers(backup *RcloneBackup, dataDir string) ([]string, error) {
+	// User directories are never crypt-wrapped: only their contents are
+	users, err := ListRemoteDir(backup, dataDir, filepath.Join(backup.RemotePath, "backup"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(users)
+	return users, nil
+}
+
+// ListCloudPath lists a directory inside a user's backup on a cloud destination, or
+// inside one of its archived versions
+func ListCloudPath(backup *RcloneBackup, dataDir, username, version, path string) ([]CloudEntry, error) {
+	rel, err := cleanCloudPath(path)
+	if err != nil {
+		return nil, err
+	}
+	root, err := versionRoot(backup, dataDir, username, version)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command("rclone", "lsjson", joinRemote(root, rel))
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed to list remote: %s", strings.TrimSpace(stderr.String()))
+	}
+
+	var items []struct {
+		Name    string
+		Size    int64
+		IsDir   bool
+		ModTime time.Time
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &items); err != nil {
+		return nil, fmt.Errorf("failed to parse remote listing: %w", err)
+	}
+
+	entries := make([]CloudEntry, 0, len(items))
+	for _, item := range items {
+		if version == "" && rel == "" && item.Name == VersionsDir {
+			continue
+		}
+		entryPath := item.Name
+		if rel != "" {
+			entryPath = rel + "/" + item.Name
+		}
+		size := item.Size
+		if item.IsDir {
+			size = 0
+		}
+		entries = append(entries, CloudEntry{
+			Name:    item.Name,
+			Path:    entryPath,
+			Size:    size,
+			IsDir:   item.IsDir,
+			ModTime: item.ModTime,
+		})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].IsDir != entries[j].IsDir {
+			return entries[i].IsDir
+		}
+		return entries[i].Name < entries[j].Name
+	})
+	return entries, nil
+}
+
+// StartRestore starts pulling files back from a cloud destination with rclone copy
+func StartRestore(backup *RcloneBackup, dataDir string, req RestoreRequest) (*RestoreJob, error) {
+	if !IsRcloneInstalled() {
+		return nil, fmt.Errorf("rclone is not installed")
+	}
+	if req.Username == "" || strings.ContainsAny(req.Username, "/\\") || req.Username == ".." {
+		return nil, fmt.Errorf("invalid username")
+	}
+	if req.TargetDir == "" || !filepath.IsAbs(req.TargetDir) {
+		return nil, fmt.Errorf("invalid target directory")
+	}
+
+	filters, err := buildRestoreFilters(req.Paths)
+	if err != nil {
+		return nil, err
+	}
+	source, err := versionRoot(backup, dataDir, req.Username, req.Version)
+	if err != nil {
+		return nil, err
+	}
+	if req.Version == "" {
+		// Archives are not part of the current files
+		filters = append([]string{"- /" + VersionsDir + "/**"}, filters...)
+	}
+
+	restoreJobsMu.Lock()
+	defer restoreJobsMu.Unlock()
+
+	// Refuse a second restore into the same directory while one is running
+	for _, j := range restoreJobs {
+		if j.Status == "running" && j.TargetDir == req.TargetDir {
+			return nil, fmt.Errorf("a restore into this directory is already running")
+		}
+	}
+
+	lastJobID++
+	job := &RestoreJob{
+		ID:         lastJobID,
+		BackupID:   backup.ID,
+		BackupName: backup.Name,
+		Username:   req.Username,
+		Version:    req.Version,
+		TargetDir:  req.TargetDir,
+		Status:     "running",
+		StartedAt:  time.Now(),
+	}
+	restoreJobs[job.ID] = job
+
+	go runRestore(job, source, filters, req.Owner)
+
+	snapshot := *job
+	return &snapshot, nil
+}
+
+// GetRestoreJob returns a snapshot of a cloud restore job
+func GetRestoreJob(id int64) (*RestoreJob, bool) {
+	restoreJobsMu.Lock()
+	defer restoreJobsMu.Unlock()
+
+	job, ok := restoreJobs[id]
+	if !ok {
+		return nil, false
+	}
+	snapshot := *job
+	return &snapshot, true
+}
+
+// ListRestoreJobs returns snapshots of the cloud restore jobs started since boot, newest first
+func ListRestoreJobs(backupID int) []*RestoreJob {
+	restoreJobsMu.Lock()
+	defer restoreJobsMu.Unlock()
+
+	var jobs []*RestoreJob
+	for _, j := range restoreJobs {
+		if backupID == 0 || j.BackupID == backupID {
+			snapshot := *j
+			jobs = append(jobs, &snapshot)
+		}
+	}
+	sort.Slice(jobs, func(i, k int) bool { return jobs[i].ID > jobs[k].ID })
+	return jobs
+}
+
+// runRestore executes rclone copy and records its progress
+func runRestore(job *RestoreJob, source string, filters []string, owner string) {
+	logger.Info("Rclone: Starting cloud restore", "job_id", job.ID, "backup", job.BackupName, "username", job.Username, "target", job.TargetDir)
+
+	err := copyWithProgress(job, source, filters)
+	if err == nil && owner != "" {
+		if chownErr := chownTree(job.TargetDir, owner); chownErr != nil {
+			logger.Warn("Rclone: Failed to set ownership of restored files", "job_id", job.ID, "error", chownErr)
+		}
+	}
+
+	restoreJobsMu.Lock()
+	now := time.Now()
+	job.CompletedAt = &now
+	if err != nil {
+		job.Status = "error"
+		job.Error = err.Error()
+	} else {
+		job.Status = "success"
+	}
+	files, size := job.Transfers, job.Bytes
+	restoreJobsMu.Unlock()
+
+	if err != nil {
+		logger.Warn("Rclone: Cloud restore failed", "job_id", job.ID, "error", err)
+		return
+	}
+	logger.Info("Rclone: Cloud restore completed", "job_id", job.ID, "files", files, "bytes", FormatBytes(size))
+}
+
+// copyWithProgress runs rclone copy and parses the JSON stats it logs
+func copyWithProgress(job *RestoreJob, source string, filters []string) error {
+	if err := os.MkdirAll(job.TargetDir, 0755); err != nil {
+		return fmt.Errorf("failed to create target directory: %w", err)
+	}
+
+	args := []string{
+		"copy",
+		source,
+		job.TargetDir,
+		"--use-json-log",
+		"--stats", "2s",
+		"--stats-log-level", "NOTICE",
+		"--transfers", "4",
+		"--checkers", "8",
+	}
+	for _, f := range filters {
+		args = append(args, "--filter", f)
+	}
+
+	cmd := exec.Command("rclone", args...)
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return fmt.Errorf("failed to capture rclone output: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start rclone: %w", err)
+	}
+
+	var lastError string
+	scanner := bufio.NewScanner(stderr)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry struct {
+			Level string `json:"level"`
+			Msg   string `json:"msg"`
+			Stats *struct {
+				Bytes          int64 `json:"bytes"`
+				TotalBytes     int64 `json:"totalBytes"`
+				Transfers      int64 `json:"transfers"`
+				TotalTransfers int64 `json:"totalTransfers"`
+				Errors         int64 `json:"errors"`
+			} `json:"stats"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		if entry.Stats != nil {
+			restoreJobsMu.Lock()
+			job.Bytes = entry.Stats.Bytes
+			job.TotalBytes = entry.Stats.TotalBytes
+			job.Transfers = entry.Stats.Transfers
+			job.TotalTransfers = entry.Stats.TotalTransfers
+			job.Errors = entry.Stats.Errors
+			restoreJobsMu.Unlock()
+		}
+		if entry.Level == "error" {
+			lastError = entry.Msg
+		}
+	}
+
+	if err := cmd.Wait(); err != nil {
+		if lastError != "" {
+			return fmt.Errorf("rclone error: %s", lastError)
+		}
+		return fmt.Errorf("rclone error: %w", err)
+	}
+	return nil
+}
+
+// buildRestoreFilters converts selected paths into rclone filter rules
+func buildRestoreFilters(paths []string) ([]string, error) {
+	var filters []string
+	for _, p := range paths {
+		rel, err := cleanCloudPath(p)
+		if err != nil {
+			return nil, err
+		}
+		if rel == "" {
+			// Whole backup selected: no filtering
+			return nil, nil
+		}
+		escaped := escapeFilterGlob(rel)
+		// Match the path itself (file) and everything below it (directory)
+		filters = append(filters, "+ /"+escaped, "+ /"+escaped+"/**")
+	}
+	if len(filters) > 0 {
+		filters = append(filters, "- **")
+	}
+	return filters, nil
+}
+
+// escapeFilterGlob escapes rclone glob metacharacters in a literal path
+func escapeFilterGlob(p string) string {
+	var b strings.Builder
+	for _, r := range p {
+		if strings.ContainsRune(`*?[]{}\`, r) {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// cleanCloudPath normalizes a path inside a user's backup and rejects traversal
+func cleanCloudPath(p string) (string, error) {
+	p = strings.Trim(p, "/")
+	if p == "" {
+		return "", nil
+	}
+	cleaned := filepath.Clean(p)
+	if cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", fmt.Errorf("invalid path")
+	}
+	if cleaned == "." {
+		return "", nil
+	}
+	return cleaned, nil
+}
+
+// chownTree gives ownership of a restored tree to a local user
+func chownTree(root, username string) error {
+	u, err := user.Lookup(username)
+	if err != nil {
+		return fmt.Errorf("user lookup failed: %w", err)
+	}
+	uid, err := strconv.Atoi(u.Uid)
+	if err != nil {
+		return fmt.Errorf("invalid UID: %w", err)
+	}
+	gid, err := strconv.Atoi(u.Gid)
+	if err != nil {
+		return fmt.Errorf("invalid GID: %w", err)
+	}
+
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		return os.Lchown(path, uid, gid)
+	})
+}
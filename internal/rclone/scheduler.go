@@ -43,7 +43,7 @@ func checkStaleRunning(db *sql.DB) {
 
 // StartScheduler launches the automatic rclone backup scheduler in a goroutine.
 // It checks every minute if a sync should be triggered and monitors running syncs.
-func StartScheduler(db *sql.DB, dataDir string) {
+func StartScheduler(db *sql.DB, dataDir, incomingDir string) {
 	logger.Info("🔄 Starting rclone backup scheduler...")
 
 	// Run scheduler in background
@@ -85,7 +85,7 @@ func StartScheduler(db *sql.DB, dataDir string) {
 
 				// Perform sync in a goroutine so we don't block other backups
 				go func(b *RcloneBackup) {
-					result, syncErr := Sync(db, b, dataDir)
+					result, syncErr := Sync(db, b, dataDir, incomingDir)
 
 					if syncErr != nil {
 						logger.Info("Rclone Scheduler: Sync to failed", "name", b.Name, "sync_err", syncErr)
@@ -0,0 +1,100 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+// This file selects the local directories sent to a cloud destination and where
+// each of them lands on the remote.
+
+package rclone
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+
+	"github.com/juste-un-gars/anemone/internal/shares"
+	"github.com/juste-un-gars/anemone/internal/users"
+)
+
+// Source is a local directory synced to a subdirectory of the remote path
+type Source struct {
+	Name      string // Shown in results and logs (e.g., "data/alice", "server")
+	LocalDir  string
+	RemoteDir string // Relative to the remote path of the backup
+}
+
+// SourceResult contains the outcome of the sync of one source
+type SourceResult struct {
+	Source           string
+	FilesTransferred int
+	BytesTransferred int64
+	Error            string
+	Skipped          bool // Local directory missing
+}
+
+// Sources returns the local directories selected for a cloud destination
+func Sources(db *sql.DB, backup *RcloneBackup, dataDir, incomingDir string) ([]Source, error) {
+	allShares, err := shares.GetAll(db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get shares: %w", err)
+	}
+	allUsers, err := users.GetAllUsers(db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get users: %w", err)
+	}
+	usernames := make(map[int]string, len(allUsers))
+	for _, u := range allUsers {
+		usernames[u.ID] = u.Username
+	}
+	return selectSources(backup, allShares, usernames, dataDir, incomingDir), nil
+}
+
+// selectSources builds the sources of a backup from the shares of the server.
+// Backup and data shares land in <kind>/<username>, the layout restores expect.
+func selectSources(backup *RcloneBackup, allShares []*shares.Share, usernames map[int]string, dataDir, incomingDir string) []Source {
+	selected := make(map[int]bool)
+	for _, id := range backup.GetSelectedShareIDs() {
+		selected[id] = true
+	}
+
+	var sources []Source
+	for _, share := range allShares {
+		kind := filepath.Base(share.Path)
+		include := selected[share.ID] ||
+			(kind == "backup" && backup.IncludeBackup) ||
+			(kind == "data" && backup.IncludeData)
+		if !include {
+			continue
+		}
+
+		username := usernames[share.UserID]
+		if username == "" {
+			username = filepath.Base(filepath.Dir(share.Path))
+		}
+		remoteDir := filepath.Join(kind, username)
+		if kind != "backup" && kind != "data" {
+			remoteDir = filepath.Join("shares", username, share.Name)
+		}
+		sources = append(sources, Source{
+			Name:      filepath.ToSlash(remoteDir),
+			LocalDir:  share.Path,
+			RemoteDir: remoteDir,
+		})
+	}
+
+	if backup.IncludeServerConfig {
+		sources = append(sources, Source{
+			Name:      "server",
+			LocalDir:  filepath.Join(dataDir, "backups", "server"),
+			RemoteDir: "server",
+		})
+	}
+	if backup.IncludeIncoming && incomingDir != "" {
+		sources = append(sources, Source{
+			Name:      "incoming",
+			LocalDir:  incomingDir,
+			RemoteDir: "incoming",
+		})
+	}
+	return sources
+}
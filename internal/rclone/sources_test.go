@@ -0,0 +1,52 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+package rclone
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/juste-un-gars/anemone/internal/shares"
+)
+
+func TestSelectSources(t *testing.T) {
+	allShares := []*shares.Share{
+		{ID: 1, UserID: 1, Name: "backup_alice", Path: "/srv/anemone/shares/alice/backup"},
+		{ID: 2, UserID: 1, Name: "data_alice", Path: "/srv/anemone/shares/alice/data"},
+		{ID: 3, UserID: 2, Name: "backup_bob", Path: "/srv/anemone/shares/bob/backup"},
+		{ID: 4, UserID: 2, Name: "data_bob", Path: "/srv/anemone/shares/bob/data"},
+	}
+	usernames := map[int]string{1: "alice", 2: "bob"}
+
+	names := func(sources []Source) []string {
+		var out []string
+		for _, s := range sources {
+			out = append(out, s.Name)
+		}
+		return out
+	}
+
+	tests := []struct {
+		name   string
+		backup RcloneBackup
+		want   []string
+	}{
+		{"backup shares only", RcloneBackup{IncludeBackup: true}, []string{"backup/alice", "backup/bob"}},
+		{"all data plus one backup share", RcloneBackup{IncludeData: true, SelectedShares: "[3]"}, []string{"data/alice", "backup/bob", "data/bob"}},
+		{"server config and incoming", RcloneBackup{IncludeServerConfig: true, IncludeIncoming: true}, []string{"server", "incoming"}},
+		{"nothing selected", RcloneBackup{}, nil},
+	}
+	for _, tt := range tests {
+		got := names(selectSources(&tt.backup, allShares, usernames, "/srv/anemone", "/srv/incoming"))
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: sources = %v, expected %v", tt.name, got, tt.want)
+		}
+	}
+
+	sources := selectSources(&RcloneBackup{IncludeServerConfig: true, IncludeIncoming: true}, nil, nil, "/srv/anemone", "/srv/incoming")
+	if sources[0].LocalDir != "/srv/anemone/backups/server" || sources[1].LocalDir != "/srv/incoming" {
+		t.Errorf("unexpected local directories %+v", sources)
+	}
+}
@@ -7,18 +7,17 @@ package rclone
 import (
 	"bytes"
 	"database/sql"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
-	"regexp"
-	"strconv"
 	"strings"
 	gosync "sync"
 	"syscall"
+	"time"
 
 	"github.com/juste-un-gars/anemone/internal/logger"
-	"github.com/juste-un-gars/anemone/internal/users"
 )
 
 // activeProcesses tracks running rclone processes per backup ID.
@@ -45,6 +44,8 @@ type SyncResult struct {
 	FilesTransferred int
 	BytesTransferred int64
 	Errors           []string
+	Sources          []SourceResult // Outcome of each source, in sync order
+	FileErrors       []FileError    // Files that failed, as logged by rclone
 }
 
 // IsRcloneInstalled checks if rclone is available on the system
@@ -92,8 +93,9 @@ func TestConnection(backup *RcloneBackup, dataDir string) error {
 	return nil
 }
 
-// Sync synchronizes all users' backup directories to the SFTP server
-func Sync(db *sql.DB, backup *RcloneBackup, dataDir string) (*SyncResult, error) {
+// Sync synchronizes the sources selected for a backup (shares, server configuration
+// backups, incoming peer backups) to the remote, each to its own subdirectory
+func Sync(db *sql.DB, backup *RcloneBackup, dataDir, incomingDir string) (*SyncResult, error) {
 	if !IsRcloneInstalled() {
 		return nil, fmt.Errorf("rclone is not installed")
 	}
@@ -101,59 +103,117 @@ func Sync(db *sql.DB, backup *RcloneBackup, dataDir string) (*SyncResult, error)
 	// Update status to running
 	UpdateSyncStatus(db, backup.ID, "running", "", 0, 0)
 
-	result := &SyncResult{}
+	sources, err := Sources(db, backup, dataDir, incomingDir)
+	if err != nil {
+		UpdateSyncStatus(db, backup.ID, "error", err.Error(), 0, 0)
+		return nil, err
+	}
+	if len(sources) == 0 {
+		err := fmt.Errorf("no source selected")
+		UpdateSyncStatus(db, backup.ID, "error", err.Error(), 0, 0)
+		return nil, err
+	}
 
-	// Get all users
-	allUsers, err := users.GetAllUsers(db)
+	result, err := syncSources(backup, dataDir, sources)
 	if err != nil {
 		UpdateSyncStatus(db, backup.ID, "error", err.Error(), 0, 0)
-		return nil, fmt.Errorf("failed to get users: %w", err)
+		return nil, err
+	}
+
+	if err := saveFileErrors(db, backup.ID, result.FileErrors); err != nil {
+		logger.Warn("Rclone: Failed to record file errors", "name", backup.Name, "error", err)
+	}
+
+	// Update final status
+	if len(result.Errors) > 0 {
+		errMsg := strings.Join(result.Errors, "; ")
+		UpdateSyncStatus(db, backup.ID, "error", errMsg, result.FilesTransferred, result.BytesTransferred)
+	} else {
+		UpdateSyncStatus(db, backup.ID, "success", "", result.FilesTransferred, result.BytesTransferred)
 	}
 
+	logger.Info("Rclone backup completed: files", "files_transferred", result.FilesTransferred, "bytes_transferred", FormatBytes(result.BytesTransferred), "file_errors", len(result.FileErrors))
+
+	return result, nil
+}
+
+// syncSources syncs each source through a private rclone remote control server,
+// publishing live progress until the run ends or is cancelled
+func syncSources(backup *RcloneBackup, dataDir string, sources []Source) (*SyncResult, error) {
+	rc, err := startRC(backup.ID)
+	if err != nil {
+		return nil, err
+	}
 	// Ensure process tracking is cleaned up when sync finishes
 	defer activeProcesses.Delete(backup.ID)
 
-	// Sync each user's backup directory
-	for _, user := range allUsers {
-		// Source: user's backup directory
-		sourceDir := filepath.Join(dataDir, "shares", user.Username, "backup")
+	cancel := beginRun(backup.ID, len(sources))
+	defer endRun(backup.ID)
+
+	result := &SyncResult{}
+
+	// All sources of a run share one archive name
+	version := versionName(time.Now())
+	var errorCount int64
 
-		// Check if backup directory exists
-		if _, err := os.Stat(sourceDir); os.IsNotExist(err) {
-			logger.Info("Rclone: No backup directory for user , skipping", "username", user.Username)
+	for i, source := range sources {
+		sourceResult := SourceResult{Source: source.Name}
+
+		// Check if the local directory exists
+		if _, err := os.Stat(source.LocalDir); os.IsNotExist(err) {
+			logger.Info("Rclone: No local directory for source, skipping", "source", source.Name, "path", source.LocalDir)
+			sourceResult.Skipped = true
+			result.Sources = append(result.Sources, sourceResult)
 			continue
 		}
 
-		// Destination: remote path / username (with optional crypt wrapping)
-		destPath := filepath.Join(backup.RemotePath, "backup", user.Username)
+		// Destination: remote path / source directory (with optional crypt wrapping)
+		destPath := filepath.Join(backup.RemotePath, source.RemoteDir)
 		dest := buildDestination(backup, dataDir, destPath)
 
-		logger.Info("Rclone: Syncing to", "username", user.Username, "display_host", backup.DisplayHost(), "dest_path", destPath)
+		var opts syncOptions
+		if backup.Versioning {
+			opts = versionOptions(dest, version)
+		}
+
+		logger.Info("Rclone: Syncing to", "source", source.Name, "display_host", backup.DisplayHost(), "dest_path", destPath)
+
+		setRunSource(backup.ID, i+1, source.Name)
+		previousErrors := errorCount
+		stats, err := rc.sync(source.Name, source.LocalDir, dest, opts, cancel, func(st rcStats) {
+			setRunStats(backup.ID, st, previousErrors)
+		})
+		errorCount += stats.Errors
+
+		sourceResult.FilesTransferred = int(stats.Transfers)
+		sourceResult.BytesTransferred = stats.Bytes
+		result.FilesTransferred += sourceResult.FilesTransferred
+		result.BytesTransferred += sourceResult.BytesTransferred
 
-		// Run rclone sync (tracked by backup ID for process monitoring)
-		userResult, err := runRcloneSyncTracked(sourceDir, dest, backup.ID)
 		if err != nil {
-			errMsg := fmt.Sprintf("user %s: %v", user.Username, err)
-			result.Errors = append(result.Errors, errMsg)
-			logger.Info("Rclone: Sync failed for", "username", user.Username, "error", err)
+			sourceResult.Error = err.Error()
+			result.Sources = append(result.Sources, sourceResult)
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", source.Name, err))
+			logger.Info("Rclone: Sync failed for", "source", source.Name, "error", err)
+			if errors.Is(err, ErrCancelled) {
+				break
+			}
 			continue
 		}
+		result.Sources = append(result.Sources, sourceResult)
 
-		result.FilesTransferred += userResult.FilesTransferred
-		result.BytesTransferred += userResult.BytesTransferred
-
-		logger.Info("Rclone: Synced - files", "username", user.Username, "files_transferred", userResult.FilesTransferred, "bytes_transferred", FormatBytes(userResult.BytesTransferred))
-	}
+		logger.Info("Rclone: Synced - files", "source", source.Name, "files_transferred", sourceResult.FilesTransferred, "bytes_transferred", FormatBytes(sourceResult.BytesTransferred))
 
-	// Update final status
-	if len(result.Errors) > 0 {
-		errMsg := strings.Join(result.Errors, "; ")
-		UpdateSyncStatus(db, backup.ID, "error", errMsg, result.FilesTransferred, result.BytesTransferred)
-	} else {
-		UpdateSyncStatus(db, backup.ID, "success", "", result.FilesTransferred, result.BytesTransferred)
+		if backup.Versioning {
+			if _, err := pruneVersions(dest, backup.VersionRetentionDays); err != nil {
+				logger.Warn("Rclone: Failed to prune old versions", "source", source.Name, "error", err)
+			}
+		}
 	}
 
-	logger.Info("Rclone backup completed: files", "files_transferred", result.FilesTransferred, "bytes_transferred", FormatBytes(result.BytesTransferred))
+	// Stop the daemon first so that every error it logged has been read
+	rc.stop()
+	result.FileErrors = rc.takeFileErrors()
 
 	return result, nil
 }
@@ -164,8 +224,6 @@ func SyncUser(db *sql.DB, backup *RcloneBackup, dataDir string, username string)
 		return nil, fmt.Errorf("rclone is not installed")
 	}
 
-	result := &SyncResult{}
-
 	// Source: user's backup directory
 	sourceDir := filepath.Join(dataDir, "shares", username, "backup")
 
@@ -174,20 +232,17 @@ func SyncUser(db *sql.DB, backup *RcloneBackup, dataDir string, username string)
 		return nil, fmt.Errorf("backup directory does not exist: %s", sourceDir)
 	}
 
-	// Destination: remote path / username (with optional crypt wrapping)
-	destPath := filepath.Join(backup.RemotePath, "backup", username)
-	dest := buildDestination(backup, dataDir, destPath)
-
-	logger.Info("Rclone: Syncing to", "username", username, "display_host", backup.DisplayHost(), "dest_path", destPath)
-
-	// Run rclone sync (tracked by backup ID)
-	userResult, err := runRcloneSyncTracked(sourceDir, dest, backup.ID)
+	result, err := syncSources(backup, dataDir, []Source{{
+		Name:      "backup/" + username,
+		LocalDir:  sourceDir,
+		RemoteDir: filepath.Join("backup", username),
+	}})
 	if err != nil {
-		return nil, fmt.Errorf("sync failed: %w", err)
+		return nil, err
+	}
+	if len(result.Errors) > 0 {
+		return nil, fmt.Errorf("sync failed: %s", result.Errors[0])
 	}
-
-	result.FilesTransferred = userResult.FilesTransferred
-	result.BytesTransferred = userResult.BytesTransferred
 
 	return result, nil
 }
@@ -305,97 +360,6 @@ func buildDestination(backup *RcloneBackup, dataDir, destPath string) string {
 		quoteValue(remote+destPath), quoteValue(cryptPass))
 }
 
-// runRcloneSyncTracked executes rclone sync with process tracking for the given backup ID.
-// The process is stored in activeProcesses so the scheduler can detect stale syncs.
-func runRcloneSyncTracked(sourceDir, dest string, backupID int) (*SyncResult, error) {
-	result := &SyncResult{}
-
-	args := []string{
-		"sync",
-		sourceDir,
-		dest,
-		"--stats-one-line",
-		"--stats", "0",
-		"--transfers", "4",
-		"--checkers", "8",
-		"-v",
-	}
-
-	cmd := exec.Command("rclone", args...)
-
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-
-	// Start process and track it
-	if err := cmd.Start(); err != nil {
-		return result, fmt.Errorf("failed to start rclone: %w", err)
-	}
-	activeProcesses.Store(backupID, cmd)
-
-	// Wait for completion
-	err := cmd.Wait()
-
-	// Parse output for stats (even on error, we might have partial results)
-	output := stdout.String() + stderr.String()
-	result.FilesTransferred, result.BytesTransferred = parseRcloneStats(output)
-
-	if err != nil {
-		return result, fmt.Errorf("rclone error: %s", strings.TrimSpace(stderr.String()))
-	}
-
-	return result, nil
-}
-
-// parseRcloneStats parses rclone output for transfer statistics
-func parseRcloneStats(output string) (files int, bytes int64) {
-	// Look for patterns like:
-	// "Transferred:   1.234 MiB / 1.234 MiB, 100%, 0 B/s, ETA -"
-	// "Transferred:        5 / 5, 100%"
-
-	// Pattern for bytes transferred
-	bytesPattern := regexp.MustCompile(`Transferred:\s*([\d.]+)\s*([KMGTi]*B)`)
-	if match := bytesPattern.FindStringSubmatch(output); len(match) >= 3 {
-		value, _ := strconv.ParseFloat(match[1], 64)
-		unit := match[2]
-		bytes = convertToBytes(value, unit)
-	}
-
-	// Pattern for files transferred
-	filesPattern := regexp.MustCompile(`Transferred:\s*(\d+)\s*/\s*\d+`)
-	if match := filesPattern.FindStringSubmatch(output); len(match) >= 2 {
-		files, _ = strconv.Atoi(match[1])
-	}
-
-	// Alternative: look for "Checks:" line
-	checksPattern := regexp.MustCompile(`Checks:\s*(\d+)`)
-	if match := checksPattern.FindStringSubmatch(output); len(match) >= 2 && files == 0 {
-		files, _ = strconv.Atoi(match[1])
-	}
-
-	return files, bytes
-}
-
-// convertToBytes converts a value with unit to bytes
-func convertToBytes(value float64, unit string) int64 {
-	multipliers := map[string]float64{
-		"B":   1,
-		"KiB": 1024,
-		"KB":  1000,
-		"MiB": 1024 * 1024,
-		"MB":  1000 * 1000,
-		"GiB": 1024 * 1024 * 1024,
-		"GB":  1000 * 1000 * 1000,
-		"TiB": 1024 * 1024 * 1024 * 1024,
-		"TB":  1000 * 1000 * 1000 * 1000,
-	}
-
-	if mult, ok := multipliers[unit]; ok {
-		return int64(value * mult)
-	}
-	return int64(value)
-}
-
 // ListRemoteDir lists the contents of a remote directory (for testing/debugging)
 func ListRemoteDir(backup *RcloneBackup, dataDir string, path string) ([]string, error) {
 	remote := buildRemoteString(backup, dataDir)
@@ -0,0 +1,149 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+// This file keeps previous versions of files on cloud destinations. With versioning
+// enabled, each sync moves the files it replaces or deletes into a dated archive
+// directory (rclone backup-dir) inside the synced directory, and archives older
+// than the retention period are pruned afterwards.
+
+package rclone
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/juste-un-gars/anemone/internal/logger"
+)
+
+// VersionsDir is the directory holding the archives, at the root of each synced directory
+const VersionsDir = ".anemone-versions"
+
+// versionLayout names archive directories after the UTC time of the sync that filled them
+const versionLayout = "2006-01-02_150405"
+
+// Version is an archive of the files replaced or deleted by one sync
+type Version struct {
+	Name string    `json:"name"`
+	Time time.Time `json:"time"`
+}
+
+// versionName returns the archive directory name of a sync started at t
+func versionName(t time.Time) string {
+	return t.UTC().Format(versionLayout)
+}
+
+// parseVersion returns the sync time of an archive directory name
+func parseVersion(name string) (time.Time, bool) {
+	t, err := time.Parse(versionLayout, name)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// versionOptions returns the sync options archiving replaced and deleted files of dest.
+// The archive lives inside dest so that it is on the same remote, and is excluded from
+// the sync so that it is neither uploaded to nor deleted.
+func versionOptions(dest, version string) syncOptions {
+	return syncOptions{
+		BackupDir: joinRemote(dest, VersionsDir+"/"+version),
+		Exclude:   []string{"/" + VersionsDir + "/**"},
+	}
+}
+
+// listVersions returns the archives found under a synced directory, newest first
+func listVersions(root string) ([]Version, error) {
+	cmd := exec.Command("rclone", "lsjson", "--dirs-only", joinRemote(root, VersionsDir))
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if strings.Contains(stderr.String(), "directory not found") {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to list versions: %s", strings.TrimSpace(stderr.String()))
+	}
+
+	var items []struct {
+		Name string
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &items); err != nil {
+		return nil, fmt.Errorf("failed to parse versions listing: %w", err)
+	}
+
+	var versions []Version
+	for _, item := range items {
+		if t, ok := parseVersion(item.Name); ok {
+			versions = append(versions, Version{Name: item.Name, Time: t})
+		}
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i].Time.After(versions[j].Time) })
+	return versions, nil
+}
+
+// expiredVersions returns the archives older than the retention period
+func expiredVersions(versions []Version, retentionDays int, now time.Time) []Version {
+	if retentionDays <= 0 {
+		return nil
+	}
+	cutoff := now.Add(-time.Duration(retentionDays) * 24 * time.Hour)
+
+	var expired []Version
+	for _, v := range versions {
+		if v.Time.Before(cutoff) {
+			expired = append(expired, v)
+		}
+	}
+	return expired
+}
+
+// pruneVersions deletes the archives of a synced directory older than the retention period
+func pruneVersions(root string, retentionDays int) (int, error) {
+	if retentionDays <= 0 {
+		return 0, nil
+	}
+	versions, err := listVersions(root)
+	if err != nil {
+		return 0, err
+	}
+
+	pruned := 0
+	for _, v := range expiredVersions(versions, retentionDays, time.Now()) {
+		cmd := exec.Command("rclone", "purge", joinRemote(root, VersionsDir+"/"+v.Name))
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			return pruned, fmt.Errorf("failed to prune version %s: %s", v.Name, strings.TrimSpace(stderr.String()))
+		}
+		logger.Info("Rclone: Pruned expired version", "version", v.Name)
+		pruned++
+	}
+	return pruned, nil
+}
+
+// ListCloudVersions returns the archives of a user's backup on a cloud destination, newest first
+func ListCloudVersions(backup *RcloneBackup, dataDir, username string) ([]Version, error) {
+	if username == "" || strings.ContainsAny(username, "/\\") || username == ".." {
+		return nil, fmt.Errorf("invalid username")
+	}
+	return listVersions(userRoot(backup, dataDir, username))
+}
+
+// versionRoot returns the rclone path of a user's backup as it was before a sync,
+// or of the current backup when version is empty
+func versionRoot(backup *RcloneBackup, dataDir, username, version string) (string, error) {
+	root := userRoot(backup, dataDir, username)
+	if version == "" {
+		return root, nil
+	}
+	if _, ok := parseVersion(version); !ok {
+		return "", fmt.Errorf("invalid version")
+	}
+	return joinRemote(root, VersionsDir+"/"+version), nil
+}
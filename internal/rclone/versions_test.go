@@ -0,0 +1,58 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+package rclone
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestVersionName(t *testing.T) {
+	at := time.Date(2026, 3, 14, 2, 0, 5, 0, time.UTC)
+	name := versionName(at)
+	if name != "2026-03-14_020005" {
+		t.Fatalf("versionName = %q", name)
+	}
+	got, ok := parseVersion(name)
+	if !ok || !got.Equal(at) {
+		t.Errorf("parseVersion(%q) = %v, %v", name, got, ok)
+	}
+	if _, ok := parseVersion("../etc"); ok {
+		t.Error("parseVersion accepted an invalid name")
+	}
+}
+
+func TestVersionOptions(t *testing.T) {
+	tests := []struct {
+		dest string
+		want string
+	}{
+		{"sftp:/srv/backups/backup/alice", "sftp:/srv/backups/backup/alice/.anemone-versions/2026-03-14_020005"},
+		{`:crypt,remote="sftp:/srv/backups/backup/alice",password="x":`, `:crypt,remote="sftp:/srv/backups/backup/alice",password="x":.anemone-versions/2026-03-14_020005`},
+	}
+	for _, tt := range tests {
+		want := syncOptions{BackupDir: tt.want, Exclude: []string{"/.anemone-versions/**"}}
+		if got := versionOptions(tt.dest, "2026-03-14_020005"); !reflect.DeepEqual(got, want) {
+			t.Errorf("versionOptions(%q) = %+v, expected %+v", tt.dest, got, want)
+		}
+	}
+}
+
+func TestExpiredVersions(t *testing.T) {
+	now := time.Date(2026, 3, 31, 12, 0, 0, 0, time.UTC)
+	versions := []Version{
+		{Name: "recent", Time: now.Add(-24 * time.Hour)},
+		{Name: "old", Time: now.Add(-40 * 24 * time.Hour)},
+	}
+
+	expired := expiredVersions(versions, 30, now)
+	if len(expired) != 1 || expired[0].Name != "old" {
+		t.Errorf("expiredVersions = %v, expected only the old version", expired)
+	}
+	if expired := expiredVersions(versions, 0, now); len(expired) != 0 {
+		t.Errorf("expiredVersions with no retention = %v, expected none", expired)
+	}
+}
@@ -44,7 +44,7 @@ func Start(db *sql.DB) {
 				logger.Info("Scheduler: Triggering sync to peer '' (frequency: )...", "name", peer.Name, "sync_frequency", peer.SyncFrequency)
 
 				// Perform sync for this peer
-				successCount, errorCount, lastError := sync.SyncPeer(db, peer.ID, peer.Name, peer.Address, peer.Port, peer.Password, peer.SyncTimeoutHours)
+				successCount, errorCount, lastError := sync.SyncPeer(db, peer.ID, peer.Name, peer.Address, peer.Port, peer.Password, peer.SyncTimeoutHours, peer.VerifyUploads)
 
 				// Update last sync timestamp for this peer
 				if err := peers.UpdateLastSync(db, peer.ID); err != nil {
@@ -0,0 +1,426 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+// This file replicates server configuration backups off the server. Once a recovery
+// passphrase is set, each backup is also written encrypted with that passphrase
+// instead of the master key, and pushed to every enabled peer, so that a server
+// lost with its disks can be rebuilt from a peer with the passphrase alone.
+
+package serverbackup
+
+import (
+	"bytes"
+	"crypto/tls"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/juste-un-gars/anemone/internal/backup"
+	"github.com/juste-un-gars/anemone/internal/crypto"
+	"github.com/juste-un-gars/anemone/internal/logger"
+	"github.com/juste-un-gars/anemone/internal/peers"
+	"github.com/juste-un-gars/anemone/internal/sync"
+)
+
+const (
+	// OffsiteDir is the subdirectory of the backup directory holding the copies
+	// encrypted with the recovery passphrase. Cloud destinations backing up the
+	// server configuration upload it along with the other backups.
+	OffsiteDir = "offsite"
+
+	// PeerDir is the directory holding the copies received from a server, in the
+	// incoming directory of that server on a peer
+	PeerDir = ".server-config"
+
+	// MinPassphraseLength is the minimum length of the recovery passphrase
+	MinPassphraseLength = 12
+)
+
+// ErrPeerAuth is returned when a peer rejects the sync password
+var ErrPeerAuth = errors.New("peer rejected the sync password")
+
+// backupNamePattern matches the names of the files written by CreateServerBackup
+var backupNamePattern = regexp.MustCompile(`^backup_\d{8}_\d{6}\.enc$`)
+
+// ValidBackupName reports whether name is the name of a server backup file
+func ValidBackupName(name string) bool {
+	return backupNamePattern.MatchString(name)
+}
+
+// ReplicationStatus is the result of the last push of a backup to the peers
+type ReplicationStatus struct {
+	At       time.Time         `json:"at"`
+	Filename string            `json:"filename"`
+	Pushed   []string          `json:"pushed"` // Peers that received the backup
+	Failed   map[string]string `json:"failed"` // Error by peer name
+}
+
+// PeerBackup is a server backup received from another server
+type PeerBackup struct {
+	SourceServer string    `json:"source_server"`
+	Filename     string    `json:"filename"`
+	Size         int64     `json:"size"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// SetRecoveryPassphrase stores the passphrase encrypting the offsite copies, itself
+// encrypted with the master key so that scheduled backups can use it
+func SetRecoveryPassphrase(db *sql.DB, masterKey, passphrase string) error {
+	if len(passphrase) < MinPassphraseLength {
+		return fmt.Errorf("passphrase must be at least %d characters", MinPassphraseLength)
+	}
+	encrypted, err := crypto.EncryptKey(passphrase, masterKey)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt passphrase: %w", err)
+	}
+	_, err = db.Exec(`INSERT INTO system_config (key, value, updated_at)
+		VALUES ('server_backup_passphrase', ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(key) DO UPDATE SET value = excluded.value, updated_at = excluded.updated_at`, encrypted)
+	if err != nil {
+		return fmt.Errorf("failed to save passphrase: %w", err)
+	}
+	return nil
+}
+
+// HasRecoveryPassphrase reports whether offsite copies are enabled
+func HasRecoveryPassphrase(db *sql.DB) bool {
+	var encrypted string
+	err := db.QueryRow("SELECT value FROM system_config WHERE key = 'server_backup_passphrase'").Scan(&encrypted)
+	return err == nil && encrypted != ""
+}
+
+// recoveryPassphrase returns the recovery passphrase, or "" if none is set
+func recoveryPassphrase(db *sql.DB, masterKey string) (string, error) {
+	var encrypted string
+	err := db.QueryRow("SELECT value FROM system_config WHERE key = 'server_backup_passphrase'").Scan(&encrypted)
+	if err == sql.ErrNoRows || (err == nil && encrypted == "") {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get passphrase: %w", err)
+	}
+	passphrase, err := crypto.DecryptKey(encrypted, masterKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt passphrase: %w", err)
+	}
+	return passphrase, nil
+}
+
+// writeOffsiteCopy writes a backup encrypted with the recovery passphrase next to the
+// regular backups. Returns "" if no passphrase is set.
+func writeOffsiteCopy(db *sql.DB, serverBackup *backup.ServerBackup, backupDir, filename, masterKey string) (string, error) {
+	passphrase, err := recoveryPassphrase(db, masterKey)
+	if err != nil || passphrase == "" {
+		return "", err
+	}
+
+	// The copies leave the server: derive the key with Argon2id so that a peer
+	// can't guess the passphrase offline
+	encryptedData, err := backup.EncryptBackupWithPassphrase(serverBackup, passphrase)
+	if err != nil {
+		return "", fmt.Errorf("failed to encrypt offsite copy: %w", err)
+	}
+
+	dir := filepath.Join(backupDir, OffsiteDir)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create offsite directory: %w", err)
+	}
+	path := filepath.Join(dir, filename)
+	if err := os.WriteFile(path, encryptedData, 0600); err != nil {
+		return "", fmt.Errorf("failed to write offsite copy: %w", err)
+	}
+
+	if err := CleanOldBackups(dir, MaxBackups); err != nil {
+		logger.Info("Warning: failed to clean old offsite copies", "error", err)
+	}
+	return path, nil
+}
+
+// ReplicateToPeers pushes an offsite copy to every enabled peer and records the result
+func ReplicateToPeers(db *sql.DB, path string) (*ReplicationStatus, error) {
+	var masterKey string
+	if err := db.QueryRow("SELECT value FROM system_config WHERE key = 'master_key'").Scan(&masterKey); err != nil {
+		return nil, fmt.Errorf("failed to get master key: %w", err)
+	}
+	serverName, err := sync.GetServerName(db)
+	if err != nil {
+		return nil, err
+	}
+	allPeers, err := peers.GetAll(db)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &ReplicationStatus{
+		At:       time.Now(),
+		Filename: filepath.Base(path),
+		Pushed:   []string{},
+		Failed:   map[string]string{},
+	}
+	for _, p := range allPeers {
+		if !p.Enabled {
+			continue
+		}
+		var password string
+		if p.Password != nil && len(*p.Password) > 0 {
+			if password, err = peers.DecryptPeerPassword(p.Password, masterKey); err != nil {
+				status.Failed[p.Name] = "failed to decrypt peer password"
+				continue
+			}
+		}
+		if err := pushToPeer(p.Address, p.Port, password, serverName, path); err != nil {
+			logger.Warn("Server backup: failed to push to peer", "peer", p.Name, "error", err)
+			status.Failed[p.Name] = err.Error()
+			continue
+		}
+		status.Pushed = append(status.Pushed, p.Name)
+	}
+
+	logger.Info("Server backup pushed to peers", "filename", status.Filename, "pushed", len(status.Pushed), "failed", len(status.Failed))
+	if err := saveReplicationStatus(db, status); err != nil {
+		logger.Warn("Server backup: failed to record replication status", "error", err)
+	}
+	return status, nil
+}
+
+// peerClient returns an HTTPS client accepting the self-signed certificates of peers
+func peerClient(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}},
+		Timeout:   timeout,
+	}
+}
+
+// pushToPeer uploads a backup file to the server backup endpoint of a peer
+func pushToPeer(address string, port int, password, serverName, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read backup: %w", err)
+	}
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("file", filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	part.Write(data)
+	writer.Close()
+
+	uploadURL := fmt.Sprintf("https://%s:%d/api/sync/server-backup?source_server=%s", address, port, url.QueryEscape(serverName))
+	req, err := http.NewRequest(http.MethodPost, uploadURL, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	if password != "" {
+		req.Header.Set("X-Sync-Password", password)
+	}
+
+	resp, err := peerClient(2 * time.Minute).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("peer returned %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// saveReplicationStatus records the result of the last push to the peers
+func saveReplicationStatus(db *sql.DB, status *ReplicationStatus) error {
+	data, err := json.Marshal(status)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`INSERT INTO system_config (key, value, updated_at)
+		VALUES ('server_backup_replication', ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(key) DO UPDATE SET value = excluded.value, updated_at = excluded.updated_at`, string(data))
+	return err
+}
+
+// GetReplicationStatus returns the result of the last push to the peers, or nil if none
+func GetReplicationStatus(db *sql.DB) (*ReplicationStatus, error) {
+	var data string
+	err := db.QueryRow("SELECT value FROM system_config WHERE key = 'server_backup_replication'").Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get replication status: %w", err)
+	}
+	var status ReplicationStatus
+	if err := json.Unmarshal([]byte(data), &status); err != nil {
+		return nil, fmt.Errorf("failed to parse replication status: %w", err)
+	}
+	return &status, nil
+}
+
+// peerBackupDir returns the directory holding the backups received from a server
+func peerBackupDir(incomingDir, sourceServer string) (string, error) {
+	if sourceServer == "" || sourceServer == "." || sourceServer == ".." || strings.ContainsAny(sourceServer, "/\\") {
+		return "", fmt.Errorf("invalid source server")
+	}
+	return filepath.Join(incomingDir, sourceServer, PeerDir), nil
+}
+
+// StorePeerBackup stores a backup received from another server, keeping its MaxBackups newest ones
+func StorePeerBackup(incomingDir, sourceServer, filename string, r io.Reader) error {
+	if !ValidBackupName(filename) {
+		return fmt.Errorf("invalid backup filename")
+	}
+	dir, err := peerBackupDir(incomingDir, sourceServer)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(dir, ".upload-*")
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(dir, filename)); err != nil {
+		return fmt.Errorf("failed to store file: %w", err)
+	}
+
+	return CleanOldBackups(dir, MaxBackups)
+}
+
+// ListPeerBackups lists the backups received from other servers, newest first
+func ListPeerBackups(incomingDir string) ([]PeerBackup, error) {
+	entries, err := os.ReadDir(incomingDir)
+	if os.IsNotExist(err) {
+		return []PeerBackup{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read incoming directory: %w", err)
+	}
+
+	result := []PeerBackup{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		files, err := ListBackups(filepath.Join(incomingDir, entry.Name(), PeerDir))
+		if err != nil {
+			continue
+		}
+		for _, f := range files {
+			if !ValidBackupName(f.Filename) {
+				continue
+			}
+			result = append(result, PeerBackup{
+				SourceServer: entry.Name(),
+				Filename:     f.Filename,
+				Size:         f.Size,
+				CreatedAt:    f.CreatedAt,
+			})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].CreatedAt.After(result[j].CreatedAt) })
+	return result, nil
+}
+
+// PeerBackupPath returns the path of a backup received from another server
+func PeerBackupPath(incomingDir, sourceServer, filename string) (string, error) {
+	if !ValidBackupName(filename) {
+		return "", fmt.Errorf("invalid backup filename")
+	}
+	dir, err := peerBackupDir(incomingDir, sourceServer)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, filename), nil
+}
+
+// ListOnPeer lists the server backups stored on a peer, authenticating with its sync password
+func ListOnPeer(address string, port int, password string) ([]PeerBackup, error) {
+	resp, err := peerRequest(address, port, password, "")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var backups []PeerBackup
+	if err := json.NewDecoder(resp.Body).Decode(&backups); err != nil {
+		return nil, fmt.Errorf("invalid response from peer: %w", err)
+	}
+	return backups, nil
+}
+
+// FetchFromPeer downloads a server backup stored on a peer
+func FetchFromPeer(address string, port int, password, sourceServer, filename string) ([]byte, error) {
+	query := url.Values{"source_server": {sourceServer}, "file": {filename}}
+	resp, err := peerRequest(address, port, password, query.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 64<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to download backup: %w", err)
+	}
+	return data, nil
+}
+
+// peerRequest sends a GET request to the server backup endpoint of a peer
+func peerRequest(address string, port int, password, query string) (*http.Response, error) {
+	if err := peers.ValidatePeerAddress(address); err != nil {
+		return nil, err
+	}
+	if port <= 0 || port > 65535 {
+		return nil, fmt.Errorf("invalid port")
+	}
+
+	reqURL := fmt.Sprintf("https://%s:%d/api/sync/server-backup", address, port)
+	if query != "" {
+		reqURL += "?" + query
+	}
+	req, err := http.NewRequest(http.MethodGet, reqURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if password != "" {
+		req.Header.Set("X-Sync-Password", password)
+	}
+
+	resp, err := peerClient(time.Minute).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("connection failed: %w", err)
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp, nil
+	case http.StatusUnauthorized, http.StatusForbidden:
+		resp.Body.Close()
+		return nil, ErrPeerAuth
+	default:
+		resp.Body.Close()
+		return nil, fmt.Errorf("peer returned status %d", resp.StatusCode)
+	}
+}
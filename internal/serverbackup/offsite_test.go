@@ -0,0 +1,110 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+package serverbackup
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/juste-un-gars/anemone/internal/backup"
+)
+
+func TestValidBackupName(t *testing.T) {
+	valid := []string{"backup_20260314_040000.enc"}
+	invalid := []string{"", "backup.enc", "backup_20260314_040000.enc.tmp", "../backup_20260314_040000.enc", "anemone_backup_20260314_040000.enc"}
+
+	for _, name := range valid {
+		if !ValidBackupName(name) {
+			t.Errorf("ValidBackupName(%q) = false, want true", name)
+		}
+	}
+	for _, name := range invalid {
+		if ValidBackupName(name) {
+			t.Errorf("ValidBackupName(%q) = true, want false", name)
+		}
+	}
+}
+
+func TestStorePeerBackup(t *testing.T) {
+	incomingDir := t.TempDir()
+
+	if err := StorePeerBackup(incomingDir, "../etc", "backup_20260314_040000.enc", strings.NewReader("x")); err == nil {
+		t.Error("expected an error for an invalid source server")
+	}
+	if err := StorePeerBackup(incomingDir, "nas1", "passwd", strings.NewReader("x")); err == nil {
+		t.Error("expected an error for an invalid filename")
+	}
+
+	// Store more backups than kept, with increasing modification times
+	base := time.Date(2026, 3, 1, 4, 0, 0, 0, time.UTC)
+	for i := 0; i < MaxBackups+2; i++ {
+		name := fmt.Sprintf("backup_202603%02d_040000.enc", i+1)
+		if err := StorePeerBackup(incomingDir, "nas1", name, strings.NewReader("data")); err != nil {
+			t.Fatalf("StorePeerBackup: %v", err)
+		}
+		path := filepath.Join(incomingDir, "nas1", PeerDir, name)
+		if err := os.Chtimes(path, base.AddDate(0, 0, i), base.AddDate(0, 0, i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Each store keeps the MaxBackups newest backups, the one being stored included
+	if err := StorePeerBackup(incomingDir, "nas1", "backup_20260320_040000.enc", strings.NewReader("data")); err != nil {
+		t.Fatalf("StorePeerBackup: %v", err)
+	}
+
+	backups, err := ListPeerBackups(incomingDir)
+	if err != nil {
+		t.Fatalf("ListPeerBackups: %v", err)
+	}
+	if len(backups) != MaxBackups {
+		t.Fatalf("got %d backups, want %d", len(backups), MaxBackups)
+	}
+	if backups[0].Filename != "backup_20260320_040000.enc" || backups[0].SourceServer != "nas1" {
+		t.Errorf("newest backup = %+v", backups[0])
+	}
+	for _, b := range backups {
+		if b.Filename == "backup_20260301_040000.enc" || b.Filename == "backup_20260302_040000.enc" {
+			t.Errorf("old backup %s was not cleaned", b.Filename)
+		}
+	}
+
+	if _, err := PeerBackupPath(incomingDir, "nas1", "../../anemone.db"); err == nil {
+		t.Error("expected an error for an invalid filename")
+	}
+}
+
+func TestOffsiteCopyEncryption(t *testing.T) {
+	serverBackup := &backup.ServerBackup{Version: "1.0", ServerName: "nas1"}
+
+	data, err := backup.EncryptBackupWithPassphrase(serverBackup, "correct horse battery")
+	if err != nil {
+		t.Fatalf("EncryptBackupWithPassphrase: %v", err)
+	}
+	if !strings.Contains(string(data), `"algorithm":"argon2id"`) || strings.Contains(string(data), "nas1") {
+		t.Errorf("unexpected offsite copy %s", data)
+	}
+
+	// Peers and the setup wizard decrypt offsite copies with DecryptBackup
+	got, err := backup.DecryptBackup(data, "correct horse battery")
+	if err != nil || got.ServerName != "nas1" {
+		t.Fatalf("DecryptBackup = %+v, %v", got, err)
+	}
+	if _, err := backup.DecryptBackup(data, "wrong horse battery"); err == nil {
+		t.Error("DecryptBackup accepted a wrong passphrase")
+	}
+
+	// Backups in the former format still decrypt
+	legacy, err := backup.EncryptBackup(serverBackup, "correct horse battery")
+	if err != nil {
+		t.Fatalf("EncryptBackup: %v", err)
+	}
+	if got, err := backup.DecryptBackup(legacy, "correct horse battery"); err != nil || got.ServerName != "nas1" {
+		t.Errorf("DecryptBackup of a legacy backup = %+v, %v", got, err)
+	}
+}
@@ -29,7 +29,8 @@ type BackupFile struct {
 	CreatedAt time.Time
 }
 
-// CreateServerBackup creates a server backup encrypted with master key
+// CreateServerBackup creates a server backup encrypted with master key. When a recovery
+// passphrase is set, a copy encrypted with it is also written and pushed to the peers.
 func CreateServerBackup(db *sql.DB, backupDir string) (string, error) {
 	// Ensure backup directory exists
 	if err := os.MkdirAll(backupDir, 0700); err != nil {
@@ -67,6 +68,18 @@ func CreateServerBackup(db *sql.DB, backupDir string) (string, error) {
 
 	logger.Info("Server backup created", "filename", filename, "size", len(encryptedData))
 
+	// Write the copy encrypted with the recovery passphrase and push it to the peers
+	offsitePath, err := writeOffsiteCopy(db, serverBackup, backupDir, filename, masterKey)
+	if err != nil {
+		logger.Warn("Server backup: failed to write offsite copy", "error", err)
+	} else if offsitePath != "" {
+		go func() {
+			if _, err := ReplicateToPeers(db, offsitePath); err != nil {
+				logger.Warn("Server backup: replication to peers failed", "error", err)
+			}
+		}()
+	}
+
 	// Clean old backups
 	if err := CleanOldBackups(backupDir, MaxBackups); err != nil {
 		logger.Info("Warning: failed to clean old backups", "error", err)
@@ -29,6 +29,7 @@ type RestoreResult struct {
 	PeersCount  int               `json:"peers_count"`
 	Users       []RestoreUser     `json:"users"`
 	Peers       []RestorePeer     `json:"peers"`
+	Clouds      []RestoreCloud    `json:"clouds"`
 	Error       string            `json:"error,omitempty"`
 }
 
@@ -46,6 +47,13 @@ type RestorePeer struct {
 	Port    int    `json:"port"`
 }
 
+// RestoreCloud is a simplified cloud destination for display
+type RestoreCloud struct {
+	ID           int    `json:"id"`
+	Name         string `json:"name"`
+	ProviderType string `json:"provider_type"`
+}
+
 // ValidateBackup validates and decrypts a backup file
 func ValidateBackup(encryptedData []byte, passphrase string) (*RestoreResult, *backup.ServerBackup, error) {
 	result := &RestoreResult{}
@@ -58,6 +66,13 @@ func ValidateBackup(encryptedData []byte, passphrase string) (*RestoreResult, *b
 		return result, nil, fmt.Errorf("failed to decrypt backup: %w", err)
 	}
 
+	return describeBackup(serverBackup), serverBackup, nil
+}
+
+// describeBackup summarizes a decrypted backup for display
+func describeBackup(serverBackup *backup.ServerBackup) *RestoreResult {
+	result := &RestoreResult{}
+
 	// Populate result
 	result.Valid = true
 	result.ServerName = serverBackup.ServerName
@@ -85,7 +100,16 @@ func ValidateBackup(encryptedData []byte, passphrase string) (*RestoreResult, *b
 		})
 	}
 
-	return result, serverBackup, nil
+	// Extract cloud destinations (user data can be pulled back from them)
+	for _, c := range serverBackup.RcloneBackups {
+		result.Clouds = append(result.Clouds, RestoreCloud{
+			ID:           c.ID,
+			Name:         c.Name,
+			ProviderType: c.ProviderType,
+		})
+	}
+
+	return result
 }
 
 // RestoreOptions contains options for restoring a backup
@@ -163,6 +187,11 @@ func ExecuteRestore(serverBackup *backup.ServerBackup, opts RestoreOptions) erro
 		return fmt.Errorf("failed to restore peers: %w", err)
 	}
 
+	// Restore per-share peer selection (needs both shares and peers)
+	if err := restoreSharePeers(tx, serverBackup.Shares); err != nil {
+		return fmt.Errorf("failed to restore share peers: %w", err)
+	}
+
 	// 5. Restore sync_config
 	if serverBackup.SyncConfig != nil {
 		if err := restoreSyncConfig(tx, serverBackup.SyncConfig); err != nil {
@@ -177,6 +206,11 @@ func ExecuteRestore(serverBackup *backup.ServerBackup, opts RestoreOptions) erro
 		}
 	}
 
+	// 7. Restore rclone_backups
+	if err := restoreRcloneBackups(tx, serverBackup.RcloneBackups); err != nil {
+		return fmt.Errorf("failed to restore cloud destinations: %w", err)
+	}
+
 	// Commit transaction
 	if err := tx.Commit(); err != nil {
 		return fmt.Errorf("failed to commit transaction: %w", err)
@@ -339,20 +373,12 @@ func restoreUsers(tx *sql.Tx, users []backup.UserBackup) error {
 func restoreShares(tx *sql.Tx, shares []backup.ShareBackup, sharesDir string) error {
 	for _, s := range shares {
 		// Update path if sharesDir is different
-		path := s.Path
-		if sharesDir != "" {
-			// Extract relative path and rebuild with new sharesDir
-			// Original path format: /srv/anemone/shares/username/sharename
-			// We want to replace the base with new sharesDir
-			baseName := filepath.Base(filepath.Dir(s.Path)) // username
-			shareName := filepath.Base(s.Path)              // sharename
-			path = filepath.Join(sharesDir, baseName, shareName)
-		}
+		path := RestoredSharePath(s.Path, sharesDir)
 
 		_, err := tx.Exec(
-			`INSERT INTO shares (id, user_id, name, path, protocol, sync_enabled, created_at)
-			VALUES (?, ?, ?, ?, ?, ?, ?)`,
-			s.ID, s.UserID, s.Name, path, s.Protocol, s.SyncEnabled, s.CreatedAt,
+			`INSERT INTO shares (id, user_id, name, path, protocol, sync_enabled, min_replicas, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			s.ID, s.UserID, s.Name, path, s.Protocol, s.SyncEnabled, s.MinReplicas, s.CreatedAt,
 		)
 		if err != nil {
 			return fmt.Errorf("failed to restore share %s: %w", s.Name, err)
@@ -398,6 +424,21 @@ func restorePeers(tx *sql.Tx, peers []backup.PeerBackup, masterKey string) error
 	return nil
 }
 
+func restoreSharePeers(tx *sql.Tx, shares []backup.ShareBackup) error {
+	for _, s := range shares {
+		for _, peerID := range s.PeerIDs {
+			_, err := tx.Exec(
+				`INSERT OR IGNORE INTO share_peers (share_id, peer_id) VALUES (?, ?)`,
+				s.ID, peerID,
+			)
+			if err != nil {
+				return fmt.Errorf("failed to restore peers for share %s: %w", s.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
 func restoreSyncConfig(tx *sql.Tx, cfg *backup.SyncConfig) error {
 	var lastSync interface{}
 	if cfg.LastSync != nil {
@@ -427,6 +468,25 @@ func restoreWireGuard(tx *sql.Tx, wg *backup.WireGuardBackup) error {
 	return nil
 }
 
+func restoreRcloneBackups(tx *sql.Tx, backups []backup.RcloneBackup) error {
+	for _, rb := range backups {
+		_, err := tx.Exec(
+			`INSERT INTO rclone_backups (id, name, provider_type, provider_config, sftp_host, sftp_port,
+				sftp_user, sftp_key_path, sftp_password, remote_path, enabled, sync_enabled, sync_frequency,
+				sync_time, sync_day_of_week, sync_day_of_month, sync_interval_minutes)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			rb.ID, rb.Name, rb.ProviderType, rb.ProviderConfig, rb.SFTPHost, rb.SFTPPort,
+			rb.SFTPUser, nullString(rb.SFTPKeyPath), nullString(rb.SFTPPassword), rb.RemotePath, rb.Enabled,
+			rb.SyncEnabled, rb.SyncFrequency, rb.SyncTime, nullInt(rb.SyncDayOfWeek), nullInt(rb.SyncDayOfMonth),
+			rb.SyncIntervalMinutes,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to restore rclone backup %s: %w", rb.Name, err)
+		}
+	}
+	return nil
+}
+
 // Helper functions
 func nullString(s string) interface{} {
 	if s == "" {
@@ -0,0 +1,69 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+// This file loads the configuration backup written on a USB drive so that
+// the setup wizard can restore a server from it.
+package setup
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/juste-un-gars/anemone/internal/backup"
+	"github.com/juste-un-gars/anemone/internal/database"
+	"github.com/juste-un-gars/anemone/internal/usbbackup"
+)
+
+// LoadUSBConfig decrypts the configuration backup of a USB drive with the drive key
+// and converts its database into a server backup, like ValidateBackup does for an
+// uploaded file.
+func LoadUSBConfig(backupPath, key string) (*RestoreResult, *backup.ServerBackup, error) {
+	tmpDir, err := os.MkdirTemp("", "anemone-usb-restore-")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	if _, err := usbbackup.RestoreConfig(backupPath, key, tmpDir); err != nil {
+		return &RestoreResult{Error: "invalid_key"}, nil, err
+	}
+
+	db, err := database.Init(filepath.Join(tmpDir, "anemone.db"))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open configuration database: %w", err)
+	}
+	defer db.Close()
+
+	// The drive may hold a database from an older release
+	if err := database.Migrate(db); err != nil {
+		return nil, nil, fmt.Errorf("failed to migrate configuration database: %w", err)
+	}
+
+	serverName := "Anemone Server"
+	if info, err := usbbackup.InspectBackup(backupPath, key); err == nil {
+		if info.Config != nil && info.Config.SourceServer != "" {
+			serverName = info.Config.SourceServer
+		} else if info.Header != nil && info.Header.Server.Name != "" {
+			serverName = info.Header.Server.Name
+		}
+	}
+
+	serverBackup, err := backup.ExportConfiguration(db, serverName)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read configuration database: %w", err)
+	}
+	return describeBackup(serverBackup), serverBackup, nil
+}
+
+// RestoredSharePath returns the path of a share once restored under sharesDir.
+// Original path format: /srv/anemone/shares/username/sharename
+func RestoredSharePath(oldPath, sharesDir string) string {
+	if sharesDir == "" {
+		return oldPath
+	}
+	baseName := filepath.Base(filepath.Dir(oldPath)) // username
+	shareName := filepath.Base(oldPath)              // sharename
+	return filepath.Join(sharesDir, baseName, shareName)
+}
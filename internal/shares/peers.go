@@ -0,0 +1,108 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+// This file manages the share-to-peer mapping used to select sync destinations.
+
+package shares
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// PeerMap maps a share ID to the set of peer IDs it is restricted to.
+// Shares without an entry are sent to every enabled peer. Only existing peers
+// are loaded: a selection left with deleted peers only is no selection.
+type PeerMap map[int]map[int]bool
+
+// Targets reports whether the share should be synchronized to the peer
+func (m PeerMap) Targets(shareID, peerID int) bool {
+	selected, ok := m[shareID]
+	if !ok || len(selected) == 0 {
+		return true
+	}
+	return selected[peerID]
+}
+
+// GetPeerMap loads the share-to-peer mapping for all shares
+func GetPeerMap(db *sql.DB) (PeerMap, error) {
+	rows, err := db.Query(`SELECT sp.share_id, sp.peer_id FROM share_peers sp
+	                       JOIN peers p ON p.id = sp.peer_id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query share peers: %w", err)
+	}
+	defer rows.Close()
+
+	m := make(PeerMap)
+	for rows.Next() {
+		var shareID, peerID int
+		if err := rows.Scan(&shareID, &peerID); err != nil {
+			return nil, fmt.Errorf("failed to scan share peer: %w", err)
+		}
+		if m[shareID] == nil {
+			m[shareID] = make(map[int]bool)
+		}
+		m[shareID][peerID] = true
+	}
+	return m, nil
+}
+
+// GetPeerIDs returns the existing peer IDs a share is restricted to.
+// An empty result means the share is sent to every enabled peer.
+func GetPeerIDs(db *sql.DB, shareID int) ([]int, error) {
+	rows, err := db.Query(`SELECT sp.peer_id FROM share_peers sp
+	                       JOIN peers p ON p.id = sp.peer_id
+	                       WHERE sp.share_id = ? ORDER BY sp.peer_id`, shareID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query share peers: %w", err)
+	}
+	defer rows.Close()
+
+	var peerIDs []int
+	for rows.Next() {
+		var peerID int
+		if err := rows.Scan(&peerID); err != nil {
+			return nil, fmt.Errorf("failed to scan share peer: %w", err)
+		}
+		peerIDs = append(peerIDs, peerID)
+	}
+	return peerIDs, nil
+}
+
+// SetPeers replaces the peer selection of a share.
+// Passing an empty list restores the default (all enabled peers).
+func SetPeers(db *sql.DB, shareID int, peerIDs []int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM share_peers WHERE share_id = ?`, shareID); err != nil {
+		return fmt.Errorf("failed to clear share peers: %w", err)
+	}
+
+	for _, peerID := range peerIDs {
+		if _, err := tx.Exec(`INSERT OR IGNORE INTO share_peers (share_id, peer_id, created_at)
+		                      VALUES (?, ?, CURRENT_TIMESTAMP)`, shareID, peerID); err != nil {
+			return fmt.Errorf("failed to add share peer: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit share peers: %w", err)
+	}
+	return nil
+}
+
+// UpdateMinReplicas sets the replication factor of a share (0 = no requirement)
+func UpdateMinReplicas(db *sql.DB, shareID, minReplicas int) error {
+	if minReplicas < 0 {
+		return fmt.Errorf("replication factor cannot be negative")
+	}
+	if _, err := db.Exec(`UPDATE shares SET min_replicas = ? WHERE id = ?`, minReplicas, shareID); err != nil {
+		return fmt.Errorf("failed to update replication factor: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+package shares
+
+import (
+	"database/sql"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestPeerMapIgnoresDeletedPeers(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	defer db.Close()
+
+	// Foreign keys are off: the rows of deleted peers stay in share_peers
+	_, err = db.Exec(`
+		CREATE TABLE peers (id INTEGER PRIMARY KEY);
+		CREATE TABLE share_peers (share_id INTEGER, peer_id INTEGER, created_at DATETIME);
+		INSERT INTO peers (id) VALUES (1), (2);
+		INSERT INTO share_peers (share_id, peer_id) VALUES (10, 1), (20, 3);`)
+	if err != nil {
+		t.Fatalf("Failed to create tables: %v", err)
+	}
+
+	m, err := GetPeerMap(db)
+	if err != nil {
+		t.Fatalf("GetPeerMap failed: %v", err)
+	}
+	if !m.Targets(10, 1) || m.Targets(10, 2) {
+		t.Error("share 10 should only target peer 1")
+	}
+	if !m.Targets(20, 1) || !m.Targets(20, 2) {
+		t.Error("a selection of deleted peers only should target every peer")
+	}
+
+	if ids, err := GetPeerIDs(db, 20); err != nil || len(ids) != 0 {
+		t.Errorf("GetPeerIDs = %v, %v", ids, err)
+	}
+}
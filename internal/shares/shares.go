@@ -25,6 +25,7 @@ type Share struct {
 	Path        string
 	Protocol    string // "smb", "nfs", etc.
 	SyncEnabled bool
+	MinReplicas int // Minimum number of peers holding a copy (0 = no requirement)
 	CreatedAt   time.Time
 }
 
@@ -59,9 +60,9 @@ func Create(db *sql.DB, share *Share, username string) error {
 		}
 	}
 
-	query := `INSERT INTO shares (user_id, name, path, protocol, sync_enabled, created_at)
-	          VALUES (?, ?, ?, ?, ?, CURRENT_TIMESTAMP)`
-	result, err := db.Exec(query, share.UserID, share.Name, share.Path, share.Protocol, share.SyncEnabled)
+	query := `INSERT INTO shares (user_id, name, path, protocol, sync_enabled, min_replicas, created_at)
+	          VALUES (?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)`
+	result, err := db.Exec(query, share.UserID, share.Name, share.Path, share.Protocol, share.SyncEnabled, share.MinReplicas)
 	if err != nil {
 		return fmt.Errorf("failed to create share: %w", err)
 	}
@@ -126,11 +127,11 @@ func configureSELinux(sharePath string) error {
 // GetByID retrieves a share by its ID
 func GetByID(db *sql.DB, id int) (*Share, error) {
 	share := &Share{}
-	query := `SELECT id, user_id, name, path, protocol, sync_enabled, created_at
+	query := `SELECT id, user_id, name, path, protocol, sync_enabled, min_replicas, created_at
 	          FROM shares WHERE id = ?`
 	err := db.QueryRow(query, id).Scan(
 		&share.ID, &share.UserID, &share.Name, &share.Path,
-		&share.Protocol, &share.SyncEnabled, &share.CreatedAt,
+		&share.Protocol, &share.SyncEnabled, &share.MinReplicas, &share.CreatedAt,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -143,7 +144,7 @@ func GetByID(db *sql.DB, id int) (*Share, error) {
 
 // GetByUser retrieves all shares for a specific user
 func GetByUser(db *sql.DB, userID int) ([]*Share, error) {
-	query := `SELECT id, user_id, name, path, protocol, sync_enabled, created_at
+	query := `SELECT id, user_id, name, path, protocol, sync_enabled, min_replicas, created_at
 	          FROM shares WHERE user_id = ? ORDER BY created_at DESC`
 	rows, err := db.Query(query, userID)
 	if err != nil {
@@ -156,7 +157,7 @@ func GetByUser(db *sql.DB, userID int) ([]*Share, error) {
 		share := &Share{}
 		err := rows.Scan(
 			&share.ID, &share.UserID, &share.Name, &share.Path,
-			&share.Protocol, &share.SyncEnabled, &share.CreatedAt,
+			&share.Protocol, &share.SyncEnabled, &share.MinReplicas, &share.CreatedAt,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan share: %w", err)
@@ -168,7 +169,7 @@ func GetByUser(db *sql.DB, userID int) ([]*Share, error) {
 
 // GetAll retrieves all shares (admin function)
 func GetAll(db *sql.DB) ([]*Share, error) {
-	query := `SELECT id, user_id, name, path, protocol, sync_enabled, created_at
+	query := `SELECT id, user_id, name, path, protocol, sync_enabled, min_replicas, created_at
 	          FROM shares ORDER BY created_at DESC`
 	rows, err := db.Query(query)
 	if err != nil {
@@ -181,7 +182,7 @@ func GetAll(db *sql.DB) ([]*Share, error) {
 		share := &Share{}
 		err := rows.Scan(
 			&share.ID, &share.UserID, &share.Name, &share.Path,
-			&share.Protocol, &share.SyncEnabled, &share.CreatedAt,
+			&share.Protocol, &share.SyncEnabled, &share.MinReplicas, &share.CreatedAt,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan share: %w", err)
@@ -193,9 +194,9 @@ func GetAll(db *sql.DB) ([]*Share, error) {
 
 // Update updates a share
 func Update(db *sql.DB, share *Share) error {
-	query := `UPDATE shares SET name = ?, path = ?, protocol = ?, sync_enabled = ?
+	query := `UPDATE shares SET name = ?, path = ?, protocol = ?, sync_enabled = ?, min_replicas = ?
 	          WHERE id = ?`
-	_, err := db.Exec(query, share.Name, share.Path, share.Protocol, share.SyncEnabled, share.ID)
+	_, err := db.Exec(query, share.Name, share.Path, share.Protocol, share.SyncEnabled, share.MinReplicas, share.ID)
 	if err != nil {
 		return fmt.Errorf("failed to update share: %w", err)
 	}
@@ -0,0 +1,104 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+// This file compares successive samples of a disk and records the alerts they
+// raise in the shared alert store.
+
+package smartmon
+
+import (
+	"database/sql"
+	"strconv"
+
+	"github.com/juste-un-gars/anemone/internal/alerts"
+	"github.com/juste-un-gars/anemone/internal/logger"
+	"github.com/juste-un-gars/anemone/internal/storage"
+)
+
+// Alert severities
+const (
+	SeverityWarning  = alerts.SeverityWarning
+	SeverityCritical = alerts.SeverityCritical
+)
+
+// Alert kinds
+const (
+	KindHealth      = "health"      // Health dropped from OldValue to NewValue
+	KindAttribute   = "attribute"   // Error counter Attribute grew from OldValue to NewValue
+	KindTemperature = "temperature" // Temperature reached NewValue, threshold OldValue
+	KindWear        = "wear"        // SSD wear level reached NewValue percent
+	KindSelfTest    = "selftest"    // Self-test of type Attribute failed with status NewValue
+)
+
+// wearWarning is the SSD wear level in percent raising an alert
+const wearWarning = 90
+
+// Alert is an event of a disk worth the attention of an admin. Its subject is
+// the key of the disk and its label the model.
+type Alert = alerts.Alert
+
+// healthRank orders the health states from the best to the worst
+func healthRank(h storage.HealthStatus) int {
+	switch h {
+	case storage.HealthOK:
+		return 1
+	case storage.HealthWarning:
+		return 2
+	case storage.HealthCritical:
+		return 3
+	}
+	return 0
+}
+
+// compareSamples returns the alerts raised by the change from prev to cur
+func compareSamples(prev, cur Sample, tempWarning int) []Alert {
+	var raised []Alert
+
+	if healthRank(prev.Health) > 0 && healthRank(cur.Health) > healthRank(prev.Health) {
+		severity := SeverityWarning
+		if cur.Health == storage.HealthCritical {
+			severity = SeverityCritical
+		}
+		raised = append(raised, Alert{Severity: severity, Kind: KindHealth, OldValue: string(prev.Health), NewValue: string(cur.Health)})
+	}
+
+	counters := []struct {
+		name     string
+		old, new int
+		severity string
+	}{
+		{"reallocated_sectors", prev.ReallocatedSectors, cur.ReallocatedSectors, SeverityWarning},
+		{"pending_sectors", prev.PendingSectors, cur.PendingSectors, SeverityCritical},
+		{"uncorrectable_sectors", prev.UncorrectableSectors, cur.UncorrectableSectors, SeverityCritical},
+		{"crc_errors", prev.CRCErrors, cur.CRCErrors, SeverityWarning},
+		{"media_errors", prev.MediaErrors, cur.MediaErrors, SeverityCritical},
+	}
+	for _, c := range counters {
+		if c.new > c.old {
+			raised = append(raised, Alert{Severity: c.severity, Kind: KindAttribute, Attribute: c.name,
+				OldValue: strconv.Itoa(c.old), NewValue: strconv.Itoa(c.new)})
+		}
+	}
+
+	// Thresholds only alert when crossed, not at every poll above them
+	if prev.Temperature < tempWarning && cur.Temperature >= tempWarning {
+		raised = append(raised, Alert{Severity: SeverityWarning, Kind: KindTemperature,
+			OldValue: strconv.Itoa(tempWarning), NewValue: strconv.Itoa(cur.Temperature)})
+	}
+	if prev.WearLevel < wearWarning && cur.WearLevel >= wearWarning {
+		raised = append(raised, Alert{Severity: SeverityWarning, Kind: KindWear, NewValue: strconv.Itoa(cur.WearLevel)})
+	}
+	return raised
+}
+
+// raiseAlert records an alert of a disk and logs it
+func raiseAlert(db *sql.DB, d storage.Disk, a Alert) {
+	a.Source, a.Subject, a.Device, a.Label = alerts.SourceSMART, DiskKey(d), d.Path, d.Model
+	logger.Warn("SMART monitor: Disk alert", "disk", d.Name, "serial", d.Serial, "severity", a.Severity,
+		"kind", a.Kind, "attribute", a.Attribute, "old", a.OldValue, "new", a.NewValue)
+
+	if err := alerts.Raise(db, a); err != nil {
+		logger.Warn("SMART monitor: Failed to record alert", "disk", d.Name, "error", err)
+	}
+}
@@ -0,0 +1,345 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+// Package smartmon monitors the SMART data of the disks in the background. It
+// records the key attributes of each disk in a time series, runs short and long
+// self-tests on a schedule and raises alerts when an error counter grows, the
+// temperature gets too high or the health of a disk drops.
+//
+// Disks are identified by their serial number, so that their history follows
+// them when device names change across reboots.
+package smartmon
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/juste-un-gars/anemone/internal/logger"
+	"github.com/juste-un-gars/anemone/internal/storage"
+)
+
+// checkInterval is how often the monitor checks whether a poll is due
+const checkInterval = 5 * time.Minute
+
+// retention is how long samples are kept
+const retention = 180 * 24 * time.Hour
+
+// Bounds of the settings
+const (
+	minInterval = 5
+	maxInterval = 1440
+	maxTestDays = 365
+)
+
+// Settings is the schedule and thresholds of the monitor
+type Settings struct {
+	IntervalMinutes int `json:"interval_minutes"` // Minutes between two polls
+	ShortTestDays   int `json:"short_test_days"`  // Days between short self-tests, 0 disables them
+	LongTestDays    int `json:"long_test_days"`   // Days between long self-tests, 0 disables them
+	TestHour        int `json:"test_hour"`        // Local hour scheduled self-tests start at
+	TempWarning     int `json:"temp_warning"`     // Temperature raising an alert, in Celsius
+}
+
+// DefaultSettings are the settings until an admin changes them
+var DefaultSettings = Settings{
+	IntervalMinutes: 30,
+	ShortTestDays:   7,
+	LongTestDays:    30,
+	TestHour:        3,
+	TempWarning:     55,
+}
+
+// settingKeys maps the system_config keys to the settings
+func settingKeys(s *Settings) map[string]*int {
+	return map[string]*int{
+		"smart_interval_minutes": &s.IntervalMinutes,
+		"smart_short_test_days":  &s.ShortTestDays,
+		"smart_long_test_days":   &s.LongTestDays,
+		"smart_test_hour":        &s.TestHour,
+		"smart_temp_warning":     &s.TempWarning,
+	}
+}
+
+// Validate checks the settings
+func (s *Settings) Validate() error {
+	if s.IntervalMinutes < minInterval || s.IntervalMinutes > maxInterval {
+		return fmt.Errorf("interval must be between %d and %d minutes", minInterval, maxInterval)
+	}
+	if s.ShortTestDays < 0 || s.ShortTestDays > maxTestDays || s.LongTestDays < 0 || s.LongTestDays > maxTestDays {
+		return fmt.Errorf("self-test periods must be between 0 and %d days", maxTestDays)
+	}
+	if s.TestHour < 0 || s.TestHour > 23 {
+		return fmt.Errorf("self-test hour must be between 0 and 23")
+	}
+	if s.TempWarning < 30 || s.TempWarning > 90 {
+		return fmt.Errorf("temperature threshold must be between 30 and 90°C")
+	}
+	return nil
+}
+
+// GetSettings returns the monitor settings, with defaults for those not set
+func GetSettings(db *sql.DB) (Settings, error) {
+	settings := DefaultSettings
+	for key, field := range settingKeys(&settings) {
+		var value string
+		err := db.QueryRow("SELECT value FROM system_config WHERE key = ?", key).Scan(&value)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return settings, fmt.Errorf("failed to get %s: %w", key, err)
+		}
+		if n, err := strconv.Atoi(value); err == nil {
+			*field = n
+		}
+	}
+	return settings, nil
+}
+
+// SetSettings saves the monitor settings
+func SetSettings(db *sql.DB, settings Settings) error {
+	if err := settings.Validate(); err != nil {
+		return err
+	}
+	for key, field := range settingKeys(&settings) {
+		_, err := db.Exec(`INSERT INTO system_config (key, value, updated_at)
+			VALUES (?, ?, CURRENT_TIMESTAMP)
+			ON CONFLICT(key) DO UPDATE SET value = excluded.value, updated_at = excluded.updated_at`, key, strconv.Itoa(*field))
+		if err != nil {
+			return fmt.Errorf("failed to set %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// Sample is the state of the key SMART attributes of a disk at a point in time
+type Sample struct {
+	Time                 time.Time            `json:"time"`
+	Health               storage.HealthStatus `json:"health"`
+	Temperature          int                  `json:"temperature"`
+	ReallocatedSectors   int                  `json:"reallocated_sectors"`
+	PendingSectors       int                  `json:"pending_sectors"`
+	UncorrectableSectors int                  `json:"uncorrectable_sectors"`
+	CRCErrors            int                  `json:"crc_errors"`
+	MediaErrors          int                  `json:"media_errors"`
+	WearLevel            int                  `json:"wear_level"` // -1 if not reported
+	PowerOnHours         int                  `json:"power_on_hours"`
+}
+
+// sampleOf returns the sample of SMART data read at t
+func sampleOf(info *storage.SMARTInfo, t time.Time) Sample {
+	return Sample{
+		Time:                 t.UTC(),
+		Health:               storage.GetDiskHealth(info),
+		Temperature:          info.Temperature,
+		ReallocatedSectors:   info.ReallocatedSectors,
+		PendingSectors:       info.PendingSectors,
+		UncorrectableSectors: info.UncorrectableSectors,
+		CRCErrors:            info.CRCErrors,
+		MediaErrors:          info.MediaErrors,
+		WearLevel:            info.WearLevel,
+		PowerOnHours:         info.PowerOnHours,
+	}
+}
+
+// DiskKey returns the identifier of a disk in the history: its serial number,
+// or its name when it has none
+func DiskKey(d storage.Disk) string {
+	if d.Serial != "" {
+		return d.Serial
+	}
+	return d.Name
+}
+
+const sampleColumns = `sampled_at, health, temperature, reallocated_sectors, pending_sectors,
+	uncorrectable_sectors, crc_errors, media_errors, wear_level, power_on_hours`
+
+// scanSample reads a row selected with sampleColumns
+func scanSample(scanner interface{ Scan(...interface{}) error }) (*Sample, error) {
+	var s Sample
+	var health string
+	err := scanner.Scan(&s.Time, &health, &s.Temperature, &s.ReallocatedSectors, &s.PendingSectors,
+		&s.UncorrectableSectors, &s.CRCErrors, &s.MediaErrors, &s.WearLevel, &s.PowerOnHours)
+	if err != nil {
+		return nil, err
+	}
+	s.Health = storage.HealthStatus(health)
+	return &s, nil
+}
+
+// saveSample records a sample of a disk
+func saveSample(db *sql.DB, disk, device string, s Sample) error {
+	_, err := db.Exec(`INSERT INTO smart_samples (disk, device, `+sampleColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		disk, device, s.Time, string(s.Health), s.Temperature, s.ReallocatedSectors, s.PendingSectors,
+		s.UncorrectableSectors, s.CRCErrors, s.MediaErrors, s.WearLevel, s.PowerOnHours)
+	if err != nil {
+		return fmt.Errorf("failed to save SMART sample: %w", err)
+	}
+	return nil
+}
+
+// lastSample returns the latest sample of a disk, or nil if it has none
+func lastSample(db *sql.DB, disk string) (*Sample, error) {
+	row := db.QueryRow(`SELECT `+sampleColumns+` FROM smart_samples
+		WHERE disk = ? ORDER BY sampled_at DESC LIMIT 1`, disk)
+	s, err := scanSample(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get last SMART sample: %w", err)
+	}
+	return s, nil
+}
+
+// GetHistory returns the samples of a disk taken since a time, oldest first
+func GetHistory(db *sql.DB, disk string, since time.Time) ([]Sample, error) {
+	rows, err := db.Query(`SELECT `+sampleColumns+` FROM smart_samples
+		WHERE disk = ? AND sampled_at >= ? ORDER BY sampled_at`, disk, since.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query SMART history: %w", err)
+	}
+	defer rows.Close()
+
+	samples := []Sample{}
+	for rows.Next() {
+		s, err := scanSample(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan SMART sample: %w", err)
+		}
+		samples = append(samples, *s)
+	}
+	return samples, rows.Err()
+}
+
+// pruneSamples deletes the samples older than the retention
+func pruneSamples(db *sql.DB, now time.Time) error {
+	if _, err := db.Exec("DELETE FROM smart_samples WHERE sampled_at < ?", now.Add(-retention).UTC()); err != nil {
+		return fmt.Errorf("failed to prune SMART samples: %w", err)
+	}
+	return nil
+}
+
+// pollMu serializes polls, which can also be requested from the web interface
+var pollMu sync.Mutex
+
+// Poll reads the SMART data of every disk, records it, raises alerts and starts
+// the self-tests that are due
+func Poll(db *sql.DB) error {
+	pollMu.Lock()
+	defer pollMu.Unlock()
+
+	settings, err := GetSettings(db)
+	if err != nil {
+		return err
+	}
+	disks, err := storage.ListDisks()
+	if err != nil {
+		return fmt.Errorf("failed to list disks: %w", err)
+	}
+
+	now := time.Now()
+	for _, d := range disks {
+		if err := pollDisk(db, d, settings, now); err != nil {
+			logger.Warn("SMART monitor: Failed to poll disk", "disk", d.Name, "error", err)
+		}
+	}
+	return pruneSamples(db, now)
+}
+
+// pollDisk records the SMART data of a disk and acts on it
+func pollDisk(db *sql.DB, d storage.Disk, settings Settings, now time.Time) error {
+	info, err := storage.GetSMARTInfo(d.Path)
+	if err != nil {
+		return err
+	}
+	if !info.Available {
+		return nil
+	}
+	key := DiskKey(d)
+	sample := sampleOf(info, now)
+
+	prev, err := lastSample(db, key)
+	if err != nil {
+		return err
+	}
+	if prev != nil {
+		for _, alert := range compareSamples(*prev, sample, settings.TempWarning) {
+			raiseAlert(db, d, alert)
+		}
+	}
+	if err := saveSample(db, key, d.Path, sample); err != nil {
+		return err
+	}
+
+	finished, err := finishSelfTests(db, key, info)
+	if err != nil {
+		return err
+	}
+	for _, test := range finished {
+		if test.Status == SelfTestFailed {
+			raiseAlert(db, d, Alert{Severity: SeverityCritical, Kind: KindSelfTest, Attribute: test.Type, NewValue: test.Result})
+		}
+	}
+
+	if info.SelfTestRunning {
+		return nil
+	}
+	lastShort, lastLong, err := lastSelfTests(db, key)
+	if err != nil {
+		return err
+	}
+	if testType := dueSelfTest(lastShort, lastLong, settings, now); testType != "" {
+		if _, err := StartSelfTest(db, d, testType, true); err != nil {
+			return err
+		}
+		logger.Info("SMART monitor: Started scheduled self-test", "disk", d.Name, "type", testType)
+	}
+	return nil
+}
+
+// lastPoll is when the scheduler last polled the disks
+var lastPoll time.Time
+
+// isDue reports whether a poll is due at now
+func isDue(last time.Time, settings Settings, now time.Time) bool {
+	return last.IsZero() || now.Sub(last) >= time.Duration(settings.IntervalMinutes)*time.Minute
+}
+
+// StartMonitor polls the disks in the background at the configured interval
+func StartMonitor(db *sql.DB) {
+	if !storage.IsSmartAvailable() {
+		logger.Info("SMART monitor not started: smartctl is not installed")
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(checkInterval)
+		defer ticker.Stop()
+
+		for {
+			<-ticker.C
+
+			settings, err := GetSettings(db)
+			if err != nil {
+				logger.Warn("SMART monitor: Failed to get settings", "error", err)
+				continue
+			}
+			now := time.Now()
+			if !isDue(lastPoll, settings, now) {
+				continue
+			}
+			lastPoll = now
+			if err := Poll(db); err != nil {
+				logger.Warn("SMART monitor: Poll failed", "error", err)
+			}
+		}
+	}()
+
+	logger.Info("✅ SMART monitor started (checks every 5 minutes)")
+}
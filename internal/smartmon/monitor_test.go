@@ -0,0 +1,140 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+package smartmon
+
+import (
+	"testing"
+	"time"
+
+	"github.com/juste-un-gars/anemone/internal/storage"
+)
+
+func TestCompareSamples(t *testing.T) {
+	prev := Sample{Health: storage.HealthOK, Temperature: 40, ReallocatedSectors: 2, WearLevel: -1}
+
+	if alerts := compareSamples(prev, prev, 55); len(alerts) != 0 {
+		t.Errorf("unchanged sample raised %+v", alerts)
+	}
+
+	cur := prev
+	cur.Health = storage.HealthCritical
+	cur.PendingSectors = 8
+	cur.ReallocatedSectors = 5
+	alerts := compareSamples(prev, cur, 55)
+	if len(alerts) != 3 {
+		t.Fatalf("compareSamples = %+v, want 3 alerts", alerts)
+	}
+	if alerts[0].Kind != KindHealth || alerts[0].Severity != SeverityCritical || alerts[0].NewValue != "CRITICAL" {
+		t.Errorf("health alert = %+v", alerts[0])
+	}
+	if alerts[1].Attribute != "reallocated_sectors" || alerts[1].OldValue != "2" || alerts[1].NewValue != "5" {
+		t.Errorf("reallocated sectors alert = %+v", alerts[1])
+	}
+	if alerts[2].Attribute != "pending_sectors" || alerts[2].Severity != SeverityCritical {
+		t.Errorf("pending sectors alert = %+v", alerts[2])
+	}
+
+	// Health back to OK and a counter reset are not alerts
+	if alerts := compareSamples(cur, prev, 55); len(alerts) != 0 {
+		t.Errorf("improving sample raised %+v", alerts)
+	}
+	// Nor is the first known health
+	unknown := prev
+	unknown.Health = storage.HealthUnknown
+	cur = prev
+	cur.Health = storage.HealthWarning
+	if alerts := compareSamples(unknown, cur, 55); len(alerts) != 0 {
+		t.Errorf("health from UNKNOWN raised %+v", alerts)
+	}
+}
+
+func TestCompareSamplesThresholds(t *testing.T) {
+	prev := Sample{Health: storage.HealthOK, Temperature: 50, WearLevel: 85}
+	hot := prev
+	hot.Temperature = 58
+	hot.WearLevel = 90
+
+	alerts := compareSamples(prev, hot, 55)
+	if len(alerts) != 2 || alerts[0].Kind != KindTemperature || alerts[1].Kind != KindWear {
+		t.Fatalf("compareSamples = %+v, want temperature and wear alerts", alerts)
+	}
+
+	// Staying above a threshold does not alert again
+	hotter := hot
+	hotter.Temperature = 60
+	hotter.WearLevel = 92
+	if alerts := compareSamples(hot, hotter, 55); len(alerts) != 0 {
+		t.Errorf("staying above thresholds raised %+v", alerts)
+	}
+}
+
+func TestDueSelfTest(t *testing.T) {
+	settings := Settings{ShortTestDays: 7, LongTestDays: 30, TestHour: 3}
+	at := func(day, hour, min int) time.Time {
+		return time.Date(2025, 3, day, hour, min, 0, 0, time.Local)
+	}
+	ptr := func(t time.Time) *time.Time { return &t }
+
+	tests := []struct {
+		name            string
+		lastShort, long *time.Time
+		now             time.Time
+		want            string
+	}{
+		{"never run", nil, nil, at(10, 3, 0), storage.SelfTestLong},
+		{"outside the hour", nil, nil, at(10, 4, 0), ""},
+		{"short due", ptr(at(3, 3, 5)), ptr(at(1, 3, 0)), at(10, 3, 0), storage.SelfTestShort},
+		{"short due despite drift", ptr(at(3, 3, 30)), ptr(at(1, 3, 0)), at(10, 3, 0), storage.SelfTestShort},
+		{"nothing due", ptr(at(8, 3, 0)), ptr(at(1, 3, 0)), at(10, 3, 0), ""},
+		{"long replaces short", ptr(at(3, 3, 0)), ptr(at(1, 3, 0)), at(31, 3, 0), storage.SelfTestLong},
+	}
+	for _, tt := range tests {
+		if got := dueSelfTest(tt.lastShort, tt.long, settings, tt.now); got != tt.want {
+			t.Errorf("%s: dueSelfTest = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	disabled := Settings{TestHour: 3}
+	if got := dueSelfTest(nil, nil, disabled, at(10, 3, 0)); got != "" {
+		t.Errorf("disabled self-tests: dueSelfTest = %q", got)
+	}
+}
+
+func TestIsDue(t *testing.T) {
+	settings := Settings{IntervalMinutes: 30}
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	if !isDue(time.Time{}, settings, now) {
+		t.Error("first poll is not due")
+	}
+	if isDue(now.Add(-20*time.Minute), settings, now) {
+		t.Error("poll due before the interval")
+	}
+	if !isDue(now.Add(-30*time.Minute), settings, now) {
+		t.Error("poll not due after the interval")
+	}
+}
+
+func TestSettingsValidate(t *testing.T) {
+	valid := DefaultSettings
+	if err := valid.Validate(); err != nil {
+		t.Errorf("default settings are invalid: %v", err)
+	}
+
+	invalid := []func(s *Settings){
+		func(s *Settings) { s.IntervalMinutes = 1 },
+		func(s *Settings) { s.ShortTestDays = -1 },
+		func(s *Settings) { s.LongTestDays = 400 },
+		func(s *Settings) { s.TestHour = 24 },
+		func(s *Settings) { s.TempWarning = 120 },
+	}
+	for i, change := range invalid {
+		s := DefaultSettings
+		change(&s)
+		if err := s.Validate(); err == nil {
+			t.Errorf("case %d: invalid settings %+v accepted", i, s)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+// This file schedules the SMART self-tests and records their outcome, read from
+// the self-test log of the disk once it finished them.
+
+package smartmon
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/juste-un-gars/anemone/internal/storage"
+)
+
+// Self-test states
+const (
+	SelfTestRunning = "running"
+	SelfTestPassed  = "passed"
+	SelfTestFailed  = "failed"
+)
+
+// scheduleSlack absorbs the drift of the poll times, so that a test due every
+// day starts every day at the configured hour
+const scheduleSlack = 2 * time.Hour
+
+// SelfTest is a self-test started by Anemone
+type SelfTest struct {
+	ID         int        `json:"id"`
+	Disk       string     `json:"disk"`
+	Device     string     `json:"device"`
+	Type       string     `json:"type"` // storage.SelfTestShort or storage.SelfTestLong
+	Scheduled  bool       `json:"scheduled"`
+	Status     string     `json:"status"`
+	Result     string     `json:"result"` // Status reported by the disk
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at"`
+}
+
+// StartSelfTest starts a self-test on a disk and records it
+func StartSelfTest(db *sql.DB, d storage.Disk, testType string, scheduled bool) (*SelfTest, error) {
+	if err := storage.StartSelfTest(d.Path, testType); err != nil {
+		return nil, err
+	}
+	test := &SelfTest{Disk: DiskKey(d), Device: d.Path, Type: testType, Scheduled: scheduled,
+		Status: SelfTestRunning, StartedAt: time.Now().UTC()}
+	res, err := db.Exec(`INSERT INTO smart_selftests (disk, device, test_type, scheduled, status, started_at)
+		VALUES (?, ?, ?, ?, ?, ?)`, test.Disk, test.Device, test.Type, test.Scheduled, test.Status, test.StartedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record self-test: %w", err)
+	}
+	id, _ := res.LastInsertId()
+	test.ID = int(id)
+	return test, nil
+}
+
+// GetSelfTests returns the latest self-tests of a disk, newest first
+func GetSelfTests(db *sql.DB, disk string, limit int) ([]SelfTest, error) {
+	rows, err := db.Query(`SELECT id, disk, device, test_type, scheduled, status, result, started_at, finished_at
+		FROM smart_selftests WHERE disk = ? ORDER BY started_at DESC LIMIT ?`, disk, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query self-tests: %w", err)
+	}
+	defer rows.Close()
+
+	tests := []SelfTest{}
+	for rows.Next() {
+		var t SelfTest
+		var finished sql.NullTime
+		if err := rows.Scan(&t.ID, &t.Disk, &t.Device, &t.Type, &t.Scheduled, &t.Status, &t.Result,
+			&t.StartedAt, &finished); err != nil {
+			return nil, fmt.Errorf("failed to scan self-test: %w", err)
+		}
+		if finished.Valid {
+			t.FinishedAt = &finished.Time
+		}
+		tests = append(tests, t)
+	}
+	return tests, rows.Err()
+}
+
+// finishSelfTests records the outcome of the running self-tests of a disk that
+// no longer runs one, and returns them
+func finishSelfTests(db *sql.DB, disk string, info *storage.SMARTInfo) ([]SelfTest, error) {
+	if info.SelfTestRunning {
+		return nil, nil
+	}
+	tests, err := GetSelfTests(db, disk, 10)
+	if err != nil {
+		return nil, err
+	}
+
+	var finished []SelfTest
+	now := time.Now().UTC()
+	for _, t := range tests {
+		if t.Status != SelfTestRunning {
+			continue
+		}
+		t.Status, t.Result = selfTestOutcome(info.LastSelfTest)
+		t.FinishedAt = &now
+		if _, err := db.Exec("UPDATE smart_selftests SET status = ?, result = ?, finished_at = ? WHERE id = ?",
+			t.Status, t.Result, now, t.ID); err != nil {
+			return nil, fmt.Errorf("failed to record self-test result: %w", err)
+		}
+		finished = append(finished, t)
+	}
+	return finished, nil
+}
+
+// selfTestOutcome returns the state and result of the latest self-test of a disk
+func selfTestOutcome(last *storage.SelfTestResult) (string, string) {
+	if last == nil {
+		return SelfTestFailed, "no result in the self-test log"
+	}
+	if last.Passed {
+		return SelfTestPassed, last.Status
+	}
+	return SelfTestFailed, last.Status
+}
+
+// lastSelfTests returns when the last short and long self-tests of a disk started
+func lastSelfTests(db *sql.DB, disk string) (short, long *time.Time, err error) {
+	rows, err := db.Query(`SELECT test_type, started_at FROM smart_selftests
+		WHERE disk = ? ORDER BY started_at DESC LIMIT 50`, disk)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query self-tests: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var testType string
+		var started time.Time
+		if err := rows.Scan(&testType, &started); err != nil {
+			return nil, nil, fmt.Errorf("failed to scan self-test: %w", err)
+		}
+		switch {
+		case testType == storage.SelfTestShort && short == nil:
+			short = &started
+		case testType == storage.SelfTestLong && long == nil:
+			long = &started
+		}
+	}
+	return short, long, rows.Err()
+}
+
+// dueSelfTest returns the type of self-test to start at now, or "". Tests only
+// start during the configured hour, and a due long test replaces the short one.
+func dueSelfTest(lastShort, lastLong *time.Time, settings Settings, now time.Time) string {
+	if now.Hour() != settings.TestHour {
+		return ""
+	}
+	due := func(last *time.Time, days int) bool {
+		return days > 0 && (last == nil || now.Sub(*last) >= time.Duration(days)*24*time.Hour-scheduleSlack)
+	}
+	if due(lastLong, settings.LongTestDays) {
+		return storage.SelfTestLong
+	}
+	if due(lastShort, settings.ShortTestDays) {
+		return storage.SelfTestShort
+	}
+	return ""
+}
@@ -14,7 +14,10 @@ import (
 	"strings"
 	"text/template"
 
+	"github.com/juste-un-gars/anemone/internal/groups"
+	"github.com/juste-un-gars/anemone/internal/logger"
 	"github.com/juste-un-gars/anemone/internal/shares"
+	"github.com/juste-un-gars/anemone/internal/snapshots"
 )
 
 // Config holds SMB server configuration
@@ -70,7 +73,7 @@ const smbConfigTemplate = `[global]
    {{if $.DfreePath}}dfree command = {{$.DfreePath}}{{end}}
 
    # Recycle bin (trash) configuration
-   vfs objects = recycle
+   vfs objects = {{if .SnapDir}}shadow_copy2 {{end}}recycle
    recycle:repository = .trash/%U
    recycle:keeptree = yes
    recycle:versions = yes
@@ -78,14 +81,78 @@ const smbConfigTemplate = `[global]
    recycle:maxsize = 0
    recycle:exclude = *.tmp,*.temp,~$*
    recycle:exclude_dir = .trash,.Trash*
-{{end}}
+{{if .SnapDir}}
+   # Previous versions from scheduled snapshots (named <prefix>-<period>-<UTC time>)
+   shadow:mountpoint = {{.SnapMountpoint}}
+   shadow:snapdir = {{.SnapDir}}
+   shadow:snapprefix = ^[a-zA-Z0-9][a-zA-Z0-9_.]*-\(hourly\|daily\|weekly\|monthly\)$
+   shadow:delimiter = -20
+   shadow:format = -%Y%m%d-%H%M
+   shadow:localtime = no
+{{end}}{{end}}
+{{range .GroupShares}}
+[{{.Name}}]
+   comment = {{.GroupName}}
+   path = {{.Path}}
+   valid users = @{{.UnixGroup}}
+   read only = {{if .ReadOnly}}yes{{else}}no{{end}}
+{{- if .ReadList}}
+   read list = {{join .ReadList " "}}{{end}}
+{{- if .WriteList}}
+   write list = {{join .WriteList " "}}{{end}}
+   browseable = yes
+   hide dot files = yes
+   create mask = 0660
+   directory mask = 2770
+   force group = anemone
+   force create mode = 0660
+   force directory mode = 2770
+
+   # Quota enforcement via dfree
+   {{if $.DfreePath}}dfree command = {{$.DfreePath}}{{end}}
+
+   # Recycle bin (trash) of each member
+   vfs objects = {{if .SnapDir}}shadow_copy2 {{end}}recycle
+   recycle:repository = .trash/%U
+   recycle:keeptree = yes
+   recycle:versions = yes
+   recycle:touch = yes
+   recycle:maxsize = 0
+   recycle:exclude = *.tmp,*.temp,~$*
+   recycle:exclude_dir = .trash,.Trash*
+{{if .SnapDir}}
+   # Previous versions from scheduled snapshots (named <prefix>-<period>-<UTC time>)
+   shadow:mountpoint = {{.SnapMountpoint}}
+   shadow:snapdir = {{.SnapDir}}
+   shadow:snapprefix = ^[a-zA-Z0-9][a-zA-Z0-9_.]*-\(hourly\|daily\|weekly\|monthly\)$
+   shadow:delimiter = -20
+   shadow:format = -%Y%m%d-%H%M
+   shadow:localtime = no
+{{end}}{{end}}
 `
 
 // ShareConfig represents a share in the SMB configuration
 type ShareConfig struct {
-	Name     string
-	Username string
-	Path     string
+	Name           string
+	Username       string
+	Path           string
+	SnapMountpoint string // Root of the snapshotted volume, "" without snapshots
+	SnapDir        string // Directory holding the snapshots
+}
+
+// GroupShareConfig represents a group share in the SMB configuration. Members
+// connect as themselves; the read and write lists hold the members whose
+// access differs from the default one.
+type GroupShareConfig struct {
+	Name           string
+	GroupName      string
+	UnixGroup      string
+	Path           string
+	ReadOnly       bool // Default access of the members
+	ReadList       []string
+	WriteList      []string
+	SnapMountpoint string
+	SnapDir        string
 }
 
 // GenerateConfig generates the smb.conf file from database shares
@@ -112,28 +179,46 @@ func GenerateConfig(db *sql.DB, cfg *Config) error {
 			absPath = share.Path // Fallback to original if Abs fails
 		}
 
-		shareConfigs = append(shareConfigs, ShareConfig{
+		shareConfig := ShareConfig{
 			Name:     share.Name,
 			Username: username,
 			Path:     absPath,
-		})
+		}
+
+		// Expose scheduled snapshots as previous versions
+		source, err := snapshots.SourceFor(absPath)
+		if err != nil {
+			logger.Warn("SMB: Failed to find snapshots of share", "share", share.Name, "error", err)
+		} else if source != nil {
+			shareConfig.SnapMountpoint = source.Mountpoint
+			shareConfig.SnapDir = source.SnapDir
+		}
+
+		shareConfigs = append(shareConfigs, shareConfig)
+	}
+
+	groupShareConfigs, err := groupShareConfigs(db)
+	if err != nil {
+		return err
 	}
 
 	// Prepare template data
 	data := struct {
-		WorkGroup  string
-		ServerName string
-		DfreePath  string
-		Shares     []ShareConfig
+		WorkGroup   string
+		ServerName  string
+		DfreePath   string
+		Shares      []ShareConfig
+		GroupShares []GroupShareConfig
 	}{
-		WorkGroup:  cfg.WorkGroup,
-		ServerName: cfg.ServerName,
-		DfreePath:  cfg.DfreePath,
-		Shares:     shareConfigs,
+		WorkGroup:   cfg.WorkGroup,
+		ServerName:  cfg.ServerName,
+		DfreePath:   cfg.DfreePath,
+		Shares:      shareConfigs,
+		GroupShares: groupShareConfigs,
 	}
 
 	// Parse and execute template
-	tmpl, err := template.New("smb").Parse(smbConfigTemplate)
+	tmpl, err := template.New("smb").Funcs(template.FuncMap{"join": strings.Join}).Parse(smbConfigTemplate)
 	if err != nil {
 		return fmt.Errorf("failed to parse template: %w", err)
 	}
@@ -164,6 +249,57 @@ func GenerateConfig(db *sql.DB, cfg *Config) error {
 	return nil
 }
 
+// groupShareConfigs returns the configuration of the shares of the groups
+func groupShareConfigs(db *sql.DB) ([]GroupShareConfig, error) {
+	allShares, err := groups.GetAllShares(db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get group shares: %w", err)
+	}
+
+	configs := []GroupShareConfig{}
+	for _, share := range allShares {
+		access, err := groups.GetShareAccess(db, share)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get access to group share %s: %w", share.Name, err)
+		}
+
+		absPath, err := filepath.Abs(share.Path)
+		if err != nil {
+			absPath = share.Path
+		}
+
+		g := groups.Group{ID: share.GroupID}
+		config := GroupShareConfig{
+			Name:      share.Name,
+			GroupName: share.GroupName,
+			UnixGroup: g.UnixGroup(),
+			Path:      absPath,
+			ReadOnly:  share.DefaultAccess == groups.AccessRead,
+		}
+		for _, member := range access {
+			if !member.Override {
+				continue
+			}
+			if member.Access == groups.AccessRead {
+				config.ReadList = append(config.ReadList, member.Username)
+			} else {
+				config.WriteList = append(config.WriteList, member.Username)
+			}
+		}
+
+		source, err := snapshots.SourceFor(absPath)
+		if err != nil {
+			logger.Warn("SMB: Failed to find snapshots of group share", "share", share.Name, "error", err)
+		} else if source != nil {
+			config.SnapMountpoint = source.Mountpoint
+			config.SnapDir = source.SnapDir
+		}
+
+		configs = append(configs, config)
+	}
+	return configs, nil
+}
+
 // AddSMBUser adds a user to Samba (creates system user and SMB password)
 func AddSMBUser(username, password string) error {
 	// Check if user already exists
@@ -0,0 +1,176 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+// This file dispatches snapshot operations to ZFS or Btrfs. A volume is either a
+// ZFS dataset ("tank/shares") or the path of a Btrfs subvolume holding a share
+// ("/srv/anemone/shares/alice/backup"), and a snapshot is named volume@name in both
+// cases, so that the storage API and the policies work the same on both.
+
+package snapshots
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/juste-un-gars/anemone/internal/btrfs"
+	"github.com/juste-un-gars/anemone/internal/storage"
+)
+
+// IsBtrfsVolume reports whether a volume is a Btrfs subvolume path rather than a ZFS dataset
+func IsBtrfsVolume(volume string) bool {
+	return strings.HasPrefix(volume, "/")
+}
+
+// splitName splits a full snapshot name into its volume and snapshot name
+func splitName(name string) (string, string, error) {
+	i := strings.LastIndex(name, "@")
+	if i <= 0 || i == len(name)-1 {
+		return "", "", fmt.Errorf("snapshot name must be in format volume@snapshot")
+	}
+	return name[:i], name[i+1:], nil
+}
+
+// checkBtrfsVolume checks that a path is a share stored in a Btrfs subvolume.
+// Only shares can be snapshotted, so that the API cannot replace other subvolumes.
+func checkBtrfsVolume(db *sql.DB, path string) error {
+	if err := btrfs.ValidateSubvolumePath(path); err != nil {
+		return err
+	}
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM shares WHERE path = ?", path).Scan(&count); err != nil {
+		return fmt.Errorf("failed to look up share: %w", err)
+	}
+	if count == 0 {
+		return fmt.Errorf("%s is not a share", path)
+	}
+	if !btrfs.IsSubvolumeSudo(path) {
+		return fmt.Errorf("%s is not a Btrfs subvolume", path)
+	}
+	return nil
+}
+
+// CreateSnapshot takes a manual snapshot of a volume. Names following the scheme
+// of scheduled snapshots are refused, so that pruning never removes a manual snapshot.
+func CreateSnapshot(db *sql.DB, opts storage.SnapshotCreateOptions) error {
+	if IsScheduledName(opts.Name) {
+		return fmt.Errorf("this name is reserved for scheduled snapshots")
+	}
+	if IsBtrfsVolume(opts.Dataset) {
+		if err := checkBtrfsVolume(db, opts.Dataset); err != nil {
+			return err
+		}
+	}
+	return createSnapshot(opts)
+}
+
+// createSnapshot takes a snapshot without checking its name
+func createSnapshot(opts storage.SnapshotCreateOptions) error {
+	if IsBtrfsVolume(opts.Dataset) {
+		if opts.Recursive {
+			return fmt.Errorf("recursive snapshots are not supported on Btrfs")
+		}
+		return btrfs.CreateSnapshot(opts.Dataset, opts.Name)
+	}
+	return storage.CreateSnapshot(opts)
+}
+
+// DeleteSnapshot deletes a snapshot. recursive and force only apply to ZFS.
+func DeleteSnapshot(db *sql.DB, name string, recursive, force bool) error {
+	volume, snap, err := splitName(name)
+	if err != nil {
+		return err
+	}
+	if IsBtrfsVolume(volume) {
+		if err := checkBtrfsVolume(db, volume); err != nil {
+			return err
+		}
+		return btrfs.DeleteSnapshot(volume, snap)
+	}
+	return storage.DeleteSnapshot(name, recursive, force)
+}
+
+// Rollback returns a volume to the state of one of its snapshots. On ZFS the
+// options decide what happens to later snapshots; on Btrfs they are kept.
+func Rollback(db *sql.DB, opts storage.RollbackOptions) error {
+	volume, snap, err := splitName(opts.Snapshot)
+	if err != nil {
+		return err
+	}
+	if IsBtrfsVolume(volume) {
+		if err := checkBtrfsVolume(db, volume); err != nil {
+			return err
+		}
+		return btrfs.Rollback(volume, snap)
+	}
+	return storage.Rollback(opts)
+}
+
+// ListSnapshots returns the snapshots of a volume, or of all ZFS datasets and
+// Btrfs shares when volume is empty
+func ListSnapshots(db *sql.DB, volume string) ([]storage.Snapshot, error) {
+	if volume != "" {
+		if IsBtrfsVolume(volume) {
+			if err := checkBtrfsVolume(db, volume); err != nil {
+				return nil, err
+			}
+			return listBtrfsSnapshots(volume)
+		}
+		return storage.ListSnapshots(volume)
+	}
+
+	var all []storage.Snapshot
+	if storage.IsZFSAvailable() {
+		snaps, err := storage.ListAllSnapshots()
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, snaps...)
+	}
+	volumes, err := ListVolumes(db)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range volumes {
+		snaps, err := listBtrfsSnapshots(v.Path)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, snaps...)
+	}
+	return all, nil
+}
+
+// listBtrfsSnapshots returns the snapshots of a subvolume in the format of ZFS snapshots
+func listBtrfsSnapshots(subvolume string) ([]storage.Snapshot, error) {
+	snaps, err := btrfs.ListSnapshots(subvolume)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]storage.Snapshot, 0, len(snaps))
+	for _, s := range snaps {
+		created := s.Created.Local()
+		result = append(result, storage.Snapshot{
+			Name:         subvolume + "@" + s.Name,
+			Dataset:      subvolume,
+			SnapName:     s.Name,
+			Used:         s.Exclusive,
+			UsedHuman:    storage.FormatBytes(s.Exclusive),
+			Referenced:   s.Referenced,
+			RefHuman:     storage.FormatBytes(s.Referenced),
+			CreationTime: created,
+			CreationStr:  created.Format("2006-01-02 15:04:05"),
+		})
+	}
+	return result, nil
+}
+
+// heldSnapshots returns the held snapshots of a volume and its children.
+// Btrfs has no holds.
+func heldSnapshots(volume string) (map[string]bool, error) {
+	if IsBtrfsVolume(volume) {
+		return map[string]bool{}, nil
+	}
+	return storage.ListHeldSnapshots(volume)
+}
@@ -0,0 +1,267 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+// Package snapshots manages the snapshots of ZFS datasets and of shares stored in
+// Btrfs subvolumes, takes them on a schedule and prunes them according to
+// per-volume retention policies.
+//
+// Scheduled snapshots are named <prefix>-<period>-<UTC time>, for example
+// auto-daily-20250301-0000. Pruning only ever considers snapshots following this
+// scheme, and manual snapshots cannot use it, so snapshots made by hand are never
+// deleted by a policy.
+package snapshots
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/juste-un-gars/anemone/internal/btrfs"
+	"github.com/juste-un-gars/anemone/internal/storage"
+)
+
+// Snapshot periods, from the shortest to the longest
+const (
+	PeriodHourly  = "hourly"
+	PeriodDaily   = "daily"
+	PeriodWeekly  = "weekly"
+	PeriodMonthly = "monthly"
+)
+
+// Periods lists the snapshot periods in the order they are taken
+var Periods = []string{PeriodHourly, PeriodDaily, PeriodWeekly, PeriodMonthly}
+
+// DefaultPrefix is the naming prefix of a policy created without one
+const DefaultPrefix = "auto"
+
+// maxKeep bounds the number of snapshots kept per period
+const maxKeep = 1000
+
+// stampLayout is the UTC time at the end of a scheduled snapshot name
+const stampLayout = "20060102-1504"
+
+var (
+	prefixPattern    = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.]{0,31}$`)
+	scheduledPattern = regexp.MustCompile(`-(hourly|daily|weekly|monthly)-\d{8}-\d{4}$`)
+)
+
+// Policy is the snapshot schedule and retention of a volume
+type Policy struct {
+	ID          int        `json:"id"`
+	Dataset     string     `json:"dataset"` // ZFS dataset or Btrfs subvolume path
+	Prefix      string     `json:"prefix"`
+	Recursive   bool       `json:"recursive"`
+	KeepHourly  int        `json:"keep_hourly"`
+	KeepDaily   int        `json:"keep_daily"`
+	KeepWeekly  int        `json:"keep_weekly"`
+	KeepMonthly int        `json:"keep_monthly"`
+	Enabled     bool       `json:"enabled"`
+	LastRun     *time.Time `json:"last_run"`
+	LastError   string     `json:"last_error"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// Keep returns the number of snapshots the policy keeps for a period
+func (p *Policy) Keep(period string) int {
+	switch period {
+	case PeriodHourly:
+		return p.KeepHourly
+	case PeriodDaily:
+		return p.KeepDaily
+	case PeriodWeekly:
+		return p.KeepWeekly
+	case PeriodMonthly:
+		return p.KeepMonthly
+	}
+	return 0
+}
+
+// Validate checks the dataset, prefix and retention counts of a policy
+func (p *Policy) Validate() error {
+	if IsBtrfsVolume(p.Dataset) {
+		if err := btrfs.ValidateSubvolumePath(p.Dataset); err != nil {
+			return err
+		}
+		if p.Recursive {
+			return fmt.Errorf("recursive snapshots are not supported on Btrfs")
+		}
+	} else if err := storage.ValidateDatasetNameOrPool(p.Dataset); err != nil {
+		return fmt.Errorf("invalid dataset: %w", err)
+	}
+	if p.Prefix == "" {
+		p.Prefix = DefaultPrefix
+	}
+	if !prefixPattern.MatchString(p.Prefix) {
+		return fmt.Errorf("invalid prefix: use up to 32 letters, digits, dots or underscores")
+	}
+	total := 0
+	for _, period := range Periods {
+		keep := p.Keep(period)
+		if keep < 0 || keep > maxKeep {
+			return fmt.Errorf("the number of %s snapshots must be between 0 and %d", period, maxKeep)
+		}
+		total += keep
+	}
+	if total == 0 {
+		return fmt.Errorf("keep at least one snapshot of one period")
+	}
+	return nil
+}
+
+// IsScheduledName reports whether a snapshot name follows the naming scheme of
+// scheduled snapshots. Such names are reserved for the scheduler.
+func IsScheduledName(snapName string) bool {
+	return scheduledPattern.MatchString(snapName)
+}
+
+// scheduledName returns the name of the snapshot of a period taken at t
+func scheduledName(prefix, period string, t time.Time) string {
+	return prefix + "-" + period + "-" + t.UTC().Format(stampLayout)
+}
+
+// parseScheduledName returns the period and time of a snapshot taken by a policy
+// with the given prefix
+func parseScheduledName(prefix, snapName string) (string, time.Time, bool) {
+	rest, ok := strings.CutPrefix(snapName, prefix+"-")
+	if !ok {
+		return "", time.Time{}, false
+	}
+	period, stamp, ok := strings.Cut(rest, "-")
+	if !ok {
+		return "", time.Time{}, false
+	}
+	switch period {
+	case PeriodHourly, PeriodDaily, PeriodWeekly, PeriodMonthly:
+	default:
+		return "", time.Time{}, false
+	}
+	t, err := time.Parse(stampLayout, stamp)
+	if err != nil {
+		return "", time.Time{}, false
+	}
+	return period, t, true
+}
+
+// CreatePolicy adds a snapshot policy
+func CreatePolicy(db *sql.DB, p *Policy) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	if IsBtrfsVolume(p.Dataset) {
+		if err := checkBtrfsVolume(db, p.Dataset); err != nil {
+			return err
+		}
+	}
+	result, err := db.Exec(`INSERT INTO snapshot_policies
+		(dataset, prefix, recursive, keep_hourly, keep_daily, keep_weekly, keep_monthly, enabled)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		p.Dataset, p.Prefix, p.Recursive, p.KeepHourly, p.KeepDaily, p.KeepWeekly, p.KeepMonthly, p.Enabled)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE") {
+			return fmt.Errorf("a policy with this prefix already exists for %s", p.Dataset)
+		}
+		return fmt.Errorf("failed to create snapshot policy: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get snapshot policy ID: %w", err)
+	}
+	p.ID = int(id)
+	return nil
+}
+
+// UpdatePolicy saves the settings of a snapshot policy
+func UpdatePolicy(db *sql.DB, p *Policy) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	if IsBtrfsVolume(p.Dataset) {
+		if err := checkBtrfsVolume(db, p.Dataset); err != nil {
+			return err
+		}
+	}
+	result, err := db.Exec(`UPDATE snapshot_policies SET dataset = ?, prefix = ?, recursive = ?,
+		keep_hourly = ?, keep_daily = ?, keep_weekly = ?, keep_monthly = ?, enabled = ?
+		WHERE id = ?`,
+		p.Dataset, p.Prefix, p.Recursive, p.KeepHourly, p.KeepDaily, p.KeepWeekly, p.KeepMonthly, p.Enabled, p.ID)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE") {
+			return fmt.Errorf("a policy with this prefix already exists for %s", p.Dataset)
+		}
+		return fmt.Errorf("failed to update snapshot policy: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("snapshot policy not found")
+	}
+	return nil
+}
+
+// DeletePolicy removes a snapshot policy. The snapshots it took are left in place.
+func DeletePolicy(db *sql.DB, id int) error {
+	if _, err := db.Exec("DELETE FROM snapshot_policies WHERE id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete snapshot policy: %w", err)
+	}
+	return nil
+}
+
+// GetPolicy returns a snapshot policy
+func GetPolicy(db *sql.DB, id int) (*Policy, error) {
+	policies, err := queryPolicies(db, "WHERE id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(policies) == 0 {
+		return nil, fmt.Errorf("snapshot policy not found")
+	}
+	return policies[0], nil
+}
+
+// GetPolicies returns all snapshot policies, ordered by dataset
+func GetPolicies(db *sql.DB) ([]*Policy, error) {
+	return queryPolicies(db, "ORDER BY dataset, prefix")
+}
+
+// GetEnabledPolicies returns the snapshot policies the scheduler runs
+func GetEnabledPolicies(db *sql.DB) ([]*Policy, error) {
+	return queryPolicies(db, "WHERE enabled = 1 ORDER BY dataset, prefix")
+}
+
+func queryPolicies(db *sql.DB, clause string, args ...interface{}) ([]*Policy, error) {
+	rows, err := db.Query(`SELECT id, dataset, prefix, recursive, keep_hourly, keep_daily, keep_weekly,
+		keep_monthly, enabled, last_run, last_error, created_at
+		FROM snapshot_policies `+clause, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query snapshot policies: %w", err)
+	}
+	defer rows.Close()
+
+	var policies []*Policy
+	for rows.Next() {
+		p := &Policy{}
+		var lastRun sql.NullTime
+		var lastError sql.NullString
+		if err := rows.Scan(&p.ID, &p.Dataset, &p.Prefix, &p.Recursive, &p.KeepHourly, &p.KeepDaily,
+			&p.KeepWeekly, &p.KeepMonthly, &p.Enabled, &lastRun, &lastError, &p.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan snapshot policy: %w", err)
+		}
+		if lastRun.Valid {
+			p.LastRun = &lastRun.Time
+		}
+		p.LastError = lastError.String
+		policies = append(policies, p)
+	}
+	return policies, rows.Err()
+}
+
+// saveRun records the outcome of the last run of a policy
+func saveRun(db *sql.DB, id int, runErr error) error {
+	msg := ""
+	if runErr != nil {
+		msg = runErr.Error()
+	}
+	_, err := db.Exec("UPDATE snapshot_policies SET last_run = CURRENT_TIMESTAMP, last_error = ? WHERE id = ?", msg, id)
+	return err
+}
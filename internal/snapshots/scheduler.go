@@ -0,0 +1,197 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+// This file runs the snapshot policies: it takes the snapshots that are due and
+// prunes those beyond the retention of each period, leaving held snapshots alone.
+
+package snapshots
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	gosync "sync"
+	"time"
+
+	"github.com/juste-un-gars/anemone/internal/logger"
+	"github.com/juste-un-gars/anemone/internal/storage"
+)
+
+// checkInterval is how often the scheduler looks for due snapshots. It is short
+// so that hourly snapshots are taken close to the start of each hour.
+const checkInterval = 5 * time.Minute
+
+// runMu prevents the scheduler and a manual run from working on policies at the same time
+var runMu gosync.Mutex
+
+// scheduled is a snapshot taken by a policy
+type scheduled struct {
+	Name   string // Full name (dataset@snapshot)
+	Period string
+	Time   time.Time
+	Held   bool
+}
+
+// periodStart returns the start of the period containing t, in t's location.
+// Weeks start on Monday.
+func periodStart(period string, t time.Time) time.Time {
+	y, m, d := t.Date()
+	switch period {
+	case PeriodHourly:
+		return time.Date(y, m, d, t.Hour(), 0, 0, 0, t.Location())
+	case PeriodDaily:
+		return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+	case PeriodWeekly:
+		offset := (int(t.Weekday()) + 6) % 7
+		return time.Date(y, m, d-offset, 0, 0, 0, 0, t.Location())
+	case PeriodMonthly:
+		return time.Date(y, m, 1, 0, 0, 0, 0, t.Location())
+	}
+	return t
+}
+
+// isDue reports whether a snapshot of the period must be taken at now, given the
+// snapshots of that period already taken
+func isDue(period string, snaps []scheduled, now time.Time) bool {
+	start := periodStart(period, now)
+	for _, s := range snaps {
+		if !s.Time.Before(start) {
+			return false
+		}
+	}
+	return true
+}
+
+// expiredSnapshots returns the snapshots beyond the newest keep ones. Held
+// snapshots are never returned, but count towards keep when among the newest.
+func expiredSnapshots(snaps []scheduled, keep int) []scheduled {
+	sorted := append([]scheduled(nil), snaps...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Time.After(sorted[j].Time) })
+
+	var expired []scheduled
+	for i, s := range sorted {
+		if i < keep || s.Held {
+			continue
+		}
+		expired = append(expired, s)
+	}
+	return expired
+}
+
+// listScheduled returns the snapshots a policy took, grouped by period
+func listScheduled(db *sql.DB, p *Policy) (map[string][]scheduled, error) {
+	all, err := ListSnapshots(db, p.Dataset)
+	if err != nil {
+		return nil, err
+	}
+	held, err := heldSnapshots(p.Dataset)
+	if err != nil {
+		return nil, err
+	}
+	// A recursive snapshot cannot be destroyed while one of its children is held
+	heldNames := make(map[string]bool)
+	for _, snap := range all {
+		if held[snap.Name] {
+			heldNames[snap.SnapName] = true
+		}
+	}
+
+	byPeriod := make(map[string][]scheduled)
+	for _, snap := range all {
+		if snap.Dataset != p.Dataset {
+			continue
+		}
+		period, t, ok := parseScheduledName(p.Prefix, snap.SnapName)
+		if !ok {
+			continue
+		}
+		isHeld := held[snap.Name] || (p.Recursive && heldNames[snap.SnapName])
+		byPeriod[period] = append(byPeriod[period], scheduled{Name: snap.Name, Period: period, Time: t, Held: isHeld})
+	}
+	return byPeriod, nil
+}
+
+// runPolicy takes the due snapshots of a policy and prunes the expired ones
+func runPolicy(db *sql.DB, p *Policy, now time.Time) (created, pruned int, err error) {
+	byPeriod, err := listScheduled(db, p)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var errs []error
+	for _, period := range Periods {
+		if p.Keep(period) == 0 || !isDue(period, byPeriod[period], now) {
+			continue
+		}
+		name := scheduledName(p.Prefix, period, now)
+		if err := createSnapshot(storage.SnapshotCreateOptions{Dataset: p.Dataset, Name: name, Recursive: p.Recursive}); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		byPeriod[period] = append(byPeriod[period], scheduled{Name: p.Dataset + "@" + name, Period: period, Time: now})
+		created++
+	}
+
+	// Periods with nothing to keep are pruned too, so that lowering a count to
+	// zero removes the snapshots taken before
+	for _, period := range Periods {
+		for _, s := range expiredSnapshots(byPeriod[period], p.Keep(period)) {
+			if err := DeleteSnapshot(db, s.Name, p.Recursive, false); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			logger.Info("Snapshots: Pruned expired snapshot", "snapshot", s.Name)
+			pruned++
+		}
+	}
+	return created, pruned, errors.Join(errs...)
+}
+
+// RunPolicy runs a policy now and records its outcome
+func RunPolicy(db *sql.DB, p *Policy) error {
+	runMu.Lock()
+	defer runMu.Unlock()
+
+	created, pruned, err := runPolicy(db, p, time.Now())
+	if created > 0 || pruned > 0 {
+		logger.Info("Snapshots: Policy applied", "dataset", p.Dataset, "prefix", p.Prefix, "created", created, "pruned", pruned)
+	}
+	if saveErr := saveRun(db, p.ID, err); saveErr != nil {
+		logger.Warn("Snapshots: Failed to record policy run", "id", p.ID, "error", saveErr)
+	}
+	if err != nil {
+		return fmt.Errorf("snapshot policy for %s: %w", p.Dataset, err)
+	}
+	return nil
+}
+
+// StartScheduler runs the enabled snapshot policies in the background
+func StartScheduler(db *sql.DB) {
+	go func() {
+		ticker := time.NewTicker(checkInterval)
+		defer ticker.Stop()
+
+		for {
+			<-ticker.C
+
+			policies, err := GetEnabledPolicies(db)
+			if err != nil {
+				logger.Warn("Snapshots: Failed to get policies", "error", err)
+				continue
+			}
+			zfsAvailable := storage.IsZFSAvailable()
+			for _, p := range policies {
+				if !IsBtrfsVolume(p.Dataset) && !zfsAvailable {
+					continue
+				}
+				if err := RunPolicy(db, p); err != nil {
+					logger.Warn("Snapshots: Scheduled run failed", "error", err)
+				}
+			}
+		}
+	}()
+
+	logger.Info("✅ Snapshot scheduler started (checks every 5 minutes)")
+}
@@ -0,0 +1,128 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+package snapshots
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduledName(t *testing.T) {
+	at := time.Date(2025, 3, 1, 14, 5, 30, 0, time.UTC)
+	name := scheduledName("auto", PeriodDaily, at)
+	if name != "auto-daily-20250301-1405" {
+		t.Fatalf("scheduledName = %q", name)
+	}
+	if !IsScheduledName(name) {
+		t.Errorf("IsScheduledName(%q) = false", name)
+	}
+
+	period, parsed, ok := parseScheduledName("auto", name)
+	if !ok || period != PeriodDaily || !parsed.Equal(at.Truncate(time.Minute)) {
+		t.Errorf("parseScheduledName = %q, %v, %v", period, parsed, ok)
+	}
+
+	for _, manual := range []string{"before-upgrade", "auto-daily", "auto-yearly-20250301-1405", "daily-20250301-1405"} {
+		if _, _, ok := parseScheduledName("auto", manual); ok {
+			t.Errorf("parseScheduledName accepted manual snapshot %q", manual)
+		}
+	}
+	if _, _, ok := parseScheduledName("other", name); ok {
+		t.Errorf("parseScheduledName accepted a snapshot of another prefix")
+	}
+}
+
+func TestIsDue(t *testing.T) {
+	now := time.Date(2025, 3, 5, 10, 20, 0, 0, time.UTC) // Wednesday
+	snaps := []scheduled{{Time: time.Date(2025, 3, 3, 9, 0, 0, 0, time.UTC)}}
+
+	tests := []struct {
+		period string
+		want   bool
+	}{
+		{PeriodHourly, true},
+		{PeriodDaily, true},
+		{PeriodWeekly, false}, // Taken on Monday of the same week
+		{PeriodMonthly, false},
+	}
+	for _, tt := range tests {
+		if got := isDue(tt.period, snaps, now); got != tt.want {
+			t.Errorf("isDue(%s) = %v, want %v", tt.period, got, tt.want)
+		}
+	}
+	if !isDue(PeriodMonthly, nil, now) {
+		t.Errorf("isDue without snapshots = false")
+	}
+}
+
+func TestExpiredSnapshots(t *testing.T) {
+	base := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	var snaps []scheduled
+	for i := 0; i < 5; i++ {
+		snaps = append(snaps, scheduled{Name: string(rune('a' + i)), Time: base.AddDate(0, 0, i)})
+	}
+	snaps[0].Held = true // Oldest
+
+	expired := expiredSnapshots(snaps, 2)
+	var names []string
+	for _, s := range expired {
+		names = append(names, s.Name)
+	}
+	// e and d are kept, a is held
+	if len(names) != 2 || names[0] != "c" || names[1] != "b" {
+		t.Errorf("expiredSnapshots = %v, want [c b]", names)
+	}
+
+	if got := expiredSnapshots(snaps, 0); len(got) != 4 {
+		t.Errorf("expiredSnapshots with keep 0 returned %d snapshots, want 4", len(got))
+	}
+}
+
+func TestPolicyValidate(t *testing.T) {
+	p := &Policy{Dataset: "tank/data", KeepDaily: 7}
+	if err := p.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	if p.Prefix != DefaultPrefix {
+		t.Errorf("Prefix = %q, want %q", p.Prefix, DefaultPrefix)
+	}
+	if err := (&Policy{Dataset: "/srv/shares/alice/backup", KeepDaily: 7}).Validate(); err != nil {
+		t.Errorf("Validate Btrfs policy: %v", err)
+	}
+
+	invalid := []*Policy{
+		{Dataset: "tank/data"},
+		{Dataset: "tank/data", Prefix: "bad-prefix", KeepDaily: 1},
+		{Dataset: "tank/data", KeepHourly: -1, KeepDaily: 1},
+		{Dataset: "", KeepDaily: 1},
+		{Dataset: "/srv/shares/alice/backup", KeepDaily: 1, Recursive: true},
+		{Dataset: "/srv/shares/../backup", KeepDaily: 1},
+	}
+	for _, p := range invalid {
+		if err := p.Validate(); err == nil {
+			t.Errorf("Validate(%+v) = nil, want error", p)
+		}
+	}
+}
+
+func TestSplitName(t *testing.T) {
+	tests := []struct {
+		name, volume, snap string
+	}{
+		{"tank/data@auto-daily-20250301-0000", "tank/data", "auto-daily-20250301-0000"},
+		{"/srv/shares/alice/backup@before-upgrade", "/srv/shares/alice/backup", "before-upgrade"},
+	}
+	for _, tt := range tests {
+		volume, snap, err := splitName(tt.name)
+		if err != nil || volume != tt.volume || snap != tt.snap {
+			t.Errorf("splitName(%q) = %q, %q, %v", tt.name, volume, snap, err)
+		}
+	}
+	for _, bad := range []string{"tank/data", "@snap", "tank/data@"} {
+		if _, _, err := splitName(bad); err == nil {
+			t.Errorf("splitName(%q) = nil error", bad)
+		}
+	}
+}
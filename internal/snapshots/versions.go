@@ -0,0 +1,203 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+// This file exposes the scheduled snapshots holding a share as previous versions,
+// for the file browser and for the shadow_copy2 module of Samba. Only scheduled
+// snapshots are exposed: their names carry their UTC time, which Samba needs,
+// and manual snapshots may have been taken for other purposes.
+
+package snapshots
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/juste-un-gars/anemone/internal/btrfs"
+	"github.com/juste-un-gars/anemone/internal/storage"
+)
+
+// Snapshot source kinds
+const (
+	SourceZFS   = "zfs"
+	SourceBtrfs = "btrfs"
+)
+
+// zfsSnapDir is the directory of a ZFS filesystem giving access to its snapshots
+const zfsSnapDir = ".zfs/snapshot"
+
+// VersionSource locates the snapshots holding previous versions of a share
+type VersionSource struct {
+	Kind       string
+	Mountpoint string // Root of the snapshotted filesystem or subvolume
+	SnapDir    string // Directory holding one directory per snapshot
+	Subdir     string // Path of the share below Mountpoint, "" when it is the root
+}
+
+// Version is a scheduled snapshot holding a file or folder
+type Version struct {
+	Snapshot string    `json:"snapshot"`
+	Period   string    `json:"period"`
+	Time     time.Time `json:"time"`
+}
+
+// SourceFor returns the snapshots holding a share, or nil if the share is
+// neither in a ZFS filesystem nor a Btrfs subvolume
+func SourceFor(sharePath string) (*VersionSource, error) {
+	path, err := filepath.Abs(sharePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if btrfs.IsFilesystem(path) {
+		if btrfs.ValidateSubvolumePath(path) != nil || !btrfs.IsSubvolumeSudo(path) {
+			return nil, nil
+		}
+		return &VersionSource{Kind: SourceBtrfs, Mountpoint: path, SnapDir: btrfs.SnapshotDir(path)}, nil
+	}
+
+	if !storage.IsZFSAvailable() {
+		return nil, nil
+	}
+	mounts, err := storage.MountedFilesystems()
+	if err != nil {
+		return nil, err
+	}
+	mountpoint, ok := containingMount(mounts, path)
+	if !ok {
+		return nil, nil
+	}
+	subdir, err := filepath.Rel(mountpoint, path)
+	if err != nil {
+		return nil, err
+	}
+	if subdir == "." {
+		subdir = ""
+	}
+	return &VersionSource{
+		Kind:       SourceZFS,
+		Mountpoint: mountpoint,
+		SnapDir:    filepath.Join(mountpoint, zfsSnapDir),
+		Subdir:     subdir,
+	}, nil
+}
+
+// containingMount returns the deepest mountpoint holding a path. Snapshots of a
+// filesystem do not include the filesystems mounted below it.
+func containingMount(mounts map[string]string, path string) (string, bool) {
+	best := ""
+	for _, mp := range mounts {
+		if mp != "/" && path != mp && !strings.HasPrefix(path, mp+"/") {
+			continue
+		}
+		if len(mp) > len(best) {
+			best = mp
+		}
+	}
+	return best, best != ""
+}
+
+// parseScheduled returns the period and time of a scheduled snapshot, whatever
+// the prefix of the policy that took it
+func parseScheduled(snapName string) (string, time.Time, bool) {
+	loc := scheduledPattern.FindStringIndex(snapName)
+	if loc == nil || !prefixPattern.MatchString(snapName[:loc[0]]) {
+		return "", time.Time{}, false
+	}
+	return parseScheduledName(snapName[:loc[0]], snapName)
+}
+
+// Versions returns the scheduled snapshots of the source, newest first. Periods
+// taken at the same time hold the same content, so only the shortest is kept.
+func (src *VersionSource) Versions() ([]Version, error) {
+	entries, err := os.ReadDir(src.SnapDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []Version{}, nil
+		}
+		return nil, fmt.Errorf("failed to read snapshot directory: %w", err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return scheduledVersions(names), nil
+}
+
+// scheduledVersions returns the versions found among snapshot names, newest first
+func scheduledVersions(names []string) []Version {
+	byTime := make(map[time.Time]Version)
+	for _, name := range names {
+		period, t, ok := parseScheduled(name)
+		if !ok {
+			continue
+		}
+		if v, ok := byTime[t]; ok && periodIndex(v.Period) <= periodIndex(period) {
+			continue
+		}
+		byTime[t] = Version{Snapshot: name, Period: period, Time: t}
+	}
+
+	versions := make([]Version, 0, len(byTime))
+	for _, v := range byTime {
+		versions = append(versions, v)
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i].Time.After(versions[j].Time) })
+	return versions
+}
+
+// periodIndex returns the rank of a period, the shortest first
+func periodIndex(period string) int {
+	for i, p := range Periods {
+		if p == period {
+			return i
+		}
+	}
+	return len(Periods)
+}
+
+// VersionsOf returns the versions holding a path of the share, newest first
+func (src *VersionSource) VersionsOf(relPath string) ([]Version, error) {
+	versions, err := src.Versions()
+	if err != nil {
+		return nil, err
+	}
+	result := []Version{}
+	for _, v := range versions {
+		path, err := src.Path(v.Snapshot, relPath)
+		if err != nil {
+			continue
+		}
+		if _, err := os.Lstat(path); err == nil {
+			result = append(result, v)
+		}
+	}
+	return result, nil
+}
+
+// Path returns the path of a file of the share in a snapshot. The path is
+// checked not to leave the snapshot through ".." or a symbolic link.
+func (src *VersionSource) Path(snapshot, relPath string) (string, error) {
+	if _, _, ok := parseScheduled(snapshot); !ok || strings.Contains(snapshot, "/") {
+		return "", fmt.Errorf("invalid snapshot: %s", snapshot)
+	}
+	root := filepath.Join(src.SnapDir, snapshot, src.Subdir)
+	path := filepath.Join(root, filepath.Join("/", relPath))
+
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", fmt.Errorf("snapshot %s not found", snapshot)
+	}
+	realPath, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", fmt.Errorf("%s not found in snapshot %s", relPath, snapshot)
+	}
+	if realPath != realRoot && !strings.HasPrefix(realPath, realRoot+"/") {
+		return "", fmt.Errorf("path escapes the snapshot")
+	}
+	return path, nil
+}
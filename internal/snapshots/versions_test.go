@@ -0,0 +1,107 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+package snapshots
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestContainingMount(t *testing.T) {
+	mounts := map[string]string{
+		"tank":              "/tank",
+		"tank/anemone":      "/srv/anemone",
+		"tank/anemone/data": "/srv/anemone/data",
+	}
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/srv/anemone/shares/alice/backup", "/srv/anemone"},
+		{"/srv/anemone/data", "/srv/anemone/data"},
+		{"/srv/anemone/database", "/srv/anemone"}, // Not below /srv/anemone/data
+		{"/tank/other", "/tank"},
+		{"/home/alice", ""},
+	}
+	for _, tt := range tests {
+		got, ok := containingMount(mounts, tt.path)
+		if got != tt.want || ok != (tt.want != "") {
+			t.Errorf("containingMount(%q) = %q, %v, want %q", tt.path, got, ok, tt.want)
+		}
+	}
+
+	if got, _ := containingMount(map[string]string{"rpool/ROOT": "/"}, "/srv/anemone"); got != "/" {
+		t.Errorf("containingMount with a root filesystem = %q", got)
+	}
+}
+
+func TestScheduledVersions(t *testing.T) {
+	names := []string{
+		"auto-daily-20250301-0000",
+		"auto-hourly-20250301-0000", // Same time as the daily snapshot
+		"nightly.v2-hourly-20250301-0100",
+		"before-upgrade",
+		"anemone-repl-20250301-000000",
+		"-daily-20250301-0200",
+	}
+
+	versions := scheduledVersions(names)
+	if len(versions) != 2 {
+		t.Fatalf("scheduledVersions = %+v, want 2 versions", versions)
+	}
+	if versions[0].Snapshot != "nightly.v2-hourly-20250301-0100" {
+		t.Errorf("newest version = %q", versions[0].Snapshot)
+	}
+	if versions[1].Snapshot != "auto-hourly-20250301-0000" || versions[1].Period != PeriodHourly {
+		t.Errorf("versions at the same time should keep the shortest period, got %q", versions[1].Snapshot)
+	}
+	if !versions[1].Time.Equal(time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("version time = %v", versions[1].Time)
+	}
+}
+
+func TestVersionPath(t *testing.T) {
+	dir := t.TempDir()
+	snapDir := filepath.Join(dir, ".zfs", "snapshot")
+	share := filepath.Join(snapDir, "auto-daily-20250301-0000", "shares", "alice")
+	if err := os.MkdirAll(filepath.Join(share, "docs"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(share, "docs", "report.txt"), []byte("v1"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(dir, filepath.Join(share, "escape")); err != nil {
+		t.Fatal(err)
+	}
+
+	src := &VersionSource{Kind: SourceZFS, Mountpoint: dir, SnapDir: snapDir, Subdir: "shares/alice"}
+
+	path, err := src.Path("auto-daily-20250301-0000", "docs/report.txt")
+	if err != nil || path != filepath.Join(share, "docs", "report.txt") {
+		t.Errorf("Path = %q, %v", path, err)
+	}
+	if _, err := src.Path("auto-daily-20250301-0000", "../../../../../etc/passwd"); err == nil {
+		t.Errorf("Path accepted a path leaving the share")
+	}
+	if _, err := src.Path("auto-daily-20250301-0000", "escape"); err == nil {
+		t.Errorf("Path followed a symbolic link out of the snapshot")
+	}
+	for _, snapshot := range []string{"before-upgrade", "../auto-daily-20250301-0000", ""} {
+		if _, err := src.Path(snapshot, "docs"); err == nil {
+			t.Errorf("Path accepted snapshot %q", snapshot)
+		}
+	}
+
+	versions, err := src.VersionsOf("docs/report.txt")
+	if err != nil || len(versions) != 1 {
+		t.Errorf("VersionsOf = %+v, %v", versions, err)
+	}
+	if versions, err := src.VersionsOf("docs/missing.txt"); err != nil || len(versions) != 0 {
+		t.Errorf("VersionsOf a missing file = %+v, %v", versions, err)
+	}
+}
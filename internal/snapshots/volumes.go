@@ -0,0 +1,75 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+// This file lists the shares stored in Btrfs subvolumes, with their qgroup usage.
+
+package snapshots
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/juste-un-gars/anemone/internal/btrfs"
+	"github.com/juste-un-gars/anemone/internal/logger"
+	"github.com/juste-un-gars/anemone/internal/storage"
+)
+
+// Volume is a share stored in a Btrfs subvolume
+type Volume struct {
+	Path         string `json:"path"`
+	Share        string `json:"share"`
+	Owner        string `json:"owner"`
+	QuotaEnabled bool   `json:"quota_enabled"` // False when qgroups are disabled on the filesystem
+	Referenced   uint64 `json:"referenced"`
+	RefHuman     string `json:"ref_human"`
+	Exclusive    uint64 `json:"exclusive"`
+	ExclHuman    string `json:"excl_human"`
+	Limit        uint64 `json:"limit"` // 0 when unlimited
+	LimitHuman   string `json:"limit_human"`
+	Snapshots    int    `json:"snapshots"`
+}
+
+// ListVolumes returns the shares stored in Btrfs subvolumes
+func ListVolumes(db *sql.DB) ([]Volume, error) {
+	rows, err := db.Query(`SELECT s.name, s.path, u.username FROM shares s
+		JOIN users u ON u.id = s.user_id ORDER BY u.username, s.name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query shares: %w", err)
+	}
+	var volumes []Volume
+	for rows.Next() {
+		var v Volume
+		if err := rows.Scan(&v.Share, &v.Path, &v.Owner); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan share: %w", err)
+		}
+		volumes = append(volumes, v)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	result := []Volume{}
+	for _, v := range volumes {
+		if btrfs.ValidateSubvolumePath(v.Path) != nil || !btrfs.IsFilesystem(v.Path) || !btrfs.IsSubvolumeSudo(v.Path) {
+			continue
+		}
+		if usage, err := btrfs.SubvolumeUsage(v.Path); err == nil {
+			v.QuotaEnabled = true
+			v.Referenced, v.RefHuman = usage.Referenced, storage.FormatBytes(usage.Referenced)
+			v.Exclusive, v.ExclHuman = usage.Exclusive, storage.FormatBytes(usage.Exclusive)
+			if usage.Limit > 0 {
+				v.Limit, v.LimitHuman = usage.Limit, storage.FormatBytes(usage.Limit)
+			}
+		}
+		if snaps, err := btrfs.ListSnapshots(v.Path); err == nil {
+			v.Snapshots = len(snaps)
+		} else {
+			logger.Warn("Snapshots: Failed to list Btrfs snapshots", "path", v.Path, "error", err)
+		}
+		result = append(result, v)
+	}
+	return result, nil
+}
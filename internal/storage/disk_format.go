@@ -21,6 +21,10 @@ type FormatOptions struct {
 	MountPath    string `json:"mount_path"`    // Mount point (e.g., /mnt/sda)
 	SharedAccess bool   `json:"shared_access"` // All users can read/write (for mount)
 	Persistent   bool   `json:"persistent"`    // Add to fstab for persistent mount
+
+	// EncryptionKey formats the disk as a LUKS2 volume unlocked by this key and
+	// creates the filesystem inside it (optional, never serialized)
+	EncryptionKey string `json:"-"`
 }
 
 // WipeOptions contains options for wiping a disk
@@ -220,6 +224,19 @@ func FormatDisk(opts FormatOptions) error {
 		return fmt.Errorf("failed to wipe partition table: %s - %w", strings.TrimSpace(string(output)), err)
 	}
 
+	// Encrypted disks get the filesystem inside the unlocked LUKS volume
+	target := opts.Device
+	if opts.EncryptionKey != "" {
+		if err := LUKSFormat(opts.Device, opts.EncryptionKey); err != nil {
+			return err
+		}
+		name := LUKSMapperName(opts.Device)
+		if err := LUKSOpen(opts.Device, name, opts.EncryptionKey); err != nil {
+			return err
+		}
+		target = MapperPath(name)
+	}
+
 	// Build format command
 	var args []string
 	switch opts.Filesystem {
@@ -231,7 +248,7 @@ func FormatDisk(opts FormatOptions) error {
 		if opts.Label != "" && len(opts.Label) <= 16 {
 			args = append(args, "-L", opts.Label)
 		}
-		args = append(args, opts.Device)
+		args = append(args, target)
 	case "xfs":
 		args = []string{"mkfs.xfs"}
 		if opts.Force {
@@ -240,19 +257,19 @@ func FormatDisk(opts FormatOptions) error {
 		if opts.Label != "" && len(opts.Label) <= 12 {
 			args = append(args, "-L", opts.Label)
 		}
-		args = append(args, opts.Device)
+		args = append(args, target)
 	case "vfat", "fat32":
 		args = []string{"mkfs.vfat", "-F", "32"}
 		if opts.Label != "" && len(opts.Label) <= 11 {
 			args = append(args, "-n", strings.ToUpper(opts.Label))
 		}
-		args = append(args, opts.Device)
+		args = append(args, target)
 	case "exfat":
 		args = []string{"mkfs.exfat"}
 		if opts.Label != "" && len(opts.Label) <= 15 {
 			args = append(args, "-L", opts.Label)
 		}
-		args = append(args, opts.Device)
+		args = append(args, target)
 	}
 
 	cmd = exec.Command("sudo", args...)
@@ -559,7 +576,12 @@ func UnmountDisk(mountPath string, eject bool) error {
 // MountDisk mounts a disk at the specified mount point
 // If sharedAccess is true, the disk will be accessible by all users (read/write)
 func MountDisk(device, mountPath string, sharedAccess bool) error {
-	if err := ValidateDevicePath(device); err != nil {
+	// Unlocked LUKS volumes are mounted through their mapper device
+	if name, ok := strings.CutPrefix(device, "/dev/mapper/"); ok {
+		if err := ValidateMapperName(name); err != nil {
+			return err
+		}
+	} else if err := ValidateDevicePath(device); err != nil {
 		return err
 	}
 
@@ -0,0 +1,150 @@
+// Package storage provides LUKS2 encrypted volume operations.
+package storage
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// luksMapperPrefix prefixes the device-mapper names of the volumes Anemone opens
+const luksMapperPrefix = "anemone-"
+
+var (
+	mapperNameRe = regexp.MustCompile(`^[a-zA-Z0-9_\-]+$`)
+	uuidRe       = regexp.MustCompile(`^[0-9a-fA-F\-]{36}$`)
+)
+
+// IsLUKSAvailable checks if the cryptsetup command is available
+func IsLUKSAvailable() bool {
+	_, err := exec.LookPath("cryptsetup")
+	return err == nil
+}
+
+// ValidateMapperName checks a device-mapper name
+func ValidateMapperName(name string) error {
+	if !mapperNameRe.MatchString(name) {
+		return fmt.Errorf("invalid mapper name: only alphanumerics, underscore and hyphen are allowed")
+	}
+	return nil
+}
+
+// LUKSMapperName returns the device-mapper name a disk is opened as
+func LUKSMapperName(device string) string {
+	return luksMapperPrefix + filepath.Base(device)
+}
+
+// MapperPath returns the path of an opened device-mapper volume
+func MapperPath(name string) string {
+	return "/dev/mapper/" + name
+}
+
+// LUKSFormat formats a device as a LUKS2 volume unlocked by key
+func LUKSFormat(device, key string) error {
+	if !IsLUKSAvailable() {
+		return fmt.Errorf("cryptsetup is not available on this system")
+	}
+	if err := ValidateDevicePath(device); err != nil {
+		return err
+	}
+	if key == "" {
+		return fmt.Errorf("encryption key cannot be empty")
+	}
+
+	cmd := exec.Command("sudo", "cryptsetup", "luksFormat", "--type", "luks2", "--batch-mode", "--key-file", "-", device)
+	cmd.Stdin = strings.NewReader(key)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to create LUKS volume: %s - %w", strings.TrimSpace(string(output)), err)
+	}
+	return nil
+}
+
+// LUKSOpen unlocks a LUKS volume as /dev/mapper/{name}
+func LUKSOpen(device, name, key string) error {
+	if !IsLUKSAvailable() {
+		return fmt.Errorf("cryptsetup is not available on this system")
+	}
+	if err := ValidateDevicePath(device); err != nil {
+		return err
+	}
+	if err := ValidateMapperName(name); err != nil {
+		return err
+	}
+
+	cmd := exec.Command("sudo", "cryptsetup", "open", "--type", "luks", "--key-file", "-", device, name)
+	cmd.Stdin = strings.NewReader(key)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to unlock LUKS volume: %s - %w", strings.TrimSpace(string(output)), err)
+	}
+	return nil
+}
+
+// LUKSClose locks an opened LUKS volume
+func LUKSClose(name string) error {
+	if err := ValidateMapperName(name); err != nil {
+		return err
+	}
+
+	cmd := exec.Command("sudo", "cryptsetup", "close", name)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to lock LUKS volume: %s - %w", strings.TrimSpace(string(output)), err)
+	}
+	return nil
+}
+
+// LUKSUUID returns the UUID of a LUKS volume, which stays the same when the
+// kernel names the disk differently
+func LUKSUUID(device string) (string, error) {
+	if err := ValidateDevicePath(device); err != nil {
+		return "", err
+	}
+
+	cmd := exec.Command("sudo", "cryptsetup", "luksUUID", device)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("failed to read LUKS UUID: %s - %w", strings.TrimSpace(string(output)), err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// LUKSDeviceByUUID returns the current device of a LUKS volume
+func LUKSDeviceByUUID(uuid string) (string, error) {
+	if !uuidRe.MatchString(uuid) {
+		return "", fmt.Errorf("invalid UUID")
+	}
+	device, err := filepath.EvalSymlinks("/dev/disk/by-uuid/" + uuid)
+	if err != nil {
+		return "", fmt.Errorf("LUKS volume %s not found", uuid)
+	}
+	if err := ValidateDevicePath(device); err != nil {
+		return "", err
+	}
+	return device, nil
+}
+
+// IsLUKSOpen reports whether a LUKS volume is unlocked
+func IsLUKSOpen(name string) bool {
+	if ValidateMapperName(name) != nil {
+		return false
+	}
+	_, err := os.Stat(MapperPath(name))
+	return err == nil
+}
+
+// IsMountpoint reports whether something is mounted at path
+func IsMountpoint(path string) bool {
+	data, err := os.ReadFile("/proc/mounts")
+	if err != nil {
+		return false
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && fields[1] == path {
+			return true
+		}
+	}
+	return false
+}
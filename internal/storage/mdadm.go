@@ -0,0 +1,469 @@
+// Package storage provides Linux software RAID (mdadm) management operations.
+package storage
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// mdstatPath is where the kernel reports the state of the md arrays
+const mdstatPath = "/proc/mdstat"
+
+// Member states of an md array
+const (
+	MDMemberActive      = "active"
+	MDMemberSpare       = "spare"
+	MDMemberFaulty      = "faulty"
+	MDMemberReplacement = "replacement"
+)
+
+// MDArray represents a Linux software RAID array
+type MDArray struct {
+	Name          string       `json:"name"`      // e.g., "md0"
+	Path          string       `json:"path"`      // e.g., "/dev/md0"
+	Level         string       `json:"level"`     // raid1, raid5, raid6, raid10...
+	State         string       `json:"state"`     // active, degraded, inactive
+	ReadOnly      bool         `json:"read_only"` // Assembled read-only (e.g. auto-read-only)
+	Health        HealthStatus `json:"health"`    // Mapped health status
+	Size          uint64       `json:"size"`      // Usable size in bytes
+	SizeHuman     string       `json:"size_human"`
+	RaidDevices   int          `json:"raid_devices"`   // Devices the array is made of
+	ActiveDevices int          `json:"active_devices"` // Devices currently in sync
+	Members       []MDMember   `json:"members"`
+	Sync          *MDSync      `json:"sync"`           // Running resync, recovery or check (nil if idle)
+	MismatchCount uint64       `json:"mismatch_count"` // Mismatches found by the last check
+}
+
+// MDMember represents a device of an md array
+type MDMember struct {
+	Name  string `json:"name"`  // e.g., "sdb1"
+	Path  string `json:"path"`  // e.g., "/dev/sdb1"
+	Role  int    `json:"role"`  // Slot of the device in the array
+	State string `json:"state"` // active, spare, faulty, replacement
+}
+
+// MDSync is a resync, recovery, reshape or check running on an md array
+type MDSync struct {
+	Action    string  `json:"action"`    // resync, recovery, reshape, check, repair
+	Progress  float64 `json:"progress"`  // Percentage done
+	Remaining string  `json:"remaining"` // Estimated time left, or PENDING/DELAYED
+	Speed     string  `json:"speed"`     // e.g., "29866K/sec"
+	Pending   bool    `json:"pending"`   // Waiting to start
+}
+
+// MDCreateOptions contains options for creating an md array
+type MDCreateOptions struct {
+	Name       string   `json:"name"`       // Array name (e.g., md0)
+	Level      string   `json:"level"`      // raid1, raid5, raid6, raid10
+	Disks      []string `json:"disks"`      // List of disk paths
+	Spares     []string `json:"spares"`     // Hot spare disk paths (optional)
+	Filesystem string   `json:"filesystem"` // ext4 or xfs to format the array with (optional)
+}
+
+var (
+	mdHeaderRe   = regexp.MustCompile(`^(md\S+) : (.*)$`)
+	mdMemberRe   = regexp.MustCompile(`^([a-zA-Z0-9_\-]+)\[(\d+)\]((?:\([A-Z]\))*)$`)
+	mdStatusRe   = regexp.MustCompile(`^(\d+) blocks.*\[(\d+)/(\d+)\] \[[U_]+\]`)
+	mdBlocksRe   = regexp.MustCompile(`^(\d+) blocks`)
+	mdProgressRe = regexp.MustCompile(`(resync|recovery|reshape|check|repair)\s*=\s*([\d.]+)%.*finish=(\S+)\s+speed=(\S+)`)
+	mdPendingRe  = regexp.MustCompile(`(resync|recovery|reshape|check|repair)\s*=\s*(PENDING|DELAYED)`)
+	mdNameRe     = regexp.MustCompile(`^md[0-9]+$`)
+)
+
+// IsMDAdmAvailable checks if the mdadm command is available
+func IsMDAdmAvailable() bool {
+	_, err := exec.LookPath("mdadm")
+	return err == nil
+}
+
+// ValidateMDName checks if an md array name is valid
+func ValidateMDName(name string) error {
+	if !mdNameRe.MatchString(name) {
+		return fmt.Errorf("invalid array name: must be md followed by a number (e.g., md0)")
+	}
+	return nil
+}
+
+// ListMDArrays returns all md arrays with their status
+func ListMDArrays() ([]MDArray, error) {
+	data, err := os.ReadFile(mdstatPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read %s: %w", mdstatPath, err)
+	}
+
+	arrays := ParseMDStat(string(data))
+	for i := range arrays {
+		// The kernel keeps the result of the last check in sysfs
+		cnt, err := os.ReadFile(filepath.Join("/sys/block", arrays[i].Name, "md", "mismatch_cnt"))
+		if err == nil {
+			arrays[i].MismatchCount, _ = strconv.ParseUint(strings.TrimSpace(string(cnt)), 10, 64)
+		}
+	}
+	return arrays, nil
+}
+
+// GetMDArray returns a specific md array
+func GetMDArray(name string) (*MDArray, error) {
+	if err := ValidateMDName(name); err != nil {
+		return nil, err
+	}
+	arrays, err := ListMDArrays()
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range arrays {
+		if a.Name == name {
+			return &a, nil
+		}
+	}
+	return nil, fmt.Errorf("array %s not found", name)
+}
+
+// ParseMDStat parses the content of /proc/mdstat
+func ParseMDStat(content string) []MDArray {
+	arrays := []MDArray{}
+	var cur *MDArray
+
+	flush := func() {
+		if cur != nil {
+			finishMDArray(cur)
+			arrays = append(arrays, *cur)
+			cur = nil
+		}
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+
+		if m := mdHeaderRe.FindStringSubmatch(line); m != nil {
+			flush()
+			cur = &MDArray{Name: m[1], Path: "/dev/" + m[1]}
+			parseMDHeader(cur, strings.Fields(m[2]))
+			continue
+		}
+		if cur == nil {
+			continue
+		}
+		if trimmed == "" {
+			flush()
+			continue
+		}
+
+		if m := mdStatusRe.FindStringSubmatch(trimmed); m != nil {
+			blocks, _ := strconv.ParseUint(m[1], 10, 64)
+			cur.Size = blocks * 1024
+			cur.RaidDevices, _ = strconv.Atoi(m[2])
+			cur.ActiveDevices, _ = strconv.Atoi(m[3])
+		} else if m := mdBlocksRe.FindStringSubmatch(trimmed); m != nil {
+			blocks, _ := strconv.ParseUint(m[1], 10, 64)
+			cur.Size = blocks * 1024
+		} else if m := mdProgressRe.FindStringSubmatch(trimmed); m != nil {
+			progress, _ := strconv.ParseFloat(m[2], 64)
+			cur.Sync = &MDSync{Action: m[1], Progress: progress, Remaining: m[3], Speed: m[4]}
+		} else if m := mdPendingRe.FindStringSubmatch(trimmed); m != nil {
+			cur.Sync = &MDSync{Action: m[1], Remaining: m[2], Pending: true}
+		}
+	}
+	flush()
+
+	return arrays
+}
+
+// parseMDHeader parses the fields following "mdX : " in /proc/mdstat, such as
+// "active (auto-read-only) raid1 sdb1[1] sda1[0](F)"
+func parseMDHeader(a *MDArray, fields []string) {
+	for i, f := range fields {
+		switch {
+		case i == 0:
+			a.State = f
+		case strings.HasPrefix(f, "("):
+			if strings.Contains(f, "read-only") {
+				a.ReadOnly = true
+			}
+		case strings.HasPrefix(f, "raid") || f == "linear" || f == "multipath":
+			a.Level = f
+		default:
+			m := mdMemberRe.FindStringSubmatch(f)
+			if m == nil {
+				continue
+			}
+			role, _ := strconv.Atoi(m[2])
+			member := MDMember{Name: m[1], Path: "/dev/" + m[1], Role: role, State: MDMemberActive}
+			switch {
+			case strings.Contains(m[3], "(F)"):
+				member.State = MDMemberFaulty
+			case strings.Contains(m[3], "(S)"):
+				member.State = MDMemberSpare
+			case strings.Contains(m[3], "(R)"):
+				member.State = MDMemberReplacement
+			}
+			a.Members = append(a.Members, member)
+		}
+	}
+}
+
+// finishMDArray derives the state and health of an array once all its lines are parsed
+func finishMDArray(a *MDArray) {
+	// mdstat lists the members from the last added one
+	sort.SliceStable(a.Members, func(i, j int) bool { return a.Members[i].Role < a.Members[j].Role })
+	a.SizeHuman = FormatBytes(a.Size)
+
+	switch {
+	case a.State != "active":
+		a.State = "inactive"
+		a.Health = HealthCritical
+	case a.RaidDevices > 0 && a.ActiveDevices < a.RaidDevices:
+		a.State = "degraded"
+		a.Health = HealthWarning
+	default:
+		a.Health = HealthOK
+	}
+}
+
+// Scan returns the resync, recovery or check running on the array, in the same
+// form as the scans of the ZFS pools
+func (a *MDArray) Scan() *ScanInfo {
+	if a.Sync == nil {
+		return &ScanInfo{State: ScanNone}
+	}
+	info := &ScanInfo{Function: a.Sync.Action, State: ScanRunning, Progress: a.Sync.Progress, Remaining: a.Sync.Remaining}
+	if a.Sync.Pending {
+		info.State = ScanPaused
+	}
+	return info
+}
+
+// GetMDScan returns the resync, recovery or check running on an md array
+func GetMDScan(name string) (*ScanInfo, error) {
+	a, err := GetMDArray(name)
+	if err != nil {
+		return nil, err
+	}
+	return a.Scan(), nil
+}
+
+// CreateMDArray creates a new md array, records it in mdadm.conf so that it is
+// assembled under the same name at boot, and formats it if asked to
+func CreateMDArray(opts MDCreateOptions) error {
+	if !IsMDAdmAvailable() {
+		return fmt.Errorf("mdadm is not available on this system")
+	}
+
+	if err := ValidateMDName(opts.Name); err != nil {
+		return err
+	}
+
+	if len(opts.Disks) == 0 {
+		return fmt.Errorf("at least one disk is required")
+	}
+	for _, disk := range append(append([]string{}, opts.Disks...), opts.Spares...) {
+		if err := ValidateDevicePath(disk); err != nil {
+			return fmt.Errorf("invalid disk %s: %w", disk, err)
+		}
+	}
+
+	// Validate level and disk count
+	var minDisks int
+	switch opts.Level {
+	case "raid1":
+		minDisks = 2
+	case "raid5":
+		minDisks = 3
+	case "raid6", "raid10":
+		minDisks = 4
+	default:
+		return fmt.Errorf("invalid RAID level: %s (use raid1, raid5, raid6, or raid10)", opts.Level)
+	}
+	if len(opts.Disks) < minDisks {
+		return fmt.Errorf("%s requires at least %d disks", opts.Level, minDisks)
+	}
+
+	switch opts.Filesystem {
+	case "", "ext4", "xfs":
+	default:
+		return fmt.Errorf("unsupported filesystem: %s (use ext4 or xfs)", opts.Filesystem)
+	}
+
+	device := "/dev/" + opts.Name
+	args := []string{"mdadm", "--create", device, "--run", "--level=" + strings.TrimPrefix(opts.Level, "raid"),
+		fmt.Sprintf("--raid-devices=%d", len(opts.Disks))}
+	if len(opts.Spares) > 0 {
+		args = append(args, fmt.Sprintf("--spare-devices=%d", len(opts.Spares)))
+	}
+	args = append(args, opts.Disks...)
+	args = append(args, opts.Spares...)
+
+	cmd := exec.Command("sudo", args...)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to create array: %s - %w", strings.TrimSpace(string(output)), err)
+	}
+
+	if err := saveMDAdmConf(device); err != nil {
+		return fmt.Errorf("array created but not saved to mdadm.conf: %w", err)
+	}
+
+	// The array can be formatted while its initial resync runs
+	if opts.Filesystem != "" {
+		mkfs := []string{"mkfs." + opts.Filesystem}
+		if opts.Filesystem == "ext4" {
+			mkfs = append(mkfs, "-F")
+		} else {
+			mkfs = append(mkfs, "-f")
+		}
+		cmd = exec.Command("sudo", append(mkfs, device)...)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("array created but failed to format it: %s - %w", strings.TrimSpace(string(output)), err)
+		}
+	}
+
+	return nil
+}
+
+// mdadmConfPath returns the mdadm configuration file of the distribution
+func mdadmConfPath() string {
+	if info, err := os.Stat("/etc/mdadm"); err == nil && info.IsDir() {
+		return "/etc/mdadm/mdadm.conf" // Debian, Ubuntu
+	}
+	return "/etc/mdadm.conf" // Fedora, RHEL, Arch
+}
+
+// saveMDAdmConf appends the ARRAY line of an array to mdadm.conf
+func saveMDAdmConf(device string) error {
+	cmd := exec.Command("sudo", "mdadm", "--detail", "--brief", device)
+	output, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("failed to get array details: %w", err)
+	}
+	line := strings.TrimSpace(string(output))
+	if line == "" {
+		return fmt.Errorf("mdadm returned no details for %s", device)
+	}
+
+	path := mdadmConfPath()
+	if content, err := os.ReadFile(path); err == nil {
+		for _, field := range strings.Fields(line) {
+			if strings.HasPrefix(field, "UUID=") && strings.Contains(string(content), field) {
+				return nil // Already recorded
+			}
+		}
+	}
+
+	cmd = exec.Command("sudo", "tee", "-a", path)
+	cmd.Stdin = strings.NewReader(line + "\n")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to update %s: %s - %w", path, strings.TrimSpace(string(output)), err)
+	}
+	return nil
+}
+
+// runMDAdm runs an mdadm management command on an array
+func runMDAdm(name, action string, args ...string) error {
+	if !IsMDAdmAvailable() {
+		return fmt.Errorf("mdadm is not available on this system")
+	}
+	if err := ValidateMDName(name); err != nil {
+		return err
+	}
+
+	cmd := exec.Command("sudo", append([]string{"mdadm", "/dev/" + name}, args...)...)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to %s: %s - %w", action, strings.TrimSpace(string(output)), err)
+	}
+	return nil
+}
+
+// validateMDMember checks a member of an array, given as a /dev path or as the
+// name /proc/mdstat shows (whole disk or partition)
+func validateMDMember(disk string) error {
+	if !strings.HasPrefix(disk, "/dev/") {
+		disk = "/dev/" + disk
+	}
+	return ValidateDiskPath(disk)
+}
+
+// AddMDDisk adds a disk to an md array. It becomes a hot spare, or rebuilds
+// the missing member of a degraded array.
+func AddMDDisk(name, disk string) error {
+	if err := ValidateDevicePath(disk); err != nil {
+		return err
+	}
+	return runMDAdm(name, "add disk", "--add", disk)
+}
+
+// FailMDDisk marks a member of an md array as faulty
+func FailMDDisk(name, disk string) error {
+	if err := validateMDMember(disk); err != nil {
+		return err
+	}
+	return runMDAdm(name, "fail disk", "--fail", mdMemberPath(disk))
+}
+
+// RemoveMDDisk removes a spare or faulty member from an md array
+func RemoveMDDisk(name, disk string) error {
+	if err := validateMDMember(disk); err != nil {
+		return err
+	}
+	return runMDAdm(name, "remove disk", "--remove", mdMemberPath(disk))
+}
+
+// ReplaceMDDisk copies a member of an md array onto a new disk, then marks the
+// old member as faulty. The array keeps its redundancy during the copy.
+func ReplaceMDDisk(name, oldDisk, newDisk string) error {
+	if err := validateMDMember(oldDisk); err != nil {
+		return fmt.Errorf("invalid old disk: %w", err)
+	}
+	if err := ValidateDevicePath(newDisk); err != nil {
+		return fmt.Errorf("invalid new disk: %w", err)
+	}
+	if err := runMDAdm(name, "add disk", "--add", newDisk); err != nil {
+		return err
+	}
+	return runMDAdm(name, "replace disk", "--replace", mdMemberPath(oldDisk), "--with", newDisk)
+}
+
+// mdMemberPath returns the /dev path of a member of an array
+func mdMemberPath(disk string) string {
+	if strings.HasPrefix(disk, "/dev/") {
+		return disk
+	}
+	return "/dev/" + disk
+}
+
+// setMDSyncAction writes an action to the sync_action of an md array
+func setMDSyncAction(name, action string) error {
+	if err := ValidateMDName(name); err != nil {
+		return err
+	}
+	path := filepath.Join("/sys/block", name, "md", "sync_action")
+	cmd := exec.Command("sudo", "tee", path)
+	cmd.Stdin = strings.NewReader(action + "\n")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to set %s on %s: %s - %w", action, name, strings.TrimSpace(string(output)), err)
+	}
+	return nil
+}
+
+// StartMDCheck starts a check of an md array, which reads all its members and
+// counts the mismatches between them
+func StartMDCheck(name string) error {
+	return setMDSyncAction(name, "check")
+}
+
+// StopMDCheck stops a running check of an md array
+func StopMDCheck(name string) error {
+	return setMDSyncAction(name, "idle")
+}
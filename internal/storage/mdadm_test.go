@@ -0,0 +1,79 @@
+package storage
+
+import "testing"
+
+const testMDStat = `Personalities : [raid1] [raid6] [raid5] [raid4] [raid10]
+md1 : active raid5 sde[4](S) sdf[2](F) sdd[3] sdc[1] sdb[0]
+      2095104 blocks super 1.2 level 5, 512k chunk, algorithm 2 [3/2] [UU_]
+      [=>...................]  recovery =  8.5% (89600/1047552) finish=0.5min speed=29866K/sec
+      bitmap: 0/1 pages [0KB], 65536KB chunk
+
+md0 : active raid1 sdb1[1] sda1[0]
+      1048512 blocks super 1.2 [2/2] [UU]
+      [==>..................]  check = 12.3% (129024/1048512) finish=10.2min speed=100000K/sec
+
+md2 : active (auto-read-only) raid1 sdh[1] sdg[0]
+      1048512 blocks super 1.2 [2/2] [UU]
+      	resync=PENDING
+
+md127 : inactive sdi[0](S)
+      1046528 blocks super 1.2
+
+unused devices: <none>
+`
+
+func TestParseMDStat(t *testing.T) {
+	arrays := ParseMDStat(testMDStat)
+	if len(arrays) != 4 {
+		t.Fatalf("ParseMDStat returned %d arrays, want 4", len(arrays))
+	}
+
+	md1 := arrays[0]
+	if md1.Name != "md1" || md1.Level != "raid5" || md1.State != "degraded" || md1.Health != HealthWarning {
+		t.Errorf("md1 = %+v", md1)
+	}
+	if md1.Size != 2095104*1024 || md1.RaidDevices != 3 || md1.ActiveDevices != 2 {
+		t.Errorf("md1 size/devices = %d %d/%d", md1.Size, md1.RaidDevices, md1.ActiveDevices)
+	}
+	if len(md1.Members) != 5 || md1.Members[0].Name != "sdb" || md1.Members[0].Path != "/dev/sdb" {
+		t.Fatalf("md1 members = %+v", md1.Members)
+	}
+	states := map[string]string{}
+	for _, m := range md1.Members {
+		states[m.Name] = m.State
+	}
+	if states["sde"] != MDMemberSpare || states["sdf"] != MDMemberFaulty || states["sdd"] != MDMemberActive {
+		t.Errorf("md1 member states = %v", states)
+	}
+	if md1.Sync == nil || md1.Sync.Action != "recovery" || md1.Sync.Progress != 8.5 || md1.Sync.Remaining != "0.5min" {
+		t.Errorf("md1 sync = %+v", md1.Sync)
+	}
+
+	md0 := arrays[1]
+	if md0.State != "active" || md0.Health != HealthOK || md0.Sync == nil || md0.Sync.Action != "check" {
+		t.Errorf("md0 = %+v, sync %+v", md0, md0.Sync)
+	}
+
+	md2 := arrays[2]
+	if !md2.ReadOnly || md2.Level != "raid1" || md2.Sync == nil || !md2.Sync.Pending || md2.Sync.Action != "resync" {
+		t.Errorf("md2 = %+v, sync %+v", md2, md2.Sync)
+	}
+
+	md127 := arrays[3]
+	if md127.State != "inactive" || md127.Health != HealthCritical || md127.Level != "" || len(md127.Members) != 1 {
+		t.Errorf("md127 = %+v", md127)
+	}
+}
+
+func TestValidateMDName(t *testing.T) {
+	for _, name := range []string{"md0", "md127"} {
+		if err := ValidateMDName(name); err != nil {
+			t.Errorf("ValidateMDName(%q) = %v", name, err)
+		}
+	}
+	for _, name := range []string{"", "md", "sda", "md0;rm", "md/data"} {
+		if err := ValidateMDName(name); err == nil {
+			t.Errorf("ValidateMDName(%q) accepted", name)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package storage
 
 import (
 	"encoding/json"
+	"fmt"
 	"os/exec"
 	"strconv"
 	"strings"
@@ -59,6 +60,46 @@ type smartctlOutput struct {
 			} `json:"raw"`
 		} `json:"table"`
 	} `json:"ata_smart_attributes"`
+	ATASmartData struct {
+		SelfTest struct {
+			Status struct {
+				Value            int    `json:"value"`
+				String           string `json:"string"`
+				RemainingPercent int    `json:"remaining_percent"`
+			} `json:"status"`
+		} `json:"self_test"`
+	} `json:"ata_smart_data"`
+	ATASmartSelfTestLog struct {
+		Standard struct {
+			Table []struct {
+				Type struct {
+					String string `json:"string"`
+				} `json:"type"`
+				Status struct {
+					Value  int    `json:"value"`
+					String string `json:"string"`
+					Passed bool   `json:"passed"`
+				} `json:"status"`
+				LifetimeHours int `json:"lifetime_hours"`
+			} `json:"table"`
+		} `json:"standard"`
+	} `json:"ata_smart_self_test_log"`
+	NVMeSelfTestLog struct {
+		CurrentSelfTestOperation struct {
+			Value int `json:"value"`
+		} `json:"current_self_test_operation"`
+		CurrentSelfTestCompletionPercent int `json:"current_self_test_completion_percent"`
+		Table []struct {
+			SelfTestCode struct {
+				String string `json:"string"`
+			} `json:"self_test_code"`
+			SelfTestResult struct {
+				Value  int    `json:"value"`
+				String string `json:"string"`
+			} `json:"self_test_result"`
+			PowerOnHours int `json:"power_on_hours"`
+		} `json:"table"`
+	} `json:"nvme_self_test_log"`
 	NVMeSmartHealthInfo struct {
 		Temperature         int `json:"temperature"`
 		AvailableSpare      int `json:"available_spare"`
@@ -85,6 +126,24 @@ const (
 	attrTemperatureAlt      = 190
 	attrCurrentPendingSector = 197
 	attrUncorrectableSector = 198
+	attrUDMACRCErrors       = 199
+)
+
+// SSD attributes whose normalized value is the remaining life in percent
+var wearAttributes = map[int]bool{
+	177: true, // Wear_Leveling_Count
+	202: true, // Percent_Lifetime_Remain
+	231: true, // SSD_Life_Left
+	233: true, // Media_Wearout_Indicator
+}
+
+// ataSelfTestRunning is the high nibble of the ATA self-test status while a test runs
+const ataSelfTestRunning = 0xF0
+
+// Self-test types accepted by StartSelfTest
+const (
+	SelfTestShort = "short"
+	SelfTestLong  = "long"
 )
 
 // IsSmartAvailable checks if smartctl is installed
@@ -140,6 +199,7 @@ func GetSMARTInfo(devicePath string) (*SMARTInfo, error) {
 	info := &SMARTInfo{
 		Available:   true,
 		Healthy:     smart.SmartStatus.Passed,
+		WearLevel:   -1,
 		LastChecked: time.Now(),
 	}
 
@@ -158,6 +218,23 @@ func GetSMARTInfo(devicePath string) (*SMARTInfo, error) {
 		info.PercentageUsed = smart.NVMeSmartHealthInfo.PercentageUsed
 		info.DataUnitsRead = smart.NVMeSmartHealthInfo.DataUnitsRead
 		info.DataUnitsWritten = smart.NVMeSmartHealthInfo.DataUnitsWritten
+		info.WearLevel = smart.NVMeSmartHealthInfo.PercentageUsed
+
+		// Self-tests
+		selfTests := smart.NVMeSelfTestLog
+		if selfTests.CurrentSelfTestOperation.Value != 0 {
+			info.SelfTestRunning = true
+			info.SelfTestRemaining = 100 - selfTests.CurrentSelfTestCompletionPercent
+		}
+		if len(selfTests.Table) > 0 {
+			last := selfTests.Table[0]
+			info.LastSelfTest = &SelfTestResult{
+				Type:         last.SelfTestCode.String,
+				Status:       last.SelfTestResult.String,
+				Passed:       last.SelfTestResult.Value == 0,
+				PowerOnHours: last.PowerOnHours,
+			}
+		}
 
 		// NVMe media errors are significant
 		if smart.NVMeSmartHealthInfo.MediaErrors > 0 {
@@ -176,6 +253,20 @@ func GetSMARTInfo(devicePath string) (*SMARTInfo, error) {
 	info.PowerOnHours = smart.PowerOnTime.Hours
 	info.PowerCycleCount = smart.PowerCycleCount
 
+	// Self-tests
+	if status := smart.ATASmartData.SelfTest.Status; status.Value&0xF0 == ataSelfTestRunning {
+		info.SelfTestRunning = true
+		info.SelfTestRemaining = status.RemainingPercent
+	}
+	if tests := smart.ATASmartSelfTestLog.Standard.Table; len(tests) > 0 {
+		info.LastSelfTest = &SelfTestResult{
+			Type:         tests[0].Type.String,
+			Status:       tests[0].Status.String,
+			Passed:       tests[0].Status.Passed,
+			PowerOnHours: tests[0].LifetimeHours,
+		}
+	}
+
 	// Parse ATA SMART attributes
 	for _, attr := range smart.ATASmartAttributes.Table {
 		smartAttr := SMARTAttribute{
@@ -215,6 +306,11 @@ func GetSMARTInfo(devicePath string) (*SMARTInfo, error) {
 			if info.PowerCycleCount == 0 {
 				info.PowerCycleCount = attr.Raw.Value
 			}
+		case attrUDMACRCErrors:
+			info.CRCErrors = attr.Raw.Value
+		}
+		if wearAttributes[attr.ID] && info.WearLevel < 0 {
+			info.WearLevel = max(0, 100-attr.Value)
 		}
 
 		info.Attributes = append(info.Attributes, smartAttr)
@@ -228,6 +324,30 @@ func GetSMARTInfo(devicePath string) (*SMARTInfo, error) {
 	return info, nil
 }
 
+// StartSelfTest starts a short or long SMART self-test. The disk runs it in the
+// background; its outcome shows in the self-test log of GetSMARTInfo.
+func StartSelfTest(devicePath, testType string) error {
+	if !IsSmartAvailable() {
+		return fmt.Errorf("smartctl is not available on this system")
+	}
+	if testType != SelfTestShort && testType != SelfTestLong {
+		return fmt.Errorf("invalid self-test type: %s", testType)
+	}
+	if !strings.HasPrefix(devicePath, "/dev/") {
+		return fmt.Errorf("invalid device path: %s", devicePath)
+	}
+
+	cmd := exec.Command("sudo", "smartctl", "-t", testType, devicePath)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		// smartctl returns non-zero bits for past errors even when the test started
+		if exitErr, ok := err.(*exec.ExitError); !ok || exitErr.ExitCode()&3 != 0 {
+			return fmt.Errorf("failed to start self-test: %s - %w", strings.TrimSpace(string(output)), err)
+		}
+	}
+	return nil
+}
+
 // GetDiskHealth determines health status from SMART info
 func GetDiskHealth(smart *SMARTInfo) HealthStatus {
 	if smart == nil || !smart.Available {
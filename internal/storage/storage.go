@@ -4,6 +4,7 @@
 // - Physical disk enumeration via lsblk
 // - SMART health monitoring via smartctl
 // - ZFS pool status via zpool/zfs commands
+// - Software RAID (mdadm) array status via /proc/mdstat
 package storage
 
 import (
@@ -83,10 +84,24 @@ type SMARTInfo struct {
 	DataUnitsRead        int64   `json:"data_units_read"`        // Data units read (in 512KB units)
 	DataUnitsWritten     int64   `json:"data_units_written"`     // Data units written (in 512KB units)
 
+	CRCErrors         int             `json:"crc_errors"`          // Interface CRC errors (cabling), ATA only
+	WearLevel         int             `json:"wear_level"`          // SSD life used (%), -1 if not reported
+	SelfTestRunning   bool            `json:"self_test_running"`   // A self-test is in progress
+	SelfTestRemaining int             `json:"self_test_remaining"` // Part of the running self-test left (%)
+	LastSelfTest      *SelfTestResult `json:"last_self_test"`      // Most recent entry of the self-test log
+
 	Attributes      []SMARTAttribute `json:"attributes"`   // Raw SMART attributes
 	LastChecked     time.Time    `json:"last_checked"`     // When SMART was last read
 }
 
+// SelfTestResult is an entry of the SMART self-test log of a disk
+type SelfTestResult struct {
+	Type         string `json:"type"`           // e.g. "Short offline", "Extended offline"
+	Status       string `json:"status"`         // e.g. "Completed without error"
+	Passed       bool   `json:"passed"`
+	PowerOnHours int    `json:"power_on_hours"` // Disk age when the test ran
+}
+
 // SMARTAttribute represents a single SMART attribute
 type SMARTAttribute struct {
 	ID         int    `json:"id"`
@@ -172,6 +187,8 @@ type StorageOverview struct {
 	Disks           []Disk       `json:"disks"`
 	Pools           []ZFSPool    `json:"pools"`
 	ZFSAvailable    bool         `json:"zfs_available"`
+	MDArrays        []MDArray    `json:"md_arrays"`
+	MDAvailable     bool         `json:"md_available"`
 	SMARTAvailable  bool         `json:"smart_available"`
 	LastUpdated     time.Time    `json:"last_updated"`
 }
@@ -195,6 +212,7 @@ func GetStorageOverview() (*StorageOverview, error) {
 	overview := &StorageOverview{
 		SMARTAvailable: IsSmartAvailable(),
 		ZFSAvailable:   IsZFSAvailable(),
+		MDAvailable:    IsMDAdmAvailable(),
 		LastUpdated:    time.Now(),
 	}
 
@@ -240,6 +258,15 @@ func GetStorageOverview() (*StorageOverview, error) {
 		}
 	}
 
+	// Get software RAID arrays
+	if overview.MDAvailable {
+		arrays, err := ListMDArrays()
+		if err != nil {
+			arrays = []MDArray{}
+		}
+		overview.MDArrays = arrays
+	}
+
 	// Format capacity strings
 	overview.TotalCapHuman = FormatBytes(overview.TotalCapacity)
 	overview.UsedCapHuman = FormatBytes(overview.UsedCapacity)
@@ -150,8 +150,8 @@ func getPoolVDevs(poolName string) ([]ZFSVDev, error) {
 			break
 		}
 
-		// Skip pool name line and headers
-		if strings.Contains(line, poolName) && strings.Contains(line, "ONLINE") {
+		// Skip pool name line, whatever the pool state, and headers
+		if fields := strings.Fields(line); len(fields) > 0 && fields[0] == poolName {
 			continue
 		}
 		if strings.Contains(line, "NAME") && strings.Contains(line, "STATE") {
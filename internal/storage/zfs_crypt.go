@@ -0,0 +1,83 @@
+// Package storage provides ZFS native encryption key management.
+package storage
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// encryptionProperties are set on the encryption roots created by Anemone:
+// the raw 32-byte key is given as hex on stdin, never stored by ZFS
+var encryptionProperties = []string{
+	"encryption=aes-256-gcm",
+	"keyformat=hex",
+	"keylocation=prompt",
+}
+
+// ZFS key status values
+const (
+	KeyStatusAvailable   = "available"
+	KeyStatusUnavailable = "unavailable"
+	KeyStatusNone        = "none" // not encrypted
+)
+
+// GetKeyStatus returns the key status of an encrypted dataset or pool
+func GetKeyStatus(name string) (string, error) {
+	if !IsZFSAvailable() {
+		return "", fmt.Errorf("ZFS is not available on this system")
+	}
+	status, err := GetDatasetProperty(name, "keystatus")
+	if err != nil {
+		return "", err
+	}
+	if status == "-" || status == "" {
+		return KeyStatusNone, nil
+	}
+	return status, nil
+}
+
+// LoadKey loads the key of an encryption root and mounts its datasets
+func LoadKey(name, key string) error {
+	if !IsZFSAvailable() {
+		return fmt.Errorf("ZFS is not available on this system")
+	}
+	if err := ValidateDatasetNameOrPool(name); err != nil {
+		return err
+	}
+
+	cmd := exec.Command("sudo", "zfs", "load-key", name)
+	cmd.Stdin = strings.NewReader(key)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to load key: %s - %w", strings.TrimSpace(string(output)), err)
+	}
+
+	// Mount the datasets that can now be decrypted
+	cmd = exec.Command("sudo", "zfs", "mount", "-a")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("key loaded but failed to mount: %s - %w", strings.TrimSpace(string(output)), err)
+	}
+	return nil
+}
+
+// UnloadKey unmounts an encryption root and its children, then unloads its key
+func UnloadKey(name string) error {
+	if !IsZFSAvailable() {
+		return fmt.Errorf("ZFS is not available on this system")
+	}
+	if err := ValidateDatasetNameOrPool(name); err != nil {
+		return err
+	}
+
+	// unload-key refuses mounted datasets, unmount them first
+	cmd := exec.Command("sudo", "zfs", "unmount", name)
+	if output, err := cmd.CombinedOutput(); err != nil && !strings.Contains(string(output), "not currently mounted") {
+		return fmt.Errorf("failed to unmount: %s - %w", strings.TrimSpace(string(output)), err)
+	}
+
+	cmd = exec.Command("sudo", "zfs", "unload-key", name)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to unload key: %s - %w", strings.TrimSpace(string(output)), err)
+	}
+	return nil
+}
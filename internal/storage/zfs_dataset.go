@@ -20,6 +20,10 @@ type DatasetCreateOptions struct {
 	Atime       string `json:"atime"`       // on, off
 	Sync        string `json:"sync"`        // standard, always, disabled
 	Owner       string `json:"owner"`       // Owner user:group for mountpoint (optional, e.g., "anemone:anemone")
+
+	// EncryptionKey makes the dataset an encryption root with this hex key
+	// (optional, never serialized)
+	EncryptionKey string `json:"-"`
 }
 
 // DatasetInfo contains detailed dataset information
@@ -109,10 +113,18 @@ func CreateDataset(opts DatasetCreateOptions) error {
 	if opts.Sync != "" {
 		args = append(args, "-o", fmt.Sprintf("sync=%s", opts.Sync))
 	}
+	if opts.EncryptionKey != "" {
+		for _, prop := range encryptionProperties {
+			args = append(args, "-o", prop)
+		}
+	}
 
 	args = append(args, opts.Name)
 
 	cmd := exec.Command("sudo", args...)
+	if opts.EncryptionKey != "" {
+		cmd.Stdin = strings.NewReader(opts.EncryptionKey)
+	}
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("failed to create dataset: %s - %w", strings.TrimSpace(string(output)), err)
@@ -373,6 +385,32 @@ func ListDatasets(parent string) ([]DatasetInfo, error) {
 	return datasets, nil
 }
 
+// MountedFilesystems returns the mountpoints of the mounted ZFS filesystems,
+// keyed by dataset name
+func MountedFilesystems() (map[string]string, error) {
+	if !IsZFSAvailable() {
+		return nil, fmt.Errorf("ZFS is not available on this system")
+	}
+
+	cmd := exec.Command("sudo", "zfs", "list", "-H", "-t", "filesystem", "-o", "name,mountpoint,mounted")
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list filesystems: %w", err)
+	}
+
+	mounts := make(map[string]string)
+	scanner := bufio.NewScanner(strings.NewReader(string(output)))
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) < 3 || fields[2] != "yes" || !strings.HasPrefix(fields[1], "/") {
+			continue
+		}
+		mounts[fields[0]] = fields[1]
+	}
+
+	return mounts, nil
+}
+
 // RenameDataset renames a dataset
 func RenameDataset(oldName, newName string) error {
 	if !IsZFSAvailable() {
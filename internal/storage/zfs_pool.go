@@ -19,6 +19,10 @@ type PoolCreateOptions struct {
 	Compression string   `json:"compression"` // Compression type: off, lz4, zstd, gzip (optional)
 	Ashift      int      `json:"ashift"`      // Sector size alignment (optional, 0 for auto)
 	Owner       string   `json:"owner"`       // Owner user:group for mountpoint (optional, e.g., "anemone:anemone")
+
+	// EncryptionKey enables native encryption of the root dataset with this
+	// hex key (optional, never serialized)
+	EncryptionKey string `json:"-"`
 }
 
 // ImportablePool represents a pool that can be imported
@@ -69,6 +73,19 @@ func ValidateDiskPath(path string) error {
 	return nil
 }
 
+// ValidateVDevName checks a device of a pool, given either as a /dev path or as
+// the name zpool status shows, such as a by-id name or the GUID of a missing disk
+func ValidateVDevName(name string) error {
+	if strings.HasPrefix(name, "/dev/") {
+		return ValidateDiskPath(name)
+	}
+	validName := regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.:\-]*$`)
+	if !validName.MatchString(name) {
+		return fmt.Errorf("invalid device name format")
+	}
+	return nil
+}
+
 // ValidateMountpoint checks if a mountpoint path is valid
 func ValidateMountpoint(path string) error {
 	if path == "" {
@@ -165,6 +182,13 @@ func CreatePool(opts PoolCreateOptions) error {
 		args = append(args, "-O", fmt.Sprintf("compression=%s", opts.Compression))
 	}
 
+	// Add native encryption, the key is read from stdin
+	if opts.EncryptionKey != "" {
+		for _, prop := range encryptionProperties {
+			args = append(args, "-O", prop)
+		}
+	}
+
 	// Add pool name
 	args = append(args, opts.Name)
 
@@ -182,6 +206,9 @@ func CreatePool(opts PoolCreateOptions) error {
 
 	// Execute command
 	cmd := exec.Command("sudo", args...)
+	if opts.EncryptionKey != "" {
+		cmd.Stdin = strings.NewReader(opts.EncryptionKey)
+	}
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("failed to create pool: %s - %w", strings.TrimSpace(string(output)), err)
@@ -432,7 +459,7 @@ func ReplaceDisk(opts ReplaceOptions) error {
 		return err
 	}
 
-	if err := ValidateDiskPath(opts.OldDisk); err != nil {
+	if err := ValidateVDevName(opts.OldDisk); err != nil {
 		return fmt.Errorf("invalid old disk: %w", err)
 	}
 
@@ -509,7 +536,7 @@ func OnlineDisk(poolName, disk string) error {
 		return err
 	}
 
-	if err := ValidateDiskPath(disk); err != nil {
+	if err := ValidateVDevName(disk); err != nil {
 		return err
 	}
 
@@ -532,7 +559,7 @@ func OfflineDisk(poolName, disk string, temporary bool) error {
 		return err
 	}
 
-	if err := ValidateDiskPath(disk); err != nil {
+	if err := ValidateVDevName(disk); err != nil {
 		return err
 	}
 
@@ -0,0 +1,154 @@
+package storage
+
+import (
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Scan states of a pool
+const (
+	ScanNone     = "none"
+	ScanRunning  = "running"
+	ScanPaused   = "paused"
+	ScanFinished = "finished"
+	ScanCanceled = "canceled"
+)
+
+// ScanInfo is the last or current scrub or resilver of a pool, as reported by
+// the scan section of zpool status
+type ScanInfo struct {
+	Function        string    `json:"function"` // scrub or resilver, "" if the pool was never scanned
+	State           string    `json:"state"`
+	Start           time.Time `json:"start"` // Set while running or paused
+	End             time.Time `json:"end"`   // Set once finished or canceled
+	DurationSeconds int64     `json:"duration_seconds"`
+	Repaired        uint64    `json:"repaired"` // Bytes repaired, or resilvered
+	Errors          uint64    `json:"errors"`
+	Progress        float64   `json:"progress"`  // Percent done while running
+	Remaining       string    `json:"remaining"` // Time left while running, as zpool prints it
+}
+
+// zpoolTimeLayout is the format of the dates of zpool status
+const zpoolTimeLayout = "Mon Jan _2 15:04:05 2006"
+
+var (
+	scanFinishedRegex = regexp.MustCompile(`^(scrub repaired|resilvered) (\S+) in (.+?) with (\d+) errors on (.+)$`)
+	scanRunningRegex  = regexp.MustCompile(`^(scrub|resilver) in progress since (.+)$`)
+	scanPausedRegex   = regexp.MustCompile(`^(scrub) paused since (.+)$`)
+	scanCanceledRegex = regexp.MustCompile(`^(scrub|resilver) canceled on (.+)$`)
+	scanProgressRegex = regexp.MustCompile(`([\d.]+)% done`)
+	scanRemainRegex   = regexp.MustCompile(`(\S+) to go`)
+	scanRepairedRegex = regexp.MustCompile(`(\S+) (?:repaired|resilvered),`)
+	scanDurationRegex = regexp.MustCompile(`^(?:(\d+) days? )?(\d+):(\d+):(\d+)$`)
+)
+
+// ParseScan parses the scan section of zpool status, without the "scan:" prefix.
+// Lines after the first one carry the progress of a running scan.
+func ParseScan(section string) ScanInfo {
+	lines := strings.Split(strings.TrimSpace(section), "\n")
+	first := strings.Join(strings.Fields(lines[0]), " ")
+	rest := strings.Join(lines[1:], " ")
+	info := ScanInfo{State: ScanNone}
+
+	if m := scanFinishedRegex.FindStringSubmatch(first); m != nil {
+		info.Function = "scrub"
+		if m[1] == "resilvered" {
+			info.Function = "resilver"
+		}
+		info.State = ScanFinished
+		info.Repaired = parseScanSize(m[2])
+		info.DurationSeconds = parseScanDuration(m[3])
+		info.Errors, _ = strconv.ParseUint(m[4], 10, 64)
+		info.End = parseZpoolTime(m[5])
+		return info
+	}
+	if m := scanCanceledRegex.FindStringSubmatch(first); m != nil {
+		info.Function, info.State, info.End = m[1], ScanCanceled, parseZpoolTime(m[2])
+		return info
+	}
+
+	m := scanRunningRegex.FindStringSubmatch(first)
+	if m != nil {
+		info.State = ScanRunning
+	} else if m = scanPausedRegex.FindStringSubmatch(first); m != nil {
+		info.State = ScanPaused
+	} else {
+		return info
+	}
+	info.Function, info.Start = m[1], parseZpoolTime(m[2])
+	if p := scanProgressRegex.FindStringSubmatch(rest); p != nil {
+		info.Progress, _ = strconv.ParseFloat(p[1], 64)
+	}
+	if r := scanRemainRegex.FindStringSubmatch(rest); r != nil {
+		info.Remaining = r[1]
+	}
+	if r := scanRepairedRegex.FindStringSubmatch(rest); r != nil {
+		info.Repaired = parseScanSize(r[1])
+	}
+	return info
+}
+
+// parseZpoolTime parses a local date printed by zpool status
+func parseZpoolTime(s string) time.Time {
+	t, _ := time.ParseInLocation(zpoolTimeLayout, strings.TrimSpace(s), time.Local)
+	return t
+}
+
+// parseScanDuration parses "01:02:03" or "2 days 01:02:03" to seconds
+func parseScanDuration(s string) int64 {
+	m := scanDurationRegex.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return 0
+	}
+	var n [4]int64
+	for i := range n {
+		n[i], _ = strconv.ParseInt(m[i+1], 10, 64)
+	}
+	return n[0]*86400 + n[1]*3600 + n[2]*60 + n[3]
+}
+
+// parseScanSize parses a size printed by zpool status, such as "0B" or "1.50M"
+func parseScanSize(s string) uint64 {
+	size, _ := parseSize(strings.TrimSuffix(s, "B"))
+	return size
+}
+
+// GetPoolScan returns the last or current scrub or resilver of a pool
+func GetPoolScan(poolName string) (*ScanInfo, error) {
+	if err := ValidatePoolName(poolName); err != nil {
+		return nil, err
+	}
+
+	output, err := exec.Command("sudo", "zpool", "status", poolName).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pool status: %w", err)
+	}
+
+	info := ParseScan(scanSection(string(output)))
+	return &info, nil
+}
+
+// scanSection returns the scan section of zpool status: the "scan:" line and
+// the indented lines following it
+func scanSection(status string) string {
+	var section []string
+	for _, line := range strings.Split(status, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if len(section) == 0 {
+			if strings.HasPrefix(trimmed, "scan:") {
+				section = append(section, strings.TrimSpace(strings.TrimPrefix(trimmed, "scan:")))
+			}
+			continue
+		}
+		// Continuation lines are indented with a tab, the next section is not
+		if trimmed == "" || !strings.HasPrefix(line, "\t") {
+			break
+		}
+		section = append(section, trimmed)
+	}
+	return strings.Join(section, "\n")
+}
@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseScan(t *testing.T) {
+	finished := ParseScan("scrub repaired 1.50M in 1 days 02:03:04 with 2 errors on Sun Mar  1 00:24:03 2026")
+	if finished.Function != "scrub" || finished.State != ScanFinished {
+		t.Fatalf("finished scrub = %+v", finished)
+	}
+	if finished.Repaired != 1572864 || finished.Errors != 2 || finished.DurationSeconds != 93784 {
+		t.Errorf("finished scrub = %+v", finished)
+	}
+	if !finished.End.Equal(time.Date(2026, 3, 1, 0, 24, 3, 0, time.Local)) {
+		t.Errorf("scrub end = %v", finished.End)
+	}
+
+	resilvered := ParseScan("resilvered 0B in 00:00:05 with 0 errors on Mon Mar 16 10:00:00 2026")
+	if resilvered.Function != "resilver" || resilvered.Repaired != 0 || resilvered.DurationSeconds != 5 {
+		t.Errorf("resilver = %+v", resilvered)
+	}
+
+	running := ParseScan("scrub in progress since Sun Mar  1 00:24:01 2026\n" +
+		"1.23G / 4.56G scanned at 100M/s, 500M / 4.56G issued at 50M/s\n" +
+		"12K repaired, 10.96% done, 00:01:23 to go")
+	if running.State != ScanRunning || running.Progress != 10.96 || running.Remaining != "00:01:23" || running.Repaired != 12288 {
+		t.Errorf("running scrub = %+v", running)
+	}
+	if running.Start.IsZero() {
+		t.Errorf("running scrub has no start")
+	}
+
+	if c := ParseScan("scrub canceled on Sun Mar  1 00:30:00 2026"); c.State != ScanCanceled || c.End.IsZero() {
+		t.Errorf("canceled scrub = %+v", c)
+	}
+	if n := ParseScan("none requested"); n.State != ScanNone || n.Function != "" {
+		t.Errorf("no scan = %+v", n)
+	}
+}
+
+func TestScanSection(t *testing.T) {
+	status := "  pool: tank\n" +
+		" state: ONLINE\n" +
+		"  scan: resilver in progress since Sun Mar  1 00:24:01 2026\n" +
+		"\t1.2G / 2G scanned, 1G / 2G issued at 10M/s\n" +
+		"\t1G resilvered, 50.00% done, 00:01:40 to go\n" +
+		"config:\n"
+
+	got := scanSection(status)
+	want := "resilver in progress since Sun Mar  1 00:24:01 2026\n" +
+		"1.2G / 2G scanned, 1G / 2G issued at 10M/s\n" +
+		"1G resilvered, 50.00% done, 00:01:40 to go"
+	if got != want {
+		t.Errorf("scanSection = %q", got)
+	}
+	if info := ParseScan(got); info.Function != "resilver" || info.Progress != 50 {
+		t.Errorf("resilver = %+v", info)
+	}
+}
@@ -0,0 +1,178 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// resumeTokenPattern matches the opaque tokens ZFS hands out for interrupted receives
+var resumeTokenPattern = regexp.MustCompile(`^[0-9a-zA-Z-]+$`)
+
+// SendStream is the output of a running zfs send
+type SendStream struct {
+	io.Reader
+	cmd    *exec.Cmd
+	stderr bytes.Buffer
+}
+
+// sendArgs returns the zfs send arguments for the options, without "zfs send"
+func sendArgs(opts SendOptions) ([]string, error) {
+	if opts.ResumeToken != "" {
+		if !resumeTokenPattern.MatchString(opts.ResumeToken) {
+			return nil, fmt.Errorf("invalid resume token")
+		}
+		return []string{"-t", opts.ResumeToken}, nil
+	}
+
+	if err := ValidateSnapshotName(opts.Snapshot); err != nil {
+		return nil, err
+	}
+	var args []string
+	if opts.Raw {
+		args = append(args, "-w")
+	}
+	if opts.Compressed {
+		args = append(args, "-c")
+	}
+	if opts.Incremental != "" {
+		if err := ValidateSnapshotName(opts.Incremental); err != nil {
+			return nil, fmt.Errorf("invalid incremental snapshot: %w", err)
+		}
+		args = append(args, "-i", opts.Incremental)
+	}
+	return append(args, opts.Snapshot), nil
+}
+
+// Send starts a zfs send and returns its stream. The caller must read the stream
+// to the end and then call Wait.
+func Send(opts SendOptions) (*SendStream, error) {
+	if !IsZFSAvailable() {
+		return nil, fmt.Errorf("ZFS is not available on this system")
+	}
+
+	args, err := sendArgs(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &SendStream{cmd: exec.Command("sudo", append([]string{"zfs", "send"}, args...)...)}
+	s.cmd.Stderr = &s.stderr
+	stdout, err := s.cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start send: %w", err)
+	}
+	if err := s.cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start send: %w", err)
+	}
+	s.Reader = stdout
+	return s, nil
+}
+
+// Wait waits for the send to finish
+func (s *SendStream) Wait() error {
+	if err := s.cmd.Wait(); err != nil {
+		return fmt.Errorf("send failed: %s - %w", strings.TrimSpace(s.stderr.String()), err)
+	}
+	return nil
+}
+
+// Abort stops a running send
+func (s *SendStream) Abort() {
+	if s.cmd.Process != nil {
+		s.cmd.Process.Kill()
+	}
+	s.cmd.Wait()
+}
+
+// Receive writes a send stream into a dataset. The dataset is read-only and not
+// mounted, even at boot, and does not take the mountpoint of its source. An
+// interrupted receive leaves a resume token on it. force rolls the dataset back
+// to its most recent snapshot first, discarding changes made since.
+func Receive(dataset string, r io.Reader, force bool) error {
+	if !IsZFSAvailable() {
+		return fmt.Errorf("ZFS is not available on this system")
+	}
+
+	if err := ValidateDatasetName(dataset); err != nil {
+		return err
+	}
+
+	args := []string{"zfs", "receive", "-s", "-u", "-o", "canmount=noauto", "-o", "readonly=on", "-x", "mountpoint"}
+	if force {
+		args = append(args, "-F")
+	}
+	args = append(args, dataset)
+
+	cmd := exec.Command("sudo", args...)
+	cmd.Stdin = r
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to receive stream: %s - %w", strings.TrimSpace(string(output)), err)
+	}
+
+	return nil
+}
+
+// AbortReceive discards the partial state of an interrupted receive
+func AbortReceive(dataset string) error {
+	if !IsZFSAvailable() {
+		return fmt.Errorf("ZFS is not available on this system")
+	}
+
+	if err := ValidateDatasetName(dataset); err != nil {
+		return err
+	}
+
+	cmd := exec.Command("sudo", "zfs", "receive", "-A", dataset)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to abort receive: %s - %w", strings.TrimSpace(string(output)), err)
+	}
+
+	return nil
+}
+
+// GetResumeToken returns the resume token of an interrupted receive into a
+// dataset, or "" if there is none
+func GetResumeToken(dataset string) (string, error) {
+	token, err := GetDatasetProperty(dataset, "receive_resume_token")
+	if err != nil {
+		return "", err
+	}
+	if token == "-" {
+		return "", nil
+	}
+	return token, nil
+}
+
+// DatasetExists reports whether a dataset exists
+func DatasetExists(name string) bool {
+	if !IsZFSAvailable() || ValidateDatasetNameOrPool(name) != nil {
+		return false
+	}
+	return exec.Command("sudo", "zfs", "list", "-H", "-o", "name", name).Run() == nil
+}
+
+// CreateDatasetParents creates a dataset and its missing parents if they do not
+// exist, without mounting them
+func CreateDatasetParents(name string) error {
+	if !IsZFSAvailable() {
+		return fmt.Errorf("ZFS is not available on this system")
+	}
+
+	if err := ValidateDatasetName(name); err != nil {
+		return err
+	}
+
+	cmd := exec.Command("sudo", "zfs", "create", "-p", "-u", name)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to create dataset: %s - %w", strings.TrimSpace(string(output)), err)
+	}
+
+	return nil
+}
@@ -399,6 +399,40 @@ func ReleaseSnapshot(name, tag string) error {
 	return nil
 }
 
+// ListHeldSnapshots returns the full names of the snapshots of a dataset and its
+// children that carry at least one hold
+func ListHeldSnapshots(dataset string) (map[string]bool, error) {
+	if !IsZFSAvailable() {
+		return nil, fmt.Errorf("ZFS is not available on this system")
+	}
+
+	if err := ValidateDatasetNameOrPool(dataset); err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command("sudo", "zfs", "list", "-H", "-p", "-t", "snapshot",
+		"-o", "name,userrefs",
+		"-r", dataset)
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshot holds: %w", err)
+	}
+
+	held := make(map[string]bool)
+	scanner := bufio.NewScanner(strings.NewReader(string(output)))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		if refs, err := strconv.Atoi(fields[1]); err == nil && refs > 0 {
+			held[fields[0]] = true
+		}
+	}
+
+	return held, nil
+}
+
 // GetSnapshotDiff shows differences between a snapshot and the live dataset
 func GetSnapshotDiff(snapshot string) (string, error) {
 	if !IsZFSAvailable() {
@@ -424,6 +458,7 @@ type SendOptions struct {
 	Incremental string `json:"incremental"` // Base snapshot for incremental send
 	Raw         bool   `json:"raw"`         // Raw send (encrypted)
 	Compressed  bool   `json:"compressed"`  // Compressed stream
+	ResumeToken string `json:"resume_token"` // Resume an interrupted send (other options are ignored)
 }
 
 // EstimateSendSize estimates the size of a send stream
@@ -432,18 +467,11 @@ func EstimateSendSize(opts SendOptions) (uint64, error) {
 		return 0, fmt.Errorf("ZFS is not available on this system")
 	}
 
-	if err := ValidateSnapshotName(opts.Snapshot); err != nil {
+	args, err := sendArgs(opts)
+	if err != nil {
 		return 0, err
 	}
-
-	args := []string{"zfs", "send", "-nv"}
-	if opts.Incremental != "" {
-		if err := ValidateSnapshotName(opts.Incremental); err != nil {
-			return 0, fmt.Errorf("invalid incremental snapshot: %w", err)
-		}
-		args = append(args, "-i", opts.Incremental)
-	}
-	args = append(args, opts.Snapshot)
+	args = append([]string{"zfs", "send", "-nv"}, args...)
 
 	cmd := exec.Command("sudo", args...)
 	output, err := cmd.CombinedOutput()
@@ -23,6 +23,9 @@ type FileMetadata struct {
 	ModTime       time.Time `json:"mtime"`
 	Checksum      string    `json:"checksum"`
 	EncryptedPath string    `json:"encrypted_path"`
+
+	// SHA-256 of the encrypted file as sent to the peer, used by integrity checks
+	EncryptedChecksum string `json:"encrypted_checksum,omitempty"`
 }
 
 // SyncManifest represents the complete manifest of synced files
@@ -0,0 +1,159 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+// This file evaluates per-share replication against each peer's last sync.
+
+package sync
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/juste-un-gars/anemone/internal/shares"
+)
+
+// PeerReplica describes the state of one share copy on one peer
+type PeerReplica struct {
+	PeerID   int
+	PeerName string
+	LastSync *time.Time // Last successful sync of the share to this peer
+	Healthy  bool       // Latest completed sync to this peer succeeded
+}
+
+// ShareReplication describes how many peers hold an up-to-date copy of a share
+type ShareReplication struct {
+	ShareID     int
+	UserID      int
+	Username    string
+	ShareName   string
+	MinReplicas int
+	Replicas    int // Number of target peers whose latest completed sync succeeded
+	Targets     []PeerReplica
+}
+
+// UnderReplicated reports whether the share is held by fewer peers than required
+func (r *ShareReplication) UnderReplicated() bool {
+	return r.MinReplicas > 0 && r.Replicas < r.MinReplicas
+}
+
+// GetShareReplication evaluates replication for every sync-enabled share.
+// A peer counts as a replica when it is enabled, selected for the share and its
+// most recent completed sync of that share succeeded.
+func GetShareReplication(db *sql.DB) ([]*ShareReplication, error) {
+	rows, err := db.Query(`SELECT s.id, s.user_id, u.username, s.name, COALESCE(s.min_replicas, 0)
+	                       FROM shares s
+	                       JOIN users u ON s.user_id = u.id
+	                       WHERE s.sync_enabled = 1
+	                       ORDER BY u.username, s.name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query shares: %w", err)
+	}
+	defer rows.Close()
+
+	var result []*ShareReplication
+	for rows.Next() {
+		r := &ShareReplication{}
+		if err := rows.Scan(&r.ShareID, &r.UserID, &r.Username, &r.ShareName, &r.MinReplicas); err != nil {
+			return nil, fmt.Errorf("failed to scan share: %w", err)
+		}
+		result = append(result, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate shares: %w", err)
+	}
+
+	if len(result) == 0 {
+		return result, nil
+	}
+
+	type peerInfo struct {
+		ID   int
+		Name string
+	}
+	peerRows, err := db.Query(`SELECT id, name FROM peers WHERE enabled = 1 ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query peers: %w", err)
+	}
+	defer peerRows.Close()
+
+	var enabledPeers []peerInfo
+	for peerRows.Next() {
+		var p peerInfo
+		if err := peerRows.Scan(&p.ID, &p.Name); err != nil {
+			return nil, fmt.Errorf("failed to scan peer: %w", err)
+		}
+		enabledPeers = append(enabledPeers, p)
+	}
+
+	peerMap, err := shares.GetPeerMap(db)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, r := range result {
+		for _, p := range enabledPeers {
+			if !peerMap.Targets(r.ShareID, p.ID) {
+				continue
+			}
+
+			replica := PeerReplica{PeerID: p.ID, PeerName: p.Name}
+			replica.Healthy, replica.LastSync, err = lastShareSyncState(db, r.ShareID, p.ID)
+			if err != nil {
+				return nil, err
+			}
+			if replica.Healthy {
+				r.Replicas++
+			}
+			r.Targets = append(r.Targets, replica)
+		}
+	}
+
+	return result, nil
+}
+
+// GetUnderReplicatedShares returns the shares held by fewer peers than their replication factor
+func GetUnderReplicatedShares(db *sql.DB) ([]*ShareReplication, error) {
+	all, err := GetShareReplication(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var under []*ShareReplication
+	for _, r := range all {
+		if r.UnderReplicated() {
+			under = append(under, r)
+		}
+	}
+	return under, nil
+}
+
+// lastShareSyncState returns whether the latest completed sync of a share to a peer
+// succeeded, along with the time of the last successful one
+func lastShareSyncState(db *sql.DB, shareID, peerID int) (bool, *time.Time, error) {
+	var status string
+	err := db.QueryRow(`SELECT status FROM sync_log
+	                    WHERE share_id = ? AND peer_id = ? AND status != 'running'
+	                    ORDER BY started_at DESC, id DESC LIMIT 1`, shareID, peerID).Scan(&status)
+	if err == sql.ErrNoRows {
+		return false, nil, nil
+	}
+	if err != nil {
+		return false, nil, fmt.Errorf("failed to query last sync: %w", err)
+	}
+
+	var lastSuccess sql.NullTime
+	err = db.QueryRow(`SELECT completed_at FROM sync_log
+	                   WHERE share_id = ? AND peer_id = ? AND status = 'success'
+	                   ORDER BY completed_at DESC LIMIT 1`, shareID, peerID).Scan(&lastSuccess)
+	if err != nil && err != sql.ErrNoRows {
+		return false, nil, fmt.Errorf("failed to query last successful sync: %w", err)
+	}
+
+	var lastSync *time.Time
+	if lastSuccess.Valid {
+		lastSync = &lastSuccess.Time
+	}
+	return status == "success", lastSync, nil
+}
@@ -17,6 +17,7 @@ import (
 	"github.com/juste-un-gars/anemone/internal/crypto"
 	"github.com/juste-un-gars/anemone/internal/logger"
 	"github.com/juste-un-gars/anemone/internal/peers"
+	"github.com/juste-un-gars/anemone/internal/shares"
 )
 
 // SyncLog represents a synchronization log entry
@@ -24,6 +25,7 @@ type SyncLog struct {
 	ID           int
 	UserID       int
 	PeerID       int
+	ShareID      *int // NULL for entries created before per-share tracking
 	StartedAt    time.Time
 	CompletedAt  *time.Time
 	Status       string // "running", "success", "error"
@@ -43,14 +45,39 @@ type SyncRequest struct {
 	PeerPassword     string // Optional password for peer authentication
 	SourceServer     string // Name of the source server (for manifest identification)
 	PeerTimeoutHours int    // Sync timeout in hours (0 = disabled)
+	VerifyUploads    bool   // Ask the peer to read each uploaded file back from disk
+
+	// Group shares have no user (UserID 0): they name their backup on the
+	// peer and are encrypted with the key of their group
+	GroupShareID  int    // Group share synchronized instead of a user share
+	ShareName     string // Name of the backup on the peer ("" = from SharePath)
+	EncryptionKey string // Key encrypting the files ("" = key of the user)
+}
+
+// CreateSyncLog creates a new sync log entry for a share and returns its ID
+func CreateSyncLog(db *sql.DB, userID, peerID, shareID int) (int, error) {
+	query := `INSERT INTO sync_log (user_id, peer_id, share_id, started_at, status)
+	          VALUES (?, ?, ?, CURRENT_TIMESTAMP, 'running')`
+
+	result, err := db.Exec(query, userID, peerID, shareID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create sync log: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get sync log ID: %w", err)
+	}
+
+	return int(id), nil
 }
 
-// CreateSyncLog creates a new sync log entry and returns its ID
-func CreateSyncLog(db *sql.DB, userID, peerID int) (int, error) {
-	query := `INSERT INTO sync_log (user_id, peer_id, started_at, status)
+// CreateGroupSyncLog creates a new sync log entry for a group share and returns its ID
+func CreateGroupSyncLog(db *sql.DB, peerID, groupShareID int) (int, error) {
+	query := `INSERT INTO sync_log (peer_id, group_share_id, started_at, status)
 	          VALUES (?, ?, CURRENT_TIMESTAMP, 'running')`
 
-	result, err := db.Exec(query, userID, peerID)
+	result, err := db.Exec(query, peerID, groupShareID)
 	if err != nil {
 		return 0, fmt.Errorf("failed to create sync log: %w", err)
 	}
@@ -79,7 +106,7 @@ func UpdateSyncLog(db *sql.DB, logID int, status string, filesSynced int, bytesS
 
 // GetLastSyncByUser retrieves the last sync log for a user
 func GetLastSyncByUser(db *sql.DB, userID int) (*SyncLog, error) {
-	query := `SELECT id, user_id, peer_id, started_at, completed_at, status, files_synced, bytes_synced, error_message
+	query := `SELECT id, user_id, peer_id, share_id, started_at, completed_at, status, files_synced, bytes_synced, error_message
 	          FROM sync_log
 	          WHERE user_id = ?
 	          ORDER BY started_at DESC
@@ -87,7 +114,7 @@ func GetLastSyncByUser(db *sql.DB, userID int) (*SyncLog, error) {
 
 	log := &SyncLog{}
 	err := db.QueryRow(query, userID).Scan(
-		&log.ID, &log.UserID, &log.PeerID, &log.StartedAt, &log.CompletedAt,
+		&log.ID, &log.UserID, &log.PeerID, &log.ShareID, &log.StartedAt, &log.CompletedAt,
 		&log.Status, &log.FilesSynced, &log.BytesSynced, &log.ErrorMessage,
 	)
 	if err != nil {
@@ -102,7 +129,7 @@ func GetLastSyncByUser(db *sql.DB, userID int) (*SyncLog, error) {
 
 // GetSyncLogs retrieves sync logs for a user with optional limit
 func GetSyncLogs(db *sql.DB, userID int, limit int) ([]*SyncLog, error) {
-	query := `SELECT id, user_id, peer_id, started_at, completed_at, status, files_synced, bytes_synced, error_message
+	query := `SELECT id, user_id, peer_id, share_id, started_at, completed_at, status, files_synced, bytes_synced, error_message
 	          FROM sync_log
 	          WHERE user_id = ?
 	          ORDER BY started_at DESC`
@@ -121,7 +148,7 @@ func GetSyncLogs(db *sql.DB, userID int, limit int) ([]*SyncLog, error) {
 	for rows.Next() {
 		log := &SyncLog{}
 		err := rows.Scan(
-			&log.ID, &log.UserID, &log.PeerID, &log.StartedAt, &log.CompletedAt,
+			&log.ID, &log.UserID, &log.PeerID, &log.ShareID, &log.StartedAt, &log.CompletedAt,
 			&log.Status, &log.FilesSynced, &log.BytesSynced, &log.ErrorMessage,
 		)
 		if err != nil {
@@ -163,7 +190,8 @@ func CleanupZombieSyncs(db *sql.DB) error {
 
 	var zombieCount int
 	for rows.Next() {
-		var id, userID, peerID int
+		var id, peerID int
+		var userID sql.NullInt64 // NULL for group shares
 		var startedAt string
 		if err := rows.Scan(&id, &userID, &peerID, &startedAt); err != nil {
 			logger.Info("Failed to scan zombie sync", "error", err)
@@ -183,7 +211,7 @@ func CleanupZombieSyncs(db *sql.DB) error {
 			continue
 		}
 
-		logger.Info("Cleaned up zombie sync: ID=, User=, Peer=, Started", "id", id, "user_id", userID, "peer_id", peerID, "started_at", startedAt)
+		logger.Info("Cleaned up zombie sync: ID=, User=, Peer=, Started", "id", id, "user_id", userID.Int64, "peer_id", peerID, "started_at", startedAt)
 		zombieCount++
 	}
 
@@ -232,7 +260,7 @@ func GetUserEncryptionKey(db *sql.DB, userID int) (string, error) {
 	return decryptedKey, nil
 }
 
-// SyncAllUsers synchronizes all users with sync_enabled shares to all enabled peers
+// SyncAllUsers synchronizes all sync_enabled shares to their selected peers (all enabled peers by default)
 // Returns: successCount, errorCount, lastError
 func SyncAllUsers(db *sql.DB) (int, int, string) {
 	// Get all shares with sync enabled
@@ -259,12 +287,17 @@ func SyncAllUsers(db *sql.DB) (int, int, string) {
 		sharesList = append(sharesList, s)
 	}
 
-	if len(sharesList) == 0 {
+	groupShares, err := syncedGroupShares(db)
+	if err != nil {
+		return 0, 1, fmt.Sprintf("Failed to get group shares: %v", err)
+	}
+
+	if len(sharesList) == 0 && len(groupShares) == 0 {
 		return 0, 0, "No shares with sync enabled"
 	}
 
 	// Get all enabled peers
-	peersQuery := `SELECT id, name, address, port, password, sync_timeout_hours FROM peers WHERE enabled = 1`
+	peersQuery := `SELECT id, name, address, port, password, sync_timeout_hours, verify_uploads FROM peers WHERE enabled = 1`
 	peerRows, err := db.Query(peersQuery)
 	if err != nil {
 		return 0, 1, fmt.Sprintf("Failed to query peers: %v", err)
@@ -278,12 +311,13 @@ func SyncAllUsers(db *sql.DB) (int, int, string) {
 		Port         int
 		Password     *[]byte
 		TimeoutHours int
+		Verify       bool
 	}
 
 	var peersList []PeerInfo
 	for peerRows.Next() {
 		var p PeerInfo
-		if err := peerRows.Scan(&p.ID, &p.Name, &p.Address, &p.Port, &p.Password, &p.TimeoutHours); err != nil {
+		if err := peerRows.Scan(&p.ID, &p.Name, &p.Address, &p.Port, &p.Password, &p.TimeoutHours, &p.Verify); err != nil {
 			return 0, 1, fmt.Sprintf("Failed to scan peer: %v", err)
 		}
 		peersList = append(peersList, p)
@@ -293,6 +327,12 @@ func SyncAllUsers(db *sql.DB) (int, int, string) {
 		return 0, 0, "No enabled peers"
 	}
 
+	// Get per-share peer selection
+	peerMap, err := shares.GetPeerMap(db)
+	if err != nil {
+		return 0, 1, fmt.Sprintf("Failed to get share peers: %v", err)
+	}
+
 	// Get server name for manifest identification
 	serverName, err := GetServerName(db)
 	if err != nil {
@@ -313,6 +353,11 @@ func SyncAllUsers(db *sql.DB) (int, int, string) {
 
 	for _, share := range sharesList {
 		for _, peer := range peersList {
+			// Skip peers not selected for this share
+			if !peerMap.Targets(share.ID, peer.ID) {
+				continue
+			}
+
 			// Decrypt peer password
 			peerPassword := ""
 			if peer.Password != nil && len(*peer.Password) > 0 {
@@ -334,6 +379,7 @@ func SyncAllUsers(db *sql.DB) (int, int, string) {
 				PeerPassword:     peerPassword,
 				SourceServer:     serverName,
 				PeerTimeoutHours: peer.TimeoutHours,
+				VerifyUploads:    peer.Verify,
 			}
 
 			if err := SyncShareIncremental(db, req); err != nil {
@@ -345,12 +391,43 @@ func SyncAllUsers(db *sql.DB) (int, int, string) {
 		}
 	}
 
+	// Group shares go to every enabled peer
+	if len(groupShares) > 0 {
+		for _, peer := range peersList {
+			peerPassword := ""
+			if peer.Password != nil && len(*peer.Password) > 0 {
+				peerPassword, err = peers.DecryptPeerPassword(peer.Password, masterKey)
+				if err != nil {
+					errorCount++
+					lastError = fmt.Sprintf("Failed to decrypt password for peer %s: %v", peer.Name, err)
+					continue
+				}
+			}
+
+			base := SyncRequest{
+				PeerID:           peer.ID,
+				PeerAddress:      peer.Address,
+				PeerPort:         peer.Port,
+				PeerPassword:     peerPassword,
+				SourceServer:     serverName,
+				PeerTimeoutHours: peer.TimeoutHours,
+				VerifyUploads:    peer.Verify,
+			}
+			success, errors, groupError := syncGroupShares(db, groupShares, base, peer.Name)
+			successCount += success
+			errorCount += errors
+			if groupError != "" {
+				lastError = groupError
+			}
+		}
+	}
+
 	return successCount, errorCount, lastError
 }
 
-// SyncPeer synchronizes all enabled shares to a specific peer
+// SyncPeer synchronizes all enabled shares selected for a specific peer
 // Returns: successCount, errorCount, lastError
-func SyncPeer(db *sql.DB, peerID int, peerName, peerAddress string, peerPort int, peerPassword *[]byte, peerTimeoutHours int) (int, int, string) {
+func SyncPeer(db *sql.DB, peerID int, peerName, peerAddress string, peerPort int, peerPassword *[]byte, peerTimeoutHours int, verifyUploads bool) (int, int, string) {
 	// Get all shares with sync enabled
 	sharesQuery := `SELECT id, user_id, name, path FROM shares WHERE sync_enabled = 1`
 	shareRows, err := db.Query(sharesQuery)
@@ -375,7 +452,12 @@ func SyncPeer(db *sql.DB, peerID int, peerName, peerAddress string, peerPort int
 		sharesList = append(sharesList, s)
 	}
 
-	if len(sharesList) == 0 {
+	groupShares, err := syncedGroupShares(db)
+	if err != nil {
+		return 0, 1, fmt.Sprintf("Failed to get group shares: %v", err)
+	}
+
+	if len(sharesList) == 0 && len(groupShares) == 0 {
 		return 0, 0, "No shares with sync enabled"
 	}
 
@@ -406,7 +488,18 @@ func SyncPeer(db *sql.DB, peerID int, peerName, peerAddress string, peerPort int
 		}
 	}
 
+	// Get per-share peer selection
+	peerMap, err := shares.GetPeerMap(db)
+	if err != nil {
+		return 0, 1, fmt.Sprintf("Failed to get share peers: %v", err)
+	}
+
 	for _, share := range sharesList {
+		// Skip shares not sent to this peer
+		if !peerMap.Targets(share.ID, peerID) {
+			continue
+		}
+
 		req := &SyncRequest{
 			ShareID:          share.ID,
 			PeerID:           peerID,
@@ -417,6 +510,7 @@ func SyncPeer(db *sql.DB, peerID int, peerName, peerAddress string, peerPort int
 			PeerPassword:     password,
 			SourceServer:     serverName,
 			PeerTimeoutHours: peerTimeoutHours,
+			VerifyUploads:    verifyUploads,
 		}
 
 		if err := SyncShareIncremental(db, req); err != nil {
@@ -427,5 +521,22 @@ func SyncPeer(db *sql.DB, peerID int, peerName, peerAddress string, peerPort int
 		}
 	}
 
+	// Group shares go to every peer
+	base := SyncRequest{
+		PeerID:           peerID,
+		PeerAddress:      peerAddress,
+		PeerPort:         peerPort,
+		PeerPassword:     password,
+		SourceServer:     serverName,
+		PeerTimeoutHours: peerTimeoutHours,
+		VerifyUploads:    verifyUploads,
+	}
+	success, errors, groupError := syncGroupShares(db, groupShares, base, peerName)
+	successCount += success
+	errorCount += errors
+	if groupError != "" {
+		lastError = groupError
+	}
+
 	return successCount, errorCount, lastError
 }
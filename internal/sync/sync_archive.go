@@ -29,7 +29,7 @@ import (
 // NOTE: This is the legacy full-archive sync method. Prefer SyncShareIncremental for production use.
 func SyncShare(db *sql.DB, req *SyncRequest) error {
 	// Create sync log entry
-	logID, err := CreateSyncLog(db, req.UserID, req.PeerID)
+	logID, err := CreateSyncLog(db, req.UserID, req.PeerID, req.ShareID)
 	if err != nil {
 		return fmt.Errorf("failed to create sync log: %w", err)
 	}
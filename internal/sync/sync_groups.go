@@ -0,0 +1,75 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+// This file contains the synchronization of the group shares, which belong to
+// no user and are encrypted with the key of their group.
+
+package sync
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/juste-un-gars/anemone/internal/groups"
+)
+
+// GroupBackupName returns the name of the backup of a group share on the
+// peers, stored there with user ID 0
+func GroupBackupName(share *groups.Share) string {
+	return "group_" + share.GroupName + "_" + share.Name
+}
+
+// syncedGroupShares returns the group shares with sync enabled
+func syncedGroupShares(db *sql.DB) ([]*groups.Share, error) {
+	all, err := groups.GetAllShares(db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query group shares: %w", err)
+	}
+	var synced []*groups.Share
+	for _, share := range all {
+		if share.SyncEnabled {
+			synced = append(synced, share)
+		}
+	}
+	return synced, nil
+}
+
+// syncGroupShares synchronizes group shares to the peer of base, which also
+// holds the source server
+// Returns: successCount, errorCount, lastError
+func syncGroupShares(db *sql.DB, groupShares []*groups.Share, base SyncRequest, peerName string) (int, int, string) {
+	successCount := 0
+	errorCount := 0
+	var lastError string
+
+	keys := make(map[int]string)
+	for _, share := range groupShares {
+		key, ok := keys[share.GroupID]
+		if !ok {
+			var err error
+			key, err = groups.GetEncryptionKey(db, share.GroupID)
+			if err != nil {
+				errorCount++
+				lastError = fmt.Sprintf("Group share %s: %v", share.Name, err)
+				continue
+			}
+			keys[share.GroupID] = key
+		}
+
+		req := base
+		req.GroupShareID = share.ID
+		req.SharePath = share.Path
+		req.ShareName = GroupBackupName(share)
+		req.EncryptionKey = key
+
+		if err := SyncShareIncremental(db, &req); err != nil {
+			errorCount++
+			lastError = fmt.Sprintf("Group share %s to %s: %v", share.Name, peerName, err)
+		} else {
+			successCount++
+		}
+	}
+
+	return successCount, errorCount, lastError
+}
@@ -9,9 +9,12 @@ package sync
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
 	"crypto/tls"
 	"database/sql"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"mime/multipart"
@@ -96,7 +99,12 @@ func SyncShareIncremental(db *sql.DB, req *SyncRequest) error {
 	defer cancel()
 
 	// Create sync log entry
-	logID, err := CreateSyncLog(db, req.UserID, req.PeerID)
+	var logID int
+	if req.GroupShareID > 0 {
+		logID, err = CreateGroupSyncLog(db, req.PeerID, req.GroupShareID)
+	} else {
+		logID, err = CreateSyncLog(db, req.UserID, req.PeerID, req.ShareID)
+	}
 	if err != nil {
 		return fmt.Errorf("failed to create sync log: %w", err)
 	}
@@ -114,16 +122,22 @@ func SyncShareIncremental(db *sql.DB, req *SyncRequest) error {
 	default:
 	}
 
-	// Get user's encryption key
-	encryptionKey, err := GetUserEncryptionKey(db, req.UserID)
-	if err != nil {
-		errMsg := fmt.Sprintf("Failed to get encryption key: %v", err)
-		UpdateSyncLog(db, logID, "error", 0, 0, errMsg)
-		return fmt.Errorf("%s", errMsg)
+	// Get user's encryption key, unless the share has its own
+	encryptionKey := req.EncryptionKey
+	if encryptionKey == "" {
+		encryptionKey, err = GetUserEncryptionKey(db, req.UserID)
+		if err != nil {
+			errMsg := fmt.Sprintf("Failed to get encryption key: %v", err)
+			UpdateSyncLog(db, logID, "error", 0, 0, errMsg)
+			return fmt.Errorf("%s", errMsg)
+		}
 	}
 
 	// Extract share name from path
-	shareName := filepath.Base(filepath.Dir(req.SharePath))
+	shareName := req.ShareName
+	if shareName == "" {
+		shareName = filepath.Base(filepath.Dir(req.SharePath))
+	}
 
 	// Build local manifest
 	localManifest, err := BuildManifest(req.SharePath, req.UserID, shareName, req.SourceServer)
@@ -133,84 +147,13 @@ func SyncShareIncremental(db *sql.DB, req *SyncRequest) error {
 		return fmt.Errorf("%s", errMsg)
 	}
 
-	// Fetch remote manifest from peer
-	peerURL := fmt.Sprintf("https://%s:%d/api/sync/manifest?source_server=%s&user_id=%d&share_name=%s",
-		req.PeerAddress, req.PeerPort, url.QueryEscape(req.SourceServer), req.UserID, url.QueryEscape(shareName))
-
-	// Create HTTP client with optimized connection pooling for many small files
-	// Keep-alive is enabled by default, but we optimize the pool settings
-	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{
-			InsecureSkipVerify: true,
-			// Enable TLS session resumption for faster subsequent handshakes
-			ClientSessionCache: tls.NewLRUClientSessionCache(32),
-		},
-		// Connection pool optimization for sequential uploads
-		MaxIdleConns:        10,
-		MaxIdleConnsPerHost: 10,
-		IdleConnTimeout:     120 * time.Second,
-		// Disable compression (files are already encrypted, compression won't help)
-		DisableCompression: true,
-		// Force HTTP/1.1 keep-alive
-		ForceAttemptHTTP2:     false,
-		MaxConnsPerHost:       10,
-		ResponseHeaderTimeout: 30 * time.Second,
-		ExpectContinueTimeout: 1 * time.Second,
-	}
-	client := &http.Client{
-		Transport: tr,
-		// No global timeout - each request manages its own via context
-	}
+	client := newPeerClient()
 
-	// Try to fetch remote manifest
-	var remoteManifest *SyncManifest
-	manifestReq, err := http.NewRequestWithContext(ctx, http.MethodGet, peerURL, nil)
+	// Fetch remote manifest from peer (nil on first sync)
+	remoteManifest, err := fetchRemoteManifest(ctx, client, req, shareName, encryptionKey)
 	if err != nil {
-		errMsg := fmt.Sprintf("Failed to create manifest request: %v", err)
-		UpdateSyncLog(db, logID, "error", 0, 0, errMsg)
-		return fmt.Errorf("%s", errMsg)
-	}
-
-	// Add authentication headers if password is provided
-	if req.PeerPassword != "" {
-		manifestReq.Header.Set("X-Sync-Password", req.PeerPassword)
-		manifestReq.Header.Set("X-Source-Server", req.SourceServer)
-	}
-
-	resp, err := client.Do(manifestReq)
-	if err == nil && resp.StatusCode == http.StatusOK {
-		// Manifest exists - decrypt it
-		defer resp.Body.Close()
-		encryptedData, err := io.ReadAll(resp.Body)
-		if err != nil {
-			errMsg := fmt.Sprintf("Failed to read remote manifest: %v", err)
-			UpdateSyncLog(db, logID, "error", 0, 0, errMsg)
-			return fmt.Errorf("%s", errMsg)
-		}
-
-		// Decrypt manifest
-		var decryptedBuf bytes.Buffer
-		if err := crypto.DecryptStream(bytes.NewReader(encryptedData), &decryptedBuf, encryptionKey); err != nil {
-			errMsg := fmt.Sprintf("Failed to decrypt manifest: %v", err)
-			UpdateSyncLog(db, logID, "error", 0, 0, errMsg)
-			return fmt.Errorf("%s", errMsg)
-		}
-
-		// Unmarshal manifest
-		remoteManifest, err = UnmarshalManifest(decryptedBuf.Bytes())
-		if err != nil {
-			errMsg := fmt.Sprintf("Failed to parse remote manifest: %v", err)
-			UpdateSyncLog(db, logID, "error", 0, 0, errMsg)
-			return fmt.Errorf("%s", errMsg)
-		}
-	} else if resp != nil && resp.StatusCode == http.StatusNotFound {
-		// No remote manifest yet (first sync) - that's OK
-		remoteManifest = nil
-	} else {
-		// Other error
-		errMsg := fmt.Sprintf("Failed to fetch remote manifest: %v", err)
-		UpdateSyncLog(db, logID, "error", 0, 0, errMsg)
-		return fmt.Errorf("%s", errMsg)
+		UpdateSyncLog(db, logID, "error", 0, 0, err.Error())
+		return err
 	}
 
 	// Compare manifests to get delta
@@ -292,7 +235,14 @@ func SyncShareIncremental(db *sql.DB, req *SyncRequest) error {
 		}
 
 		// Stream encrypt and upload file (memory-efficient)
-		err = streamEncryptAndUpload(ctx, client, file, req, shareName, fileMeta.EncryptedPath, encryptionKey, req.UserID)
+		encryptedChecksum, err := streamEncryptAndUpload(ctx, client, file, req, shareName, fileMeta.EncryptedPath, encryptionKey, req.UserID)
+		if errors.Is(err, errUploadMismatch) {
+			// The copy read back by the peer differs from what was sent: send it again once
+			logger.Warn("Peer stored a damaged copy, uploading again", "relative_path", relativePath, "peer_id", req.PeerID)
+			if _, seekErr := file.Seek(0, io.SeekStart); seekErr == nil {
+				encryptedChecksum, err = streamEncryptAndUpload(ctx, client, file, req, shareName, fileMeta.EncryptedPath, encryptionKey, req.UserID)
+			}
+		}
 		file.Close()
 
 		if err != nil {
@@ -306,6 +256,7 @@ func SyncShareIncremental(db *sql.DB, req *SyncRequest) error {
 		uploadedCount++
 
 		// Update progress manifest with successfully uploaded file
+		fileMeta.EncryptedChecksum = encryptedChecksum
 		progressManifest.Files[relativePath] = fileMeta
 		progressManifest.LastSync = time.Now()
 
@@ -430,6 +381,83 @@ func SyncShareIncremental(db *sql.DB, req *SyncRequest) error {
 	return nil
 }
 
+// newPeerClient returns an HTTP client tuned for many sequential requests to a peer
+func newPeerClient() *http.Client {
+	// Keep-alive is enabled by default, but we optimize the pool settings
+	tr := &http.Transport{
+		TLSClientConfig: &tls.Config{
+			InsecureSkipVerify: true,
+			// Enable TLS session resumption for faster subsequent handshakes
+			ClientSessionCache: tls.NewLRUClientSessionCache(32),
+		},
+		// Connection pool optimization for sequential uploads
+		MaxIdleConns:        10,
+		MaxIdleConnsPerHost: 10,
+		IdleConnTimeout:     120 * time.Second,
+		// Disable compression (files are already encrypted, compression won't help)
+		DisableCompression: true,
+		// Force HTTP/1.1 keep-alive
+		ForceAttemptHTTP2:     false,
+		MaxConnsPerHost:       10,
+		ResponseHeaderTimeout: 30 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+	return &http.Client{
+		Transport: tr,
+		// No global timeout - each request manages its own via context
+	}
+}
+
+// fetchRemoteManifest downloads and decrypts the manifest of a share on the peer.
+// It returns nil without error when the share was never synced to the peer.
+func fetchRemoteManifest(ctx context.Context, client *http.Client, req *SyncRequest, shareName string, encryptionKey string) (*SyncManifest, error) {
+	peerURL := fmt.Sprintf("https://%s:%d/api/sync/manifest?source_server=%s&user_id=%d&share_name=%s",
+		req.PeerAddress, req.PeerPort, url.QueryEscape(req.SourceServer), req.UserID, url.QueryEscape(shareName))
+
+	manifestReq, err := http.NewRequestWithContext(ctx, http.MethodGet, peerURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create manifest request: %v", err)
+	}
+
+	// Add authentication headers if password is provided
+	if req.PeerPassword != "" {
+		manifestReq.Header.Set("X-Sync-Password", req.PeerPassword)
+		manifestReq.Header.Set("X-Source-Server", req.SourceServer)
+	}
+
+	resp, err := client.Do(manifestReq)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to fetch remote manifest: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		// No remote manifest yet (first sync) - that's OK
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Failed to fetch remote manifest: status %d", resp.StatusCode)
+	}
+
+	encryptedData, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read remote manifest: %v", err)
+	}
+
+	// Decrypt manifest
+	var decryptedBuf bytes.Buffer
+	if err := crypto.DecryptStream(bytes.NewReader(encryptedData), &decryptedBuf, encryptionKey); err != nil {
+		return nil, fmt.Errorf("Failed to decrypt manifest: %v", err)
+	}
+
+	// Unmarshal manifest
+	manifest, err := UnmarshalManifest(decryptedBuf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse remote manifest: %v", err)
+	}
+	return manifest, nil
+}
+
 // cleanupOrphanedFiles removes files on peer that don't exist in the local manifest
 // This handles orphaned files that were left behind (e.g., from trash deletion)
 func cleanupOrphanedFiles(ctx context.Context, client *http.Client, req *SyncRequest, localManifest *SyncManifest, shareName string) error {
@@ -520,7 +548,9 @@ func cleanupOrphanedFiles(ctx context.Context, client *http.Client, req *SyncReq
 
 // streamEncryptAndUpload encrypts and uploads a file using streaming to avoid loading entire file in RAM
 // This prevents OOM (Out Of Memory) issues when syncing large files
-func streamEncryptAndUpload(ctx context.Context, client *http.Client, file *os.File, req *SyncRequest, shareName, encryptedPath, encryptionKey string, userID int) error {
+// It returns the SHA-256 of the encrypted file sent. With VerifyUploads the peer reads the
+// file back after writing it and answers with its checksum; a mismatch returns errUploadMismatch.
+func streamEncryptAndUpload(ctx context.Context, client *http.Client, file *os.File, req *SyncRequest, shareName, encryptedPath, encryptionKey string, userID int) (string, error) {
 	// Create a pipe for streaming the complete multipart request
 	pipeReader, pipeWriter := io.Pipe()
 
@@ -531,6 +561,9 @@ func streamEncryptAndUpload(ctx context.Context, client *http.Client, file *os.F
 	// Channel to capture errors from goroutine
 	errChan := make(chan error, 1)
 
+	// Checksum of the encrypted content, compared with the copy written by the peer
+	sent := sha256.New()
+
 	// Goroutine to build multipart form with streamed encrypted data
 	go func() {
 		defer pipeWriter.Close()
@@ -558,7 +591,7 @@ func streamEncryptAndUpload(ctx context.Context, client *http.Client, file *os.F
 		}
 
 		// Encrypt and stream file directly into multipart (memory-efficient)
-		if err := crypto.EncryptStream(file, part, encryptionKey); err != nil {
+		if err := crypto.EncryptStream(file, io.MultiWriter(part, sent), encryptionKey); err != nil {
 			errChan <- fmt.Errorf("encryption failed: %w", err)
 			return
 		}
@@ -568,10 +601,13 @@ func streamEncryptAndUpload(ctx context.Context, client *http.Client, file *os.F
 
 	// Upload file
 	uploadURL := fmt.Sprintf("https://%s:%d/api/sync/file?source_server=%s", req.PeerAddress, req.PeerPort, url.QueryEscape(req.SourceServer))
+	if req.VerifyUploads {
+		uploadURL += "&verify=1"
+	}
 
 	uploadReq, err := http.NewRequestWithContext(ctx, http.MethodPost, uploadURL, pipeReader)
 	if err != nil {
-		return fmt.Errorf("failed to create upload request: %w", err)
+		return "", fmt.Errorf("failed to create upload request: %w", err)
 	}
 
 	// Set correct content type with boundary
@@ -586,20 +622,28 @@ func streamEncryptAndUpload(ctx context.Context, client *http.Client, file *os.F
 	// Send request
 	resp, err := client.Do(uploadReq)
 	if err != nil {
-		return fmt.Errorf("failed to send upload request: %w", err)
+		return "", fmt.Errorf("failed to send upload request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	// Check for errors from goroutine
 	if goroutineErr := <-errChan; goroutineErr != nil {
-		return goroutineErr
+		return "", goroutineErr
 	}
 
 	// Check response status
+	body, _ := io.ReadAll(resp.Body)
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("upload failed with status %d: %s", resp.StatusCode, string(body))
+		return "", fmt.Errorf("upload failed with status %d: %s", resp.StatusCode, string(body))
 	}
 
-	return nil
+	checksum := hex.EncodeToString(sent.Sum(nil))
+	var uploaded struct {
+		SHA256 string `json:"sha256"`
+	}
+	if json.Unmarshal(body, &uploaded) == nil && uploaded.SHA256 != "" && uploaded.SHA256 != checksum {
+		return "", errUploadMismatch
+	}
+
+	return checksum, nil
 }
@@ -0,0 +1,396 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+// This file checks that the files stored on peers are still intact. Peers answer a
+// challenge computed from the checksum of each stored file, so nothing needs to be
+// downloaded, and damaged or missing files are sent again from the share.
+
+package sync
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	mathrand "math/rand/v2"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/juste-un-gars/anemone/internal/logger"
+	"github.com/juste-un-gars/anemone/internal/peers"
+	"github.com/juste-un-gars/anemone/internal/shares"
+)
+
+// verifyBatchSize is the number of files challenged per request to a peer
+const verifyBatchSize = 200
+
+// errUploadMismatch is returned when the copy read back by the peer differs from what was sent
+var errUploadMismatch = errors.New("file stored by the peer does not match the file sent")
+
+// VerifyFailure is a file of a peer backup that failed an integrity check
+type VerifyFailure struct {
+	ShareID   int    `json:"share_id"`
+	ShareName string `json:"share_name"`
+	Path      string `json:"path"` // Path relative to the share
+	Error     string `json:"error"`
+}
+
+// VerifyChallenge is sent by the origin server to /api/sync/verify
+type VerifyChallenge struct {
+	UserID    int      `json:"user_id"`
+	ShareName string   `json:"share_name"`
+	Nonce     string   `json:"nonce"`
+	Paths     []string `json:"paths"` // Encrypted paths
+}
+
+// VerifyResponse holds the proof computed by the peer for each file it could read
+type VerifyResponse struct {
+	Proofs map[string]string `json:"proofs"`
+	Errors map[string]string `json:"errors,omitempty"` // Files missing or unreadable
+}
+
+// OpenUncached opens a file for reading from the disk rather than the page cache,
+// so that a freshly written file is really read back from the media
+func OpenUncached(path string) (*os.File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	// Only clean pages can be dropped: flush the file first
+	f.Sync()
+	unix.Fadvise(int(f.Fd()), 0, 0, unix.FADV_DONTNEED)
+	return f, nil
+}
+
+// DiskChecksum returns the SHA-256 of a file as stored on disk
+func DiskChecksum(path string) (string, error) {
+	f, err := OpenUncached(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// VerifyProof binds the checksum of a stored file to a challenge nonce
+func VerifyProof(nonce, checksum string) string {
+	mac := hmac.New(sha256.New, []byte(nonce))
+	mac.Write([]byte(checksum))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SampleFiles returns a random selection of percent of the paths (at least one),
+// or all of them when percent is 100 or more
+func SampleFiles(paths []string, percent int) []string {
+	if percent >= 100 || len(paths) == 0 {
+		return paths
+	}
+	n := int(math.Ceil(float64(len(paths)) * float64(max(percent, 1)) / 100))
+	sample := append([]string(nil), paths...)
+	mathrand.Shuffle(len(sample), func(i, j int) { sample[i], sample[j] = sample[j], sample[i] })
+	sample = sample[:n]
+	sort.Strings(sample)
+	return sample
+}
+
+// peerShare is a share replicated to the peer being checked
+type peerShare struct {
+	share *shares.Share
+	name  string // Share name on the peer
+	key   string // User encryption key
+	req   *SyncRequest
+}
+
+// peerShares returns the shares sent to a peer with what is needed to reach their copy
+func peerShares(db *sql.DB, peer *peers.Peer) ([]*peerShare, error) {
+	running, err := HasRunningSyncForPeer(db, peer.ID)
+	if err != nil {
+		return nil, err
+	}
+	if running {
+		return nil, fmt.Errorf("sync in progress for peer %s", peer.Name)
+	}
+
+	serverName, err := GetServerName(db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get server name: %w", err)
+	}
+
+	password := ""
+	if peer.Password != nil && len(*peer.Password) > 0 {
+		var masterKey string
+		if err := db.QueryRow("SELECT value FROM system_config WHERE key = 'master_key'").Scan(&masterKey); err != nil {
+			return nil, fmt.Errorf("failed to get master key: %w", err)
+		}
+		password, err = peers.DecryptPeerPassword(peer.Password, masterKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt password for peer %s: %w", peer.Name, err)
+		}
+	}
+
+	allShares, err := shares.GetAll(db)
+	if err != nil {
+		return nil, err
+	}
+	peerMap, err := shares.GetPeerMap(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []*peerShare
+	for _, share := range allShares {
+		if !share.SyncEnabled || !peerMap.Targets(share.ID, peer.ID) {
+			continue
+		}
+		key, err := GetUserEncryptionKey(db, share.UserID)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, &peerShare{
+			share: share,
+			name:  filepath.Base(filepath.Dir(share.Path)),
+			key:   key,
+			req: &SyncRequest{
+				ShareID:      share.ID,
+				PeerID:       peer.ID,
+				UserID:       share.UserID,
+				SharePath:    share.Path,
+				PeerAddress:  peer.Address,
+				PeerPort:     peer.Port,
+				PeerPassword: password,
+				SourceServer: serverName,
+			},
+		})
+	}
+	return result, nil
+}
+
+// VerifyPeer checks the files stored on a peer against the checksums recorded when they
+// were sent. With samplePercent below 100, a random sample of each share is checked.
+// Files sent before checksums were recorded are only checked for presence.
+func VerifyPeer(db *sql.DB, peerID int, samplePercent int) (int, []VerifyFailure, error) {
+	peer, err := peers.GetByID(db, peerID)
+	if err != nil {
+		return 0, nil, err
+	}
+	list, err := peerShares(db, peer)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 6*time.Hour)
+	defer cancel()
+	client := newPeerClient()
+
+	checked := 0
+	var failures []VerifyFailure
+	for _, ps := range list {
+		manifest, err := fetchRemoteManifest(ctx, client, ps.req, ps.name, ps.key)
+		if err != nil {
+			return checked, failures, fmt.Errorf("share %s: %w", ps.share.Name, err)
+		}
+		if manifest == nil {
+			continue // Never synced to this peer
+		}
+
+		paths := make([]string, 0, len(manifest.Files))
+		for relPath := range manifest.Files {
+			paths = append(paths, relPath)
+		}
+		sort.Strings(paths)
+		paths = SampleFiles(paths, samplePercent)
+
+		for start := 0; start < len(paths); start += verifyBatchSize {
+			batch := paths[start:min(start+verifyBatchSize, len(paths))]
+			nonce, resp, err := challengePeer(ctx, client, ps, manifest, batch)
+			if err != nil {
+				return checked, failures, fmt.Errorf("share %s: %w", ps.share.Name, err)
+			}
+
+			for _, relPath := range batch {
+				meta := manifest.Files[relPath]
+				checked++
+				proof, ok := resp.Proofs[meta.EncryptedPath]
+				switch {
+				case !ok:
+					msg := resp.Errors[meta.EncryptedPath]
+					if msg == "" {
+						msg = "missing on peer"
+					}
+					failures = append(failures, VerifyFailure{ShareID: ps.share.ID, ShareName: ps.share.Name, Path: relPath, Error: msg})
+				case meta.EncryptedChecksum != "" && !hmac.Equal([]byte(proof), []byte(VerifyProof(nonce, meta.EncryptedChecksum))):
+					failures = append(failures, VerifyFailure{ShareID: ps.share.ID, ShareName: ps.share.Name, Path: relPath, Error: "content differs from the file sent"})
+				}
+			}
+		}
+	}
+
+	logger.Info("Peer integrity check completed", "peer", peer.Name, "checked", checked, "failed", len(failures))
+	return checked, failures, nil
+}
+
+// challengePeer asks the peer for the proof of a batch of files with a fresh nonce
+func challengePeer(ctx context.Context, client *http.Client, ps *peerShare, manifest *SyncManifest, batch []string) (string, *VerifyResponse, error) {
+	nonceBytes := make([]byte, 16)
+	if _, err := rand.Read(nonceBytes); err != nil {
+		return "", nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	challenge := VerifyChallenge{
+		UserID:    ps.req.UserID,
+		ShareName: ps.name,
+		Nonce:     hex.EncodeToString(nonceBytes),
+	}
+	for _, relPath := range batch {
+		challenge.Paths = append(challenge.Paths, manifest.Files[relPath].EncryptedPath)
+	}
+	body, _ := json.Marshal(challenge)
+
+	verifyURL := fmt.Sprintf("https://%s:%d/api/sync/verify?source_server=%s",
+		ps.req.PeerAddress, ps.req.PeerPort, url.QueryEscape(ps.req.SourceServer))
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, verifyURL, bytes.NewReader(body))
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create verify request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if ps.req.PeerPassword != "" {
+		httpReq.Header.Set("X-Sync-Password", ps.req.PeerPassword)
+		httpReq.Header.Set("X-Source-Server", ps.req.SourceServer)
+	}
+
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to send verify request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return "", nil, fmt.Errorf("peer does not support integrity checks (update it)")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", nil, fmt.Errorf("verify request returned status %d", resp.StatusCode)
+	}
+
+	var result VerifyResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", nil, fmt.Errorf("invalid verify response: %w", err)
+	}
+	return challenge.Nonce, &result, nil
+}
+
+// RepairPeerFiles sends the files that failed a check to the peer again, from the
+// current content of the share, and returns the failures that could not be repaired
+func RepairPeerFiles(db *sql.DB, peerID int, failures []VerifyFailure) (int, []VerifyFailure, error) {
+	peer, err := peers.GetByID(db, peerID)
+	if err != nil {
+		return 0, failures, err
+	}
+	list, err := peerShares(db, peer)
+	if err != nil {
+		return 0, failures, err
+	}
+	byShare := make(map[int]*peerShare, len(list))
+	for _, ps := range list {
+		byShare[ps.share.ID] = ps
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 6*time.Hour)
+	defer cancel()
+	client := newPeerClient()
+
+	repaired := 0
+	var remaining []VerifyFailure
+	manifests := make(map[int]*SyncManifest)
+
+	for _, f := range failures {
+		ps, ok := byShare[f.ShareID]
+		if !ok {
+			f.Error = "share is no longer sent to this peer"
+			remaining = append(remaining, f)
+			continue
+		}
+		manifest, ok := manifests[f.ShareID]
+		if !ok {
+			manifest, err = fetchRemoteManifest(ctx, client, ps.req, ps.name, ps.key)
+			if err != nil || manifest == nil {
+				f.Error = fmt.Sprintf("cannot read the manifest on the peer: %v", err)
+				remaining = append(remaining, f)
+				continue
+			}
+			manifests[f.ShareID] = manifest
+		}
+
+		meta, err := resendFile(ctx, client, ps, f.Path)
+		if err != nil {
+			f.Error = err.Error()
+			remaining = append(remaining, f)
+			continue
+		}
+		manifest.Files[f.Path] = *meta
+		repaired++
+	}
+
+	// Record the new checksums so the next check compares against the repaired copies
+	for shareID, manifest := range manifests {
+		ps := byShare[shareID]
+		if err := uploadManifestToRemote(ctx, client, ps.req, manifest, ps.name, ps.key); err != nil {
+			return repaired, remaining, fmt.Errorf("share %s: %w", ps.share.Name, err)
+		}
+	}
+
+	logger.Info("Peer files repaired", "peer", peer.Name, "repaired", repaired, "remaining", len(remaining))
+	return repaired, remaining, nil
+}
+
+// resendFile encrypts a file of the share and uploads it to the peer again
+func resendFile(ctx context.Context, client *http.Client, ps *peerShare, relPath string) (*FileMetadata, error) {
+	sourcePath := filepath.Join(ps.share.Path, relPath)
+	file, err := os.Open(sourcePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("file no longer exists in the share")
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	checksum, err := CalculateChecksum(sourcePath)
+	if err != nil {
+		return nil, err
+	}
+
+	meta := &FileMetadata{
+		Size:          info.Size(),
+		ModTime:       info.ModTime(),
+		Checksum:      checksum,
+		EncryptedPath: relPath + ".enc",
+	}
+	meta.EncryptedChecksum, err = streamEncryptAndUpload(ctx, client, file, ps.req, ps.name, meta.EncryptedPath, ps.key, ps.req.UserID)
+	if err != nil {
+		return nil, err
+	}
+	return meta, nil
+}
@@ -0,0 +1,73 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+package sync
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSampleFiles(t *testing.T) {
+	paths := make([]string, 50)
+	for i := range paths {
+		paths[i] = fmt.Sprintf("file%02d.txt", i)
+	}
+
+	if got := SampleFiles(paths, 100); len(got) != 50 {
+		t.Errorf("full check returned %d files, expected 50", len(got))
+	}
+	if got := SampleFiles(paths, 10); len(got) != 5 {
+		t.Errorf("10%% sample returned %d files, expected 5", len(got))
+	}
+	// A sample never skips a share entirely
+	if got := SampleFiles(paths[:3], 1); len(got) != 1 {
+		t.Errorf("1%% sample of 3 files returned %d files, expected 1", len(got))
+	}
+
+	seen := make(map[string]bool)
+	for _, p := range SampleFiles(paths, 20) {
+		if seen[p] {
+			t.Errorf("%s sampled twice", p)
+		}
+		seen[p] = true
+	}
+	if paths[0] != "file00.txt" || paths[49] != "file49.txt" {
+		t.Error("SampleFiles modified its input")
+	}
+}
+
+func TestVerifyProof(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file.enc")
+	if err := os.WriteFile(path, []byte("encrypted content"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	stored, err := DiskChecksum(path)
+	if err != nil {
+		t.Fatalf("DiskChecksum failed: %v", err)
+	}
+	sent, _ := CalculateChecksum(path)
+	sent = strings.TrimPrefix(sent, "sha256:")
+	if stored != sent {
+		t.Fatalf("DiskChecksum = %s, expected %s", stored, sent)
+	}
+
+	proof := VerifyProof("nonce-1", stored)
+	if proof != VerifyProof("nonce-1", sent) {
+		t.Error("same file and nonce give different proofs")
+	}
+	if proof == VerifyProof("nonce-2", sent) {
+		t.Error("proof does not depend on the nonce")
+	}
+
+	// A damaged copy no longer matches
+	os.WriteFile(path, []byte("encrypted c0ntent"), 0644)
+	damaged, _ := DiskChecksum(path)
+	if VerifyProof("nonce-1", damaged) == proof {
+		t.Error("damaged file gives the same proof")
+	}
+}
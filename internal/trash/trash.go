@@ -267,8 +267,12 @@ func CleanupAllUserTrash(db interface{}, retentionDays int) (int, error) {
 		return 0, fmt.Errorf("invalid database type")
 	}
 
-	// Get all users and their shares
-	rows, err := database.Query("SELECT u.username, s.path FROM users u JOIN shares s ON u.id = s.user_id")
+	// Get all users and their shares, and the shares of their groups
+	rows, err := database.Query(`SELECT u.username, s.path FROM users u JOIN shares s ON u.id = s.user_id
+		UNION ALL
+		SELECT u.username, gs.path FROM users u
+		JOIN group_members gm ON gm.user_id = u.id
+		JOIN group_shares gs ON gs.group_id = gm.group_id`)
 	if err != nil {
 		return 0, fmt.Errorf("failed to query users and shares: %w", err)
 	}
@@ -368,3 +368,93 @@ func getDiskFilesystem(diskName string) (string, bool) {
 	}
 	return fsType, hasParts
 }
+
+// BlockDevice is a filesystem reported by lsblk, mounted or not
+type BlockDevice struct {
+	Path       string // e.g., /dev/sdb1
+	UUID       string // Filesystem UUID
+	Label      string // Filesystem label
+	Filesystem string // e.g., ext4, vfat
+	MountPoint string // Empty when not mounted
+}
+
+// ListBlockDevices returns the block devices holding a filesystem
+func ListBlockDevices() ([]BlockDevice, error) {
+	cmd := exec.Command("lsblk", "-p", "-P", "-n", "-o", "NAME,UUID,LABEL,FSTYPE,MOUNTPOINT")
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list block devices: %w", err)
+	}
+
+	var devices []BlockDevice
+	scanner := bufio.NewScanner(strings.NewReader(string(output)))
+	for scanner.Scan() {
+		fields := parseLsblkPairs(scanner.Text())
+		if fields["UUID"] == "" || fields["FSTYPE"] == "" {
+			continue
+		}
+		devices = append(devices, BlockDevice{
+			Path:       fields["NAME"],
+			UUID:       fields["UUID"],
+			Label:      fields["LABEL"],
+			Filesystem: fields["FSTYPE"],
+			MountPoint: fields["MOUNTPOINT"],
+		})
+	}
+	return devices, nil
+}
+
+// parseLsblkPairs parses a line of `lsblk -P` output (KEY="value" pairs).
+// lsblk escapes special characters in values as \xHH.
+func parseLsblkPairs(line string) map[string]string {
+	fields := make(map[string]string)
+	for {
+		line = strings.TrimLeft(line, " ")
+		eq := strings.Index(line, `="`)
+		if eq <= 0 {
+			return fields
+		}
+		key := line[:eq]
+		rest := line[eq+2:]
+		end := strings.Index(rest, `"`)
+		if end < 0 {
+			return fields
+		}
+		fields[key] = unescapeLsblk(rest[:end])
+		line = rest[end+1:]
+	}
+}
+
+// unescapeLsblk decodes the \xHH sequences used by lsblk and /proc/mounts style output
+func unescapeLsblk(value string) string {
+	if !strings.Contains(value, `\x`) {
+		return value
+	}
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\\' && i+3 < len(value) && value[i+1] == 'x' {
+			if n, err := strconv.ParseUint(value[i+2:i+4], 16, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(value[i])
+	}
+	return b.String()
+}
+
+// mountSource returns the device mounted at mountPath according to /proc/mounts
+func mountSource(mountPath string) string {
+	data, err := os.ReadFile("/proc/mounts")
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && fields[1] == mountPath {
+			return fields[0]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,288 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+// This file implements the self-describing USB backup format: a header file at the
+// root of the backup directory carries the data key of the drive, wrapped with a
+// passphrase, so the drive can be decrypted without the server that wrote it.
+
+package usbbackup
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/juste-un-gars/anemone/internal/crypto"
+)
+
+const (
+	// HeaderFileName is the header file written at the root of the backup directory
+	HeaderFileName = "anemone-usb.json"
+	// HeaderFormat identifies Anemone USB headers
+	HeaderFormat = "anemone-usb"
+	// HeaderVersion is the current header format version
+	HeaderVersion = 1
+
+	// MinPassphraseLength is the minimum length of a drive passphrase
+	MinPassphraseLength = 12
+)
+
+// ServerIdentity identifies the server that wrote a backup
+type ServerIdentity struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Hostname string `json:"hostname"`
+}
+
+// KDFParams describes how the key-encryption key is derived from the passphrase
+type KDFParams = crypto.PassphraseParams
+
+// Header describes an encrypted USB backup and carries its wrapped data key
+type Header struct {
+	Format     string         `json:"format"`
+	Version    int            `json:"version"`
+	Server     ServerIdentity `json:"server"`
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+	WrappedAt  time.Time      `json:"wrapped_at"` // Last time the passphrase changed
+	KeyID      string         `json:"key_id"`     // Identifies the data key without revealing it
+	KDF        KDFParams      `json:"kdf"`
+	WrappedKey string         `json:"wrapped_key"` // base64(nonce || AES-256-GCM(data key))
+}
+
+// NewHeader wraps dataKey (base64, 32 bytes) with a passphrase
+func NewHeader(dataKey, passphrase string, server ServerIdentity) (*Header, error) {
+	now := time.Now().UTC()
+	h := &Header{
+		Format:    HeaderFormat,
+		Version:   HeaderVersion,
+		Server:    server,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := h.wrap(dataKey, passphrase); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// Unwrap returns the data key (base64) protected by the passphrase
+func (h *Header) Unwrap(passphrase string) (string, error) {
+	if h.Format != HeaderFormat {
+		return "", fmt.Errorf("not an Anemone USB header")
+	}
+	if h.Version > HeaderVersion {
+		return "", fmt.Errorf("unsupported header version %d", h.Version)
+	}
+
+	wrapped, err := base64.StdEncoding.DecodeString(h.WrappedKey)
+	if err != nil {
+		return "", fmt.Errorf("invalid wrapped key: %w", err)
+	}
+	key, err := crypto.OpenWithPassphrase(wrapped, passphrase, h.KDF, []byte(h.Format))
+	if err == crypto.ErrWrongPassphrase {
+		return "", fmt.Errorf("invalid passphrase")
+	}
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// Rewrap protects the data key with a new passphrase. The data key, and therefore
+// the files already on the drive, stay the same.
+func (h *Header) Rewrap(oldPassphrase, newPassphrase string) error {
+	dataKey, err := h.Unwrap(oldPassphrase)
+	if err != nil {
+		return err
+	}
+	return h.wrap(dataKey, newPassphrase)
+}
+
+// wrap encrypts dataKey with a key derived from the passphrase using a fresh salt
+func (h *Header) wrap(dataKey, passphrase string) error {
+	if len(passphrase) < MinPassphraseLength {
+		return fmt.Errorf("passphrase must be at least %d characters", MinPassphraseLength)
+	}
+	key, err := base64.StdEncoding.DecodeString(dataKey)
+	if err != nil || len(key) != 32 {
+		return fmt.Errorf("invalid data key")
+	}
+
+	wrapped, params, err := crypto.SealWithPassphrase(key, passphrase, []byte(h.Format))
+	if err != nil {
+		return err
+	}
+
+	h.KDF = params
+	h.WrappedKey = base64.StdEncoding.EncodeToString(wrapped)
+	h.KeyID = KeyID(dataKey)
+	h.WrappedAt = time.Now().UTC()
+	h.UpdatedAt = h.WrappedAt
+	return nil
+}
+
+// KeyID returns a short identifier of a data key
+func KeyID(dataKey string) string {
+	sum := sha256.Sum256([]byte("anemone-usb-key-id:" + dataKey))
+	return hex.EncodeToString(sum[:8])
+}
+
+// ReadHeader reads the header of a backup directory
+func ReadHeader(dir string) (*Header, error) {
+	data, err := os.ReadFile(filepath.Join(dir, HeaderFileName))
+	if err != nil {
+		return nil, err
+	}
+	var h Header
+	if err := json.Unmarshal(data, &h); err != nil {
+		return nil, fmt.Errorf("invalid header: %w", err)
+	}
+	if h.Format != HeaderFormat {
+		return nil, fmt.Errorf("not an Anemone USB header")
+	}
+	return &h, nil
+}
+
+// WriteHeader writes the header of a backup directory
+func WriteHeader(dir string, h *Header) error {
+	data, err := json.MarshalIndent(h, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(dir, HeaderFileName)
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// GetServerIdentity returns the identity written in USB headers.
+// The server ID is generated once and kept in system_config.
+func GetServerIdentity(db *sql.DB, serverName string) ServerIdentity {
+	identity := ServerIdentity{Name: serverName}
+	identity.Hostname, _ = os.Hostname()
+
+	if err := db.QueryRow("SELECT value FROM system_config WHERE key = 'server_id'").Scan(&identity.ID); err != nil || identity.ID == "" {
+		raw := make([]byte, 16)
+		if _, err := rand.Read(raw); err == nil {
+			identity.ID = hex.EncodeToString(raw)
+			db.Exec(`INSERT INTO system_config (key, value, updated_at) VALUES ('server_id', ?, CURRENT_TIMESTAMP)
+				ON CONFLICT(key) DO NOTHING`, identity.ID)
+			db.QueryRow("SELECT value FROM system_config WHERE key = 'server_id'").Scan(&identity.ID)
+		}
+	}
+	return identity
+}
+
+// HasPassphrase reports whether the drive is protected by its own passphrase-wrapped key
+func (b *USBBackup) HasPassphrase() bool {
+	return b.DataKeyEncrypted != "" && b.KeyHeader != ""
+}
+
+// SetPassphrase protects the drive with a passphrase. The first call generates the
+// drive data key; later calls only re-wrap it, so the data on the drive stays valid.
+func SetPassphrase(db *sql.DB, backup *USBBackup, masterKey, passphrase string, server ServerIdentity) error {
+	var dataKey string
+	var header *Header
+	var err error
+
+	if backup.DataKeyEncrypted != "" {
+		if dataKey, err = crypto.DecryptKey(backup.DataKeyEncrypted, masterKey); err != nil {
+			return fmt.Errorf("failed to decrypt drive key: %w", err)
+		}
+	} else {
+		if dataKey, err = crypto.GenerateEncryptionKey(); err != nil {
+			return fmt.Errorf("failed to generate drive key: %w", err)
+		}
+	}
+
+	if backup.KeyHeader != "" {
+		header = &Header{}
+		if err := json.Unmarshal([]byte(backup.KeyHeader), header); err != nil {
+			header = nil
+		}
+	}
+	if header == nil {
+		if header, err = NewHeader(dataKey, passphrase, server); err != nil {
+			return err
+		}
+	} else {
+		header.Server = server
+		if err := header.wrap(dataKey, passphrase); err != nil {
+			return err
+		}
+	}
+
+	encrypted, err := crypto.EncryptKey(dataKey, masterKey)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt drive key: %w", err)
+	}
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
+
+	if _, err := db.Exec("UPDATE usb_backups SET data_key_encrypted = ?, key_header = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?",
+		encrypted, string(headerJSON), backup.ID); err != nil {
+		return fmt.Errorf("failed to save drive key: %w", err)
+	}
+	backup.DataKeyEncrypted = encrypted
+	backup.KeyHeader = string(headerJSON)
+
+	// Write the new header right away when the drive is connected
+	if backup.IsMounted() {
+		if err := backup.EnsureBackupDir(); err == nil {
+			WriteHeader(backup.GetFullBackupPath(), header)
+		}
+	}
+	return nil
+}
+
+// driveKey returns the key the drive content is encrypted with and refreshes the
+// header on the drive. Drives without a passphrase use the master key.
+func driveKey(db *sql.DB, backup *USBBackup, masterKey string, server ServerIdentity) (string, error) {
+	if !backup.HasPassphrase() {
+		return masterKey, nil
+	}
+
+	dataKey, err := crypto.DecryptKey(backup.DataKeyEncrypted, masterKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt drive key: %w", err)
+	}
+
+	var header Header
+	if err := json.Unmarshal([]byte(backup.KeyHeader), &header); err != nil {
+		return "", fmt.Errorf("invalid drive header: %w", err)
+	}
+
+	// The passphrase may have been changed on the drive with anemone-usb-restore:
+	// keep the newer wrapping of the same key
+	dir := backup.GetFullBackupPath()
+	if onDrive, err := ReadHeader(dir); err == nil && onDrive.KeyID == header.KeyID && onDrive.WrappedAt.After(header.WrappedAt) {
+		header.KDF = onDrive.KDF
+		header.WrappedKey = onDrive.WrappedKey
+		header.WrappedAt = onDrive.WrappedAt
+	}
+
+	header.Server = server
+	header.UpdatedAt = time.Now().UTC()
+	if err := WriteHeader(dir, &header); err != nil {
+		return "", fmt.Errorf("failed to write drive header: %w", err)
+	}
+
+	if headerJSON, err := json.Marshal(&header); err == nil && string(headerJSON) != backup.KeyHeader {
+		backup.KeyHeader = string(headerJSON)
+		db.Exec("UPDATE usb_backups SET key_header = ? WHERE id = ?", backup.KeyHeader, backup.ID)
+	}
+	return dataKey, nil
+}
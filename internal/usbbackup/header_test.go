@@ -0,0 +1,109 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+package usbbackup
+
+import (
+	"testing"
+	"time"
+
+	"github.com/juste-un-gars/anemone/internal/crypto"
+)
+
+func TestHeaderWrapUnwrap(t *testing.T) {
+	dataKey, err := crypto.GenerateEncryptionKey()
+	if err != nil {
+		t.Fatalf("GenerateEncryptionKey failed: %v", err)
+	}
+	server := ServerIdentity{ID: "abc", Name: "nas1", Hostname: "nas1.local"}
+
+	h, err := NewHeader(dataKey, "correct horse battery", server)
+	if err != nil {
+		t.Fatalf("NewHeader failed: %v", err)
+	}
+	if h.KeyID != KeyID(dataKey) || h.Server != server {
+		t.Errorf("unexpected header %+v", h)
+	}
+
+	// The header survives a write and read on disk
+	dir := t.TempDir()
+	if err := WriteHeader(dir, h); err != nil {
+		t.Fatalf("WriteHeader failed: %v", err)
+	}
+	loaded, err := ReadHeader(dir)
+	if err != nil {
+		t.Fatalf("ReadHeader failed: %v", err)
+	}
+
+	got, err := loaded.Unwrap("correct horse battery")
+	if err != nil {
+		t.Fatalf("Unwrap failed: %v", err)
+	}
+	if got != dataKey {
+		t.Errorf("Unwrap returned %q, expected %q", got, dataKey)
+	}
+
+	if _, err := loaded.Unwrap("wrong passphrase!"); err == nil {
+		t.Error("Unwrap succeeded with the wrong passphrase")
+	}
+}
+
+func TestHeaderRewrap(t *testing.T) {
+	dataKey, _ := crypto.GenerateEncryptionKey()
+	h, err := NewHeader(dataKey, "first passphrase", ServerIdentity{Name: "nas1"})
+	if err != nil {
+		t.Fatalf("NewHeader failed: %v", err)
+	}
+	wrappedAt := h.WrappedAt
+	time.Sleep(time.Millisecond)
+
+	if err := h.Rewrap("not the passphrase", "second passphrase"); err == nil {
+		t.Error("Rewrap succeeded with the wrong passphrase")
+	}
+	if err := h.Rewrap("first passphrase", "second passphrase"); err != nil {
+		t.Fatalf("Rewrap failed: %v", err)
+	}
+	if !h.WrappedAt.After(wrappedAt) {
+		t.Error("Rewrap did not update WrappedAt")
+	}
+
+	// The data key is unchanged so files on the drive stay readable
+	got, err := h.Unwrap("second passphrase")
+	if err != nil || got != dataKey {
+		t.Errorf("Unwrap after rewrap = %q, %v", got, err)
+	}
+	if _, err := h.Unwrap("first passphrase"); err == nil {
+		t.Error("old passphrase still unlocks the drive")
+	}
+}
+
+func TestHeaderPassphraseTooShort(t *testing.T) {
+	dataKey, _ := crypto.GenerateEncryptionKey()
+	if _, err := NewHeader(dataKey, "short", ServerIdentity{}); err == nil {
+		t.Error("NewHeader accepted a short passphrase")
+	}
+}
+
+func TestResolveDriveKeyWithHeader(t *testing.T) {
+	dataKey, _ := crypto.GenerateEncryptionKey()
+	h, err := NewHeader(dataKey, "drive passphrase", ServerIdentity{Name: "nas1"})
+	if err != nil {
+		t.Fatalf("NewHeader failed: %v", err)
+	}
+	dir := t.TempDir()
+	if err := WriteHeader(dir, h); err != nil {
+		t.Fatalf("WriteHeader failed: %v", err)
+	}
+	if !IsBackupDir(dir) {
+		t.Error("a directory with a header is not detected as a backup")
+	}
+
+	key, err := ResolveDriveKey(dir, "drive passphrase")
+	if err != nil || key != dataKey {
+		t.Errorf("ResolveDriveKey = %q, %v", key, err)
+	}
+	if _, err := ResolveDriveKey(dir, "another passphrase"); err == nil {
+		t.Error("ResolveDriveKey succeeded with the wrong passphrase")
+	}
+}
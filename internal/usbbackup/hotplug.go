@@ -0,0 +1,341 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+// This file recognises registered backup drives by filesystem UUID when they are
+// plugged in, runs their backup and ejects them once it is done.
+
+package usbbackup
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	gosync "sync"
+	"syscall"
+	"time"
+
+	"github.com/juste-un-gars/anemone/internal/logger"
+	"github.com/juste-un-gars/anemone/internal/storage"
+	"github.com/juste-un-gars/anemone/internal/sync"
+)
+
+// hotplugInterval is how often block devices are listed to detect plugged drives
+const hotplugInterval = 5 * time.Second
+
+// ErrSyncInProgress is returned by RunBackup when the backup is already running
+var ErrSyncInProgress = errors.New("a backup to this drive is already running")
+
+// HotplugEvent reports the automatic backup of a drive that was plugged in
+type HotplugEvent struct {
+	BackupID     int
+	BackupName   string
+	DriveName    string
+	Device       string
+	Status       string // "running", "success", "error"
+	Error        string
+	FilesSynced  int
+	SafeToRemove bool // Drive flushed and unmounted
+	Time         time.Time
+}
+
+var (
+	// Backups currently being written, guarded by runningMu
+	runningBackups = make(map[int]bool)
+	runningMu      gosync.Mutex
+
+	// Last hotplug event of each backup, guarded by hotplugMu
+	hotplugEvents = make(map[int]*HotplugEvent)
+	hotplugMu     gosync.Mutex
+)
+
+// MountedDrive returns the filesystem currently mounted at the backup mount path
+func (b *USBBackup) MountedDrive() (*BlockDevice, error) {
+	source := mountSource(b.MountPath)
+	if source == "" {
+		return nil, fmt.Errorf("backup drive not mounted: %s", b.MountPath)
+	}
+	devices, err := ListBlockDevices()
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range devices {
+		if d.Path == source || d.MountPoint == b.MountPath {
+			return &d, nil
+		}
+	}
+	return nil, fmt.Errorf("no filesystem found for %s", source)
+}
+
+// checkDrive makes sure the drive mounted at MountPath belongs to the rotation set of
+// the backup, so that another drive mounted at the same path never receives it, and
+// returns it. A backup without any registered drive adopts the drive of its first sync.
+func checkDrive(db *sql.DB, backup *USBBackup) (*Drive, error) {
+	drives, err := GetDrives(db, backup.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	dev, err := backup.MountedDrive()
+	if err != nil {
+		if len(drives) == 0 {
+			// Not a block device (bind mount, network share): nothing to verify
+			return nil, nil
+		}
+		return nil, fmt.Errorf("cannot identify the drive mounted at %s: %w", backup.MountPath, err)
+	}
+
+	if len(drives) == 0 {
+		logger.Info("USB backup: drive registered", "name", backup.Name, "uuid", dev.UUID, "label", dev.Label)
+		return AddDrive(db, backup.ID, *dev)
+	}
+	if d := matchDrive(drives, *dev); d != nil {
+		return d, nil
+	}
+	return nil, fmt.Errorf("the drive mounted at %s (filesystem %s) is not part of this backup", backup.MountPath, dev.UUID)
+}
+
+// RunBackup runs a backup to its drive: the configuration, then the selected shares
+// for full backups. Only one backup runs per drive at a time.
+func RunBackup(db *sql.DB, backup *USBBackup, dataDir, masterKey, serverName string) (*SyncResult, error) {
+	if !tryStart(backup.ID) {
+		return nil, ErrSyncInProgress
+	}
+	defer finish(backup.ID)
+
+	if !backup.IsMounted() {
+		return nil, fmt.Errorf("backup drive not mounted: %s", backup.MountPath)
+	}
+	drive, err := checkDrive(db, backup)
+	if err != nil {
+		UpdateSyncStatus(db, backup.ID, "error", err.Error(), 0, 0)
+		return nil, err
+	}
+
+	configInfo := &ConfigBackupInfo{
+		DataDir:  dataDir,
+		DBPath:   filepath.Join(dataDir, "db", "anemone.db"),
+		CertsDir: filepath.Join(dataDir, "certs"),
+		SMBConf:  filepath.Join(dataDir, "smb", "smb.conf"),
+	}
+
+	var result *SyncResult
+	if backup.BackupType == BackupTypeConfig {
+		result, err = SyncConfig(db, backup, configInfo, masterKey, serverName)
+	} else {
+		configResult, _ := SyncConfig(db, backup, configInfo, masterKey, serverName)
+		result, err = SyncAllShares(db, backup, masterKey, serverName)
+		if result != nil && configResult != nil {
+			result.FilesAdded += configResult.FilesAdded
+			result.BytesSynced += configResult.BytesSynced
+		}
+	}
+
+	// Each drive of a rotation set keeps its own status
+	if drive != nil {
+		switch {
+		case err != nil:
+			updateDriveStatus(db, drive.ID, "error", err.Error(), 0, 0)
+		case len(result.Errors) > 0:
+			updateDriveStatus(db, drive.ID, "error", fmt.Sprintf("%d errors, first: %s", len(result.Errors), result.Errors[0]), 0, 0)
+		default:
+			updateDriveStatus(db, drive.ID, "success", "", result.FilesAdded+result.FilesUpdated, result.BytesSynced)
+		}
+	}
+	return result, err
+}
+
+// tryStart marks a drive as busy, or returns false if a backup or check already uses it
+func tryStart(id int) bool {
+	runningMu.Lock()
+	defer runningMu.Unlock()
+	if runningBackups[id] {
+		return false
+	}
+	runningBackups[id] = true
+	return true
+}
+
+// finish releases a drive marked busy by tryStart
+func finish(id int) {
+	runningMu.Lock()
+	defer runningMu.Unlock()
+	delete(runningBackups, id)
+}
+
+// IsRunning reports whether a backup to the drive is in progress
+func IsRunning(id int) bool {
+	runningMu.Lock()
+	defer runningMu.Unlock()
+	return runningBackups[id]
+}
+
+// StartHotplugMonitor watches for registered drives being plugged in. Drives of
+// enabled backups with automatic sync are mounted if needed, backed up, then
+// flushed, unmounted and ejected. Drives already present at startup are left to
+// the scheduler.
+func StartHotplugMonitor(db *sql.DB, dataDir string) {
+	logger.Info("🔌 Starting USB hotplug monitor...")
+
+	go func() {
+		ticker := time.NewTicker(hotplugInterval)
+		defer ticker.Stop()
+
+		var known map[string]bool
+		loggedError := false
+		for ; ; <-ticker.C {
+			devices, err := ListBlockDevices()
+			if err != nil {
+				if !loggedError {
+					logger.Warn("USB hotplug: cannot list block devices", "error", err)
+					loggedError = true
+				}
+				continue
+			}
+			loggedError = false
+
+			present := make(map[string]bool, len(devices))
+			for _, d := range devices {
+				present[d.UUID] = true
+				if known != nil && !known[d.UUID] {
+					handleDriveAdded(db, dataDir, d)
+				}
+			}
+			known = present
+		}
+	}()
+}
+
+// handleDriveAdded starts the backups registered for a drive that was just plugged in
+func handleDriveAdded(db *sql.DB, dataDir string, d BlockDevice) {
+	backups, err := GetEnabled(db)
+	if err != nil {
+		logger.Warn("USB hotplug: failed to get backups", "error", err)
+		return
+	}
+	for _, b := range backups {
+		if !b.AutoDetect {
+			continue
+		}
+		drives, err := GetDrives(db, b.ID)
+		if err != nil {
+			logger.Warn("USB hotplug: failed to get drives", "name", b.Name, "error", err)
+			continue
+		}
+		member := matchDrive(drives, d)
+		if member == nil {
+			continue
+		}
+		logger.Info("USB hotplug: registered drive connected", "name", b.Name, "drive", member.Name, "device", d.Path, "uuid", d.UUID)
+		go runHotplugBackup(db, dataDir, b, member, d)
+		// A drive holds a single backup definition
+		return
+	}
+}
+
+// runHotplugBackup mounts a plugged drive, backs it up and ejects it
+func runHotplugBackup(db *sql.DB, dataDir string, backup *USBBackup, member *Drive, d BlockDevice) {
+	event := &HotplugEvent{
+		BackupID:   backup.ID,
+		BackupName: backup.Name,
+		DriveName:  member.Name,
+		Device:     d.Path,
+		Status:     "running",
+		Time:       time.Now(),
+	}
+	setHotplugEvent(event)
+
+	fail := func(err error) {
+		logger.Warn("USB hotplug: automatic backup failed", "name", backup.Name, "error", err)
+		event.Status = "error"
+		event.Error = err.Error()
+		event.Time = time.Now()
+		setHotplugEvent(event)
+	}
+
+	mountPath := d.MountPoint
+	if mountPath == "" {
+		if err := storage.MountDisk(d.Path, backup.MountPath, false); err != nil {
+			fail(err)
+			return
+		}
+		mountPath = backup.MountPath
+	}
+	if mountPath != backup.MountPath {
+		// Mounted elsewhere by the system: back up where the drive actually is
+		moved := *backup
+		moved.MountPath = mountPath
+		backup = &moved
+	}
+
+	var masterKey string
+	if err := db.QueryRow("SELECT value FROM system_config WHERE key = 'master_key'").Scan(&masterKey); err != nil {
+		fail(fmt.Errorf("failed to get master key: %w", err))
+		return
+	}
+	serverName, _ := sync.GetServerName(db)
+	if serverName == "" {
+		serverName = "anemone"
+	}
+
+	// Wait for a scheduled or manual backup already writing to the drive
+	result, syncErr := RunBackup(db, backup, dataDir, masterKey, serverName)
+	for errors.Is(syncErr, ErrSyncInProgress) {
+		time.Sleep(hotplugInterval)
+		result, syncErr = RunBackup(db, backup, dataDir, masterKey, serverName)
+	}
+	if result != nil {
+		event.FilesSynced = result.FilesAdded + result.FilesUpdated
+		if syncErr == nil && len(result.Errors) > 0 {
+			syncErr = fmt.Errorf("%d errors, first: %s", len(result.Errors), result.Errors[0])
+		}
+	}
+
+	// Flush and release the drive whatever the outcome
+	syscall.Sync()
+	if err := storage.UnmountDisk(mountPath, true); err != nil {
+		logger.Warn("USB hotplug: failed to release drive", "name", backup.Name, "error", err)
+		if mountSource(mountPath) != "" {
+			if syncErr == nil {
+				syncErr = err
+			}
+			fail(fmt.Errorf("%v (the drive is still mounted)", syncErr))
+			return
+		}
+	}
+	event.SafeToRemove = true
+
+	if syncErr != nil {
+		fail(syncErr)
+		return
+	}
+	event.Status = "success"
+	event.Time = time.Now()
+	setHotplugEvent(event)
+	logger.Info("USB hotplug: backup completed, drive can be removed", "name", backup.Name, "files", event.FilesSynced)
+}
+
+// setHotplugEvent records the latest event of a backup
+func setHotplugEvent(event *HotplugEvent) {
+	hotplugMu.Lock()
+	defer hotplugMu.Unlock()
+	snapshot := *event
+	hotplugEvents[event.BackupID] = &snapshot
+}
+
+// RecentHotplugEvents returns the hotplug events of the last maxAge, newest first
+func RecentHotplugEvents(maxAge time.Duration) []HotplugEvent {
+	hotplugMu.Lock()
+	defer hotplugMu.Unlock()
+
+	var events []HotplugEvent
+	for _, e := range hotplugEvents {
+		if e.Status == "running" || time.Since(e.Time) <= maxAge {
+			events = append(events, *e)
+		}
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].Time.After(events[j].Time) })
+	return events
+}
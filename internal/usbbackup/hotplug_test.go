@@ -0,0 +1,53 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+package usbbackup
+
+import "testing"
+
+func TestParseLsblkPairs(t *testing.T) {
+	line := `NAME="/dev/sdb1" UUID="1A2B-3C4D" LABEL="My\x20Backup" FSTYPE="vfat" MOUNTPOINT=""`
+	fields := parseLsblkPairs(line)
+
+	expected := map[string]string{
+		"NAME":       "/dev/sdb1",
+		"UUID":       "1A2B-3C4D",
+		"LABEL":      "My Backup",
+		"FSTYPE":     "vfat",
+		"MOUNTPOINT": "",
+	}
+	for k, v := range expected {
+		got, ok := fields[k]
+		if !ok || got != v {
+			t.Errorf("%s = %q, expected %q", k, got, v)
+		}
+	}
+}
+
+func TestMatchDrive(t *testing.T) {
+	drives := []*Drive{
+		{ID: 1, Name: "Drive A", FsUUID: "1A2B-3C4D", FsLabel: "BACKUP"},
+		{ID: 2, Name: "Drive B", FsUUID: "5e6f-7a8b", FsLabel: "BACKUP"},
+	}
+
+	tests := []struct {
+		name string
+		dev  BlockDevice
+		want int
+	}{
+		{"same uuid other case", BlockDevice{UUID: "1a2b-3c4d"}, 1},
+		{"second drive", BlockDevice{UUID: "5E6F-7A8B"}, 2},
+		{"same label other uuid", BlockDevice{UUID: "ffff-0000", Label: "BACKUP"}, 0},
+		{"no uuid", BlockDevice{Label: "BACKUP"}, 0},
+	}
+	for _, tt := range tests {
+		got := 0
+		if d := matchDrive(drives, tt.dev); d != nil {
+			got = d.ID
+		}
+		if got != tt.want {
+			t.Errorf("%s: matchDrive = %d, expected %d", tt.name, got, tt.want)
+		}
+	}
+}
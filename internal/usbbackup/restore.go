@@ -0,0 +1,665 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+// This file reads Anemone backups back from USB drives and restores their content.
+
+package usbbackup
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	gosync "sync"
+	"time"
+
+	"github.com/juste-un-gars/anemone/internal/crypto"
+	"github.com/juste-un-gars/anemone/internal/logger"
+)
+
+const (
+	manifestFileName       = ".anemone-manifest.json"
+	configManifestFileName = ".anemone-config-manifest.json"
+	recoveryKeyFileName    = "recovery.key"
+)
+
+// ConfigManifest describes the configuration backup written by SyncConfig
+type ConfigManifest struct {
+	Version      int    `json:"version"`
+	BackupType   string `json:"backup_type"`
+	SourceServer string `json:"source_server"`
+	Timestamp    string `json:"timestamp"`
+	FilesCount   int    `json:"files_count"`
+	BytesTotal   int64  `json:"bytes_total"`
+}
+
+// ShareBackupInfo describes one share backed up on a drive
+type ShareBackupInfo struct {
+	Dir          string    `json:"dir"` // Directory name on the drive: {user_id}_{share_name}
+	UserID       int       `json:"user_id"`
+	Username     string    `json:"username,omitempty"`
+	ShareName    string    `json:"share_name"`
+	SourceServer string    `json:"source_server"`
+	LastSync     time.Time `json:"last_sync"`
+	FileCount    int       `json:"file_count"`
+	TotalSize    int64     `json:"total_size"`
+	Locked       bool      `json:"locked"` // Manifest could not be decrypted with the given key
+}
+
+// DriveBackup is an Anemone backup found on a mounted drive
+type DriveBackup struct {
+	MountPath      string            `json:"mount_path"`
+	DevicePath     string            `json:"device_path"`
+	Label          string            `json:"label"`
+	Path           string            `json:"path"` // Backup directory on the drive
+	Config         *ConfigManifest   `json:"config,omitempty"`
+	Header         *Header           `json:"header,omitempty"` // Drive protected by its own passphrase
+	HasRecoveryKey bool              `json:"has_recovery_key"`
+	Shares         []ShareBackupInfo `json:"shares"`
+}
+
+// RestoreFile is a file listed in a share manifest
+type RestoreFile struct {
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// RestoreRequest describes what to restore from a drive
+type RestoreRequest struct {
+	BackupPath string   // Backup directory on the drive
+	ShareDir   string   // Share directory inside BackupPath
+	Paths      []string // Files or folders to restore (empty = whole share)
+	TargetDir  string   // Local directory receiving the files
+	Owner      string   // Local user owning restored files ("" = leave as is)
+	Overwrite  bool     // Replace existing files instead of skipping them
+	MasterKey  string   // Key the drive content was encrypted with
+}
+
+// RestoreJob tracks a USB restore running in the background
+type RestoreJob struct {
+	ID             int64      `json:"id"`
+	BackupPath     string     `json:"backup_path"`
+	ShareDir       string     `json:"share_dir"`
+	ShareName      string     `json:"share_name"`
+	UserID         int        `json:"user_id"`
+	TargetDir      string     `json:"target_dir"`
+	Status         string     `json:"status"` // "running", "success", "partial", "error"
+	TotalFiles     int        `json:"total_files"`
+	ProcessedFiles int        `json:"processed_files"`
+	SkippedFiles   int        `json:"skipped_files"`
+	FailedFiles    int        `json:"failed_files"`
+	TotalBytes     int64      `json:"total_bytes"`
+	ProcessedBytes int64      `json:"processed_bytes"`
+	Errors         []string   `json:"errors"`
+	Error          string     `json:"error"`
+	StartedAt      time.Time  `json:"started_at"`
+	CompletedAt    *time.Time `json:"completed_at"`
+}
+
+// USB restore jobs started since boot, guarded by restoreJobsMu
+var (
+	restoreJobs   = make(map[int64]*RestoreJob)
+	restoreJobsMu gosync.Mutex
+	lastJobID     int64
+)
+
+// FindBackups scans mounted drives for Anemone backups.
+// Manifests that cannot be decrypted with masterKey are reported as locked.
+func FindBackups(masterKey string) ([]DriveBackup, error) {
+	drives, err := DetectDrives()
+	if err != nil {
+		return nil, err
+	}
+
+	var found []DriveBackup
+	for _, drive := range drives {
+		candidates := []string{drive.MountPath}
+		if entries, err := os.ReadDir(drive.MountPath); err == nil {
+			for _, e := range entries {
+				if e.IsDir() && !strings.HasPrefix(e.Name(), ".") {
+					candidates = append(candidates, filepath.Join(drive.MountPath, e.Name()))
+				}
+			}
+		}
+
+		for _, dir := range candidates {
+			if !IsBackupDir(dir) {
+				continue
+			}
+			b, err := InspectBackup(dir, masterKey)
+			if err != nil {
+				logger.Warn("USB restore: failed to inspect backup", "path", dir, "error", err)
+				continue
+			}
+			b.MountPath = drive.MountPath
+			b.DevicePath = drive.DevicePath
+			b.Label = drive.Label
+			found = append(found, *b)
+		}
+	}
+	return found, nil
+}
+
+// IsBackupPath reports whether path is an Anemone backup directory on a mounted drive
+func IsBackupPath(path string) bool {
+	path = filepath.Clean(path)
+	drives, err := DetectDrives()
+	if err != nil {
+		return false
+	}
+	for _, drive := range drives {
+		if path == drive.MountPath || strings.HasPrefix(path, drive.MountPath+string(filepath.Separator)) {
+			return IsBackupDir(path)
+		}
+	}
+	return false
+}
+
+// IsBackupDir reports whether dir holds a header, a config backup or at least one share backup
+func IsBackupDir(dir string) bool {
+	if _, err := os.Stat(filepath.Join(dir, HeaderFileName)); err == nil {
+		return true
+	}
+	if _, err := os.Stat(filepath.Join(dir, "config", configManifestFileName)); err == nil {
+		return true
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return false
+	}
+	for _, e := range entries {
+		if e.IsDir() {
+			if _, err := os.Stat(filepath.Join(dir, e.Name(), manifestFileName)); err == nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// InspectBackup reads the config manifest and share manifests of a backup directory
+func InspectBackup(dir, masterKey string) (*DriveBackup, error) {
+	b := &DriveBackup{Path: dir}
+
+	if data, err := os.ReadFile(filepath.Join(dir, "config", configManifestFileName)); err == nil {
+		var cm ConfigManifest
+		if err := json.Unmarshal(data, &cm); err == nil {
+			b.Config = &cm
+		}
+	}
+	if h, err := ReadHeader(dir); err == nil {
+		b.Header = h
+	}
+	if _, err := os.Stat(filepath.Join(dir, "config", recoveryKeyFileName)); err == nil {
+		b.HasRecoveryKey = true
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup directory: %w", err)
+	}
+	for _, e := range entries {
+		if !e.IsDir() || e.Name() == "config" {
+			continue
+		}
+		shareDir := filepath.Join(dir, e.Name())
+		if _, err := os.Stat(filepath.Join(shareDir, manifestFileName)); err != nil {
+			continue
+		}
+
+		info := ShareBackupInfo{Dir: e.Name()}
+		if idx := strings.Index(e.Name(), "_"); idx > 0 {
+			info.UserID, _ = strconv.Atoi(e.Name()[:idx])
+			info.ShareName = e.Name()[idx+1:]
+		}
+
+		manifest, err := loadManifest(shareDir, masterKey)
+		if err != nil {
+			info.Locked = true
+			b.Shares = append(b.Shares, info)
+			continue
+		}
+		info.UserID = manifest.UserID
+		info.ShareName = manifest.ShareName
+		info.SourceServer = manifest.SourceServer
+		info.LastSync = manifest.LastSync
+		info.FileCount = len(manifest.Files)
+		for _, f := range manifest.Files {
+			info.TotalSize += f.Size
+		}
+		b.Shares = append(b.Shares, info)
+	}
+
+	sort.Slice(b.Shares, func(i, j int) bool { return b.Shares[i].Dir < b.Shares[j].Dir })
+	return b, nil
+}
+
+// ResolveDriveKey turns the secret supplied by an admin into the key the drive is
+// encrypted with. The secret is either the drive passphrase (drives with a header),
+// the master key of the server that wrote it, or the recovery passphrase protecting
+// the recovery key stored on the drive.
+func ResolveDriveKey(backupPath, secret string) (string, error) {
+	secret = strings.TrimSpace(secret)
+	if secret == "" {
+		return "", fmt.Errorf("master key or recovery passphrase required")
+	}
+
+	if h, err := ReadHeader(backupPath); err == nil {
+		if key, err := h.Unwrap(secret); err == nil {
+			return key, nil
+		}
+	}
+
+	if wrapped, err := os.ReadFile(filepath.Join(backupPath, "config", recoveryKeyFileName)); err == nil {
+		if key, err := crypto.DecryptKey(strings.TrimSpace(string(wrapped)), secret); err == nil {
+			return key, nil
+		}
+	}
+
+	if raw, err := base64.StdEncoding.DecodeString(secret); err != nil || len(raw) != 32 {
+		return "", fmt.Errorf("invalid master key or recovery passphrase")
+	}
+	if err := checkMasterKey(backupPath, secret); err != nil {
+		return "", err
+	}
+	return secret, nil
+}
+
+// checkMasterKey verifies a master key against the content of a backup directory
+func checkMasterKey(backupPath, masterKey string) error {
+	if f, err := os.Open(filepath.Join(backupPath, "config", "anemone.db.enc")); err == nil {
+		defer f.Close()
+		if err := crypto.DecryptStream(f, io.Discard, masterKey); err != nil {
+			return fmt.Errorf("invalid master key or recovery passphrase")
+		}
+		return nil
+	}
+
+	b, err := InspectBackup(backupPath, masterKey)
+	if err != nil {
+		return err
+	}
+	for _, s := range b.Shares {
+		if !s.Locked {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid master key or recovery passphrase")
+}
+
+// KnownDriveKey returns the key of a drive written by this server: the data key of the
+// USB backup whose header matches the one on the drive, or the master key otherwise.
+func KnownDriveKey(db *sql.DB, backupPath, masterKey string) string {
+	onDrive, err := ReadHeader(backupPath)
+	if err != nil {
+		return masterKey
+	}
+	backups, err := GetAll(db)
+	if err != nil {
+		return masterKey
+	}
+	for _, b := range backups {
+		if !b.HasPassphrase() {
+			continue
+		}
+		var h Header
+		if json.Unmarshal([]byte(b.KeyHeader), &h) != nil || h.KeyID != onDrive.KeyID {
+			continue
+		}
+		if key, err := crypto.DecryptKey(b.DataKeyEncrypted, masterKey); err == nil {
+			return key
+		}
+	}
+	return masterKey
+}
+
+// SetRecoveryPassphrase stores the master key wrapped with a recovery passphrase.
+// SyncConfig copies it to every drive so a backup can be restored without the master key.
+func SetRecoveryPassphrase(db *sql.DB, masterKey, passphrase string) error {
+	wrapped, err := crypto.EncryptKey(masterKey, passphrase)
+	if err != nil {
+		return fmt.Errorf("failed to wrap master key: %w", err)
+	}
+	_, err = db.Exec(`INSERT INTO system_config (key, value, updated_at)
+		VALUES ('usb_recovery_key', ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(key) DO UPDATE SET value = excluded.value, updated_at = excluded.updated_at`, wrapped)
+	if err != nil {
+		return fmt.Errorf("failed to save recovery key: %w", err)
+	}
+	return nil
+}
+
+// HasRecoveryPassphrase reports whether a recovery passphrase has been configured
+func HasRecoveryPassphrase(db *sql.DB) bool {
+	var wrapped string
+	err := db.QueryRow("SELECT value FROM system_config WHERE key = 'usb_recovery_key'").Scan(&wrapped)
+	return err == nil && wrapped != ""
+}
+
+// ListFiles returns the files of a share backup, sorted by path
+func ListFiles(backupPath, shareDir, masterKey string) ([]RestoreFile, error) {
+	dir, err := shareBackupDir(backupPath, shareDir)
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := loadManifest(dir, masterKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	files := make([]RestoreFile, 0, len(manifest.Files))
+	for relPath, meta := range manifest.Files {
+		files = append(files, RestoreFile{
+			Path:    filepath.ToSlash(relPath),
+			Size:    meta.Size,
+			ModTime: meta.ModTime,
+		})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files, nil
+}
+
+// shareBackupDir returns the path of a share directory inside a backup, rejecting traversal
+func shareBackupDir(backupPath, shareDir string) (string, error) {
+	if shareDir == "" || shareDir == "config" || strings.ContainsAny(shareDir, "/\\") || strings.HasPrefix(shareDir, ".") {
+		return "", fmt.Errorf("invalid share backup")
+	}
+	return filepath.Join(backupPath, shareDir), nil
+}
+
+// StartRestore starts restoring files of a share backup in the background
+func StartRestore(req RestoreRequest) (*RestoreJob, error) {
+	dir, err := shareBackupDir(req.BackupPath, req.ShareDir)
+	if err != nil {
+		return nil, err
+	}
+	if req.TargetDir == "" || !filepath.IsAbs(req.TargetDir) {
+		return nil, fmt.Errorf("invalid target directory")
+	}
+
+	manifest, err := loadManifest(dir, req.MasterKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	files := selectFiles(manifest, req.Paths)
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no files selected")
+	}
+
+	restoreJobsMu.Lock()
+	defer restoreJobsMu.Unlock()
+
+	// Refuse a second restore into the same directory while one is running
+	for _, j := range restoreJobs {
+		if j.Status == "running" && j.TargetDir == req.TargetDir {
+			return nil, fmt.Errorf("a restore into this directory is already running")
+		}
+	}
+
+	lastJobID++
+	job := &RestoreJob{
+		ID:         lastJobID,
+		BackupPath: req.BackupPath,
+		ShareDir:   req.ShareDir,
+		ShareName:  manifest.ShareName,
+		UserID:     manifest.UserID,
+		TargetDir:  req.TargetDir,
+		Status:     "running",
+		TotalFiles: len(files),
+		StartedAt:  time.Now(),
+	}
+	for _, rel := range files {
+		job.TotalBytes += manifest.Files[rel].Size
+	}
+	restoreJobs[job.ID] = job
+
+	go runRestore(job, dir, manifest, files, req)
+
+	snapshot := *job
+	return &snapshot, nil
+}
+
+// GetRestoreJob returns a snapshot of a USB restore job
+func GetRestoreJob(id int64) (*RestoreJob, bool) {
+	restoreJobsMu.Lock()
+	defer restoreJobsMu.Unlock()
+
+	job, ok := restoreJobs[id]
+	if !ok {
+		return nil, false
+	}
+	snapshot := *job
+	snapshot.Errors = append([]string(nil), job.Errors...)
+	return &snapshot, true
+}
+
+// ListRestoreJobs returns snapshots of the USB restore jobs started since boot, newest first
+func ListRestoreJobs() []*RestoreJob {
+	restoreJobsMu.Lock()
+	defer restoreJobsMu.Unlock()
+
+	jobs := make([]*RestoreJob, 0, len(restoreJobs))
+	for _, j := range restoreJobs {
+		snapshot := *j
+		snapshot.Errors = append([]string(nil), j.Errors...)
+		jobs = append(jobs, &snapshot)
+	}
+	sort.Slice(jobs, func(i, k int) bool { return jobs[i].ID > jobs[k].ID })
+	return jobs
+}
+
+// runRestore decrypts the selected files into the target directory
+func runRestore(job *RestoreJob, dir string, manifest *BackupManifest, files []string, req RestoreRequest) {
+	logger.Info("USB restore: starting", "job_id", job.ID, "share", job.ShareDir, "files", len(files), "target", job.TargetDir)
+
+	uid, gid := -1, -1
+	if req.Owner != "" {
+		if u, err := user.Lookup(req.Owner); err == nil {
+			uid, _ = strconv.Atoi(u.Uid)
+			gid, _ = strconv.Atoi(u.Gid)
+		} else {
+			logger.Warn("USB restore: owner not found, keeping current ownership", "owner", req.Owner)
+		}
+	}
+
+	for _, rel := range files {
+		meta := manifest.Files[rel]
+		skipped, err := restoreFile(dir, rel, meta, job.TargetDir, req.Overwrite, req.MasterKey)
+
+		restoreJobsMu.Lock()
+		job.ProcessedFiles++
+		job.ProcessedBytes += meta.Size
+		switch {
+		case err != nil:
+			job.FailedFiles++
+			job.Errors = append(job.Errors, fmt.Sprintf("%s: %v", rel, err))
+		case skipped:
+			job.SkippedFiles++
+		}
+		restoreJobsMu.Unlock()
+	}
+
+	// Give restored files and folders to the share owner
+	if uid >= 0 {
+		filepath.Walk(job.TargetDir, func(path string, info os.FileInfo, err error) error {
+			if err == nil {
+				os.Lchown(path, uid, gid)
+			}
+			return nil
+		})
+	}
+
+	restoreJobsMu.Lock()
+	now := time.Now()
+	job.CompletedAt = &now
+	switch {
+	case job.FailedFiles == 0:
+		job.Status = "success"
+	case job.FailedFiles < job.TotalFiles:
+		job.Status = "partial"
+	default:
+		job.Status = "error"
+		job.Error = "no file could be restored"
+	}
+	status, failed := job.Status, job.FailedFiles
+	restoreJobsMu.Unlock()
+
+	logger.Info("USB restore: completed", "job_id", job.ID, "status", status, "failed", failed)
+}
+
+// insideRoot reports whether the real path of dir, through its symlinks, is
+// root or below it
+func insideRoot(root, dir string) bool {
+	rootReal, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return false
+	}
+	dirReal, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return false
+	}
+	return dirReal == rootReal || strings.HasPrefix(dirReal, rootReal+string(filepath.Separator))
+}
+
+// restoreFile decrypts one backed up file, verifying its checksum.
+// Returns true when the file was skipped because it already exists.
+func restoreFile(dir, rel string, meta FileMetadata, targetDir string, overwrite bool, masterKey string) (bool, error) {
+	dest := filepath.Join(targetDir, rel)
+	if !strings.HasPrefix(dest, filepath.Clean(targetDir)+string(filepath.Separator)) {
+		return false, fmt.Errorf("invalid path")
+	}
+	if _, err := os.Lstat(dest); err == nil && !overwrite {
+		return true, nil
+	}
+
+	src, err := os.Open(filepath.Join(dir, meta.EncryptedName))
+	if err != nil {
+		return false, fmt.Errorf("missing on drive: %w", err)
+	}
+	defer src.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return false, fmt.Errorf("failed to create directory: %w", err)
+	}
+	// A symlink in the target must not send the file outside of it
+	if !insideRoot(targetDir, filepath.Dir(dest)) {
+		return false, fmt.Errorf("path outside target folder")
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(dest), ".anemone-restore-*")
+	if err != nil {
+		return false, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+
+	hash := sha256.New()
+	err = crypto.DecryptStream(src, io.MultiWriter(tmp, hash), masterKey)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to decrypt: %w", err)
+	}
+	if meta.Checksum != "" && hex.EncodeToString(hash.Sum(nil)) != meta.Checksum {
+		return false, fmt.Errorf("checksum mismatch")
+	}
+
+	if err := os.Rename(tmpPath, dest); err != nil {
+		return false, fmt.Errorf("failed to move into place: %w", err)
+	}
+	os.Chtimes(dest, meta.ModTime, meta.ModTime)
+	return false, nil
+}
+
+// selectFiles returns the manifest paths matching the selection (files or folders).
+// An empty selection matches every file.
+func selectFiles(manifest *BackupManifest, selected []string) []string {
+	var prefixes []string
+	for _, p := range selected {
+		p = strings.Trim(filepath.ToSlash(filepath.Clean("/"+p)), "/")
+		if p == "" {
+			prefixes = nil
+			selected = nil
+			break
+		}
+		prefixes = append(prefixes, p)
+	}
+
+	var files []string
+	for relPath := range manifest.Files {
+		if len(selected) == 0 {
+			files = append(files, relPath)
+			continue
+		}
+		slashed := filepath.ToSlash(relPath)
+		for _, prefix := range prefixes {
+			if slashed == prefix || strings.HasPrefix(slashed, prefix+"/") {
+				files = append(files, relPath)
+				break
+			}
+		}
+	}
+	sort.Strings(files)
+	return files
+}
+
+// RestoreConfig decrypts the configuration backup (database, certificates, smb.conf) into destDir
+func RestoreConfig(backupPath, masterKey, destDir string) (int, error) {
+	configDir := filepath.Join(backupPath, "config")
+	if _, err := os.Stat(filepath.Join(configDir, "anemone.db.enc")); err != nil {
+		return 0, fmt.Errorf("no configuration backup on this drive")
+	}
+	if err := os.MkdirAll(destDir, 0700); err != nil {
+		return 0, fmt.Errorf("failed to create destination: %w", err)
+	}
+
+	restored := 0
+	err := filepath.Walk(configDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || !strings.HasSuffix(path, ".enc") {
+			return nil
+		}
+		rel, _ := filepath.Rel(configDir, path)
+		dest := filepath.Join(destDir, strings.TrimSuffix(rel, ".enc"))
+		if err := os.MkdirAll(filepath.Dir(dest), 0700); err != nil {
+			return err
+		}
+		if err := decryptFile(path, dest, masterKey); err != nil {
+			return fmt.Errorf("%s: %w", rel, err)
+		}
+		restored++
+		return nil
+	})
+	return restored, err
+}
+
+// decryptFile decrypts src into a new dest file readable only by its owner
+func decryptFile(src, dest, masterKey string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dest, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if err := crypto.DecryptStream(in, out, masterKey); err != nil {
+		out.Close()
+		os.Remove(dest)
+		return fmt.Errorf("failed to decrypt: %w", err)
+	}
+	return out.Close()
+}
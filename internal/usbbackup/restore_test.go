@@ -0,0 +1,136 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+package usbbackup
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/juste-un-gars/anemone/internal/crypto"
+)
+
+func TestManifestRoundTrip(t *testing.T) {
+	masterKey, err := crypto.GenerateEncryptionKey()
+	if err != nil {
+		t.Fatalf("GenerateEncryptionKey failed: %v", err)
+	}
+	dir := t.TempDir()
+
+	manifest := &BackupManifest{
+		Version:   1,
+		LastSync:  time.Now().UTC().Truncate(time.Second),
+		UserID:    2,
+		ShareName: "data_alice",
+		Files: map[string]FileMetadata{
+			"docs/report.pdf": {Size: 10, Checksum: "abc", EncryptedName: generateEncryptedName("docs/report.pdf")},
+		},
+	}
+	if err := saveManifest(manifest, dir, masterKey); err != nil {
+		t.Fatalf("saveManifest failed: %v", err)
+	}
+
+	// The file list must not be readable on the drive
+	raw, _ := os.ReadFile(filepath.Join(dir, manifestFileName))
+	if len(raw) > 0 && raw[0] == '{' {
+		t.Error("manifest written in plaintext")
+	}
+
+	loaded, err := loadManifest(dir, masterKey)
+	if err != nil {
+		t.Fatalf("loadManifest failed: %v", err)
+	}
+	if !reflect.DeepEqual(loaded.Files, manifest.Files) || loaded.ShareName != "data_alice" {
+		t.Errorf("loaded manifest %+v, expected %+v", loaded, manifest)
+	}
+
+	otherKey, _ := crypto.GenerateEncryptionKey()
+	if _, err := loadManifest(dir, otherKey); err == nil {
+		t.Error("loadManifest succeeded with the wrong key")
+	}
+}
+
+func TestLoadLegacyPlaintextManifest(t *testing.T) {
+	dir := t.TempDir()
+	legacy := `{"version":1,"user_id":3,"share_name":"backup_bob","files":{"a.txt":{"size":1}}}`
+	if err := os.WriteFile(filepath.Join(dir, manifestFileName), []byte(legacy), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := loadManifest(dir, "")
+	if err != nil {
+		t.Fatalf("loadManifest failed: %v", err)
+	}
+	if loaded.UserID != 3 || len(loaded.Files) != 1 {
+		t.Errorf("unexpected legacy manifest: %+v", loaded)
+	}
+}
+
+func TestSelectFiles(t *testing.T) {
+	manifest := &BackupManifest{Files: map[string]FileMetadata{
+		"Documents/report.pdf":  {},
+		"Documents/notes.txt":   {},
+		"Documents2/other.txt":  {},
+		"Projects/code/main.go": {},
+	}}
+
+	testCases := []struct {
+		name     string
+		selected []string
+		expected []string
+	}{
+		{"empty selects everything", nil, []string{"Documents/notes.txt", "Documents/report.pdf", "Documents2/other.txt", "Projects/code/main.go"}},
+		{"root selects everything", []string{"/"}, []string{"Documents/notes.txt", "Documents/report.pdf", "Documents2/other.txt", "Projects/code/main.go"}},
+		{"directory does not match sibling prefix", []string{"Documents"}, []string{"Documents/notes.txt", "Documents/report.pdf"}},
+		{"traversal is cleaned", []string{"../Projects/code"}, []string{"Projects/code/main.go"}},
+		{"unknown path", []string{"Missing"}, nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := selectFiles(manifest, tc.selected)
+			if !reflect.DeepEqual(result, tc.expected) {
+				t.Errorf("selectFiles(%v) = %v, expected %v", tc.selected, result, tc.expected)
+			}
+		})
+	}
+}
+
+func TestRestoreFileStaysInTarget(t *testing.T) {
+	masterKey, err := crypto.GenerateEncryptionKey()
+	if err != nil {
+		t.Fatalf("GenerateEncryptionKey failed: %v", err)
+	}
+	dir, target, outside := t.TempDir(), t.TempDir(), t.TempDir()
+
+	meta := FileMetadata{EncryptedName: generateEncryptedName("link/report.pdf")}
+	var encrypted bytes.Buffer
+	if err := crypto.EncryptStream(bytes.NewReader([]byte("report")), &encrypted, masterKey); err != nil {
+		t.Fatalf("EncryptStream failed: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, meta.EncryptedName), encrypted.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(target, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := restoreFile(dir, "link/report.pdf", meta, target, false, masterKey); err == nil {
+		t.Error("restoreFile followed a symlink out of the target folder")
+	}
+	if entries, _ := os.ReadDir(outside); len(entries) != 0 {
+		t.Errorf("restoreFile wrote %d files outside the target folder", len(entries))
+	}
+
+	if _, err := restoreFile(dir, "docs/report.pdf", FileMetadata{EncryptedName: meta.EncryptedName}, target, false, masterKey); err != nil {
+		t.Fatalf("restoreFile failed: %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(target, "docs", "report.pdf")); string(data) != "report" {
+		t.Errorf("restored %q", data)
+	}
+}
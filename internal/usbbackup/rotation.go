@@ -0,0 +1,275 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+// This file manages rotation sets: several physical drives, identified by their
+// filesystem UUID, sharing one USB backup definition. Each drive keeps its own
+// manifest on disk and its own sync status.
+
+package usbbackup
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Drive is a physical drive belonging to the rotation set of a USB backup
+type Drive struct {
+	ID          int
+	BackupID    int
+	Name        string // Display name (e.g., "Drive A")
+	FsUUID      string
+	FsLabel     string
+	LastSync    *time.Time
+	LastStatus  string
+	LastError   string
+	FilesSynced int
+	BytesSynced int64
+	CreatedAt   time.Time
+}
+
+// DriveState is a member of a rotation set as shown to the admin
+type DriveState struct {
+	*Drive
+	Connected bool // Currently mounted at the backup mount path
+	Overdue   bool // Last sync older than the RPO of the set
+	NextBack  bool // Oldest drive away from the server: the next one to bring back onsite
+}
+
+// RPOWarning reports a drive whose last sync is older than the RPO of its set
+type RPOWarning struct {
+	BackupID   int
+	BackupName string
+	DriveID    int
+	DriveName  string
+	LastSync   *time.Time
+	RPOHours   int
+}
+
+// GetDrives returns the drives of a rotation set, in registration order
+func GetDrives(db *sql.DB, backupID int) ([]*Drive, error) {
+	rows, err := db.Query(`SELECT id, backup_id, name, fs_uuid, fs_label, last_sync, last_status, last_error,
+		files_synced, bytes_synced, created_at
+		FROM usb_backup_drives WHERE backup_id = ? ORDER BY id`, backupID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query USB backup drives: %w", err)
+	}
+	defer rows.Close()
+
+	var drives []*Drive
+	for rows.Next() {
+		d := &Drive{}
+		var label, status, lastError sql.NullString
+		if err := rows.Scan(&d.ID, &d.BackupID, &d.Name, &d.FsUUID, &label, &d.LastSync, &status, &lastError,
+			&d.FilesSynced, &d.BytesSynced, &d.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan USB backup drive: %w", err)
+		}
+		d.FsLabel = label.String
+		d.LastStatus = status.String
+		d.LastError = lastError.String
+		drives = append(drives, d)
+	}
+	return drives, rows.Err()
+}
+
+// AddDrive registers a drive in the rotation set of a backup
+func AddDrive(db *sql.DB, backupID int, dev BlockDevice) (*Drive, error) {
+	if dev.UUID == "" {
+		return nil, fmt.Errorf("drive has no filesystem UUID")
+	}
+
+	drives, err := GetDrives(db, backupID)
+	if err != nil {
+		return nil, err
+	}
+	if d := matchDrive(drives, dev); d != nil {
+		return d, nil
+	}
+
+	name := dev.Label
+	if name == "" {
+		name = fmt.Sprintf("Drive %d", len(drives)+1)
+	}
+	result, err := db.Exec(`INSERT INTO usb_backup_drives (backup_id, name, fs_uuid, fs_label, last_status)
+		VALUES (?, ?, ?, ?, 'unknown')`, backupID, name, dev.UUID, dev.Label)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add USB backup drive: %w", err)
+	}
+	id, _ := result.LastInsertId()
+	return &Drive{ID: int(id), BackupID: backupID, Name: name, FsUUID: dev.UUID, FsLabel: dev.Label, LastStatus: "unknown"}, nil
+}
+
+// AddMountedDrive registers the drive mounted at the backup mount path
+func AddMountedDrive(db *sql.DB, backup *USBBackup) (*Drive, error) {
+	dev, err := backup.MountedDrive()
+	if err != nil {
+		return nil, err
+	}
+	return AddDrive(db, backup.ID, *dev)
+}
+
+// RenameDrive changes the display name of a drive
+func RenameDrive(db *sql.DB, backupID, driveID int, name string) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return fmt.Errorf("drive name cannot be empty")
+	}
+	_, err := db.Exec("UPDATE usb_backup_drives SET name = ? WHERE id = ? AND backup_id = ?", name, driveID, backupID)
+	if err != nil {
+		return fmt.Errorf("failed to rename USB backup drive: %w", err)
+	}
+	return nil
+}
+
+// RemoveDrive removes a drive from a rotation set. Its content is left untouched.
+func RemoveDrive(db *sql.DB, backupID, driveID int) error {
+	_, err := db.Exec("DELETE FROM usb_backup_drives WHERE id = ? AND backup_id = ?", driveID, backupID)
+	if err != nil {
+		return fmt.Errorf("failed to remove USB backup drive: %w", err)
+	}
+	return nil
+}
+
+// updateDriveStatus records the outcome of a sync to one drive of the set
+func updateDriveStatus(db *sql.DB, driveID int, status, errorMsg string, filesSynced int, bytesSynced int64) error {
+	var err error
+	if status == "success" {
+		_, err = db.Exec(`UPDATE usb_backup_drives SET last_sync = CURRENT_TIMESTAMP, last_status = ?, last_error = '',
+			files_synced = ?, bytes_synced = ? WHERE id = ?`, status, filesSynced, bytesSynced, driveID)
+	} else {
+		_, err = db.Exec("UPDATE usb_backup_drives SET last_status = ?, last_error = ? WHERE id = ?", status, errorMsg, driveID)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update USB backup drive status: %w", err)
+	}
+	return nil
+}
+
+// matchDrive returns the member of a set holding the filesystem dev
+func matchDrive(drives []*Drive, dev BlockDevice) *Drive {
+	for _, d := range drives {
+		if dev.UUID != "" && strings.EqualFold(d.FsUUID, dev.UUID) {
+			return d
+		}
+	}
+	return nil
+}
+
+// ConnectedDrive returns the member of the set mounted at the backup mount path.
+// It returns nil without error when no drive, or a drive outside the set, is mounted.
+func ConnectedDrive(db *sql.DB, backup *USBBackup) (*Drive, *BlockDevice, error) {
+	if !backup.IsMounted() {
+		return nil, nil, nil
+	}
+	dev, err := backup.MountedDrive()
+	if err != nil {
+		return nil, nil, err
+	}
+	drives, err := GetDrives(db, backup.ID)
+	if err != nil {
+		return nil, dev, err
+	}
+	return matchDrive(drives, *dev), dev, nil
+}
+
+// Age returns how long ago the drive was last synced (0 if never)
+func (d *Drive) Age() time.Duration {
+	if d.LastSync == nil {
+		return 0
+	}
+	return time.Since(*d.LastSync)
+}
+
+// isOverdue reports whether the drive was last synced longer ago than the RPO
+func (d *Drive) isOverdue(rpoHours int, now time.Time) bool {
+	if rpoHours <= 0 {
+		return false
+	}
+	if d.LastSync == nil {
+		return true
+	}
+	return now.Sub(*d.LastSync) > time.Duration(rpoHours)*time.Hour
+}
+
+// RotationState returns the drives of a set with their connection and rotation status
+func RotationState(db *sql.DB, backup *USBBackup) ([]DriveState, error) {
+	drives, err := GetDrives(db, backup.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	connectedID := 0
+	if connected, _, err := ConnectedDrive(db, backup); err == nil && connected != nil {
+		connectedID = connected.ID
+	}
+	return rotationState(drives, connectedID, backup.RPOHours, time.Now()), nil
+}
+
+// rotationState computes the status of each drive of a set
+func rotationState(drives []*Drive, connectedID, rpoHours int, now time.Time) []DriveState {
+	states := make([]DriveState, len(drives))
+	for i, d := range drives {
+		states[i] = DriveState{
+			Drive:     d,
+			Connected: d.ID == connectedID,
+			Overdue:   d.isOverdue(rpoHours, now),
+		}
+	}
+
+	// With several drives, the one away from the server with the oldest sync comes back next
+	if len(drives) > 1 {
+		away := make([]int, 0, len(states))
+		for i := range states {
+			if !states[i].Connected {
+				away = append(away, i)
+			}
+		}
+		sort.SliceStable(away, func(a, b int) bool {
+			x, y := states[away[a]].Drive, states[away[b]].Drive
+			if x.LastSync == nil || y.LastSync == nil {
+				return x.LastSync == nil && y.LastSync != nil
+			}
+			return x.LastSync.Before(*y.LastSync)
+		})
+		if len(away) > 0 {
+			states[away[0]].NextBack = true
+		}
+	}
+	return states
+}
+
+// GetRPOWarnings returns the drives of enabled backups last synced longer ago than their RPO
+func GetRPOWarnings(db *sql.DB) ([]RPOWarning, error) {
+	backups, err := GetEnabled(db)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var warnings []RPOWarning
+	for _, b := range backups {
+		if b.RPOHours <= 0 {
+			continue
+		}
+		drives, err := GetDrives(db, b.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, d := range drives {
+			if d.isOverdue(b.RPOHours, now) {
+				warnings = append(warnings, RPOWarning{
+					BackupID:   b.ID,
+					BackupName: b.Name,
+					DriveID:    d.ID,
+					DriveName:  d.Name,
+					LastSync:   d.LastSync,
+					RPOHours:   b.RPOHours,
+				})
+			}
+		}
+	}
+	return warnings, nil
+}
@@ -0,0 +1,50 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+package usbbackup
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRotationState(t *testing.T) {
+	now := time.Date(2025, 6, 10, 12, 0, 0, 0, time.UTC)
+	at := func(hoursAgo int) *time.Time {
+		t := now.Add(-time.Duration(hoursAgo) * time.Hour)
+		return &t
+	}
+	drives := []*Drive{
+		{ID: 1, Name: "A", LastSync: at(2)},
+		{ID: 2, Name: "B", LastSync: at(200)},
+		{ID: 3, Name: "C", LastSync: at(30)},
+	}
+
+	states := rotationState(drives, 1, 168, now)
+	want := []struct{ connected, overdue, next bool }{
+		{true, false, false},
+		{false, true, true},
+		{false, false, false},
+	}
+	for i, w := range want {
+		s := states[i]
+		if s.Connected != w.connected || s.Overdue != w.overdue || s.NextBack != w.next {
+			t.Errorf("drive %s: connected=%v overdue=%v next=%v, expected %v %v %v",
+				s.Name, s.Connected, s.Overdue, s.NextBack, w.connected, w.overdue, w.next)
+		}
+	}
+
+	// A drive never synced comes back first and is overdue as soon as an RPO is set
+	drives = append(drives, &Drive{ID: 4, Name: "D"})
+	states = rotationState(drives, 0, 168, now)
+	if !states[3].NextBack || !states[3].Overdue || states[1].NextBack {
+		t.Errorf("never synced drive: next=%v overdue=%v", states[3].NextBack, states[3].Overdue)
+	}
+
+	// Without RPO no drive is overdue, and a single drive is never "next"
+	states = rotationState(drives[:1], 0, 0, now)
+	if states[0].Overdue || states[0].NextBack {
+		t.Errorf("single drive without RPO: overdue=%v next=%v", states[0].Overdue, states[0].NextBack)
+	}
+}
@@ -6,8 +6,8 @@ package usbbackup
 
 import (
 	"database/sql"
+	"errors"
 	"github.com/juste-un-gars/anemone/internal/logger"
-	"path/filepath"
 	"time"
 
 	"github.com/juste-un-gars/anemone/internal/sync"
@@ -23,9 +23,14 @@ func StartScheduler(db *sql.DB, dataDir string) {
 		ticker := time.NewTicker(1 * time.Minute)
 		defer ticker.Stop()
 
+		// Drives already reported as overdue, with the time of the warning
+		rpoWarned := make(map[int]time.Time)
+
 		for {
 			<-ticker.C // Wait for next tick
 
+			logRPOWarnings(db, rpoWarned)
+
 			// Get all enabled USB backups
 			backups, err := GetEnabled(db)
 			if err != nil {
@@ -35,6 +40,11 @@ func StartScheduler(db *sql.DB, dataDir string) {
 
 			// Check each backup individually
 			for _, backup := range backups {
+				// In a rotation set the frequency applies to the drive currently connected
+				if drive, _, err := ConnectedDrive(db, backup); err == nil && drive != nil {
+					backup.LastSync = drive.LastSync
+				}
+
 				// Check if this backup should be synced now
 				if !backup.ShouldSync() {
 					continue
@@ -55,29 +65,10 @@ func StartScheduler(db *sql.DB, dataDir string) {
 					serverName = "anemone"
 				}
 
-				// Build config info
-				configInfo := &ConfigBackupInfo{
-					DataDir:  dataDir,
-					DBPath:   filepath.Join(dataDir, "db", "anemone.db"),
-					CertsDir: filepath.Join(dataDir, "certs"),
-					SMBConf:  filepath.Join(dataDir, "smb", "smb.conf"),
-				}
-
 				// Perform sync
-				var result *SyncResult
-				var syncErr error
-
-				if backup.BackupType == BackupTypeConfig {
-					// Config-only backup
-					result, syncErr = SyncConfig(db, backup, configInfo, masterKey, serverName)
-				} else {
-					// Full backup (config + data)
-					configResult, _ := SyncConfig(db, backup, configInfo, masterKey, serverName)
-					result, syncErr = SyncAllShares(db, backup, masterKey, serverName)
-					if result != nil && configResult != nil {
-						result.FilesAdded += configResult.FilesAdded
-						result.BytesSynced += configResult.BytesSynced
-					}
+				result, syncErr := RunBackup(db, backup, dataDir, masterKey, serverName)
+				if errors.Is(syncErr, ErrSyncInProgress) {
+					continue
 				}
 
 				// Log results
@@ -96,3 +87,19 @@ func StartScheduler(db *sql.DB, dataDir string) {
 
 	logger.Info("✅ USB backup scheduler started (checks every 1 minute)")
 }
+
+// logRPOWarnings logs the drives of rotation sets that missed their RPO, once a day per drive
+func logRPOWarnings(db *sql.DB, warned map[int]time.Time) {
+	warnings, err := GetRPOWarnings(db)
+	if err != nil {
+		logger.Warn("USB Scheduler: Failed to check drive RPO", "error", err)
+		return
+	}
+	for _, w := range warnings {
+		if last, ok := warned[w.DriveID]; ok && time.Since(last) < 24*time.Hour {
+			continue
+		}
+		warned[w.DriveID] = time.Now()
+		logger.Warn("USB backup drive exceeds its RPO", "backup", w.BackupName, "drive", w.DriveName, "last_sync", w.LastSync, "rpo_hours", w.RPOHours)
+	}
+}
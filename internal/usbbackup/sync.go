@@ -5,6 +5,7 @@
 package usbbackup
 
 import (
+	"bytes"
 	"crypto/sha256"
 	"database/sql"
 	"encoding/hex"
@@ -70,6 +71,12 @@ func SyncConfig(db *sql.DB, backup *USBBackup, configInfo *ConfigBackupInfo, mas
 	// Update status to running
 	UpdateSyncStatus(db, backup.ID, "running", "", 0, 0)
 
+	key, err := driveKey(db, backup, masterKey, GetServerIdentity(db, serverName))
+	if err != nil {
+		UpdateSyncStatus(db, backup.ID, "error", err.Error(), 0, 0)
+		return nil, err
+	}
+
 	result := &SyncResult{}
 
 	// Config backup directory
@@ -82,7 +89,7 @@ func SyncConfig(db *sql.DB, backup *USBBackup, configInfo *ConfigBackupInfo, mas
 	// 1. Backup database (encrypted)
 	if configInfo.DBPath != "" {
 		dbDest := filepath.Join(configDir, "anemone.db.enc")
-		bytesCopied, err := copyFileEncrypted(configInfo.DBPath, dbDest, masterKey)
+		bytesCopied, _, err := writeFile(configInfo.DBPath, dbDest, key, backup.VerifyWrites)
 		if err != nil {
 			result.Errors = append(result.Errors, fmt.Sprintf("database: %v", err))
 			logger.Info("USB backup: failed to backup database", "error", err)
@@ -107,7 +114,7 @@ func SyncConfig(db *sql.DB, backup *USBBackup, configInfo *ConfigBackupInfo, mas
 				// Ensure parent directory exists
 				os.MkdirAll(filepath.Dir(destPath), 0755)
 
-				bytesCopied, copyErr := copyFileEncrypted(path, destPath, masterKey)
+				bytesCopied, _, copyErr := writeFile(path, destPath, key, backup.VerifyWrites)
 				if copyErr != nil {
 					result.Errors = append(result.Errors, fmt.Sprintf("cert %s: %v", relPath, copyErr))
 				} else {
@@ -128,7 +135,7 @@ func SyncConfig(db *sql.DB, backup *USBBackup, configInfo *ConfigBackupInfo, mas
 	if configInfo.SMBConf != "" {
 		if _, err := os.Stat(configInfo.SMBConf); err == nil {
 			smbDest := filepath.Join(configDir, "smb.conf.enc")
-			bytesCopied, err := copyFileEncrypted(configInfo.SMBConf, smbDest, masterKey)
+			bytesCopied, _, err := writeFile(configInfo.SMBConf, smbDest, key, backup.VerifyWrites)
 			if err != nil {
 				result.Errors = append(result.Errors, fmt.Sprintf("smb.conf: %v", err))
 				logger.Info("USB backup: failed to backup smb.conf", "error", err)
@@ -140,6 +147,20 @@ func SyncConfig(db *sql.DB, backup *USBBackup, configInfo *ConfigBackupInfo, mas
 		}
 	}
 
+	// 4. Copy the recovery key (master key wrapped with the recovery passphrase).
+	// Drives with their own passphrase are unlocked through their header instead.
+	var recoveryKey string
+	recoveryPath := filepath.Join(configDir, recoveryKeyFileName)
+	if backup.HasPassphrase() {
+		os.Remove(recoveryPath)
+	} else if err := db.QueryRow("SELECT value FROM system_config WHERE key = 'usb_recovery_key'").Scan(&recoveryKey); err == nil && recoveryKey != "" {
+		if err := os.WriteFile(recoveryPath, []byte(recoveryKey), 0600); err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("recovery key: %v", err))
+		}
+	} else {
+		os.Remove(recoveryPath)
+	}
+
 	// Save config manifest
 	configManifest := map[string]interface{}{
 		"version":       1,
@@ -150,7 +171,7 @@ func SyncConfig(db *sql.DB, backup *USBBackup, configInfo *ConfigBackupInfo, mas
 		"bytes_total":   result.BytesSynced,
 	}
 	manifestData, _ := json.MarshalIndent(configManifest, "", "  ")
-	manifestPath := filepath.Join(configDir, ".anemone-config-manifest.json")
+	manifestPath := filepath.Join(configDir, configManifestFileName)
 	os.WriteFile(manifestPath, manifestData, 0600)
 
 	// Update final status
@@ -179,6 +200,12 @@ func SyncAllShares(db *sql.DB, backup *USBBackup, masterKey string, serverName s
 	// Update status to running
 	UpdateSyncStatus(db, backup.ID, "running", "", 0, 0)
 
+	key, err := driveKey(db, backup, masterKey, GetServerIdentity(db, serverName))
+	if err != nil {
+		UpdateSyncStatus(db, backup.ID, "error", err.Error(), 0, 0)
+		return nil, err
+	}
+
 	// Get all shares
 	allShares, err := shares.GetAll(db)
 	if err != nil {
@@ -209,7 +236,7 @@ func SyncAllShares(db *sql.DB, backup *USBBackup, masterKey string, serverName s
 
 		logger.Info("USB backup: syncing share", "name", share.Name, "id", share.ID)
 
-		shareResult, err := syncShare(db, backup, share, masterKey, serverName)
+		shareResult, err := syncShare(db, backup, share, key, masterKey, serverName)
 		if err != nil {
 			errMsg := fmt.Sprintf("share %s: %v", share.Name, err)
 			result.Errors = append(result.Errors, errMsg)
@@ -238,8 +265,9 @@ func SyncAllShares(db *sql.DB, backup *USBBackup, masterKey string, serverName s
 	return result, nil
 }
 
-// syncShare backs up a single share to the USB drive
-func syncShare(db *sql.DB, backup *USBBackup, share *shares.Share, masterKey string, serverName string) (*SyncResult, error) {
+// syncShare backs up a single share to the USB drive, encrypted with key.
+// masterKey is only used to read manifests written before the drive got its own key.
+func syncShare(db *sql.DB, backup *USBBackup, share *shares.Share, key, masterKey string, serverName string) (*SyncResult, error) {
 	result := &SyncResult{}
 
 	// Destination directory: {backup_path}/{user_id}_{share_name}/
@@ -255,7 +283,19 @@ func syncShare(db *sql.DB, backup *USBBackup, share *shares.Share, masterKey str
 	}
 
 	// Load remote manifest from USB
-	remoteManifest, err := loadManifest(destDir)
+	remoteManifest, err := loadManifest(destDir, key)
+	if err != nil && key != masterKey {
+		// Written with the master key before a passphrase was set: re-encrypt every file
+		// with the drive key, but keep the list so deleted files are still removed
+		if legacy, legacyErr := loadManifest(destDir, masterKey); legacyErr == nil {
+			logger.Info("USB backup: re-encrypting share with the drive key", "name", share.Name)
+			for relPath, meta := range legacy.Files {
+				meta.Checksum = ""
+				legacy.Files[relPath] = meta
+			}
+			remoteManifest, err = legacy, nil
+		}
+	}
 	if err != nil {
 		logger.Info("No existing manifest on USB for , full backup needed", "name", share.Name)
 		remoteManifest = &BackupManifest{Files: make(map[string]FileMetadata)}
@@ -267,6 +307,7 @@ func syncShare(db *sql.DB, backup *USBBackup, share *shares.Share, masterKey str
 	logger.Info("Share sync delta", "name", share.Name, "to_add", len(toAdd), "to_update", len(toUpdate), "to_delete", len(toDelete))
 
 	// Copy new and updated files (encrypted)
+	failed := make(map[string]bool)
 	for _, relPath := range append(toAdd, toUpdate...) {
 		srcPath := filepath.Join(share.Path, relPath)
 
@@ -274,11 +315,17 @@ func syncShare(db *sql.DB, backup *USBBackup, share *shares.Share, masterKey str
 		encName := generateEncryptedName(relPath)
 		destPath := filepath.Join(destDir, encName)
 
-		bytesCopied, err := copyFileEncrypted(srcPath, destPath, masterKey)
+		bytesCopied, checksum, err := writeFile(srcPath, destPath, key, backup.VerifyWrites)
 		if err != nil {
 			result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", relPath, err))
+			failed[relPath] = true
 			continue
 		}
+		// Record what was actually written, in case the file changed since the scan
+		if meta := localManifest.Files[relPath]; meta.Checksum != checksum {
+			meta.Checksum = checksum
+			localManifest.Files[relPath] = meta
+		}
 
 		result.BytesSynced += bytesCopied
 		if contains(toAdd, relPath) {
@@ -302,8 +349,13 @@ func syncShare(db *sql.DB, backup *USBBackup, share *shares.Share, masterKey str
 		result.FilesDeleted++
 	}
 
-	// Update manifest with new file info
+	// Update manifest with new file info. Files that could not be written are left
+	// out so that the next sync sends them again.
 	for relPath, meta := range localManifest.Files {
+		if failed[relPath] {
+			delete(remoteManifest.Files, relPath)
+			continue
+		}
 		remoteManifest.Files[relPath] = meta
 	}
 	for _, relPath := range toDelete {
@@ -313,7 +365,7 @@ func syncShare(db *sql.DB, backup *USBBackup, share *shares.Share, masterKey str
 	remoteManifest.SourceServer = serverName
 
 	// Save updated manifest (encrypted)
-	if err := saveManifest(remoteManifest, destDir, masterKey); err != nil {
+	if err := saveManifest(remoteManifest, destDir, key); err != nil {
 		return nil, fmt.Errorf("failed to save manifest: %w", err)
 	}
 
@@ -373,14 +425,23 @@ func buildManifest(sourceDir string, userID int, shareName string, serverName st
 	return manifest, err
 }
 
-// loadManifest loads manifest from USB backup directory
-func loadManifest(destDir string) (*BackupManifest, error) {
-	manifestPath := filepath.Join(destDir, ".anemone-manifest.json")
+// loadManifest loads manifest from USB backup directory.
+// Manifests are encrypted with the drive key; older drives hold plaintext JSON.
+func loadManifest(destDir string, masterKey string) (*BackupManifest, error) {
+	manifestPath := filepath.Join(destDir, manifestFileName)
 	data, err := os.ReadFile(manifestPath)
 	if err != nil {
 		return nil, err
 	}
 
+	if !bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		var plain bytes.Buffer
+		if err := crypto.DecryptStream(bytes.NewReader(data), &plain, masterKey); err != nil {
+			return nil, fmt.Errorf("failed to decrypt manifest: %w", err)
+		}
+		data = plain.Bytes()
+	}
+
 	var manifest BackupManifest
 	if err := json.Unmarshal(data, &manifest); err != nil {
 		return nil, err
@@ -389,15 +450,25 @@ func loadManifest(destDir string) (*BackupManifest, error) {
 	return &manifest, nil
 }
 
-// saveManifest saves manifest to USB backup directory
+// saveManifest saves the manifest to USB backup directory, encrypted with the drive key
 func saveManifest(manifest *BackupManifest, destDir string, masterKey string) error {
 	data, err := json.MarshalIndent(manifest, "", "  ")
 	if err != nil {
 		return err
 	}
 
-	manifestPath := filepath.Join(destDir, ".anemone-manifest.json")
-	return os.WriteFile(manifestPath, data, 0600)
+	var encrypted bytes.Buffer
+	if err := crypto.EncryptStream(bytes.NewReader(data), &encrypted, masterKey); err != nil {
+		return fmt.Errorf("failed to encrypt manifest: %w", err)
+	}
+
+	// Write then rename so an interrupted sync never leaves a truncated manifest
+	manifestPath := filepath.Join(destDir, manifestFileName)
+	tmpPath := manifestPath + ".tmp"
+	if err := os.WriteFile(tmpPath, encrypted.Bytes(), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, manifestPath)
 }
 
 // compareManifests compares local and remote manifests
@@ -422,35 +493,38 @@ func compareManifests(local, remote *BackupManifest) (toAdd, toUpdate, toDelete
 	return
 }
 
-// copyFileEncrypted copies a file with encryption using streaming
-func copyFileEncrypted(src, dest string, masterKey string) (int64, error) {
+// copyFileEncrypted copies a file with encryption using streaming and returns the
+// size written and the checksum of the plaintext read
+func copyFileEncrypted(src, dest string, masterKey string) (int64, string, error) {
 	// Open source file
 	srcFile, err := os.Open(src)
 	if err != nil {
-		return 0, fmt.Errorf("failed to open source: %w", err)
+		return 0, "", fmt.Errorf("failed to open source: %w", err)
 	}
 	defer srcFile.Close()
 
 	// Create destination file
 	destFile, err := os.Create(dest)
 	if err != nil {
-		return 0, fmt.Errorf("failed to create destination: %w", err)
+		return 0, "", fmt.Errorf("failed to create destination: %w", err)
 	}
 	defer destFile.Close()
 
 	// Encrypt using streaming
-	if err := crypto.EncryptStream(srcFile, destFile, masterKey); err != nil {
+	hash := sha256.New()
+	if err := crypto.EncryptStream(io.TeeReader(srcFile, hash), destFile, masterKey); err != nil {
 		os.Remove(dest) // Clean up on error
-		return 0, fmt.Errorf("failed to encrypt: %w", err)
+		return 0, "", fmt.Errorf("failed to encrypt: %w", err)
 	}
+	checksum := hex.EncodeToString(hash.Sum(nil))
 
 	// Get size of encrypted file
 	info, err := destFile.Stat()
 	if err != nil {
-		return 0, nil // File was written, just can't get size
+		return 0, checksum, nil // File was written, just can't get size
 	}
 
-	return info.Size(), nil
+	return info.Size(), checksum, nil
 }
 
 // calculateChecksum calculates SHA256 checksum of a file
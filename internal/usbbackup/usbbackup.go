@@ -47,6 +47,15 @@ type USBBackup struct {
 	SyncDayOfWeek       *int   // 0-6 (0=Sunday) for weekly
 	SyncDayOfMonth      *int   // 1-31 for monthly
 	SyncIntervalMinutes int    // Interval in minutes for interval mode
+
+	// Drive encryption (empty = content encrypted with the master key)
+	DataKeyEncrypted string // Drive data key, encrypted with the master key
+	KeyHeader        string // JSON header written on the drive (data key wrapped with the passphrase)
+
+	// Rotation set: drives sharing this definition are listed in usb_backup_drives
+	RPOHours int // Warn when a drive of the set was last synced longer ago (0 = disabled)
+
+	VerifyWrites bool // Read back and authenticate each file after writing it
 }
 
 // DriveInfo represents detected USB/external drive information
@@ -79,14 +88,14 @@ func Create(db *sql.DB, backup *USBBackup) error {
 
 	query := `INSERT INTO usb_backups (name, mount_path, backup_path, backup_type, selected_shares,
 	          enabled, auto_detect, sync_enabled, sync_frequency, sync_time,
-	          sync_day_of_week, sync_day_of_month, sync_interval_minutes,
+	          sync_day_of_week, sync_day_of_month, sync_interval_minutes, rpo_hours, verify_writes,
 	          last_status, created_at, updated_at)
-	          VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 'unknown', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)`
+	          VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 'unknown', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)`
 
 	result, err := db.Exec(query, backup.Name, backup.MountPath, backup.BackupPath,
 		backup.BackupType, backup.SelectedShares, backup.Enabled, backup.AutoDetect,
 		backup.SyncEnabled, backup.SyncFrequency, backup.SyncTime,
-		backup.SyncDayOfWeek, backup.SyncDayOfMonth, backup.SyncIntervalMinutes)
+		backup.SyncDayOfWeek, backup.SyncDayOfMonth, backup.SyncIntervalMinutes, backup.RPOHours, backup.VerifyWrites)
 	if err != nil {
 		return fmt.Errorf("failed to create USB backup: %w", err)
 	}
@@ -106,18 +115,18 @@ func GetByID(db *sql.DB, id int) (*USBBackup, error) {
 	query := `SELECT id, name, mount_path, backup_path, backup_type, selected_shares,
 	          enabled, auto_detect, last_sync, last_status, last_error, files_synced, bytes_synced,
 	          sync_enabled, sync_frequency, sync_time, sync_day_of_week, sync_day_of_month, sync_interval_minutes,
-	          created_at, updated_at
+	          data_key_encrypted, key_header, rpo_hours, verify_writes, created_at, updated_at
 	          FROM usb_backups WHERE id = ?`
 
-	var backupType, selectedShares, syncFrequency, syncTime sql.NullString
-	var syncDayOfWeek, syncDayOfMonth, syncIntervalMinutes sql.NullInt64
+	var backupType, selectedShares, syncFrequency, syncTime, dataKey, keyHeader sql.NullString
+	var syncDayOfWeek, syncDayOfMonth, syncIntervalMinutes, rpoHours sql.NullInt64
 	err := db.QueryRow(query, id).Scan(
 		&backup.ID, &backup.Name, &backup.MountPath, &backup.BackupPath,
 		&backupType, &selectedShares,
 		&backup.Enabled, &backup.AutoDetect, &backup.LastSync, &backup.LastStatus,
 		&backup.LastError, &backup.FilesSynced, &backup.BytesSynced,
 		&backup.SyncEnabled, &syncFrequency, &syncTime, &syncDayOfWeek, &syncDayOfMonth, &syncIntervalMinutes,
-		&backup.CreatedAt, &backup.UpdatedAt,
+		&dataKey, &keyHeader, &rpoHours, &backup.VerifyWrites, &backup.CreatedAt, &backup.UpdatedAt,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -156,6 +165,9 @@ func GetByID(db *sql.DB, id int) (*USBBackup, error) {
 	if syncIntervalMinutes.Valid {
 		backup.SyncIntervalMinutes = int(syncIntervalMinutes.Int64)
 	}
+	backup.DataKeyEncrypted = dataKey.String
+	backup.KeyHeader = keyHeader.String
+	backup.RPOHours = int(rpoHours.Int64)
 
 	return backup, nil
 }
@@ -165,7 +177,7 @@ func GetAll(db *sql.DB) ([]*USBBackup, error) {
 	query := `SELECT id, name, mount_path, backup_path, backup_type, selected_shares,
 	          enabled, auto_detect, last_sync, last_status, last_error, files_synced, bytes_synced,
 	          sync_enabled, sync_frequency, sync_time, sync_day_of_week, sync_day_of_month, sync_interval_minutes,
-	          created_at, updated_at
+	          data_key_encrypted, key_header, rpo_hours, verify_writes, created_at, updated_at
 	          FROM usb_backups ORDER BY created_at DESC`
 
 	rows, err := db.Query(query)
@@ -177,15 +189,15 @@ func GetAll(db *sql.DB) ([]*USBBackup, error) {
 	var backups []*USBBackup
 	for rows.Next() {
 		backup := &USBBackup{}
-		var backupType, selectedShares, syncFrequency, syncTime sql.NullString
-		var syncDayOfWeek, syncDayOfMonth, syncIntervalMinutes sql.NullInt64
+		var backupType, selectedShares, syncFrequency, syncTime, dataKey, keyHeader sql.NullString
+		var syncDayOfWeek, syncDayOfMonth, syncIntervalMinutes, rpoHours sql.NullInt64
 		err := rows.Scan(
 			&backup.ID, &backup.Name, &backup.MountPath, &backup.BackupPath,
 			&backupType, &selectedShares,
 			&backup.Enabled, &backup.AutoDetect, &backup.LastSync, &backup.LastStatus,
 			&backup.LastError, &backup.FilesSynced, &backup.BytesSynced,
 			&backup.SyncEnabled, &syncFrequency, &syncTime, &syncDayOfWeek, &syncDayOfMonth, &syncIntervalMinutes,
-			&backup.CreatedAt, &backup.UpdatedAt,
+			&dataKey, &keyHeader, &rpoHours, &backup.VerifyWrites, &backup.CreatedAt, &backup.UpdatedAt,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan USB backup: %w", err)
@@ -221,6 +233,9 @@ func GetAll(db *sql.DB) ([]*USBBackup, error) {
 		if syncIntervalMinutes.Valid {
 			backup.SyncIntervalMinutes = int(syncIntervalMinutes.Int64)
 		}
+		backup.DataKeyEncrypted = dataKey.String
+		backup.KeyHeader = keyHeader.String
+		backup.RPOHours = int(rpoHours.Int64)
 
 		backups = append(backups, backup)
 	}
@@ -233,7 +248,7 @@ func GetEnabled(db *sql.DB) ([]*USBBackup, error) {
 	query := `SELECT id, name, mount_path, backup_path, backup_type, selected_shares,
 	          enabled, auto_detect, last_sync, last_status, last_error, files_synced, bytes_synced,
 	          sync_enabled, sync_frequency, sync_time, sync_day_of_week, sync_day_of_month, sync_interval_minutes,
-	          created_at, updated_at
+	          data_key_encrypted, key_header, rpo_hours, verify_writes, created_at, updated_at
 	          FROM usb_backups WHERE enabled = 1 ORDER BY created_at DESC`
 
 	rows, err := db.Query(query)
@@ -245,15 +260,15 @@ func GetEnabled(db *sql.DB) ([]*USBBackup, error) {
 	var backups []*USBBackup
 	for rows.Next() {
 		backup := &USBBackup{}
-		var backupType, selectedShares, syncFrequency, syncTime sql.NullString
-		var syncDayOfWeek, syncDayOfMonth, syncIntervalMinutes sql.NullInt64
+		var backupType, selectedShares, syncFrequency, syncTime, dataKey, keyHeader sql.NullString
+		var syncDayOfWeek, syncDayOfMonth, syncIntervalMinutes, rpoHours sql.NullInt64
 		err := rows.Scan(
 			&backup.ID, &backup.Name, &backup.MountPath, &backup.BackupPath,
 			&backupType, &selectedShares,
 			&backup.Enabled, &backup.AutoDetect, &backup.LastSync, &backup.LastStatus,
 			&backup.LastError, &backup.FilesSynced, &backup.BytesSynced,
 			&backup.SyncEnabled, &syncFrequency, &syncTime, &syncDayOfWeek, &syncDayOfMonth, &syncIntervalMinutes,
-			&backup.CreatedAt, &backup.UpdatedAt,
+			&dataKey, &keyHeader, &rpoHours, &backup.VerifyWrites, &backup.CreatedAt, &backup.UpdatedAt,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan USB backup: %w", err)
@@ -289,6 +304,9 @@ func GetEnabled(db *sql.DB) ([]*USBBackup, error) {
 		if syncIntervalMinutes.Valid {
 			backup.SyncIntervalMinutes = int(syncIntervalMinutes.Int64)
 		}
+		backup.DataKeyEncrypted = dataKey.String
+		backup.KeyHeader = keyHeader.String
+		backup.RPOHours = int(rpoHours.Int64)
 
 		backups = append(backups, backup)
 	}
@@ -303,7 +321,7 @@ func Update(db *sql.DB, backup *USBBackup) error {
 	          enabled = ?, auto_detect = ?,
 	          sync_enabled = ?, sync_frequency = ?, sync_time = ?,
 	          sync_day_of_week = ?, sync_day_of_month = ?, sync_interval_minutes = ?,
-	          updated_at = CURRENT_TIMESTAMP
+	          rpo_hours = ?, verify_writes = ?, updated_at = CURRENT_TIMESTAMP
 	          WHERE id = ?`
 
 	_, err := db.Exec(query, backup.Name, backup.MountPath, backup.BackupPath,
@@ -311,7 +329,7 @@ func Update(db *sql.DB, backup *USBBackup) error {
 		backup.Enabled, backup.AutoDetect,
 		backup.SyncEnabled, backup.SyncFrequency, backup.SyncTime,
 		backup.SyncDayOfWeek, backup.SyncDayOfMonth, backup.SyncIntervalMinutes,
-		backup.ID)
+		backup.RPOHours, backup.VerifyWrites, backup.ID)
 	if err != nil {
 		return fmt.Errorf("failed to update USB backup: %w", err)
 	}
@@ -0,0 +1,240 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+// This file checks backed up files on the drive: each file is read back from the
+// media, authenticated and compared with the checksum recorded in the manifest.
+
+package usbbackup
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/juste-un-gars/anemone/internal/crypto"
+	"github.com/juste-un-gars/anemone/internal/logger"
+	"github.com/juste-un-gars/anemone/internal/shares"
+	"github.com/juste-un-gars/anemone/internal/sync"
+)
+
+// VerifyFailure is a backed up file that failed an integrity check
+type VerifyFailure struct {
+	Share string `json:"share"` // Share directory on the drive ({user_id}_{share_name})
+	Path  string `json:"path"`  // Path relative to the share
+	Error string `json:"error"`
+}
+
+// verifyFile reads an encrypted file back from the drive, authenticates every chunk and,
+// when checksum is set, compares the decrypted content with it
+func verifyFile(path, key, checksum string) error {
+	f, err := sync.OpenUncached(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("missing on the drive")
+		}
+		return err
+	}
+	defer f.Close()
+
+	sum, err := crypto.VerifyStream(f, key)
+	if err != nil {
+		return fmt.Errorf("unreadable or altered: %w", err)
+	}
+	if checksum != "" && sum != checksum {
+		return fmt.Errorf("content differs from the backed up file")
+	}
+	return nil
+}
+
+// writeFile encrypts src to dest and returns the size written and the plaintext
+// checksum. With verify, the file is read back and written once more if it fails.
+func writeFile(src, dest, key string, verify bool) (int64, string, error) {
+	size, checksum, err := copyFileEncrypted(src, dest, key)
+	if err != nil || !verify {
+		return size, checksum, err
+	}
+	err = verifyFile(dest, key, checksum)
+	if err == nil {
+		return size, checksum, nil
+	}
+	logger.Warn("USB backup: written file failed verification, writing it again", "file", src, "error", err)
+
+	size, checksum, err = copyFileEncrypted(src, dest, key)
+	if err != nil {
+		return 0, "", err
+	}
+	if err := verifyFile(dest, key, checksum); err != nil {
+		os.Remove(dest)
+		return 0, "", fmt.Errorf("verification failed after writing twice: %w", err)
+	}
+	return size, checksum, nil
+}
+
+// shareDir is a share directory of the drive with its manifest
+type shareDir struct {
+	name     string
+	path     string
+	manifest *BackupManifest
+	key      string // Key the files of the directory are encrypted with
+}
+
+// shareDirs loads the manifest of every share directory on the drive
+func shareDirs(backup *USBBackup, key, masterKey string) ([]*shareDir, error) {
+	root := backup.GetFullBackupPath()
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup directory: %w", err)
+	}
+
+	var dirs []*shareDir
+	for _, entry := range entries {
+		if !entry.IsDir() || entry.Name() == "config" {
+			continue
+		}
+		dir := &shareDir{name: entry.Name(), path: filepath.Join(root, entry.Name()), key: key}
+		if _, err := os.Stat(filepath.Join(dir.path, manifestFileName)); err != nil {
+			continue
+		}
+		dir.manifest, err = loadManifest(dir.path, key)
+		if err != nil && key != masterKey {
+			// Not yet re-encrypted with the drive key
+			dir.manifest, err = loadManifest(dir.path, masterKey)
+			dir.key = masterKey
+		}
+		if err != nil {
+			return nil, fmt.Errorf("share %s: %w", entry.Name(), err)
+		}
+		dirs = append(dirs, dir)
+	}
+	return dirs, nil
+}
+
+// openDrive checks the drive mounted for a backup and returns its key
+func openDrive(db *sql.DB, backup *USBBackup, masterKey, serverName string) (string, error) {
+	if !backup.IsMounted() {
+		return "", fmt.Errorf("backup drive not mounted: %s", backup.MountPath)
+	}
+	if _, err := checkDrive(db, backup); err != nil {
+		return "", err
+	}
+	return driveKey(db, backup, masterKey, GetServerIdentity(db, serverName))
+}
+
+// Verify checks the shares backed up on the drive. With samplePercent below 100, a
+// random sample of each share is checked. The configuration is not checked: it is
+// written again in full by every backup.
+func Verify(db *sql.DB, backup *USBBackup, masterKey, serverName string, samplePercent int) (int, []VerifyFailure, error) {
+	if !tryStart(backup.ID) {
+		return 0, nil, ErrSyncInProgress
+	}
+	defer finish(backup.ID)
+
+	key, err := openDrive(db, backup, masterKey, serverName)
+	if err != nil {
+		return 0, nil, err
+	}
+	dirs, err := shareDirs(backup, key, masterKey)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	checked := 0
+	var failures []VerifyFailure
+	for _, dir := range dirs {
+		paths := make([]string, 0, len(dir.manifest.Files))
+		for relPath := range dir.manifest.Files {
+			paths = append(paths, relPath)
+		}
+		sort.Strings(paths)
+
+		for _, relPath := range sync.SampleFiles(paths, samplePercent) {
+			meta := dir.manifest.Files[relPath]
+			checked++
+			if err := verifyFile(filepath.Join(dir.path, meta.EncryptedName), dir.key, meta.Checksum); err != nil {
+				failures = append(failures, VerifyFailure{Share: dir.name, Path: relPath, Error: err.Error()})
+			}
+		}
+	}
+
+	logger.Info("USB integrity check completed", "name", backup.Name, "checked", checked, "failed", len(failures))
+	return checked, failures, nil
+}
+
+// RepairFiles writes the files that failed a check to the drive again, from the
+// current content of their share, and returns the failures that could not be repaired
+func RepairFiles(db *sql.DB, backup *USBBackup, masterKey, serverName string, failures []VerifyFailure) (int, []VerifyFailure, error) {
+	if !tryStart(backup.ID) {
+		return 0, failures, ErrSyncInProgress
+	}
+	defer finish(backup.ID)
+
+	key, err := openDrive(db, backup, masterKey, serverName)
+	if err != nil {
+		return 0, failures, err
+	}
+	dirs, err := shareDirs(backup, key, masterKey)
+	if err != nil {
+		return 0, failures, err
+	}
+	byName := make(map[string]*shareDir, len(dirs))
+	for _, dir := range dirs {
+		byName[dir.name] = dir
+	}
+
+	allShares, err := shares.GetAll(db)
+	if err != nil {
+		return 0, failures, fmt.Errorf("failed to get shares: %w", err)
+	}
+	sharePaths := make(map[string]string, len(allShares))
+	for _, share := range allShares {
+		sharePaths[fmt.Sprintf("%d_%s", share.UserID, share.Name)] = share.Path
+	}
+
+	repaired := 0
+	var remaining []VerifyFailure
+	changed := make(map[string]bool)
+	for _, f := range failures {
+		dir, sharePath := byName[f.Share], sharePaths[f.Share]
+		switch {
+		case dir == nil || sharePath == "":
+			f.Error = "share no longer exists"
+		case dir.key != key:
+			f.Error = "run a backup first to re-encrypt this share with the drive key"
+		default:
+			src := filepath.Join(sharePath, f.Path)
+			info, statErr := os.Stat(src)
+			if statErr != nil {
+				f.Error = "file no longer exists in the share"
+				break
+			}
+			encName := generateEncryptedName(f.Path)
+			_, checksum, writeErr := writeFile(src, filepath.Join(dir.path, encName), key, true)
+			if writeErr != nil {
+				f.Error = writeErr.Error()
+				break
+			}
+			dir.manifest.Files[f.Path] = FileMetadata{
+				Size:          info.Size(),
+				ModTime:       info.ModTime(),
+				Checksum:      checksum,
+				EncryptedName: encName,
+			}
+			changed[dir.name] = true
+			repaired++
+			continue
+		}
+		remaining = append(remaining, f)
+	}
+
+	for name := range changed {
+		if err := saveManifest(byName[name].manifest, byName[name].path, key); err != nil {
+			return repaired, remaining, fmt.Errorf("share %s: failed to save manifest: %w", name, err)
+		}
+	}
+
+	logger.Info("USB backup files repaired", "name", backup.Name, "repaired", repaired, "remaining", len(remaining))
+	return repaired, remaining, nil
+}
@@ -0,0 +1,54 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+package usbbackup
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/juste-un-gars/anemone/internal/crypto"
+)
+
+func TestWriteFileVerified(t *testing.T) {
+	key, _ := crypto.GenerateEncryptionKey()
+	dir := t.TempDir()
+	src := filepath.Join(dir, "report.txt")
+	dest := filepath.Join(dir, "report.enc")
+	if err := os.WriteFile(src, []byte("quarterly report"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	_, checksum, err := writeFile(src, dest, key, true)
+	if err != nil {
+		t.Fatalf("writeFile failed: %v", err)
+	}
+	expected, _ := calculateChecksum(src)
+	if checksum != expected {
+		t.Errorf("writeFile checksum = %s, expected %s", checksum, expected)
+	}
+	if err := verifyFile(dest, key, checksum); err != nil {
+		t.Errorf("verifyFile failed on a good file: %v", err)
+	}
+
+	// Flip one byte of the ciphertext: authentication must fail
+	data, _ := os.ReadFile(dest)
+	data[len(data)-1] ^= 0xff
+	os.WriteFile(dest, data, 0644)
+	if err := verifyFile(dest, key, checksum); err == nil {
+		t.Error("verifyFile accepted a corrupted file")
+	}
+
+	// Intact file, but not the one recorded in the manifest
+	writeFile(src, dest, key, false)
+	if err := verifyFile(dest, key, "0000"); err == nil {
+		t.Error("verifyFile accepted a file with another checksum")
+	}
+
+	os.Remove(dest)
+	if err := verifyFile(dest, key, checksum); err == nil {
+		t.Error("verifyFile accepted a missing file")
+	}
+}
@@ -0,0 +1,133 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+// This file contains the app passwords, used by WebDAV clients instead of the
+// account password.
+
+package users
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/juste-un-gars/anemone/internal/crypto"
+)
+
+// appPasswordAlphabet has no ambiguous characters (0/O, 1/l/I), since app
+// passwords are often typed on a phone
+const appPasswordAlphabet = "abcdefghijkmnopqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// appPasswordLength gives about 140 bits of entropy
+const appPasswordLength = 24
+
+// maxAppPasswordName is the maximum length of the name of an app password
+const maxAppPasswordName = 64
+
+// AppPassword is a password generated for a client (phone, rclone...), which
+// can be revoked without changing the account password
+type AppPassword struct {
+	ID         int
+	UserID     int
+	Name       string
+	CreatedAt  time.Time
+	LastUsedAt *time.Time
+}
+
+// generateAppPassword returns a random password, grouped by 4 characters
+func generateAppPassword() (string, error) {
+	buf := make([]byte, appPasswordLength)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate app password: %w", err)
+	}
+	var b strings.Builder
+	for i, c := range buf {
+		if i > 0 && i%4 == 0 {
+			b.WriteByte('-')
+		}
+		// 256 is not a multiple of the alphabet size, the bias is negligible
+		// for a password of this length
+		b.WriteByte(appPasswordAlphabet[int(c)%len(appPasswordAlphabet)])
+	}
+	return b.String(), nil
+}
+
+// CreateAppPassword creates an app password for a user. The password is
+// returned in clear only once; only its hash is stored.
+func CreateAppPassword(db *sql.DB, userID int, name string) (*AppPassword, string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, "", fmt.Errorf("name is required")
+	}
+	if len(name) > maxAppPasswordName {
+		return nil, "", fmt.Errorf("name must be at most %d characters", maxAppPasswordName)
+	}
+
+	password, err := generateAppPassword()
+	if err != nil {
+		return nil, "", err
+	}
+
+	// The password is random and long, a SHA-256 hash is enough and lets us
+	// find it without comparing against each bcrypt hash of the user
+	now := time.Now()
+	result, err := db.Exec(`INSERT INTO app_passwords (user_id, name, password_hash, created_at) VALUES (?, ?, ?, ?)`,
+		userID, name, crypto.HashKey(password), now)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create app password: %w", err)
+	}
+	id, _ := result.LastInsertId()
+
+	return &AppPassword{ID: int(id), UserID: userID, Name: name, CreatedAt: now}, password, nil
+}
+
+// GetAppPasswords lists the app passwords of a user, newest first
+func GetAppPasswords(db *sql.DB, userID int) ([]*AppPassword, error) {
+	rows, err := db.Query(`SELECT id, user_id, name, created_at, last_used_at FROM app_passwords
+		WHERE user_id = ? ORDER BY created_at DESC, id DESC`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query app passwords: %w", err)
+	}
+	defer rows.Close()
+
+	var passwords []*AppPassword
+	for rows.Next() {
+		p := &AppPassword{}
+		var lastUsed sql.NullTime
+		if err := rows.Scan(&p.ID, &p.UserID, &p.Name, &p.CreatedAt, &lastUsed); err != nil {
+			return nil, fmt.Errorf("failed to scan app password: %w", err)
+		}
+		if lastUsed.Valid {
+			p.LastUsedAt = &lastUsed.Time
+		}
+		passwords = append(passwords, p)
+	}
+	return passwords, rows.Err()
+}
+
+// DeleteAppPassword revokes an app password of a user
+func DeleteAppPassword(db *sql.DB, userID, id int) error {
+	result, err := db.Exec(`DELETE FROM app_passwords WHERE id = ? AND user_id = ?`, id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete app password: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("app password not found")
+	}
+	return nil
+}
+
+// CheckAppPassword checks an app password of a user and records its use
+func CheckAppPassword(db *sql.DB, userID int, password string) bool {
+	var id int
+	err := db.QueryRow(`SELECT id FROM app_passwords WHERE user_id = ? AND password_hash = ?`,
+		userID, crypto.HashKey(password)).Scan(&id)
+	if err != nil {
+		return false
+	}
+	db.Exec(`UPDATE app_passwords SET last_used_at = ? WHERE id = ?`, time.Now(), id)
+	return true
+}
@@ -0,0 +1,84 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+package users
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestAppPasswords(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:?_foreign_keys=on")
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	defer db.Close()
+	_, err = db.Exec(`
+		CREATE TABLE users (id INTEGER PRIMARY KEY, username TEXT);
+		CREATE TABLE app_passwords (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			name TEXT NOT NULL,
+			password_hash TEXT NOT NULL UNIQUE,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			last_used_at DATETIME,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		);
+		INSERT INTO users VALUES (1, 'alice'), (2, 'bob');`)
+	if err != nil {
+		t.Fatalf("Failed to create tables: %v", err)
+	}
+
+	if _, _, err := CreateAppPassword(db, 1, "  "); err == nil {
+		t.Error("CreateAppPassword accepted an empty name")
+	}
+	if _, _, err := CreateAppPassword(db, 1, strings.Repeat("a", 65)); err == nil {
+		t.Error("CreateAppPassword accepted a name too long")
+	}
+
+	phone, password, err := CreateAppPassword(db, 1, "Phone")
+	if err != nil {
+		t.Fatalf("CreateAppPassword failed: %v", err)
+	}
+	if len(password) != appPasswordLength+appPasswordLength/4-1 {
+		t.Errorf("unexpected app password %q", password)
+	}
+	if _, other, err := CreateAppPassword(db, 1, "rclone"); err != nil || other == password {
+		t.Fatalf("second CreateAppPassword = %q, %v", other, err)
+	}
+
+	if !CheckAppPassword(db, 1, password) {
+		t.Error("CheckAppPassword refused a valid app password")
+	}
+	if CheckAppPassword(db, 2, password) {
+		t.Error("CheckAppPassword accepted the app password of another user")
+	}
+	if CheckAppPassword(db, 1, password+"x") {
+		t.Error("CheckAppPassword accepted a wrong password")
+	}
+
+	list, err := GetAppPasswords(db, 1)
+	if err != nil || len(list) != 2 {
+		t.Fatalf("GetAppPasswords = %+v, %v", list, err)
+	}
+	for _, p := range list {
+		if p.ID == phone.ID && p.LastUsedAt == nil {
+			t.Error("last use of the app password not recorded")
+		}
+	}
+
+	if err := DeleteAppPassword(db, 2, phone.ID); err == nil {
+		t.Error("DeleteAppPassword revoked the app password of another user")
+	}
+	if err := DeleteAppPassword(db, 1, phone.ID); err != nil {
+		t.Fatalf("DeleteAppPassword failed: %v", err)
+	}
+	if CheckAppPassword(db, 1, password) {
+		t.Error("CheckAppPassword accepted a revoked app password")
+	}
+}
@@ -20,6 +20,7 @@ import (
 
 	"github.com/juste-un-gars/anemone/internal/btrfs"
 	"github.com/juste-un-gars/anemone/internal/crypto"
+	"github.com/juste-un-gars/anemone/internal/nfs"
 	"github.com/juste-un-gars/anemone/internal/smb"
 )
 
@@ -386,6 +387,10 @@ func GetAllUsers(db *sql.DB) ([]*User, error) {
 func removeShareDirectory(path string) error {
 	// Check if it's a Btrfs subvolume
 	if btrfs.IsSubvolume(path) {
+		// Read-only snapshots would prevent removing the user directory
+		if err := btrfs.DeleteAllSnapshots(path); err != nil {
+			return fmt.Errorf("failed to delete snapshots: %w", err)
+		}
 		// Use btrfs subvolume delete for proper cleanup
 		if output, err := btrfs.DeleteSubvolume(path); err != nil {
 			return fmt.Errorf("failed to delete subvolume: %w\nOutput: %s", err, output)
@@ -448,6 +453,12 @@ func DeleteUser(db *sql.DB, userID int, dataDir, sharesDir string) error {
 		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
+	// Stop exporting the user's shares over NFS before deleting them
+	nfsCfg := &nfs.Config{ConfigPath: filepath.Join(dataDir, "nfs", "anemone.exports")}
+	if err := nfs.GenerateConfig(db, nfsCfg); err != nil {
+		fmt.Printf("Warning: failed to regenerate NFS exports: %v\n", err)
+	}
+
 	// Delete files from disk for each share (handles Btrfs subvolumes properly)
 	for _, share := range shares {
 		if err := removeShareDirectory(share.Path); err != nil {
@@ -0,0 +1,59 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+// This file contains the handler of the alerts raised by the monitors.
+
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/juste-un-gars/anemone/internal/alerts"
+	"github.com/juste-un-gars/anemone/internal/auth"
+	"github.com/juste-un-gars/anemone/internal/logger"
+)
+
+// handleAdminAlerts lists the recent alerts of a monitor (GET ?source=smart&pending=1)
+// and acknowledges one (POST ?id=N), or all those of a source without id
+// (POST ?source=smart). Without source, all monitors are concerned.
+func (s *Server) handleAdminAlerts(w http.ResponseWriter, r *http.Request) {
+	if _, ok := auth.GetSessionFromContext(r); !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	source := r.URL.Query().Get("source")
+
+	switch r.Method {
+	case http.MethodGet:
+		list, err := alerts.List(s.db, source, r.URL.Query().Get("pending") == "1", 100)
+		if err != nil {
+			logger.Info("Error listing alerts", "source", source, "error", err)
+			storageJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(list)
+
+	case http.MethodPost:
+		id := 0
+		if v := r.URL.Query().Get("id"); v != "" {
+			var err error
+			if id, err = strconv.Atoi(v); err != nil || id <= 0 {
+				storageJSONError(w, http.StatusBadRequest, "Invalid alert ID")
+				return
+			}
+		}
+		if err := alerts.Acknowledge(s.db, source, id); err != nil {
+			storageJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true})
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -293,6 +293,47 @@ func (s *Server) handleAdminBackupDelete(w http.ResponseWriter, r *http.Request)
 	w.Write([]byte("Backup deleted successfully"))
 }
 
+// handleAdminBackupRecovery sets the recovery passphrase of the offsite copies of server
+// backups, then creates a backup so that the peers receive a copy right away
+func (s *Server) handleAdminBackupRecovery(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	session, _ := auth.GetSessionFromContext(r)
+	lang := s.getLang(r)
+
+	passphrase := r.FormValue("passphrase")
+	if len(passphrase) < serverbackup.MinPassphraseLength {
+		http.Redirect(w, r, "/admin/backups?tab=server&error="+i18n.T(lang, "server_backup.recovery.too_short"), http.StatusSeeOther)
+		return
+	}
+	if passphrase != r.FormValue("passphrase_confirm") {
+		http.Redirect(w, r, "/admin/backups?tab=server&error="+i18n.T(lang, "server_backup.recovery.mismatch"), http.StatusSeeOther)
+		return
+	}
+
+	masterKey, err := s.getMasterKey()
+	if err != nil {
+		logger.Info("Error getting master key", "error", err)
+		http.Redirect(w, r, "/admin/backups?tab=server&error=internal_error", http.StatusSeeOther)
+		return
+	}
+	if err := serverbackup.SetRecoveryPassphrase(s.db, masterKey, passphrase); err != nil {
+		logger.Info("Error setting server backup recovery passphrase", "error", err)
+		http.Redirect(w, r, "/admin/backups?tab=server&error=internal_error", http.StatusSeeOther)
+		return
+	}
+	logger.Info("Admin set server backup recovery passphrase", "username", session.Username)
+
+	backupDir := filepath.Join(s.cfg.DataDir, "backups", "server")
+	if _, err := serverbackup.CreateServerBackup(s.db, backupDir); err != nil {
+		logger.Info("Error creating server backup", "error", err)
+	}
+
+	http.Redirect(w, r, "/admin/backups?tab=server&recovery_saved=1", http.StatusSeeOther)
+}
+
 // handleRestoreWarning displays the restore warning page
 
 func (s *Server) handleAdminRestoreUsers(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,416 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+// This file contains the handlers of the groups: the admin page, the groups,
+// their members, their shares and the access of the members to the shares.
+
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"strconv"
+
+	"github.com/juste-un-gars/anemone/internal/auth"
+	"github.com/juste-un-gars/anemone/internal/groups"
+	"github.com/juste-un-gars/anemone/internal/i18n"
+	"github.com/juste-un-gars/anemone/internal/logger"
+	"github.com/juste-un-gars/anemone/internal/quota"
+	"github.com/juste-un-gars/anemone/internal/smb"
+	"github.com/juste-un-gars/anemone/internal/users"
+)
+
+// groupShareView is a group share with the access of each member
+type groupShareView struct {
+	*groups.Share
+	Access []groups.MemberAccess
+}
+
+// groupView is a group with its members and shares, as shown on the admin page
+type groupView struct {
+	*groups.Group
+	Members []groups.Member
+	Shares  []groupShareView
+}
+
+// handleAdminGroups displays the groups, their members and their shares
+func (s *Server) handleAdminGroups(w http.ResponseWriter, r *http.Request) {
+	session, ok := auth.GetSessionFromContext(r)
+	if !ok {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	lang := s.getLang(r)
+
+	allGroups, err := groups.GetAll(s.db)
+	if err != nil {
+		logger.Info("Error getting groups", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	var views []groupView
+	for _, g := range allGroups {
+		view := groupView{Group: g}
+		if view.Members, err = groups.GetMembers(s.db, g.ID); err != nil {
+			logger.Info("Error getting group members", "group", g.Name, "error", err)
+		}
+		groupShares, err := groups.GetShares(s.db, g.ID)
+		if err != nil {
+			logger.Info("Error getting group shares", "group", g.Name, "error", err)
+		}
+		for _, gs := range groupShares {
+			access, err := groups.GetShareAccess(s.db, gs)
+			if err != nil {
+				logger.Info("Error getting group share access", "share", gs.Name, "error", err)
+			}
+			view.Shares = append(view.Shares, groupShareView{Share: gs, Access: access})
+		}
+		views = append(views, view)
+	}
+
+	allUsers, err := users.GetAllUsers(s.db)
+	if err != nil {
+		logger.Info("Error getting users", "error", err)
+	}
+
+	data := struct {
+		V2TemplateData
+		Groups []groupView
+		Users  []*users.User
+	}{
+		V2TemplateData: V2TemplateData{
+			Lang:       lang,
+			Title:      i18n.T(lang, "groups.title"),
+			ActivePage: "groups",
+			Session:    session,
+		},
+		Groups: views,
+		Users:  allUsers,
+	}
+
+	tmpl := s.loadV2Page("v2_groups.html", s.funcMap)
+	if err := tmpl.ExecuteTemplate(w, "v2_base", data); err != nil {
+		logger.Info("Error rendering groups template", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// groupQuotaManager returns the quota manager of the shares directory
+func (s *Server) groupQuotaManager() (groups.QuotaManager, error) {
+	return quota.NewQuotaManager(s.cfg.SharesDir)
+}
+
+// reloadGroupShares regenerates smb.conf and reloads Samba after a change to
+// the groups or their shares
+func (s *Server) reloadGroupShares() {
+	smbCfg := &smb.Config{
+		ConfigPath: filepath.Join(s.cfg.DataDir, "smb", "smb.conf"),
+		WorkGroup:  "ANEMONE",
+		ServerName: "Anemone NAS",
+		SharesDir:  s.cfg.SharesDir,
+		DfreePath:  "/usr/local/bin/anemone-dfree-wrapper.sh",
+	}
+	if err := smb.GenerateConfig(s.db, smbCfg); err != nil {
+		logger.Info("Warning: Failed to regenerate SMB config", "error", err)
+		return
+	}
+	if err := smb.ReloadConfig(); err != nil {
+		logger.Info("Warning: Could not reload smbd automatically. Run: sudo systemctl reload smbd")
+	}
+}
+
+// handleAdminGroupsCreate creates a group (POST /api/admin/groups)
+func (s *Server) handleAdminGroupsCreate(w http.ResponseWriter, r *http.Request) {
+	session, ok := auth.GetSessionFromContext(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+		QuotaGB     int    `json:"quota_gb"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		storageJSONError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+
+	var masterKey string
+	if err := s.db.QueryRow("SELECT value FROM system_config WHERE key = 'master_key'").Scan(&masterKey); err != nil {
+		logger.Info("Error getting master key", "error", err)
+		storageJSONError(w, http.StatusInternalServerError, "System configuration error")
+		return
+	}
+
+	qm, err := s.groupQuotaManager()
+	if err != nil {
+		storageJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	g, err := groups.Create(s.db, masterKey, req.Name, req.Description, req.QuotaGB)
+	if err != nil {
+		storageJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := groups.SetupGroup(qm, s.cfg.SharesDir, g); err != nil {
+		logger.Info("Error setting up group", "group", g.Name, "error", err)
+		groups.Delete(s.db, g.ID)
+		storageJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	logger.Info("Admin created group", "username", session.Username, "group", g.Name, "quota_gb", g.QuotaGB)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"id":      g.ID,
+	})
+}
+
+// handleAdminGroupsActions handles the routes of a group:
+//
+//	PUT    /api/admin/groups/{id}                  update the description and quota
+//	DELETE /api/admin/groups/{id}                  delete the group and its shares
+//	POST   /api/admin/groups/{id}/members          add a member
+//	DELETE /api/admin/groups/{id}/members/{userID} remove a member
+//	POST   /api/admin/groups/{id}/shares           create a share
+func (s *Server) handleAdminGroupsActions(w http.ResponseWriter, r *http.Request) {
+	session, ok := auth.GetSessionFromContext(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	parts := splitPath(r.URL.Path)
+	if len(parts) < 4 {
+		http.Error(w, "Invalid path", http.StatusBadRequest)
+		return
+	}
+	groupID, err := strconv.Atoi(parts[3])
+	if err != nil {
+		http.Error(w, "Invalid group ID", http.StatusBadRequest)
+		return
+	}
+	g, err := groups.GetByID(s.db, groupID)
+	if err != nil {
+		storageJSONError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	qm, err := s.groupQuotaManager()
+	if err != nil {
+		storageJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	switch {
+	case len(parts) == 4 && r.Method == http.MethodPut:
+		var req struct {
+			Description string `json:"description"`
+			QuotaGB     int    `json:"quota_gb"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			storageJSONError(w, http.StatusBadRequest, "Invalid request")
+			return
+		}
+		g.Description, g.QuotaGB = req.Description, req.QuotaGB
+		if err := groups.Update(s.db, g); err != nil {
+			storageJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err := groups.UpdateQuota(qm, s.cfg.SharesDir, g); err != nil {
+			logger.Info("Warning: Failed to apply group quota", "group", g.Name, "error", err)
+		}
+		logger.Info("Admin updated group", "username", session.Username, "group", g.Name, "quota_gb", g.QuotaGB)
+
+	case len(parts) == 4 && r.Method == http.MethodDelete:
+		if err := groups.RemoveGroup(qm, s.cfg.SharesDir, g); err != nil {
+			logger.Info("Error removing group", "group", g.Name, "error", err)
+			storageJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if err := groups.Delete(s.db, g.ID); err != nil {
+			storageJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		s.reloadGroupShares()
+		logger.Info("Admin deleted group", "username", session.Username, "group", g.Name)
+
+	case len(parts) == 5 && parts[4] == "members" && r.Method == http.MethodPost:
+		var req struct {
+			UserID int `json:"user_id"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			storageJSONError(w, http.StatusBadRequest, "Invalid request")
+			return
+		}
+		user, err := users.GetByID(s.db, req.UserID)
+		if err != nil {
+			storageJSONError(w, http.StatusNotFound, "User not found")
+			return
+		}
+		if err := groups.AddUnixMember(g, user.Username); err != nil {
+			logger.Info("Error adding group member", "group", g.Name, "user", user.Username, "error", err)
+			storageJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if err := groups.AddMember(s.db, g.ID, user.ID); err != nil {
+			storageJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		groupShares, _ := groups.GetShares(s.db, g.ID)
+		for _, gs := range groupShares {
+			if err := groups.SetupTrash(gs.Path, user.Username); err != nil {
+				logger.Info("Warning: Failed to create group share trash", "share", gs.Name, "user", user.Username, "error", err)
+			}
+		}
+		logger.Info("Admin added group member", "username", session.Username, "group", g.Name, "member", user.Username)
+
+	case len(parts) == 6 && parts[4] == "members" && r.Method == http.MethodDelete:
+		userID, err := strconv.Atoi(parts[5])
+		if err != nil {
+			http.Error(w, "Invalid user ID", http.StatusBadRequest)
+			return
+		}
+		user, err := users.GetByID(s.db, userID)
+		if err != nil {
+			storageJSONError(w, http.StatusNotFound, "User not found")
+			return
+		}
+		if err := groups.RemoveMember(s.db, g.ID, user.ID); err != nil {
+			storageJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if err := groups.RemoveUnixMember(g, user.Username); err != nil {
+			logger.Info("Warning: Failed to remove unix group member", "group", g.Name, "user", user.Username, "error", err)
+		}
+		logger.Info("Admin removed group member", "username", session.Username, "group", g.Name, "member", user.Username)
+
+	case len(parts) == 5 && parts[4] == "shares" && r.Method == http.MethodPost:
+		var req struct {
+			Name          string `json:"name"`
+			DefaultAccess string `json:"default_access"`
+			SyncEnabled   bool   `json:"sync_enabled"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			storageJSONError(w, http.StatusBadRequest, "Invalid request")
+			return
+		}
+		gs, err := groups.CreateShare(s.db, s.cfg.SharesDir, g, req.Name, req.DefaultAccess, req.SyncEnabled)
+		if err != nil {
+			storageJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		members, _ := groups.GetMembers(s.db, g.ID)
+		if err := groups.SetupShare(gs, members); err != nil {
+			logger.Info("Error setting up group share", "share", gs.Name, "error", err)
+			groups.DeleteShare(s.db, gs.ID)
+			storageJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		s.reloadGroupShares()
+		logger.Info("Admin created group share", "username", session.Username, "group", g.Name, "share", gs.Name)
+
+	default:
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	jsonSuccess(w)
+}
+
+// handleAdminGroupSharesActions handles the routes of a group share:
+//
+//	PUT    /api/admin/group-shares/{id}        update the default access and synchronization
+//	DELETE /api/admin/group-shares/{id}        delete the share and its files
+//	POST   /api/admin/group-shares/{id}/access set the access of a member
+func (s *Server) handleAdminGroupSharesActions(w http.ResponseWriter, r *http.Request) {
+	session, ok := auth.GetSessionFromContext(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	parts := splitPath(r.URL.Path)
+	if len(parts) < 4 {
+		http.Error(w, "Invalid path", http.StatusBadRequest)
+		return
+	}
+	shareID, err := strconv.Atoi(parts[3])
+	if err != nil {
+		http.Error(w, "Invalid share ID", http.StatusBadRequest)
+		return
+	}
+	gs, err := groups.GetShareByID(s.db, shareID)
+	if err != nil {
+		storageJSONError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	switch {
+	case len(parts) == 4 && r.Method == http.MethodPut:
+		var req struct {
+			DefaultAccess string `json:"default_access"`
+			SyncEnabled   bool   `json:"sync_enabled"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			storageJSONError(w, http.StatusBadRequest, "Invalid request")
+			return
+		}
+		gs.DefaultAccess, gs.SyncEnabled = req.DefaultAccess, req.SyncEnabled
+		if err := groups.UpdateShare(s.db, gs); err != nil {
+			storageJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		s.reloadGroupShares()
+		logger.Info("Admin updated group share", "username", session.Username, "share", gs.Name,
+			"default_access", gs.DefaultAccess, "sync", gs.SyncEnabled)
+
+	case len(parts) == 4 && r.Method == http.MethodDelete:
+		if err := groups.RemoveShareDir(gs); err != nil {
+			logger.Info("Error removing group share", "share", gs.Name, "error", err)
+			storageJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if err := groups.DeleteShare(s.db, gs.ID); err != nil {
+			storageJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		s.reloadGroupShares()
+		logger.Info("Admin deleted group share", "username", session.Username, "share", gs.Name)
+
+	case len(parts) == 5 && parts[4] == "access" && r.Method == http.MethodPost:
+		var req struct {
+			UserID int    `json:"user_id"`
+			Access string `json:"access"` // read, write or empty for the default access
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			storageJSONError(w, http.StatusBadRequest, "Invalid request")
+			return
+		}
+		if err := groups.SetMemberAccess(s.db, gs.ID, req.UserID, req.Access); err != nil {
+			storageJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		s.reloadGroupShares()
+		logger.Info("Admin set group share access", "username", session.Username, "share", gs.Name,
+			"user_id", req.UserID, "access", req.Access)
+
+	default:
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	jsonSuccess(w)
+}
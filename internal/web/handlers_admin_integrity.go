@@ -0,0 +1,181 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+// This file contains handlers for the integrity checks of USB drives and peers.
+package web
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/juste-un-gars/anemone/internal/auth"
+	"github.com/juste-un-gars/anemone/internal/i18n"
+	"github.com/juste-un-gars/anemone/internal/integrity"
+	"github.com/juste-un-gars/anemone/internal/logger"
+)
+
+// handleAdminIntegritySettings saves the scheduled integrity check settings
+func (s *Server) handleAdminIntegritySettings(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	lang := s.getLang(r)
+
+	intervalDays, _ := strconv.Atoi(r.FormValue("interval_days"))
+	samplePercent, _ := strconv.Atoi(r.FormValue("sample_percent"))
+	settings := &integrity.Settings{
+		Enabled:       r.FormValue("enabled") == "on",
+		IntervalDays:  intervalDays,
+		SamplePercent: samplePercent,
+	}
+	if err := integrity.SaveSettings(s.db, settings); err != nil {
+		logger.Info("Failed to save integrity check settings", "error", err)
+		http.Redirect(w, r, "/admin/backups?tab=integrity&error="+i18n.T(lang, "integrity.error.settings"), http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, "/admin/backups?tab=integrity&integrity_saved=1", http.StatusSeeOther)
+}
+
+// handleAdminIntegrityRun starts a check of every USB drive and peer, on a sample or on all files
+func (s *Server) handleAdminIntegrityRun(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	lang := s.getLang(r)
+
+	if integrity.IsRunning() {
+		http.Redirect(w, r, "/admin/backups?tab=integrity&error="+i18n.T(lang, "integrity.error.running"), http.StatusSeeOther)
+		return
+	}
+
+	percent := 100
+	if r.FormValue("mode") != "full" {
+		settings, err := integrity.GetSettings(s.db)
+		if err != nil {
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		percent = settings.SamplePercent
+	}
+
+	go func() {
+		if err := integrity.RunAll(s.db, percent); err != nil {
+			logger.Warn("Integrity check failed", "error", err)
+		}
+	}()
+	http.Redirect(w, r, "/admin/backups?tab=integrity&integrity_started=1", http.StatusSeeOther)
+}
+
+// handleAdminIntegrityActions routes /admin/integrity/{id}/{action}
+func (s *Server) handleAdminIntegrityActions(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/admin/integrity/"), "/")
+	id, err := strconv.Atoi(parts[0])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+	action := ""
+	if len(parts) > 1 {
+		action = parts[1]
+	}
+
+	switch action {
+	case "repair", "recheck":
+		s.handleIntegrityCheckAction(w, r, id, action)
+	default:
+		s.handleIntegrityCheckDetail(w, r, id)
+	}
+}
+
+// handleIntegrityCheckDetail shows a check with the files that failed it
+func (s *Server) handleIntegrityCheckDetail(w http.ResponseWriter, r *http.Request, id int) {
+	session, ok := auth.GetSessionFromContext(r)
+	if !ok {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	lang := s.getLang(r)
+
+	check, err := integrity.GetByID(s.db, id)
+	if err != nil {
+		http.Redirect(w, r, "/admin/backups?tab=integrity&error="+i18n.T(lang, "integrity.error.not_found"), http.StatusSeeOther)
+		return
+	}
+
+	data := struct {
+		V2TemplateData
+		Check   *integrity.Check
+		Running bool
+		Success string
+		Error   string
+	}{
+		V2TemplateData: V2TemplateData{
+			Lang:       lang,
+			Title:      i18n.T(lang, "integrity.detail.title"),
+			ActivePage: "backups",
+			Session:    session,
+		},
+		Check:   check,
+		Running: integrity.IsRunning(),
+		Error:   r.URL.Query().Get("error"),
+	}
+	if r.URL.Query().Get("repairing") != "" {
+		data.Success = i18n.T(lang, "integrity.repair_started")
+	}
+
+	tmpl := s.loadV2Page("v2_integrity_check.html", s.funcMap)
+	if err := tmpl.ExecuteTemplate(w, "v2_base", data); err != nil {
+		logger.Info("Template error", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
+
+// handleIntegrityCheckAction repairs the damaged files of a check, or checks its target again
+func (s *Server) handleIntegrityCheckAction(w http.ResponseWriter, r *http.Request, id int, action string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	lang := s.getLang(r)
+	detailURL := "/admin/integrity/" + strconv.Itoa(id)
+
+	check, err := integrity.GetByID(s.db, id)
+	if err != nil {
+		http.Redirect(w, r, "/admin/backups?tab=integrity&error="+i18n.T(lang, "integrity.error.not_found"), http.StatusSeeOther)
+		return
+	}
+	if integrity.IsRunning() {
+		http.Redirect(w, r, detailURL+"?error="+i18n.T(lang, "integrity.error.running"), http.StatusSeeOther)
+		return
+	}
+
+	if action == "repair" {
+		go func() {
+			if err := integrity.Repair(s.db, id, s.cfg.DataDir, s.cfg.IncomingDir); err != nil {
+				logger.Warn("Integrity repair failed", "id", id, "error", err)
+			}
+		}()
+		http.Redirect(w, r, detailURL+"?repairing=1", http.StatusSeeOther)
+		return
+	}
+
+	go func() {
+		var err error
+		switch check.TargetType {
+		case integrity.TargetUSB:
+			err = integrity.RunUSB(s.db, check.TargetID, check.SamplePercent)
+		case integrity.TargetCloud:
+			err = integrity.RunCloud(s.db, check.TargetID, s.cfg.DataDir, s.cfg.IncomingDir)
+		default:
+			err = integrity.RunPeer(s.db, check.TargetID, check.SamplePercent)
+		}
+		if err != nil {
+			logger.Warn("Integrity check failed", "target", check.TargetName, "error", err)
+		}
+	}()
+	http.Redirect(w, r, "/admin/backups?tab=integrity&integrity_started=1", http.StatusSeeOther)
+}
@@ -48,9 +48,11 @@ func (s *Server) handleAdminPeers(w http.ResponseWriter, r *http.Request) {
 	}
 
 	query := `
-		SELECT u.username, p.name, sl.started_at, sl.completed_at, sl.status, sl.files_synced, sl.bytes_synced
+		SELECT COALESCE(u.username, '@' || g.name || '/' || gs.name, '?'), p.name, sl.started_at, sl.completed_at, sl.status, sl.files_synced, sl.bytes_synced
 		FROM sync_log sl
-		JOIN users u ON sl.user_id = u.id
+		LEFT JOIN users u ON sl.user_id = u.id
+		LEFT JOIN group_shares gs ON sl.group_share_id = gs.id
+		LEFT JOIN groups g ON gs.group_id = g.id
 		JOIN peers p ON sl.peer_id = p.id
 		ORDER BY sl.started_at DESC
 		LIMIT 20
@@ -259,6 +261,7 @@ func (s *Server) handleAdminPeersAdd(w http.ResponseWriter, r *http.Request) {
 				syncTimeoutHours = timeout
 			}
 		}
+		verifyUploads := r.FormValue("verify_uploads") == "on"
 
 		// Get master key for password encryption
 		var masterKey string
@@ -299,6 +302,7 @@ func (s *Server) handleAdminPeersAdd(w http.ResponseWriter, r *http.Request) {
 			SyncDayOfMonth:      syncDayOfMonthPtr,
 			SyncIntervalMinutes: syncIntervalMinutes,
 			SyncTimeoutHours:    syncTimeoutHours,
+			VerifyUploads:       verifyUploads,
 		}
 
 		if err := peers.Create(s.db, peer); err != nil {
@@ -526,6 +530,7 @@ func (s *Server) handleAdminPeersActions(w http.ResponseWriter, r *http.Request)
 		} else {
 			peer.SyncTimeoutHours = 2 // Default: 2 hours
 		}
+		peer.VerifyUploads = r.FormValue("verify_uploads") == "on"
 
 		// Save to database
 		if err := peers.Update(s.db, peer); err != nil {
@@ -14,13 +14,70 @@ import (
 	"github.com/juste-un-gars/anemone/internal/auth"
 	"github.com/juste-un-gars/anemone/internal/i18n"
 	"github.com/juste-un-gars/anemone/internal/incoming"
+	"github.com/juste-un-gars/anemone/internal/integrity"
 	"github.com/juste-un-gars/anemone/internal/logger"
 	"github.com/juste-un-gars/anemone/internal/rclone"
+	"github.com/juste-un-gars/anemone/internal/shares"
+	"github.com/juste-un-gars/anemone/internal/users"
 )
 
 // validRemoteName matches safe rclone remote names (alphanumeric, dash, underscore, dot).
 var validRemoteName = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._-]*$`)
 
+// rcloneShareOption is a share offered for individual selection in the rclone forms
+type rcloneShareOption struct {
+	ID         int
+	Name       string
+	Username   string
+	IsSelected bool
+}
+
+// rcloneShareOptions lists the shares of the server, marking the selected ones
+func (s *Server) rcloneShareOptions(selectedIDs []int) []rcloneShareOption {
+	selected := make(map[int]bool, len(selectedIDs))
+	for _, id := range selectedIDs {
+		selected[id] = true
+	}
+
+	var options []rcloneShareOption
+	allShares, _ := shares.GetAll(s.db)
+	for _, sh := range allShares {
+		opt := rcloneShareOption{ID: sh.ID, Name: sh.Name, IsSelected: selected[sh.ID]}
+		if user, err := users.GetByID(s.db, sh.UserID); err == nil {
+			opt.Username = user.Username
+		}
+		options = append(options, opt)
+	}
+	return options
+}
+
+// parseRcloneSources reads the source selection, versioning and integrity check options of the rclone forms
+func parseRcloneSources(r *http.Request, backup *rclone.RcloneBackup) {
+	backup.IncludeBackup = r.FormValue("include_backup") == "on"
+	backup.IncludeData = r.FormValue("include_data") == "on"
+	backup.IncludeServerConfig = r.FormValue("include_server_config") == "on"
+	backup.IncludeIncoming = r.FormValue("include_incoming") == "on"
+
+	backup.Versioning = r.FormValue("versioning") == "on"
+	backup.VersionRetentionDays = 30
+	if days, err := strconv.Atoi(r.FormValue("version_retention_days")); err == nil && days >= 0 {
+		backup.VersionRetentionDays = days
+	}
+
+	backup.CheckIntervalDays = 0
+	if days, err := strconv.Atoi(r.FormValue("check_interval_days")); err == nil && days > 0 {
+		backup.CheckIntervalDays = days
+	}
+
+	var ids []int
+	for _, idStr := range r.Form["selected_shares"] {
+		if id, err := strconv.Atoi(idStr); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	backup.SetSelectedShareIDs(ids)
+}
+
 // handleAdminRclone displays the rclone backup management page
 func (s *Server) handleAdminRclone(w http.ResponseWriter, r *http.Request) {
 	// Redirect GET to consolidated backups page
@@ -141,6 +198,8 @@ func (s *Server) handleAdminRcloneAdd(w http.ResponseWriter, r *http.Request) {
 		Enabled:      enabled,
 		ProviderType: providerType,
 	}
+	r.ParseForm()
+	parseRcloneSources(r, backup)
 
 	switch providerType {
 	case rclone.ProviderSFTP:
@@ -246,6 +305,7 @@ func (s *Server) handleRcloneAddForm(w http.ResponseWriter, r *http.Request) {
 	data := struct {
 		V2TemplateData
 		Remotes    []rclone.RemoteInfo
+		Shares     []rcloneShareOption
 		SSHKeyPath string
 		Error      string
 	}{
@@ -256,6 +316,7 @@ func (s *Server) handleRcloneAddForm(w http.ResponseWriter, r *http.Request) {
 			Session:    session,
 		},
 		Remotes: remotes,
+		Shares:  s.rcloneShareOptions(nil),
 		Error:   r.URL.Query().Get("error"),
 	}
 
@@ -283,7 +344,7 @@ func (s *Server) handleAdminRcloneListRemotes(w http.ResponseWriter, r *http.Req
 	json.NewEncoder(w).Encode(remotes)
 }
 
-// handleAdminRcloneActions handles edit, delete, sync, test actions for rclone backups
+// handleAdminRcloneActions handles edit, delete, sync, test, restore, progress, cancel, errors and check actions for rclone backups
 func (s *Server) handleAdminRcloneActions(w http.ResponseWriter, r *http.Request) {
 	// Extract ID from URL: /admin/rclone/{id}/{action}
 	path := strings.TrimPrefix(r.URL.Path, "/admin/rclone/")
@@ -314,6 +375,16 @@ func (s *Server) handleAdminRcloneActions(w http.ResponseWriter, r *http.Request
 		s.handleRcloneTest(w, r, id)
 	case "edit":
 		s.handleRcloneEdit(w, r, id)
+	case "restore":
+		s.handleRcloneRestore(w, r, id, strings.Join(parts[2:], "/"))
+	case "progress":
+		s.handleRcloneProgress(w, r, id)
+	case "cancel":
+		s.handleRcloneCancel(w, r, id)
+	case "errors":
+		s.handleRcloneErrors(w, r, id)
+	case "check":
+		s.handleRcloneCheck(w, r, id)
 	default:
 		// Show edit form
 		s.handleRcloneEditForm(w, r, id)
@@ -359,8 +430,7 @@ func (s *Server) handleRcloneSync(w http.ResponseWriter, r *http.Request, id int
 
 	// Run sync in background
 	go func() {
-		dataDir := s.cfg.DataDir
-		result, syncErr := rclone.Sync(s.db, backup, dataDir)
+		result, syncErr := rclone.Sync(s.db, backup, s.cfg.DataDir, s.cfg.IncomingDir)
 
 		if syncErr != nil {
 			logger.Info("Rclone backup sync error", "sync_err", syncErr)
@@ -372,6 +442,112 @@ func (s *Server) handleRcloneSync(w http.ResponseWriter, r *http.Request, id int
 	http.Redirect(w, r, "/admin/backups?tab=cloud&syncing=1", http.StatusSeeOther)
 }
 
+// handleRcloneCheck starts an integrity check comparing a cloud destination with the local files
+func (s *Server) handleRcloneCheck(w http.ResponseWriter, r *http.Request, id int) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	lang := s.getLang(r)
+
+	backup, err := rclone.GetByID(s.db, id)
+	if err != nil {
+		http.Redirect(w, r, "/admin/backups?tab=cloud&error="+i18n.T(lang, "backup_not_found"), http.StatusSeeOther)
+		return
+	}
+	if !rclone.IsRcloneInstalled() {
+		http.Redirect(w, r, "/admin/backups?tab=cloud&error="+i18n.T(lang, "rclone.not_installed"), http.StatusSeeOther)
+		return
+	}
+	if rclone.IsBackupSyncing(id) {
+		http.Redirect(w, r, "/admin/backups?tab=cloud&error="+i18n.T(lang, "rclone.check.syncing"), http.StatusSeeOther)
+		return
+	}
+	if integrity.IsRunning() {
+		http.Redirect(w, r, "/admin/backups?tab=cloud&error="+i18n.T(lang, "integrity.error.running"), http.StatusSeeOther)
+		return
+	}
+
+	go func() {
+		if err := integrity.RunCloud(s.db, backup.ID, s.cfg.DataDir, s.cfg.IncomingDir); err != nil {
+			logger.Warn("Cloud integrity check failed", "name", backup.Name, "error", err)
+		}
+	}()
+	http.Redirect(w, r, "/admin/backups?tab=cloud&integrity_started=1", http.StatusSeeOther)
+}
+
+// handleRcloneProgress returns the live progress of a running sync as JSON
+func (s *Server) handleRcloneProgress(w http.ResponseWriter, r *http.Request, id int) {
+	w.Header().Set("Content-Type", "application/json")
+	progress, ok := rclone.GetProgress(id)
+	if !ok {
+		json.NewEncoder(w).Encode(map[string]interface{}{"running": false})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"running": true, "progress": progress})
+}
+
+// handleRcloneCancel stops a running sync
+func (s *Server) handleRcloneCancel(w http.ResponseWriter, r *http.Request, id int) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	lang := s.getLang(r)
+	if err := rclone.CancelSync(id); err != nil {
+		http.Redirect(w, r, "/admin/backups?tab=cloud&error="+i18n.T(lang, "rclone.not_running"), http.StatusSeeOther)
+		return
+	}
+
+	session, _ := auth.GetSessionFromContext(r)
+	logger.Info("Admin cancelled rclone sync", "username", session.Username, "backup_id", id)
+	http.Redirect(w, r, "/admin/backups?tab=cloud&cancelled=1", http.StatusSeeOther)
+}
+
+// handleRcloneErrors lists the files that failed during the last sync of a rclone backup
+func (s *Server) handleRcloneErrors(w http.ResponseWriter, r *http.Request, id int) {
+	session, ok := auth.GetSessionFromContext(r)
+	if !ok {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	lang := s.getLang(r)
+
+	backup, err := rclone.GetByID(s.db, id)
+	if err != nil {
+		http.Redirect(w, r, "/admin/backups?tab=cloud&error="+i18n.T(lang, "backup_not_found"), http.StatusSeeOther)
+		return
+	}
+
+	fileErrors, err := rclone.GetFileErrors(s.db, id)
+	if err != nil {
+		logger.Info("Error getting rclone file errors", "backup_id", id, "error", err)
+	}
+
+	data := struct {
+		V2TemplateData
+		Backup     *rclone.RcloneBackup
+		FileErrors []rclone.FileError
+	}{
+		V2TemplateData: V2TemplateData{
+			Lang:       lang,
+			Title:      i18n.T(lang, "rclone.file_errors.title"),
+			ActivePage: "backups",
+			Session:    session,
+		},
+		Backup:     backup,
+		FileErrors: fileErrors,
+	}
+
+	tmpl := s.loadV2Page("v2_rclone_errors.html", s.funcMap)
+	if err := tmpl.ExecuteTemplate(w, "v2_base", data); err != nil {
+		logger.Info("Template error", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
+
 // handleRcloneTest tests the SFTP connection for a rclone backup
 func (s *Server) handleRcloneTest(w http.ResponseWriter, r *http.Request, id int) {
 	if r.Method != http.MethodPost {
@@ -424,6 +600,7 @@ func (s *Server) handleRcloneEditForm(w http.ResponseWriter, r *http.Request, id
 		V2TemplateData
 		Backup  *rclone.RcloneBackup
 		Remotes []rclone.RemoteInfo
+		Shares  []rcloneShareOption
 	}{
 		V2TemplateData: V2TemplateData{
 			Lang:       lang,
@@ -433,6 +610,7 @@ func (s *Server) handleRcloneEditForm(w http.ResponseWriter, r *http.Request, id
 		},
 		Backup:  backup,
 		Remotes: remotes,
+		Shares:  s.rcloneShareOptions(backup.GetSelectedShareIDs()),
 	}
 
 	tmpl := s.loadV2Page("v2_rclone_edit.html", s.funcMap)
@@ -461,6 +639,8 @@ func (s *Server) handleRcloneEdit(w http.ResponseWriter, r *http.Request, id int
 	backup.Name = strings.TrimSpace(r.FormValue("name"))
 	backup.RemotePath = strings.TrimSpace(r.FormValue("remote_path"))
 	backup.Enabled = r.FormValue("enabled") == "on"
+	r.ParseForm()
+	parseRcloneSources(r, backup)
 
 	// Provider-specific fields (provider type is read-only, kept from DB)
 	switch backup.ProviderType {
@@ -0,0 +1,195 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+// This file contains handlers for restoring user backups from rclone cloud destinations.
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/juste-un-gars/anemone/internal/auth"
+	"github.com/juste-un-gars/anemone/internal/i18n"
+	"github.com/juste-un-gars/anemone/internal/logger"
+	"github.com/juste-un-gars/anemone/internal/rclone"
+	"github.com/juste-un-gars/anemone/internal/shares"
+	"github.com/juste-un-gars/anemone/internal/users"
+)
+
+// handleRcloneRestore dispatches cloud restore requests
+// URL format: /admin/rclone/{id}/restore[/users|/versions|/files|/start|/jobs]
+func (s *Server) handleRcloneRestore(w http.ResponseWriter, r *http.Request, id int, sub string) {
+	backup, err := rclone.GetByID(s.db, id)
+	if err != nil {
+		http.Redirect(w, r, "/admin/backups?tab=cloud&error=not_found", http.StatusSeeOther)
+		return
+	}
+
+	switch sub {
+	case "":
+		s.handleRcloneRestorePage(w, r, backup)
+	case "users":
+		cloudUsers, err := rclone.ListCloudUsers(backup, s.cfg.DataDir)
+		if err != nil {
+			logger.Info("Error listing cloud users", "backup_id", id, "error", err)
+			jsonError(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		if cloudUsers == nil {
+			cloudUsers = []string{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(cloudUsers)
+	case "versions":
+		versions, err := rclone.ListCloudVersions(backup, s.cfg.DataDir, r.URL.Query().Get("user"))
+		if err != nil {
+			logger.Info("Error listing cloud versions", "backup_id", id, "error", err)
+			jsonError(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		if versions == nil {
+			versions = []rclone.Version{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(versions)
+	case "files":
+		q := r.URL.Query()
+		entries, err := rclone.ListCloudPath(backup, s.cfg.DataDir, q.Get("user"), q.Get("version"), q.Get("path"))
+		if err != nil {
+			logger.Info("Error listing cloud files", "backup_id", id, "error", err)
+			jsonError(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(entries)
+	case "start":
+		s.handleRcloneRestoreStart(w, r, backup)
+	case "jobs":
+		jobs := rclone.ListRestoreJobs(backup.ID)
+		if jobs == nil {
+			jobs = []*rclone.RestoreJob{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(jobs)
+	default:
+		http.Error(w, "Not found", http.StatusNotFound)
+	}
+}
+
+// handleRcloneRestorePage shows the cloud restore browser
+func (s *Server) handleRcloneRestorePage(w http.ResponseWriter, r *http.Request, backup *rclone.RcloneBackup) {
+	session, ok := auth.GetSessionFromContext(r)
+	if !ok {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	lang := s.getLang(r)
+
+	data := struct {
+		V2TemplateData
+		Backup    *rclone.RcloneBackup
+		Installed bool
+	}{
+		V2TemplateData: V2TemplateData{
+			Lang:       lang,
+			Title:      i18n.T(lang, "rclone.restore.title"),
+			ActivePage: "backups",
+			Session:    session,
+		},
+		Backup:    backup,
+		Installed: rclone.IsRcloneInstalled(),
+	}
+
+	tmpl := s.loadV2Page("v2_rclone_restore.html", s.funcMap)
+	if err := tmpl.ExecuteTemplate(w, "v2_base", data); err != nil {
+		logger.Info("Template error", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
+
+// handleRcloneRestoreStart starts pulling a user's cloud backup back into one of their shares
+// Form data: user, version, paths (multiple), target_share, target_dir
+func (s *Server) handleRcloneRestoreStart(w http.ResponseWriter, r *http.Request, backup *rclone.RcloneBackup) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	session, _ := auth.GetSessionFromContext(r)
+
+	if err := r.ParseForm(); err != nil {
+		jsonError(w, "Failed to parse form", http.StatusBadRequest)
+		return
+	}
+
+	username := r.FormValue("user")
+	targetDir, err := s.cloudRestoreTarget(username, r.FormValue("target_share"), r.FormValue("target_dir"))
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	job, err := rclone.StartRestore(backup, s.cfg.DataDir, rclone.RestoreRequest{
+		Username:  username,
+		Version:   r.FormValue("version"),
+		Paths:     r.Form["paths"],
+		TargetDir: targetDir,
+		Owner:     username,
+	})
+	if err != nil {
+		logger.Info("Error starting cloud restore", "backup_id", backup.ID, "error", err)
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	logger.Info("Admin started cloud restore", "username", session.Username, "backup_id", backup.ID, "user", username, "version", r.FormValue("version"), "target", targetDir)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"job":     job,
+	})
+}
+
+// cloudRestoreTarget resolves the local directory receiving a user's cloud restore.
+// Defaults to the user's backup share; subDir is confined to the chosen share.
+func (s *Server) cloudRestoreTarget(username, shareName, subDir string) (string, error) {
+	user, err := users.GetByUsername(s.db, username)
+	if err != nil {
+		return "", fmt.Errorf("no local user named %q: create or restore the account first", username)
+	}
+
+	if shareName == "" {
+		shareName = "backup_" + username
+	}
+	userShares, err := shares.GetByUser(s.db, user.ID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get user shares: %w", err)
+	}
+
+	root := ""
+	for _, sh := range userShares {
+		if sh.Name == shareName {
+			root = sh.Path
+			break
+		}
+	}
+	if root == "" {
+		if shareName != "backup_"+username {
+			return "", fmt.Errorf("share not found: %s", shareName)
+		}
+		root = filepath.Join(s.cfg.SharesDir, username, "backup")
+	}
+
+	subDir = strings.Trim(strings.TrimSpace(subDir), "/")
+	if subDir == "" {
+		return root, nil
+	}
+	target := filepath.Join(root, filepath.Clean(subDir))
+	if !strings.HasPrefix(target, filepath.Clean(root)+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid target directory")
+	}
+	return target, nil
+}
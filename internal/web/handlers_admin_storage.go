@@ -16,8 +16,10 @@ import (
 
 	"github.com/juste-un-gars/anemone/internal/adminverify"
 	"github.com/juste-un-gars/anemone/internal/auth"
+	"github.com/juste-un-gars/anemone/internal/btrfs"
 	"github.com/juste-un-gars/anemone/internal/i18n"
 	"github.com/juste-un-gars/anemone/internal/logger"
+	"github.com/juste-un-gars/anemone/internal/peers"
 	"github.com/juste-un-gars/anemone/internal/storage"
 )
 
@@ -37,6 +39,14 @@ func (s *Server) handleAdminStorage(w http.ResponseWriter, r *http.Request) {
 		overview = &storage.StorageOverview{}
 	}
 
+	// Peers replicated datasets can be sent to
+	var allPeers []*peers.Peer
+	if overview.ZFSAvailable {
+		if allPeers, err = peers.GetAll(s.db); err != nil {
+			logger.Info("Error getting peers", "error", err)
+		}
+	}
+
 	data := struct {
 		V2TemplateData
 		Overview       *storage.StorageOverview
@@ -44,6 +54,11 @@ func (s *Server) handleAdminStorage(w http.ResponseWriter, r *http.Request) {
 		Pools          []storage.ZFSPool
 		SMARTAvailable bool
 		ZFSAvailable   bool
+		MDAvailable    bool
+		MDArrays       []storage.MDArray
+		LUKSAvailable  bool
+		BtrfsAvailable bool
+		Peers          []*peers.Peer
 	}{
 		V2TemplateData: V2TemplateData{
 			Lang:       lang,
@@ -56,6 +71,11 @@ func (s *Server) handleAdminStorage(w http.ResponseWriter, r *http.Request) {
 		Pools:          overview.Pools,
 		SMARTAvailable: overview.SMARTAvailable,
 		ZFSAvailable:   overview.ZFSAvailable,
+		MDAvailable:    overview.MDAvailable,
+		MDArrays:       overview.MDArrays,
+		LUKSAvailable:  storage.IsLUKSAvailable(),
+		BtrfsAvailable: btrfs.IsFilesystem(s.cfg.SharesDir),
+		Peers:          allPeers,
 	}
 
 	tmpl := s.loadV2Page("v2_storage.html", s.funcMap)
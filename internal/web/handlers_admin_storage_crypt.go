@@ -0,0 +1,277 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+// This file contains the handlers of the at-rest encryption: the encrypted
+// disks, pools and datasets, their locking and unlocking, and their keys.
+
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/juste-un-gars/anemone/internal/auth"
+	"github.com/juste-un-gars/anemone/internal/diskcrypt"
+	"github.com/juste-un-gars/anemone/internal/logger"
+	"github.com/juste-un-gars/anemone/internal/storage"
+)
+
+// encryptionRequest holds the encryption options of the format, create pool and
+// create dataset requests
+type encryptionRequest struct {
+	Enabled    bool   `json:"enabled"`
+	KeyMode    string `json:"key_mode"` // master, passphrase
+	Passphrase string `json:"passphrase"`
+}
+
+// newKey validates the options and generates the volume key, empty when the
+// encryption is disabled
+func (e encryptionRequest) newKey() (string, error) {
+	if !e.Enabled {
+		return "", nil
+	}
+	if err := diskcrypt.ValidateKeyMode(e.KeyMode, e.Passphrase); err != nil {
+		return "", err
+	}
+	return diskcrypt.NewVolumeKey()
+}
+
+// registerVolume records a volume just created with key. The volume exists
+// already, so if its key cannot be saved it is returned in the error for the
+// admin to keep it.
+func (s *Server) registerVolume(v diskcrypt.Volume, key string, enc encryptionRequest) error {
+	v.KeyMode = enc.KeyMode
+	if _, err := diskcrypt.Register(s.db, v, key, enc.Passphrase); err != nil {
+		logger.Error("Failed to save the key of an encrypted volume", "volume", v.Name, "error", err)
+		return fmt.Errorf("volume created but its key could not be saved (%v), write down this key now: %s", err, key)
+	}
+	logger.Info("Registered encrypted volume", "volume", v.Name, "kind", v.Kind, "key_mode", v.KeyMode)
+	return nil
+}
+
+// volumeID reads the id query parameter of a volume request
+func volumeID(r *http.Request) (int64, error) {
+	id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("invalid volume ID")
+	}
+	return id, nil
+}
+
+// handleAdminStorageEncryptedVolumes lists the encrypted volumes with their
+// lock state (GET) and forgets one that no longer exists (DELETE ?id=)
+func (s *Server) handleAdminStorageEncryptedVolumes(w http.ResponseWriter, r *http.Request) {
+	session, ok := auth.GetSessionFromContext(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		volumes, err := diskcrypt.List(s.db)
+		if err != nil {
+			logger.Info("Error listing encrypted volumes", "error", err)
+			storageJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"volumes":        volumes,
+			"luks_available": storage.IsLUKSAvailable(),
+		})
+
+	case http.MethodDelete:
+		id, err := volumeID(r)
+		if err != nil {
+			storageJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err := s.validateVerificationToken(r, session); err != nil {
+			storageJSONError(w, http.StatusForbidden, "Password verification required")
+			return
+		}
+		if err := diskcrypt.Forget(s.db, id); err != nil {
+			storageJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		logger.Info("Admin forgot encrypted volume", "username", session.Username, "id", id)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true})
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleAdminStorageVolumeUnlock unlocks and mounts an encrypted volume
+// (POST ?id=, passphrase in the body for the passphrase-wrapped volumes)
+func (s *Server) handleAdminStorageVolumeUnlock(w http.ResponseWriter, r *http.Request) {
+	session, ok := auth.GetSessionFromContext(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := volumeID(r)
+	if err != nil {
+		storageJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	var req struct {
+		Passphrase string `json:"passphrase"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		storageJSONError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+
+	if err := diskcrypt.Unlock(s.db, id, req.Passphrase); err != nil {
+		logger.Info("Error unlocking encrypted volume", "id", id, "error", err)
+		storageJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	logger.Info("Admin unlocked encrypted volume", "username", session.Username, "id", id)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": true})
+}
+
+// handleAdminStorageVolumeLock unmounts and locks an encrypted volume (POST ?id=)
+func (s *Server) handleAdminStorageVolumeLock(w http.ResponseWriter, r *http.Request) {
+	session, ok := auth.GetSessionFromContext(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := volumeID(r)
+	if err != nil {
+		storageJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Locking makes the data unavailable to the shares
+	if err := s.validateVerificationToken(r, session); err != nil {
+		storageJSONError(w, http.StatusForbidden, "Password verification required")
+		return
+	}
+
+	if err := diskcrypt.Lock(s.db, id); err != nil {
+		logger.Info("Error locking encrypted volume", "id", id, "error", err)
+		storageJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	logger.Info("Admin locked encrypted volume", "username", session.Username, "id", id)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": true})
+}
+
+// handleAdminStorageVolumeKey changes the passphrase of an encrypted volume, or
+// switches it between the master key and a passphrase (POST ?id=)
+func (s *Server) handleAdminStorageVolumeKey(w http.ResponseWriter, r *http.Request) {
+	session, ok := auth.GetSessionFromContext(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := volumeID(r)
+	if err != nil {
+		storageJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	var req struct {
+		CurrentPassphrase string `json:"current_passphrase"`
+		KeyMode           string `json:"key_mode"`
+		Passphrase        string `json:"passphrase"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		storageJSONError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+
+	if err := s.validateVerificationToken(r, session); err != nil {
+		storageJSONError(w, http.StatusForbidden, "Password verification required")
+		return
+	}
+
+	if err := diskcrypt.ChangeKey(s.db, id, req.CurrentPassphrase, req.KeyMode, req.Passphrase); err != nil {
+		logger.Info("Error changing encrypted volume key", "id", id, "error", err)
+		storageJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	logger.Info("Admin changed encrypted volume key", "username", session.Username, "id", id, "key_mode", req.KeyMode)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": true})
+}
+
+// handleAdminStorageVolumeExport returns the raw key of an encrypted volume, for
+// the admin to keep it offline (POST ?id=)
+func (s *Server) handleAdminStorageVolumeExport(w http.ResponseWriter, r *http.Request) {
+	session, ok := auth.GetSessionFromContext(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := volumeID(r)
+	if err != nil {
+		storageJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	var req struct {
+		Passphrase string `json:"passphrase"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		storageJSONError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+
+	if err := s.validateVerificationToken(r, session); err != nil {
+		storageJSONError(w, http.StatusForbidden, "Password verification required")
+		return
+	}
+
+	v, key, err := diskcrypt.ExportKey(s.db, id, req.Passphrase)
+	if err != nil {
+		storageJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	logger.Warn("Admin exported encrypted volume key", "username", session.Username, "volume", v.Name)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"name": v.Name,
+		"kind": v.Kind,
+		"uuid": v.UUID,
+		"key":  key,
+	})
+}
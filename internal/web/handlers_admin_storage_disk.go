@@ -11,6 +11,7 @@ import (
 	"net/http"
 
 	"github.com/juste-un-gars/anemone/internal/auth"
+	"github.com/juste-un-gars/anemone/internal/diskcrypt"
 	"github.com/juste-un-gars/anemone/internal/logger"
 	"github.com/juste-un-gars/anemone/internal/storage"
 )
@@ -49,7 +50,10 @@ func (s *Server) handleAdminStorageDiskFormat(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	var req storage.FormatOptions
+	var req struct {
+		storage.FormatOptions
+		Encryption encryptionRequest `json:"encryption"`
+	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusBadRequest)
@@ -65,7 +69,14 @@ func (s *Server) handleAdminStorageDiskFormat(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	if err := storage.FormatDisk(req); err != nil {
+	key, err := req.Encryption.newKey()
+	if err != nil {
+		storageJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	req.EncryptionKey = key
+
+	if err := storage.FormatDisk(req.FormatOptions); err != nil {
 		logger.Info("Error formatting disk", "device", req.Device, "error", err)
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusInternalServerError)
@@ -73,11 +84,37 @@ func (s *Server) handleAdminStorageDiskFormat(w http.ResponseWriter, r *http.Req
 		return
 	}
 
+	// Encrypted disks are mounted through their unlocked volume, and remounted
+	// when unlocked instead of from fstab
+	mountDevice := req.Device
+	if key != "" {
+		volume := diskcrypt.Volume{
+			Kind:         diskcrypt.KindLUKS,
+			Name:         storage.LUKSMapperName(req.Device),
+			Device:       req.Device,
+			SharedAccess: req.SharedAccess,
+		}
+		if uuid, err := storage.LUKSUUID(req.Device); err == nil {
+			volume.UUID = uuid
+		} else {
+			logger.Warn("Failed to read LUKS UUID", "device", req.Device, "error", err)
+		}
+		if req.Mount && req.MountPath != "" {
+			volume.Mountpoint = req.MountPath
+		}
+		if err := s.registerVolume(volume, key, req.Encryption); err != nil {
+			storageJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		mountDevice = storage.MapperPath(volume.Name)
+		req.Persistent = false
+	}
+
 	// Mount the disk if requested
 	var mountError string
 	var fstabError string
 	if req.Mount && req.MountPath != "" {
-		if err := storage.MountDisk(req.Device, req.MountPath, req.SharedAccess); err != nil {
+		if err := storage.MountDisk(mountDevice, req.MountPath, req.SharedAccess); err != nil {
 			logger.Info("Warning: Failed to mount disk at", "device", req.Device, "mount_path", req.MountPath, "error", err)
 			mountError = err.Error()
 		} else {
@@ -101,6 +138,7 @@ func (s *Server) handleAdminStorageDiskFormat(w http.ResponseWriter, r *http.Req
 		"device":     req.Device,
 		"mounted":    req.Mount && mountError == "",
 		"mount_path": req.MountPath,
+		"encrypted":  key != "",
 	}
 	if mountError != "" {
 		response["mount_error"] = mountError
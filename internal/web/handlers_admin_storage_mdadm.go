@@ -0,0 +1,175 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+// This file contains the handlers of the software RAID (mdadm) arrays: listing
+// and creating arrays, managing their members and checking them.
+
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/juste-un-gars/anemone/internal/auth"
+	"github.com/juste-un-gars/anemone/internal/logger"
+	"github.com/juste-un-gars/anemone/internal/storage"
+)
+
+// handleAdminStorageMDArrays lists the md arrays (GET) and creates one (POST)
+func (s *Server) handleAdminStorageMDArrays(w http.ResponseWriter, r *http.Request) {
+	session, ok := auth.GetSessionFromContext(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		arrays, err := storage.ListMDArrays()
+		if err != nil {
+			logger.Info("Error listing md arrays", "error", err)
+			storageJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if arrays == nil {
+			arrays = []storage.MDArray{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(arrays)
+
+	case http.MethodPost:
+		var req storage.MDCreateOptions
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			storageJSONError(w, http.StatusBadRequest, "Invalid request")
+			return
+		}
+
+		// Verify password token for this destructive operation
+		if err := s.validateVerificationToken(r, session); err != nil {
+			storageJSONError(w, http.StatusForbidden, "Password verification required")
+			return
+		}
+
+		if err := storage.CreateMDArray(req); err != nil {
+			logger.Info("Error creating md array", "array", req.Name, "error", err)
+			storageJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		logger.Info("Admin created md array", "username", session.Username, "array", req.Name,
+			"level", req.Level, "disks", req.Disks, "spares", req.Spares)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"array":   req.Name,
+		})
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleAdminStorageMDDisk adds, fails, removes or replaces a member of an md
+// array (POST /api/admin/storage/md-array-disk/{name})
+func (s *Server) handleAdminStorageMDDisk(w http.ResponseWriter, r *http.Request) {
+	session, ok := auth.GetSessionFromContext(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	parts := splitPath(r.URL.Path)
+	if len(parts) < 5 {
+		http.Error(w, "Invalid path", http.StatusBadRequest)
+		return
+	}
+	name := parts[4]
+
+	var req struct {
+		Action  string `json:"action"` // add, fail, remove, replace
+		Disk    string `json:"disk"`
+		NewDisk string `json:"new_disk"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		storageJSONError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+
+	if err := s.validateVerificationToken(r, session); err != nil {
+		storageJSONError(w, http.StatusForbidden, "Password verification required")
+		return
+	}
+
+	var err error
+	switch req.Action {
+	case "add":
+		err = storage.AddMDDisk(name, req.Disk)
+	case "fail":
+		err = storage.FailMDDisk(name, req.Disk)
+	case "remove":
+		err = storage.RemoveMDDisk(name, req.Disk)
+	case "replace":
+		err = storage.ReplaceMDDisk(name, req.Disk, req.NewDisk)
+	default:
+		storageJSONError(w, http.StatusBadRequest, "Invalid action")
+		return
+	}
+	if err != nil {
+		logger.Info("Error managing md array member", "array", name, "action", req.Action, "disk", req.Disk, "error", err)
+		storageJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	logger.Info("Admin changed md array member", "username", session.Username, "array", name,
+		"action", req.Action, "disk", req.Disk, "new_disk", req.NewDisk)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"array":   name,
+	})
+}
+
+// handleAdminStorageMDCheck starts, or stops with ?stop=1, the check of an md
+// array (POST /api/admin/storage/md-array-check/{name})
+func (s *Server) handleAdminStorageMDCheck(w http.ResponseWriter, r *http.Request) {
+	session, ok := auth.GetSessionFromContext(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	parts := splitPath(r.URL.Path)
+	if len(parts) < 5 {
+		http.Error(w, "Invalid path", http.StatusBadRequest)
+		return
+	}
+	name := parts[4]
+
+	stop := r.URL.Query().Get("stop") == "1"
+	var err error
+	if stop {
+		err = storage.StopMDCheck(name)
+	} else {
+		err = storage.StartMDCheck(name)
+	}
+	if err != nil {
+		logger.Info("Error changing md array check", "array", name, "stop", stop, "error", err)
+		storageJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	logger.Info("Admin changed md array check", "username", session.Username, "array", name, "stop", stop)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": true})
+}
@@ -0,0 +1,191 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+// This file contains the handlers of the ZFS replication jobs sending datasets to
+// peers, and of the replicas this server receives.
+
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/juste-un-gars/anemone/internal/auth"
+	"github.com/juste-un-gars/anemone/internal/logger"
+	"github.com/juste-un-gars/anemone/internal/zfsrepl"
+)
+
+// handleAdminStorageReplications lists (GET), creates (POST), updates (PUT) and
+// deletes (DELETE) ZFS replication jobs. Creating and updating a job require
+// password verification, as it sends a dataset to another server.
+func (s *Server) handleAdminStorageReplications(w http.ResponseWriter, r *http.Request) {
+	session, ok := auth.GetSessionFromContext(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		replications, err := zfsrepl.GetReplications(s.db)
+		if err != nil {
+			logger.Info("Error listing ZFS replications", "error", err)
+			storageJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if replications == nil {
+			replications = []*zfsrepl.Replication{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(replications)
+
+	case http.MethodPost, http.MethodPut:
+		var req zfsrepl.Replication
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			storageJSONError(w, http.StatusBadRequest, "Invalid request")
+			return
+		}
+
+		if err := s.validateVerificationToken(r, session); err != nil {
+			storageJSONError(w, http.StatusForbidden, "Password verification required")
+			return
+		}
+
+		var err error
+		if r.Method == http.MethodPost {
+			err = zfsrepl.CreateReplication(s.db, &req)
+		} else {
+			err = zfsrepl.UpdateReplication(s.db, &req)
+		}
+		if err != nil {
+			logger.Info("Error saving ZFS replication", "dataset", req.Dataset, "error", err)
+			storageJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success":     true,
+			"replication": req,
+		})
+
+	case http.MethodDelete:
+		id, err := strconv.Atoi(r.URL.Query().Get("id"))
+		if err != nil {
+			storageJSONError(w, http.StatusBadRequest, "Replication ID required")
+			return
+		}
+		if zfsrepl.IsRunning(id) {
+			storageJSONError(w, http.StatusConflict, zfsrepl.ErrRunning.Error())
+			return
+		}
+		if err := zfsrepl.DeleteReplication(s.db, id); err != nil {
+			logger.Info("Error deleting ZFS replication", "id", id, "error", err)
+			storageJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true})
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleAdminStorageReplicationRun starts a replication job in the background.
+// Its progress is reported by the job list.
+func (s *Server) handleAdminStorageReplicationRun(w http.ResponseWriter, r *http.Request) {
+	if _, ok := auth.GetSessionFromContext(r); !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		storageJSONError(w, http.StatusBadRequest, "Replication ID required")
+		return
+	}
+	if _, err := zfsrepl.GetReplication(s.db, id); err != nil {
+		storageJSONError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if zfsrepl.IsRunning(id) {
+		storageJSONError(w, http.StatusConflict, zfsrepl.ErrRunning.Error())
+		return
+	}
+
+	go func() {
+		if err := zfsrepl.Run(s.db, id); err != nil && !errors.Is(err, zfsrepl.ErrRunning) {
+			logger.Info("Error running ZFS replication", "id", id, "error", err)
+		}
+	}()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": true})
+}
+
+// handleAdminStorageReplicas returns (GET) the receive base and the replicas
+// received from peers, or sets the receive base (PUT). Setting it requires
+// password verification, as it lets peers write datasets on this server.
+func (s *Server) handleAdminStorageReplicas(w http.ResponseWriter, r *http.Request) {
+	session, ok := auth.GetSessionFromContext(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		base, err := zfsrepl.GetReceiveBase(s.db)
+		if err != nil {
+			storageJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		replicas, err := zfsrepl.ListReplicas(s.db)
+		if err != nil {
+			logger.Info("Error listing ZFS replicas", "error", err)
+			storageJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"receive_base": base,
+			"replicas":     replicas,
+		})
+
+	case http.MethodPut:
+		var req struct {
+			ReceiveBase string `json:"receive_base"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			storageJSONError(w, http.StatusBadRequest, "Invalid request")
+			return
+		}
+
+		if err := s.validateVerificationToken(r, session); err != nil {
+			storageJSONError(w, http.StatusForbidden, "Password verification required")
+			return
+		}
+
+		if err := zfsrepl.SetReceiveBase(s.db, req.ReceiveBase); err != nil {
+			storageJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		logger.Info("ZFS receive base updated", "dataset", req.ReceiveBase, "user", session.Username)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true})
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,158 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+// This file contains the handlers of the pool monitor: scrub schedules and
+// history, and taking a disk offline before replacing it.
+
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/juste-un-gars/anemone/internal/auth"
+	"github.com/juste-un-gars/anemone/internal/logger"
+	"github.com/juste-un-gars/anemone/internal/poolmon"
+	"github.com/juste-un-gars/anemone/internal/storage"
+)
+
+// handleAdminStorageScrubSchedules lists (GET), creates or updates (PUT) and
+// deletes (DELETE ?pool=) the scrub schedules of the pools
+func (s *Server) handleAdminStorageScrubSchedules(w http.ResponseWriter, r *http.Request) {
+	session, ok := auth.GetSessionFromContext(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		schedules, err := poolmon.GetSchedules(s.db)
+		if err != nil {
+			logger.Info("Error listing scrub schedules", "error", err)
+			storageJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(schedules)
+
+	case http.MethodPut:
+		var req poolmon.Schedule
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			storageJSONError(w, http.StatusBadRequest, "Invalid request")
+			return
+		}
+		if err := req.Validate(); err != nil {
+			storageJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err := poolmon.SetSchedule(s.db, req); err != nil {
+			logger.Info("Error saving scrub schedule", "pool", req.Pool, "error", err)
+			storageJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		logger.Info("Admin saved scrub schedule", "username", session.Username, "pool", req.Pool,
+			"interval_days", req.IntervalDays, "hour", req.Hour, "enabled", req.Enabled)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true})
+
+	case http.MethodDelete:
+		pool := r.URL.Query().Get("pool")
+		if err := poolmon.DeleteSchedule(s.db, pool); err != nil {
+			storageJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		logger.Info("Admin deleted scrub schedule", "username", session.Username, "pool", pool)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true})
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleAdminStorageScrubHistory returns the current scan of a pool or md array
+// and its latest scrubs, checks and rebuilds (GET ?pool=)
+func (s *Server) handleAdminStorageScrubHistory(w http.ResponseWriter, r *http.Request) {
+	if _, ok := auth.GetSessionFromContext(r); !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	pool := r.URL.Query().Get("pool")
+	var scan *storage.ScanInfo
+	var err error
+	if storage.ValidateMDName(pool) == nil {
+		scan, err = storage.GetMDScan(pool)
+	} else {
+		scan, err = storage.GetPoolScan(pool)
+	}
+	if err != nil {
+		storageJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	history, err := poolmon.GetHistory(s.db, pool, 20)
+	if err != nil {
+		logger.Info("Error getting scrub history", "pool", pool, "error", err)
+		storageJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"scan":    scan,
+		"history": history,
+	})
+}
+
+// handleAdminStoragePoolOffline takes a disk of a pool offline, before pulling
+// it out to replace it (POST /api/admin/storage/pool-offline/{name})
+func (s *Server) handleAdminStoragePoolOffline(w http.ResponseWriter, r *http.Request) {
+	session, ok := auth.GetSessionFromContext(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	parts := splitPath(r.URL.Path)
+	if len(parts) < 5 {
+		http.Error(w, "Invalid path", http.StatusBadRequest)
+		return
+	}
+	poolName := parts[4]
+
+	var req struct {
+		Disk      string `json:"disk"`
+		Temporary bool   `json:"temporary"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		storageJSONError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+
+	if err := s.validateVerificationToken(r, session); err != nil {
+		storageJSONError(w, http.StatusForbidden, "Password verification required")
+		return
+	}
+
+	if err := storage.OfflineDisk(poolName, req.Disk, req.Temporary); err != nil {
+		logger.Info("Error taking disk offline", "pool_name", poolName, "disk", req.Disk, "error", err)
+		storageJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	logger.Info("Admin took disk offline", "username", session.Username, "pool", poolName, "disk", req.Disk)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"pool":    poolName,
+	})
+}
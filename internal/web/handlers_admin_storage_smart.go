@@ -0,0 +1,165 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+// This file contains the handlers of the SMART monitor: disk history, self-tests
+// and monitor settings.
+
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/juste-un-gars/anemone/internal/auth"
+	"github.com/juste-un-gars/anemone/internal/logger"
+	"github.com/juste-un-gars/anemone/internal/smartmon"
+	"github.com/juste-un-gars/anemone/internal/storage"
+)
+
+// findDisk returns the disk with the given device name
+func findDisk(name string) (*storage.Disk, error) {
+	disks, err := storage.ListDisks()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list disks: %w", err)
+	}
+	for _, d := range disks {
+		if d.Name == name {
+			return &d, nil
+		}
+	}
+	return nil, fmt.Errorf("disk not found: %s", name)
+}
+
+// handleAdminStorageSMARTHistory returns the samples and self-tests of a disk
+// (GET /api/admin/storage/smart/history?disk=sda&days=30)
+func (s *Server) handleAdminStorageSMARTHistory(w http.ResponseWriter, r *http.Request) {
+	if _, ok := auth.GetSessionFromContext(r); !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	disk, err := findDisk(r.URL.Query().Get("disk"))
+	if err != nil {
+		storageJSONError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	days, err := strconv.Atoi(r.URL.Query().Get("days"))
+	if err != nil || days < 1 || days > 180 {
+		days = 30
+	}
+
+	key := smartmon.DiskKey(*disk)
+	samples, err := smartmon.GetHistory(s.db, key, time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		logger.Info("Error getting SMART history", "disk", disk.Name, "error", err)
+		storageJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	tests, err := smartmon.GetSelfTests(s.db, key, 10)
+	if err != nil {
+		logger.Info("Error getting self-tests", "disk", disk.Name, "error", err)
+		storageJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"samples":   samples,
+		"selftests": tests,
+	})
+}
+
+// handleAdminStorageSMARTSelfTest starts a short or long self-test on a disk
+// (POST /api/admin/storage/smart/selftest)
+func (s *Server) handleAdminStorageSMARTSelfTest(w http.ResponseWriter, r *http.Request) {
+	session, ok := auth.GetSessionFromContext(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Disk string `json:"disk"`
+		Type string `json:"type"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		storageJSONError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+	if req.Type != storage.SelfTestShort && req.Type != storage.SelfTestLong {
+		storageJSONError(w, http.StatusBadRequest, "Invalid self-test type")
+		return
+	}
+	disk, err := findDisk(req.Disk)
+	if err != nil {
+		storageJSONError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	test, err := smartmon.StartSelfTest(s.db, *disk, req.Type, false)
+	if err != nil {
+		logger.Info("Error starting self-test", "disk", disk.Name, "type", req.Type, "error", err)
+		storageJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	logger.Info("Admin started SMART self-test", "username", session.Username, "disk", disk.Name, "type", req.Type)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":  true,
+		"selftest": test,
+	})
+}
+
+// handleAdminStorageSMARTSettings returns (GET) and updates (PUT) the schedule
+// and thresholds of the SMART monitor
+func (s *Server) handleAdminStorageSMARTSettings(w http.ResponseWriter, r *http.Request) {
+	session, ok := auth.GetSessionFromContext(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		settings, err := smartmon.GetSettings(s.db)
+		if err != nil {
+			storageJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(settings)
+
+	case http.MethodPut:
+		var settings smartmon.Settings
+		if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+			storageJSONError(w, http.StatusBadRequest, "Invalid request")
+			return
+		}
+		if err := settings.Validate(); err != nil {
+			storageJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err := smartmon.SetSettings(s.db, settings); err != nil {
+			logger.Info("Error saving SMART monitor settings", "error", err)
+			storageJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		logger.Info("Admin updated SMART monitor settings", "username", session.Username, "settings", settings)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true})
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
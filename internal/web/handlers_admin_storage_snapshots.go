@@ -0,0 +1,152 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+// This file contains the handlers of the scheduled snapshot policies and of the
+// shares stored in Btrfs subvolumes.
+
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/juste-un-gars/anemone/internal/auth"
+	"github.com/juste-un-gars/anemone/internal/logger"
+	"github.com/juste-un-gars/anemone/internal/snapshots"
+)
+
+// storageJSONError writes an error in the {"error": ...} format the storage page expects
+func storageJSONError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
+
+// handleAdminStorageSnapshotPolicies lists (GET), creates (POST), updates (PUT)
+// and deletes (DELETE) snapshot policies. Creating and updating a policy require
+// password verification, as the retention counts decide which snapshots are pruned.
+func (s *Server) handleAdminStorageSnapshotPolicies(w http.ResponseWriter, r *http.Request) {
+	session, ok := auth.GetSessionFromContext(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		policies, err := snapshots.GetPolicies(s.db)
+		if err != nil {
+			logger.Info("Error listing snapshot policies", "error", err)
+			storageJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if policies == nil {
+			policies = []*snapshots.Policy{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(policies)
+
+	case http.MethodPost, http.MethodPut:
+		var req snapshots.Policy
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			storageJSONError(w, http.StatusBadRequest, "Invalid request")
+			return
+		}
+
+		if err := s.validateVerificationToken(r, session); err != nil {
+			storageJSONError(w, http.StatusForbidden, "Password verification required")
+			return
+		}
+
+		var err error
+		if r.Method == http.MethodPost {
+			err = snapshots.CreatePolicy(s.db, &req)
+		} else {
+			err = snapshots.UpdatePolicy(s.db, &req)
+		}
+		if err != nil {
+			logger.Info("Error saving snapshot policy", "dataset", req.Dataset, "error", err)
+			storageJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"policy":  req,
+		})
+
+	case http.MethodDelete:
+		id, err := strconv.Atoi(r.URL.Query().Get("id"))
+		if err != nil {
+			storageJSONError(w, http.StatusBadRequest, "Policy ID required")
+			return
+		}
+		if err := snapshots.DeletePolicy(s.db, id); err != nil {
+			logger.Info("Error deleting snapshot policy", "id", id, "error", err)
+			storageJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true})
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleAdminStorageSnapshotPolicyRun applies a snapshot policy now instead of
+// waiting for the scheduler
+func (s *Server) handleAdminStorageSnapshotPolicyRun(w http.ResponseWriter, r *http.Request) {
+	if _, ok := auth.GetSessionFromContext(r); !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		storageJSONError(w, http.StatusBadRequest, "Policy ID required")
+		return
+	}
+	policy, err := snapshots.GetPolicy(s.db, id)
+	if err != nil {
+		storageJSONError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	if err := snapshots.RunPolicy(s.db, policy); err != nil {
+		logger.Info("Error running snapshot policy", "id", id, "error", err)
+		storageJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": true})
+}
+
+// handleAdminStorageSubvolumes lists the shares stored in Btrfs subvolumes, with
+// their qgroup usage
+func (s *Server) handleAdminStorageSubvolumes(w http.ResponseWriter, r *http.Request) {
+	if _, ok := auth.GetSessionFromContext(r); !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	volumes, err := snapshots.ListVolumes(s.db)
+	if err != nil {
+		logger.Info("Error listing Btrfs subvolumes", "error", err)
+		storageJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(volumes)
+}
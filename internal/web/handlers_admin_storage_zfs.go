@@ -3,6 +3,7 @@
 // Licensed under the GNU Affero General Public License v3.0
 
 // This file contains ZFS-related handlers: pools, datasets, and snapshots.
+// Snapshot handlers also serve shares stored in Btrfs subvolumes.
 
 package web
 
@@ -11,7 +12,9 @@ import (
 	"net/http"
 
 	"github.com/juste-un-gars/anemone/internal/auth"
+	"github.com/juste-un-gars/anemone/internal/diskcrypt"
 	"github.com/juste-un-gars/anemone/internal/logger"
+	"github.com/juste-un-gars/anemone/internal/snapshots"
 	"github.com/juste-un-gars/anemone/internal/storage"
 )
 
@@ -30,7 +33,10 @@ func (s *Server) handleAdminStoragePoolCreate(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	var req storage.PoolCreateOptions
+	var req struct {
+		storage.PoolCreateOptions
+		Encryption encryptionRequest `json:"encryption"`
+	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusBadRequest)
@@ -46,7 +52,14 @@ func (s *Server) handleAdminStoragePoolCreate(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	if err := storage.CreatePool(req); err != nil {
+	key, err := req.Encryption.newKey()
+	if err != nil {
+		storageJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	req.EncryptionKey = key
+
+	if err := storage.CreatePool(req.PoolCreateOptions); err != nil {
 		logger.Info("Error creating pool", "error", err)
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusInternalServerError)
@@ -54,6 +67,14 @@ func (s *Server) handleAdminStoragePoolCreate(w http.ResponseWriter, r *http.Req
 		return
 	}
 
+	if key != "" {
+		volume := diskcrypt.Volume{Kind: diskcrypt.KindZFS, Name: req.Name, Mountpoint: req.Mountpoint}
+		if err := s.registerVolume(volume, key, req.Encryption); err != nil {
+			storageJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"success": true,
@@ -121,7 +142,7 @@ func (s *Server) handleAdminStoragePoolExport(w http.ResponseWriter, r *http.Req
 	}
 
 	parts := splitPath(r.URL.Path)
-	if len(parts) < 6 {
+	if len(parts) < 5 {
 		http.Error(w, "Invalid path", http.StatusBadRequest)
 		return
 	}
@@ -235,7 +256,7 @@ func (s *Server) handleAdminStoragePoolAddVDev(w http.ResponseWriter, r *http.Re
 	}
 
 	parts := splitPath(r.URL.Path)
-	if len(parts) < 6 {
+	if len(parts) < 5 {
 		http.Error(w, "Invalid path", http.StatusBadRequest)
 		return
 	}
@@ -297,7 +318,7 @@ func (s *Server) handleAdminStoragePoolReplace(w http.ResponseWriter, r *http.Re
 	}
 
 	parts := splitPath(r.URL.Path)
-	if len(parts) < 6 {
+	if len(parts) < 5 {
 		http.Error(w, "Invalid path", http.StatusBadRequest)
 		return
 	}
@@ -360,7 +381,10 @@ func (s *Server) handleAdminStorageDatasetCreate(w http.ResponseWriter, r *http.
 		return
 	}
 
-	var req storage.DatasetCreateOptions
+	var req struct {
+		storage.DatasetCreateOptions
+		Encryption encryptionRequest `json:"encryption"`
+	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusBadRequest)
@@ -376,7 +400,14 @@ func (s *Server) handleAdminStorageDatasetCreate(w http.ResponseWriter, r *http.
 		return
 	}
 
-	if err := storage.CreateDataset(req); err != nil {
+	key, err := req.Encryption.newKey()
+	if err != nil {
+		storageJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	req.EncryptionKey = key
+
+	if err := storage.CreateDataset(req.DatasetCreateOptions); err != nil {
 		logger.Info("Error creating dataset", "error", err)
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusInternalServerError)
@@ -384,6 +415,14 @@ func (s *Server) handleAdminStorageDatasetCreate(w http.ResponseWriter, r *http.
 		return
 	}
 
+	if key != "" {
+		volume := diskcrypt.Volume{Kind: diskcrypt.KindZFS, Name: req.Name, Mountpoint: req.Mountpoint}
+		if err := s.registerVolume(volume, key, req.Encryption); err != nil {
+			storageJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"success": true,
@@ -544,7 +583,7 @@ func (s *Server) handleAdminStorageSnapshotCreate(w http.ResponseWriter, r *http
 	// No password verification needed for creating snapshots (non-destructive)
 	_ = session
 
-	if err := storage.CreateSnapshot(req); err != nil {
+	if err := snapshots.CreateSnapshot(s.db, req); err != nil {
 		logger.Info("Error creating snapshot", "error", err)
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusInternalServerError)
@@ -591,7 +630,7 @@ func (s *Server) handleAdminStorageSnapshotDelete(w http.ResponseWriter, r *http
 	recursive := r.URL.Query().Get("recursive") == "true"
 	force := r.URL.Query().Get("force") == "true"
 
-	if err := storage.DeleteSnapshot(name, recursive, force); err != nil {
+	if err := snapshots.DeleteSnapshot(s.db, name, recursive, force); err != nil {
 		logger.Info("Error deleting snapshot", "name", name, "error", err)
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusInternalServerError)
@@ -616,14 +655,8 @@ func (s *Server) handleAdminStorageSnapshotList(w http.ResponseWriter, r *http.R
 
 	dataset := r.URL.Query().Get("dataset")
 
-	var snapshots []storage.Snapshot
-	var err error
-
-	if dataset != "" {
-		snapshots, err = storage.ListSnapshots(dataset)
-	} else {
-		snapshots, err = storage.ListAllSnapshots()
-	}
+	// Lists both ZFS and Btrfs snapshots when no dataset is given
+	list, err := snapshots.ListSnapshots(s.db, dataset)
 
 	if err != nil {
 		logger.Info("Error listing snapshots", "error", err)
@@ -634,7 +667,7 @@ func (s *Server) handleAdminStorageSnapshotList(w http.ResponseWriter, r *http.R
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(snapshots)
+	json.NewEncoder(w).Encode(list)
 }
 
 // handleAdminStorageSnapshotRollback rolls back to a snapshot
@@ -666,7 +699,7 @@ func (s *Server) handleAdminStorageSnapshotRollback(w http.ResponseWriter, r *ht
 		return
 	}
 
-	if err := storage.Rollback(req); err != nil {
+	if err := snapshots.Rollback(s.db, req); err != nil {
 		logger.Info("Error rolling back to snapshot", "snapshot", req.Snapshot, "error", err)
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusInternalServerError)
@@ -56,9 +56,11 @@ func (s *Server) handleAdminSync(w http.ResponseWriter, r *http.Request) {
 	}
 
 	query := `
-		SELECT u.username, p.name, sl.started_at, sl.completed_at, sl.status, sl.files_synced, sl.bytes_synced
+		SELECT COALESCE(u.username, '@' || g.name || '/' || gs.name, '?'), p.name, sl.started_at, sl.completed_at, sl.status, sl.files_synced, sl.bytes_synced
 		FROM sync_log sl
-		JOIN users u ON sl.user_id = u.id
+		LEFT JOIN users u ON sl.user_id = u.id
+		LEFT JOIN group_shares gs ON sl.group_share_id = gs.id
+		LEFT JOIN groups g ON gs.group_id = g.id
 		JOIN peers p ON sl.peer_id = p.id
 		ORDER BY sl.started_at DESC
 		LIMIT 20
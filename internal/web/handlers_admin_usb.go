@@ -8,7 +8,6 @@ import (
 	"encoding/json"
 	"github.com/juste-un-gars/anemone/internal/logger"
 	"net/http"
-	"path/filepath"
 	"strconv"
 	"strings"
 
@@ -287,6 +286,13 @@ func (s *Server) handleAdminUSBBackupAdd(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	// Register the drive now if it is plugged in, so hotplug can recognise it
+	if backup.IsMounted() {
+		if _, err := usbbackup.AddMountedDrive(s.db, backup); err != nil {
+			logger.Info("Could not identify USB backup drive", "name", backup.Name, "error", err)
+		}
+	}
+
 	http.Redirect(w, r, "/admin/backups", http.StatusSeeOther)
 }
 
@@ -319,6 +325,10 @@ func (s *Server) handleAdminUSBBackupActions(w http.ResponseWriter, r *http.Requ
 		s.handleUSBBackupSync(w, r, id)
 	case "edit":
 		s.handleUSBBackupEdit(w, r, id)
+	case "drive-add", "drive-rename", "drive-remove":
+		s.handleUSBBackupDrive(w, r, id, action)
+	case "passphrase":
+		s.handleUSBBackupPassphrase(w, r, id)
 	default:
 		// Show edit form
 		s.handleUSBBackupEditForm(w, r, id)
@@ -376,42 +386,14 @@ func (s *Server) handleUSBBackupSync(w http.ResponseWriter, r *http.Request, id
 		return
 	}
 
-	// Get data directory for config backup
-	dataDir := s.cfg.DataDir
+	if usbbackup.IsRunning(backup.ID) {
+		http.Redirect(w, r, "/admin/usb-backup?error="+i18n.T(lang, "usb_backup.sync_running"), http.StatusSeeOther)
+		return
+	}
 
 	// Run sync in background
 	go func() {
-		var result *usbbackup.SyncResult
-		var syncErr error
-
-		if backup.BackupType == usbbackup.BackupTypeConfig {
-			// Config-only backup
-			configInfo := &usbbackup.ConfigBackupInfo{
-				DataDir:  dataDir,
-				DBPath:   filepath.Join(dataDir, "db", "anemone.db"),
-				CertsDir: filepath.Join(dataDir, "certs"),
-				SMBConf:  filepath.Join(dataDir, "smb", "smb.conf"),
-			}
-			result, syncErr = usbbackup.SyncConfig(s.db, backup, configInfo, masterKey, serverName)
-		} else {
-			// Full backup (config + data)
-			// First backup config
-			configInfo := &usbbackup.ConfigBackupInfo{
-				DataDir:  dataDir,
-				DBPath:   filepath.Join(dataDir, "db", "anemone.db"),
-				CertsDir: filepath.Join(dataDir, "certs"),
-				SMBConf:  filepath.Join(dataDir, "smb", "smb.conf"),
-			}
-			configResult, _ := usbbackup.SyncConfig(s.db, backup, configInfo, masterKey, serverName)
-
-			// Then backup selected shares
-			result, syncErr = usbbackup.SyncAllShares(s.db, backup, masterKey, serverName)
-			if result != nil && configResult != nil {
-				result.FilesAdded += configResult.FilesAdded
-				result.BytesSynced += configResult.BytesSynced
-			}
-		}
-
+		result, syncErr := usbbackup.RunBackup(s.db, backup, s.cfg.DataDir, masterKey, serverName)
 		if syncErr != nil {
 			logger.Info("USB backup sync error", "sync_err", syncErr)
 		} else if result != nil {
@@ -481,10 +463,24 @@ func (s *Server) handleUSBBackupEditForm(w http.ResponseWriter, r *http.Request,
 		sharesWithUsers = append(sharesWithUsers, swu)
 	}
 
+	// Rotation set, and the mounted drive if it is not part of it yet
+	drives, err := usbbackup.RotationState(s.db, backup)
+	if err != nil {
+		logger.Info("Error getting USB backup drives", "error", err)
+	}
+	var unknownDrive *usbbackup.BlockDevice
+	if member, dev, err := usbbackup.ConnectedDrive(s.db, backup); err == nil && dev != nil && member == nil {
+		unknownDrive = dev
+	}
+
 	data := struct {
 		V2TemplateData
-		Backup    *usbbackup.USBBackup
-		AllShares []ShareWithUser
+		Backup       *usbbackup.USBBackup
+		AllShares    []ShareWithUser
+		Drives       []usbbackup.DriveState
+		UnknownDrive *usbbackup.BlockDevice
+		Success      string
+		Error        string
 	}{
 		V2TemplateData: V2TemplateData{
 			Lang:       lang,
@@ -492,8 +488,12 @@ func (s *Server) handleUSBBackupEditForm(w http.ResponseWriter, r *http.Request,
 			ActivePage: "backups",
 			Session:    session,
 		},
-		Backup:    backup,
-		AllShares: sharesWithUsers,
+		Backup:       backup,
+		AllShares:    sharesWithUsers,
+		Drives:       drives,
+		UnknownDrive: unknownDrive,
+		Success:      r.URL.Query().Get("success"),
+		Error:        r.URL.Query().Get("error"),
 	}
 
 	tmpl := s.loadV2Page("v2_usb_backup_edit.html", s.funcMap)
@@ -524,6 +524,11 @@ func (s *Server) handleUSBBackupEdit(w http.ResponseWriter, r *http.Request, id
 	backup.BackupType = strings.TrimSpace(r.FormValue("backup_type"))
 	backup.Enabled = r.FormValue("enabled") == "on"
 	backup.AutoDetect = r.FormValue("auto_detect") == "on"
+	backup.VerifyWrites = r.FormValue("verify_writes") == "on"
+	backup.RPOHours = 0
+	if rpo, err := strconv.Atoi(r.FormValue("rpo_hours")); err == nil && rpo > 0 {
+		backup.RPOHours = rpo
+	}
 
 	// Schedule fields
 	backup.SyncEnabled = r.FormValue("sync_enabled") == "on"
@@ -592,6 +597,96 @@ func (s *Server) handleUSBBackupEdit(w http.ResponseWriter, r *http.Request, id
 	http.Redirect(w, r, "/admin/usb-backup?updated=1", http.StatusSeeOther)
 }
 
+// handleUSBBackupDrive adds the mounted drive to the rotation set, or renames or removes a drive
+func (s *Server) handleUSBBackupDrive(w http.ResponseWriter, r *http.Request, id int, action string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	lang := s.getLang(r)
+	editURL := "/admin/usb-backup/" + strconv.Itoa(id)
+
+	backup, err := usbbackup.GetByID(s.db, id)
+	if err != nil {
+		http.Redirect(w, r, "/admin/usb-backup?error=not_found", http.StatusSeeOther)
+		return
+	}
+	driveID, _ := strconv.Atoi(r.FormValue("drive_id"))
+
+	switch action {
+	case "drive-add":
+		var drive *usbbackup.Drive
+		drive, err = usbbackup.AddMountedDrive(s.db, backup)
+		if err == nil {
+			logger.Info("USB backup drive added to rotation set", "name", backup.Name, "drive", drive.Name, "uuid", drive.FsUUID)
+		}
+	case "drive-rename":
+		err = usbbackup.RenameDrive(s.db, id, driveID, r.FormValue("name"))
+	case "drive-remove":
+		err = usbbackup.RemoveDrive(s.db, id, driveID)
+		if err == nil {
+			logger.Info("USB backup drive removed from rotation set", "name", backup.Name, "drive_id", driveID)
+		}
+	}
+	if err != nil {
+		logger.Info("Error updating USB backup rotation set", "action", action, "error", err)
+		http.Redirect(w, r, editURL+"?error="+i18n.T(lang, "usb_backup.rotation.error"), http.StatusSeeOther)
+		return
+	}
+
+	http.Redirect(w, r, editURL+"?success="+i18n.T(lang, "usb_backup.rotation.updated"), http.StatusSeeOther)
+}
+
+// handleUSBBackupPassphrase sets or changes the passphrase protecting the drive key
+func (s *Server) handleUSBBackupPassphrase(w http.ResponseWriter, r *http.Request, id int) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	session, _ := auth.GetSessionFromContext(r)
+	lang := s.getLang(r)
+	editURL := "/admin/usb-backup/" + strconv.Itoa(id)
+
+	backup, err := usbbackup.GetByID(s.db, id)
+	if err != nil {
+		http.Redirect(w, r, "/admin/usb-backup?error=not_found", http.StatusSeeOther)
+		return
+	}
+
+	passphrase := r.FormValue("passphrase")
+	if len(passphrase) < usbbackup.MinPassphraseLength {
+		http.Redirect(w, r, editURL+"?error="+i18n.T(lang, "usb_backup.passphrase.too_short"), http.StatusSeeOther)
+		return
+	}
+	if passphrase != r.FormValue("passphrase_confirm") {
+		http.Redirect(w, r, editURL+"?error="+i18n.T(lang, "usb_backup.passphrase.mismatch"), http.StatusSeeOther)
+		return
+	}
+
+	var masterKey string
+	if err := s.db.QueryRow("SELECT value FROM system_config WHERE key = 'master_key'").Scan(&masterKey); err != nil {
+		logger.Info("Error getting master key", "error", err)
+		http.Redirect(w, r, editURL+"?error=internal_error", http.StatusSeeOther)
+		return
+	}
+
+	serverName, _ := sync.GetServerName(s.db)
+	if serverName == "" {
+		serverName = "anemone"
+	}
+
+	if err := usbbackup.SetPassphrase(s.db, backup, masterKey, passphrase, usbbackup.GetServerIdentity(s.db, serverName)); err != nil {
+		logger.Info("Error setting USB drive passphrase", "id", id, "error", err)
+		http.Redirect(w, r, editURL+"?error="+i18n.T(lang, "usb_backup.passphrase.error"), http.StatusSeeOther)
+		return
+	}
+
+	logger.Info("Admin set USB drive passphrase", "username", session.Username, "backup", backup.Name)
+	http.Redirect(w, r, editURL+"?success="+i18n.T(lang, "usb_backup.passphrase.saved"), http.StatusSeeOther)
+}
+
 // handleAdminUSBBackupAPI provides JSON API for USB backup status
 func (s *Server) handleAdminUSBBackupAPI(w http.ResponseWriter, r *http.Request) {
 	backups, err := usbbackup.GetAll(s.db)
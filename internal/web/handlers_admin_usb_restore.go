@@ -0,0 +1,324 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+// This file contains handlers for restoring shares and configuration from USB backup drives.
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/juste-un-gars/anemone/internal/auth"
+	"github.com/juste-un-gars/anemone/internal/i18n"
+	"github.com/juste-un-gars/anemone/internal/logger"
+	"github.com/juste-un-gars/anemone/internal/shares"
+	"github.com/juste-un-gars/anemone/internal/usbbackup"
+	"github.com/juste-un-gars/anemone/internal/users"
+)
+
+// handleAdminUSBRestore shows the USB restore page
+func (s *Server) handleAdminUSBRestore(w http.ResponseWriter, r *http.Request) {
+	session, ok := auth.GetSessionFromContext(r)
+	if !ok {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	lang := s.getLang(r)
+
+	data := struct {
+		V2TemplateData
+		HasRecoveryPassphrase bool
+		Success               string
+		Error                 string
+	}{
+		V2TemplateData: V2TemplateData{
+			Lang:       lang,
+			Title:      i18n.T(lang, "usb_restore.title"),
+			ActivePage: "backups",
+			Session:    session,
+		},
+		HasRecoveryPassphrase: usbbackup.HasRecoveryPassphrase(s.db),
+		Success:               r.URL.Query().Get("success"),
+		Error:                 r.URL.Query().Get("error"),
+	}
+
+	tmpl := s.loadV2Page("v2_usb_restore.html", s.funcMap)
+	if err := tmpl.ExecuteTemplate(w, "v2_base", data); err != nil {
+		logger.Info("Template error", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
+
+// handleAdminUSBRecovery sets the recovery passphrase copied to USB drives
+func (s *Server) handleAdminUSBRecovery(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	session, _ := auth.GetSessionFromContext(r)
+	lang := s.getLang(r)
+
+	passphrase := r.FormValue("passphrase")
+	if len(passphrase) < 12 {
+		http.Redirect(w, r, "/admin/usb-backup/restore?error="+i18n.T(lang, "usb_restore.recovery.too_short"), http.StatusSeeOther)
+		return
+	}
+	if passphrase != r.FormValue("passphrase_confirm") {
+		http.Redirect(w, r, "/admin/usb-backup/restore?error="+i18n.T(lang, "usb_restore.recovery.mismatch"), http.StatusSeeOther)
+		return
+	}
+
+	masterKey, err := s.getMasterKey()
+	if err != nil {
+		logger.Info("Error getting master key", "error", err)
+		http.Redirect(w, r, "/admin/usb-backup/restore?error=internal_error", http.StatusSeeOther)
+		return
+	}
+	if err := usbbackup.SetRecoveryPassphrase(s.db, masterKey, passphrase); err != nil {
+		logger.Info("Error setting USB recovery passphrase", "error", err)
+		http.Redirect(w, r, "/admin/usb-backup/restore?error=internal_error", http.StatusSeeOther)
+		return
+	}
+
+	logger.Info("Admin set USB recovery passphrase", "username", session.Username)
+	http.Redirect(w, r, "/admin/usb-backup/restore?success="+i18n.T(lang, "usb_restore.recovery.saved"), http.StatusSeeOther)
+}
+
+// handleAdminUSBRestoreAPI serves the USB restore JSON API
+// GET  /api/admin/usb-restore/scan                   - Anemone backups on mounted drives
+// POST /api/admin/usb-restore/unlock                 - inspect a backup with another key (path, key)
+// POST /api/admin/usb-restore/files                  - files of a share backup (path, share, key)
+// POST /api/admin/usb-restore/start                  - restore (path, share, paths, target_dir, overwrite, key)
+// POST /api/admin/usb-restore/config                 - extract the configuration backup (path, key)
+// GET  /api/admin/usb-restore/jobs                   - restore jobs
+func (s *Server) handleAdminUSBRestoreAPI(w http.ResponseWriter, r *http.Request) {
+	action := strings.TrimPrefix(r.URL.Path, "/api/admin/usb-restore/")
+
+	switch action {
+	case "scan":
+		masterKey, err := s.getMasterKey()
+		if err != nil {
+			jsonError(w, "Failed to get master key", http.StatusInternalServerError)
+			return
+		}
+		found, err := usbbackup.FindBackups(masterKey)
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if found == nil {
+			found = []usbbackup.DriveBackup{}
+		}
+		for i := range found {
+			// Drives with their own passphrase are readable with the key of their USB backup
+			if found[i].Header != nil {
+				if key := usbbackup.KnownDriveKey(s.db, found[i].Path, masterKey); key != masterKey {
+					if b, err := usbbackup.InspectBackup(found[i].Path, key); err == nil {
+						found[i].Shares = b.Shares
+					}
+				}
+			}
+			s.fillShareUsernames(found[i].Shares)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(found)
+
+	case "unlock":
+		path, masterKey, err := s.usbRestoreKey(r)
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		backup, err := usbbackup.InspectBackup(path, masterKey)
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		s.fillShareUsernames(backup.Shares)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(backup)
+
+	case "files":
+		path, masterKey, err := s.usbRestoreKey(r)
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		files, err := usbbackup.ListFiles(path, r.FormValue("share"), masterKey)
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(files)
+
+	case "start":
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s.handleUSBRestoreStart(w, r)
+
+	case "config":
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		path, masterKey, err := s.usbRestoreKey(r)
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		destDir := filepath.Join(s.cfg.DataDir, "backups", "usb-restore", time.Now().Format("20060102_150405"))
+		count, err := usbbackup.RestoreConfig(path, masterKey, destDir)
+		if err != nil {
+			logger.Info("Error extracting USB config backup", "path", path, "error", err)
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		logger.Info("USB config backup extracted", "path", path, "dest", destDir, "files", count)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"dest":    destDir,
+			"files":   count,
+		})
+
+	case "jobs":
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(usbbackup.ListRestoreJobs())
+
+	default:
+		http.Error(w, "Not found", http.StatusNotFound)
+	}
+}
+
+// handleUSBRestoreStart restores files of a share backup into the original share
+func (s *Server) handleUSBRestoreStart(w http.ResponseWriter, r *http.Request) {
+	session, _ := auth.GetSessionFromContext(r)
+
+	path, masterKey, err := s.usbRestoreKey(r)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	backup, err := usbbackup.InspectBackup(path, masterKey)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	shareDir := r.FormValue("share")
+	var info *usbbackup.ShareBackupInfo
+	for i := range backup.Shares {
+		if backup.Shares[i].Dir == shareDir && !backup.Shares[i].Locked {
+			info = &backup.Shares[i]
+			break
+		}
+	}
+	if info == nil {
+		jsonError(w, "Share backup not found", http.StatusBadRequest)
+		return
+	}
+
+	targetDir, owner, err := s.usbRestoreTarget(info, r.FormValue("target_dir"))
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	job, err := usbbackup.StartRestore(usbbackup.RestoreRequest{
+		BackupPath: path,
+		ShareDir:   shareDir,
+		Paths:      r.Form["paths"],
+		TargetDir:  targetDir,
+		Owner:      owner,
+		Overwrite:  r.FormValue("overwrite") == "on",
+		MasterKey:  masterKey,
+	})
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	logger.Info("Admin started USB restore", "username", session.Username, "path", path, "share", shareDir, "target", targetDir)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"job":     job,
+	})
+}
+
+// usbRestoreKey validates the backup path of a request and resolves its drive key.
+// Without a key in the request, the key known by this server is used.
+func (s *Server) usbRestoreKey(r *http.Request) (string, string, error) {
+	path := filepath.Clean(r.FormValue("path"))
+	if !usbbackup.IsBackupPath(path) {
+		return "", "", fmt.Errorf("no Anemone backup at %s", path)
+	}
+
+	if secret := r.FormValue("key"); secret != "" {
+		key, err := usbbackup.ResolveDriveKey(path, secret)
+		return path, key, err
+	}
+
+	masterKey, err := s.getMasterKey()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get master key")
+	}
+	return path, usbbackup.KnownDriveKey(s.db, path, masterKey), nil
+}
+
+// usbRestoreTarget returns the original share directory (optionally a subfolder) and its owner
+func (s *Server) usbRestoreTarget(info *usbbackup.ShareBackupInfo, subDir string) (string, string, error) {
+	owner, err := users.GetByID(s.db, info.UserID)
+	if err != nil {
+		return "", "", fmt.Errorf("user #%d does not exist on this server", info.UserID)
+	}
+	userShares, err := shares.GetByUser(s.db, info.UserID)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get user shares: %w", err)
+	}
+
+	root := ""
+	for _, sh := range userShares {
+		if sh.Name == info.ShareName {
+			root = sh.Path
+			break
+		}
+	}
+	if root == "" {
+		return "", "", fmt.Errorf("share %s does not exist on this server", info.ShareName)
+	}
+
+	subDir = strings.Trim(strings.TrimSpace(subDir), "/")
+	if subDir == "" {
+		return root, owner.Username, nil
+	}
+	target := filepath.Join(root, filepath.Clean(subDir))
+	if !strings.HasPrefix(target, filepath.Clean(root)+string(filepath.Separator)) {
+		return "", "", fmt.Errorf("invalid target directory")
+	}
+	return target, owner.Username, nil
+}
+
+// fillShareUsernames resolves the local username of each share backup
+func (s *Server) fillShareUsernames(list []usbbackup.ShareBackupInfo) {
+	for i := range list {
+		if u, err := users.GetByID(s.db, list[i].UserID); err == nil {
+			list[i].Username = u.Username
+		}
+	}
+}
+
+// getMasterKey returns the server master key
+func (s *Server) getMasterKey() (string, error) {
+	var masterKey string
+	err := s.db.QueryRow("SELECT value FROM system_config WHERE key = 'master_key'").Scan(&masterKey)
+	return masterKey, err
+}
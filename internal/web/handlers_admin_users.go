@@ -390,6 +390,7 @@ func (s *Server) handleAdminUsersActions(w http.ResponseWriter, r *http.Request)
 			http.Error(w, "Failed to delete user", http.StatusInternalServerError)
 			return
 		}
+		forgetDAVAuth(userID)
 
 		logger.Info("User deleted by admin", "user_id", userID, "username", session.Username)
 		w.WriteHeader(http.StatusOK)
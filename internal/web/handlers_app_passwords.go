@@ -0,0 +1,76 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+// This file contains the handlers of the app passwords of the user settings.
+
+package web
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/juste-un-gars/anemone/internal/auth"
+	"github.com/juste-un-gars/anemone/internal/i18n"
+	"github.com/juste-un-gars/anemone/internal/logger"
+	"github.com/juste-un-gars/anemone/internal/users"
+)
+
+// handleSettingsAppPasswords creates an app password (POST /settings/app-passwords).
+// The settings page is rendered directly, the only time the password is shown.
+func (s *Server) handleSettingsAppPasswords(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	session, _ := auth.GetSessionFromContext(r)
+
+	if err := r.ParseForm(); err != nil {
+		http.Redirect(w, r, "/settings?error=Invalid+form+data", http.StatusSeeOther)
+		return
+	}
+
+	appPassword, password, err := users.CreateAppPassword(s.db, session.UserID, r.FormValue("name"))
+	if err != nil {
+		logger.Info("Error creating app password", "user_id", session.UserID, "error", err)
+		http.Redirect(w, r, "/settings?error="+url.QueryEscape(err.Error()), http.StatusSeeOther)
+		return
+	}
+
+	logger.Info("User created app password", "username", session.Username, "name", appPassword.Name)
+	w.Header().Set("Cache-Control", "no-store")
+	s.renderSettings(w, r, session, appPassword, password)
+}
+
+// handleSettingsAppPasswordsDelete revokes an app password
+// (POST /settings/app-passwords/{id}/delete)
+func (s *Server) handleSettingsAppPasswordsDelete(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	session, _ := auth.GetSessionFromContext(r)
+
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/settings/app-passwords/"), "/")
+	if len(parts) != 2 || parts[1] != "delete" {
+		http.NotFound(w, r)
+		return
+	}
+	id, err := strconv.Atoi(parts[0])
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	if err := users.DeleteAppPassword(s.db, session.UserID, id); err != nil {
+		logger.Info("Error revoking app password", "user_id", session.UserID, "id", id, "error", err)
+		http.Redirect(w, r, "/settings?error="+url.QueryEscape(err.Error()), http.StatusSeeOther)
+		return
+	}
+	forgetDAVAuth(session.UserID)
+
+	logger.Info("User revoked app password", "username", session.Username, "id", id)
+	http.Redirect(w, r, "/settings?success="+url.QueryEscape(i18n.T(s.getLang(r), "settings.app_passwords.revoked")), http.StatusSeeOther)
+}
@@ -700,6 +700,7 @@ func (s *Server) handleResetPasswordSubmit(w http.ResponseWriter, r *http.Reques
 		http.Redirect(w, r, fmt.Sprintf("/reset-password?token=%s&error=Failed+to+reset+password", tokenString), http.StatusSeeOther)
 		return
 	}
+	forgetDAVAuth(user.ID)
 
 	logger.Info("Password reset successfully for user", "username", user.Username)
 
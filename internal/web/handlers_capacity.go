@@ -0,0 +1,217 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+// This file contains the handlers of the storage usage history and of the
+// capacity forecasts, for the admins (every series) and the users (their own).
+
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/juste-un-gars/anemone/internal/auth"
+	"github.com/juste-un-gars/anemone/internal/capacity"
+	"github.com/juste-un-gars/anemone/internal/logger"
+)
+
+// historyDays reads the days query parameter of a history request
+func historyDays(r *http.Request) int {
+	days, err := strconv.Atoi(r.URL.Query().Get("days"))
+	if err != nil || days < 1 || days > 730 {
+		return 30
+	}
+	return days
+}
+
+// sparklinePoints returns the points of an SVG polyline of width x height
+// drawing the usage of samples, scaled between its lowest and highest values
+func sparklinePoints(samples []capacity.Sample, width, height int) string {
+	if len(samples) == 0 {
+		return ""
+	}
+	lo, hi := samples[0].Used, samples[0].Used
+	for _, sample := range samples {
+		if sample.Used < lo {
+			lo = sample.Used
+		}
+		if sample.Used > hi {
+			hi = sample.Used
+		}
+	}
+	start := samples[0].Time
+	span := samples[len(samples)-1].Time.Sub(start).Seconds()
+
+	points := make([]string, 0, len(samples))
+	for _, sample := range samples {
+		x, y := 0.0, float64(height)/2
+		if span > 0 {
+			x = sample.Time.Sub(start).Seconds() / span * float64(width)
+		}
+		if hi > lo {
+			y = float64(height) - float64(sample.Used-lo)/float64(hi-lo)*float64(height)
+		}
+		points = append(points, fmt.Sprintf("%.1f,%.1f", x, y))
+	}
+	return strings.Join(points, " ")
+}
+
+// userCapacitySeries returns the series of the usage of a user shown on its
+// dashboard, labelled by their kind
+func userCapacitySeries(userID int) []capacity.Series {
+	return []capacity.Series{
+		{Scope: capacity.ScopeShare, Key: capacity.ShareKey(userID, capacity.ShareData), Label: capacity.ShareData},
+		{Scope: capacity.ScopeShare, Key: capacity.ShareKey(userID, capacity.ShareBackup), Label: capacity.ShareBackup},
+		{Scope: capacity.ScopeShare, Key: capacity.ShareKey(userID, capacity.ShareTrash), Label: capacity.ShareTrash},
+		{Scope: capacity.ScopeUser, Key: strconv.Itoa(userID), Label: "total"},
+	}
+}
+
+// getUserForecasts returns the forecasts of the usage of a user, skipping the
+// series not collected yet
+func (s *Server) getUserForecasts(userID int) ([]capacity.Forecast, error) {
+	now := time.Now()
+	var forecasts []capacity.Forecast
+	for _, series := range userCapacitySeries(userID) {
+		f, err := capacity.GetForecast(s.db, series, now)
+		if err != nil {
+			return nil, err
+		}
+		if len(f.Samples) > 0 {
+			forecasts = append(forecasts, f)
+		}
+	}
+	return forecasts, nil
+}
+
+// getDashboardForecasts returns the forecasts shown on the admin dashboard:
+// every pool and filesystem, and the users filling up their quota
+func (s *Server) getDashboardForecasts() ([]capacity.Forecast, error) {
+	all, err := capacity.GetForecasts(s.db, "")
+	if err != nil {
+		return nil, err
+	}
+	var forecasts []capacity.Forecast
+	for _, f := range all {
+		switch {
+		case f.Scope == capacity.ScopePool || f.Scope == capacity.ScopeFilesystem:
+			forecasts = append(forecasts, f)
+		case f.Scope == capacity.ScopeUser && f.Severity != capacity.SeverityNone:
+			forecasts = append(forecasts, f)
+		}
+	}
+	return forecasts, nil
+}
+
+// handleAdminCapacityForecasts returns the forecasts of every series, or of a
+// scope (GET ?scope=)
+func (s *Server) handleAdminCapacityForecasts(w http.ResponseWriter, r *http.Request) {
+	if _, ok := auth.GetSessionFromContext(r); !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	forecasts, err := capacity.GetForecasts(s.db, r.URL.Query().Get("scope"))
+	if err != nil {
+		logger.Info("Error getting capacity forecasts", "error", err)
+		storageJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(forecasts)
+}
+
+// handleAdminCapacityHistory returns the usage history of a series
+// (GET ?scope=&key=&days=)
+func (s *Server) handleAdminCapacityHistory(w http.ResponseWriter, r *http.Request) {
+	if _, ok := auth.GetSessionFromContext(r); !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	scope, key := r.URL.Query().Get("scope"), r.URL.Query().Get("key")
+	if scope == "" || key == "" {
+		storageJSONError(w, http.StatusBadRequest, "scope and key are required")
+		return
+	}
+	samples, err := capacity.GetHistory(s.db, scope, key, time.Now().AddDate(0, 0, -historyDays(r)))
+	if err != nil {
+		logger.Info("Error getting usage history", "scope", scope, "key", key, "error", err)
+		storageJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(samples)
+}
+
+// handleCapacity returns the forecasts of the usage of the current user (GET)
+func (s *Server) handleCapacity(w http.ResponseWriter, r *http.Request) {
+	session, ok := auth.GetSessionFromContext(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	forecasts, err := s.getUserForecasts(session.UserID)
+	if err != nil {
+		logger.Info("Error getting user capacity forecasts", "username", session.Username, "error", err)
+		storageJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if forecasts == nil {
+		forecasts = []capacity.Forecast{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(forecasts)
+}
+
+// handleCapacityHistory returns the usage history of a series of the current
+// user (GET ?kind=data|backup|trash|total&days=)
+func (s *Server) handleCapacityHistory(w http.ResponseWriter, r *http.Request) {
+	session, ok := auth.GetSessionFromContext(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	scope, key := capacity.ScopeShare, ""
+	switch kind := r.URL.Query().Get("kind"); kind {
+	case capacity.ShareData, capacity.ShareBackup, capacity.ShareTrash:
+		key = capacity.ShareKey(session.UserID, kind)
+	case "total":
+		scope, key = capacity.ScopeUser, strconv.Itoa(session.UserID)
+	default:
+		storageJSONError(w, http.StatusBadRequest, "Invalid kind")
+		return
+	}
+
+	samples, err := capacity.GetHistory(s.db, scope, key, time.Now().AddDate(0, 0, -historyDays(r)))
+	if err != nil {
+		logger.Info("Error getting user usage history", "username", session.Username, "error", err)
+		storageJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(samples)
+}
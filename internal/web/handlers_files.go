@@ -18,9 +18,11 @@ import (
 	"time"
 
 	"github.com/juste-un-gars/anemone/internal/auth"
+	"github.com/juste-un-gars/anemone/internal/groups"
 	"github.com/juste-un-gars/anemone/internal/i18n"
 	"github.com/juste-un-gars/anemone/internal/logger"
 	"github.com/juste-un-gars/anemone/internal/shares"
+	"github.com/juste-un-gars/anemone/internal/snapshots"
 )
 
 // decodeJSON reads and decodes a JSON request body.
@@ -59,29 +61,90 @@ type BreadcrumbItem struct {
 	Path string
 }
 
-// resolveSharePath validates that the user owns the share and the relative path is safe.
-// Returns the absolute filesystem path and the share, or an error.
-func (s *Server) resolveSharePath(session *auth.Session, shareName, relPath string) (string, *shares.Share, error) {
-	if shareName == "" {
-		return "", nil, fmt.Errorf("missing share name")
+// groupShareAsShare presents a group share like a user share to the file browser.
+// The share has no ID: it is not in the shares table.
+func groupShareAsShare(gs *groups.Share) *shares.Share {
+	return &shares.Share{
+		Name:        gs.Name,
+		Path:        gs.Path,
+		Protocol:    "smb",
+		SyncEnabled: gs.SyncEnabled,
+		CreatedAt:   gs.CreatedAt,
+	}
+}
+
+// isOtherMemberTrash reports whether a path of a group share is out of the
+// trash of the user, in .trash or the trash of another member
+func isOtherMemberTrash(share *shares.Share, username, relPath string) bool {
+	if share.ID != 0 {
+		return false
 	}
+	parts := strings.SplitN(filepath.ToSlash(relPath), "/", 3)
+	return parts[0] == ".trash" && (len(parts) < 2 || parts[1] != username)
+}
 
-	// Get user's shares
-	userShares, err := shares.GetByUser(s.db, session.UserID)
+// browsableShares returns the shares of the user followed by the shares of its groups.
+func (s *Server) browsableShares(userID int) ([]*shares.Share, error) {
+	userShares, err := shares.GetByUser(s.db, userID)
 	if err != nil {
-		return "", nil, fmt.Errorf("failed to get user shares: %w", err)
+		return nil, fmt.Errorf("failed to get user shares: %w", err)
+	}
+	groupShares, err := groups.GetUserShares(s.db, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, gs := range groupShares {
+		userShares = append(userShares, groupShareAsShare(gs.Share))
+	}
+	return userShares, nil
+}
+
+// findShare returns a share of the user, its own or one of its groups, and whether
+// the user may modify it. Group shares can be read-only for some members.
+func (s *Server) findShare(userID int, shareName string) (*shares.Share, bool, error) {
+	if shareName == "" {
+		return nil, false, fmt.Errorf("missing share name")
 	}
 
-	// Find the matching share
-	var targetShare *shares.Share
+	userShares, err := shares.GetByUser(s.db, userID)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get user shares: %w", err)
+	}
 	for _, sh := range userShares {
 		if sh.Name == shareName {
-			targetShare = sh
-			break
+			return sh, true, nil
 		}
 	}
-	if targetShare == nil {
-		return "", nil, fmt.Errorf("share not found")
+
+	gs, err := groups.GetUserShare(s.db, userID, shareName)
+	if err != nil {
+		return nil, false, err
+	}
+	if gs == nil {
+		return nil, false, fmt.Errorf("share not found")
+	}
+	return groupShareAsShare(gs.Share), gs.Writable(), nil
+}
+
+// resolveSharePath validates that the user can read the share and the relative path is safe.
+// Returns the absolute filesystem path and the share, or an error.
+func (s *Server) resolveSharePath(session *auth.Session, shareName, relPath string) (string, *shares.Share, error) {
+	return s.resolveSharePathFor(session, shareName, relPath, false)
+}
+
+// resolveWritableSharePath is resolveSharePath for the requests modifying the share.
+func (s *Server) resolveWritableSharePath(session *auth.Session, shareName, relPath string) (string, *shares.Share, error) {
+	return s.resolveSharePathFor(session, shareName, relPath, true)
+}
+
+// resolveSharePathFor resolves a path of a share, checking the user may modify it when write is set.
+func (s *Server) resolveSharePathFor(session *auth.Session, shareName, relPath string, write bool) (string, *shares.Share, error) {
+	targetShare, writable, err := s.findShare(session.UserID, shareName)
+	if err != nil {
+		return "", nil, err
+	}
+	if write && !writable {
+		return "", nil, fmt.Errorf("share is read-only")
 	}
 
 	// Normalize relative path
@@ -94,6 +157,10 @@ func (s *Server) resolveSharePath(session *auth.Session, shareName, relPath stri
 	if isPathTraversal(relPath) {
 		return "", nil, fmt.Errorf("invalid path")
 	}
+	// The service can read the trash of every member of a group share
+	if isOtherMemberTrash(targetShare, session.Username, relPath) {
+		return "", nil, fmt.Errorf("invalid path")
+	}
 
 	// Build absolute path
 	absPath := filepath.Join(targetShare.Path, relPath)
@@ -111,6 +178,9 @@ func (s *Server) resolveSharePath(session *auth.Session, shareName, relPath stri
 			if !strings.HasPrefix(parentReal, shareReal) {
 				return "", nil, fmt.Errorf("path outside share")
 			}
+			if rel, err := filepath.Rel(shareReal, parentReal); err != nil || isOtherMemberTrash(targetShare, session.Username, rel) {
+				return "", nil, fmt.Errorf("invalid path")
+			}
 			return absPath, targetShare, nil
 		}
 		return "", nil, fmt.Errorf("invalid path")
@@ -120,6 +190,10 @@ func (s *Server) resolveSharePath(session *auth.Session, shareName, relPath stri
 	if !strings.HasPrefix(realPath, shareReal) {
 		return "", nil, fmt.Errorf("path outside share")
 	}
+	// A symlink must not lead to the trash of another member either
+	if rel, err := filepath.Rel(shareReal, realPath); err != nil || isOtherMemberTrash(targetShare, session.Username, rel) {
+		return "", nil, fmt.Errorf("invalid path")
+	}
 
 	return absPath, targetShare, nil
 }
@@ -237,8 +311,8 @@ func (s *Server) handleFiles(w http.ResponseWriter, r *http.Request) {
 	}
 	lang := s.getLang(r)
 
-	// Get user's shares
-	userShares, err := shares.GetByUser(s.db, session.UserID)
+	// Get user's shares and the shares of its groups
+	userShares, err := s.browsableShares(session.UserID)
 	if err != nil {
 		logger.Info("Error getting shares", "error", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
@@ -256,6 +330,8 @@ func (s *Server) handleFiles(w http.ResponseWriter, r *http.Request) {
 	var files []FileEntry
 	var breadcrumb []BreadcrumbItem
 	var currentShare string
+	var hasVersions bool
+	readOnly := false
 
 	if shareName != "" {
 		absPath, share, err := s.resolveSharePath(session, shareName, relPath)
@@ -264,6 +340,9 @@ func (s *Server) handleFiles(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, i18n.T(lang, "files.error.access_denied"), http.StatusForbidden)
 			return
 		}
+		if _, writable, err := s.findShare(session.UserID, shareName); err == nil {
+			readOnly = !writable
+		}
 
 		// Check if path exists and is a directory
 		info, err := os.Stat(absPath)
@@ -281,6 +360,11 @@ func (s *Server) handleFiles(w http.ResponseWriter, r *http.Request) {
 
 		breadcrumb = buildBreadcrumb(relPath)
 		currentShare = shareName
+
+		// Previous versions are offered when the share is snapshotted
+		if source, err := snapshots.SourceFor(share.Path); err == nil && source != nil {
+			hasVersions = true
+		}
 	}
 
 	data := struct {
@@ -291,6 +375,8 @@ func (s *Server) handleFiles(w http.ResponseWriter, r *http.Request) {
 		CurrentShare string
 		CurrentPath  string
 		OOEnabled    bool
+		HasVersions  bool
+		ReadOnly     bool
 	}{
 		V2TemplateData: V2TemplateData{
 			Lang:       lang,
@@ -304,6 +390,8 @@ func (s *Server) handleFiles(w http.ResponseWriter, r *http.Request) {
 		CurrentShare: currentShare,
 		CurrentPath:  relPath,
 		OOEnabled:    s.cfg.OnlyOfficeEnabled,
+		HasVersions:  hasVersions,
+		ReadOnly:     readOnly,
 	}
 
 	tmpl := s.loadV2UserPage("v2_files.html", s.funcMap)
@@ -377,7 +465,7 @@ func (s *Server) handleFilesUpload(w http.ResponseWriter, r *http.Request) {
 	shareName := r.FormValue("share")
 	relPath := r.FormValue("path")
 
-	destDir, _, err := s.resolveSharePath(session, shareName, relPath)
+	destDir, _, err := s.resolveWritableSharePath(session, shareName, relPath)
 	if err != nil {
 		jsonError(w, "Access denied", http.StatusForbidden)
 		return
@@ -485,7 +573,7 @@ func (s *Server) handleFilesMkdir(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Resolve parent directory
-	parentPath, _, err := s.resolveSharePath(session, req.Share, req.Path)
+	parentPath, _, err := s.resolveWritableSharePath(session, req.Share, req.Path)
 	if err != nil {
 		jsonError(w, "Access denied", http.StatusForbidden)
 		return
@@ -541,7 +629,7 @@ func (s *Server) handleFilesRename(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Resolve source path
-	srcPath, _, err := s.resolveSharePath(session, req.Share, req.Path)
+	srcPath, _, err := s.resolveWritableSharePath(session, req.Share, req.Path)
 	if err != nil {
 		jsonError(w, "Access denied", http.StatusForbidden)
 		return
@@ -605,7 +693,7 @@ func (s *Server) handleFilesDelete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	absPath, share, err := s.resolveSharePath(session, req.Share, req.Path)
+	absPath, share, err := s.resolveWritableSharePath(session, req.Share, req.Path)
 	if err != nil {
 		jsonError(w, "Access denied", http.StatusForbidden)
 		return
@@ -618,14 +706,26 @@ func (s *Server) handleFilesDelete(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Move to .trash/{username}/ (same pattern as Samba recycle)
-	trashBase := filepath.Join(share.Path, ".trash", session.Username)
+	if err := moveToTrash(share, session.Username, req.Path, absPath); err != nil {
+		logger.Info("Error moving to trash", "abs_path", absPath, "error", err)
+		jsonError(w, "Failed to delete", http.StatusInternalServerError)
+		return
+	}
+
+	logger.Info("User deleted from share (moved to trash)", "username", session.Username, "path", req.Path, "share", req.Share)
+	jsonSuccess(w)
+}
+
+// moveToTrash moves a file or directory of a share to .trash/{username}/,
+// keeping its relative path like the Samba recycle module.
+func moveToTrash(share *shares.Share, username, relPath, absPath string) error {
+	trashBase := filepath.Join(share.Path, ".trash", username)
 
 	// Ensure trash directory exists
 	os.MkdirAll(trashBase, 0755)
 
 	// Build trash destination preserving relative path structure (keeptree)
-	relPath := filepath.Clean(req.Path)
-	trashDest := filepath.Join(trashBase, relPath)
+	trashDest := filepath.Join(trashBase, filepath.Clean(relPath))
 
 	// If destination already exists, add timestamp suffix
 	if _, err := os.Stat(trashDest); err == nil {
@@ -636,21 +736,11 @@ func (s *Server) handleFilesDelete(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Ensure parent directory in trash exists
-	trashParent := filepath.Dir(trashDest)
-	os.MkdirAll(trashParent, 0755)
+	os.MkdirAll(filepath.Dir(trashDest), 0755)
 
 	// Move to trash — try direct first, fallback to sudo
-	err = os.Rename(absPath, trashDest)
-	if err != nil {
-		cmd := exec.Command("sudo", "/usr/bin/mv", absPath, trashDest)
-		err = cmd.Run()
-	}
-	if err != nil {
-		logger.Info("Error moving to trash", "abs_path", absPath, "error", err)
-		jsonError(w, "Failed to delete", http.StatusInternalServerError)
-		return
+	if err := os.Rename(absPath, trashDest); err != nil {
+		return exec.Command("sudo", "/usr/bin/mv", absPath, trashDest).Run()
 	}
-
-	logger.Info("User deleted from share (moved to trash)", "username", session.Username, "path", req.Path, "share", req.Share)
-	jsonSuccess(w)
+	return nil
 }
@@ -0,0 +1,262 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+// This file contains the file browser handlers of previous versions: users browse
+// the scheduled snapshots of their shares, download and restore files from them.
+
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/juste-un-gars/anemone/internal/auth"
+	"github.com/juste-un-gars/anemone/internal/logger"
+	"github.com/juste-un-gars/anemone/internal/shares"
+	"github.com/juste-un-gars/anemone/internal/snapshots"
+)
+
+// resolveVersionSource returns the share of the user and the snapshots holding
+// it. Unlike resolveSharePath, the path may no longer exist in the share.
+func (s *Server) resolveVersionSource(session *auth.Session, shareName, relPath string) (*shares.Share, *snapshots.VersionSource, string, error) {
+	targetShare, _, err := s.findShare(session.UserID, shareName)
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	if relPath == "" || relPath == "/" {
+		relPath = "."
+	}
+	relPath = filepath.Clean(relPath)
+	if isPathTraversal(relPath) || isOtherMemberTrash(targetShare, session.Username, relPath) {
+		return nil, nil, "", fmt.Errorf("invalid path")
+	}
+
+	source, err := snapshots.SourceFor(targetShare.Path)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	if source == nil {
+		return nil, nil, "", fmt.Errorf("no snapshots for this share")
+	}
+	return targetShare, source, relPath, nil
+}
+
+// handleFilesVersions lists the snapshots holding a file or folder
+// (GET /api/files/versions?share=X&path=Y).
+func (s *Server) handleFilesVersions(w http.ResponseWriter, r *http.Request) {
+	session, ok := auth.GetSessionFromContext(r)
+	if !ok {
+		jsonError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	_, source, relPath, err := s.resolveVersionSource(session, r.URL.Query().Get("share"), r.URL.Query().Get("path"))
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	versions, err := source.VersionsOf(relPath)
+	if err != nil {
+		logger.Info("Error listing previous versions", "user_id", session.UserID, "error", err)
+		jsonError(w, "Failed to list versions", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":  true,
+		"versions": versions,
+	})
+}
+
+// handleFilesVersionsBrowse lists a folder as of a snapshot
+// (GET /api/files/versions/browse?share=X&path=Y&snapshot=Z).
+func (s *Server) handleFilesVersionsBrowse(w http.ResponseWriter, r *http.Request) {
+	session, ok := auth.GetSessionFromContext(r)
+	if !ok {
+		jsonError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	_, source, relPath, err := s.resolveVersionSource(session, r.URL.Query().Get("share"), r.URL.Query().Get("path"))
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	snapRoot, err := source.Path(r.URL.Query().Get("snapshot"), ".")
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	snapPath, err := source.Path(r.URL.Query().Get("snapshot"), relPath)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	// Paths are relative to the share, as in the live listing
+	files, err := listDirectory(snapPath, snapRoot)
+	if err != nil {
+		jsonError(w, "Failed to read directory", http.StatusInternalServerError)
+		return
+	}
+	if files == nil {
+		files = []FileEntry{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"files":   files,
+	})
+}
+
+// handleFilesVersionsDownload serves a file as of a snapshot
+// (GET /api/files/versions/download?share=X&path=Y&snapshot=Z).
+func (s *Server) handleFilesVersionsDownload(w http.ResponseWriter, r *http.Request) {
+	session, ok := auth.GetSessionFromContext(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	_, source, relPath, err := s.resolveVersionSource(session, r.URL.Query().Get("share"), r.URL.Query().Get("path"))
+	if err != nil {
+		http.Error(w, "Access denied", http.StatusForbidden)
+		return
+	}
+	snapPath, err := source.Path(r.URL.Query().Get("snapshot"), relPath)
+	if err != nil {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+
+	info, err := os.Stat(snapPath)
+	if err != nil {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+	if info.IsDir() {
+		http.Error(w, "Cannot download directory", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filepath.Base(snapPath)))
+	http.ServeFile(w, r, snapPath)
+}
+
+// handleFilesVersionsRestore copies a file or folder from a snapshot back into
+// the share (POST /api/files/versions/restore). The current item is moved to the
+// trash when replace is set; otherwise the old version is restored next to it.
+func (s *Server) handleFilesVersionsRestore(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	session, ok := auth.GetSessionFromContext(r)
+	if !ok {
+		jsonError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		Share    string `json:"share"`
+		Path     string `json:"path"`
+		Snapshot string `json:"snapshot"`
+		Replace  bool   `json:"replace"`
+	}
+	if err := decodeJSON(r, &req); err != nil {
+		jsonError(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	share, source, relPath, err := s.resolveVersionSource(session, req.Share, req.Path)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if relPath == "." {
+		jsonError(w, "Cannot restore the whole share", http.StatusBadRequest)
+		return
+	}
+	snapPath, err := source.Path(req.Snapshot, relPath)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	// Recreate the destination folder if it was deleted, once its closest
+	// existing parent is checked to be within the share
+	destDir := filepath.Join(share.Path, filepath.Dir(relPath))
+	existing := filepath.Dir(relPath)
+	for existing != "." {
+		if _, err := os.Lstat(filepath.Join(share.Path, existing)); err == nil {
+			break
+		}
+		existing = filepath.Dir(existing)
+	}
+	if _, _, err := s.resolveWritableSharePath(session, req.Share, existing); err != nil {
+		jsonError(w, "Access denied", http.StatusForbidden)
+		return
+	}
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		if err := exec.Command("sudo", "/bin/mkdir", "-p", destDir).Run(); err != nil {
+			logger.Info("Error creating restore folder", "dest_dir", destDir, "error", err)
+			jsonError(w, "Failed to restore", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	destPath := filepath.Join(share.Path, relPath)
+	if _, err := os.Lstat(destPath); err == nil {
+		if req.Replace {
+			if err := moveToTrash(share, session.Username, relPath, destPath); err != nil {
+				logger.Info("Error moving to trash", "abs_path", destPath, "error", err)
+				jsonError(w, "Failed to restore", http.StatusInternalServerError)
+				return
+			}
+		} else {
+			destPath = restoredName(destPath, req.Snapshot)
+		}
+	}
+
+	// Copy — try direct first, fallback to sudo (-T never copies into an existing folder)
+	if err := exec.Command("cp", "-a", "-T", "--reflink=auto", snapPath, destPath).Run(); err != nil {
+		if err := exec.Command("sudo", "/usr/bin/cp", "-a", "-T", "--reflink=auto", snapPath, destPath).Run(); err != nil {
+			logger.Info("Error restoring previous version", "snapshot", req.Snapshot, "dest_path", destPath, "error", err)
+			jsonError(w, "Failed to restore", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	logger.Info("User restored previous version", "username", session.Username, "share", req.Share, "path", req.Path, "snapshot", req.Snapshot, "replace", req.Replace)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"name":    filepath.Base(destPath),
+	})
+}
+
+// restoredName returns the path an old version is restored to next to the
+// current one: "report.odt" becomes "report (auto-daily-20250301-0000).odt"
+func restoredName(path, snapshot string) string {
+	ext := filepath.Ext(path)
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		ext = ""
+	}
+	base := path[:len(path)-len(ext)]
+	name := fmt.Sprintf("%s (%s)%s", base, snapshot, ext)
+	for i := 2; ; i++ {
+		if _, err := os.Lstat(name); os.IsNotExist(err) {
+			return name
+		}
+		name = fmt.Sprintf("%s (%s %d)%s", base, snapshot, i, ext)
+	}
+}
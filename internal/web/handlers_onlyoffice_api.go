@@ -28,7 +28,6 @@ import (
 	"github.com/juste-un-gars/anemone/internal/auth"
 	"github.com/juste-un-gars/anemone/internal/logger"
 	"github.com/juste-un-gars/anemone/internal/onlyoffice"
-	"github.com/juste-un-gars/anemone/internal/shares"
 )
 
 // validHostnameRe matches valid hostnames and IP addresses (no CSP-breaking chars).
@@ -63,7 +62,7 @@ func (s *Server) handleOODownload(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Resolve the file path using share ownership validation
-	absPath, err := s.resolveSharePathByUserID(claims.UserID, claims.ShareName, claims.FilePath)
+	absPath, err := s.resolveSharePathByUserID(claims.UserID, claims.ShareName, claims.FilePath, false)
 	if err != nil {
 		logger.Warn("OO download: path resolution failed", "error", err)
 		http.Error(w, "File not found", http.StatusNotFound)
@@ -151,7 +150,7 @@ func (s *Server) downloadAndSaveOOFile(docKey, downloadURL string) error {
 	relPath := parts[2]
 
 	// Resolve target path
-	absPath, err := s.resolveSharePathByUserID(userID, shareName, relPath)
+	absPath, err := s.resolveSharePathByUserID(userID, shareName, relPath, true)
 	if err != nil {
 		return fmt.Errorf("path resolution failed: %w", err)
 	}
@@ -322,21 +321,14 @@ func (s *Server) convertViaOO(fileURL, fromExt, toExt, key string) (string, erro
 
 // resolveSharePathByUserID resolves a file path using user ID instead of session.
 // Used by OnlyOffice endpoints where authentication is via JWT, not session cookie.
-func (s *Server) resolveSharePathByUserID(userID int, shareName, relPath string) (string, error) {
-	userShares, err := shares.GetByUser(s.db, userID)
+// When write is set, the user must be allowed to modify the share.
+func (s *Server) resolveSharePathByUserID(userID int, shareName, relPath string, write bool) (string, error) {
+	targetShare, writable, err := s.findShare(userID, shareName)
 	if err != nil {
-		return "", fmt.Errorf("failed to get user shares: %w", err)
+		return "", err
 	}
-
-	var targetShare *shares.Share
-	for _, sh := range userShares {
-		if sh.Name == shareName {
-			targetShare = sh
-			break
-		}
-	}
-	if targetShare == nil {
-		return "", fmt.Errorf("share not found")
+	if write && !writable {
+		return "", fmt.Errorf("share is read-only")
 	}
 
 	if relPath == "" || relPath == "/" {
@@ -405,6 +397,11 @@ func (s *Server) handleFilesEdit(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Access denied", http.StatusForbidden)
 		return
 	}
+	_, writable, _ := s.findShare(session.UserID, shareName)
+	mode := "view"
+	if writable {
+		mode = "edit"
+	}
 
 	info, err := os.Stat(absPath)
 	if err != nil {
@@ -457,7 +454,7 @@ func (s *Server) handleFilesEdit(w http.ResponseWriter, r *http.Request) {
 			"title":    filepath.Base(absPath),
 			"url":      downloadURL,
 			"permissions": map[string]interface{}{
-				"edit":     true,
+				"edit":     writable,
 				"download": true,
 			},
 		},
@@ -465,7 +462,7 @@ func (s *Server) handleFilesEdit(w http.ResponseWriter, r *http.Request) {
 		"editorConfig": map[string]interface{}{
 			"callbackUrl": callbackURL,
 			"lang":        lang,
-			"mode":        "edit",
+			"mode":        mode,
 			"user": map[string]interface{}{
 				"id":   fmt.Sprintf("%d", session.UserID),
 				"name": session.Username,
@@ -28,6 +28,7 @@ import (
 	"github.com/juste-un-gars/anemone/internal/logger"
 	"github.com/juste-un-gars/anemone/internal/peers"
 	"github.com/juste-un-gars/anemone/internal/restore"
+	"github.com/juste-un-gars/anemone/internal/shares"
 	"github.com/juste-un-gars/anemone/internal/sync"
 )
 
@@ -39,11 +40,24 @@ func (s *Server) handleRestore(w http.ResponseWriter, r *http.Request) {
 	}
 
 	lang := s.getLang(r)
-	data := V2TemplateData{
-		Lang:       lang,
-		Title:      i18n.T(lang, "v2.nav.restore"),
-		ActivePage: "restore",
-		Session:    session,
+
+	// Shares the user can restore into (server-side restore)
+	userShares, err := shares.GetByUser(s.db, session.UserID)
+	if err != nil {
+		logger.Info("Error getting user shares", "error", err)
+	}
+
+	data := struct {
+		V2TemplateData
+		Shares []*shares.Share
+	}{
+		V2TemplateData: V2TemplateData{
+			Lang:       lang,
+			Title:      i18n.T(lang, "v2.nav.restore"),
+			ActivePage: "restore",
+			Session:    session,
+		},
+		Shares: userShares,
 	}
 
 	tmpl := s.loadV2UserPage("v2_restore.html", s.funcMap)
@@ -0,0 +1,109 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+// This file contains the API handlers for server-side restore jobs.
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/juste-un-gars/anemone/internal/auth"
+	"github.com/juste-un-gars/anemone/internal/bulkrestore"
+	"github.com/juste-un-gars/anemone/internal/logger"
+)
+
+// handleAPIRestoreJobs lists, inspects and starts server-side restore jobs
+// GET  /api/restore/jobs            - recent jobs of the current user
+// GET  /api/restore/jobs?id={id}    - a single job
+// POST /api/restore/jobs?peer_id={id}&backup={share_name}&source_server={name}
+// Form data: paths (multiple), target_share, target_dir, conflict
+func (s *Server) handleAPIRestoreJobs(w http.ResponseWriter, r *http.Request) {
+	session, ok := auth.GetSessionFromContext(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		if idStr := r.URL.Query().Get("id"); idStr != "" {
+			jobID, err := strconv.ParseInt(idStr, 10, 64)
+			if err != nil {
+				http.Error(w, "Invalid id", http.StatusBadRequest)
+				return
+			}
+			job, err := bulkrestore.GetJob(s.db, jobID)
+			if err != nil || job.UserID != session.UserID {
+				http.Error(w, "Job not found", http.StatusNotFound)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(job)
+			return
+		}
+
+		jobs, err := bulkrestore.ListJobs(s.db, session.UserID, 20)
+		if err != nil {
+			logger.Info("Error listing restore jobs", "error", err)
+			http.Error(w, "Failed to list restore jobs", http.StatusInternalServerError)
+			return
+		}
+		if jobs == nil {
+			jobs = []*bulkrestore.Job{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(jobs)
+
+	case http.MethodPost:
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "Failed to parse form", http.StatusBadRequest)
+			return
+		}
+
+		peerID, err := strconv.Atoi(r.URL.Query().Get("peer_id"))
+		shareName := r.URL.Query().Get("backup")
+		sourceServer := r.URL.Query().Get("source_server")
+		if err != nil || shareName == "" || sourceServer == "" {
+			http.Error(w, "Missing peer_id, backup, or source_server", http.StatusBadRequest)
+			return
+		}
+
+		targetShare := r.FormValue("target_share")
+		if targetShare == "" {
+			targetShare = shareName
+		}
+
+		jobID, err := bulkrestore.StartJob(s.db, bulkrestore.JobRequest{
+			UserID:         session.UserID,
+			PeerID:         peerID,
+			ShareName:      shareName,
+			SourceServer:   sourceServer,
+			Paths:          r.Form["paths"],
+			TargetShare:    targetShare,
+			TargetDir:      r.FormValue("target_dir"),
+			ConflictPolicy: r.FormValue("conflict"),
+		})
+		w.Header().Set("Content-Type", "application/json")
+		if err != nil {
+			logger.Info("Error starting restore job", "username", session.Username, "error", err)
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"success": false,
+				"error":   err.Error(),
+			})
+			return
+		}
+
+		logger.Info("User started server-side restore", "username", session.Username, "job_id", jobID, "peer_id", peerID, "share_name", shareName, "target_share", targetShare)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"job_id":  jobID,
+		})
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -6,6 +6,8 @@ package web
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"html/template"
 	"io"
 	"github.com/juste-un-gars/anemone/internal/logger"
@@ -17,7 +19,10 @@ import (
 
 	"github.com/juste-un-gars/anemone/internal/backup"
 	"github.com/juste-un-gars/anemone/internal/i18n"
+	"github.com/juste-un-gars/anemone/internal/rclone"
+	"github.com/juste-un-gars/anemone/internal/serverbackup"
 	"github.com/juste-un-gars/anemone/internal/setup"
+	"github.com/juste-un-gars/anemone/internal/usbbackup"
 )
 
 // SetupWizardServer wraps setup wizard handlers
@@ -30,6 +35,8 @@ type SetupWizardServer struct {
 	// Pending restore data (temporary storage during wizard flow)
 	pendingRestoreMu     sync.RWMutex
 	pendingRestoreBackup *backup.ServerBackup
+	pendingUSBPath       string // USB backup the pending configuration was read from
+	pendingUSBKey        string // Key that USB backup is encrypted with
 }
 
 // NewSetupWizardServer creates a new setup wizard server
@@ -468,6 +475,8 @@ func (s *SetupWizardServer) handleRestoreValidate(w http.ResponseWriter, r *http
 	// Store backup for later execution
 	s.pendingRestoreMu.Lock()
 	s.pendingRestoreBackup = serverBackup
+	s.pendingUSBPath = ""
+	s.pendingUSBKey = ""
 	s.pendingRestoreMu.Unlock()
 
 	w.Header().Set("Content-Type", "application/json")
@@ -484,6 +493,7 @@ func (s *SetupWizardServer) handleRestoreExecute(w http.ResponseWriter, r *http.
 	// Check for pending backup
 	s.pendingRestoreMu.RLock()
 	serverBackup := s.pendingRestoreBackup
+	usbPath, usbKey := s.pendingUSBPath, s.pendingUSBKey
 	s.pendingRestoreMu.RUnlock()
 
 	if serverBackup == nil {
@@ -496,6 +506,8 @@ func (s *SetupWizardServer) handleRestoreExecute(w http.ResponseWriter, r *http.
 		DataDir     string `json:"data_dir"`
 		SharesDir   string `json:"shares_dir"`
 		IncomingDir string `json:"incoming_dir"`
+		CloudID     int    `json:"cloud_id"` // Cloud destination to pull user data from (0 = none)
+		USBData     bool   `json:"usb_data"` // Also restore share data from the USB drive
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -521,15 +533,33 @@ func (s *SetupWizardServer) handleRestoreExecute(w http.ResponseWriter, r *http.
 		IncomingDir: req.IncomingDir,
 	}
 
-	if err := setup.ExecuteRestore(serverBackup, opts); err != nil {
+	err := setup.ExecuteRestore(serverBackup, opts)
+	if err != nil {
 		logger.Info("Restore failed", "error", err)
 		http.Error(w, "Restore failed: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	// Pull user backup data back from the selected cloud destination
+	cloudJobs := 0
+	if req.CloudID != 0 {
+		cloudJobs, err = startWizardCloudRestore(serverBackup, req.CloudID, opts)
+		if err != nil {
+			logger.Warn("Cloud data restore could not be started", "error", err)
+		}
+	}
+
+	// Restore share data from the USB drive the configuration was read from
+	usbJobs := 0
+	if req.USBData && usbPath != "" {
+		usbJobs = startWizardUSBRestore(serverBackup, usbPath, usbKey, opts)
+	}
+
 	// Clear pending backup
 	s.pendingRestoreMu.Lock()
 	s.pendingRestoreBackup = nil
+	s.pendingUSBPath = ""
+	s.pendingUSBKey = ""
 	s.pendingRestoreMu.Unlock()
 
 	// Mark setup as finalized (keep state file so step-6 shows restart message)
@@ -547,10 +577,304 @@ func (s *SetupWizardServer) handleRestoreExecute(w http.ResponseWriter, r *http.
 		"server_name": serverBackup.ServerName,
 		"users_count": len(serverBackup.Users),
 		"peers_count": len(serverBackup.Peers),
+		"cloud_jobs":  cloudJobs,
+		"usb_jobs":    usbJobs,
 		"redirect":    "/setup/wizard", // Stay in wizard to show restart message
 	})
 }
 
+// startWizardCloudRestore restores every user's backup share from a cloud destination
+// of the restored configuration. Returns the number of jobs started.
+func startWizardCloudRestore(serverBackup *backup.ServerBackup, cloudID int, opts setup.RestoreOptions) (int, error) {
+	var entry *backup.RcloneBackup
+	for i := range serverBackup.RcloneBackups {
+		if serverBackup.RcloneBackups[i].ID == cloudID {
+			entry = &serverBackup.RcloneBackups[i]
+			break
+		}
+	}
+	if entry == nil {
+		return 0, fmt.Errorf("cloud destination %d not found in backup", cloudID)
+	}
+
+	dest := &rclone.RcloneBackup{
+		ID:             entry.ID,
+		Name:           entry.Name,
+		ProviderType:   entry.ProviderType,
+		ProviderConfig: make(map[string]string),
+		SFTPHost:       entry.SFTPHost,
+		SFTPPort:       entry.SFTPPort,
+		SFTPUser:       entry.SFTPUser,
+		SFTPKeyPath:    entry.SFTPKeyPath,
+		SFTPPassword:   entry.SFTPPassword,
+		RemotePath:     entry.RemotePath,
+	}
+	if entry.ProviderConfig != "" {
+		if err := json.Unmarshal([]byte(entry.ProviderConfig), &dest.ProviderConfig); err != nil {
+			return 0, fmt.Errorf("invalid provider config: %w", err)
+		}
+	}
+
+	cloudUsers, err := rclone.ListCloudUsers(dest, opts.DataDir)
+	if err != nil {
+		return 0, err
+	}
+	known := make(map[string]bool, len(serverBackup.Users))
+	for _, u := range serverBackup.Users {
+		known[u.Username] = true
+	}
+
+	started := 0
+	for _, username := range cloudUsers {
+		if !known[username] {
+			logger.Info("Skipping cloud backup of unknown user", "username", username)
+			continue
+		}
+		_, err := rclone.StartRestore(dest, opts.DataDir, rclone.RestoreRequest{
+			Username:  username,
+			TargetDir: filepath.Join(opts.SharesDir, username, "backup"),
+			Owner:     username,
+		})
+		if err != nil {
+			logger.Warn("Failed to start cloud restore", "username", username, "error", err)
+			continue
+		}
+		started++
+	}
+	return started, nil
+}
+
+// handleRestoreCloudStatus returns the progress of cloud restores started by the wizard
+func (s *SetupWizardServer) handleRestoreCloudStatus(w http.ResponseWriter, r *http.Request) {
+	jobs := rclone.ListRestoreJobs(0)
+	if jobs == nil {
+		jobs = []*rclone.RestoreJob{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(jobs)
+}
+
+// handleRestoreUSBScan lists Anemone backups found on mounted USB drives
+func (s *SetupWizardServer) handleRestoreUSBScan(w http.ResponseWriter, r *http.Request) {
+	found, err := usbbackup.FindBackups("")
+	if err != nil {
+		logger.Info("USB backup scan failed", "error", err)
+	}
+
+	type driveInfo struct {
+		Path           string `json:"path"`
+		Label          string `json:"label"`
+		HasConfig      bool   `json:"has_config"`
+		Protected      bool   `json:"protected"` // Unlocked with the drive passphrase
+		HasRecoveryKey bool   `json:"has_recovery_key"`
+	}
+	drives := []driveInfo{}
+	for _, b := range found {
+		label := b.Label
+		if label == "" {
+			label = b.MountPath
+		}
+		drives = append(drives, driveInfo{
+			Path:           b.Path,
+			Label:          label,
+			HasConfig:      b.Config != nil,
+			Protected:      b.Header != nil,
+			HasRecoveryKey: b.HasRecoveryKey,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(drives)
+}
+
+// handleRestoreUSBValidate unlocks a USB backup with its passphrase, master key or
+// recovery passphrase and loads its configuration backup for restoration
+func (s *SetupWizardServer) handleRestoreUSBValidate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Path string `json:"path"`
+		Key  string `json:"key"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	path := filepath.Clean(req.Path)
+	if !usbbackup.IsBackupPath(path) {
+		http.Error(w, "No Anemone backup at this location", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	key, err := usbbackup.ResolveDriveKey(path, req.Key)
+	if err != nil {
+		logger.Info("USB backup unlock failed", "path", path, "error", err)
+		json.NewEncoder(w).Encode(&setup.RestoreResult{Error: "invalid_key"})
+		return
+	}
+
+	result, serverBackup, err := setup.LoadUSBConfig(path, key)
+	if err != nil {
+		logger.Info("USB configuration backup could not be loaded", "path", path, "error", err)
+		if result == nil {
+			result = &setup.RestoreResult{Error: "invalid_backup"}
+		}
+		json.NewEncoder(w).Encode(result)
+		return
+	}
+
+	s.pendingRestoreMu.Lock()
+	s.pendingRestoreBackup = serverBackup
+	s.pendingUSBPath = path
+	s.pendingUSBKey = key
+	s.pendingRestoreMu.Unlock()
+
+	json.NewEncoder(w).Encode(result)
+}
+
+// startWizardUSBRestore restores every share backup of a USB drive into the restored
+// shares. Returns the number of jobs started.
+func startWizardUSBRestore(serverBackup *backup.ServerBackup, path, key string, opts setup.RestoreOptions) int {
+	info, err := usbbackup.InspectBackup(path, key)
+	if err != nil {
+		logger.Warn("USB data restore could not be started", "error", err)
+		return 0
+	}
+
+	usernames := make(map[int]string, len(serverBackup.Users))
+	for _, u := range serverBackup.Users {
+		usernames[u.ID] = u.Username
+	}
+
+	started := 0
+	for _, shareBackup := range info.Shares {
+		if shareBackup.Locked {
+			continue
+		}
+		var target string
+		for _, sh := range serverBackup.Shares {
+			if sh.UserID == shareBackup.UserID && sh.Name == shareBackup.ShareName {
+				target = setup.RestoredSharePath(sh.Path, opts.SharesDir)
+				break
+			}
+		}
+		owner, ok := usernames[shareBackup.UserID]
+		if target == "" || !ok {
+			logger.Info("Skipping USB backup of unknown share", "share", shareBackup.Dir)
+			continue
+		}
+
+		_, err := usbbackup.StartRestore(usbbackup.RestoreRequest{
+			BackupPath: path,
+			ShareDir:   shareBackup.Dir,
+			TargetDir:  target,
+			Owner:      owner,
+			MasterKey:  key,
+		})
+		if err != nil {
+			logger.Warn("Failed to start USB restore", "share", shareBackup.Dir, "error", err)
+			continue
+		}
+		started++
+	}
+	return started
+}
+
+// handleRestoreUSBStatus returns the progress of USB restores started by the wizard
+func (s *SetupWizardServer) handleRestoreUSBStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(usbbackup.ListRestoreJobs())
+}
+
+// wizardPeerRequest identifies a peer holding configuration backups, and the backup to fetch
+type wizardPeerRequest struct {
+	Address      string `json:"address"`
+	Port         int    `json:"port"`
+	Password     string `json:"password"` // Sync password of the peer
+	SourceServer string `json:"source_server"`
+	Filename     string `json:"filename"`
+	Passphrase   string `json:"passphrase"` // Recovery passphrase of the backup
+}
+
+// wizardPeerError maps a peer access error to the code shown by the wizard
+func wizardPeerError(err error) string {
+	if errors.Is(err, serverbackup.ErrPeerAuth) {
+		return "peer_auth"
+	}
+	return "peer_unreachable"
+}
+
+// handleRestorePeerList lists the configuration backups stored on a peer
+func (s *SetupWizardServer) handleRestorePeerList(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req wizardPeerRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	backups, err := serverbackup.ListOnPeer(strings.TrimSpace(req.Address), req.Port, req.Password)
+	if err != nil {
+		logger.Info("Failed to list configuration backups on peer", "address", req.Address, "error", err)
+		json.NewEncoder(w).Encode(map[string]string{"error": wizardPeerError(err)})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"backups": backups})
+}
+
+// handleRestorePeerValidate fetches a configuration backup from a peer and decrypts it
+// with the recovery passphrase, like handleRestoreValidate does for an uploaded file
+func (s *SetupWizardServer) handleRestorePeerValidate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req wizardPeerRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Passphrase == "" {
+		http.Error(w, "Passphrase is required", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	encryptedData, err := serverbackup.FetchFromPeer(strings.TrimSpace(req.Address), req.Port, req.Password, req.SourceServer, req.Filename)
+	if err != nil {
+		logger.Info("Failed to fetch configuration backup from peer", "address", req.Address, "error", err)
+		json.NewEncoder(w).Encode(&setup.RestoreResult{Error: wizardPeerError(err)})
+		return
+	}
+
+	result, serverBackup, err := setup.ValidateBackup(encryptedData, req.Passphrase)
+	if err != nil {
+		logger.Info("Backup fetched from peer could not be decrypted", "error", err)
+		json.NewEncoder(w).Encode(result)
+		return
+	}
+
+	s.pendingRestoreMu.Lock()
+	s.pendingRestoreBackup = serverBackup
+	s.pendingUSBPath = ""
+	s.pendingUSBKey = ""
+	s.pendingRestoreMu.Unlock()
+
+	json.NewEncoder(w).Encode(result)
+}
+
 // RegisterWizardRoutes registers setup wizard routes
 func (s *SetupWizardServer) RegisterWizardRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("/setup/wizard", s.handleWizard)
@@ -564,4 +888,10 @@ func (s *SetupWizardServer) RegisterWizardRoutes(mux *http.ServeMux) {
 	// Restore endpoints
 	mux.HandleFunc("/setup/wizard/restore/validate", s.handleRestoreValidate)
 	mux.HandleFunc("/setup/wizard/restore/execute", s.handleRestoreExecute)
+	mux.HandleFunc("/setup/wizard/restore/cloud/status", s.handleRestoreCloudStatus)
+	mux.HandleFunc("/setup/wizard/restore/usb/scan", s.handleRestoreUSBScan)
+	mux.HandleFunc("/setup/wizard/restore/usb/validate", s.handleRestoreUSBValidate)
+	mux.HandleFunc("/setup/wizard/restore/usb/status", s.handleRestoreUSBStatus)
+	mux.HandleFunc("/setup/wizard/restore/peer/list", s.handleRestorePeerList)
+	mux.HandleFunc("/setup/wizard/restore/peer/validate", s.handleRestorePeerValidate)
 }
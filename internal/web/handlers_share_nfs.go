@@ -0,0 +1,125 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+// This file contains the handler of the NFS export of a share.
+
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"path/filepath"
+
+	"github.com/juste-un-gars/anemone/internal/auth"
+	"github.com/juste-un-gars/anemone/internal/i18n"
+	"github.com/juste-un-gars/anemone/internal/logger"
+	"github.com/juste-un-gars/anemone/internal/nfs"
+	"github.com/juste-un-gars/anemone/internal/shares"
+)
+
+// nfsConfig returns the configuration of the exports file
+func (s *Server) nfsConfig() *nfs.Config {
+	return &nfs.Config{
+		ConfigPath: filepath.Join(s.cfg.DataDir, "nfs", "anemone.exports"),
+	}
+}
+
+// handleAdminShareNFS shows (GET) and saves (POST) the NFS export of a share
+// (/admin/shares/{id}/nfs)
+func (s *Server) handleAdminShareNFS(w http.ResponseWriter, r *http.Request, session *auth.Session, share *shares.Share) {
+	redirect := fmt.Sprintf("/admin/shares/%d/nfs", share.ID)
+
+	switch r.Method {
+	case http.MethodGet:
+		lang := s.getLang(r)
+		export, err := nfs.Get(s.db, share.ID)
+		if err != nil {
+			logger.Info("Error loading NFS export", "share_id", share.ID, "error", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		if export == nil {
+			export = &nfs.Export{ShareID: share.ID, Squash: nfs.SquashRoot}
+		}
+
+		var owner string
+		s.db.QueryRow("SELECT username FROM users WHERE id = ?", share.UserID).Scan(&owner)
+		status, _ := nfs.GetServiceStatus()
+
+		data := struct {
+			V2TemplateData
+			Share        *shares.Share
+			Export       *nfs.Export
+			Owner        string
+			NFSStatus    string
+			NFSInstalled bool
+			Success      string
+			Error        string
+		}{
+			V2TemplateData: V2TemplateData{
+				Lang:       lang,
+				Title:      i18n.T(lang, "shares.nfs.title"),
+				ActivePage: "shares",
+				Session:    session,
+			},
+			Share:        share,
+			Export:       export,
+			Owner:        owner,
+			NFSStatus:    status,
+			NFSInstalled: nfs.CheckNFSInstalled(),
+			Success:      r.URL.Query().Get("success"),
+			Error:        r.URL.Query().Get("error"),
+		}
+
+		tmpl := s.loadV2Page("v2_shares_nfs.html", s.funcMap)
+		if err := tmpl.ExecuteTemplate(w, "v2_base", data); err != nil {
+			logger.Info("Error rendering share NFS template", "error", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		}
+
+	case http.MethodPost:
+		if err := r.ParseForm(); err != nil {
+			http.Redirect(w, r, redirect+"?error=Invalid+form", http.StatusSeeOther)
+			return
+		}
+		previous, err := nfs.Get(s.db, share.ID)
+		if err != nil {
+			http.Redirect(w, r, redirect+"?error="+url.QueryEscape(err.Error()), http.StatusSeeOther)
+			return
+		}
+
+		export := &nfs.Export{
+			ShareID:  share.ID,
+			Enabled:  r.FormValue("enabled") == "on",
+			Clients:  nfs.ParseClients(r.FormValue("clients")),
+			ReadOnly: r.FormValue("read_only") == "on",
+			Squash:   r.FormValue("squash"),
+		}
+		if err := nfs.Save(s.db, export); err != nil {
+			http.Redirect(w, r, redirect+"?error="+url.QueryEscape(err.Error()), http.StatusSeeOther)
+			return
+		}
+
+		// Apply the exports, and go back to the previous settings if the NFS
+		// server rejects them
+		if err := nfs.GenerateConfig(s.db, s.nfsConfig()); err != nil {
+			logger.Info("Error applying NFS exports", "share_id", share.ID, "error", err)
+			if previous != nil {
+				nfs.Save(s.db, previous)
+			} else {
+				nfs.Delete(s.db, share.ID)
+			}
+			http.Redirect(w, r, redirect+"?error="+url.QueryEscape(err.Error()), http.StatusSeeOther)
+			return
+		}
+
+		logger.Info("Admin updated NFS export", "username", session.Username, "share_id", share.ID,
+			"enabled", export.Enabled, "clients", export.Clients, "read_only", export.ReadOnly, "squash", export.Squash)
+		http.Redirect(w, r, redirect+"?success=1", http.StatusSeeOther)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
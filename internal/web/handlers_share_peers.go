@@ -0,0 +1,263 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+// This file contains handlers for per-share peer selection and replication factor.
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/juste-un-gars/anemone/internal/auth"
+	"github.com/juste-un-gars/anemone/internal/i18n"
+	"github.com/juste-un-gars/anemone/internal/logger"
+	"github.com/juste-un-gars/anemone/internal/peers"
+	"github.com/juste-un-gars/anemone/internal/shares"
+	"github.com/juste-un-gars/anemone/internal/sync"
+)
+
+// SharePeerOption is a peer checkbox in the share destinations form
+type SharePeerOption struct {
+	ID       int
+	Name     string
+	Enabled  bool
+	Selected bool
+}
+
+// SharePeerSettings holds the destinations form state for one share
+type SharePeerSettings struct {
+	Share       *shares.Share
+	Peers       []SharePeerOption
+	AllPeers    bool // No explicit selection: sent to every enabled peer
+	Replication *sync.ShareReplication
+}
+
+// getSharePeerSettings builds the destinations form state for a share
+func (s *Server) getSharePeerSettings(share *shares.Share, replication map[int]*sync.ShareReplication) (*SharePeerSettings, error) {
+	allPeers, err := peers.GetAll(s.db)
+	if err != nil {
+		return nil, err
+	}
+
+	selectedIDs, err := shares.GetPeerIDs(s.db, share.ID)
+	if err != nil {
+		return nil, err
+	}
+	selected := make(map[int]bool, len(selectedIDs))
+	for _, id := range selectedIDs {
+		selected[id] = true
+	}
+
+	settings := &SharePeerSettings{
+		Share:       share,
+		AllPeers:    len(selectedIDs) == 0,
+		Replication: replication[share.ID],
+	}
+	for _, p := range allPeers {
+		settings.Peers = append(settings.Peers, SharePeerOption{
+			ID:       p.ID,
+			Name:     p.Name,
+			Enabled:  p.Enabled,
+			Selected: selected[p.ID],
+		})
+	}
+
+	return settings, nil
+}
+
+// getReplicationByShare returns replication state indexed by share ID
+func (s *Server) getReplicationByShare() map[int]*sync.ShareReplication {
+	byShare := make(map[int]*sync.ShareReplication)
+	replication, err := sync.GetShareReplication(s.db)
+	if err != nil {
+		logger.Info("Error evaluating share replication", "error", err)
+		return byShare
+	}
+	for _, r := range replication {
+		byShare[r.ShareID] = r
+	}
+	return byShare
+}
+
+// parseSharePeersForm reads the selected peers and replication factor from a submitted form
+func (s *Server) parseSharePeersForm(r *http.Request) ([]int, int, error) {
+	if err := r.ParseForm(); err != nil {
+		return nil, 0, fmt.Errorf("invalid form data")
+	}
+
+	// Only accept IDs of existing peers
+	allPeers, err := peers.GetAll(s.db)
+	if err != nil {
+		return nil, 0, err
+	}
+	known := make(map[int]bool, len(allPeers))
+	for _, p := range allPeers {
+		known[p.ID] = true
+	}
+
+	var peerIDs []int
+	if r.FormValue("all_peers") != "on" {
+		for _, v := range r.Form["peer_ids"] {
+			id, err := strconv.Atoi(v)
+			if err != nil || !known[id] {
+				return nil, 0, fmt.Errorf("invalid peer")
+			}
+			peerIDs = append(peerIDs, id)
+		}
+		if len(peerIDs) == 0 {
+			return nil, 0, fmt.Errorf("select at least one peer")
+		}
+	}
+
+	minReplicas := 0
+	if v := strings.TrimSpace(r.FormValue("min_replicas")); v != "" {
+		minReplicas, err = strconv.Atoi(v)
+		if err != nil || minReplicas < 0 || minReplicas > len(allPeers) {
+			return nil, 0, fmt.Errorf("invalid replication factor")
+		}
+	}
+
+	return peerIDs, minReplicas, nil
+}
+
+// saveSharePeers stores the destinations of a share
+func (s *Server) saveSharePeers(shareID int, peerIDs []int, minReplicas int) error {
+	if err := shares.SetPeers(s.db, shareID, peerIDs); err != nil {
+		return err
+	}
+	return shares.UpdateMinReplicas(s.db, shareID, minReplicas)
+}
+
+// handleAdminSharesActions handles per-share admin actions
+// URL format: /admin/shares/{id}/peers
+func (s *Server) handleAdminSharesActions(w http.ResponseWriter, r *http.Request) {
+	session, ok := auth.GetSessionFromContext(r)
+	if !ok {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/admin/shares/")
+	parts := strings.Split(path, "/")
+	if len(parts) < 2 || (parts[1] != "peers" && parts[1] != "nfs") {
+		http.Error(w, "Invalid URL", http.StatusBadRequest)
+		return
+	}
+
+	shareID, err := strconv.Atoi(parts[0])
+	if err != nil {
+		http.Error(w, "Invalid share ID", http.StatusBadRequest)
+		return
+	}
+
+	share, err := shares.GetByID(s.db, shareID)
+	if err != nil {
+		http.Error(w, "Share not found", http.StatusNotFound)
+		return
+	}
+
+	if parts[1] == "nfs" {
+		s.handleAdminShareNFS(w, r, session, share)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		lang := s.getLang(r)
+		settings, err := s.getSharePeerSettings(share, s.getReplicationByShare())
+		if err != nil {
+			logger.Info("Error loading share peers", "share_id", shareID, "error", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		var owner string
+		s.db.QueryRow("SELECT username FROM users WHERE id = ?", share.UserID).Scan(&owner)
+
+		data := struct {
+			V2TemplateData
+			Settings *SharePeerSettings
+			Owner    string
+			Success  string
+			Error    string
+		}{
+			V2TemplateData: V2TemplateData{
+				Lang:       lang,
+				Title:      i18n.T(lang, "shares.peers.title"),
+				ActivePage: "shares",
+				Session:    session,
+			},
+			Settings: settings,
+			Owner:    owner,
+			Success:  r.URL.Query().Get("success"),
+			Error:    r.URL.Query().Get("error"),
+		}
+
+		tmpl := s.loadV2Page("v2_shares_peers.html", s.funcMap)
+		if err := tmpl.ExecuteTemplate(w, "v2_base", data); err != nil {
+			logger.Info("Error rendering share peers template", "error", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		}
+
+	case http.MethodPost:
+		redirect := fmt.Sprintf("/admin/shares/%d/peers", shareID)
+		peerIDs, minReplicas, err := s.parseSharePeersForm(r)
+		if err != nil {
+			http.Redirect(w, r, redirect+"?error="+url.QueryEscape(err.Error()), http.StatusSeeOther)
+			return
+		}
+
+		if err := s.saveSharePeers(shareID, peerIDs, minReplicas); err != nil {
+			logger.Info("Error saving share peers", "share_id", shareID, "error", err)
+			http.Redirect(w, r, redirect+"?error=Failed+to+save+destinations", http.StatusSeeOther)
+			return
+		}
+
+		logger.Info("Admin updated share destinations", "username", session.Username, "share_id", shareID, "peers", peerIDs, "min_replicas", minReplicas)
+		http.Redirect(w, r, redirect+"?success=1", http.StatusSeeOther)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleSettingsSharePeers lets a user choose the peers their own shares are sent to
+func (s *Server) handleSettingsSharePeers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	session, _ := auth.GetSessionFromContext(r)
+
+	shareID, err := strconv.Atoi(r.FormValue("share_id"))
+	if err != nil {
+		http.Redirect(w, r, "/settings?error=Invalid+share", http.StatusSeeOther)
+		return
+	}
+
+	share, err := shares.GetByID(s.db, shareID)
+	if err != nil || share.UserID != session.UserID {
+		http.Redirect(w, r, "/settings?error=Invalid+share", http.StatusSeeOther)
+		return
+	}
+
+	peerIDs, minReplicas, err := s.parseSharePeersForm(r)
+	if err != nil {
+		http.Redirect(w, r, "/settings?error="+url.QueryEscape(err.Error()), http.StatusSeeOther)
+		return
+	}
+
+	if err := s.saveSharePeers(shareID, peerIDs, minReplicas); err != nil {
+		logger.Info("Error saving share peers", "share_id", shareID, "error", err)
+		http.Redirect(w, r, "/settings?error=Failed+to+save+destinations", http.StatusSeeOther)
+		return
+	}
+
+	logger.Info("User updated share destinations", "username", session.Username, "share_id", shareID, "peers", peerIDs, "min_replicas", minReplicas)
+	http.Redirect(w, r, "/settings?success="+url.QueryEscape(i18n.T(s.getLang(r), "shares.peers.saved")), http.StatusSeeOther)
+}
@@ -316,8 +316,9 @@ func (s *Server) handleAPISyncFile(w http.ResponseWriter, r *http.Request) {
 }
 
 // handleAPISyncFileUpload handles uploading a single encrypted file
-// POST /api/sync/file?source_server=X
+// POST /api/sync/file?source_server=X[&verify=1]
 // Multipart form with: user_id, share_name, relative_path, file
+// With verify=1 the file is flushed, read back and its checksum returned
 func (s *Server) handleAPISyncFileUpload(w http.ResponseWriter, r *http.Request) {
 	// Parse multipart form (max 10GB)
 	if err := r.ParseMultipartForm(10 << 30); err != nil {
@@ -390,12 +391,32 @@ func (s *Server) handleAPISyncFileUpload(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	// Read the file back from disk when the sender wants to check what was really stored
+	checksum := ""
+	if r.URL.Query().Get("verify") == "1" {
+		if err := outFile.Sync(); err != nil {
+			logger.Info("Error flushing file", "error", err)
+			http.Error(w, "Failed to write file", http.StatusInternalServerError)
+			return
+		}
+		checksum, err = sync.DiskChecksum(targetPath)
+		if err != nil {
+			logger.Info("Error reading back file", "error", err)
+			http.Error(w, "Failed to read back file", http.StatusInternalServerError)
+			return
+		}
+	}
+
 	logger.Info("Successfully uploaded file: (user , share )", "relative_path", relativePath, "user_id", userID, "share_name", shareName)
 
 	// Return success
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	fmt.Fprintf(w, `{"success": true, "message": "File uploaded"}`)
+	if checksum == "" {
+		fmt.Fprint(w, `{"success": true, "message": "File uploaded"}`)
+		return
+	}
+	fmt.Fprintf(w, `{"success": true, "message": "File uploaded", "sha256": "%s"}`, checksum)
 }
 
 // handleAPISyncFileDelete handles deleting a single file from backup
@@ -18,6 +18,7 @@ import (
 	"time"
 
 	"github.com/juste-un-gars/anemone/internal/logger"
+	"github.com/juste-un-gars/anemone/internal/sync"
 )
 
 // handleAPISyncListPhysicalFiles lists all physical .enc files in a backup directory
@@ -359,3 +360,63 @@ func (s *Server) handleAPISyncDownloadEncryptedFile(w http.ResponseWriter, r *ht
 
 	logger.Info("Sent encrypted file for user share", "file_path", filePath, "user_id", userID, "share_name", shareName)
 }
+
+// handleAPISyncVerify answers an integrity challenge on stored files
+// POST /api/sync/verify?source_server=X with {"user_id", "share_name", "nonce", "paths"}
+// Each file is read back from disk and proven with an HMAC of its checksum keyed by the
+// nonce, so the origin server can check it without downloading it.
+func (s *Server) handleAPISyncVerify(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	sourceServer := r.URL.Query().Get("source_server")
+	if sourceServer == "" {
+		sourceServer = "unknown"
+	}
+
+	var challenge sync.VerifyChallenge
+	if err := json.NewDecoder(io.LimitReader(r.Body, 10<<20)).Decode(&challenge); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if challenge.ShareName == "" || challenge.Nonce == "" {
+		http.Error(w, "Missing share_name or nonce", http.StatusBadRequest)
+		return
+	}
+
+	// Security check: prevent path traversal
+	if isPathTraversal(sourceServer) || isPathTraversal(challenge.ShareName) {
+		http.Error(w, "Invalid source_server or share_name (path traversal detected)", http.StatusBadRequest)
+		return
+	}
+
+	backupDir := filepath.Join(s.cfg.IncomingDir, sourceServer, fmt.Sprintf("%d_%s", challenge.UserID, challenge.ShareName))
+	resp := sync.VerifyResponse{
+		Proofs: make(map[string]string, len(challenge.Paths)),
+		Errors: make(map[string]string),
+	}
+	for _, p := range challenge.Paths {
+		if isPathTraversal(p) || filepath.IsAbs(p) {
+			resp.Errors[p] = "invalid path"
+			continue
+		}
+		checksum, err := sync.DiskChecksum(filepath.Join(backupDir, p))
+		if err != nil {
+			if os.IsNotExist(err) {
+				resp.Errors[p] = "missing on peer"
+			} else {
+				resp.Errors[p] = err.Error()
+			}
+			continue
+		}
+		resp.Proofs[p] = sync.VerifyProof(challenge.Nonce, checksum)
+	}
+
+	logger.Info("Answered integrity challenge", "source_server", sourceServer, "user_id", challenge.UserID,
+		"files", len(challenge.Paths), "failed", len(resp.Errors))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,139 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+// This file contains the sync API handler storing the configuration backups pushed
+// by peers, and serving them back to a server being rebuilt.
+
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/juste-un-gars/anemone/internal/logger"
+	"github.com/juste-un-gars/anemone/internal/peers"
+	"github.com/juste-un-gars/anemone/internal/serverbackup"
+)
+
+// handleAPISyncServerBackup handles the configuration backups of peers
+// GET  /api/sync/server-backup                               lists the stored backups
+// GET  /api/sync/server-backup?source_server=X&file=Y         downloads a backup
+// POST /api/sync/server-backup?source_server=X (multipart "file") stores a backup
+func (s *Server) handleAPISyncServerBackup(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.handleAPISyncServerBackupGet(w, r)
+	case http.MethodPost:
+		s.handleAPISyncServerBackupUpload(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleAPISyncServerBackupGet lists the stored backups, or downloads one of them
+func (s *Server) handleAPISyncServerBackupGet(w http.ResponseWriter, r *http.Request) {
+	sourceServer := r.URL.Query().Get("source_server")
+	filename := r.URL.Query().Get("file")
+
+	if sourceServer == "" {
+		backups, err := serverbackup.ListPeerBackups(s.cfg.IncomingDir)
+		if err != nil {
+			logger.Info("Error listing peer server backups", "error", err)
+			http.Error(w, "Failed to list backups", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(backups)
+		return
+	}
+
+	path, err := serverbackup.PeerBackupPath(s.cfg.IncomingDir, sourceServer, filename)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		http.Error(w, "Backup not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		logger.Info("Error reading peer server backup", "path", path, "error", err)
+		http.Error(w, "Failed to read backup", http.StatusInternalServerError)
+		return
+	}
+
+	logger.Info("Peer server backup downloaded", "source_server", sourceServer, "filename", filename, "remote_addr", r.RemoteAddr)
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filepath.Base(path)))
+	w.Write(data)
+}
+
+// isSourcePeer reports whether a request comes from the address of the
+// registered peer named sourceServer
+func (s *Server) isSourcePeer(r *http.Request, sourceServer string) bool {
+	allPeers, err := peers.GetAll(s.db)
+	if err != nil {
+		logger.Info("Error listing peers", "error", err)
+		return false
+	}
+	ip := clientIP(r)
+	for _, p := range allPeers {
+		if p.Name != sourceServer {
+			continue
+		}
+		addrs, err := net.LookupHost(p.Address)
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if addr == ip {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// handleAPISyncServerBackupUpload stores a backup pushed by a peer. Each store
+// drops the oldest backups of the sender, so a peer may only store under its
+// own name: any peer knowing the sync password could otherwise evict the
+// backups of another server.
+func (s *Server) handleAPISyncServerBackupUpload(w http.ResponseWriter, r *http.Request) {
+	sourceServer := r.URL.Query().Get("source_server")
+	if sourceServer == "" || isPathTraversal(sourceServer) {
+		http.Error(w, "Invalid source_server", http.StatusBadRequest)
+		return
+	}
+	if !s.isSourcePeer(r, sourceServer) {
+		logger.Warn("Refused server backup from unregistered source", "source_server", sourceServer, "remote_addr", r.RemoteAddr)
+		http.Error(w, "source_server is not a registered peer", http.StatusForbidden)
+		return
+	}
+
+	if err := r.ParseMultipartForm(64 << 20); err != nil {
+		http.Error(w, "Failed to parse form", http.StatusBadRequest)
+		return
+	}
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "Missing file", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	if err := serverbackup.StorePeerBackup(s.cfg.IncomingDir, sourceServer, header.Filename, file); err != nil {
+		logger.Info("Error storing peer server backup", "source_server", sourceServer, "error", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	logger.Info("Stored server backup from peer", "source_server", sourceServer, "filename", header.Filename)
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintf(w, `{"success": true}`)
+}
@@ -0,0 +1,91 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+// This file contains the sync API handlers receiving the ZFS replicas sent by
+// peers with zfs send.
+
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/juste-un-gars/anemone/internal/logger"
+	"github.com/juste-un-gars/anemone/internal/zfsrepl"
+)
+
+// handleAPISyncZFSState reports or resets the replica of a peer
+// GET    /api/sync/zfs/state?source_server=X&target=Y  returns the snapshots and resume token
+// DELETE /api/sync/zfs/state?source_server=X&target=Y  discards an interrupted receive
+func (s *Server) handleAPISyncZFSState(w http.ResponseWriter, r *http.Request) {
+	dataset, err := zfsrepl.ReceivedDataset(s.db, r.URL.Query().Get("source_server"), r.URL.Query().Get("target"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		state, err := zfsrepl.GetReceiveState(dataset)
+		if err != nil {
+			logger.Info("Error getting ZFS replica state", "dataset", dataset, "error", err)
+			http.Error(w, "Failed to get replica state", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(state)
+
+	case http.MethodDelete:
+		if err := zfsrepl.AbortReceive(dataset); err != nil {
+			logger.Info("Error aborting ZFS receive", "dataset", dataset, "error", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		logger.Info("Aborted interrupted ZFS receive", "dataset", dataset)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"success": true}`)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleAPISyncZFSReceive applies a zfs send stream pushed by a peer
+// POST /api/sync/zfs/receive?source_server=X&target=Y&mode=full|incremental|resume&keep=N
+func (s *Server) handleAPISyncZFSReceive(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	sourceServer := r.URL.Query().Get("source_server")
+	dataset, err := zfsrepl.ReceivedDataset(s.db, sourceServer, r.URL.Query().Get("target"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	keep, err := strconv.Atoi(r.URL.Query().Get("keep"))
+	if err != nil || keep < 1 {
+		http.Error(w, "Invalid keep", http.StatusBadRequest)
+		return
+	}
+
+	// A stream can take hours: lift the server timeouts for this request
+	rc := http.NewResponseController(w)
+	rc.SetReadDeadline(time.Time{})
+	rc.SetWriteDeadline(time.Time{})
+
+	if err := zfsrepl.ReceiveStream(dataset, r.URL.Query().Get("mode"), keep, r.Body); err != nil {
+		logger.Info("Error receiving ZFS stream", "source_server", sourceServer, "dataset", dataset, "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	logger.Info("Received ZFS replica from peer", "source_server", sourceServer, "dataset", dataset)
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintf(w, `{"success": true}`)
+}
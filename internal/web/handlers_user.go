@@ -12,8 +12,11 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/juste-un-gars/anemone/internal/alerts"
 	"github.com/juste-un-gars/anemone/internal/auth"
+	"github.com/juste-un-gars/anemone/internal/capacity"
 	"github.com/juste-un-gars/anemone/internal/i18n"
+	"github.com/juste-un-gars/anemone/internal/nfs"
 	"github.com/juste-un-gars/anemone/internal/peers"
 	"github.com/juste-un-gars/anemone/internal/quota"
 	"github.com/juste-un-gars/anemone/internal/shares"
@@ -21,6 +24,7 @@ import (
 	"github.com/juste-un-gars/anemone/internal/sync"
 	"github.com/juste-un-gars/anemone/internal/trash"
 	"github.com/juste-un-gars/anemone/internal/updater"
+	"github.com/juste-un-gars/anemone/internal/usbbackup"
 	"github.com/juste-un-gars/anemone/internal/users"
 )
 
@@ -70,6 +74,36 @@ func (s *Server) handleDashboard(w http.ResponseWriter, r *http.Request) {
 		// Admin: render v2 dashboard
 		activity := s.getRecentActivity(lang, 5)
 
+		underReplicated, err := sync.GetUnderReplicatedShares(s.db)
+		if err != nil {
+			logger.Info("Warning: Failed to evaluate share replication", "error", err)
+		}
+
+		rpoWarnings, err := usbbackup.GetRPOWarnings(s.db)
+		if err != nil {
+			logger.Info("Warning: Failed to evaluate USB backup RPO", "error", err)
+		}
+
+		smartAlerts, err := alerts.List(s.db, alerts.SourceSMART, true, 5)
+		if err != nil {
+			logger.Info("Warning: Failed to get SMART alerts", "error", err)
+		}
+
+		poolAlerts, err := alerts.List(s.db, alerts.SourcePool, true, 5)
+		if err != nil {
+			logger.Info("Warning: Failed to get pool alerts", "error", err)
+		}
+
+		forecasts, err := s.getDashboardForecasts()
+		if err != nil {
+			logger.Info("Warning: Failed to get capacity forecasts", "error", err)
+		}
+
+		capacityAlerts, err := alerts.List(s.db, alerts.SourceCapacity, true, 5)
+		if err != nil {
+			logger.Info("Warning: Failed to get capacity alerts", "error", err)
+		}
+
 		data := V2DashboardData{
 			V2TemplateData: V2TemplateData{
 				Lang:       lang,
@@ -77,9 +111,15 @@ func (s *Server) handleDashboard(w http.ResponseWriter, r *http.Request) {
 				ActivePage: "dashboard",
 				Session:    session,
 			},
-			Stats:          stats,
-			RecentActivity: activity,
-			UpdateInfo:     updateInfo,
+			Stats:           stats,
+			RecentActivity:  activity,
+			UpdateInfo:      updateInfo,
+			UnderReplicated: underReplicated,
+			USBRPOWarnings:  rpoWarnings,
+			SMARTAlerts:     smartAlerts,
+			PoolAlerts:      poolAlerts,
+			Capacity:        forecasts,
+			CapacityAlerts:  capacityAlerts,
 		}
 
 		tmpl := s.loadV2Page("v2_dashboard.html", s.funcMap)
@@ -91,9 +131,15 @@ func (s *Server) handleDashboard(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// User: render v2 user dashboard
+	forecasts, err := s.getUserForecasts(session.UserID)
+	if err != nil {
+		logger.Info("Warning: Failed to get capacity forecasts", "error", err)
+	}
+
 	userData := struct {
 		V2TemplateData
-		Stats *DashboardStats
+		Stats    *DashboardStats
+		Capacity []capacity.Forecast
 	}{
 		V2TemplateData: V2TemplateData{
 			Lang:       lang,
@@ -101,7 +147,8 @@ func (s *Server) handleDashboard(w http.ResponseWriter, r *http.Request) {
 			ActivePage: "dashboard",
 			Session:    session,
 		},
-		Stats: stats,
+		Stats:    stats,
+		Capacity: forecasts,
 	}
 
 	tmplUser := s.loadV2UserPage("v2_dashboard_user.html", s.funcMap)
@@ -130,8 +177,8 @@ func (s *Server) handleTrash(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get user's shares
-	userShares, err := shares.GetByUser(s.db, session.UserID)
+	// Get user's shares, with the shares of its groups
+	userShares, err := s.browsableShares(session.UserID)
 	if err != nil {
 		logger.Info("Error getting shares", "error", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
@@ -215,21 +262,8 @@ func (s *Server) handleTrashActions(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Find the share
-	userShares, err := shares.GetByUser(s.db, session.UserID)
+	targetShare, writable, err := s.findShare(session.UserID, shareName)
 	if err != nil {
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		return
-	}
-
-	var targetShare *shares.Share
-	for _, share := range userShares {
-		if share.Name == shareName {
-			targetShare = share
-			break
-		}
-	}
-
-	if targetShare == nil {
 		http.Error(w, "Share not found", http.StatusNotFound)
 		return
 	}
@@ -237,6 +271,10 @@ func (s *Server) handleTrashActions(w http.ResponseWriter, r *http.Request) {
 	// Execute action
 	switch action {
 	case "restore":
+		if !writable {
+			http.Error(w, "Share is read-only", http.StatusForbidden)
+			return
+		}
 		err = trash.RestoreItem(targetShare.Path, user.Username, relPath)
 		if err != nil {
 			logger.Info("Error restoring item", "error", err)
@@ -293,11 +331,26 @@ func (s *Server) handleAdminShares(w http.ResponseWriter, r *http.Request) {
 	smbStatus, _ := smb.GetServiceStatus()
 	smbInstalled := smb.CheckSambaInstalled()
 
+	// Get NFS status and exports
+	nfsStatus, _ := nfs.GetServiceStatus()
+	nfsExports := make(map[int]*nfs.Export)
+	exports, err := nfs.GetAll(s.db)
+	if err != nil {
+		logger.Info("Error getting NFS exports", "error", err)
+	}
+	for _, e := range exports {
+		nfsExports[e.ShareID] = e
+	}
+
 	data := struct {
 		V2TemplateData
 		Shares       []*shares.Share
+		Replication  map[int]*sync.ShareReplication
 		SMBStatus    string
 		SMBInstalled bool
+		NFSStatus    string
+		NFSInstalled bool
+		NFSExports   map[int]*nfs.Export
 	}{
 		V2TemplateData: V2TemplateData{
 			Lang:       lang,
@@ -306,8 +359,12 @@ func (s *Server) handleAdminShares(w http.ResponseWriter, r *http.Request) {
 			Session:    session,
 		},
 		Shares:       allShares,
+		Replication:  s.getReplicationByShare(),
 		SMBStatus:    smbStatus,
 		SMBInstalled: smbInstalled,
+		NFSStatus:    nfsStatus,
+		NFSInstalled: nfs.CheckNFSInstalled(),
+		NFSExports:   nfsExports,
 	}
 
 	tmpl := s.loadV2Page("v2_shares.html", s.funcMap)
@@ -318,7 +375,7 @@ func (s *Server) handleAdminShares(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// handleSyncShare triggers manual synchronization of a share to all enabled peers
+// handleSyncShare triggers manual synchronization of a share to its selected peers
 func (s *Server) handleSyncShare(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -369,9 +426,17 @@ func (s *Server) handleSyncShare(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Keep only enabled peers selected for this share
+	peerMap, err := shares.GetPeerMap(s.db)
+	if err != nil {
+		logger.Info("Error getting share peers", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
 	enabledPeers := []*peers.Peer{}
 	for _, peer := range allPeers {
-		if peer.Enabled {
+		if peer.Enabled && peerMap.Targets(shareID, peer.ID) {
 			enabledPeers = append(enabledPeers, peer)
 		}
 	}
@@ -406,6 +471,7 @@ func (s *Server) handleSyncShare(w http.ResponseWriter, r *http.Request) {
 			PeerPort:         peer.Port,
 			SourceServer:     serverName,
 			PeerTimeoutHours: peer.SyncTimeoutHours,
+			VerifyUploads:    peer.VerifyUploads,
 		}
 
 		// Use incremental sync (manifest-based)
@@ -433,7 +499,12 @@ func (s *Server) handleSyncShare(w http.ResponseWriter, r *http.Request) {
 // handleSettings shows the user settings page
 func (s *Server) handleSettings(w http.ResponseWriter, r *http.Request) {
 	session, _ := auth.GetSessionFromContext(r)
+	s.renderSettings(w, r, session, nil, "")
+}
 
+// renderSettings renders the settings page. newAppPassword is the app password
+// just created, shown this once with its password in clear.
+func (s *Server) renderSettings(w http.ResponseWriter, r *http.Request, session *auth.Session, newAppPassword *users.AppPassword, newPassword string) {
 	// Get user from database
 	user, err := users.GetByID(s.db, session.UserID)
 	if err != nil {
@@ -442,11 +513,45 @@ func (s *Server) handleSettings(w http.ResponseWriter, r *http.Request) {
 	}
 
 	lang := s.getLang(r)
+
+	// Destinations of the user's synchronized shares
+	var sharePeers []*SharePeerSettings
+	userShares, err := shares.GetByUser(s.db, session.UserID)
+	if err != nil {
+		logger.Info("Error getting shares for settings", "error", err)
+	}
+	replication := s.getReplicationByShare()
+	for _, share := range userShares {
+		if !share.SyncEnabled {
+			continue
+		}
+		settings, err := s.getSharePeerSettings(share, replication)
+		if err != nil {
+			logger.Info("Error loading share peers", "share_id", share.ID, "error", err)
+			continue
+		}
+		sharePeers = append(sharePeers, settings)
+	}
+
+	appPasswords, err := users.GetAppPasswords(s.db, session.UserID)
+	if err != nil {
+		logger.Info("Error loading app passwords", "user_id", session.UserID, "error", err)
+	}
+	scheme := "https"
+	if r.TLS == nil {
+		scheme = "http"
+	}
+
 	data := struct {
 		V2TemplateData
-		User    *users.User
-		Success string
-		Error   string
+		User           *users.User
+		SharePeers     []*SharePeerSettings
+		AppPasswords   []*users.AppPassword
+		NewAppPassword *users.AppPassword
+		NewPassword    string
+		DAVURL         string
+		Success        string
+		Error          string
 	}{
 		V2TemplateData: V2TemplateData{
 			Lang:       lang,
@@ -454,9 +559,14 @@ func (s *Server) handleSettings(w http.ResponseWriter, r *http.Request) {
 			ActivePage: "settings",
 			Session:    session,
 		},
-		User:    user,
-		Success: r.URL.Query().Get("success"),
-		Error:   r.URL.Query().Get("error"),
+		User:           user,
+		SharePeers:     sharePeers,
+		AppPasswords:   appPasswords,
+		NewAppPassword: newAppPassword,
+		NewPassword:    newPassword,
+		DAVURL:         scheme + "://" + r.Host + davPrefix + "/",
+		Success:        r.URL.Query().Get("success"),
+		Error:          r.URL.Query().Get("error"),
 	}
 
 	tmpl := s.loadV2UserPage("v2_settings_user.html", s.funcMap)
@@ -557,6 +667,7 @@ func (s *Server) handleSettingsPassword(w http.ResponseWriter, r *http.Request)
 		}
 		return
 	}
+	forgetDAVAuth(session.UserID)
 
 	// Get user to determine language for success message
 	user, _ := users.GetByID(s.db, session.UserID)
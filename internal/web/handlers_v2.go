@@ -14,12 +14,17 @@ import (
 	"sort"
 	"time"
 
+	"github.com/juste-un-gars/anemone/internal/alerts"
 	"github.com/juste-un-gars/anemone/internal/auth"
+	"github.com/juste-un-gars/anemone/internal/bulkrestore"
+	"github.com/juste-un-gars/anemone/internal/capacity"
 	"github.com/juste-un-gars/anemone/internal/i18n"
 	"github.com/juste-un-gars/anemone/internal/incoming"
+	"github.com/juste-un-gars/anemone/internal/integrity"
 	"github.com/juste-un-gars/anemone/internal/logger"
 	"github.com/juste-un-gars/anemone/internal/rclone"
 	"github.com/juste-un-gars/anemone/internal/serverbackup"
+	"github.com/juste-un-gars/anemone/internal/sync"
 	"github.com/juste-un-gars/anemone/internal/syncconfig"
 	"github.com/juste-un-gars/anemone/internal/updater"
 	"github.com/juste-un-gars/anemone/internal/usbbackup"
@@ -36,9 +41,15 @@ type V2TemplateData struct {
 // V2DashboardData holds data for the v2 dashboard page.
 type V2DashboardData struct {
 	V2TemplateData
-	Stats          *DashboardStats
-	RecentActivity []V2Activity
-	UpdateInfo     *updater.UpdateInfo
+	Stats           *DashboardStats
+	RecentActivity  []V2Activity
+	UpdateInfo      *updater.UpdateInfo
+	UnderReplicated []*sync.ShareReplication
+	USBRPOWarnings  []usbbackup.RPOWarning
+	SMARTAlerts     []alerts.Alert
+	PoolAlerts      []alerts.Alert
+	Capacity        []capacity.Forecast
+	CapacityAlerts  []alerts.Alert
 }
 
 // V2Activity represents a recent activity item on the dashboard.
@@ -55,6 +66,7 @@ type V2BackupsData struct {
 	// USB tab
 	USBBackups []V2USBBackup
 	USBDrives  []V2Drive
+	USBEvents  []V2USBEvent
 
 	// Cloud tab
 	RcloneConfigs    []V2RcloneConfig
@@ -73,13 +85,20 @@ type V2BackupsData struct {
 	IncomingSize    string
 
 	// Server backup tab
-	ServerBackups []V2ServerBackup
+	ServerBackups         []V2ServerBackup
+	ServerRecoveryEnabled bool                            // Offsite copies encrypted with the recovery passphrase
+	ServerReplication     *serverbackup.ReplicationStatus // Last push to the peers (nil if none)
 
 	// Recent tab
 	RecentBackups []V2RecentBackup
 
+	// Integrity tab
+	IntegrityChecks   []*integrity.Check
+	IntegritySettings *integrity.Settings
+	IntegrityRunning  bool
+
 	// UI state
-	ActiveTab string // "recent", "usb", "cloud", "p2p", "incoming", "server"
+	ActiveTab string // "recent", "usb", "cloud", "p2p", "incoming", "server", "integrity"
 	Flash     string // message text
 	FlashType string // "success", "error", "info"
 }
@@ -90,8 +109,18 @@ type V2USBBackup struct {
 	Name       string
 	DevicePath string
 	IsMounted  bool
+	WrongDrive bool   // Mounted drive is not part of the rotation set
+	DriveName  string // Drive of the rotation set currently connected
 	LastSync   string
 	LastStatus string
+
+	OverdueDrives int // Drives last synced longer ago than the RPO
+}
+
+// V2USBEvent holds the outcome of a backup started by plugging in a drive.
+type V2USBEvent struct {
+	Message string
+	Type    string // "success", "error", "info"
 }
 
 // V2Drive holds available drive display data.
@@ -111,6 +140,14 @@ type V2RcloneConfig struct {
 	Encrypted    bool
 	LastSync     string
 	LastStatus   string
+	LastError    string
+	FileErrors   int // Files that failed during the last sync
+
+	// Latest integrity check (LastCheckID is 0 if never checked)
+	LastCheckID     int
+	LastCheckStatus string
+	LastCheckFailed int
+	LastCheckAt     string
 }
 
 // V2SyncEntry holds a recent P2P sync log entry.
@@ -188,6 +225,7 @@ func (s *Server) handleAdminBackups(w http.ResponseWriter, r *http.Request) {
 
 	// USB backups
 	data.USBBackups, data.USBDrives = s.getV2USBData(lang)
+	data.USBEvents = getV2USBEvents(lang)
 
 	// Cloud/Rclone backups
 	data.RcloneConfigs, data.SSHKeyExists, data.SSHKeyPublicKey, data.SSHKeyRelPath = s.getV2RcloneData(lang)
@@ -200,10 +238,20 @@ func (s *Server) handleAdminBackups(w http.ResponseWriter, r *http.Request) {
 
 	// Server backups
 	data.ServerBackups = s.getV2ServerBackupData()
+	data.ServerRecoveryEnabled = serverbackup.HasRecoveryPassphrase(s.db)
+	data.ServerReplication, _ = serverbackup.GetReplicationStatus(s.db)
 
 	// Recent backups (consolidated)
 	data.RecentBackups = s.getV2RecentBackups(lang, 10)
 
+	// Integrity checks
+	data.IntegrityChecks, _ = integrity.GetRecent(s.db, 20)
+	data.IntegritySettings, _ = integrity.GetSettings(s.db)
+	if data.IntegritySettings == nil {
+		data.IntegritySettings = &integrity.Settings{IntervalDays: 30, SamplePercent: 10}
+	}
+	data.IntegrityRunning = integrity.IsRunning()
+
 	// Active tab from query params
 	q := r.URL.Query()
 	data.ActiveTab = q.Get("tab")
@@ -216,6 +264,9 @@ func (s *Server) handleAdminBackups(w http.ResponseWriter, r *http.Request) {
 	case q.Get("syncing") != "":
 		data.Flash = i18n.T(lang, "rclone.sync_started")
 		data.FlashType = "info"
+	case q.Get("cancelled") != "":
+		data.Flash = i18n.T(lang, "rclone.cancel_requested")
+		data.FlashType = "info"
 	case q.Get("test_success") != "":
 		data.Flash = i18n.T(lang, "rclone.test_success")
 		data.FlashType = "success"
@@ -231,6 +282,15 @@ func (s *Server) handleAdminBackups(w http.ResponseWriter, r *http.Request) {
 	case q.Get("success") != "":
 		data.Flash = i18n.T(lang, "rclone.created")
 		data.FlashType = "success"
+	case q.Get("integrity_saved") != "":
+		data.Flash = i18n.T(lang, "integrity.settings_saved")
+		data.FlashType = "success"
+	case q.Get("recovery_saved") != "":
+		data.Flash = i18n.T(lang, "server_backup.recovery.saved")
+		data.FlashType = "success"
+	case q.Get("integrity_started") != "":
+		data.Flash = i18n.T(lang, "integrity.started")
+		data.FlashType = "info"
 	case q.Get("error") != "":
 		data.Flash = q.Get("error")
 		data.FlashType = "error"
@@ -243,14 +303,16 @@ func (s *Server) handleAdminBackups(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// getRecentActivity returns recent sync log entries for the dashboard.
+// getRecentActivity returns recent sync log entries and restore jobs for the dashboard.
 func (s *Server) getRecentActivity(lang string, limit int) []V2Activity {
 	rows, err := s.db.Query(`
 		SELECT sl.status, sl.started_at, sl.completed_at,
-		       COALESCE(u.username, '?') AS username,
+		       COALESCE(u.username, '@' || g.name || '/' || gs.name, '?') AS username,
 		       COALESCE(p.name, '?') AS peer_name
 		FROM sync_log sl
 		LEFT JOIN users u ON sl.user_id = u.id
+		LEFT JOIN group_shares gs ON sl.group_share_id = gs.id
+		LEFT JOIN groups g ON gs.group_id = g.id
 		LEFT JOIN peers p ON sl.peer_id = p.id
 		ORDER BY sl.started_at DESC
 		LIMIT ?
@@ -261,7 +323,11 @@ func (s *Server) getRecentActivity(lang string, limit int) []V2Activity {
 	}
 	defer rows.Close()
 
-	var activities []V2Activity
+	type timedActivity struct {
+		at       time.Time
+		activity V2Activity
+	}
+	var items []timedActivity
 	for rows.Next() {
 		var status, username, peerName string
 		var startedAt time.Time
@@ -277,11 +343,48 @@ func (s *Server) getRecentActivity(lang string, limit int) []V2Activity {
 			desc += " ✗"
 		}
 
-		activities = append(activities, V2Activity{
+		items = append(items, timedActivity{startedAt, V2Activity{
 			Description: desc,
 			Time:        formatTimeAgo(startedAt, lang),
 			Status:      status,
-		})
+		}})
+	}
+
+	// Server-side restores are part of the activity history too
+	jobs, err := bulkrestore.ListRecentJobs(s.db, limit)
+	if err != nil {
+		logger.Info("Error querying restore jobs", "error", err)
+	}
+	for _, job := range jobs {
+		var username string
+		s.db.QueryRow("SELECT username FROM users WHERE id = ?", job.UserID).Scan(&username)
+
+		desc := fmt.Sprintf("Restore %s → %s/%s", job.PeerName, username, job.TargetShare)
+		status := job.Status
+		switch job.Status {
+		case bulkrestore.JobSuccess:
+			desc += " ✓"
+		case bulkrestore.JobError:
+			desc += " ✗"
+		case bulkrestore.JobPartial:
+			status = "warning"
+		}
+
+		items = append(items, timedActivity{job.StartedAt, V2Activity{
+			Description: desc,
+			Time:        formatTimeAgo(job.StartedAt, lang),
+			Status:      status,
+		}})
+	}
+
+	sort.Slice(items, func(i, j int) bool { return items[i].at.After(items[j].at) })
+	if len(items) > limit {
+		items = items[:limit]
+	}
+
+	var activities []V2Activity
+	for _, item := range items {
+		activities = append(activities, item.activity)
 	}
 	return activities
 }
@@ -300,14 +403,30 @@ func (s *Server) getV2USBData(lang string) ([]V2USBBackup, []V2Drive) {
 		if b.LastSync != nil {
 			lastSync = formatTimeAgo(*b.LastSync, lang)
 		}
-		v2backups = append(v2backups, V2USBBackup{
+		v2b := V2USBBackup{
 			ID:         b.ID,
 			Name:       b.Name,
 			DevicePath: b.MountPath,
 			IsMounted:  b.IsMounted(),
 			LastSync:   lastSync,
 			LastStatus: b.LastStatus,
-		})
+		}
+		if states, err := usbbackup.RotationState(s.db, b); err == nil {
+			for _, st := range states {
+				if st.Connected {
+					v2b.DriveName = st.Name
+				}
+				if st.Overdue {
+					v2b.OverdueDrives++
+				}
+			}
+			// A drive is mounted but it is not part of the rotation set
+			if v2b.IsMounted && len(states) > 0 && v2b.DriveName == "" {
+				_, err := b.MountedDrive()
+				v2b.WrongDrive = err == nil
+			}
+		}
+		v2backups = append(v2backups, v2b)
 	}
 
 	drives, err := usbbackup.DetectDrives()
@@ -325,6 +444,33 @@ func (s *Server) getV2USBData(lang string) ([]V2USBBackup, []V2Drive) {
 	return v2backups, v2drives
 }
 
+// getV2USBEvents describes the backups started by plugging in a drive during the last day.
+func getV2USBEvents(lang string) []V2USBEvent {
+	var events []V2USBEvent
+	for _, e := range usbbackup.RecentHotplugEvents(24 * time.Hour) {
+		ev := V2USBEvent{Type: "info"}
+		name := e.BackupName
+		if e.DriveName != "" {
+			name += " / " + e.DriveName
+		}
+		switch {
+		case e.Status == "running":
+			ev.Message = fmt.Sprintf(i18n.T(lang, "usb_backup.hotplug.running"), name)
+		case e.Status == "success":
+			ev.Message = fmt.Sprintf(i18n.T(lang, "usb_backup.hotplug.success"), name, e.FilesSynced)
+			ev.Type = "success"
+		case e.SafeToRemove:
+			ev.Message = fmt.Sprintf(i18n.T(lang, "usb_backup.hotplug.error_removable"), name, e.Error)
+			ev.Type = "error"
+		default:
+			ev.Message = fmt.Sprintf(i18n.T(lang, "usb_backup.hotplug.error"), name, e.Error)
+			ev.Type = "error"
+		}
+		events = append(events, ev)
+	}
+	return events
+}
+
 // getV2RcloneData retrieves rclone cloud backup data.
 func (s *Server) getV2RcloneData(lang string) ([]V2RcloneConfig, bool, string, string) {
 	backups, err := rclone.GetAll(s.db)
@@ -333,6 +479,11 @@ func (s *Server) getV2RcloneData(lang string) ([]V2RcloneConfig, bool, string, s
 		return nil, false, "", ""
 	}
 
+	fileErrors, err := rclone.CountFileErrors(s.db)
+	if err != nil {
+		logger.Info("Error counting rclone file errors", "error", err)
+	}
+
 	var configs []V2RcloneConfig
 	for _, b := range backups {
 		lastSync := i18n.T(lang, "v2.backups.never")
@@ -343,7 +494,7 @@ func (s *Server) getV2RcloneData(lang string) ([]V2RcloneConfig, bool, string, s
 		if v, ok := b.ProviderConfig["crypt_password"]; ok && v != "" {
 			encrypted = true
 		}
-		configs = append(configs, V2RcloneConfig{
+		cfg := V2RcloneConfig{
 			ID:           b.ID,
 			Name:         b.Name,
 			ProviderType: b.ProviderType,
@@ -353,7 +504,18 @@ func (s *Server) getV2RcloneData(lang string) ([]V2RcloneConfig, bool, string, s
 			Encrypted:    encrypted,
 			LastSync:     lastSync,
 			LastStatus:   b.LastStatus,
-		})
+			LastError:    b.LastError,
+			FileErrors:   fileErrors[b.ID],
+		}
+		if check, err := integrity.GetLatest(s.db, integrity.TargetCloud, b.ID); err != nil {
+			logger.Info("Error getting cloud integrity check", "error", err)
+		} else if check != nil {
+			cfg.LastCheckID = check.ID
+			cfg.LastCheckStatus = check.Status
+			cfg.LastCheckFailed = check.FilesFailed
+			cfg.LastCheckAt = formatTimeAgo(check.StartedAt, lang)
+		}
+		configs = append(configs, cfg)
 	}
 
 	keyInfo, err := rclone.GetSSHKeyInfo(s.cfg.DataDir)
@@ -382,10 +544,12 @@ func (s *Server) getV2SyncData(lang string) (bool, string, []V2SyncEntry) {
 
 	// Recent syncs
 	rows, err := s.db.Query(`
-		SELECT COALESCE(u.username, '?'), COALESCE(p.name, '?'),
+		SELECT COALESCE(u.username, '@' || g.name || '/' || gs.name, '?'), COALESCE(p.name, '?'),
 		       sl.status, sl.files_synced, sl.bytes_synced, sl.completed_at
 		FROM sync_log sl
 		LEFT JOIN users u ON sl.user_id = u.id
+		LEFT JOIN group_shares gs ON sl.group_share_id = gs.id
+		LEFT JOIN groups g ON gs.group_id = g.id
 		LEFT JOIN peers p ON sl.peer_id = p.id
 		ORDER BY sl.started_at DESC
 		LIMIT 20
@@ -521,10 +685,12 @@ func (s *Server) getV2RecentBackups(lang string, limit int) []V2RecentBackup {
 	// P2P sync log
 	p2pRows, err := s.db.Query(`
 		SELECT sl.completed_at, sl.status,
-		       COALESCE(u.username, '?') AS username,
+		       COALESCE(u.username, '@' || g.name || '/' || gs.name, '?') AS username,
 		       COALESCE(p.name, '?') AS peer_name
 		FROM sync_log sl
 		LEFT JOIN users u ON sl.user_id = u.id
+		LEFT JOIN group_shares gs ON sl.group_share_id = gs.id
+		LEFT JOIN groups g ON gs.group_id = g.id
 		LEFT JOIN peers p ON sl.peer_id = p.id
 		WHERE sl.completed_at IS NOT NULL
 		ORDER BY sl.started_at DESC LIMIT 10
@@ -0,0 +1,639 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+// This file contains the WebDAV endpoint (/dav/<share>/...), for the clients
+// outside the LAN: mobile apps, rclone and the file managers of the OS.
+
+package web
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/juste-un-gars/anemone/internal/auth"
+	"github.com/juste-un-gars/anemone/internal/btrfs"
+	"github.com/juste-un-gars/anemone/internal/groups"
+	"github.com/juste-un-gars/anemone/internal/logger"
+	"github.com/juste-un-gars/anemone/internal/shares"
+	"github.com/juste-un-gars/anemone/internal/users"
+	"golang.org/x/net/webdav"
+)
+
+// davPrefix is the URL prefix of the WebDAV endpoint
+const davPrefix = "/dav"
+
+// davAuthTTL is how long a successful authentication is remembered, so that
+// clients sending credentials with every request don't pay bcrypt each time
+const davAuthTTL = 5 * time.Minute
+
+// davReserved are the directories at the root of a share that WebDAV clients
+// can neither see nor modify
+var davReserved = map[string]bool{
+	".trash":               true,
+	btrfs.SnapshotsDirName: true,
+	".zfs":                 true,
+}
+
+// davTempPrefix starts the names of the temp files uploads are written to,
+// hidden from the listings
+const davTempPrefix = ".anemone-dav-"
+
+// errQuotaExceeded is returned when a write would exceed the quota of the share
+var errQuotaExceeded = errors.New("quota exceeded")
+
+// davLocks holds the WebDAV locks of all users, so that members of a group
+// editing the same document see each other's locks
+var davLocks = webdav.NewMemLS()
+
+// davAuthEntry is a remembered authentication
+type davAuthEntry struct {
+	userID   int
+	username string
+	expires  time.Time
+}
+
+// davAuthCache remembers the successful authentications, by hash of the
+// credentials
+var davAuthCache = struct {
+	sync.Mutex
+	entries map[string]davAuthEntry
+}{entries: make(map[string]davAuthEntry)}
+
+// davCredentialsKey returns the cache key of a username and password
+func davCredentialsKey(username, password string) string {
+	sum := sha256.Sum256([]byte(username + "\x00" + password))
+	return hex.EncodeToString(sum[:])
+}
+
+// forgetDAVAuth drops the remembered authentications of a user, when its
+// password changes or one of its app passwords is revoked
+func forgetDAVAuth(userID int) {
+	davAuthCache.Lock()
+	defer davAuthCache.Unlock()
+	for key, entry := range davAuthCache.entries {
+		if entry.userID == userID {
+			delete(davAuthCache.entries, key)
+		}
+	}
+}
+
+// davAuthenticate checks the Basic credentials of a request, with the account
+// password or an app password. It writes the error response and returns nil
+// when the request is not authenticated.
+func (s *Server) davAuthenticate(w http.ResponseWriter, r *http.Request) *auth.Session {
+	username, password, ok := r.BasicAuth()
+	if !ok || username == "" {
+		w.Header().Set("WWW-Authenticate", `Basic realm="Anemone", charset="UTF-8"`)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return nil
+	}
+
+	key := davCredentialsKey(username, password)
+	now := time.Now()
+	davAuthCache.Lock()
+	entry, found := davAuthCache.entries[key]
+	if found && now.After(entry.expires) {
+		delete(davAuthCache.entries, key)
+		found = false
+	}
+	davAuthCache.Unlock()
+	if found {
+		return &auth.Session{UserID: entry.userID, Username: entry.username}
+	}
+
+	ip := clientIP(r)
+	rl := auth.GetLoginRateLimiter()
+	if blocked, remaining := rl.IsBlocked(ip); blocked {
+		logger.Warn("WebDAV login blocked by IP rate limiter", "ip", ip, "remaining", remaining.Round(time.Second))
+		w.Header().Set("Retry-After", strconv.Itoa(int(remaining.Seconds())+1))
+		http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+		return nil
+	}
+	if blocked, remaining := rl.IsBlockedUser(username); blocked {
+		logger.Warn("WebDAV login blocked by account rate limiter", "username", username, "ip", ip, "remaining", remaining.Round(time.Second))
+		users.DummyCheckPassword(password)
+		w.Header().Set("Retry-After", strconv.Itoa(int(remaining.Seconds())+1))
+		http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+		return nil
+	}
+
+	// Always run bcrypt, as on the login page, so that response times don't
+	// reveal whether the user exists
+	var valid bool
+	user, err := users.GetByUsername(s.db, username)
+	if err != nil || user == nil {
+		users.DummyCheckPassword(password)
+	} else {
+		valid = user.CheckPassword(password) || users.CheckAppPassword(s.db, user.ID, password)
+	}
+
+	if !valid {
+		lockedIP := rl.RecordFailure(ip)
+		lockedUser := rl.RecordFailureUser(username)
+		logger.Warn("Failed WebDAV login attempt", "username", username, "ip", ip,
+			"locked_ip", lockedIP, "locked_user", lockedUser)
+		w.Header().Set("WWW-Authenticate", `Basic realm="Anemone", charset="UTF-8"`)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return nil
+	}
+
+	rl.RecordSuccess(ip)
+	rl.RecordSuccessUser(username)
+
+	davAuthCache.Lock()
+	for k, e := range davAuthCache.entries {
+		if now.After(e.expires) {
+			delete(davAuthCache.entries, k)
+		}
+	}
+	davAuthCache.entries[key] = davAuthEntry{userID: user.ID, username: user.Username, expires: now.Add(davAuthTTL)}
+	davAuthCache.Unlock()
+
+	return &auth.Session{UserID: user.ID, Username: user.Username}
+}
+
+// handleWebDAV serves the shares of the user over WebDAV (/dav/<share>/...).
+// Clients authenticate with HTTP Basic auth on every request.
+func (s *Server) handleWebDAV(w http.ResponseWriter, r *http.Request) {
+	session := s.davAuthenticate(w, r)
+	if session == nil {
+		return
+	}
+	// After a server restore, the user must first acknowledge it in the web
+	// interface, as for the pages behind RequireRestoreCheck
+	if auth.RestorePending(s.db, session.UserID) {
+		http.Error(w, "Service Unavailable: acknowledge the server restore in the web interface first", http.StatusServiceUnavailable)
+		return
+	}
+
+	fs := &davFS{s: s, session: session, space: make(map[string]*davSpace)}
+
+	// Refuse uploads that can't fit before reading them, so that clients get
+	// a proper 507 instead of a failure in the middle of the transfer
+	if r.Method == http.MethodPut && r.ContentLength > 0 {
+		if absPath, share, _, err := fs.resolve(strings.TrimPrefix(r.URL.Path, davPrefix), true); err == nil {
+			free, limited := fs.freeSpace(share)
+			// The file being replaced frees its space
+			if info, err := os.Stat(absPath); err == nil && info.Mode().IsRegular() {
+				free += info.Size()
+			}
+			if limited && r.ContentLength > free {
+				http.Error(w, "Insufficient Storage", http.StatusInsufficientStorage)
+				return
+			}
+		}
+	}
+
+	handler := &webdav.Handler{
+		Prefix:     davPrefix,
+		FileSystem: fs,
+		LockSystem: davLocks,
+		Logger: func(r *http.Request, err error) {
+			if err != nil && !os.IsNotExist(err) {
+				logger.Info("WebDAV request failed", "username", session.Username, "method", r.Method, "path", r.URL.Path, "error", err)
+			}
+		},
+	}
+	handler.ServeHTTP(w, r)
+}
+
+// splitDAVPath splits a WebDAV path into the share name and the path in the share
+func splitDAVPath(name string) (string, string) {
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	shareName, relPath, _ := strings.Cut(name, "/")
+	return shareName, relPath
+}
+
+// davFS is the webdav.FileSystem of the shares of a user: its shares and the
+// shares of its groups, one directory each at the root
+type davFS struct {
+	s       *Server
+	session *auth.Session
+	space   map[string]*davSpace // Space left in the shares written by the request
+	mu      sync.Mutex
+}
+
+// davSpace is the space left in a share during a request
+type davSpace struct {
+	free    int64
+	limited bool
+}
+
+// resolve resolves a WebDAV path with the confinement of the file browser.
+// The root of the endpoint and the reserved directories are refused.
+func (fs *davFS) resolve(name string, write bool) (string, *shares.Share, string, error) {
+	shareName, relPath := splitDAVPath(name)
+	if shareName == "" {
+		return "", nil, "", os.ErrPermission
+	}
+	if first, _, _ := strings.Cut(relPath, "/"); davReserved[first] {
+		return "", nil, "", os.ErrNotExist
+	}
+
+	absPath, share, err := fs.s.resolveSharePathFor(fs.session, shareName, relPath, write)
+	if err != nil {
+		if _, writable, ferr := fs.s.findShare(fs.session.UserID, shareName); ferr == nil && write && !writable {
+			return "", nil, "", os.ErrPermission
+		}
+		return "", nil, "", os.ErrNotExist
+	}
+	return absPath, share, relPath, nil
+}
+
+// Mkdir creates a directory, like the file browser
+func (fs *davFS) Mkdir(ctx context.Context, name string, perm os.FileMode) error {
+	absPath, _, relPath, err := fs.resolve(name, true)
+	if err != nil {
+		return err
+	}
+	if relPath == "" {
+		return os.ErrExist
+	}
+	if err := os.Mkdir(absPath, 0755); err != nil {
+		if !os.IsPermission(err) {
+			return err
+		}
+		if err2 := exec.Command("sudo", "/bin/mkdir", "-m", "0755", absPath).Run(); err2 != nil {
+			return err
+		}
+	}
+	logger.Info("User created folder over WebDAV", "username", fs.session.Username, "path", name)
+	return nil
+}
+
+// OpenFile opens a file or a directory. Files opened for writing count against
+// the quota of their share. A file opened with O_TRUNC is written to a temp
+// file, which replaces it on close once complete.
+func (fs *davFS) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (webdav.File, error) {
+	shareName, _ := splitDAVPath(name)
+	if shareName == "" {
+		if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC) != 0 {
+			return nil, os.ErrPermission
+		}
+		return fs.openRoot()
+	}
+
+	write := flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) != 0
+	absPath, share, relPath, err := fs.resolve(name, write)
+	if err != nil {
+		return nil, err
+	}
+
+	if !write {
+		f, err := os.Open(absPath)
+		if err != nil {
+			return nil, err
+		}
+		return &davFile{File: f, fs: fs, name: davEntryName(share, relPath), root: relPath == ""}, nil
+	}
+
+	if relPath == "" {
+		return nil, os.ErrPermission
+	}
+	info, err := os.Stat(absPath)
+	switch {
+	case err != nil && flag&os.O_CREATE == 0:
+		return nil, err
+	case err == nil && info.IsDir():
+		return nil, &os.PathError{Op: "open", Path: absPath, Err: syscall.EISDIR}
+	case err == nil && flag&os.O_TRUNC != 0:
+		// The file being replaced frees its space
+		fs.release(share, info.Size())
+	}
+	free, limited := fs.freeSpace(share)
+	if limited && free <= 0 {
+		return nil, errQuotaExceeded
+	}
+
+	file := &davFile{fs: fs, share: share, name: davEntryName(share, relPath)}
+	if flag&os.O_TRUNC == 0 {
+		f, err := os.OpenFile(absPath, flag, 0644)
+		if err != nil {
+			return nil, err
+		}
+		file.File = f
+		return file, nil
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(absPath), davTempPrefix+"*")
+	if err != nil {
+		if !os.IsPermission(err) {
+			return nil, err
+		}
+		// Permission denied: write to the system temp directory, moved in
+		// place with sudo on close, as for the uploads of the file browser
+		tmp, err2 := os.CreateTemp("", "anemone-dav-*")
+		if err2 != nil {
+			return nil, err
+		}
+		f = tmp
+	}
+	f.Chmod(0644)
+	file.File, file.dest = f, absPath
+	return file, nil
+}
+
+// RemoveAll moves a file or a directory to the trash of the user, like the
+// deletes of the file browser
+func (fs *davFS) RemoveAll(ctx context.Context, name string) error {
+	absPath, share, relPath, err := fs.resolve(name, true)
+	if err != nil {
+		return err
+	}
+	if relPath == "" {
+		return os.ErrPermission
+	}
+	if _, err := os.Lstat(absPath); err != nil {
+		return err
+	}
+	if err := moveToTrash(share, fs.session.Username, relPath, absPath); err != nil {
+		logger.Info("Error moving to trash", "abs_path", absPath, "error", err)
+		return err
+	}
+	logger.Info("User deleted over WebDAV (moved to trash)", "username", fs.session.Username, "path", name)
+	return nil
+}
+
+// Rename moves a file or a directory inside a share
+func (fs *davFS) Rename(ctx context.Context, oldName, newName string) error {
+	srcPath, srcShare, srcRel, err := fs.resolve(oldName, true)
+	if err != nil {
+		return err
+	}
+	dstPath, dstShare, dstRel, err := fs.resolve(newName, true)
+	if err != nil {
+		return err
+	}
+	// Shares may live on different filesystems, with different quotas
+	if srcRel == "" || dstRel == "" || srcShare.Path != dstShare.Path {
+		return os.ErrPermission
+	}
+
+	if err := os.Rename(srcPath, dstPath); err != nil {
+		if err2 := exec.Command("sudo", "/usr/bin/mv", srcPath, dstPath).Run(); err2 != nil {
+			return err
+		}
+	}
+	logger.Info("User renamed over WebDAV", "username", fs.session.Username, "path", oldName, "new_path", newName)
+	return nil
+}
+
+// Stat returns the information of a file or a directory
+func (fs *davFS) Stat(ctx context.Context, name string) (os.FileInfo, error) {
+	shareName, _ := splitDAVPath(name)
+	if shareName == "" {
+		return davDirInfo{name: "/", modTime: time.Now()}, nil
+	}
+	absPath, share, relPath, err := fs.resolve(name, false)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(absPath)
+	if err != nil {
+		return nil, err
+	}
+	if relPath == "" {
+		return davNamedInfo{FileInfo: info, name: share.Name}, nil
+	}
+	return info, nil
+}
+
+// openRoot returns the root of the endpoint, listing the shares of the user
+func (fs *davFS) openRoot() (webdav.File, error) {
+	userShares, err := fs.s.browsableShares(fs.session.UserID)
+	if err != nil {
+		return nil, err
+	}
+	var entries []os.FileInfo
+	for _, share := range userShares {
+		info, err := os.Stat(share.Path)
+		if err != nil {
+			continue
+		}
+		entries = append(entries, davNamedInfo{FileInfo: info, name: share.Name})
+	}
+	return &davRoot{entries: entries}, nil
+}
+
+// freeSpace returns the space left in a share, and whether it is limited.
+// The usage is measured once per request, then decreased as files are written.
+func (fs *davFS) freeSpace(share *shares.Share) (int64, bool) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	space, ok := fs.space[share.Path]
+	if !ok {
+		limit, used := fs.s.shareQuota(share)
+		space = &davSpace{free: limit - used, limited: limit > 0}
+		fs.space[share.Path] = space
+	}
+	return space.free, space.limited
+}
+
+// reserve takes n bytes from the space left in a share, false when the quota
+// would be exceeded
+func (fs *davFS) reserve(share *shares.Share, n int64) bool {
+	if _, limited := fs.freeSpace(share); !limited {
+		return true
+	}
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	space := fs.space[share.Path]
+	if space.free < n {
+		return false
+	}
+	space.free -= n
+	return true
+}
+
+// release gives n bytes back to the space left in a share
+func (fs *davFS) release(share *shares.Share, n int64) {
+	if _, limited := fs.freeSpace(share); !limited {
+		return
+	}
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.space[share.Path].free += n
+}
+
+// shareQuota returns the quota and the usage of a share in bytes, with the
+// rules of the Samba dfree script: group shares share the quota of their
+// group, backup shares use the backup quota of the user and the other shares
+// its total quota. A quota of 0 is unlimited.
+func (s *Server) shareQuota(share *shares.Share) (limit, used int64) {
+	const gb = 1024 * 1024 * 1024
+
+	groupDir := filepath.Dir(share.Path)
+	groupName := filepath.Base(groupDir)
+	if groups.Dir(s.cfg.SharesDir, groupName) == groupDir {
+		var quotaGB int64
+		if err := s.db.QueryRow(`SELECT quota_gb FROM groups WHERE name = ?`, groupName).Scan(&quotaGB); err != nil || quotaGB == 0 {
+			return 0, 0
+		}
+		return quotaGB * gb, calculateDirectorySize(groupDir)
+	}
+
+	user, err := users.GetByID(s.db, share.UserID)
+	if err != nil {
+		return 0, 0
+	}
+	quotaGB := int64(user.QuotaTotalGB)
+	if share.Name == "backup" || strings.HasPrefix(share.Name, "backup_") {
+		quotaGB = int64(user.QuotaBackupGB)
+	}
+	if quotaGB == 0 {
+		return 0, 0
+	}
+	return quotaGB * gb, calculateDirectorySize(share.Path)
+}
+
+// davEntryName returns the name of a file for WebDAV, the share name at the
+// root of a share
+func davEntryName(share *shares.Share, relPath string) string {
+	if relPath == "" {
+		return share.Name
+	}
+	return path.Base(relPath)
+}
+
+// davFile is a file or a directory of a share
+type davFile struct {
+	*os.File
+	fs     *davFS
+	share  *shares.Share // Set when the file is opened for writing
+	name   string
+	root   bool   // Root of the share, where the reserved directories are
+	dest   string // Destination of a file written to a temp file
+	failed bool   // A write failed or was refused by the quota
+}
+
+// Write writes to the file within the quota of the share
+func (f *davFile) Write(p []byte) (int, error) {
+	if f.share != nil && !f.fs.reserve(f.share, int64(len(p))) {
+		f.failed = true
+		return 0, errQuotaExceeded
+	}
+	n, err := f.File.Write(p)
+	if err != nil {
+		f.failed = true
+	}
+	return n, err
+}
+
+// ReadFrom copies an upload to the file through Write, which the ReadFrom of
+// os.File would bypass. A copy stopped by an error, such as a dropped
+// connection, marks the file failed.
+func (f *davFile) ReadFrom(r io.Reader) (int64, error) {
+	n, err := io.Copy(struct{ io.Writer }{f}, r)
+	if err != nil {
+		f.failed = true
+	}
+	return n, err
+}
+
+// Close closes the file. A file written to a temp file replaces its
+// destination only when complete: otherwise the temp file is removed and the
+// previous version is left in place.
+func (f *davFile) Close() error {
+	err := f.File.Close()
+	if f.dest == "" {
+		return err
+	}
+	if err == nil && !f.failed {
+		if err = os.Rename(f.File.Name(), f.dest); err != nil {
+			err = exec.Command("sudo", "/usr/bin/mv", f.File.Name(), f.dest).Run()
+		}
+		if err == nil {
+			return nil
+		}
+	}
+	os.Remove(f.File.Name())
+	return err
+}
+
+// Readdir lists a directory, without the uploads in progress and the reserved
+// directories at the root of the share
+func (f *davFile) Readdir(count int) ([]os.FileInfo, error) {
+	entries, err := f.File.Readdir(count)
+	filtered := entries[:0]
+	for _, e := range entries {
+		if !(f.root && davReserved[e.Name()]) && !strings.HasPrefix(e.Name(), davTempPrefix) {
+			filtered = append(filtered, e)
+		}
+	}
+	return filtered, err
+}
+
+// Stat returns the information of the file, under its WebDAV name
+func (f *davFile) Stat() (os.FileInfo, error) {
+	info, err := f.File.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return davNamedInfo{FileInfo: info, name: f.name}, nil
+}
+
+// davRoot is the root of the endpoint, a read-only directory of the shares
+type davRoot struct {
+	entries []os.FileInfo
+	pos     int
+}
+
+func (d *davRoot) Close() error                                 { return nil }
+func (d *davRoot) Read(p []byte) (int, error)                   { return 0, fmt.Errorf("is a directory") }
+func (d *davRoot) Seek(offset int64, whence int) (int64, error) { return 0, nil }
+func (d *davRoot) Write(p []byte) (int, error)                  { return 0, os.ErrPermission }
+func (d *davRoot) Stat() (os.FileInfo, error)                   { return davDirInfo{name: "/", modTime: time.Now()}, nil }
+
+// Readdir lists the shares, count at a time like os.File.Readdir
+func (d *davRoot) Readdir(count int) ([]os.FileInfo, error) {
+	rest := d.entries[d.pos:]
+	if count <= 0 {
+		d.pos = len(d.entries)
+		return rest, nil
+	}
+	if len(rest) == 0 {
+		return nil, io.EOF
+	}
+	if count > len(rest) {
+		count = len(rest)
+	}
+	d.pos += count
+	return rest[:count], nil
+}
+
+// davNamedInfo renames a file information, for the shares whose directory
+// name differs from the share name
+type davNamedInfo struct {
+	os.FileInfo
+	name string
+}
+
+func (i davNamedInfo) Name() string { return i.name }
+
+// davDirInfo is the information of the root of the endpoint
+type davDirInfo struct {
+	name    string
+	modTime time.Time
+}
+
+func (i davDirInfo) Name() string       { return i.name }
+func (i davDirInfo) Size() int64        { return 0 }
+func (i davDirInfo) Mode() os.FileMode  { return os.ModeDir | 0555 }
+func (i davDirInfo) ModTime() time.Time { return i.modTime }
+func (i davDirInfo) IsDir() bool        { return true }
+func (i davDirInfo) Sys() interface{}   { return nil }
@@ -23,7 +23,6 @@ import (
 	"github.com/juste-un-gars/anemone/internal/incoming"
 	"github.com/juste-un-gars/anemone/internal/quota"
 	"github.com/juste-un-gars/anemone/internal/setup"
-	"github.com/juste-un-gars/anemone/internal/shares"
 	"github.com/juste-un-gars/anemone/internal/sync"
 	"github.com/juste-un-gars/anemone/internal/syncauth"
 	"github.com/juste-un-gars/anemone/internal/trash"
@@ -144,6 +143,7 @@ func NewRouter(db *sql.DB, cfg *config.Config) http.Handler {
 		}
 		return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
 	}
+	funcMap["Sparkline"] = sparklinePoints
 	funcMap["FormatTime"] = func(t time.Time, lang string) string {
 		now := time.Now()
 		diff := now.Sub(t)
@@ -324,12 +324,17 @@ func NewRouter(db *sql.DB, cfg *config.Config) http.Handler {
 	mux.HandleFunc("/admin/backup/create", auth.RequireAdmin(server.handleAdminBackupCreate))
 	mux.HandleFunc("/admin/backup/download", auth.RequireAdmin(server.handleAdminBackupDownload))
 	mux.HandleFunc("/admin/backup/delete", auth.RequireAdmin(server.handleAdminBackupDelete))
+	mux.HandleFunc("/admin/backup/recovery", auth.RequireAdmin(server.handleAdminBackupRecovery))
 
 	// Admin routes - Storage management
 	mux.HandleFunc("/admin/storage", auth.RequireAdmin(server.handleAdminStorage))
 	mux.HandleFunc("/admin/storage/api", auth.RequireAdmin(server.handleAdminStorageAPI))
 	mux.HandleFunc("/api/admin/storage/pool/", auth.RequireAdmin(server.handleAdminStoragePoolScrub))
 	mux.HandleFunc("/api/admin/storage/disk/", auth.RequireAdmin(server.handleAdminStorageDiskSMART))
+	mux.HandleFunc("/api/admin/storage/smart/history", auth.RequireAdmin(server.handleAdminStorageSMARTHistory))
+	mux.HandleFunc("/api/admin/storage/smart/selftest", auth.RequireAdmin(server.handleAdminStorageSMARTSelfTest))
+	mux.HandleFunc("/api/admin/storage/smart/settings", auth.RequireAdmin(server.handleAdminStorageSMARTSettings))
+	mux.HandleFunc("/api/admin/alerts", auth.RequireAdmin(server.handleAdminAlerts))
 
 	// Admin routes - Password verification for destructive operations
 	mux.HandleFunc("/api/admin/verify-password", auth.RequireAdmin(server.handleAdminVerifyPassword))
@@ -340,8 +345,21 @@ func NewRouter(db *sql.DB, cfg *config.Config) http.Handler {
 	mux.HandleFunc("/api/admin/storage/pool-export/", auth.RequireAdmin(server.handleAdminStoragePoolExport))
 	mux.HandleFunc("/api/admin/storage/pool-vdev/", auth.RequireAdmin(server.handleAdminStoragePoolAddVDev))
 	mux.HandleFunc("/api/admin/storage/pool-replace/", auth.RequireAdmin(server.handleAdminStoragePoolReplace))
+	mux.HandleFunc("/api/admin/storage/pool-offline/", auth.RequireAdmin(server.handleAdminStoragePoolOffline))
 	mux.HandleFunc("/api/admin/storage/pools/importable", auth.RequireAdmin(server.handleAdminStoragePoolsImportable))
 	mux.HandleFunc("/api/admin/storage/pools/import", auth.RequireAdmin(server.handleAdminStoragePoolImport))
+	mux.HandleFunc("/api/admin/storage/scrub-schedules", auth.RequireAdmin(server.handleAdminStorageScrubSchedules))
+	mux.HandleFunc("/api/admin/storage/scrub-history", auth.RequireAdmin(server.handleAdminStorageScrubHistory))
+	mux.HandleFunc("/api/admin/storage/md-arrays", auth.RequireAdmin(server.handleAdminStorageMDArrays))
+	mux.HandleFunc("/api/admin/storage/md-array-disk/", auth.RequireAdmin(server.handleAdminStorageMDDisk))
+	mux.HandleFunc("/api/admin/storage/md-array-check/", auth.RequireAdmin(server.handleAdminStorageMDCheck))
+	mux.HandleFunc("/api/admin/storage/encrypted-volumes", auth.RequireAdmin(server.handleAdminStorageEncryptedVolumes))
+	mux.HandleFunc("/api/admin/storage/encrypted-volumes/unlock", auth.RequireAdmin(server.handleAdminStorageVolumeUnlock))
+	mux.HandleFunc("/api/admin/storage/encrypted-volumes/lock", auth.RequireAdmin(server.handleAdminStorageVolumeLock))
+	mux.HandleFunc("/api/admin/storage/encrypted-volumes/key", auth.RequireAdmin(server.handleAdminStorageVolumeKey))
+	mux.HandleFunc("/api/admin/storage/encrypted-volumes/export", auth.RequireAdmin(server.handleAdminStorageVolumeExport))
+	mux.HandleFunc("/api/admin/capacity/forecasts", auth.RequireAdmin(server.handleAdminCapacityForecasts))
+	mux.HandleFunc("/api/admin/capacity/history", auth.RequireAdmin(server.handleAdminCapacityHistory))
 
 	// Admin routes - ZFS Dataset management
 	mux.HandleFunc("/api/admin/storage/dataset", auth.RequireAdmin(server.handleAdminStorageDatasetCreate))
@@ -354,6 +372,12 @@ func NewRouter(db *sql.DB, cfg *config.Config) http.Handler {
 	mux.HandleFunc("/api/admin/storage/snapshot-delete", auth.RequireAdmin(server.handleAdminStorageSnapshotDelete))
 	mux.HandleFunc("/api/admin/storage/snapshot-rollback", auth.RequireAdmin(server.handleAdminStorageSnapshotRollback))
 	mux.HandleFunc("/api/admin/storage/snapshots", auth.RequireAdmin(server.handleAdminStorageSnapshotList))
+	mux.HandleFunc("/api/admin/storage/snapshot-policies", auth.RequireAdmin(server.handleAdminStorageSnapshotPolicies))
+	mux.HandleFunc("/api/admin/storage/snapshot-policies/run", auth.RequireAdmin(server.handleAdminStorageSnapshotPolicyRun))
+	mux.HandleFunc("/api/admin/storage/subvolumes", auth.RequireAdmin(server.handleAdminStorageSubvolumes))
+	mux.HandleFunc("/api/admin/storage/replications", auth.RequireAdmin(server.handleAdminStorageReplications))
+	mux.HandleFunc("/api/admin/storage/replications/run", auth.RequireAdmin(server.handleAdminStorageReplicationRun))
+	mux.HandleFunc("/api/admin/storage/replicas", auth.RequireAdmin(server.handleAdminStorageReplicas))
 
 	// Admin routes - Disk management
 	mux.HandleFunc("/api/admin/storage/disks/available", auth.RequireAdmin(server.handleAdminStorageDisksAvailable))
@@ -366,10 +390,13 @@ func NewRouter(db *sql.DB, cfg *config.Config) http.Handler {
 	mux.HandleFunc("/admin/usb-backup", auth.RequireAdmin(server.handleAdminUSBBackup))
 	mux.HandleFunc("/admin/usb-backup/add", auth.RequireAdmin(server.handleAdminUSBBackupAdd))
 	mux.HandleFunc("/admin/usb-backup/format", auth.RequireAdmin(server.handleAdminUSBFormat))
+	mux.HandleFunc("/admin/usb-backup/restore", auth.RequireAdmin(server.handleAdminUSBRestore))
+	mux.HandleFunc("/admin/usb-backup/recovery", auth.RequireAdmin(server.handleAdminUSBRecovery))
 	mux.HandleFunc("/admin/usb-backup/", auth.RequireAdmin(server.handleAdminUSBBackupActions))
 	mux.HandleFunc("/api/admin/usb-backup/status", auth.RequireAdmin(server.handleAdminUSBBackupAPI))
 	mux.HandleFunc("/api/admin/usb-backup/drives", auth.RequireAdmin(server.handleAdminUSBDrives))
 	mux.HandleFunc("/api/admin/usb-backup/unmounted", auth.RequireAdmin(server.handleAdminUSBUnmountedDisks))
+	mux.HandleFunc("/api/admin/usb-restore/", auth.RequireAdmin(server.handleAdminUSBRestoreAPI))
 
 	// Admin routes - Rclone Cloud Backup
 	mux.HandleFunc("/admin/rclone", auth.RequireAdmin(server.handleAdminRclone))
@@ -410,6 +437,14 @@ func NewRouter(db *sql.DB, cfg *config.Config) http.Handler {
 	mux.HandleFunc("/api/files/mkdir", auth.RequireAuth(auth.RequireRestoreCheck(server.db, server.handleFilesMkdir)))
 	mux.HandleFunc("/api/files/rename", auth.RequireAuth(auth.RequireRestoreCheck(server.db, server.handleFilesRename)))
 	mux.HandleFunc("/api/files/delete", auth.RequireAuth(auth.RequireRestoreCheck(server.db, server.handleFilesDelete)))
+	mux.HandleFunc("/api/files/versions", auth.RequireAuth(auth.RequireRestoreCheck(server.db, server.handleFilesVersions)))
+	mux.HandleFunc("/api/files/versions/browse", auth.RequireAuth(auth.RequireRestoreCheck(server.db, server.handleFilesVersionsBrowse)))
+	mux.HandleFunc("/api/files/versions/download", auth.RequireAuth(auth.RequireRestoreCheck(server.db, server.handleFilesVersionsDownload)))
+	mux.HandleFunc("/api/files/versions/restore", auth.RequireAuth(auth.RequireRestoreCheck(server.db, server.handleFilesVersionsRestore)))
+
+	// Storage usage history and forecasts of the current user
+	mux.HandleFunc("/api/capacity", auth.RequireAuth(server.handleCapacity))
+	mux.HandleFunc("/api/capacity/history", auth.RequireAuth(server.handleCapacityHistory))
 
 	// User routes (with restore check)
 	mux.HandleFunc("/trash", auth.RequireAuth(auth.RequireRestoreCheck(server.db, server.handleTrash)))
@@ -417,6 +452,14 @@ func NewRouter(db *sql.DB, cfg *config.Config) http.Handler {
 	mux.HandleFunc("/settings", auth.RequireAuth(auth.RequireRestoreCheck(server.db, server.handleSettings)))
 	mux.HandleFunc("/settings/language", auth.RequireAuth(auth.RequireRestoreCheck(server.db, server.handleSettingsLanguage)))
 	mux.HandleFunc("/settings/password", auth.RequireAuth(auth.RequireRestoreCheck(server.db, server.handleSettingsPassword)))
+	mux.HandleFunc("/settings/share-peers", auth.RequireAuth(auth.RequireRestoreCheck(server.db, server.handleSettingsSharePeers)))
+	mux.HandleFunc("/settings/app-passwords", auth.RequireAuth(auth.RequireRestoreCheck(server.db, server.handleSettingsAppPasswords)))
+	mux.HandleFunc("/settings/app-passwords/", auth.RequireAuth(auth.RequireRestoreCheck(server.db, server.handleSettingsAppPasswordsDelete)))
+
+	// WebDAV (HTTP Basic auth with the account password or an app password,
+	// restore check in the handler)
+	mux.HandleFunc(davPrefix, server.handleWebDAV)
+	mux.HandleFunc(davPrefix+"/", server.handleWebDAV)
 
 	// Restore routes (user can restore their own backups) (with restore check)
 	mux.HandleFunc("/restore", auth.RequireAuth(auth.RequireRestoreCheck(server.db, server.handleRestore)))
@@ -424,9 +467,17 @@ func NewRouter(db *sql.DB, cfg *config.Config) http.Handler {
 	mux.HandleFunc("/api/restore/files", auth.RequireAuth(auth.RequireRestoreCheck(server.db, server.handleAPIRestoreFiles)))
 	mux.HandleFunc("/api/restore/download", auth.RequireAuth(auth.RequireRestoreCheck(server.db, server.handleAPIRestoreDownload)))
 	mux.HandleFunc("/api/restore/download-multiple", auth.RequireAuth(auth.RequireRestoreCheck(server.db, server.handleAPIRestoreDownloadMultiple)))
+	mux.HandleFunc("/api/restore/jobs", auth.RequireAuth(auth.RequireRestoreCheck(server.db, server.handleAPIRestoreJobs)))
 
 	// Admin routes - Shares
 	mux.HandleFunc("/admin/shares", auth.RequireAdmin(server.handleAdminShares))
+	mux.HandleFunc("/admin/shares/", auth.RequireAdmin(server.handleAdminSharesActions))
+
+	// Admin routes - Groups
+	mux.HandleFunc("/admin/groups", auth.RequireAdmin(server.handleAdminGroups))
+	mux.HandleFunc("/api/admin/groups", auth.RequireAdmin(server.handleAdminGroupsCreate))
+	mux.HandleFunc("/api/admin/groups/", auth.RequireAdmin(server.handleAdminGroupsActions))
+	mux.HandleFunc("/api/admin/group-shares/", auth.RequireAdmin(server.handleAdminGroupSharesActions))
 
 	// Sync routes
 	mux.HandleFunc("/sync/share/", auth.RequireAuth(server.handleSyncShare))
@@ -439,18 +490,31 @@ func NewRouter(db *sql.DB, cfg *config.Config) http.Handler {
 	mux.HandleFunc("/api/sync/source-info", server.syncAuthMiddleware(server.handleAPISyncSourceInfo)) // PUT
 	mux.HandleFunc("/api/sync/file", server.syncAuthMiddleware(server.handleAPISyncFile))               // POST/DELETE
 	mux.HandleFunc("/api/sync/list-physical-files", server.syncAuthMiddleware(server.handleAPISyncListPhysicalFiles)) // GET
+	mux.HandleFunc("/api/sync/verify", server.syncAuthMiddleware(server.handleAPISyncVerify))                      // POST
 
 	// API routes - Remote restore (protected by password authentication)
 	mux.HandleFunc("/api/sync/list-user-backups", server.syncAuthMiddleware(server.handleAPISyncListUserBackups))
 	mux.HandleFunc("/api/sync/download-encrypted-manifest", server.syncAuthMiddleware(server.handleAPISyncDownloadEncryptedManifest))
 	mux.HandleFunc("/api/sync/download-encrypted-file", server.syncAuthMiddleware(server.handleAPISyncDownloadEncryptedFile))
 
+	// API routes - Configuration backups of peers (protected by password authentication)
+	mux.HandleFunc("/api/sync/server-backup", server.syncAuthMiddleware(server.handleAPISyncServerBackup)) // GET/POST
+
+	// API routes - ZFS replicas of peers (protected by password authentication)
+	mux.HandleFunc("/api/sync/zfs/state", server.syncAuthMiddleware(server.handleAPISyncZFSState))     // GET/DELETE
+	mux.HandleFunc("/api/sync/zfs/receive", server.syncAuthMiddleware(server.handleAPISyncZFSReceive)) // POST
+
 	// API routes - User management (protected by password authentication)
 	mux.HandleFunc("/api/sync/delete-user-backup", server.syncAuthMiddleware(server.handleAPISyncDeleteUserBackup))
 
 	// Admin routes - Consolidated backups page
 	mux.HandleFunc("/admin/backups", auth.RequireAdmin(server.handleAdminBackups))
 
+	// Admin routes - Integrity checks of USB drives and peers
+	mux.HandleFunc("/admin/integrity/settings", auth.RequireAdmin(server.handleAdminIntegritySettings))
+	mux.HandleFunc("/admin/integrity/run", auth.RequireAdmin(server.handleAdminIntegrityRun))
+	mux.HandleFunc("/admin/integrity/", auth.RequireAdmin(server.handleAdminIntegrityActions))
+
 	// Apply middlewares: CSRF then security headers
 	return securityHeadersMiddleware(csrfMiddleware(mux))
 }
@@ -744,8 +808,8 @@ func (s *Server) getDashboardStats(session *auth.Session, lang string) *Dashboar
 			stats.StoragePercent = int(quotaInfo.PercentUsed)
 		}
 
-		// Get user's shares for trash count
-		userShares, err := shares.GetByUser(s.db, session.UserID)
+		// Get user's shares, with the shares of its groups, for trash count
+		userShares, err := s.browsableShares(session.UserID)
 		if err != nil {
 			logger.Info("Error getting shares for stats", "error", err)
 			return stats
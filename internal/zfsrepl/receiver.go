@@ -0,0 +1,210 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+// This file is the receiving side: it maps the replicas of each source server to
+// datasets under the receive base, applies the streams and prunes old snapshots.
+
+package zfsrepl
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/juste-un-gars/anemone/internal/logger"
+	"github.com/juste-un-gars/anemone/internal/storage"
+)
+
+// receiveBaseKey is the system_config key of the dataset holding received replicas
+const receiveBaseKey = "zfs_receive_base"
+
+// GetReceiveBase returns the dataset holding the replicas received from peers,
+// or "" if this server does not accept replicas
+func GetReceiveBase(db *sql.DB) (string, error) {
+	var base string
+	err := db.QueryRow("SELECT value FROM system_config WHERE key = ?", receiveBaseKey).Scan(&base)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get receive base: %w", err)
+	}
+	return base, nil
+}
+
+// SetReceiveBase sets the dataset holding received replicas. An empty base stops
+// accepting replicas; the replicas already received are kept.
+func SetReceiveBase(db *sql.DB, base string) error {
+	if base != "" {
+		if err := storage.ValidateDatasetNameOrPool(base); err != nil {
+			return fmt.Errorf("invalid dataset: %w", err)
+		}
+		if !storage.DatasetExists(base) {
+			return fmt.Errorf("dataset %s does not exist", base)
+		}
+	}
+	_, err := db.Exec(`INSERT INTO system_config (key, value, updated_at)
+		VALUES (?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(key) DO UPDATE SET value = excluded.value, updated_at = excluded.updated_at`, receiveBaseKey, base)
+	if err != nil {
+		return fmt.Errorf("failed to save receive base: %w", err)
+	}
+	return nil
+}
+
+// receiveDataset returns the dataset receiving the replica target of a source server
+func receiveDataset(base, sourceServer, target string) (string, error) {
+	if base == "" {
+		return "", fmt.Errorf("this server does not accept ZFS replicas")
+	}
+	source := sanitizeComponent(sourceServer)
+	if source == "" {
+		return "", fmt.Errorf("invalid source server")
+	}
+	if !targetPattern.MatchString(target) {
+		return "", fmt.Errorf("invalid target name")
+	}
+	return base + "/" + source + "/" + target, nil
+}
+
+// ReceivedDataset returns the dataset receiving a replica, checking that this
+// server accepts replicas
+func ReceivedDataset(db *sql.DB, sourceServer, target string) (string, error) {
+	base, err := GetReceiveBase(db)
+	if err != nil {
+		return "", err
+	}
+	return receiveDataset(base, sourceServer, target)
+}
+
+// GetReceiveState returns what this server holds of a replica
+func GetReceiveState(dataset string) (*ReceiveState, error) {
+	state := &ReceiveState{Snapshots: []string{}}
+	if !storage.DatasetExists(dataset) {
+		return state, nil
+	}
+	state.Exists = true
+
+	token, err := storage.GetResumeToken(dataset)
+	if err != nil {
+		return nil, err
+	}
+	state.ResumeToken = token
+
+	snaps, err := storage.ListSnapshots(dataset)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, s := range snaps {
+		if s.Dataset == dataset {
+			names = append(names, s.SnapName)
+		}
+	}
+	if names := replicationSnapshots(names); names != nil {
+		state.Snapshots = names
+	}
+	return state, nil
+}
+
+// ReceiveStream applies a stream sent by a peer and prunes the replicated
+// snapshots beyond keep
+func ReceiveStream(dataset, mode string, keep int, r io.Reader) error {
+	force := false
+	switch mode {
+	case ModeFull:
+		if storage.DatasetExists(dataset) {
+			return fmt.Errorf("%s already exists", dataset)
+		}
+		parent := dataset[:strings.LastIndex(dataset, "/")]
+		if !storage.DatasetExists(parent) {
+			if err := storage.CreateDatasetParents(parent); err != nil {
+				return err
+			}
+		}
+	case ModeIncremental:
+		// Changes made to the replica since the last receive are discarded
+		force = true
+	case ModeResume:
+	default:
+		return fmt.Errorf("invalid mode")
+	}
+
+	if err := storage.Receive(dataset, r, force); err != nil {
+		return err
+	}
+	return pruneReceived(dataset, keep)
+}
+
+// pruneReceived deletes the replicated snapshots of a replica beyond keep
+func pruneReceived(dataset string, keep int) error {
+	snaps, err := storage.ListSnapshots(dataset)
+	if err != nil {
+		return err
+	}
+	var names []string
+	for _, s := range snaps {
+		if s.Dataset == dataset {
+			names = append(names, s.SnapName)
+		}
+	}
+	for _, name := range expiredSnapshots(names, keep) {
+		if err := storage.DeleteSnapshot(dataset+"@"+name, false, false); err != nil {
+			return err
+		}
+		logger.Info("ZFS replication: Pruned received snapshot", "snapshot", dataset+"@"+name)
+	}
+	return nil
+}
+
+// AbortReceive discards the interrupted receive of a replica, if any
+func AbortReceive(dataset string) error {
+	token, err := storage.GetResumeToken(dataset)
+	if err != nil || token == "" {
+		return err
+	}
+	return storage.AbortReceive(dataset)
+}
+
+// Replica is a replica received from a peer
+type Replica struct {
+	Dataset      string `json:"dataset"`
+	Source       string `json:"source"`
+	Target       string `json:"target"`
+	Used         uint64 `json:"used"`
+	UsedHuman    string `json:"used_human"`
+	Snapshots    int    `json:"snapshots"`
+	LastSnapshot string `json:"last_snapshot"`
+	Resumable    bool   `json:"resumable"` // An interrupted receive can be resumed
+}
+
+// ListReplicas returns the replicas received from peers
+func ListReplicas(db *sql.DB) ([]Replica, error) {
+	replicas := []Replica{}
+	base, err := GetReceiveBase(db)
+	if err != nil || base == "" || !storage.DatasetExists(base) {
+		return replicas, err
+	}
+	datasets, err := storage.ListDatasets(base)
+	if err != nil {
+		return nil, err
+	}
+	for _, ds := range datasets {
+		parts := strings.Split(strings.TrimPrefix(ds.Name, base+"/"), "/")
+		if !strings.HasPrefix(ds.Name, base+"/") || len(parts) != 2 {
+			continue
+		}
+		replica := Replica{Dataset: ds.Name, Source: parts[0], Target: parts[1], Used: ds.Used, UsedHuman: ds.UsedHuman}
+		if state, err := GetReceiveState(ds.Name); err == nil {
+			replica.Snapshots = len(state.Snapshots)
+			if len(state.Snapshots) > 0 {
+				replica.LastSnapshot = state.Snapshots[len(state.Snapshots)-1]
+			}
+			replica.Resumable = state.ResumeToken != ""
+		}
+		replicas = append(replicas, replica)
+	}
+	return replicas, nil
+}
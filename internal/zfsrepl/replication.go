@@ -0,0 +1,306 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+// Package zfsrepl replicates ZFS datasets to peers with zfs send/receive.
+//
+// Each run takes a snapshot named anemone-repl-<UTC time> and sends it raw
+// (zfs send -w), incrementally from the newest snapshot both sides have, so an
+// encrypted dataset stays encrypted on the peer. The peer receives it under
+// <receive base>/<source server>/<target name>, keeps an interrupted receive
+// resumable, and prunes its replicated snapshots beyond the retention of the job.
+package zfsrepl
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/juste-un-gars/anemone/internal/storage"
+)
+
+// SnapshotPrefix starts the name of the snapshots taken for replication
+const SnapshotPrefix = "anemone-repl-"
+
+// HoldTag is the hold placed on the last replicated snapshot of a dataset, which
+// the next incremental send starts from
+const HoldTag = "anemone-repl"
+
+// stampLayout is the UTC time at the end of a replication snapshot name
+const stampLayout = "20060102-150405"
+
+// Bounds of the settings of a job
+const (
+	MinIntervalMinutes = 15
+	MaxIntervalMinutes = 7 * 24 * 60
+	maxKeep            = 1000
+)
+
+// Status of the last run of a job
+const (
+	StatusSuccess = "success"
+	StatusError   = "error"
+)
+
+var (
+	targetPattern   = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]{0,63}$`)
+	snapshotPattern = regexp.MustCompile(`^anemone-repl-\d{8}-\d{6}$`)
+)
+
+// Replication is a job sending a dataset to a peer
+type Replication struct {
+	ID              int        `json:"id"`
+	PeerID          int        `json:"peer_id"`
+	PeerName        string     `json:"peer_name"`
+	Dataset         string     `json:"dataset"`
+	TargetName      string     `json:"target_name"` // Name of the replica on the peer
+	IntervalMinutes int        `json:"interval_minutes"`
+	KeepSnapshots   int        `json:"keep_snapshots"` // Replicated snapshots kept by the peer
+	Enabled         bool       `json:"enabled"`
+	LastRun         *time.Time `json:"last_run"`
+	LastStatus      string     `json:"last_status"`
+	LastError       string     `json:"last_error"`
+	LastSnapshot    string     `json:"last_snapshot"`
+	BytesSent       int64      `json:"bytes_sent"`
+	CreatedAt       time.Time  `json:"created_at"`
+	Progress        *Progress  `json:"progress,omitempty"` // Set while the job runs
+}
+
+// Validate checks the settings of a job and fills in the target name
+func (r *Replication) Validate() error {
+	if r.PeerID <= 0 {
+		return fmt.Errorf("select a peer")
+	}
+	if err := storage.ValidateDatasetName(r.Dataset); err != nil {
+		return fmt.Errorf("invalid dataset: %w", err)
+	}
+	if r.TargetName == "" {
+		r.TargetName = sanitizeComponent(r.Dataset[strings.LastIndex(r.Dataset, "/")+1:])
+	}
+	if !targetPattern.MatchString(r.TargetName) {
+		return fmt.Errorf("invalid target name: use up to 64 letters, digits, dots, dashes or underscores")
+	}
+	if r.IntervalMinutes < MinIntervalMinutes || r.IntervalMinutes > MaxIntervalMinutes {
+		return fmt.Errorf("the interval must be between %d and %d minutes", MinIntervalMinutes, MaxIntervalMinutes)
+	}
+	if r.KeepSnapshots < 1 || r.KeepSnapshots > maxKeep {
+		return fmt.Errorf("the number of kept snapshots must be between 1 and %d", maxKeep)
+	}
+	return nil
+}
+
+// checkEncrypted refuses a dataset that is not encrypted: the peer would hold a
+// readable copy of its files
+func checkEncrypted(dataset string) error {
+	encryption, err := storage.GetDatasetProperty(dataset, "encryption")
+	if err != nil {
+		return fmt.Errorf("failed to check the encryption of %s: %w", dataset, err)
+	}
+	if encryption == "off" {
+		return fmt.Errorf("%s is not encrypted: only encrypted datasets can be replicated to a peer", dataset)
+	}
+	return nil
+}
+
+// IsReplicationSnapshot reports whether a snapshot name was taken for replication
+func IsReplicationSnapshot(snapName string) bool {
+	return snapshotPattern.MatchString(snapName)
+}
+
+// snapshotName returns the name of the replication snapshot taken at t
+func snapshotName(t time.Time) string {
+	return SnapshotPrefix + t.UTC().Format(stampLayout)
+}
+
+// replicationSnapshots returns the replication snapshot names among names, oldest
+// first. The names embed their UTC time, so their order is their age.
+func replicationSnapshots(names []string) []string {
+	var result []string
+	for _, name := range names {
+		if IsReplicationSnapshot(name) {
+			result = append(result, name)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// commonSnapshot returns the newest replication snapshot present on both sides,
+// or "" if there is none
+func commonSnapshot(local, remote []string) string {
+	onRemote := make(map[string]bool, len(remote))
+	for _, name := range remote {
+		onRemote[name] = true
+	}
+	common := ""
+	for _, name := range replicationSnapshots(local) {
+		if onRemote[name] {
+			common = name
+		}
+	}
+	return common
+}
+
+// expiredSnapshots returns the replication snapshots beyond the newest keep ones
+func expiredSnapshots(names []string, keep int) []string {
+	snaps := replicationSnapshots(names)
+	if keep < 1 {
+		keep = 1
+	}
+	if len(snaps) <= keep {
+		return nil
+	}
+	return snaps[:len(snaps)-keep]
+}
+
+// sanitizeComponent turns a name into a valid dataset name component
+func sanitizeComponent(name string) string {
+	var b strings.Builder
+	for _, c := range name {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '_', c == '-', c == '.':
+			b.WriteRune(c)
+		default:
+			b.WriteRune('_')
+		}
+	}
+	s := strings.TrimLeft(b.String(), ".-")
+	if len(s) > 64 {
+		s = s[:64]
+	}
+	return s
+}
+
+// CreateReplication adds a replication job
+func CreateReplication(db *sql.DB, r *Replication) error {
+	if err := r.Validate(); err != nil {
+		return err
+	}
+	if err := checkEncrypted(r.Dataset); err != nil {
+		return err
+	}
+	result, err := db.Exec(`INSERT INTO zfs_replications
+		(peer_id, dataset, target_name, interval_minutes, keep_snapshots, enabled)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		r.PeerID, r.Dataset, r.TargetName, r.IntervalMinutes, r.KeepSnapshots, r.Enabled)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE") {
+			return fmt.Errorf("a replication named %s already exists on this peer", r.TargetName)
+		}
+		return fmt.Errorf("failed to create replication: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get replication ID: %w", err)
+	}
+	r.ID = int(id)
+	return nil
+}
+
+// UpdateReplication saves the settings of a replication job
+func UpdateReplication(db *sql.DB, r *Replication) error {
+	if err := r.Validate(); err != nil {
+		return err
+	}
+	if err := checkEncrypted(r.Dataset); err != nil {
+		return err
+	}
+	result, err := db.Exec(`UPDATE zfs_replications SET peer_id = ?, dataset = ?, target_name = ?,
+		interval_minutes = ?, keep_snapshots = ?, enabled = ?
+		WHERE id = ?`,
+		r.PeerID, r.Dataset, r.TargetName, r.IntervalMinutes, r.KeepSnapshots, r.Enabled, r.ID)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE") {
+			return fmt.Errorf("a replication named %s already exists on this peer", r.TargetName)
+		}
+		return fmt.Errorf("failed to update replication: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("replication not found")
+	}
+	return nil
+}
+
+// DeleteReplication removes a replication job. The snapshots and the replica on
+// the peer are left in place.
+func DeleteReplication(db *sql.DB, id int) error {
+	if _, err := db.Exec("DELETE FROM zfs_replications WHERE id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete replication: %w", err)
+	}
+	return nil
+}
+
+// GetReplication returns a replication job
+func GetReplication(db *sql.DB, id int) (*Replication, error) {
+	replications, err := queryReplications(db, "WHERE r.id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(replications) == 0 {
+		return nil, fmt.Errorf("replication not found")
+	}
+	return replications[0], nil
+}
+
+// GetReplications returns all replication jobs with the progress of the running ones
+func GetReplications(db *sql.DB) ([]*Replication, error) {
+	replications, err := queryReplications(db, "ORDER BY r.dataset, p.name")
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range replications {
+		r.Progress = GetProgress(r.ID)
+	}
+	return replications, nil
+}
+
+func queryReplications(db *sql.DB, clause string, args ...interface{}) ([]*Replication, error) {
+	rows, err := db.Query(`SELECT r.id, r.peer_id, p.name, r.dataset, r.target_name, r.interval_minutes,
+		r.keep_snapshots, r.enabled, r.last_run, r.last_status, r.last_error, r.last_snapshot,
+		r.bytes_sent, r.created_at
+		FROM zfs_replications r JOIN peers p ON p.id = r.peer_id `+clause, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query replications: %w", err)
+	}
+	defer rows.Close()
+
+	var replications []*Replication
+	for rows.Next() {
+		r := &Replication{}
+		var lastRun sql.NullTime
+		var lastStatus, lastError, lastSnapshot sql.NullString
+		if err := rows.Scan(&r.ID, &r.PeerID, &r.PeerName, &r.Dataset, &r.TargetName, &r.IntervalMinutes,
+			&r.KeepSnapshots, &r.Enabled, &lastRun, &lastStatus, &lastError, &lastSnapshot,
+			&r.BytesSent, &r.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan replication: %w", err)
+		}
+		if lastRun.Valid {
+			r.LastRun = &lastRun.Time
+		}
+		r.LastStatus = lastStatus.String
+		r.LastError = lastError.String
+		r.LastSnapshot = lastSnapshot.String
+		replications = append(replications, r)
+	}
+	return replications, rows.Err()
+}
+
+// saveRun records the outcome of the last run of a job
+func saveRun(db *sql.DB, id int, snapshot string, sent uint64, runErr error) error {
+	status, msg := StatusSuccess, ""
+	if runErr != nil {
+		status, msg = StatusError, runErr.Error()
+	}
+	var err error
+	if snapshot != "" {
+		_, err = db.Exec(`UPDATE zfs_replications SET last_run = CURRENT_TIMESTAMP, last_status = ?,
+			last_error = ?, last_snapshot = ?, bytes_sent = ? WHERE id = ?`, status, msg, snapshot, sent, id)
+	} else {
+		_, err = db.Exec(`UPDATE zfs_replications SET last_run = CURRENT_TIMESTAMP, last_status = ?,
+			last_error = ?, bytes_sent = ? WHERE id = ?`, status, msg, sent, id)
+	}
+	return err
+}
@@ -0,0 +1,126 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+package zfsrepl
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSnapshotName(t *testing.T) {
+	name := snapshotName(time.Date(2025, 3, 1, 14, 5, 30, 0, time.UTC))
+	if name != "anemone-repl-20250301-140530" {
+		t.Fatalf("snapshotName = %q", name)
+	}
+	if !IsReplicationSnapshot(name) {
+		t.Errorf("IsReplicationSnapshot(%q) = false", name)
+	}
+	for _, other := range []string{"auto-daily-20250301-1405", "anemone-repl-", "anemone-repl-20250301-1405", "before-upgrade"} {
+		if IsReplicationSnapshot(other) {
+			t.Errorf("IsReplicationSnapshot(%q) = true", other)
+		}
+	}
+}
+
+func TestCommonSnapshot(t *testing.T) {
+	local := []string{"anemone-repl-20250303-000000", "auto-daily-20250303-0000", "anemone-repl-20250301-000000", "anemone-repl-20250302-000000"}
+
+	tests := []struct {
+		remote []string
+		want   string
+	}{
+		{[]string{"anemone-repl-20250301-000000", "anemone-repl-20250302-000000"}, "anemone-repl-20250302-000000"},
+		{[]string{"anemone-repl-20250301-000000"}, "anemone-repl-20250301-000000"},
+		{[]string{"auto-daily-20250303-0000"}, ""}, // Only replication snapshots count
+		{nil, ""},
+	}
+	for _, tt := range tests {
+		if got := commonSnapshot(local, tt.remote); got != tt.want {
+			t.Errorf("commonSnapshot(%v) = %q, want %q", tt.remote, got, tt.want)
+		}
+	}
+}
+
+func TestExpiredSnapshots(t *testing.T) {
+	names := []string{"anemone-repl-20250303-000000", "manual", "anemone-repl-20250301-000000", "anemone-repl-20250302-000000"}
+
+	want := []string{"anemone-repl-20250301-000000"}
+	if got := expiredSnapshots(names, 2); !reflect.DeepEqual(got, want) {
+		t.Errorf("expiredSnapshots(2) = %v, want %v", got, want)
+	}
+	// The newest snapshot is the base of the next send and is always kept
+	if got := expiredSnapshots(names, 0); len(got) != 2 {
+		t.Errorf("expiredSnapshots(0) = %v, want the 2 oldest", got)
+	}
+	if got := expiredSnapshots(names, 5); got != nil {
+		t.Errorf("expiredSnapshots(5) = %v, want none", got)
+	}
+}
+
+func TestReceiveDataset(t *testing.T) {
+	dataset, err := receiveDataset("tank/replicas", "NAS du salon", "data")
+	if err != nil || dataset != "tank/replicas/NAS_du_salon/data" {
+		t.Errorf("receiveDataset = %q, %v", dataset, err)
+	}
+
+	invalid := []struct{ base, source, target string }{
+		{"", "nas", "data"},
+		{"tank/replicas", "", "data"},
+		{"tank/replicas", "..", "data"},
+		{"tank/replicas", "nas", "../data"},
+		{"tank/replicas", "nas", "a/b"},
+	}
+	for _, tt := range invalid {
+		if _, err := receiveDataset(tt.base, tt.source, tt.target); err == nil {
+			t.Errorf("receiveDataset(%q, %q, %q) = nil error", tt.base, tt.source, tt.target)
+		}
+	}
+}
+
+func TestReplicationValidate(t *testing.T) {
+	r := &Replication{PeerID: 1, Dataset: "tank/home data", IntervalMinutes: 60, KeepSnapshots: 7}
+	if err := r.Validate(); err == nil {
+		t.Errorf("Validate accepted an invalid dataset")
+	}
+
+	r = &Replication{PeerID: 1, Dataset: "tank/shares", IntervalMinutes: 60, KeepSnapshots: 7}
+	if err := r.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	if r.TargetName != "shares" {
+		t.Errorf("TargetName = %q, want shares", r.TargetName)
+	}
+
+	invalid := []*Replication{
+		{Dataset: "tank/shares", IntervalMinutes: 60, KeepSnapshots: 7},
+		{PeerID: 1, Dataset: "tank", IntervalMinutes: 60, KeepSnapshots: 7},
+		{PeerID: 1, Dataset: "tank/shares", IntervalMinutes: 5, KeepSnapshots: 7},
+		{PeerID: 1, Dataset: "tank/shares", IntervalMinutes: 60, KeepSnapshots: 0},
+		{PeerID: 1, Dataset: "tank/shares", TargetName: "-bad", IntervalMinutes: 60, KeepSnapshots: 7},
+	}
+	for _, r := range invalid {
+		if err := r.Validate(); err == nil {
+			t.Errorf("Validate(%+v) = nil, want error", r)
+		}
+	}
+}
+
+func TestIsDue(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	r := &Replication{IntervalMinutes: 60}
+	if !isDue(r, now) {
+		t.Errorf("isDue without a previous run = false")
+	}
+	last := now.Add(-30 * time.Minute)
+	r.LastRun = &last
+	if isDue(r, now) {
+		t.Errorf("isDue 30 minutes after the last run = true")
+	}
+	last = now.Add(-time.Hour)
+	if !isDue(r, now) {
+		t.Errorf("isDue an hour after the last run = false")
+	}
+}
@@ -0,0 +1,404 @@
+// Anemone - Multi-user NAS with P2P encrypted synchronization
+// Copyright (C) 2025 juste-un-gars
+// Licensed under the GNU Affero General Public License v3.0
+
+// This file runs the replication jobs: it snapshots the dataset, resumes or
+// starts the send and streams it to the receive endpoint of the peer.
+
+package zfsrepl
+
+import (
+	"crypto/tls"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	gosync "sync"
+	"time"
+
+	"github.com/juste-un-gars/anemone/internal/logger"
+	"github.com/juste-un-gars/anemone/internal/peers"
+	"github.com/juste-un-gars/anemone/internal/storage"
+	"github.com/juste-un-gars/anemone/internal/sync"
+)
+
+// checkInterval is how often the scheduler looks for due jobs
+const checkInterval = 5 * time.Minute
+
+// ErrRunning is returned when a job is started while it is already running
+var ErrRunning = errors.New("this replication is already running")
+
+// errLocalSend marks a failure of zfs send itself rather than of the transfer
+var errLocalSend = errors.New("local send failed")
+
+// Progress is the state of a running job
+type Progress struct {
+	Snapshot string    `json:"snapshot"`
+	Bytes    uint64    `json:"bytes"`
+	Total    uint64    `json:"total"` // Estimated stream size, 0 if unknown
+	Started  time.Time `json:"started"`
+}
+
+var (
+	progressMu gosync.Mutex
+	progress   = make(map[int]*Progress)
+)
+
+// GetProgress returns a copy of the progress of a running job, or nil
+func GetProgress(id int) *Progress {
+	progressMu.Lock()
+	defer progressMu.Unlock()
+	p, ok := progress[id]
+	if !ok {
+		return nil
+	}
+	copied := *p
+	return &copied
+}
+
+// IsRunning reports whether a job is running
+func IsRunning(id int) bool {
+	return GetProgress(id) != nil
+}
+
+// progressReader counts the bytes of a send stream into the progress of a job
+type progressReader struct {
+	r   io.Reader
+	id  int
+	n   uint64
+	eof bool
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	if n > 0 {
+		p.n += uint64(n)
+		progressMu.Lock()
+		if prog, ok := progress[p.id]; ok {
+			prog.Bytes += uint64(n)
+		}
+		progressMu.Unlock()
+	}
+	if err == io.EOF {
+		p.eof = true
+	}
+	return n, err
+}
+
+// ReceiveState is what a peer holds of a replica
+type ReceiveState struct {
+	Exists      bool     `json:"exists"`
+	Snapshots   []string `json:"snapshots"` // Replication snapshot names
+	ResumeToken string   `json:"resume_token"`
+}
+
+// peerConn talks to the ZFS endpoints of a peer on behalf of a job
+type peerConn struct {
+	baseURL    string
+	password   string
+	serverName string
+	target     string
+}
+
+// newPeerConn prepares the connection to the peer of a job
+func newPeerConn(db *sql.DB, r *Replication) (*peerConn, error) {
+	var masterKey string
+	if err := db.QueryRow("SELECT value FROM system_config WHERE key = 'master_key'").Scan(&masterKey); err != nil {
+		return nil, fmt.Errorf("failed to get master key: %w", err)
+	}
+	serverName, err := sync.GetServerName(db)
+	if err != nil {
+		return nil, err
+	}
+	peer, err := peers.GetByID(db, r.PeerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get peer: %w", err)
+	}
+	if err := peers.ValidatePeerAddress(peer.Address); err != nil {
+		return nil, fmt.Errorf("invalid peer address: %w", err)
+	}
+	var password string
+	if peer.Password != nil && len(*peer.Password) > 0 {
+		if password, err = peers.DecryptPeerPassword(peer.Password, masterKey); err != nil {
+			return nil, fmt.Errorf("failed to decrypt peer password")
+		}
+	}
+	return &peerConn{baseURL: peer.URL(), password: password, serverName: serverName, target: r.TargetName}, nil
+}
+
+// peerClient returns an HTTPS client accepting the self-signed certificates of
+// peers. A zero timeout is used for streams, which can take hours.
+func peerClient(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}},
+		Timeout:   timeout,
+	}
+}
+
+// request sends a request to a ZFS endpoint of the peer and checks the status
+func (c *peerConn) request(method, endpoint string, params url.Values, body io.Reader, timeout time.Duration) (*http.Response, error) {
+	if params == nil {
+		params = url.Values{}
+	}
+	params.Set("source_server", c.serverName)
+	params.Set("target", c.target)
+	req, err := http.NewRequest(method, c.baseURL+"/api/sync/zfs/"+endpoint+"?"+params.Encode(), body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/octet-stream")
+	}
+	if c.password != "" {
+		req.Header.Set("X-Sync-Password", c.password)
+	}
+	resp, err := peerClient(timeout).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		resp.Body.Close()
+		return nil, fmt.Errorf("peer returned %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return resp, nil
+}
+
+// state returns what the peer holds of the replica
+func (c *peerConn) state() (*ReceiveState, error) {
+	resp, err := c.request(http.MethodGet, "state", nil, nil, 30*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var state ReceiveState
+	if err := json.NewDecoder(resp.Body).Decode(&state); err != nil {
+		return nil, fmt.Errorf("invalid response from peer: %w", err)
+	}
+	return &state, nil
+}
+
+// abort discards the interrupted receive on the peer
+func (c *peerConn) abort() error {
+	resp, err := c.request(http.MethodDelete, "state", nil, nil, time.Minute)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// send streams a zfs send to the peer and returns the number of bytes sent.
+// Failures of zfs send itself wrap errLocalSend.
+func (c *peerConn) send(id int, opts storage.SendOptions, mode string, keep int) (uint64, error) {
+	total, err := storage.EstimateSendSize(opts)
+	if err != nil {
+		logger.Warn("ZFS replication: Failed to estimate send size", "id", id, "error", err)
+	}
+	progressMu.Lock()
+	progress[id].Snapshot = opts.Snapshot
+	progress[id].Bytes = 0
+	progress[id].Total = total
+	progressMu.Unlock()
+
+	stream, err := storage.Send(opts)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %w", errLocalSend, err)
+	}
+	body := &progressReader{r: stream, id: id}
+	params := url.Values{"mode": {mode}, "keep": {strconv.Itoa(keep)}}
+	resp, err := c.request(http.MethodPost, "receive", params, body, 0)
+	if err != nil {
+		// A stream that ended early failed on this side
+		if body.eof {
+			if waitErr := stream.Wait(); waitErr != nil {
+				return body.n, fmt.Errorf("%w: %w", errLocalSend, waitErr)
+			}
+		} else {
+			stream.Abort()
+		}
+		return body.n, err
+	}
+	resp.Body.Close()
+	if err := stream.Wait(); err != nil {
+		return body.n, fmt.Errorf("%w: %w", errLocalSend, err)
+	}
+	return body.n, nil
+}
+
+// Receive modes, telling the peer how to apply a stream
+const (
+	ModeFull        = "full"
+	ModeIncremental = "incremental"
+	ModeResume      = "resume"
+)
+
+// localSnapshots returns the replication snapshots of a dataset, oldest first
+func localSnapshots(dataset string) ([]string, error) {
+	snaps, err := storage.ListSnapshots(dataset)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, s := range snaps {
+		if s.Dataset == dataset {
+			names = append(names, s.SnapName)
+		}
+	}
+	return replicationSnapshots(names), nil
+}
+
+// run replicates a dataset once. It returns the snapshot the peer now holds and
+// the number of bytes sent.
+func run(db *sql.DB, r *Replication) (string, uint64, error) {
+	if !storage.IsZFSAvailable() {
+		return "", 0, fmt.Errorf("ZFS is not available on this system")
+	}
+	// The encryption of the dataset may have changed since the job was saved
+	if err := checkEncrypted(r.Dataset); err != nil {
+		return "", 0, err
+	}
+	conn, err := newPeerConn(db, r)
+	if err != nil {
+		return "", 0, err
+	}
+	state, err := conn.state()
+	if err != nil {
+		return "", 0, err
+	}
+
+	var sent uint64
+	if state.ResumeToken != "" {
+		logger.Info("ZFS replication: Resuming interrupted send", "dataset", r.Dataset, "peer", r.PeerName)
+		n, err := conn.send(r.ID, storage.SendOptions{ResumeToken: state.ResumeToken}, ModeResume, r.KeepSnapshots)
+		sent += n
+		if err != nil {
+			// The snapshot the token points to is gone: start over on the next run
+			if errors.Is(err, errLocalSend) {
+				if abortErr := conn.abort(); abortErr != nil {
+					logger.Warn("ZFS replication: Failed to abort receive on peer", "peer", r.PeerName, "error", abortErr)
+				}
+			}
+			return "", sent, err
+		}
+		if state, err = conn.state(); err != nil {
+			return "", sent, err
+		}
+	}
+
+	local, err := localSnapshots(r.Dataset)
+	if err != nil {
+		return "", sent, err
+	}
+	base := commonSnapshot(local, state.Snapshots)
+	if base == "" && state.Exists {
+		return "", sent, fmt.Errorf("the replica on %s has no snapshot in common with %s; delete it on the peer to send a full copy", r.PeerName, r.Dataset)
+	}
+
+	name := snapshotName(time.Now())
+	if err := storage.CreateSnapshot(storage.SnapshotCreateOptions{Dataset: r.Dataset, Name: name}); err != nil {
+		return "", sent, err
+	}
+	opts := storage.SendOptions{Snapshot: r.Dataset + "@" + name, Raw: true}
+	mode := ModeFull
+	if base != "" {
+		opts.Incremental = r.Dataset + "@" + base
+		mode = ModeIncremental
+	}
+	n, err := conn.send(r.ID, opts, mode, r.KeepSnapshots)
+	sent += n
+	if err != nil {
+		return "", sent, err
+	}
+
+	// Keep only the new snapshot, which the next send starts from
+	if err := storage.HoldSnapshot(opts.Snapshot, HoldTag); err != nil {
+		logger.Warn("ZFS replication: Failed to hold snapshot", "snapshot", opts.Snapshot, "error", err)
+	}
+	for _, old := range local {
+		fullName := r.Dataset + "@" + old
+		storage.ReleaseSnapshot(fullName, HoldTag)
+		if err := storage.DeleteSnapshot(fullName, false, false); err != nil {
+			logger.Warn("ZFS replication: Failed to delete old snapshot", "snapshot", fullName, "error", err)
+		}
+	}
+	return name, sent, nil
+}
+
+// Run runs a replication job now and records its outcome
+func Run(db *sql.DB, id int) error {
+	r, err := GetReplication(db, id)
+	if err != nil {
+		return err
+	}
+
+	progressMu.Lock()
+	if _, running := progress[id]; running {
+		progressMu.Unlock()
+		return ErrRunning
+	}
+	progress[id] = &Progress{Started: time.Now()}
+	progressMu.Unlock()
+	defer func() {
+		progressMu.Lock()
+		delete(progress, id)
+		progressMu.Unlock()
+	}()
+
+	snapshot, sent, err := run(db, r)
+	if err == nil {
+		logger.Info("ZFS replication completed", "dataset", r.Dataset, "peer", r.PeerName, "snapshot", snapshot, "bytes", sent)
+	}
+	if saveErr := saveRun(db, id, snapshot, sent, err); saveErr != nil {
+		logger.Warn("ZFS replication: Failed to record run", "id", id, "error", saveErr)
+	}
+	if err != nil {
+		return fmt.Errorf("replication of %s to %s: %w", r.Dataset, r.PeerName, err)
+	}
+	return nil
+}
+
+// isDue reports whether a job must run at now
+func isDue(r *Replication, now time.Time) bool {
+	if r.LastRun == nil {
+		return true
+	}
+	return !now.Before(r.LastRun.Add(time.Duration(r.IntervalMinutes) * time.Minute))
+}
+
+// StartScheduler runs the enabled replication jobs in the background when they are due
+func StartScheduler(db *sql.DB) {
+	go func() {
+		ticker := time.NewTicker(checkInterval)
+		defer ticker.Stop()
+
+		for {
+			<-ticker.C
+
+			if !storage.IsZFSAvailable() {
+				continue
+			}
+			replications, err := queryReplications(db, "WHERE r.enabled = 1 AND p.enabled = 1 ORDER BY r.id")
+			if err != nil {
+				logger.Warn("ZFS replication: Failed to get jobs", "error", err)
+				continue
+			}
+			now := time.Now()
+			for _, r := range replications {
+				if !isDue(r, now) {
+					continue
+				}
+				if err := Run(db, r.ID); err != nil && !errors.Is(err, ErrRunning) {
+					logger.Warn("ZFS replication: Scheduled run failed", "error", err)
+				}
+			}
+		}
+	}()
+
+	logger.Info("✅ ZFS replication scheduler started (checks every 5 minutes)")
+}
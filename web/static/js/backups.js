@@ -81,6 +81,49 @@ function deleteServerBackup(filename) {
     }).catch(function(err) { alert(t.backupDeleteError + ': ' + err.message); });
 }
 
+/* Cloud backups: live progress of running syncs */
+function formatBytes(bytes) {
+    if (!bytes) return '0 B';
+    var k = 1024;
+    var sizes = ['B', 'KB', 'MB', 'GB', 'TB'];
+    var i = Math.floor(Math.log(bytes) / Math.log(k));
+    return parseFloat((bytes / Math.pow(k, i)).toFixed(2)) + ' ' + sizes[i];
+}
+
+function formatDuration(seconds) {
+    if (seconds < 60) return seconds + 's';
+    if (seconds < 3600) return Math.floor(seconds / 60) + 'm ' + (seconds % 60) + 's';
+    return Math.floor(seconds / 3600) + 'h ' + Math.floor((seconds % 3600) / 60) + 'm';
+}
+
+function pollRcloneProgress(el) {
+    var id = el.getAttribute('data-rclone-progress');
+    fetch('/admin/rclone/' + id + '/progress')
+        .then(function(r) { if (!r.ok) throw new Error('HTTP ' + r.status); return r.json(); })
+        .then(function(data) {
+            if (!data.running) {
+                // Reload once the sync seen running has finished; it may also not have started yet
+                if (el.getAttribute('data-seen')) { location.reload(); return; }
+                setTimeout(function() { pollRcloneProgress(el); }, 2000);
+                return;
+            }
+            el.setAttribute('data-seen', '1');
+            var p = data.progress;
+            var parts = [];
+            if (p.source) parts.push(p.source + ' (' + p.source_index + '/' + p.source_count + ')');
+            if (p.total_bytes > 0) parts.push(Math.floor(p.bytes * 100 / p.total_bytes) + '%');
+            parts.push(p.transfers + '/' + p.total_transfers + ' ' + t.progressFiles);
+            if (p.speed > 0) parts.push(formatBytes(Math.round(p.speed)) + '/s');
+            if (p.eta >= 0) parts.push(t.progressEta + ' ' + formatDuration(p.eta));
+            if (p.cancelling) parts.push(t.progressCancelling);
+            el.textContent = parts.join(' · ');
+            setTimeout(function() { pollRcloneProgress(el); }, 2000);
+        })
+        .catch(function() { setTimeout(function() { pollRcloneProgress(el); }, 5000); });
+}
+
+document.querySelectorAll('[data-rclone-progress]').forEach(pollRcloneProgress);
+
 /* Event delegation */
 document.addEventListener('click', function(e) {
     var target = e.target.closest('[data-action]');
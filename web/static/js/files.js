@@ -1,4 +1,4 @@
-/* Anemone files page JS - upload, mkdir, rename, delete, modals */
+/* Anemone files page JS - upload, mkdir, rename, delete, previous versions, modals */
 
 var pageData = JSON.parse(document.getElementById('page-data')?.textContent || '{}');
 var t = pageData.translations || {};
@@ -30,6 +30,114 @@ function deleteItem(share, path, name) {
     }).catch(function(err) { alert(t.error + ' ' + err); });
 }
 
+/* Previous versions */
+var versionsItem = null;
+
+function versionQuery(path, snapshot) {
+    var q = 'share=' + encodeURIComponent(currentShare) + '&path=' + encodeURIComponent(path);
+    if (snapshot) q += '&snapshot=' + encodeURIComponent(snapshot);
+    return q;
+}
+
+function versionButton(label, action, attrs) {
+    var btn = document.createElement(attrs.href ? 'a' : 'button');
+    btn.className = 'v2-btn';
+    btn.style.cssText = 'font-size:0.75rem;padding:0.25rem 0.5rem;text-decoration:none;';
+    btn.textContent = label;
+    if (action) btn.setAttribute('data-action', action);
+    Object.keys(attrs).forEach(function(k) { btn.setAttribute(k, attrs[k]); });
+    return btn;
+}
+
+function versionRow(label, buttons) {
+    var row = document.createElement('div');
+    row.style.cssText = 'display:flex;align-items:center;justify-content:space-between;gap:0.5rem;padding:0.5rem 0;border-bottom:1px solid var(--border);font-size:0.8125rem;color:var(--text-primary);';
+    var name = document.createElement('span');
+    name.style.wordBreak = 'break-all';
+    name.textContent = label;
+    var actions = document.createElement('div');
+    actions.style.cssText = 'display:flex;gap:0.375rem;flex-shrink:0;';
+    buttons.forEach(function(b) { actions.appendChild(b); });
+    row.appendChild(name);
+    row.appendChild(actions);
+    return row;
+}
+
+function setVersionsMessage(message) {
+    var list = document.getElementById('versionsList');
+    list.innerHTML = '';
+    var p = document.createElement('p');
+    p.style.cssText = 'font-size:0.8125rem;color:var(--text-muted);';
+    p.textContent = message;
+    list.appendChild(p);
+}
+
+function itemButtons(path, name, isDir, snapshot) {
+    var buttons = [];
+    if (isDir) {
+        buttons.push(versionButton(t.versionsOpen, 'browseVersion', {'data-snapshot': snapshot, 'data-path': path}));
+    } else {
+        buttons.push(versionButton(t.download, null, {href: '/api/files/versions/download?' + versionQuery(path, snapshot)}));
+    }
+    if (path !== '' && path !== '.') {
+        buttons.push(versionButton(t.versionsRestore, 'restoreVersion', {'data-snapshot': snapshot, 'data-path': path, 'data-name': name}));
+    }
+    return buttons;
+}
+
+function showVersions(path, name, isDir) {
+    versionsItem = {path: path, name: name, isDir: isDir};
+    document.getElementById('versionsTitle').textContent = (t.versionsTitle || '').replace('{name}', name);
+    document.getElementById('versionsBack').classList.add('hidden');
+    document.getElementById('versionsReplace').checked = false;
+    document.getElementById('versionsModal').classList.remove('hidden');
+    setVersionsMessage(t.versionsLoading);
+
+    fetch('/api/files/versions?' + versionQuery(path)).then(function(r) { return r.json(); }).then(function(data) {
+        if (!data.success) { setVersionsMessage(t.error + ' ' + (data.message || '')); return; }
+        if (data.versions.length === 0) { setVersionsMessage(t.versionsEmpty); return; }
+        var list = document.getElementById('versionsList');
+        list.innerHTML = '';
+        data.versions.forEach(function(v) {
+            list.appendChild(versionRow(new Date(v.time).toLocaleString(), itemButtons(path, name, isDir, v.snapshot)));
+        });
+    }).catch(function(err) { setVersionsMessage(t.error + ' ' + err); });
+}
+
+function browseVersion(snapshot, path) {
+    document.getElementById('versionsBack').classList.remove('hidden');
+    setVersionsMessage(t.versionsLoading);
+
+    fetch('/api/files/versions/browse?' + versionQuery(path, snapshot)).then(function(r) { return r.json(); }).then(function(data) {
+        if (!data.success) { setVersionsMessage(t.error + ' ' + (data.message || '')); return; }
+        if (data.files.length === 0) { setVersionsMessage(t.versionsEmptyFolder); return; }
+        var list = document.getElementById('versionsList');
+        list.innerHTML = '';
+        data.files.forEach(function(f) {
+            list.appendChild(versionRow((f.IsDir ? f.Name + '/' : f.Name), itemButtons(f.Path, f.Name, f.IsDir, snapshot)));
+        });
+    }).catch(function(err) { setVersionsMessage(t.error + ' ' + err); });
+}
+
+function restoreVersion(snapshot, path, name) {
+    if (!confirm((t.versionsConfirmRestore || '').replace('{name}', name))) return;
+    fetch('/api/files/versions/restore', {
+        method: 'POST',
+        headers: {'Content-Type': 'application/json'},
+        body: JSON.stringify({
+            share: currentShare,
+            path: path,
+            snapshot: snapshot,
+            replace: document.getElementById('versionsReplace').checked
+        })
+    }).then(function(r) { return r.json(); }).then(function(data) {
+        if (data.success) {
+            alert((t.versionsRestored || '').replace('{name}', data.name));
+            window.location.reload();
+        } else { alert(t.error + ' ' + (data.message || '')); }
+    }).catch(function(err) { alert(t.error + ' ' + err); });
+}
+
 /* Mkdir form */
 var mkdirForm = document.getElementById('mkdirForm');
 if (mkdirForm) {
@@ -128,7 +236,7 @@ if (shareSelector) {
 }
 
 /* Close modals on background click */
-['uploadModal', 'mkdirModal', 'renameModal'].forEach(function(id) {
+['uploadModal', 'mkdirModal', 'renameModal', 'versionsModal'].forEach(function(id) {
     var el = document.getElementById(id);
     if (el) {
         el.addEventListener('click', function(e) {
@@ -166,5 +274,25 @@ document.addEventListener('click', function(e) {
                 target.getAttribute('data-name')
             );
             break;
+        case 'showVersions':
+            showVersions(
+                target.getAttribute('data-path'),
+                target.getAttribute('data-name'),
+                target.getAttribute('data-dir') === 'true'
+            );
+            break;
+        case 'browseVersion':
+            browseVersion(target.getAttribute('data-snapshot'), target.getAttribute('data-path'));
+            break;
+        case 'versionsBack':
+            if (versionsItem) showVersions(versionsItem.path, versionsItem.name, versionsItem.isDir);
+            break;
+        case 'restoreVersion':
+            restoreVersion(
+                target.getAttribute('data-snapshot'),
+                target.getAttribute('data-path'),
+                target.getAttribute('data-name')
+            );
+            break;
     }
 });
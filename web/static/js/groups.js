@@ -0,0 +1,111 @@
+/* Anemone groups management */
+(function() {
+    var pageData = JSON.parse(document.getElementById('page-data').textContent || '{}');
+    var t = pageData.translations || {};
+
+    function request(method, url, body) {
+        var opts = { method: method, headers: {} };
+        if (body !== undefined) {
+            opts.headers['Content-Type'] = 'application/json';
+            opts.body = JSON.stringify(body);
+        }
+        return fetch(url, opts)
+        .then(function(r) { return r.json(); })
+        .then(function(data) {
+            if (data.error) {
+                alert(t.error + ': ' + data.error);
+                return;
+            }
+            window.location.reload();
+        })
+        .catch(function(err) { alert(t.error + ': ' + err); });
+    }
+
+    function formValue(form, name) {
+        var el = form.elements[name];
+        if (el.type === 'checkbox') return el.checked;
+        if (el.type === 'number' || name === 'user_id') return parseInt(el.value, 10) || 0;
+        return el.value.trim();
+    }
+
+    document.addEventListener('submit', function(e) {
+        var form = e.target;
+        if (form.id === 'createGroupForm') {
+            e.preventDefault();
+            request('POST', '/api/admin/groups', {
+                name: formValue(form, 'name'),
+                description: formValue(form, 'description'),
+                quota_gb: formValue(form, 'quota_gb')
+            });
+            return;
+        }
+        switch (form.getAttribute('data-form')) {
+            case 'updateGroup':
+                e.preventDefault();
+                request('PUT', '/api/admin/groups/' + form.getAttribute('data-id'), {
+                    description: formValue(form, 'description'),
+                    quota_gb: formValue(form, 'quota_gb')
+                });
+                break;
+            case 'addMember':
+                e.preventDefault();
+                request('POST', '/api/admin/groups/' + form.getAttribute('data-group') + '/members', {
+                    user_id: formValue(form, 'user_id')
+                });
+                break;
+            case 'createShare':
+                e.preventDefault();
+                request('POST', '/api/admin/groups/' + form.getAttribute('data-group') + '/shares', {
+                    name: formValue(form, 'name'),
+                    default_access: formValue(form, 'default_access'),
+                    sync_enabled: formValue(form, 'sync_enabled')
+                });
+                break;
+        }
+    });
+
+    document.addEventListener('change', function(e) {
+        var target = e.target.closest('[data-change]');
+        if (!target) return;
+        switch (target.getAttribute('data-change')) {
+            case 'shareDefault':
+                request('PUT', '/api/admin/group-shares/' + target.getAttribute('data-id'), {
+                    default_access: target.value,
+                    sync_enabled: target.getAttribute('data-sync') === 'true'
+                });
+                break;
+            case 'shareSync':
+                request('PUT', '/api/admin/group-shares/' + target.getAttribute('data-id'), {
+                    default_access: target.getAttribute('data-access'),
+                    sync_enabled: target.checked
+                });
+                break;
+            case 'memberAccess':
+                request('POST', '/api/admin/group-shares/' + target.getAttribute('data-share') + '/access', {
+                    user_id: parseInt(target.getAttribute('data-id'), 10),
+                    access: target.value
+                });
+                break;
+        }
+    });
+
+    document.addEventListener('click', function(e) {
+        var target = e.target.closest('[data-action]');
+        if (!target) return;
+        var name = target.getAttribute('data-name');
+        switch (target.getAttribute('data-action')) {
+            case 'deleteGroup':
+                if (!confirm(t.deleteGroupConfirm + '\n\n' + name)) return;
+                request('DELETE', '/api/admin/groups/' + target.getAttribute('data-id'));
+                break;
+            case 'removeMember':
+                if (!confirm(t.removeMemberConfirm + '\n\n' + name)) return;
+                request('DELETE', '/api/admin/groups/' + target.getAttribute('data-group') + '/members/' + target.getAttribute('data-id'));
+                break;
+            case 'deleteShare':
+                if (!confirm(t.deleteShareConfirm + '\n\n' + name)) return;
+                request('DELETE', '/api/admin/group-shares/' + target.getAttribute('data-id'));
+                break;
+        }
+    });
+})();
@@ -0,0 +1,275 @@
+// rclone_restore.js — Cloud restore page logic (v2_rclone_restore.html)
+
+var pageData = JSON.parse(document.getElementById('page-data')?.textContent || '{}');
+var t = pageData.translations || {};
+var baseURL = '/admin/rclone/' + pageData.backupId + '/restore';
+
+var currentUser = '';
+var currentVersion = '';
+var currentPath = '';
+var selectedItems = new Set();
+var jobsTimer = null;
+
+document.addEventListener('DOMContentLoaded', function() { loadJobs(); loadUsers(); });
+
+document.getElementById('cloud-user').addEventListener('change', function(e) {
+    currentUser = e.target.value;
+    currentVersion = '';
+    selectedItems.clear();
+    updateSelectionUI();
+    if (!currentUser) {
+        document.getElementById('file-browser').classList.add('hidden');
+        document.getElementById('version-select').classList.add('hidden');
+        return;
+    }
+    document.getElementById('target-share').placeholder = 'backup_' + currentUser;
+    loadVersions();
+    loadFiles('');
+});
+
+document.getElementById('cloud-version').addEventListener('change', function(e) {
+    currentVersion = e.target.value;
+    selectedItems.clear();
+    updateSelectionUI();
+    loadFiles('');
+});
+
+// Event delegation for data-action buttons
+document.addEventListener('click', function(e) {
+    var target = e.target.closest('[data-action]');
+    if (!target) return;
+    if (target.getAttribute('data-action') === 'startRestore') { startRestore(); }
+});
+
+async function loadUsers() {
+    try {
+        var response = await fetch(baseURL + '/users');
+        var users = await response.json();
+        if (!response.ok) throw new Error(users.message || 'Failed to load users');
+        var select = document.getElementById('cloud-user');
+        if (users.length === 0) {
+            select.options[0].textContent = t.noUsers;
+            return;
+        }
+        users.forEach(function(user) {
+            var option = document.createElement('option');
+            option.value = user;
+            option.textContent = user;
+            select.appendChild(option);
+        });
+    } catch (error) { console.error('Error loading cloud users:', error); alert(t.errorUsers + ': ' + error.message); }
+}
+
+async function loadVersions() {
+    var select = document.getElementById('cloud-version');
+    while (select.options.length > 1) select.remove(1);
+    select.value = '';
+    try {
+        var response = await fetch(baseURL + '/versions?user=' + encodeURIComponent(currentUser));
+        var versions = await response.json();
+        if (!response.ok) throw new Error(versions.message || 'Failed to load versions');
+        versions.forEach(function(version) {
+            var option = document.createElement('option');
+            option.value = version.name;
+            option.textContent = new Date(version.time).toLocaleString();
+            select.appendChild(option);
+        });
+        document.getElementById('version-select').classList.toggle('hidden', versions.length === 0);
+    } catch (error) { console.error('Error loading cloud versions:', error); }
+}
+
+async function loadFiles(path) {
+    document.getElementById('loading').classList.remove('hidden');
+    document.getElementById('file-browser').classList.add('hidden');
+    try {
+        var response = await fetch(baseURL + '/files?user=' + encodeURIComponent(currentUser) + '&version=' + encodeURIComponent(currentVersion) + '&path=' + encodeURIComponent(path));
+        var entries = await response.json();
+        if (!response.ok) throw new Error(entries.message || 'Failed to load files');
+        currentPath = path;
+        renderFiles(entries || []);
+        document.getElementById('file-browser').classList.remove('hidden');
+    } catch (error) { console.error('Error loading cloud files:', error); alert(t.errorFiles + ': ' + error.message); }
+    finally { document.getElementById('loading').classList.add('hidden'); }
+}
+
+function renderFiles(entries) {
+    renderBreadcrumb();
+    var fileList = document.getElementById('file-list');
+    fileList.innerHTML = '';
+    if (entries.length === 0) {
+        var emptyRow = document.createElement('tr');
+        var emptyCell = document.createElement('td');
+        emptyCell.colSpan = 4;
+        emptyCell.style.cssText = 'text-align:center;color:var(--text-muted);font-size:0.8125rem;';
+        emptyCell.textContent = t.emptyDir;
+        emptyRow.appendChild(emptyCell);
+        fileList.appendChild(emptyRow);
+        return;
+    }
+    entries.forEach(function(item) {
+        var row = document.createElement('tr');
+
+        var checkboxCell = document.createElement('td');
+        var checkbox = document.createElement('input');
+        checkbox.type = 'checkbox';
+        checkbox.style.accentColor = 'var(--info)';
+        checkbox.checked = selectedItems.has(item.path);
+        checkbox.addEventListener('change', function() {
+            if (checkbox.checked) selectedItems.add(item.path); else selectedItems.delete(item.path);
+            updateSelectionUI();
+        });
+        checkboxCell.appendChild(checkbox);
+        row.appendChild(checkboxCell);
+
+        var nameCell = document.createElement('td');
+        nameCell.style.fontSize = '0.8125rem';
+        if (item.is_dir) {
+            var link = document.createElement('a');
+            link.href = '#';
+            link.style.cssText = 'color:var(--info);text-decoration:none;';
+            link.textContent = item.name + '/';
+            link.addEventListener('click', function(e) { e.preventDefault(); loadFiles(item.path); });
+            nameCell.appendChild(link);
+        } else {
+            nameCell.textContent = item.name;
+        }
+        row.appendChild(nameCell);
+
+        var sizeCell = document.createElement('td');
+        sizeCell.style.cssText = 'font-size:0.8125rem;color:var(--text-secondary);';
+        sizeCell.textContent = item.is_dir ? '-' : formatBytes(item.size);
+        row.appendChild(sizeCell);
+
+        var dateCell = document.createElement('td');
+        dateCell.style.cssText = 'font-size:0.8125rem;color:var(--text-secondary);';
+        dateCell.textContent = new Date(item.mod_time).toLocaleString();
+        row.appendChild(dateCell);
+
+        fileList.appendChild(row);
+    });
+}
+
+function renderBreadcrumb() {
+    var breadcrumb = document.getElementById('breadcrumb');
+    breadcrumb.innerHTML = '';
+    var crumbs = [{ name: currentUser, path: '' }];
+    var acc = '';
+    currentPath.split('/').filter(function(p) { return p; }).forEach(function(part) {
+        acc = acc ? acc + '/' + part : part;
+        crumbs.push({ name: part, path: acc });
+    });
+    crumbs.forEach(function(crumb, index) {
+        var li = document.createElement('li');
+        li.style.cssText = 'font-size:0.8125rem;color:var(--text-muted);';
+        if (index > 0) li.appendChild(document.createTextNode(' / '));
+        var link = document.createElement('a');
+        link.href = '#';
+        link.style.cssText = 'color:var(--info);text-decoration:none;';
+        link.textContent = crumb.name;
+        link.addEventListener('click', function(e) { e.preventDefault(); loadFiles(crumb.path); });
+        li.appendChild(link);
+        breadcrumb.appendChild(li);
+    });
+}
+
+function updateSelectionUI() {
+    document.getElementById('selection-count').textContent = selectedItems.size > 0 ? selectedItems.size + ' ' + t.selectionCount : t.allFiles;
+}
+
+async function startRestore() {
+    if (!currentUser) return;
+    if (!confirm(t.confirmStart)) return;
+    var body = new URLSearchParams();
+    body.append('user', currentUser);
+    body.append('version', currentVersion);
+    body.append('target_share', document.getElementById('target-share').value.trim());
+    body.append('target_dir', document.getElementById('target-dir').value.trim());
+    selectedItems.forEach(function(path) { body.append('paths', path); });
+    try {
+        var response = await fetch(baseURL + '/start', { method: 'POST', body: body });
+        var result = await response.json();
+        if (!response.ok || !result.success) throw new Error(result.message || 'Restore failed');
+        selectedItems.clear();
+        updateSelectionUI();
+        loadFiles(currentPath);
+        loadJobs();
+    } catch (error) { console.error('Restore error:', error); alert(t.restoreError + ': ' + error.message); }
+}
+
+async function loadJobs() {
+    try {
+        var response = await fetch(baseURL + '/jobs');
+        if (!response.ok) return;
+        var jobs = await response.json() || [];
+        renderJobs(jobs);
+        var running = jobs.some(function(j) { return j.status === 'running'; });
+        if (jobsTimer) { clearTimeout(jobsTimer); jobsTimer = null; }
+        if (running) jobsTimer = setTimeout(loadJobs, 2000);
+    } catch (error) { console.error('Error loading restore jobs:', error); }
+}
+
+function renderJobs(jobs) {
+    var card = document.getElementById('jobs-card');
+    var tbody = document.getElementById('jobs');
+    if (jobs.length === 0) { card.classList.add('hidden'); return; }
+    card.classList.remove('hidden');
+    tbody.innerHTML = '';
+    jobs.forEach(function(job) {
+        var row = document.createElement('tr');
+
+        var started = document.createElement('td');
+        started.style.cssText = 'font-size:0.8125rem;color:var(--text-secondary);';
+        started.textContent = new Date(job.started_at).toLocaleString();
+        row.appendChild(started);
+
+        var user = document.createElement('td');
+        user.style.fontSize = '0.8125rem';
+        user.textContent = job.username;
+        if (job.version) {
+            var version = document.createElement('div');
+            version.style.cssText = 'font-size:0.7rem;color:var(--text-muted);';
+            version.textContent = t.versionOf + ' ' + job.version;
+            user.appendChild(version);
+        }
+        row.appendChild(user);
+
+        var target = document.createElement('td');
+        target.style.cssText = 'font-size:0.75rem;color:var(--text-secondary);font-family:monospace;';
+        target.textContent = job.target_dir;
+        row.appendChild(target);
+
+        var progress = document.createElement('td');
+        var percent = job.total_bytes > 0 ? Math.floor(job.bytes * 100 / job.total_bytes) : (job.status === 'success' ? 100 : 0);
+        var bar = document.createElement('div');
+        bar.style.cssText = 'width:120px;height:6px;border-radius:3px;background:var(--bg-page);overflow:hidden;';
+        var fill = document.createElement('div');
+        fill.style.cssText = 'height:100%;border-radius:3px;background:var(--info);width:' + percent + '%;';
+        bar.appendChild(fill);
+        progress.appendChild(bar);
+        var counts = document.createElement('div');
+        counts.style.cssText = 'font-size:0.7rem;color:var(--text-muted);margin-top:0.25rem;';
+        counts.textContent = job.transfers + ' / ' + job.total_transfers + ' (' + formatBytes(job.bytes) + ' / ' + formatBytes(job.total_bytes) + ')';
+        progress.appendChild(counts);
+        row.appendChild(progress);
+
+        var status = document.createElement('td');
+        var badge = document.createElement('span');
+        var labels = { running: [t.jobRunning, 'v2-badge-info'], success: [t.jobSuccess, 'v2-badge-success'], error: [t.jobError, 'v2-badge-error'] };
+        var label = labels[job.status] || [job.status, ''];
+        badge.className = 'v2-badge ' + label[1];
+        badge.textContent = label[0];
+        if (job.error) badge.title = job.error;
+        status.appendChild(badge);
+        row.appendChild(status);
+
+        tbody.appendChild(row);
+    });
+}
+
+function formatBytes(bytes) {
+    if (!bytes) return '0 B';
+    var k = 1024;
+    var sizes = ['B', 'KB', 'MB', 'GB', 'TB'];
+    var i = Math.floor(Math.log(bytes) / Math.log(k));
+    return parseFloat((bytes / Math.pow(k, i)).toFixed(2)) + ' ' + sizes[i];
+}
@@ -20,7 +20,7 @@ var currentPath = '/';
 var fileTree = null;
 var selectedItems = new Set();
 
-document.addEventListener('DOMContentLoaded', async function() { await loadBackups(); });
+document.addEventListener('DOMContentLoaded', async function() { loadJobs(); await loadBackups(); });
 
 document.getElementById('backup-select').addEventListener('change', async function(e) {
     var value = e.target.value;
@@ -51,6 +51,9 @@ document.addEventListener('click', function(e) {
     if (action === 'selectAll') { selectAll(); }
     else if (action === 'clearSelection') { clearSelection(); }
     else if (action === 'downloadSelection') { downloadSelection(); }
+    else if (action === 'openRestorePanel') { openRestorePanel(); }
+    else if (action === 'closeRestorePanel') { document.getElementById('restore-panel').classList.add('hidden'); }
+    else if (action === 'startRestore') { startRestore(); }
 });
 
 // Event listener for select-all checkbox
@@ -282,3 +285,104 @@ async function downloadSelection() {
     paths.forEach(function(path) { var input = document.createElement('input'); input.type = 'hidden'; input.name = 'paths'; input.value = path; form.appendChild(input); });
     document.body.appendChild(form); form.submit(); document.body.removeChild(form);
 }
+
+// Server-side restore: decrypt the selection straight into one of the user's shares
+
+var jobsTimer = null;
+
+function openRestorePanel() {
+    if (selectedItems.size === 0) { alert(t.errorSelection); return; }
+    var select = document.getElementById('restore-target-share');
+    if (currentBackup && select) {
+        // Default to the share the backup came from
+        Array.from(select.options).forEach(function(opt) { if (opt.value === currentBackup.share_name) select.value = opt.value; });
+    }
+    document.getElementById('restore-panel').classList.remove('hidden');
+}
+
+async function startRestore() {
+    if (selectedItems.size === 0) { alert(t.errorSelection); return; }
+    if (!currentBackup) return;
+    var url = '/api/restore/jobs?peer_id=' + currentBackup.peer_id + '&backup=' + encodeURIComponent(currentBackup.share_name) + '&source_server=' + encodeURIComponent(currentBackup.source_server);
+    var body = new URLSearchParams();
+    selectedItems.forEach(function(path) { body.append('paths', path); });
+    body.append('target_share', document.getElementById('restore-target-share').value);
+    body.append('target_dir', document.getElementById('restore-target-dir').value);
+    body.append('conflict', document.getElementById('restore-conflict').value);
+    try {
+        var response = await fetch(url, { method: 'POST', body: body });
+        var result = await response.json();
+        if (!result.success) { alert(t.restoreError + ': ' + result.error); return; }
+        document.getElementById('restore-panel').classList.add('hidden');
+        clearSelection();
+        alert(t.restoreStarted);
+        loadJobs();
+    } catch (error) { console.error('Restore error:', error); alert(t.restoreError); }
+}
+
+async function loadJobs() {
+    try {
+        var response = await fetch('/api/restore/jobs');
+        if (!response.ok) return;
+        var jobs = await response.json() || [];
+        renderJobs(jobs);
+        var running = jobs.some(function(j) { return j.status === 'running'; });
+        if (jobsTimer) { clearTimeout(jobsTimer); jobsTimer = null; }
+        if (running) jobsTimer = setTimeout(loadJobs, 2000);
+    } catch (error) { console.error('Error loading restore jobs:', error); }
+}
+
+function renderJobs(jobs) {
+    var card = document.getElementById('restore-jobs-card');
+    var tbody = document.getElementById('restore-jobs');
+    if (!card || !tbody) return;
+    if (jobs.length === 0) { card.classList.add('hidden'); return; }
+    card.classList.remove('hidden');
+    tbody.innerHTML = '';
+    jobs.forEach(function(job) {
+        var row = document.createElement('tr');
+        var started = document.createElement('td');
+        started.style.cssText = 'font-size:0.8125rem;color:var(--text-secondary);';
+        started.textContent = formatDate(job.started_at);
+        row.appendChild(started);
+
+        var source = document.createElement('td');
+        source.style.fontSize = '0.8125rem';
+        source.textContent = job.peer_name + ' - ' + job.share_name;
+        row.appendChild(source);
+
+        var target = document.createElement('td');
+        target.style.fontSize = '0.8125rem';
+        target.textContent = job.target_share + (job.target_dir ? '/' + job.target_dir : '');
+        row.appendChild(target);
+
+        var progress = document.createElement('td');
+        var percent = job.total_bytes > 0 ? Math.floor(job.processed_bytes * 100 / job.total_bytes) : (job.total_files > 0 ? Math.floor(job.processed_files * 100 / job.total_files) : 0);
+        var bar = document.createElement('div');
+        bar.style.cssText = 'width:120px;height:6px;border-radius:3px;background:var(--bg-page);overflow:hidden;';
+        var fill = document.createElement('div');
+        fill.style.cssText = 'height:100%;border-radius:3px;background:var(--info);width:' + percent + '%;';
+        bar.appendChild(fill);
+        progress.appendChild(bar);
+        var counts = document.createElement('div');
+        counts.style.cssText = 'font-size:0.7rem;color:var(--text-muted);margin-top:0.25rem;';
+        counts.textContent = job.processed_files + ' / ' + job.total_files + ' (' + formatBytes(job.processed_bytes) + ')';
+        if (job.skipped_files > 0) counts.textContent += ' · ' + job.skipped_files + ' ' + t.jobSkipped;
+        if (job.failed_files > 0) counts.textContent += ' · ' + job.failed_files + ' ' + t.jobFailed;
+        progress.appendChild(counts);
+        row.appendChild(progress);
+
+        var status = document.createElement('td');
+        var badge = document.createElement('span');
+        var labels = { running: [t.jobRunning, 'v2-badge-info'], success: [t.jobSuccess, 'v2-badge-success'], partial: [t.jobPartial, 'v2-badge-warning'], error: [t.jobError, 'v2-badge-error'] };
+        var label = labels[job.status] || [job.status, ''];
+        badge.className = 'v2-badge ' + label[1];
+        badge.textContent = label[0];
+        status.appendChild(badge);
+        var details = (job.error_message ? [job.error_message] : []).concat(job.errors || []);
+        if (details.length > 0) badge.title = details.join('\n');
+        row.appendChild(status);
+
+        tbody.appendChild(row);
+    });
+}
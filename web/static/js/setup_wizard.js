@@ -15,6 +15,7 @@
     let zfsConfigConfirmed = false;
     let restoreFile = null;
     let restoreResult = null;
+    let restoreSource = 'file'; // 'file', 'usb' or 'peer'
 
     // Initialize
     document.addEventListener('DOMContentLoaded', function() {
@@ -342,6 +343,7 @@
             // Go to restore upload step
             hideAllSteps();
             document.getElementById('step-restore-upload').classList.remove('hidden');
+            scanUSBBackups();
             return;
         }
 
@@ -743,6 +745,169 @@
 
             // Store result and show confirm step
             restoreResult = result;
+            restoreSource = 'file';
+            showRestoreConfirm(result);
+
+        } catch (err) {
+            hideLoading();
+            document.getElementById('restore-error-text').textContent = err.message;
+            document.getElementById('restore-error').classList.remove('hidden');
+        }
+    }
+
+    // List Anemone backups found on mounted USB drives
+    async function scanUSBBackups() {
+        try {
+            const resp = await fetch('/setup/wizard/restore/usb/scan');
+            if (!resp.ok) return;
+            const drives = await resp.json() || [];
+            const select = document.getElementById('restore-usb-drive');
+            select.length = 0;
+            drives.forEach(d => {
+                const option = document.createElement('option');
+                option.value = d.path;
+                option.disabled = !d.has_config;
+                let label = `${d.label} — ${d.path}`;
+                if (!d.has_config) label += ` (${t.usb_no_config})`;
+                else if (d.protected) label += ` (${t.usb_protected})`;
+                else if (d.has_recovery_key) label += ` (${t.usb_recovery})`;
+                option.textContent = label;
+                select.appendChild(option);
+            });
+            document.getElementById('restore-usb-source').classList.toggle('hidden', drives.length === 0);
+        } catch (err) {
+            console.error('USB backup scan error:', err);
+        }
+    }
+
+    // Unlock a USB backup and load its configuration backup
+    async function validateUSBRestore() {
+        const path = document.getElementById('restore-usb-drive').value;
+        const key = document.getElementById('restore-usb-key').value.trim();
+        if (!path || !key) return;
+
+        showLoading(t.validating_backup || 'Validating backup...');
+        document.getElementById('restore-error').classList.add('hidden');
+
+        try {
+            const resp = await fetch('/setup/wizard/restore/usb/validate', {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ path: path, key: key })
+            });
+            if (!resp.ok) {
+                throw new Error(await resp.text());
+            }
+            const result = await resp.json();
+            hideLoading();
+
+            if (!result.valid) {
+                document.getElementById('restore-error-text').textContent =
+                    result.error === 'invalid_key' ? t.usb_invalid_key : t.usb_invalid_backup;
+                document.getElementById('restore-error').classList.remove('hidden');
+                return;
+            }
+
+            restoreResult = result;
+            restoreSource = 'usb';
+            showRestoreConfirm(result);
+
+        } catch (err) {
+            hideLoading();
+            document.getElementById('restore-error-text').textContent = err.message;
+            document.getElementById('restore-error').classList.remove('hidden');
+        }
+    }
+
+    // Read the peer fields of the restore step
+    function peerRequest() {
+        return {
+            address: document.getElementById('restore-peer-address').value.trim(),
+            port: parseInt(document.getElementById('restore-peer-port').value, 10) || 8443,
+            password: document.getElementById('restore-peer-password').value
+        };
+    }
+
+    // List the configuration backups stored on a peer
+    async function listPeerBackups() {
+        const req = peerRequest();
+        if (!req.address) return;
+
+        showLoading(t.validating_backup || 'Validating backup...');
+        document.getElementById('restore-error').classList.add('hidden');
+        document.getElementById('restore-peer-backups').classList.add('hidden');
+
+        try {
+            const resp = await fetch('/setup/wizard/restore/peer/list', {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify(req)
+            });
+            if (!resp.ok) {
+                throw new Error(await resp.text());
+            }
+            const result = await resp.json();
+            hideLoading();
+
+            const backups = result.backups || [];
+            if (result.error || backups.length === 0) {
+                document.getElementById('restore-error-text').textContent =
+                    result.error ? t[result.error] : t.peer_none;
+                document.getElementById('restore-error').classList.remove('hidden');
+                return;
+            }
+
+            const select = document.getElementById('restore-peer-backup');
+            select.length = 0;
+            backups.forEach(b => {
+                const option = document.createElement('option');
+                option.value = JSON.stringify({ source_server: b.source_server, filename: b.filename });
+                option.textContent = `${b.source_server} — ${new Date(b.created_at).toLocaleString()}`;
+                select.appendChild(option);
+            });
+            document.getElementById('restore-peer-backups').classList.remove('hidden');
+
+        } catch (err) {
+            hideLoading();
+            document.getElementById('restore-error-text').textContent = err.message;
+            document.getElementById('restore-error').classList.remove('hidden');
+        }
+    }
+
+    // Fetch a configuration backup from a peer and decrypt it with the recovery passphrase
+    async function validatePeerRestore() {
+        const selected = document.getElementById('restore-peer-backup').value;
+        const passphrase = document.getElementById('restore-peer-passphrase').value.trim();
+        if (!selected || !passphrase) return;
+
+        const req = Object.assign(peerRequest(), JSON.parse(selected), { passphrase: passphrase });
+
+        showLoading(t.validating_backup || 'Validating backup...');
+        document.getElementById('restore-error').classList.add('hidden');
+
+        try {
+            const resp = await fetch('/setup/wizard/restore/peer/validate', {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify(req)
+            });
+            if (!resp.ok) {
+                throw new Error(await resp.text());
+            }
+            const result = await resp.json();
+            hideLoading();
+
+            if (!result.valid) {
+                document.getElementById('restore-error-text').textContent =
+                    result.error === 'invalid_passphrase'
+                        ? (t.invalid_passphrase || 'Invalid passphrase. Please check your passphrase and try again.')
+                        : (t[result.error] || result.error || 'Failed to validate backup');
+                document.getElementById('restore-error').classList.remove('hidden');
+                return;
+            }
+
+            restoreResult = result;
+            restoreSource = 'peer';
             showRestoreConfirm(result);
 
         } catch (err) {
@@ -786,6 +951,24 @@
             peersContainer.innerHTML = '<p class="text-gray-500">No peers</p>';
         }
 
+        // Offer to pull user data back from a cloud destination
+        const cloudSelect = document.getElementById('restore-cloud');
+        cloudSelect.length = 1;
+        if (result.clouds && result.clouds.length > 0) {
+            result.clouds.forEach(c => {
+                const option = document.createElement('option');
+                option.value = c.id;
+                option.textContent = `${c.name} (${c.provider_type})`;
+                cloudSelect.appendChild(option);
+            });
+            document.getElementById('restore-cloud-section').classList.remove('hidden');
+        } else {
+            document.getElementById('restore-cloud-section').classList.add('hidden');
+        }
+
+        // Offer to restore share data from the USB drive the configuration came from
+        document.getElementById('restore-usb-section').classList.toggle('hidden', restoreSource !== 'usb');
+
         // Go to storage configuration step (same as new installation)
         // The user needs to choose where to store data before restoring
         currentStep = 1;
@@ -822,7 +1005,9 @@
                 body: JSON.stringify({
                     data_dir: storageResult.config?.data_dir || '/srv/anemone',
                     shares_dir: storageResult.config?.shares_dir || '/srv/anemone/shares',
-                    incoming_dir: storageResult.config?.incoming_dir || '/srv/anemone/backups/incoming'
+                    incoming_dir: storageResult.config?.incoming_dir || '/srv/anemone/backups/incoming',
+                    cloud_id: parseInt(document.getElementById('restore-cloud').value, 10) || 0,
+                    usb_data: restoreSource === 'usb' && document.getElementById('restore-usb-data').checked
                 })
             });
 
@@ -843,12 +1028,81 @@
             hideAllSteps();
             document.getElementById('step-restore-success').classList.remove('hidden');
 
+            if (parseInt(document.getElementById('restore-cloud').value, 10) > 0) {
+                document.getElementById('restore-cloud-progress').classList.remove('hidden');
+                if (result.cloud_jobs > 0) {
+                    pollCloudRestore();
+                } else {
+                    document.getElementById('restore-cloud-jobs').textContent = t.cloud_none_started;
+                }
+            }
+
+            if (restoreSource === 'usb' && document.getElementById('restore-usb-data').checked) {
+                document.getElementById('restore-usb-progress').classList.remove('hidden');
+                if (result.usb_jobs > 0) {
+                    pollUSBRestore();
+                } else {
+                    document.getElementById('restore-usb-jobs').textContent = t.usb_none_started;
+                }
+            }
+
         } catch (err) {
             hideLoading();
             alert((t.restore_failed || 'Restore failed') + ': ' + err.message);
         }
     }
 
+    // Poll the progress of user data restores from the cloud
+    async function pollCloudRestore() {
+        try {
+            const resp = await fetch('/setup/wizard/restore/cloud/status');
+            if (!resp.ok) return;
+            const jobs = await resp.json() || [];
+            const list = document.getElementById('restore-cloud-jobs');
+            list.innerHTML = '';
+            jobs.forEach(job => {
+                const item = document.createElement('li');
+                const percent = job.total_bytes > 0 ? Math.floor(job.bytes * 100 / job.total_bytes) : 0;
+                let state = `${t.cloud_running} ${percent}%`;
+                if (job.status === 'success') state = t.cloud_done;
+                if (job.status === 'error') state = `${t.cloud_failed}: ${job.error}`;
+                item.textContent = `${job.username} — ${state}`;
+                list.appendChild(item);
+            });
+            if (jobs.some(j => j.status === 'running')) {
+                setTimeout(pollCloudRestore, 3000);
+            }
+        } catch (err) {
+            console.error('Cloud restore status error:', err);
+        }
+    }
+
+    // Poll the progress of share data restores from the USB drive
+    async function pollUSBRestore() {
+        try {
+            const resp = await fetch('/setup/wizard/restore/usb/status');
+            if (!resp.ok) return;
+            const jobs = await resp.json() || [];
+            const list = document.getElementById('restore-usb-jobs');
+            list.innerHTML = '';
+            jobs.forEach(job => {
+                const item = document.createElement('li');
+                const percent = job.total_files > 0 ? Math.floor(job.processed_files * 100 / job.total_files) : 0;
+                let state = `${t.cloud_running} ${percent}%`;
+                if (job.status === 'success' || job.status === 'partial') state = t.cloud_done;
+                if (job.status === 'partial') state += ` (${job.failed_files} ${t.cloud_failed})`;
+                if (job.status === 'error') state = `${t.cloud_failed}: ${job.error}`;
+                item.textContent = `${job.share_name} — ${state}`;
+                list.appendChild(item);
+            });
+            if (jobs.some(j => j.status === 'running')) {
+                setTimeout(pollUSBRestore, 3000);
+            }
+        } catch (err) {
+            console.error('USB restore status error:', err);
+        }
+    }
+
     // =============================================
     // NAVIGATION HELPERS FOR RESTORE FLOW
     // =============================================
@@ -869,7 +1123,12 @@
         currentStep = 0;
         restoreFile = null;
         restoreResult = null;
+        restoreSource = 'file';
         document.getElementById('restore-file').value = '';
+        document.getElementById('restore-usb-key').value = '';
+        document.getElementById('restore-peer-password').value = '';
+        document.getElementById('restore-peer-passphrase').value = '';
+        document.getElementById('restore-peer-backups').classList.add('hidden');
         document.getElementById('restore-passphrase').value = '';
         document.getElementById('selected-file-name').classList.add('hidden');
         document.getElementById('restore-error').classList.add('hidden');
@@ -946,6 +1205,15 @@
             case 'validateRestore':
                 validateRestore();
                 break;
+            case 'validateUSBRestore':
+                validateUSBRestore();
+                break;
+            case 'listPeerBackups':
+                listPeerBackups();
+                break;
+            case 'validatePeerRestore':
+                validatePeerRestore();
+                break;
             case 'executeRestore':
                 executeRestore();
                 break;
@@ -3,6 +3,7 @@
 var pageData = JSON.parse(document.getElementById('page-data').textContent || '{}');
 var t = pageData.translations || {};
 var poolNames = (pageData.poolNames || '').split(',').filter(function(p) { return p; });
+var btrfsAvailable = pageData.btrfsAvailable === true;
 
 var verificationToken = null;
 var pendingAction = null;
@@ -15,8 +16,12 @@ function showTab(tabName) {
     if (panel) panel.classList.add('active');
     var tab = document.querySelector('.v2-tab[data-tab="' + tabName + '"]');
     if (tab) tab.classList.add('active');
+    if (tabName === 'overview') loadEncryptedVolumes();
+    if (tabName === 'disks') { loadSMARTAlerts(); loadSMARTSettings(); }
+    if (tabName === 'pools' || tabName === 'raid') loadPoolAlerts();
     if (tabName === 'datasets') loadDatasets();
     if (tabName === 'snapshots') loadSnapshots();
+    if (tabName === 'replication') { loadReplications(); loadReplicas(); loadReplicationDatasets(); }
 }
 
 /* Password verification */
@@ -113,6 +118,9 @@ function showSMARTDetails(diskName) {
         var healthText = data.healthy ? t.smartStatusGood : t.smartStatusCritical;
         html += '<div style="display:flex;align-items:center;gap:0.5rem;padding:0.75rem;border-radius:8px;margin-bottom:1rem;' + healthClass + '">';
         html += '<span style="font-weight:500;">' + t.smartHealth + ': ' + healthText + '</span>' + helpIcon(t.smartHelpHealth) + '</div>';
+        if (data.self_test_running) {
+            html += '<div style="padding:0.5rem 0.75rem;border-radius:8px;margin-bottom:1rem;background:rgba(59,130,246,0.1);border:1px solid var(--accent);font-size:0.8125rem;">' + t.smartSelfTestInProgress.replace('{percent}', data.self_test_remaining) + '</div>';
+        }
 
         html += '<div class="smart-card"><div class="smart-card-title">' + t.smartGeneralInfo + '</div>';
         var tempClass = data.temperature > 60 ? 'smart-value-critical' : (data.temperature > 50 ? 'smart-value-warning' : 'smart-value-good');
@@ -143,6 +151,7 @@ function showSMARTDetails(diskName) {
             html += smartMetricRow(t.smartReallocated, data.reallocated_sectors, t.smartHelpReallocated, getValueClass(data.reallocated_sectors, 10, 100, false));
             html += smartMetricRow(t.smartPending, data.pending_sectors, t.smartHelpPending, data.pending_sectors > 0 ? 'smart-value-critical' : 'smart-value-good');
             html += smartMetricRow(t.smartUncorrectable, data.uncorrectable_sectors, t.smartHelpUncorrectable, data.uncorrectable_sectors > 0 ? 'smart-value-critical' : 'smart-value-good');
+            html += smartMetricRow(t.smartCRCErrors, data.crc_errors, t.smartHelpCRCErrors, data.crc_errors > 0 ? 'smart-value-warning' : 'smart-value-good');
             html += '</div>';
         }
 
@@ -156,7 +165,9 @@ function showSMARTDetails(diskName) {
             });
             html += '</tbody></table></div></details>';
         }
+        html += '<div id="smartHistory"></div>';
         document.getElementById('smartContent').innerHTML = html;
+        loadSMARTHistory(diskName, smartRange);
     }).catch(function(err) {
         document.getElementById('smartContent').innerHTML = '<div style="text-align:center;color:var(--error);padding:2rem;">' + t.error + ': ' + err + '</div>';
     });
@@ -164,6 +175,209 @@ function showSMARTDetails(diskName) {
 
 function closeSMARTModal() { document.getElementById('smartModal').classList.add('hidden'); }
 
+/* SMART history and self-tests */
+var smartDisk = null;
+var smartRange = 30;
+
+function smartChart(title, samples, series) {
+    var w = 600, h = 120, pad = 4;
+    var max = 1;
+    samples.forEach(function(s) {
+        series.forEach(function(se) { if (s[se.key] > max) max = s[se.key]; });
+    });
+    var t0 = new Date(samples[0].time).getTime();
+    var t1 = new Date(samples[samples.length - 1].time).getTime();
+    var span = Math.max(t1 - t0, 1);
+    var html = '<div class="smart-card"><div class="smart-card-title">' + title + '</div>';
+    html += '<svg viewBox="0 0 ' + w + ' ' + h + '" preserveAspectRatio="none" style="width:100%;height:7rem;display:block;">';
+    html += '<line x1="0" y1="' + (h - pad) + '" x2="' + w + '" y2="' + (h - pad) + '" stroke="var(--border)" stroke-width="1"/>';
+    series.forEach(function(se) {
+        var points = samples.filter(function(s) { return s[se.key] >= 0; }).map(function(s) {
+            var x = (new Date(s.time).getTime() - t0) * w / span;
+            var y = h - pad - s[se.key] * (h - 2 * pad) / max;
+            return x.toFixed(1) + ',' + y.toFixed(1);
+        });
+        if (points.length === 1) points.push(w + ',' + points[0].split(',')[1]);
+        if (points.length > 0) html += '<polyline fill="none" stroke="' + se.color + '" stroke-width="2" vector-effect="non-scaling-stroke" points="' + points.join(' ') + '"/>';
+    });
+    html += '</svg>';
+    html += '<div style="display:flex;justify-content:space-between;flex-wrap:wrap;gap:0.5rem;font-size:0.6875rem;color:var(--text-muted);margin-top:0.25rem;">';
+    html += '<span>' + new Date(t0).toLocaleDateString() + '</span><span style="display:flex;gap:0.75rem;">';
+    series.forEach(function(se) {
+        var last = samples[samples.length - 1][se.key];
+        html += '<span><span style="display:inline-block;width:8px;height:8px;border-radius:2px;background:' + se.color + ';margin-right:4px;"></span>' + se.label + ': ' + (last >= 0 ? last : '-') + '</span>';
+    });
+    html += '</span><span>' + new Date(t1).toLocaleDateString() + ' (max ' + max + ')</span></div></div>';
+    return html;
+}
+
+function loadSMARTHistory(diskName, days) {
+    smartDisk = diskName;
+    smartRange = days;
+    var container = document.getElementById('smartHistory');
+    if (!container) return;
+    fetch('/api/admin/storage/smart/history?disk=' + encodeURIComponent(diskName) + '&days=' + days)
+    .then(function(resp) { return resp.json(); })
+    .then(function(data) {
+        if (data.error) { container.innerHTML = '<div style="color:var(--error);font-size:0.8125rem;">' + t.error + ': ' + escapeHtml(data.error) + '</div>'; return; }
+        var html = '<div style="display:flex;justify-content:space-between;align-items:center;margin:1rem 0 0.5rem;">';
+        html += '<div style="font-size:0.875rem;font-weight:600;color:var(--text-primary);">' + t.smartHistory + '</div><div style="display:flex;gap:0.25rem;">';
+        [7, 30, 90].forEach(function(d) {
+            html += '<button data-action="smartHistoryRange" data-days="' + d + '" class="v2-btn v2-btn-sm ' + (d === days ? 'v2-btn-primary' : 'v2-btn-secondary') + '">' + t.smartRangeDays.replace('{days}', d) + '</button>';
+        });
+        html += '</div></div>';
+
+        var samples = data.samples || [];
+        if (samples.length === 0) {
+            html += '<div style="text-align:center;color:var(--text-muted);font-size:0.8125rem;padding:1rem;">' + t.smartHistoryEmpty + '</div>';
+        } else {
+            html += smartChart(t.smartChartTemp, samples, [{key: 'temperature', label: t.smartTemp, color: 'var(--warning)'}]);
+            html += smartChart(t.smartChartErrors, samples, [
+                {key: 'reallocated_sectors', label: t.smartReallocated, color: 'var(--error)'},
+                {key: 'pending_sectors', label: t.smartPending, color: 'var(--warning)'},
+                {key: 'crc_errors', label: t.smartCRCErrors, color: 'var(--accent)'}
+            ]);
+            if (samples.some(function(s) { return s.wear_level >= 0; })) {
+                html += smartChart(t.smartChartWear, samples, [{key: 'wear_level', label: t.smartChartWear, color: 'var(--accent)'}]);
+            }
+        }
+
+        html += '<div class="smart-card"><div style="display:flex;justify-content:space-between;align-items:center;margin-bottom:8px;">';
+        html += '<div class="smart-card-title" style="margin-bottom:0;">' + t.smartSelfTests + '</div><div style="display:flex;gap:0.25rem;">';
+        html += '<button data-action="startSMARTSelfTest" data-type="short" class="v2-btn v2-btn-secondary v2-btn-sm">' + t.smartSelfTestShort + '</button>';
+        html += '<button data-action="startSMARTSelfTest" data-type="long" class="v2-btn v2-btn-secondary v2-btn-sm">' + t.smartSelfTestLong + '</button></div></div>';
+        var tests = data.selftests || [];
+        if (tests.length === 0) {
+            html += '<div style="font-size:0.8125rem;color:var(--text-muted);">' + t.smartSelfTestNone + '</div>';
+        }
+        tests.forEach(function(st) {
+            var badge = st.status === 'passed' ? 'v2-badge-success' : (st.status === 'failed' ? 'v2-badge-error' : 'v2-badge-info');
+            var label = st.status === 'passed' ? t.smartSelfTestPassed : (st.status === 'failed' ? t.smartSelfTestFailed : t.smartSelfTestRunning);
+            html += '<div style="display:flex;justify-content:space-between;align-items:center;font-size:0.8125rem;padding:0.25rem 0;border-bottom:1px solid var(--border);">';
+            html += '<span>' + (st.type === 'long' ? t.smartSelfTestLong : t.smartSelfTestShort) + (st.scheduled ? ' <span style="color:var(--text-muted);">(' + t.smartSelfTestScheduled + ')</span>' : '') + '</span>';
+            html += '<span style="display:flex;align-items:center;gap:0.5rem;"><span style="color:var(--text-muted);">' + new Date(st.started_at).toLocaleString() + '</span>';
+            html += '<span class="v2-badge ' + badge + '" title="' + escapeHtml(st.result || '') + '">' + label + '</span></span></div>';
+        });
+        html += '</div>';
+        container.innerHTML = html;
+    })
+    .catch(function(err) {
+        container.innerHTML = '<div style="color:var(--error);font-size:0.8125rem;">' + t.error + ': ' + err + '</div>';
+    });
+}
+
+function startSMARTSelfTest(type) {
+    var label = type === 'long' ? t.smartSelfTestLong : t.smartSelfTestShort;
+    if (!smartDisk || !confirm(t.smartSelfTestConfirm.replace('{type}', label.toLowerCase()))) return;
+    fetch('/api/admin/storage/smart/selftest', {
+        method: 'POST',
+        headers: {'Content-Type': 'application/json'},
+        body: JSON.stringify({disk: smartDisk, type: type})
+    })
+    .then(function(resp) { return resp.json(); })
+    .then(function(data) {
+        if (data.success) { alert(t.smartSelfTestStarted); loadSMARTHistory(smartDisk, smartRange); }
+        else alert(t.error + ': ' + data.error);
+    })
+    .catch(function(err) { alert(t.error + ': ' + err); });
+}
+
+/* SMART alerts and monitor settings */
+function smartAlertMessage(a) {
+    var labels = {
+        reallocated_sectors: t.smartReallocated,
+        pending_sectors: t.smartPending,
+        uncorrectable_sectors: t.smartUncorrectable,
+        crc_errors: t.smartCRCErrors,
+        media_errors: t.smartMediaErrors,
+        short: t.smartSelfTestShort,
+        long: t.smartSelfTestLong
+    };
+    var templates = {
+        health: t.smartAlertHealth,
+        attribute: t.smartAlertAttribute,
+        temperature: t.smartAlertTemperature,
+        wear: t.smartAlertWear,
+        selftest: t.smartAlertSelftest
+    };
+    return (templates[a.kind] || a.kind)
+        .replace('{attribute}', labels[a.attribute] || a.attribute)
+        .replace('{old}', a.old_value)
+        .replace('{new}', a.new_value);
+}
+
+function loadSMARTAlerts() {
+    var container = document.getElementById('smart-alerts-list');
+    if (!container) return;
+    fetch('/api/admin/alerts?source=smart')
+    .then(function(resp) { return resp.json(); })
+    .then(function(alerts) {
+        if (!alerts || alerts.length === 0) {
+            container.innerHTML = '<div style="text-align:center;color:var(--text-muted);padding:1rem;">' + t.smartNoAlerts + '</div>';
+            return;
+        }
+        var html = '<div style="overflow-x:auto;max-height:16rem;overflow-y:auto;"><table class="v2-table"><tbody>';
+        alerts.forEach(function(a) {
+            var badge = a.severity === 'critical' ? 'v2-badge-error' : 'v2-badge-warning';
+            html += '<tr' + (a.acknowledged ? ' style="opacity:0.6;"' : '') + '><td><span class="v2-badge ' + badge + '">' + escapeHtml(a.severity) + '</span></td>';
+            html += '<td><div style="font-weight:500;color:var(--text-primary);">' + escapeHtml(a.label || a.device) + '</div><div style="font-size:0.6875rem;color:var(--text-muted);">' + escapeHtml(a.device) + ' - ' + escapeHtml(a.subject) + '</div></td>';
+            html += '<td>' + escapeHtml(smartAlertMessage(a)) + '</td>';
+            html += '<td style="white-space:nowrap;color:var(--text-muted);">' + new Date(a.created_at).toLocaleString() + '</td>';
+            html += '<td>' + (a.acknowledged ? '<span style="color:var(--text-muted);font-size:0.75rem;">' + t.smartAcknowledged + '</span>' : '<button data-action="acknowledgeSMARTAlert" data-id="' + a.id + '" class="v2-btn v2-btn-secondary v2-btn-sm">' + t.smartAck + '</button>') + '</td></tr>';
+        });
+        html += '</tbody></table></div>';
+        container.innerHTML = html;
+    })
+    .catch(function(err) {
+        container.innerHTML = '<div style="text-align:center;color:var(--error);padding:1rem;">' + t.error + '</div>';
+    });
+}
+
+function acknowledgeSMARTAlert(id) {
+    fetch('/api/admin/alerts?' + (id ? 'id=' + encodeURIComponent(id) : 'source=smart'), {method: 'POST'})
+    .then(function(resp) { return resp.json(); })
+    .then(function(data) {
+        if (data.success) loadSMARTAlerts();
+        else alert(t.error + ': ' + data.error);
+    })
+    .catch(function(err) { alert(t.error + ': ' + err); });
+}
+
+function loadSMARTSettings() {
+    if (!document.getElementById('smartSettingsForm')) return;
+    fetch('/api/admin/storage/smart/settings')
+    .then(function(resp) { return resp.json(); })
+    .then(function(s) {
+        document.getElementById('smartInterval').value = s.interval_minutes;
+        document.getElementById('smartShortDays').value = s.short_test_days;
+        document.getElementById('smartLongDays').value = s.long_test_days;
+        document.getElementById('smartTestHour').value = s.test_hour;
+        document.getElementById('smartTempWarning').value = s.temp_warning;
+    });
+}
+
+function saveSMARTSettings(e) {
+    e.preventDefault();
+    var settings = {
+        interval_minutes: parseInt(document.getElementById('smartInterval').value, 10) || 0,
+        short_test_days: parseInt(document.getElementById('smartShortDays').value, 10) || 0,
+        long_test_days: parseInt(document.getElementById('smartLongDays').value, 10) || 0,
+        test_hour: parseInt(document.getElementById('smartTestHour').value, 10) || 0,
+        temp_warning: parseInt(document.getElementById('smartTempWarning').value, 10) || 0
+    };
+    fetch('/api/admin/storage/smart/settings', {
+        method: 'PUT',
+        headers: {'Content-Type': 'application/json'},
+        body: JSON.stringify(settings)
+    })
+    .then(function(resp) { return resp.json(); })
+    .then(function(data) {
+        if (data.success) alert(t.smartSettingsSaved);
+        else alert(t.error + ': ' + data.error);
+    })
+    .catch(function(err) { alert(t.error + ': ' + err); });
+}
+
 /* Pool operations */
 function startScrub(poolName) {
     if (!confirm(t.confirmScrub)) return;
@@ -175,8 +389,603 @@ function startScrub(poolName) {
     }).catch(function(err) { alert(t.scrubError + ': ' + err); });
 }
 
+/* Software RAID (mdadm) */
+var mdAction = null;
+
+function showCreateMDModal() {
+    var used = Array.from(document.querySelectorAll('[data-md-member]')).map(function(el) { return el.getAttribute('data-md-member'); });
+    var n = 0;
+    while (used.indexOf('md' + n) !== -1) n++;
+    document.getElementById('mdName').value = 'md' + n;
+    fetch('/api/admin/storage/disks/available')
+    .then(function(resp) { return resp.json(); })
+    .then(function(disks) {
+        var html = '';
+        (disks || []).forEach(function(disk) {
+            if (disk.in_use) return;
+            html += '<div style="display:flex;align-items:center;justify-content:space-between;gap:0.5rem;padding:0.25rem 0;font-size:0.8125rem;color:var(--text-primary);"><span>' + escapeHtml(disk.path) + ' - ' + escapeHtml(disk.model || '') + ' (' + escapeHtml(disk.size_human) + ')</span>';
+            html += '<select name="mdDiskRole" data-path="' + escapeHtml(disk.path) + '" style="padding:0.125rem 0.25rem;border:1px solid var(--border);border-radius:4px;background:var(--bg-card);color:var(--text-primary);font-size:0.75rem;"><option value="">' + t.mdUnused + '</option><option value="member">' + t.mdMember + '</option><option value="spare">' + t.mdSpare + '</option></select></div>';
+        });
+        document.getElementById('mdDisksList').innerHTML = html || '<div style="text-align:center;color:var(--text-muted);padding:1rem;font-size:0.8125rem;">' + t.noAvailableDisks + '</div>';
+    })
+    .catch(function(err) {
+        document.getElementById('mdDisksList').innerHTML = '<div style="text-align:center;color:var(--error);padding:1rem;font-size:0.8125rem;">' + t.error + '</div>';
+    });
+    document.getElementById('createMDModal').classList.remove('hidden');
+}
+function closeCreateMDModal() { document.getElementById('createMDModal').classList.add('hidden'); }
+
+function createMDArray(e) {
+    e.preventDefault();
+    var req = {
+        name: document.getElementById('mdName').value,
+        level: document.getElementById('mdLevel').value,
+        filesystem: document.getElementById('mdFilesystem').value,
+        disks: [],
+        spares: []
+    };
+    document.querySelectorAll('select[name="mdDiskRole"]').forEach(function(el) {
+        if (el.value === 'member') req.disks.push(el.getAttribute('data-path'));
+        if (el.value === 'spare') req.spares.push(el.getAttribute('data-path'));
+    });
+    if (req.disks.length === 0) { alert(t.selectAtLeastOneDisk); return; }
+    closeCreateMDModal();
+    requirePassword(t.mdCreate + ': ' + req.name, t.mdCreateWarning, function() {
+        fetch('/api/admin/storage/md-arrays', {
+            method: 'POST',
+            headers: {'Content-Type': 'application/json', 'X-Verification-Token': verificationToken},
+            body: JSON.stringify(req)
+        })
+        .then(function(resp) { return resp.json(); })
+        .then(function(data) {
+            if (data.success) { alert(t.mdCreated); location.reload(); }
+            else alert(t.error + ': ' + data.error);
+        })
+        .catch(function(err) { alert(t.error + ': ' + err); });
+    });
+}
+
+function sendMDDiskAction(req) {
+    fetch('/api/admin/storage/md-array-disk/' + encodeURIComponent(mdAction.array), {
+        method: 'POST',
+        headers: {'Content-Type': 'application/json', 'X-Verification-Token': verificationToken},
+        body: JSON.stringify(req)
+    })
+    .then(function(resp) { return resp.json(); })
+    .then(function(data) {
+        if (data.success) { alert(t.mdDone); location.reload(); }
+        else alert(t.error + ': ' + data.error);
+    })
+    .catch(function(err) { alert(t.error + ': ' + err); });
+}
+
+function mdDiskAction(array, disk, action) {
+    mdAction = {array: array, disk: disk, action: action};
+    var title = action === 'fail' ? t.mdFailConfirm : t.mdRemoveConfirm;
+    var warning = action === 'fail' ? t.mdFailWarning : t.mdRemoveWarning;
+    requirePassword(title + ': ' + disk + ' (' + array + ')', warning, function() {
+        sendMDDiskAction({action: action, disk: disk});
+    });
+}
+
+function showMDDiskModal(array, disk, action) {
+    mdAction = {array: array, disk: disk, action: action};
+    document.getElementById('mdDiskTitle').textContent = (action === 'add' ? t.mdAddDisk : t.replaceConfirm + ' ' + disk) + ' - ' + array;
+    document.getElementById('mdDiskHelp').textContent = action === 'add' ? t.mdAddHelp : t.mdReplaceHelp;
+    var select = document.getElementById('mdNewDisk');
+    select.innerHTML = '';
+    fetch('/api/admin/storage/disks/available')
+    .then(function(resp) { return resp.json(); })
+    .then(function(disks) {
+        var html = '<option value="">' + t.replaceSelectNew + '</option>';
+        (disks || []).forEach(function(d) {
+            if (d.in_use) return;
+            html += '<option value="' + escapeHtml(d.path) + '">' + escapeHtml(d.path) + ' - ' + escapeHtml(d.model || '') + ' (' + escapeHtml(d.size_human) + ')</option>';
+        });
+        select.innerHTML = html;
+    })
+    .catch(function(err) { select.innerHTML = '<option value="">' + t.error + '</option>'; });
+    document.getElementById('mdDiskModal').classList.remove('hidden');
+}
+function closeMDDiskModal() { document.getElementById('mdDiskModal').classList.add('hidden'); }
+
+function submitMDDisk(e) {
+    e.preventDefault();
+    var newDisk = document.getElementById('mdNewDisk').value;
+    if (!newDisk) { alert(t.replaceSelectNew); return; }
+    closeMDDiskModal();
+    requirePassword(document.getElementById('mdDiskTitle').textContent, t.mdDiskWarning, function() {
+        if (mdAction.action === 'add') sendMDDiskAction({action: 'add', disk: newDisk});
+        else sendMDDiskAction({action: 'replace', disk: mdAction.disk, new_disk: newDisk});
+    });
+}
+
+function mdCheck(array, stop) {
+    if (!stop && !confirm(t.mdCheckConfirm)) return;
+    fetch('/api/admin/storage/md-array-check/' + encodeURIComponent(array) + (stop ? '?stop=1' : ''), {method: 'POST'})
+    .then(function(resp) { return resp.json(); })
+    .then(function(data) {
+        if (data.success) { if (!stop) alert(t.mdCheckStarted); location.reload(); }
+        else alert(t.error + ': ' + data.error);
+    })
+    .catch(function(err) { alert(t.error + ': ' + err); });
+}
+
+/* Pool alerts */
+function poolAlertMessage(a) {
+    var templates = {
+        pool_state: t.poolAlertPoolState,
+        device_state: t.poolAlertDeviceState,
+        errors: t.poolAlertErrors,
+        scrub_errors: t.poolAlertScrubErrors
+    };
+    return (templates[a.kind] || a.kind)
+        .replace('{device}', a.device)
+        .replace('{attribute}', a.attribute)
+        .replace('{old}', a.old_value)
+        .replace('{new}', a.new_value);
+}
+
+function loadPoolAlerts() {
+    var containers = document.querySelectorAll('[data-pool-alerts]');
+    if (containers.length === 0) return;
+    var render = function(html) { containers.forEach(function(el) { el.innerHTML = html; }); };
+    fetch('/api/admin/alerts?source=pool')
+    .then(function(resp) { return resp.json(); })
+    .then(function(alerts) {
+        if (!alerts || alerts.length === 0) {
+            render('<div style="text-align:center;color:var(--text-muted);padding:1rem;">' + t.poolNoAlerts + '</div>');
+            return;
+        }
+        var html = '<div style="overflow-x:auto;max-height:16rem;overflow-y:auto;"><table class="v2-table"><tbody>';
+        alerts.forEach(function(a) {
+            var badge = a.severity === 'critical' ? 'v2-badge-error' : (a.severity === 'warning' ? 'v2-badge-warning' : 'v2-badge-success');
+            html += '<tr' + (a.acknowledged ? ' style="opacity:0.6;"' : '') + '><td><span class="v2-badge ' + badge + '">' + escapeHtml(a.severity) + '</span></td>';
+            html += '<td style="font-weight:500;color:var(--text-primary);">' + escapeHtml(a.subject) + '</td>';
+            html += '<td>' + escapeHtml(poolAlertMessage(a)) + '</td>';
+            html += '<td style="white-space:nowrap;color:var(--text-muted);">' + new Date(a.created_at).toLocaleString() + '</td>';
+            html += '<td>' + (a.acknowledged ? '<span style="color:var(--text-muted);font-size:0.75rem;">' + t.smartAcknowledged + '</span>' : '<button data-action="acknowledgePoolAlert" data-id="' + a.id + '" class="v2-btn v2-btn-secondary v2-btn-sm">' + t.smartAck + '</button>') + '</td></tr>';
+        });
+        html += '</tbody></table></div>';
+        render(html);
+    })
+    .catch(function(err) {
+        render('<div style="text-align:center;color:var(--error);padding:1rem;">' + t.error + '</div>');
+    });
+}
+
+function acknowledgePoolAlert(id) {
+    fetch('/api/admin/alerts?' + (id ? 'id=' + encodeURIComponent(id) : 'source=pool'), {method: 'POST'})
+    .then(function(resp) { return resp.json(); })
+    .then(function(data) {
+        if (data.success) loadPoolAlerts();
+        else alert(t.error + ': ' + data.error);
+    })
+    .catch(function(err) { alert(t.error + ': ' + err); });
+}
+
+/* Scrub schedules and history */
+var scrubPool = null;
+
+function formatDuration(seconds) {
+    var h = Math.floor(seconds / 3600);
+    var m = Math.floor((seconds % 3600) / 60);
+    return h > 0 ? h + 'h ' + m + 'm' : m + 'm ' + (seconds % 60) + 's';
+}
+
+function scanSummary(scan) {
+    if (!scan || scan.state !== 'running' && scan.state !== 'paused') return '';
+    var fn = scan.function === 'resilver' ? t.poolResilver : t.poolScrub;
+    return fn + ' - ' + t.poolScanRunning.replace('{progress}', scan.progress.toFixed(1)) + (scan.remaining ? ' (' + escapeHtml(scan.remaining) + ')' : '');
+}
+
+function showScrubModal(poolName) {
+    scrubPool = poolName;
+    document.getElementById('scrubPoolName').textContent = poolName;
+    document.getElementById('scrubInterval').value = 30;
+    document.getElementById('scrubHour').value = 2;
+    document.getElementById('scrubEnabled').checked = true;
+    document.getElementById('scrubDeleteBtn').classList.add('hidden');
+    document.getElementById('scrubHistory').innerHTML = '<div style="text-align:center;color:var(--text-muted);padding:1rem;">' + t.loading + '</div>';
+    document.getElementById('scrubModal').classList.remove('hidden');
+
+    fetch('/api/admin/storage/scrub-schedules')
+    .then(function(resp) { return resp.json(); })
+    .then(function(schedules) {
+        var s = (schedules || []).filter(function(x) { return x.pool === poolName; })[0];
+        if (!s) return;
+        document.getElementById('scrubInterval').value = s.interval_days;
+        document.getElementById('scrubHour').value = s.hour;
+        document.getElementById('scrubEnabled').checked = s.enabled;
+        document.getElementById('scrubDeleteBtn').classList.remove('hidden');
+    });
+    loadScrubHistory(poolName);
+}
+function closeScrubModal() { document.getElementById('scrubModal').classList.add('hidden'); }
+
+function loadScrubHistory(poolName) {
+    var container = document.getElementById('scrubHistory');
+    fetch('/api/admin/storage/scrub-history?pool=' + encodeURIComponent(poolName))
+    .then(function(resp) { return resp.json(); })
+    .then(function(data) {
+        if (data.error) { container.innerHTML = '<div style="text-align:center;color:var(--error);padding:1rem;">' + escapeHtml(data.error) + '</div>'; return; }
+        var html = '';
+        var running = scanSummary(data.scan);
+        if (running) html += '<div style="font-size:0.8125rem;color:var(--text-primary);margin-bottom:0.5rem;">' + running + '</div>';
+        if (!data.history || data.history.length === 0) {
+            html += '<div style="text-align:center;color:var(--text-muted);padding:1rem;">' + t.poolHistoryNone + '</div>';
+            container.innerHTML = html;
+            return;
+        }
+        html += '<div style="overflow-x:auto;"><table class="v2-table"><tbody>';
+        data.history.forEach(function(h) {
+            html += '<tr><td>' + (h.function === 'resilver' ? t.poolResilver : t.poolScrub) + '</td>';
+            html += '<td><span class="v2-badge ' + (h.state === 'finished' ? 'v2-badge-success' : 'v2-badge-warning') + '">' + (h.state === 'finished' ? t.poolScanFinished : t.poolScanCanceled) + '</span></td>';
+            html += '<td style="white-space:nowrap;color:var(--text-muted);">' + new Date(h.finished_at).toLocaleString() + '</td>';
+            html += '<td>' + t.poolDuration + ': ' + formatDuration(h.duration_seconds) + '</td>';
+            html += '<td>' + t.poolRepaired + ': ' + formatSize(h.bytes_repaired) + '</td>';
+            html += '<td style="' + (h.errors > 0 ? 'color:var(--error);' : '') + '">' + t.poolScanErrors + ': ' + h.errors + '</td></tr>';
+        });
+        html += '</tbody></table></div>';
+        container.innerHTML = html;
+    })
+    .catch(function(err) {
+        container.innerHTML = '<div style="text-align:center;color:var(--error);padding:1rem;">' + t.error + '</div>';
+    });
+}
+
+function saveScrubSchedule(e) {
+    e.preventDefault();
+    var schedule = {
+        pool: scrubPool,
+        interval_days: parseInt(document.getElementById('scrubInterval').value, 10) || 0,
+        hour: parseInt(document.getElementById('scrubHour').value, 10) || 0,
+        enabled: document.getElementById('scrubEnabled').checked
+    };
+    fetch('/api/admin/storage/scrub-schedules', {
+        method: 'PUT',
+        headers: {'Content-Type': 'application/json'},
+        body: JSON.stringify(schedule)
+    })
+    .then(function(resp) { return resp.json(); })
+    .then(function(data) {
+        if (data.success) { alert(t.poolScheduleSaved); closeScrubModal(); }
+        else alert(t.error + ': ' + data.error);
+    })
+    .catch(function(err) { alert(t.error + ': ' + err); });
+}
+
+function deleteScrubSchedule() {
+    fetch('/api/admin/storage/scrub-schedules?pool=' + encodeURIComponent(scrubPool), {method: 'DELETE'})
+    .then(function(resp) { return resp.json(); })
+    .then(function(data) {
+        if (data.success) { alert(t.poolScheduleNone); closeScrubModal(); }
+        else alert(t.error + ': ' + data.error);
+    })
+    .catch(function(err) { alert(t.error + ': ' + err); });
+}
+
+/* Disk replacement wizard */
+var replacePool = null;
+var replaceTimer = null;
+
+function replaceDiskStep(step) {
+    [1, 2, 3].forEach(function(n) {
+        document.getElementById('replaceStep' + n).classList.toggle('hidden', n !== step);
+    });
+    if (step === 2) loadReplacementDisks();
+}
+
+function showReplaceDiskModal(poolName, diskName) {
+    replacePool = poolName;
+    document.getElementById('replacePoolName').textContent = poolName;
+    var html = '';
+    document.querySelectorAll('[data-pool-disk]').forEach(function(el) {
+        if (el.getAttribute('data-pool-disk') !== poolName) return;
+        var name = el.getAttribute('data-name');
+        var state = el.getAttribute('data-state');
+        var selected = diskName ? name === diskName : state !== 'ONLINE';
+        html += '<option value="' + escapeHtml(name) + '"' + (selected ? ' selected' : '') + '>' + escapeHtml(name) + ' (' + escapeHtml(state) + ')</option>';
+    });
+    document.getElementById('replaceOldDisk').innerHTML = html;
+    document.getElementById('replaceForce').checked = false;
+    replaceDiskStep(1);
+    document.getElementById('replaceModal').classList.remove('hidden');
+}
+
+function closeReplaceDiskModal() {
+    document.getElementById('replaceModal').classList.add('hidden');
+    if (replaceTimer) {
+        clearInterval(replaceTimer);
+        replaceTimer = null;
+        location.reload();
+    }
+}
+
+function loadReplacementDisks() {
+    var select = document.getElementById('replaceNewDisk');
+    fetch('/api/admin/storage/disks/available')
+    .then(function(resp) { return resp.json(); })
+    .then(function(disks) {
+        var html = '<option value="">' + t.replaceSelectNew + '</option>';
+        (disks || []).forEach(function(disk) {
+            if (disk.in_use) return;
+            html += '<option value="' + escapeHtml(disk.path) + '">' + escapeHtml(disk.path) + ' - ' + escapeHtml(disk.model || '') + ' (' + escapeHtml(disk.size_human) + ')</option>';
+        });
+        select.innerHTML = html;
+    })
+    .catch(function(err) { select.innerHTML = '<option value="">' + t.error + '</option>'; });
+}
+
+function offlineReplaceDisk() {
+    var disk = document.getElementById('replaceOldDisk').value;
+    if (!disk) return;
+    requirePassword(t.replaceOfflineConfirm + ': ' + disk, t.replaceOfflineWarning, function() {
+        fetch('/api/admin/storage/pool-offline/' + encodeURIComponent(replacePool), {
+            method: 'POST',
+            headers: {'Content-Type': 'application/json', 'X-Verification-Token': verificationToken},
+            body: JSON.stringify({disk: disk, temporary: false})
+        })
+        .then(function(resp) { return resp.json(); })
+        .then(function(data) {
+            if (data.success) alert(t.replaceOfflineDone);
+            else alert(t.error + ': ' + data.error);
+        })
+        .catch(function(err) { alert(t.error + ': ' + err); });
+    });
+}
+
+function submitReplaceDisk() {
+    var oldDisk = document.getElementById('replaceOldDisk').value;
+    var newDisk = document.getElementById('replaceNewDisk').value;
+    var force = document.getElementById('replaceForce').checked;
+    if (!oldDisk || !newDisk) { alert(t.replaceSelectNew); return; }
+    requirePassword(t.replaceConfirm + ': ' + oldDisk + ' → ' + newDisk, t.replaceWarning, function() {
+        fetch('/api/admin/storage/pool-replace/' + encodeURIComponent(replacePool), {
+            method: 'POST',
+            headers: {'Content-Type': 'application/json', 'X-Verification-Token': verificationToken},
+            body: JSON.stringify({old_disk: oldDisk, new_disk: newDisk, force: force})
+        })
+        .then(function(resp) { return resp.json(); })
+        .then(function(data) {
+            if (!data.success) { alert(t.error + ': ' + data.error); return; }
+            replaceDiskStep(3);
+            pollResilver();
+            replaceTimer = setInterval(pollResilver, 5000);
+        })
+        .catch(function(err) { alert(t.error + ': ' + err); });
+    });
+}
+
+function pollResilver() {
+    var container = document.getElementById('replaceProgress');
+    fetch('/api/admin/storage/scrub-history?pool=' + encodeURIComponent(replacePool))
+    .then(function(resp) { return resp.json(); })
+    .then(function(data) {
+        var scan = data.scan || {};
+        if (scan.state === 'running' || scan.state === 'paused') {
+            container.innerHTML = '<div class="storage-progress"><div class="storage-progress-bar" style="width:' + scan.progress.toFixed(1) + '%;background:var(--accent);"></div></div>' +
+                '<div style="font-size:0.8125rem;color:var(--text-secondary);">' + scanSummary(scan) + '</div>';
+            return;
+        }
+        container.innerHTML = '<div style="font-size:0.8125rem;color:var(--success);">' + t.replaceDone + '</div>';
+        if (replaceTimer) { clearInterval(replaceTimer); replaceTimer = null; }
+    })
+    .catch(function(err) {
+        container.innerHTML = '<div style="color:var(--error);">' + t.error + '</div>';
+    });
+}
+
+/* Encrypted volumes */
+var cryptVolume = null;
+var cryptPurpose = null;
+
+function resetEncryption(prefix) {
+    var toggle = document.getElementById(prefix + 'Encrypt');
+    if (!toggle) return;
+    toggle.checked = false;
+    document.getElementById(prefix + 'KeyMode').value = 'master';
+    document.getElementById(prefix + 'Passphrase').value = '';
+    document.getElementById(prefix + 'PassphraseConfirm').value = '';
+    document.getElementById(prefix + 'Encryption').style.display = 'none';
+    document.getElementById(prefix + 'PassphraseFields').style.display = 'none';
+}
+
+function readPassphrase(prefix) {
+    var passphrase = document.getElementById(prefix + 'Passphrase').value;
+    if (passphrase.length < 12) { alert(t.cryptPassphraseShort); return null; }
+    if (passphrase !== document.getElementById(prefix + 'PassphraseConfirm').value) { alert(t.cryptPassphraseMismatch); return null; }
+    return passphrase;
+}
+
+/* Returns the encryption options of a form, or null if they are invalid */
+function encryptionOptions(prefix) {
+    var toggle = document.getElementById(prefix + 'Encrypt');
+    if (!toggle || !toggle.checked) return {enabled: false};
+    var mode = document.getElementById(prefix + 'KeyMode').value;
+    var options = {enabled: true, key_mode: mode, passphrase: ''};
+    if (mode === 'passphrase') {
+        options.passphrase = readPassphrase(prefix);
+        if (options.passphrase === null) return null;
+    }
+    return options;
+}
+
+var encryptedVolumes = [];
+
+function loadEncryptedVolumes() {
+    var container = document.querySelector('[data-encrypted-volumes]');
+    if (!container) return;
+    fetch('/api/admin/storage/encrypted-volumes')
+    .then(function(resp) { return resp.json(); })
+    .then(function(data) {
+        encryptedVolumes = data.volumes || [];
+        document.getElementById('encryptedVolumesCard').style.display = encryptedVolumes.length ? 'block' : 'none';
+        var states = {locked: ['v2-badge-warning', t.cryptStateLocked], unlocked: ['v2-badge-success', t.cryptStateUnlocked]};
+        var html = '<div style="overflow-x:auto;"><table class="v2-table"><tbody>';
+        encryptedVolumes.forEach(function(v) {
+            var state = states[v.state] || ['v2-badge-error', t.cryptStateUnknown];
+            html += '<tr><td style="font-family:monospace;font-weight:500;color:var(--text-primary);">' + escapeHtml(v.name) + '</td>';
+            html += '<td>' + (v.kind === 'luks' ? t.cryptKindLuks : t.cryptKindZfs) + (v.device ? ' <span style="color:var(--text-muted);">' + escapeHtml(v.device) + '</span>' : '') + '</td>';
+            html += '<td>' + (v.key_mode === 'master' ? t.cryptKeyModeMaster : t.cryptKeyModePassphrase) + '</td>';
+            html += '<td><span class="v2-badge ' + state[0] + '">' + state[1] + '</span>';
+            if (v.mounted && v.mountpoint) html += ' <span style="color:var(--text-muted);font-size:0.75rem;">' + t.cryptMounted + ': ' + escapeHtml(v.mountpoint) + '</span>';
+            html += '</td><td style="white-space:nowrap;text-align:right;">';
+            if (v.state === 'locked') html += '<button data-action="unlockVolume" data-id="' + v.id + '" class="v2-btn v2-btn-primary v2-btn-sm">' + t.cryptUnlock + '</button> ';
+            if (v.state === 'unlocked') html += '<button data-action="lockVolume" data-id="' + v.id + '" class="v2-btn v2-btn-secondary v2-btn-sm">' + t.cryptLock + '</button> ';
+            html += '<button data-action="showVolumeKeyModal" data-id="' + v.id + '" class="v2-btn v2-btn-secondary v2-btn-sm">' + t.cryptChangeKey + '</button> ';
+            html += '<button data-action="exportVolumeKey" data-id="' + v.id + '" class="v2-btn v2-btn-secondary v2-btn-sm">' + t.cryptExportKey + '</button>';
+            if (v.state !== 'unlocked') html += ' <button data-action="forgetVolume" data-id="' + v.id + '" class="v2-btn v2-btn-danger v2-btn-sm">' + t.cryptForget + '</button>';
+            html += '</td></tr>';
+        });
+        html += '</tbody></table></div>';
+        container.innerHTML = html;
+    })
+    .catch(function(err) {
+        container.innerHTML = '<div style="text-align:center;color:var(--error);padding:1rem;">' + t.error + '</div>';
+    });
+}
+
+function findVolume(id) {
+    return encryptedVolumes.filter(function(v) { return String(v.id) === String(id); })[0] || null;
+}
+
+function volumeRequest(action, id, body) {
+    return fetch('/api/admin/storage/encrypted-volumes/' + action + '?id=' + encodeURIComponent(id), {
+        method: 'POST',
+        headers: {'Content-Type': 'application/json', 'X-Verification-Token': verificationToken || ''},
+        body: JSON.stringify(body || {})
+    }).then(function(resp) { return resp.json(); });
+}
+
+function unlockVolume(id, passphrase) {
+    volumeRequest('unlock', id, {passphrase: passphrase || ''})
+    .then(function(data) {
+        if (data.success) { alert(t.cryptUnlocked); loadEncryptedVolumes(); }
+        else alert(t.error + ': ' + data.error);
+    })
+    .catch(function(err) { alert(t.error + ': ' + err); });
+}
+
+/* Unlocking and exporting ask for the passphrase, unless the master key wraps the volume */
+function showVolumePassphraseModal(id, purpose) {
+    var v = findVolume(id);
+    if (!v) return;
+    if (v.key_mode === 'master') {
+        if (purpose === 'unlock') unlockVolume(id);
+        else exportVolumeKey(id, '');
+        return;
+    }
+    cryptVolume = v;
+    cryptPurpose = purpose;
+    document.getElementById('volumePassphraseTitle').textContent = purpose === 'unlock' ? t.cryptUnlock : t.cryptExportKey;
+    document.getElementById('volumePassphraseName').textContent = v.name;
+    document.getElementById('volumePassphrase').value = '';
+    document.getElementById('volumePassphraseModal').classList.remove('hidden');
+}
+
+function closeVolumePassphraseModal() { document.getElementById('volumePassphraseModal').classList.add('hidden'); }
+
+function submitVolumePassphrase(e) {
+    e.preventDefault();
+    var passphrase = document.getElementById('volumePassphrase').value;
+    closeVolumePassphraseModal();
+    if (cryptPurpose === 'unlock') unlockVolume(cryptVolume.id, passphrase);
+    else exportVolumeKey(cryptVolume.id, passphrase);
+}
+
+function lockVolume(id) {
+    var v = findVolume(id);
+    if (!v) return;
+    requirePassword(t.cryptLockConfirm + ': ' + v.name, t.cryptLockWarning, function() {
+        volumeRequest('lock', id)
+        .then(function(data) {
+            if (data.success) { alert(t.cryptLocked); loadEncryptedVolumes(); }
+            else alert(t.error + ': ' + data.error);
+        })
+        .catch(function(err) { alert(t.error + ': ' + err); });
+    });
+}
+
+function exportVolumeKey(id, passphrase) {
+    var v = findVolume(id);
+    if (!v) return;
+    requirePassword(t.cryptExportConfirm + ': ' + v.name, t.cryptExportWarning, function() {
+        volumeRequest('export', id, {passphrase: passphrase})
+        .then(function(data) {
+            if (!data.key) { alert(t.error + ': ' + data.error); return; }
+            var lines = ['Anemone encrypted volume key', 'Name: ' + data.name, 'Kind: ' + data.kind];
+            if (data.uuid) lines.push('UUID: ' + data.uuid);
+            lines.push('Key (hex): ' + data.key, '');
+            if (data.kind === 'luks') lines.push('Unlock: echo -n KEY | cryptsetup open --key-file - /dev/disk/by-uuid/' + (data.uuid || 'UUID') + ' ' + data.name);
+            else lines.push('Unlock: echo KEY | zfs load-key ' + data.name);
+            var link = document.createElement('a');
+            link.href = URL.createObjectURL(new Blob([lines.join('\n') + '\n'], {type: 'text/plain'}));
+            link.download = 'anemone-key-' + data.name.replace(/[^a-zA-Z0-9_-]/g, '_') + '.txt';
+            link.click();
+            URL.revokeObjectURL(link.href);
+        })
+        .catch(function(err) { alert(t.error + ': ' + err); });
+    });
+}
+
+function forgetVolume(id) {
+    var v = findVolume(id);
+    if (!v) return;
+    requirePassword(t.cryptForgetConfirm + ': ' + v.name, t.cryptForgetWarning, function() {
+        fetch('/api/admin/storage/encrypted-volumes?id=' + encodeURIComponent(id), {
+            method: 'DELETE',
+            headers: {'X-Verification-Token': verificationToken}
+        })
+        .then(function(resp) { return resp.json(); })
+        .then(function(data) {
+            if (data.success) loadEncryptedVolumes();
+            else alert(t.error + ': ' + data.error);
+        })
+        .catch(function(err) { alert(t.error + ': ' + err); });
+    });
+}
+
+function showVolumeKeyModal(id) {
+    var v = findVolume(id);
+    if (!v) return;
+    cryptVolume = v;
+    document.getElementById('volumeKeyName').textContent = v.name;
+    document.getElementById('volumeKeyCurrent').value = '';
+    document.getElementById('volumeKeyCurrentField').style.display = v.key_mode === 'passphrase' ? 'block' : 'none';
+    document.getElementById('volumeKeyKeyMode').value = 'passphrase';
+    document.getElementById('volumeKeyPassphrase').value = '';
+    document.getElementById('volumeKeyPassphraseConfirm').value = '';
+    document.getElementById('volumeKeyPassphraseFields').style.display = 'block';
+    document.getElementById('volumeKeyModal').classList.remove('hidden');
+}
+
+function closeVolumeKeyModal() { document.getElementById('volumeKeyModal').classList.add('hidden'); }
+
+function saveVolumeKey(e) {
+    e.preventDefault();
+    var mode = document.getElementById('volumeKeyKeyMode').value;
+    var passphrase = '';
+    if (mode === 'passphrase') {
+        passphrase = readPassphrase('volumeKey');
+        if (passphrase === null) return;
+    }
+    var v = cryptVolume;
+    var body = {current_passphrase: document.getElementById('volumeKeyCurrent').value, key_mode: mode, passphrase: passphrase};
+    closeVolumeKeyModal();
+    requirePassword(t.cryptKeyConfirm + ': ' + v.name, t.cryptKeyWarning, function() {
+        volumeRequest('key', v.id, body)
+        .then(function(data) {
+            if (data.success) { alert(t.cryptKeyChanged); loadEncryptedVolumes(); }
+            else alert(t.error + ': ' + data.error);
+        })
+        .catch(function(err) { alert(t.error + ': ' + err); });
+    });
+}
+
 function showCreatePoolModal() {
     loadAvailableDisks();
+    resetEncryption('pool');
     document.getElementById('createPoolModal').classList.remove('hidden');
 }
 
@@ -207,12 +1016,14 @@ function createPool(e) {
     var mountpoint = document.getElementById('poolMountpoint').value;
     var disks = Array.from(document.querySelectorAll('input[name="poolDisks"]:checked')).map(function(el) { return el.value; });
     if (disks.length === 0) { alert(t.selectAtLeastOneDisk); return; }
+    var encryption = encryptionOptions('pool');
+    if (!encryption) return;
     closeCreatePoolModal();
     requirePassword(t.createPool, t.createPoolWarning, function() {
         fetch('/api/admin/storage/pool', {
             method: 'POST',
             headers: {'Content-Type': 'application/json', 'X-Verification-Token': verificationToken},
-            body: JSON.stringify({name: name, vdev_type: vdevType, disks: disks, compression: compression, mountpoint: mountpoint, force: true})
+            body: JSON.stringify({name: name, vdev_type: vdevType, disks: disks, compression: compression, mountpoint: mountpoint, force: true, encryption: encryption})
         })
         .then(function(resp) { return resp.json(); })
         .then(function(data) {
@@ -314,7 +1125,10 @@ function loadDatasets() {
     });
 }
 
-function showCreateDatasetModal() { document.getElementById('createDatasetModal').classList.remove('hidden'); }
+function showCreateDatasetModal() {
+    resetEncryption('dataset');
+    document.getElementById('createDatasetModal').classList.remove('hidden');
+}
 function closeCreateDatasetModal() { document.getElementById('createDatasetModal').classList.add('hidden'); }
 
 function createDataset(e) {
@@ -322,12 +1136,14 @@ function createDataset(e) {
     var name = document.getElementById('datasetName').value;
     var compression = document.getElementById('datasetCompression').value;
     var quota = document.getElementById('datasetQuota').value;
+    var encryption = encryptionOptions('dataset');
+    if (!encryption) return;
     closeCreateDatasetModal();
     requirePassword(t.createDataset, t.createDatasetWarning, function() {
         fetch('/api/admin/storage/dataset', {
             method: 'POST',
             headers: {'Content-Type': 'application/json', 'X-Verification-Token': verificationToken},
-            body: JSON.stringify({name: name, compression: compression, quota: quota})
+            body: JSON.stringify({name: name, compression: compression, quota: quota, encryption: encryption})
         })
         .then(function(resp) { return resp.json(); })
         .then(function(data) {
@@ -354,7 +1170,10 @@ function deleteDataset(name) {
 }
 
 /* Snapshot operations */
+var scheduledPattern = /-(hourly|daily|weekly|monthly)-\d{8}-\d{4}$/;
+
 function loadSnapshots() {
+    loadPolicies();
     fetch('/api/admin/storage/snapshots')
     .then(function(resp) { return resp.json(); })
     .then(function(snapshots) {
@@ -364,7 +1183,8 @@ function loadSnapshots() {
             html += '<th>' + t.snapshotHeader + '</th><th>' + t.datasetHeader + '</th><th style="text-align:right;">' + t.usedHeader + '</th><th>' + t.createdHeader + '</th><th>' + t.actionsHeader + '</th>';
             html += '</tr></thead><tbody>';
             snapshots.forEach(function(snap) {
-                html += '<tr><td>' + snap.snap_name + '</td><td>' + snap.dataset + '</td><td style="text-align:right;">' + snap.used_human + '</td><td>' + snap.creation_str + '</td>';
+                var badge = scheduledPattern.test(snap.snap_name) ? ' <span class="v2-badge v2-badge-info">' + t.scheduledBadge + '</span>' : '';
+                html += '<tr><td>' + snap.snap_name + badge + '</td><td>' + snap.dataset + '</td><td style="text-align:right;">' + snap.used_human + '</td><td>' + snap.creation_str + '</td>';
                 html += '<td><button data-action="rollbackSnapshot" data-name="' + snap.name + '" class="v2-btn v2-btn-secondary v2-btn-sm" style="margin-right:0.25rem;">' + t.rollbackBtn + '</button>';
                 html += '<button data-action="deleteSnapshot" data-name="' + snap.name + '" class="v2-btn v2-btn-danger v2-btn-sm">' + t.deleteBtn + '</button></td></tr>';
             });
@@ -373,9 +1193,9 @@ function loadSnapshots() {
             html = '<div style="text-align:center;color:var(--text-muted);padding:2rem;">' + t.noSnapshots + '</div>';
         }
         document.getElementById('snapshots-list').innerHTML = html;
-        /* Populate snapshot dataset dropdown */
-        var select = document.getElementById('snapshotDataset');
-        if (select) {
+        /* Populate snapshot dataset dropdowns */
+        ['snapshotDataset', 'policyDataset'].forEach(function(selectID) {
+            var select = document.getElementById(selectID);
             select.innerHTML = '';
             var loadedCount = 0;
             poolNames.forEach(function(pool) {
@@ -395,13 +1215,51 @@ function loadSnapshots() {
                     loadedCount++;
                 });
             });
-        }
+        });
+        if (btrfsAvailable) loadSubvolumes();
     })
     .catch(function(err) {
         document.getElementById('snapshots-list').innerHTML = '<div style="text-align:center;color:var(--error);padding:2rem;">' + t.error + '</div>';
     });
 }
 
+/* Shares stored in Btrfs subvolumes */
+function loadSubvolumes() {
+    fetch('/api/admin/storage/subvolumes')
+    .then(function(resp) { return resp.json(); })
+    .then(function(volumes) {
+        var html = '';
+        if (volumes && volumes.length > 0) {
+            html = '<div style="overflow-x:auto;"><table class="v2-table"><thead><tr>';
+            html += '<th>' + t.shareHeader + '</th><th>' + t.ownerHeader + '</th><th style="text-align:right;">' + t.usedHeader + '</th><th style="text-align:right;">' + t.exclusiveHeader + '</th><th style="text-align:right;">' + t.limitHeader + '</th><th style="text-align:right;">' + t.snapshotsHeader + '</th>';
+            html += '</tr></thead><tbody>';
+            volumes.forEach(function(v) {
+                var usage = v.quota_enabled
+                    ? '<td style="text-align:right;">' + v.ref_human + '</td><td style="text-align:right;">' + v.excl_human + '</td><td style="text-align:right;">' + (v.limit ? v.limit_human : t.unlimited) + '</td>'
+                    : '<td colspan="3" style="text-align:right;color:var(--text-muted);">' + t.quotaDisabled + '</td>';
+                html += '<tr><td>' + escapeHtml(v.share) + '<div style="font-size:0.75rem;color:var(--text-muted);font-family:monospace;">' + escapeHtml(v.path) + '</div></td><td>' + escapeHtml(v.owner) + '</td>' + usage + '<td style="text-align:right;">' + v.snapshots + '</td></tr>';
+            });
+            html += '</tbody></table></div>';
+        } else {
+            html = '<div style="text-align:center;color:var(--text-muted);padding:1rem;">' + t.noSubvolumes + '</div>';
+        }
+        document.getElementById('subvolumes-list').innerHTML = html;
+
+        ['snapshotDataset', 'policyDataset'].forEach(function(selectID) {
+            var select = document.getElementById(selectID);
+            (volumes || []).forEach(function(v) {
+                var opt = document.createElement('option');
+                opt.value = v.path;
+                opt.textContent = v.owner + ' / ' + v.share + ' (Btrfs)';
+                select.appendChild(opt);
+            });
+        });
+    })
+    .catch(function(err) {
+        document.getElementById('subvolumes-list').innerHTML = '<div style="text-align:center;color:var(--error);padding:1rem;">' + t.error + '</div>';
+    });
+}
+
 function showCreateSnapshotModal() { document.getElementById('createSnapshotModal').classList.remove('hidden'); }
 function closeCreateSnapshotModal() { document.getElementById('createSnapshotModal').classList.add('hidden'); }
 
@@ -438,7 +1296,8 @@ function deleteSnapshot(name) {
 }
 
 function rollbackSnapshot(name) {
-    requirePassword(t.rollbackSnapshot + ': ' + name, t.rollbackWarning, function() {
+    var warning = name.charAt(0) === '/' ? t.btrfsRollbackWarning : t.rollbackWarning;
+    requirePassword(t.rollbackSnapshot + ': ' + name, warning, function() {
         fetch('/api/admin/storage/snapshot-rollback', {
             method: 'POST',
             headers: {'Content-Type': 'application/json', 'X-Verification-Token': verificationToken},
@@ -453,6 +1312,294 @@ function rollbackSnapshot(name) {
     });
 }
 
+/* Snapshot policies */
+var policies = [];
+
+function escapeHtml(s) {
+    var div = document.createElement('div');
+    div.textContent = s;
+    return div.innerHTML;
+}
+
+function loadPolicies() {
+    fetch('/api/admin/storage/snapshot-policies')
+    .then(function(resp) { return resp.json(); })
+    .then(function(data) {
+        policies = data || [];
+        var html = '';
+        if (policies.length > 0) {
+            html = '<div style="overflow-x:auto;"><table class="v2-table"><thead><tr>';
+            html += '<th>' + t.datasetHeader + '</th><th>' + t.policyPrefix + '</th><th>' + t.policyRetention + '</th><th>' + t.policyLastRun + '</th><th>' + t.actionsHeader + '</th>';
+            html += '</tr></thead><tbody>';
+            policies.forEach(function(p) {
+                var status = p.enabled ? '<span class="v2-badge v2-badge-success">' + t.policyEnabled + '</span>' : '<span class="v2-badge v2-badge-warning">' + t.policyDisabled + '</span>';
+                if (p.recursive) status += ' <span class="v2-badge v2-badge-info">' + t.policyRecursive + '</span>';
+                var retention = t.policyRetentionShort.replace('{hourly}', p.keep_hourly).replace('{daily}', p.keep_daily).replace('{weekly}', p.keep_weekly).replace('{monthly}', p.keep_monthly);
+                var lastRun = p.last_run ? new Date(p.last_run).toLocaleString() : t.policyNever;
+                if (p.last_error) lastRun += '<div style="font-size:0.75rem;color:var(--error);">' + escapeHtml(p.last_error) + '</div>';
+                html += '<tr><td>' + p.dataset + ' ' + status + '</td><td>' + p.prefix + '</td><td>' + retention + '</td><td>' + lastRun + '</td>';
+                html += '<td><button data-action="runPolicy" data-id="' + p.id + '" class="v2-btn v2-btn-secondary v2-btn-sm" style="margin-right:0.25rem;">' + t.policyRun + '</button>';
+                html += '<button data-action="editPolicy" data-id="' + p.id + '" class="v2-btn v2-btn-secondary v2-btn-sm" style="margin-right:0.25rem;">' + t.editBtn + '</button>';
+                html += '<button data-action="deletePolicy" data-id="' + p.id + '" class="v2-btn v2-btn-danger v2-btn-sm">' + t.deleteBtn + '</button></td></tr>';
+            });
+            html += '</tbody></table></div>';
+        } else {
+            html = '<div style="text-align:center;color:var(--text-muted);padding:1rem;">' + t.noPolicies + '</div>';
+        }
+        document.getElementById('policies-list').innerHTML = html;
+    })
+    .catch(function(err) {
+        document.getElementById('policies-list').innerHTML = '<div style="text-align:center;color:var(--error);padding:1rem;">' + t.error + '</div>';
+    });
+}
+
+function showPolicyModal(id) {
+    var p = policies.filter(function(x) { return String(x.id) === String(id); })[0] ||
+        {id: '', dataset: '', prefix: 'auto', keep_hourly: 0, keep_daily: 7, keep_weekly: 4, keep_monthly: 0, recursive: false, enabled: true};
+    document.getElementById('policyID').value = p.id;
+    if (p.dataset) document.getElementById('policyDataset').value = p.dataset;
+    document.getElementById('policyPrefix').value = p.prefix;
+    document.getElementById('policyKeepHourly').value = p.keep_hourly;
+    document.getElementById('policyKeepDaily').value = p.keep_daily;
+    document.getElementById('policyKeepWeekly').value = p.keep_weekly;
+    document.getElementById('policyKeepMonthly').value = p.keep_monthly;
+    document.getElementById('policyRecursive').checked = p.recursive;
+    document.getElementById('policyEnabled').checked = p.enabled;
+    document.getElementById('policyModal').classList.remove('hidden');
+}
+function closePolicyModal() { document.getElementById('policyModal').classList.add('hidden'); }
+
+function savePolicy(e) {
+    e.preventDefault();
+    var id = document.getElementById('policyID').value;
+    var policy = {
+        id: id ? parseInt(id, 10) : 0,
+        dataset: document.getElementById('policyDataset').value,
+        prefix: document.getElementById('policyPrefix').value,
+        keep_hourly: parseInt(document.getElementById('policyKeepHourly').value, 10) || 0,
+        keep_daily: parseInt(document.getElementById('policyKeepDaily').value, 10) || 0,
+        keep_weekly: parseInt(document.getElementById('policyKeepWeekly').value, 10) || 0,
+        keep_monthly: parseInt(document.getElementById('policyKeepMonthly').value, 10) || 0,
+        recursive: document.getElementById('policyRecursive').checked,
+        enabled: document.getElementById('policyEnabled').checked
+    };
+    closePolicyModal();
+    requirePassword(t.policySave + ': ' + policy.dataset, t.policySaveWarning, function() {
+        fetch('/api/admin/storage/snapshot-policies', {
+            method: id ? 'PUT' : 'POST',
+            headers: {'Content-Type': 'application/json', 'X-Verification-Token': verificationToken},
+            body: JSON.stringify(policy)
+        })
+        .then(function(resp) { return resp.json(); })
+        .then(function(data) {
+            if (data.success) { alert(t.policySaved); loadPolicies(); }
+            else alert(t.error + ': ' + data.error);
+        })
+        .catch(function(err) { alert(t.error + ': ' + err); });
+    });
+}
+
+function deletePolicy(id) {
+    if (!confirm(t.policyDeleteConfirm)) return;
+    fetch('/api/admin/storage/snapshot-policies?id=' + encodeURIComponent(id), {method: 'DELETE'})
+    .then(function(resp) { return resp.json(); })
+    .then(function(data) {
+        if (data.success) { alert(t.policyDeleted); loadPolicies(); }
+        else alert(t.error + ': ' + data.error);
+    })
+    .catch(function(err) { alert(t.error + ': ' + err); });
+}
+
+function runPolicy(id) {
+    fetch('/api/admin/storage/snapshot-policies/run?id=' + encodeURIComponent(id), {method: 'POST'})
+    .then(function(resp) { return resp.json(); })
+    .then(function(data) {
+        if (data.success) { alert(t.policyRunDone); loadSnapshots(); }
+        else { alert(t.error + ': ' + data.error); loadPolicies(); }
+    })
+    .catch(function(err) { alert(t.error + ': ' + err); });
+}
+
+/* ZFS replication */
+var replications = [];
+var replicationTimer = null;
+
+function formatSize(bytes) {
+    if (bytes >= 1099511627776) return (bytes / 1099511627776).toFixed(1) + ' TiB';
+    if (bytes >= 1073741824) return (bytes / 1073741824).toFixed(1) + ' GiB';
+    if (bytes >= 1048576) return (bytes / 1048576).toFixed(1) + ' MiB';
+    return (bytes / 1024).toFixed(1) + ' KiB';
+}
+
+function loadReplications() {
+    fetch('/api/admin/storage/replications')
+    .then(function(resp) { return resp.json(); })
+    .then(function(data) {
+        replications = data || [];
+        var running = false;
+        var html = '';
+        if (replications.length > 0) {
+            html = '<div style="overflow-x:auto;"><table class="v2-table"><thead><tr>';
+            html += '<th>' + t.datasetHeader + '</th><th>' + t.peerHeader + '</th><th>' + t.replicationSchedule + '</th><th>' + t.policyLastRun + '</th><th>' + t.actionsHeader + '</th>';
+            html += '</tr></thead><tbody>';
+            replications.forEach(function(r) {
+                var status = r.enabled ? '<span class="v2-badge v2-badge-success">' + t.policyEnabled + '</span>' : '<span class="v2-badge v2-badge-warning">' + t.policyDisabled + '</span>';
+                var schedule = t.replicationScheduleShort.replace('{minutes}', r.interval_minutes).replace('{keep}', r.keep_snapshots);
+                var lastRun = r.last_run ? new Date(r.last_run).toLocaleString() : t.policyNever;
+                if (r.last_status === 'success' && r.last_snapshot) lastRun += '<div style="font-size:0.75rem;color:var(--text-muted);">' + escapeHtml(r.last_snapshot) + ' (' + t.replicationLastSent + ' ' + formatSize(r.bytes_sent) + ')</div>';
+                if (r.last_error) lastRun += '<div style="font-size:0.75rem;color:var(--error);">' + escapeHtml(r.last_error) + '</div>';
+                if (r.progress) {
+                    running = true;
+                    var done = formatSize(r.progress.bytes);
+                    if (r.progress.total > 0) done += ' / ' + formatSize(r.progress.total) + ' (' + Math.min(100, Math.round(r.progress.bytes * 100 / r.progress.total)) + '%)';
+                    lastRun = '<span class="v2-badge v2-badge-info">' + t.replicationRunning + '</span> ' + done;
+                }
+                html += '<tr><td>' + escapeHtml(r.dataset) + ' ' + status + '</td><td>' + escapeHtml(r.peer_name) + '<div style="font-size:0.75rem;color:var(--text-muted);font-family:monospace;">' + escapeHtml(r.target_name) + '</div></td><td>' + schedule + '</td><td>' + lastRun + '</td>';
+                html += '<td><button data-action="runReplication" data-id="' + r.id + '" class="v2-btn v2-btn-secondary v2-btn-sm" style="margin-right:0.25rem;"' + (r.progress ? ' disabled' : '') + '>' + t.replicationRun + '</button>';
+                html += '<button data-action="editReplication" data-id="' + r.id + '" class="v2-btn v2-btn-secondary v2-btn-sm" style="margin-right:0.25rem;">' + t.editBtn + '</button>';
+                html += '<button data-action="deleteReplication" data-id="' + r.id + '" class="v2-btn v2-btn-danger v2-btn-sm">' + t.deleteBtn + '</button></td></tr>';
+            });
+            html += '</tbody></table></div>';
+        } else {
+            html = '<div style="text-align:center;color:var(--text-muted);padding:1rem;">' + t.replicationNone + '</div>';
+        }
+        document.getElementById('replications-list').innerHTML = html;
+
+        /* Refresh while a job is running */
+        clearTimeout(replicationTimer);
+        if (running) replicationTimer = setTimeout(loadReplications, 3000);
+    })
+    .catch(function(err) {
+        document.getElementById('replications-list').innerHTML = '<div style="text-align:center;color:var(--error);padding:1rem;">' + t.error + '</div>';
+    });
+}
+
+function loadReplicas() {
+    fetch('/api/admin/storage/replicas')
+    .then(function(resp) { return resp.json(); })
+    .then(function(data) {
+        document.getElementById('receiveBase').value = data.receive_base || '';
+        var html = '';
+        if (data.replicas && data.replicas.length > 0) {
+            html = '<div style="overflow-x:auto;"><table class="v2-table"><thead><tr>';
+            html += '<th>' + t.datasetHeader + '</th><th>' + t.replicaSource + '</th><th style="text-align:right;">' + t.usedHeader + '</th><th style="text-align:right;">' + t.snapshotsHeader + '</th><th>' + t.snapshotHeader + '</th>';
+            html += '</tr></thead><tbody>';
+            data.replicas.forEach(function(r) {
+                var last = r.last_snapshot ? escapeHtml(r.last_snapshot) : '-';
+                if (r.resumable) last += ' <span class="v2-badge v2-badge-warning">' + t.replicaResumable + '</span>';
+                html += '<tr><td style="font-family:monospace;">' + escapeHtml(r.dataset) + '</td><td>' + escapeHtml(r.source) + '</td><td style="text-align:right;">' + r.used_human + '</td><td style="text-align:right;">' + r.snapshots + '</td><td>' + last + '</td></tr>';
+            });
+            html += '</tbody></table></div>';
+        } else {
+            html = '<div style="text-align:center;color:var(--text-muted);padding:1rem;">' + t.replicaNone + '</div>';
+        }
+        document.getElementById('replicas-list').innerHTML = html;
+    })
+    .catch(function(err) {
+        document.getElementById('replicas-list').innerHTML = '<div style="text-align:center;color:var(--error);padding:1rem;">' + t.error + '</div>';
+    });
+}
+
+function loadReplicationDatasets() {
+    var select = document.getElementById('replicationDataset');
+    select.innerHTML = '';
+    poolNames.forEach(function(pool) {
+        fetch('/api/admin/storage/datasets?parent=' + pool)
+        .then(function(resp) { return resp.json(); })
+        .then(function(datasets) {
+            datasets.forEach(function(ds) {
+                if (ds.name.indexOf('/') < 0) return;
+                var opt = document.createElement('option');
+                opt.value = ds.name;
+                opt.textContent = ds.name;
+                select.appendChild(opt);
+            });
+        })
+        .catch(function(err) { console.error(err); });
+    });
+}
+
+function showReplicationModal(id) {
+    var r = replications.filter(function(x) { return String(x.id) === String(id); })[0] ||
+        {id: '', dataset: '', peer_id: '', target_name: '', interval_minutes: 1440, keep_snapshots: 7, enabled: true};
+    document.getElementById('replicationID').value = r.id;
+    if (r.dataset) document.getElementById('replicationDataset').value = r.dataset;
+    if (r.peer_id) document.getElementById('replicationPeer').value = r.peer_id;
+    document.getElementById('replicationTarget').value = r.target_name;
+    document.getElementById('replicationInterval').value = r.interval_minutes;
+    document.getElementById('replicationKeep').value = r.keep_snapshots;
+    document.getElementById('replicationEnabled').checked = r.enabled;
+    document.getElementById('replicationModal').classList.remove('hidden');
+}
+function closeReplicationModal() { document.getElementById('replicationModal').classList.add('hidden'); }
+
+function saveReplication(e) {
+    e.preventDefault();
+    var id = document.getElementById('replicationID').value;
+    var replication = {
+        id: id ? parseInt(id, 10) : 0,
+        dataset: document.getElementById('replicationDataset').value,
+        peer_id: parseInt(document.getElementById('replicationPeer').value, 10) || 0,
+        target_name: document.getElementById('replicationTarget').value,
+        interval_minutes: parseInt(document.getElementById('replicationInterval').value, 10) || 0,
+        keep_snapshots: parseInt(document.getElementById('replicationKeep').value, 10) || 0,
+        enabled: document.getElementById('replicationEnabled').checked
+    };
+    closeReplicationModal();
+    requirePassword(t.replicationSave + ': ' + replication.dataset, t.replicationSaveWarning, function() {
+        fetch('/api/admin/storage/replications', {
+            method: id ? 'PUT' : 'POST',
+            headers: {'Content-Type': 'application/json', 'X-Verification-Token': verificationToken},
+            body: JSON.stringify(replication)
+        })
+        .then(function(resp) { return resp.json(); })
+        .then(function(data) {
+            if (data.success) { alert(t.replicationSaved); loadReplications(); }
+            else alert(t.error + ': ' + data.error);
+        })
+        .catch(function(err) { alert(t.error + ': ' + err); });
+    });
+}
+
+function deleteReplication(id) {
+    if (!confirm(t.replicationDeleteConfirm)) return;
+    fetch('/api/admin/storage/replications?id=' + encodeURIComponent(id), {method: 'DELETE'})
+    .then(function(resp) { return resp.json(); })
+    .then(function(data) {
+        if (data.success) { alert(t.replicationDeleted); loadReplications(); }
+        else alert(t.error + ': ' + data.error);
+    })
+    .catch(function(err) { alert(t.error + ': ' + err); });
+}
+
+function runReplication(id) {
+    fetch('/api/admin/storage/replications/run?id=' + encodeURIComponent(id), {method: 'POST'})
+    .then(function(resp) { return resp.json(); })
+    .then(function(data) {
+        if (!data.success) alert(t.error + ': ' + data.error);
+        setTimeout(loadReplications, 1000);
+    })
+    .catch(function(err) { alert(t.error + ': ' + err); });
+}
+
+function saveReceiveBase(e) {
+    e.preventDefault();
+    var base = document.getElementById('receiveBase').value.trim();
+    requirePassword(t.receiveBaseSave, t.receiveBaseWarning, function() {
+        fetch('/api/admin/storage/replicas', {
+            method: 'PUT',
+            headers: {'Content-Type': 'application/json', 'X-Verification-Token': verificationToken},
+            body: JSON.stringify({receive_base: base})
+        })
+        .then(function(resp) { return resp.json(); })
+        .then(function(data) {
+            if (data.success) { alert(t.receiveBaseSaved); loadReplicas(); }
+            else alert(t.error + ': ' + data.error);
+        })
+        .catch(function(err) { alert(t.error + ': ' + err); });
+    });
+}
+
 /* Disk format operations */
 function showFormatDiskModal(device) {
     document.getElementById('formatDiskDevice').value = device;
@@ -465,6 +1612,7 @@ function showFormatDiskModal(device) {
     document.getElementById('mountPathContainer').style.display = 'block';
     document.getElementById('formatSharedAccessContainer').style.display = 'block';
     document.getElementById('formatPersistentContainer').style.display = 'block';
+    resetEncryption('format');
     document.getElementById('formatDiskModal').classList.remove('hidden');
 }
 
@@ -492,12 +1640,14 @@ function formatDisk(e) {
             return;
         }
     }
+    var encryption = encryptionOptions('format');
+    if (!encryption) return;
     closeFormatDiskModal();
     requirePassword(t.formatDisk + ': ' + device, t.formatDiskWarning, function() {
         fetch('/api/admin/storage/disk/format', {
             method: 'POST',
             headers: {'Content-Type': 'application/json', 'X-Verification-Token': verificationToken},
-            body: JSON.stringify({device: device, filesystem: filesystem, label: label, force: true, mount: mount, mount_path: mountPath, shared_access: sharedAccess, persistent: persistent})
+            body: JSON.stringify({device: device, filesystem: filesystem, label: label, force: true, mount: mount, mount_path: mountPath, shared_access: sharedAccess, persistent: persistent, encryption: encryption})
         })
         .then(function(resp) { return resp.json(); })
         .then(function(data) {
@@ -591,12 +1741,37 @@ document.addEventListener('click', function(e) {
         case 'showImportPoolModal': showImportPoolModal(); break;
         case 'showCreatePoolModal': showCreatePoolModal(); break;
         case 'startScrub': startScrub(target.getAttribute('data-pool')); break;
+        case 'showScrubModal': showScrubModal(target.getAttribute('data-pool')); break;
+        case 'closeScrubModal': closeScrubModal(); break;
+        case 'deleteScrubSchedule': deleteScrubSchedule(); break;
+        case 'acknowledgePoolAlert': acknowledgePoolAlert(target.getAttribute('data-id')); break;
+        case 'showReplaceDiskModal': showReplaceDiskModal(target.getAttribute('data-pool'), target.getAttribute('data-disk')); break;
+        case 'closeReplaceDiskModal': closeReplaceDiskModal(); break;
+        case 'replaceDiskStep': replaceDiskStep(parseInt(target.getAttribute('data-step'), 10)); break;
+        case 'offlineReplaceDisk': offlineReplaceDisk(); break;
+        case 'submitReplaceDisk': submitReplaceDisk(); break;
+        case 'showCreateMDModal': showCreateMDModal(); break;
+        case 'closeCreateMDModal': closeCreateMDModal(); break;
+        case 'showMDDiskModal': showMDDiskModal(target.getAttribute('data-array'), target.getAttribute('data-disk'), target.getAttribute('data-md-action')); break;
+        case 'closeMDDiskModal': closeMDDiskModal(); break;
+        case 'mdDiskAction': mdDiskAction(target.getAttribute('data-array'), target.getAttribute('data-disk'), target.getAttribute('data-md-action')); break;
+        case 'mdCheck': mdCheck(target.getAttribute('data-array'), target.getAttribute('data-stop') === '1'); break;
+        case 'unlockVolume': showVolumePassphraseModal(target.getAttribute('data-id'), 'unlock'); break;
+        case 'exportVolumeKey': showVolumePassphraseModal(target.getAttribute('data-id'), 'export'); break;
+        case 'closeVolumePassphraseModal': closeVolumePassphraseModal(); break;
+        case 'lockVolume': lockVolume(target.getAttribute('data-id')); break;
+        case 'forgetVolume': forgetVolume(target.getAttribute('data-id')); break;
+        case 'showVolumeKeyModal': showVolumeKeyModal(target.getAttribute('data-id')); break;
+        case 'closeVolumeKeyModal': closeVolumeKeyModal(); break;
         case 'showExportPoolModal': showExportPoolModal(target.getAttribute('data-pool')); break;
         case 'showDestroyPoolModal': showDestroyPoolModal(target.getAttribute('data-pool')); break;
         case 'showCreateDatasetModal': showCreateDatasetModal(); break;
         case 'showCreateSnapshotModal': showCreateSnapshotModal(); break;
         case 'closePasswordModal': closePasswordModal(); break;
         case 'closeSMARTModal': closeSMARTModal(); break;
+        case 'smartHistoryRange': loadSMARTHistory(smartDisk, parseInt(target.getAttribute('data-days'), 10)); break;
+        case 'startSMARTSelfTest': startSMARTSelfTest(target.getAttribute('data-type')); break;
+        case 'acknowledgeSMARTAlert': acknowledgeSMARTAlert(target.getAttribute('data-id')); break;
         case 'closeCreatePoolModal': closeCreatePoolModal(); break;
         case 'closeCreateDatasetModal': closeCreateDatasetModal(); break;
         case 'closeCreateSnapshotModal': closeCreateSnapshotModal(); break;
@@ -605,6 +1780,16 @@ document.addEventListener('click', function(e) {
         case 'deleteDataset': deleteDataset(target.getAttribute('data-name')); break;
         case 'rollbackSnapshot': rollbackSnapshot(target.getAttribute('data-name')); break;
         case 'deleteSnapshot': deleteSnapshot(target.getAttribute('data-name')); break;
+        case 'showPolicyModal': showPolicyModal(); break;
+        case 'closePolicyModal': closePolicyModal(); break;
+        case 'editPolicy': showPolicyModal(target.getAttribute('data-id')); break;
+        case 'deletePolicy': deletePolicy(target.getAttribute('data-id')); break;
+        case 'runPolicy': runPolicy(target.getAttribute('data-id')); break;
+        case 'showReplicationModal': showReplicationModal(); break;
+        case 'closeReplicationModal': closeReplicationModal(); break;
+        case 'editReplication': showReplicationModal(target.getAttribute('data-id')); break;
+        case 'deleteReplication': deleteReplication(target.getAttribute('data-id')); break;
+        case 'runReplication': runReplication(target.getAttribute('data-id')); break;
     }
 });
 
@@ -613,12 +1798,29 @@ document.getElementById('passwordForm').addEventListener('submit', function(e) {
 document.getElementById('createPoolForm').addEventListener('submit', function(e) { createPool(e); });
 document.getElementById('createDatasetForm').addEventListener('submit', function(e) { createDataset(e); });
 document.getElementById('createSnapshotForm').addEventListener('submit', function(e) { createSnapshot(e); });
+document.getElementById('policyForm').addEventListener('submit', function(e) { savePolicy(e); });
+if (document.getElementById('scrubForm')) document.getElementById('scrubForm').addEventListener('submit', function(e) { saveScrubSchedule(e); });
+if (document.getElementById('createMDForm')) document.getElementById('createMDForm').addEventListener('submit', function(e) { createMDArray(e); });
+if (document.getElementById('mdDiskForm')) document.getElementById('mdDiskForm').addEventListener('submit', function(e) { submitMDDisk(e); });
+document.getElementById('replicationForm').addEventListener('submit', function(e) { saveReplication(e); });
+if (document.getElementById('smartSettingsForm')) document.getElementById('smartSettingsForm').addEventListener('submit', function(e) { saveSMARTSettings(e); });
+if (document.getElementById('receiveBaseForm')) document.getElementById('receiveBaseForm').addEventListener('submit', function(e) { saveReceiveBase(e); });
 document.getElementById('formatDiskForm').addEventListener('submit', function(e) { formatDisk(e); });
+document.getElementById('volumePassphraseForm').addEventListener('submit', function(e) { submitVolumePassphrase(e); });
+document.getElementById('volumeKeyForm').addEventListener('submit', function(e) { saveVolumeKey(e); });
 document.getElementById('mountDiskForm').addEventListener('submit', function(e) { mountDisk(e); });
 
 /* Format mount checkbox */
 document.getElementById('formatMount').addEventListener('change', function() { toggleMountPath(); });
 
+/* Encryption options */
+document.addEventListener('change', function(e) {
+    var prefix = e.target.getAttribute('data-encrypt-toggle');
+    if (prefix) document.getElementById(prefix + 'Encryption').style.display = e.target.checked ? 'block' : 'none';
+    prefix = e.target.getAttribute('data-key-mode');
+    if (prefix) document.getElementById(prefix + 'PassphraseFields').style.display = e.target.value === 'passphrase' ? 'block' : 'none';
+});
+
 /* Close modals on escape */
 document.addEventListener('keydown', function(e) {
     if (e.key === 'Escape') {
@@ -627,7 +1829,17 @@ document.addEventListener('keydown', function(e) {
         closeCreatePoolModal();
         closeCreateDatasetModal();
         closeCreateSnapshotModal();
+        closePolicyModal();
+        closeReplicationModal();
+        if (document.getElementById('scrubModal')) closeScrubModal();
+        if (document.getElementById('replaceModal')) closeReplaceDiskModal();
+        if (document.getElementById('createMDModal')) closeCreateMDModal();
+        if (document.getElementById('mdDiskModal')) closeMDDiskModal();
+        closeVolumePassphraseModal();
+        closeVolumeKeyModal();
         closeFormatDiskModal();
         closeMountDiskModal();
     }
 });
+
+loadEncryptedVolumes();
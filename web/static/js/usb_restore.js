@@ -0,0 +1,451 @@
+// usb_restore.js — USB restore page logic (v2_usb_restore.html)
+
+var pageData = JSON.parse(document.getElementById('page-data')?.textContent || '{}');
+var t = pageData.translations || {};
+var apiURL = '/api/admin/usb-restore';
+
+var backups = [];        // Backups detected on mounted drives
+var keys = {};           // Unlock secret per backup path
+var current = null;      // { path, share } being browsed
+var currentFiles = [];   // Flat file list of the browsed share
+var currentPath = '';
+var selectedItems = new Set();
+var jobsTimer = null;
+
+document.addEventListener('DOMContentLoaded', function() { scan(); loadJobs(); });
+
+// Event delegation for data-action buttons
+document.addEventListener('click', function(e) {
+    var target = e.target.closest('[data-action]');
+    if (!target) return;
+    var action = target.getAttribute('data-action');
+    var path = target.getAttribute('data-path');
+    var share = target.getAttribute('data-share');
+    switch (action) {
+        case 'scan': scan(); break;
+        case 'unlock': unlock(path); break;
+        case 'extractConfig': extractConfig(path); break;
+        case 'browse': browse(path, share); break;
+        case 'restoreAll': restoreAll(path, share); break;
+        case 'startRestore': startRestore(); break;
+        case 'closeBrowser': closeBrowser(); break;
+    }
+});
+
+function withKey(params, path) {
+    if (keys[path]) params.append('key', keys[path]);
+    return params;
+}
+
+async function scan() {
+    var container = document.getElementById('backups');
+    try {
+        var response = await fetch(apiURL + '/scan');
+        var result = await response.json();
+        if (!response.ok) throw new Error(result.message || 'Scan failed');
+        backups = result || [];
+        renderBackups();
+    } catch (error) {
+        console.error('Error scanning USB drives:', error);
+        container.textContent = t.error + ': ' + error.message;
+    }
+}
+
+async function unlock(path) {
+    var input = document.querySelector('input[data-key-for="' + CSS.escape(path) + '"]');
+    if (!input || !input.value) return;
+    var params = new URLSearchParams();
+    params.append('path', path);
+    params.append('key', input.value);
+    try {
+        var response = await fetch(apiURL + '/unlock', { method: 'POST', body: params });
+        var result = await response.json();
+        if (!response.ok) throw new Error(result.message || 'Unlock failed');
+        keys[path] = input.value;
+        backups = backups.map(function(b) { return b.path === path ? result : b; });
+        renderBackups();
+    } catch (error) { console.error('Unlock error:', error); alert(t.error + ': ' + error.message); }
+}
+
+async function extractConfig(path) {
+    var params = withKey(new URLSearchParams(), path);
+    params.append('path', path);
+    try {
+        var response = await fetch(apiURL + '/config', { method: 'POST', body: params });
+        var result = await response.json();
+        if (!response.ok || !result.success) throw new Error(result.message || 'Extraction failed');
+        alert(t.configExtracted + ' ' + result.dest);
+    } catch (error) { console.error('Config extraction error:', error); alert(t.error + ': ' + error.message); }
+}
+
+function renderBackups() {
+    var container = document.getElementById('backups');
+    container.innerHTML = '';
+    if (backups.length === 0) {
+        var empty = document.createElement('p');
+        empty.style.cssText = 'font-size:0.8125rem;color:var(--text-muted);';
+        empty.textContent = t.noBackups;
+        container.appendChild(empty);
+        return;
+    }
+    backups.forEach(function(backup) { container.appendChild(renderBackup(backup)); });
+}
+
+function renderBackup(backup) {
+    var block = document.createElement('div');
+    block.style.cssText = 'border:1px solid var(--border);border-radius:0.5rem;padding:1rem;margin-bottom:0.75rem;';
+
+    var header = document.createElement('div');
+    header.style.cssText = 'display:flex;justify-content:space-between;align-items:center;gap:0.75rem;flex-wrap:wrap;margin-bottom:0.75rem;';
+    var title = document.createElement('div');
+    var name = document.createElement('div');
+    name.style.cssText = 'font-size:0.875rem;font-weight:600;color:var(--text-primary);';
+    name.textContent = (backup.label || backup.device_path || backup.mount_path);
+    var location = document.createElement('div');
+    location.style.cssText = 'font-size:0.75rem;color:var(--text-muted);font-family:monospace;';
+    location.textContent = backup.path;
+    title.appendChild(name);
+    title.appendChild(location);
+    header.appendChild(title);
+
+    // Unlock with the drive passphrase, another master key or the recovery passphrase
+    var unlockBox = document.createElement('div');
+    unlockBox.style.cssText = 'display:flex;gap:0.5rem;align-items:center;';
+    if (backup.header) {
+        var protectedBadge = document.createElement('span');
+        protectedBadge.className = 'v2-badge v2-badge-info';
+        protectedBadge.textContent = t.protected;
+        protectedBadge.title = backup.header.server.name;
+        unlockBox.appendChild(protectedBadge);
+    } else if (backup.has_recovery_key) {
+        var badge = document.createElement('span');
+        badge.className = 'v2-badge v2-badge-info';
+        badge.textContent = t.recoveryKey;
+        unlockBox.appendChild(badge);
+    }
+    var keyInput = document.createElement('input');
+    keyInput.type = 'password';
+    keyInput.placeholder = t.keyPlaceholder;
+    keyInput.setAttribute('data-key-for', backup.path);
+    keyInput.style.cssText = 'padding:0.375rem 0.625rem;border-radius:0.375rem;border:1px solid var(--border);background:var(--bg-card);color:var(--text-primary);font-size:0.75rem;width:220px;';
+    unlockBox.appendChild(keyInput);
+    unlockBox.appendChild(actionButton(t.unlock, 'unlock', backup.path, null, 'v2-btn-secondary'));
+    header.appendChild(unlockBox);
+    block.appendChild(header);
+
+    // Configuration backup
+    var config = document.createElement('div');
+    config.style.cssText = 'display:flex;justify-content:space-between;align-items:center;font-size:0.8125rem;color:var(--text-secondary);margin-bottom:0.75rem;';
+    var configText = document.createElement('span');
+    if (backup.config) {
+        configText.textContent = t.config + ': ' + backup.config.source_server + ' — ' + new Date(backup.config.timestamp).toLocaleString() + ' (' + backup.config.files_count + ')';
+        config.appendChild(configText);
+        config.appendChild(actionButton(t.configRestore, 'extractConfig', backup.path, null, 'v2-btn-secondary'));
+    } else {
+        configText.textContent = t.noConfig;
+        config.appendChild(configText);
+    }
+    block.appendChild(config);
+
+    // Share backups
+    if (backup.shares && backup.shares.length > 0) {
+        var table = document.createElement('table');
+        table.className = 'v2-table';
+        var thead = document.createElement('thead');
+        var headRow = document.createElement('tr');
+        [t.user, t.share, t.files, t.size, t.lastSync, ''].forEach(function(label) {
+            var th = document.createElement('th');
+            th.textContent = label;
+            headRow.appendChild(th);
+        });
+        thead.appendChild(headRow);
+        table.appendChild(thead);
+
+        var tbody = document.createElement('tbody');
+        backup.shares.forEach(function(share) {
+            var row = document.createElement('tr');
+            addCell(row, share.username || (t.unknownUser + ' #' + share.user_id));
+            addCell(row, share.share_name);
+            if (share.locked) {
+                var lockedCell = document.createElement('td');
+                lockedCell.colSpan = 3;
+                var lockedBadge = document.createElement('span');
+                lockedBadge.className = 'v2-badge v2-badge-warning';
+                lockedBadge.textContent = t.locked;
+                lockedCell.appendChild(lockedBadge);
+                row.appendChild(lockedCell);
+                addCell(row, '');
+            } else {
+                addCell(row, String(share.file_count));
+                addCell(row, formatBytes(share.total_size));
+                addCell(row, new Date(share.last_sync).toLocaleString());
+                var actions = document.createElement('td');
+                actions.style.cssText = 'white-space:nowrap;';
+                actions.appendChild(actionButton(t.browse, 'browse', backup.path, share.dir, 'v2-btn-secondary'));
+                actions.appendChild(document.createTextNode(' '));
+                actions.appendChild(actionButton(t.restoreAll, 'restoreAll', backup.path, share.dir, 'v2-btn-primary'));
+                row.appendChild(actions);
+            }
+            tbody.appendChild(row);
+        });
+        table.appendChild(tbody);
+        block.appendChild(table);
+    }
+    return block;
+}
+
+function actionButton(label, action, path, share, style) {
+    var button = document.createElement('button');
+    button.className = 'v2-btn v2-btn-sm ' + style;
+    button.textContent = label;
+    button.setAttribute('data-action', action);
+    button.setAttribute('data-path', path);
+    if (share) button.setAttribute('data-share', share);
+    return button;
+}
+
+function addCell(row, text) {
+    var cell = document.createElement('td');
+    cell.style.fontSize = '0.8125rem';
+    cell.textContent = text;
+    row.appendChild(cell);
+}
+
+async function browse(path, share) {
+    var params = withKey(new URLSearchParams(), path);
+    params.append('path', path);
+    params.append('share', share);
+    try {
+        var response = await fetch(apiURL + '/files', { method: 'POST', body: params });
+        var result = await response.json();
+        if (!response.ok) throw new Error(result.message || 'Failed to load files');
+        current = { path: path, share: share };
+        currentFiles = result || [];
+        selectedItems.clear();
+        updateSelectionUI();
+        renderFiles('');
+        document.getElementById('file-browser').classList.remove('hidden');
+    } catch (error) { console.error('Error loading USB backup files:', error); alert(t.error + ': ' + error.message); }
+}
+
+function closeBrowser() {
+    current = null;
+    currentFiles = [];
+    selectedItems.clear();
+    document.getElementById('file-browser').classList.add('hidden');
+}
+
+// entriesAt groups the flat file list into the direct children of a directory
+function entriesAt(dir) {
+    var prefix = dir ? dir + '/' : '';
+    var entries = {};
+    currentFiles.forEach(function(file) {
+        if (!file.path.startsWith(prefix)) return;
+        var rest = file.path.substring(prefix.length);
+        var slash = rest.indexOf('/');
+        if (slash === -1) {
+            entries[rest] = { name: rest, path: file.path, is_dir: false, size: file.size, mod_time: file.mod_time };
+            return;
+        }
+        var name = rest.substring(0, slash);
+        var entry = entries[name] || { name: name, path: prefix + name, is_dir: true, size: 0, mod_time: file.mod_time };
+        entry.size += file.size;
+        if (new Date(file.mod_time) > new Date(entry.mod_time)) entry.mod_time = file.mod_time;
+        entries[name] = entry;
+    });
+    return Object.values(entries).sort(function(a, b) {
+        if (a.is_dir !== b.is_dir) return a.is_dir ? -1 : 1;
+        return a.name.localeCompare(b.name);
+    });
+}
+
+function renderFiles(dir) {
+    currentPath = dir;
+    renderBreadcrumb();
+    var fileList = document.getElementById('file-list');
+    fileList.innerHTML = '';
+    entriesAt(dir).forEach(function(item) {
+        var row = document.createElement('tr');
+
+        var checkboxCell = document.createElement('td');
+        var checkbox = document.createElement('input');
+        checkbox.type = 'checkbox';
+        checkbox.style.accentColor = 'var(--info)';
+        checkbox.checked = selectedItems.has(item.path);
+        checkbox.addEventListener('change', function() {
+            if (checkbox.checked) selectedItems.add(item.path); else selectedItems.delete(item.path);
+            updateSelectionUI();
+        });
+        checkboxCell.appendChild(checkbox);
+        row.appendChild(checkboxCell);
+
+        var nameCell = document.createElement('td');
+        nameCell.style.fontSize = '0.8125rem';
+        if (item.is_dir) {
+            var link = document.createElement('a');
+            link.href = '#';
+            link.style.cssText = 'color:var(--info);text-decoration:none;';
+            link.textContent = item.name + '/';
+            link.addEventListener('click', function(e) { e.preventDefault(); renderFiles(item.path); });
+            nameCell.appendChild(link);
+        } else {
+            nameCell.textContent = item.name;
+        }
+        row.appendChild(nameCell);
+
+        var sizeCell = document.createElement('td');
+        sizeCell.style.cssText = 'font-size:0.8125rem;color:var(--text-secondary);';
+        sizeCell.textContent = formatBytes(item.size);
+        row.appendChild(sizeCell);
+
+        var dateCell = document.createElement('td');
+        dateCell.style.cssText = 'font-size:0.8125rem;color:var(--text-secondary);';
+        dateCell.textContent = new Date(item.mod_time).toLocaleString();
+        row.appendChild(dateCell);
+
+        fileList.appendChild(row);
+    });
+}
+
+function renderBreadcrumb() {
+    var breadcrumb = document.getElementById('breadcrumb');
+    breadcrumb.innerHTML = '';
+    var shareName = current ? current.share.substring(current.share.indexOf('_') + 1) : '';
+    var crumbs = [{ name: shareName, path: '' }];
+    var acc = '';
+    currentPath.split('/').filter(function(p) { return p; }).forEach(function(part) {
+        acc = acc ? acc + '/' + part : part;
+        crumbs.push({ name: part, path: acc });
+    });
+    crumbs.forEach(function(crumb, index) {
+        var li = document.createElement('li');
+        li.style.cssText = 'font-size:0.8125rem;color:var(--text-muted);';
+        if (index > 0) li.appendChild(document.createTextNode(' / '));
+        var link = document.createElement('a');
+        link.href = '#';
+        link.style.cssText = 'color:var(--info);text-decoration:none;';
+        link.textContent = crumb.name;
+        link.addEventListener('click', function(e) { e.preventDefault(); renderFiles(crumb.path); });
+        li.appendChild(link);
+        breadcrumb.appendChild(li);
+    });
+}
+
+function updateSelectionUI() {
+    document.getElementById('selection-count').textContent = selectedItems.size > 0 ? selectedItems.size + ' ' + t.selectionCount : t.allFiles;
+}
+
+async function restoreAll(path, share) {
+    if (!confirm(t.confirmAll)) return;
+    await submitRestore(path, share, [], '', false);
+}
+
+async function startRestore() {
+    if (!current) return;
+    var ok = await submitRestore(current.path, current.share, Array.from(selectedItems),
+        document.getElementById('target-dir').value.trim(), document.getElementById('overwrite').checked);
+    if (ok) closeBrowser();
+}
+
+async function submitRestore(path, share, paths, targetDir, overwrite) {
+    var body = withKey(new URLSearchParams(), path);
+    body.append('path', path);
+    body.append('share', share);
+    body.append('target_dir', targetDir);
+    if (overwrite) body.append('overwrite', 'on');
+    paths.forEach(function(p) { body.append('paths', p); });
+    try {
+        var response = await fetch(apiURL + '/start', { method: 'POST', body: body });
+        var result = await response.json();
+        if (!response.ok || !result.success) throw new Error(result.message || 'Restore failed');
+        loadJobs();
+        return true;
+    } catch (error) {
+        console.error('Restore error:', error);
+        alert(t.error + ': ' + error.message);
+        return false;
+    }
+}
+
+async function loadJobs() {
+    try {
+        var response = await fetch(apiURL + '/jobs');
+        if (!response.ok) return;
+        var jobs = await response.json() || [];
+        renderJobs(jobs);
+        var running = jobs.some(function(j) { return j.status === 'running'; });
+        if (jobsTimer) { clearTimeout(jobsTimer); jobsTimer = null; }
+        if (running) jobsTimer = setTimeout(loadJobs, 2000);
+    } catch (error) { console.error('Error loading restore jobs:', error); }
+}
+
+function renderJobs(jobs) {
+    var card = document.getElementById('jobs-card');
+    var tbody = document.getElementById('jobs');
+    if (jobs.length === 0) { card.classList.add('hidden'); return; }
+    card.classList.remove('hidden');
+    tbody.innerHTML = '';
+    jobs.forEach(function(job) {
+        var row = document.createElement('tr');
+
+        var started = document.createElement('td');
+        started.style.cssText = 'font-size:0.8125rem;color:var(--text-secondary);';
+        started.textContent = new Date(job.started_at).toLocaleString();
+        row.appendChild(started);
+
+        var source = document.createElement('td');
+        source.style.fontSize = '0.8125rem';
+        source.textContent = job.share_name;
+        row.appendChild(source);
+
+        var target = document.createElement('td');
+        target.style.cssText = 'font-size:0.75rem;color:var(--text-secondary);font-family:monospace;';
+        target.textContent = job.target_dir;
+        row.appendChild(target);
+
+        var progress = document.createElement('td');
+        var percent = job.total_files > 0 ? Math.floor(job.processed_files * 100 / job.total_files) : (job.status === 'running' ? 0 : 100);
+        var bar = document.createElement('div');
+        bar.style.cssText = 'width:120px;height:6px;border-radius:3px;background:var(--bg-page);overflow:hidden;';
+        var fill = document.createElement('div');
+        fill.style.cssText = 'height:100%;border-radius:3px;background:var(--info);width:' + percent + '%;';
+        bar.appendChild(fill);
+        progress.appendChild(bar);
+        var counts = document.createElement('div');
+        counts.style.cssText = 'font-size:0.7rem;color:var(--text-muted);margin-top:0.25rem;';
+        var text = job.processed_files + ' / ' + job.total_files + ' (' + formatBytes(job.processed_bytes) + ')';
+        if (job.skipped_files > 0) text += ', ' + job.skipped_files + ' ' + t.jobSkipped;
+        if (job.failed_files > 0) text += ', ' + job.failed_files + ' ' + t.jobFailed;
+        counts.textContent = text;
+        progress.appendChild(counts);
+        row.appendChild(progress);
+
+        var status = document.createElement('td');
+        var badge = document.createElement('span');
+        var labels = {
+            running: [t.jobRunning, 'v2-badge-info'],
+            success: [t.jobSuccess, 'v2-badge-success'],
+            partial: [t.jobPartial, 'v2-badge-warning'],
+            error: [t.jobError, 'v2-badge-error']
+        };
+        var label = labels[job.status] || [job.status, ''];
+        badge.className = 'v2-badge ' + label[1];
+        badge.textContent = label[0];
+        var details = [];
+        if (job.error) details.push(job.error);
+        if (job.errors) details = details.concat(job.errors);
+        if (details.length > 0) badge.title = details.join('\n');
+        status.appendChild(badge);
+        row.appendChild(status);
+
+        tbody.appendChild(row);
+    });
+}
+
+function formatBytes(bytes) {
+    if (!bytes) return '0 B';
+    var k = 1024;
+    var sizes = ['B', 'KB', 'MB', 'GB', 'TB'];
+    var i = Math.floor(Math.log(bytes) / Math.log(k));
+    return parseFloat((bytes / Math.pow(k, i)).toFixed(2)) + ' ' + sizes[i];
+}